@@ -6,11 +6,10 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
 	"github.com/criteo/command-launcher-registry/internal/cli"
 )
 
-var version = "1.0.0"
-
 // rootCmd represents the base command
 var rootCmd = &cobra.Command{
 	Use:   "cola-registry",
@@ -18,13 +17,17 @@ var rootCmd = &cobra.Command{
 	Long: `COLA Registry Server provides a REST API for managing Command Launcher
 remote registries. It serves registry indexes and provides full CRUD operations
 for registries, packages, and versions.`,
-	Version: version,
+	Version: buildinfo.Version,
 }
 
 func init() {
 	// Add subcommands
 	rootCmd.AddCommand(cli.ServerCmd)
+	rootCmd.AddCommand(cli.StandaloneCmd)
 	rootCmd.AddCommand(cli.AuthCmd)
+	rootCmd.AddCommand(cli.StorageCmd)
+	rootCmd.AddCommand(cli.MigrateCmd)
+	rootCmd.AddCommand(cli.OperatorCmd)
 
 	// Set version template
 	rootCmd.SetVersionTemplate(`{{.Version}}
@@ -0,0 +1,9 @@
+// Package docs embeds the API description served and enforced by the server.
+package docs
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI description of the REST API (openapi.yaml)
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
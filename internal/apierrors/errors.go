@@ -22,6 +22,24 @@ const (
 	ErrCodePartitionOverlap      ErrorCode = "PARTITION_OVERLAP"
 	ErrCodeStorageUnavailable    ErrorCode = "STORAGE_UNAVAILABLE"
 	ErrCodeUnauthorized          ErrorCode = "UNAUTHORIZED"
+	ErrCodeInternalError         ErrorCode = "INTERNAL_ERROR"
+	ErrCodeGenerationAhead       ErrorCode = "SYNC_GENERATION_AHEAD"
+	ErrCodeVersionNotScheduled   ErrorCode = "VERSION_NOT_SCHEDULED"
+	ErrCodeRequestTimeout        ErrorCode = "REQUEST_TIMEOUT"
+	ErrCodeStorageLoading        ErrorCode = "STORAGE_LOADING"
+	ErrCodePackageNameTaken      ErrorCode = "PACKAGE_NAME_TAKEN"
+	ErrCodeSchemaNotFound        ErrorCode = "SCHEMA_NOT_FOUND"
+	ErrCodeStorageTimeout        ErrorCode = "STORAGE_TIMEOUT"
+	ErrCodeWriteQueueFull        ErrorCode = "WRITE_QUEUE_FULL"
+	ErrCodeStorageReadOnly       ErrorCode = "STORAGE_READ_ONLY"
+	ErrCodeLockUnresolved        ErrorCode = "LOCK_UNRESOLVED"
+	ErrCodePreconditionFailed    ErrorCode = "PRECONDITION_FAILED"
+	ErrCodeAPIKeyNotFound        ErrorCode = "API_KEY_NOT_FOUND"
+	ErrCodeStorageConflict       ErrorCode = "STORAGE_CONFLICT"
+	ErrCodeBackupNotFound        ErrorCode = "BACKUP_NOT_FOUND"
+
+	// A version the storage backend does not keep was asked for a rollback
+	ErrCodeStorageVersionNotFound ErrorCode = "STORAGE_VERSION_NOT_FOUND"
 )
 
 // ErrorResponse represents the standard error response format
@@ -63,6 +81,8 @@ func MapStorageError(err error, resourceType string) (ErrorCode, string, int) {
 			return ErrCodePackageNotFound, "Package not found", http.StatusNotFound
 		case "version":
 			return ErrCodeVersionNotFound, "Version not found", http.StatusNotFound
+		case "api_key":
+			return ErrCodeAPIKeyNotFound, "API key not found", http.StatusNotFound
 		default:
 			return ErrCodeRegistryNotFound, "Resource not found", http.StatusNotFound
 		}
@@ -82,13 +102,47 @@ func MapStorageError(err error, resourceType string) (ErrorCode, string, int) {
 	case storage.ErrStorageUnavailable:
 		return ErrCodeStorageUnavailable, "Storage service unavailable", http.StatusServiceUnavailable
 
+	case storage.ErrTimeout:
+		return ErrCodeStorageTimeout, "Storage operation timed out", http.StatusGatewayTimeout
+
+	case storage.ErrConflictRemoteModified:
+		return ErrCodeStorageConflict, "Storage was modified by another server instance; retry the request", http.StatusConflict
+
+	case storage.ErrReadOnly:
+		return ErrCodeStorageReadOnly, "Storage backend is unavailable, the server is serving cached data read-only", http.StatusServiceUnavailable
+
 	case storage.ErrImmutabilityViolation:
 		return ErrCodeVersionAlreadyExists, "Version already exists (immutability violation)", http.StatusConflict
 
 	case storage.ErrPartitionOverlap:
 		return ErrCodePartitionOverlap, "Partition ranges overlap with existing version", http.StatusBadRequest
 
+	case storage.ErrEncryptionNotConfigured:
+		return ErrCodeValidationError, "sensitive_keys require the server to be configured with an encryption key", http.StatusBadRequest
+
+	case storage.ErrGenerationAhead:
+		return ErrCodeGenerationAhead, "Sync generation is ahead of the server; resync from generation 0", http.StatusGone
+
+	case storage.ErrNotScheduled:
+		return ErrCodeVersionNotScheduled, "Version is not scheduled for publication (already published)", http.StatusConflict
+
+	case storage.ErrPackageNameTaken:
+		return ErrCodePackageNameTaken, "Package name is already used in another registry", http.StatusConflict
+
 	default:
 		return ErrCodeStorageUnavailable, "Internal server error", http.StatusInternalServerError
 	}
 }
+
+// WriteStorageFailure writes the response for a storage error a handler
+// does not expect: 504 STORAGE_TIMEOUT when the operation ran out of time,
+// 503 STORAGE_READ_ONLY when serving cached data, 409 STORAGE_CONFLICT when
+// another instance changed the stored data, 500 with message otherwise
+func WriteStorageFailure(w http.ResponseWriter, err error, message string) {
+	if err == storage.ErrTimeout || err == storage.ErrReadOnly || err == storage.ErrConflictRemoteModified {
+		code, msg, status := MapStorageError(err, "")
+		WriteError(w, code, msg, status, nil)
+		return
+	}
+	WriteError(w, ErrCodeStorageUnavailable, message, http.StatusInternalServerError, nil)
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// APIKeyLookup returns a stored API key by ID
+type APIKeyLookup func(ctx context.Context, id string) (*models.APIKey, error)
+
+// APIKeyAuth authenticates API keys, sent as HTTP Basic credentials with
+// the key ID as username, and passes other credentials to the wrapped
+// authenticator. Users authenticated with a key carry it, so routes can
+// enforce its restrictions.
+type APIKeyAuth struct {
+	next   Authenticator
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	lookup APIKeyLookup // nil until storage is loaded
+}
+
+// NewAPIKeyAuth creates an authenticator accepting API keys besides the
+// credentials of next. Keys are rejected until SetLookup is called.
+func NewAPIKeyAuth(next Authenticator, logger *slog.Logger) *APIKeyAuth {
+	return &APIKeyAuth{next: next, logger: logger}
+}
+
+// SetLookup sets where API keys are read from
+func (a *APIKeyAuth) SetLookup(lookup APIKeyLookup) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lookup = lookup
+}
+
+// Authenticate validates an API key, or the credentials of the wrapped
+// authenticator
+func (a *APIKeyAuth) Authenticate(r *http.Request) (*User, error) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || !models.IsAPIKeyID(id) {
+		return a.next.Authenticate(r)
+	}
+
+	a.mu.RLock()
+	lookup := a.lookup
+	a.mu.RUnlock()
+	if lookup == nil {
+		return nil, fmt.Errorf("invalid credentials")
+	}
+	key, err := lookup(r.Context(), id)
+	if err != nil || !key.Matches(secret) {
+		a.logger.Warn("Authentication failed: invalid API key",
+			"key_id", id,
+			"source_ip", r.RemoteAddr)
+		return nil, fmt.Errorf("invalid credentials")
+	}
+	if key.Expired(time.Now()) {
+		a.logger.Warn("Authentication failed: expired API key",
+			"key_id", id,
+			"registry", key.Registry,
+			"source_ip", r.RemoteAddr)
+		return nil, fmt.Errorf("invalid credentials")
+	}
+
+	a.logger.Debug("Authentication successful",
+		"key_id", id,
+		"registry", key.Registry,
+		"source_ip", r.RemoteAddr)
+	return &User{Username: id, APIKey: key}, nil
+}
+
+// Middleware returns HTTP Basic Auth middleware accepting API keys
+func (a *APIKeyAuth) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := a.Authenticate(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Basic realm="COLA Registry"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+		})
+	}
+}
@@ -1,12 +1,46 @@
 package auth
 
 import (
+	"context"
 	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
 )
 
+// ScopeAdmin grants access to administrative data such as sensitive custom values
+const ScopeAdmin = "admin"
+
 // User represents an authenticated user
 type User struct {
 	Username string
+	Scopes   []string
+	APIKey   *models.APIKey // Set for API keys, whose restrictions apply
+}
+
+// HasScope reports whether the user has been granted a scope
+func (u *User) HasScope(scope string) bool {
+	if u == nil {
+		return false
+	}
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type userContextKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user stored in ctx, or nil
+func UserFromContext(ctx context.Context) *User {
+	user, _ := ctx.Value(userContextKey{}).(*User)
+	return user
 }
 
 // Authenticator defines the authentication interface
@@ -5,15 +5,16 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"sync"
 
-	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
 )
 
 // UserConfig represents a user in the users.yaml file
 type UserConfig struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"` // bcrypt hash
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`         // bcrypt or PBKDF2 hash
+	Scopes   []string `yaml:"scopes,omitempty"` // e.g. [admin]
 }
 
 // UsersFile represents the structure of users.yaml
@@ -23,38 +24,74 @@ type UsersFile struct {
 
 // BasicAuth implements HTTP Basic Authentication
 type BasicAuth struct {
-	users  map[string]string // username -> bcrypt hash
+	mu     sync.RWMutex
+	users  map[string]string   // username -> password hash
+	scopes map[string][]string // username -> granted scopes
 	logger *slog.Logger
 }
 
 // NewBasicAuth creates a new BasicAuth authenticator
 func NewBasicAuth(usersFile string, logger *slog.Logger) (*BasicAuth, error) {
+	users, scopes, err := loadUsersFile(usersFile)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Basic auth initialized",
+		"users_file", usersFile,
+		"user_count", len(users))
+
+	return &BasicAuth{
+		users:  users,
+		scopes: scopes,
+		logger: logger,
+	}, nil
+}
+
+// Reload replaces the users with the content of usersFile.
+// On error the current users are kept.
+func (a *BasicAuth) Reload(usersFile string) error {
+	users, scopes, err := loadUsersFile(usersFile)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.users = users
+	a.scopes = scopes
+	a.mu.Unlock()
+
+	a.logger.Info("Basic auth users reloaded",
+		"users_file", usersFile,
+		"user_count", len(users))
+	return nil
+}
+
+// loadUsersFile reads users.yaml into username -> hash and username -> scopes maps
+func loadUsersFile(usersFile string) (map[string]string, map[string][]string, error) {
 	// Read users file
 	data, err := os.ReadFile(usersFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read users file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read users file: %w", err)
 	}
 
 	// Parse YAML
 	var usersFileData UsersFile
 	if err := yaml.Unmarshal(data, &usersFileData); err != nil {
-		return nil, fmt.Errorf("failed to parse users file (invalid YAML syntax): %w", err)
+		return nil, nil, fmt.Errorf("failed to parse users file (invalid YAML syntax): %w", err)
 	}
 
 	// Build username -> password hash map
 	users := make(map[string]string)
+	scopes := make(map[string][]string)
 	for _, user := range usersFileData.Users {
+		if err := checkHashAllowed(user.Password); err != nil {
+			return nil, nil, fmt.Errorf("user %q: %w", user.Username, err)
+		}
 		users[user.Username] = user.Password
+		scopes[user.Username] = user.Scopes
 	}
-
-	logger.Info("Basic auth initialized",
-		"users_file", usersFile,
-		"user_count", len(users))
-
-	return &BasicAuth{
-		users:  users,
-		logger: logger,
-	}, nil
+	return users, scopes, nil
 }
 
 // Authenticate validates HTTP Basic Auth credentials
@@ -65,7 +102,10 @@ func (a *BasicAuth) Authenticate(r *http.Request) (*User, error) {
 	}
 
 	// Check if user exists
+	a.mu.RLock()
 	hashedPassword, exists := a.users[username]
+	userScopes := a.scopes[username]
+	a.mu.RUnlock()
 	if !exists {
 		a.logger.Warn("Authentication failed: user not found",
 			"username", username,
@@ -74,7 +114,7 @@ func (a *BasicAuth) Authenticate(r *http.Request) (*User, error) {
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+	if err := verifyPassword(hashedPassword, password); err != nil {
 		a.logger.Warn("Authentication failed: invalid password",
 			"username", username,
 			"source_ip", r.RemoteAddr)
@@ -85,7 +125,7 @@ func (a *BasicAuth) Authenticate(r *http.Request) (*User, error) {
 		"username", username,
 		"source_ip", r.RemoteAddr)
 
-	return &User{Username: username}, nil
+	return &User{Username: username, Scopes: userScopes}, nil
 }
 
 // Middleware returns HTTP Basic Auth middleware
@@ -100,19 +140,7 @@ func (a *BasicAuth) Middleware() func(http.Handler) http.Handler {
 				return
 			}
 
-			// Store user in context (if needed in future)
-			_ = user
-
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
 		})
 	}
 }
-
-// HashPassword hashes a password using bcrypt
-func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hash), nil
-}
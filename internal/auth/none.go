@@ -12,9 +12,10 @@ func NewNoAuth() *NoAuth {
 	return &NoAuth{}
 }
 
-// Authenticate always returns a dummy user (no authentication)
+// Authenticate always returns a dummy user (no authentication).
+// Since every request is allowed, the anonymous user holds the admin scope.
 func (a *NoAuth) Authenticate(r *http.Request) (*User, error) {
-	return &User{Username: "anonymous"}, nil
+	return &User{Username: "anonymous", Scopes: []string{ScopeAdmin}}, nil
 }
 
 // Middleware returns a no-op middleware (passes all requests through)
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/criteo/command-launcher-registry/internal/fips"
+)
+
+// Password hash algorithms accepted in users.yaml
+const (
+	HashBcrypt = "bcrypt"
+	HashPBKDF2 = "pbkdf2-sha256" // FIPS-approved
+)
+
+// pbkdf2Iterations follows current OWASP guidance for PBKDF2-HMAC-SHA256
+const pbkdf2Iterations = 600000
+
+// pbkdf2Prefix starts PBKDF2 hashes:
+// $pbkdf2-sha256$<iterations>$<base64 salt>$<base64 key>
+const pbkdf2Prefix = "$" + HashPBKDF2 + "$"
+
+var errPasswordMismatch = errors.New("password does not match")
+
+// HashPassword hashes a password with bcrypt, or PBKDF2 in FIPS mode
+func HashPassword(password string) (string, error) {
+	if fips.Enabled() {
+		return HashPasswordWith(password, HashPBKDF2)
+	}
+	return HashPasswordWith(password, HashBcrypt)
+}
+
+// HashPasswordWith hashes a password with the given algorithm
+func HashPasswordWith(password, algorithm string) (string, error) {
+	switch algorithm {
+	case HashBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	case HashPBKDF2:
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key, err := pbkdf2.Key(sha256.New, password, salt, pbkdf2Iterations, sha256.Size)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s%d$%s$%s", pbkdf2Prefix, pbkdf2Iterations,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	return "", fmt.Errorf("unsupported password hash algorithm %q, expected %s or %s", algorithm, HashBcrypt, HashPBKDF2)
+}
+
+// hashAlgorithm returns the algorithm of a password hash
+func hashAlgorithm(hash string) string {
+	if strings.HasPrefix(hash, pbkdf2Prefix) {
+		return HashPBKDF2
+	}
+	return HashBcrypt
+}
+
+// checkHashAllowed rejects hashes whose algorithm the current mode forbids
+func checkHashAllowed(hash string) error {
+	if fips.Enabled() && hashAlgorithm(hash) != HashPBKDF2 {
+		return fmt.Errorf("%s password hashes are not allowed in FIPS mode, rehash with %s", hashAlgorithm(hash), HashPBKDF2)
+	}
+	return nil
+}
+
+// verifyPassword checks a password against a bcrypt or PBKDF2 hash
+func verifyPassword(hash, password string) error {
+	if hashAlgorithm(hash) == HashBcrypt {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	}
+
+	parts := strings.Split(strings.TrimPrefix(hash, pbkdf2Prefix), "$")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed %s hash", HashPBKDF2)
+	}
+	iterations, err := strconv.Atoi(parts[0])
+	if err != nil || iterations < 1 {
+		return fmt.Errorf("malformed %s hash: invalid iteration count", HashPBKDF2)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed %s hash: invalid salt", HashPBKDF2)
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil || len(expected) == 0 {
+		return fmt.Errorf("malformed %s hash: invalid key", HashPBKDF2)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return errPasswordMismatch
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/fips"
+)
+
+func TestHashPasswordWith(t *testing.T) {
+	for _, algorithm := range []string{HashBcrypt, HashPBKDF2} {
+		hash, err := HashPasswordWith("s3cret", algorithm)
+		require.NoError(t, err)
+		assert.Equal(t, algorithm, hashAlgorithm(hash))
+
+		assert.NoError(t, verifyPassword(hash, "s3cret"), algorithm)
+		assert.Error(t, verifyPassword(hash, "wrong"), algorithm)
+	}
+
+	_, err := HashPasswordWith("s3cret", "md5")
+	assert.Error(t, err)
+	assert.Error(t, verifyPassword("$pbkdf2-sha256$600000$not-base64!", "s3cret"))
+}
+
+// Runs last: FIPS mode cannot be turned off within the process
+func TestCheckHashAllowed_FIPS(t *testing.T) {
+	bcryptHash, err := HashPasswordWith("s3cret", HashBcrypt)
+	require.NoError(t, err)
+	if !fips.Enabled() {
+		require.NoError(t, checkHashAllowed(bcryptHash))
+	}
+
+	fips.Enable()
+	err = checkHashAllowed(bcryptHash)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not allowed in FIPS mode")
+
+	hash, err := HashPassword("s3cret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$pbkdf2-sha256$"))
+	assert.NoError(t, checkHashAllowed(hash))
+}
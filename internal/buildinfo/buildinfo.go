@@ -0,0 +1,19 @@
+// Package buildinfo holds the release version shared by the server and the
+// CLI client, which are built and released together.
+package buildinfo
+
+import (
+	"runtime"
+	"strings"
+)
+
+// Version is the release version, overridden at build time with
+// -ldflags "-X github.com/criteo/command-launcher-registry/internal/buildinfo.Version=1.2.3"
+var Version = "1.0.0"
+
+// UserAgent returns the User-Agent of a program of this release, with the
+// platform and the given comments, e.g. "cola-regctl/1.2.3 (linux; amd64)"
+func UserAgent(program string, comments ...string) string {
+	comments = append([]string{runtime.GOOS, runtime.GOARCH}, comments...)
+	return program + "/" + Version + " (" + strings.Join(comments, "; ") + ")"
+}
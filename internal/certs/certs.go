@@ -0,0 +1,175 @@
+// Package certs provides the TLS configuration of the server: a certificate
+// loaded from files, or certificates obtained and renewed through ACME
+// (e.g. Let's Encrypt) and kept in the storage backend.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
+	"github.com/criteo/command-launcher-registry/internal/config"
+	"github.com/criteo/command-launcher-registry/internal/fips"
+	"github.com/criteo/command-launcher-registry/internal/secrets"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// Cache keeps ACME certificates and the account key in the storage
+// backend, so every instance sharing it uses the same certificates and a
+// restart does not request new ones. Entries are encrypted when an
+// encryption key is configured.
+//
+// The server accepts connections before storage is loaded; lookups wait
+// for SetStore rather than report a miss, which would request a new
+// certificate on each start.
+type Cache struct {
+	cipher *secrets.Cipher // nil stores entries in clear
+	ready  chan struct{}
+	once   sync.Once
+	store  storage.Store
+}
+
+// NewCache creates a cache; cipher may be nil
+func NewCache(cipher *secrets.Cipher) *Cache {
+	return &Cache{cipher: cipher, ready: make(chan struct{})}
+}
+
+// SetStore sets the store once loaded, releasing waiting lookups
+func (c *Cache) SetStore(store storage.Store) {
+	c.once.Do(func() {
+		c.store = store
+		close(c.ready)
+	})
+}
+
+// wait returns the store once set, or the context's error
+func (c *Cache) wait(ctx context.Context) (storage.Store, error) {
+	select {
+	case <-c.ready:
+		return c.store, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Get returns a cached entry, autocert.ErrCacheMiss when there is none
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	store, err := c.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.GetCertificate(ctx, key)
+	if err == storage.ErrNotFound {
+		return nil, autocert.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !secrets.IsEncrypted(string(data)) {
+		return data, nil
+	}
+	if c.cipher == nil {
+		return nil, fmt.Errorf("certificate %q is encrypted but no encryption key is configured", key)
+	}
+	plain, err := c.cipher.Decrypt(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("certificate %q: %w", key, err)
+	}
+	return []byte(plain), nil
+}
+
+// Put stores an entry, encrypted when a cipher is configured
+func (c *Cache) Put(ctx context.Context, key string, data []byte) error {
+	store, err := c.wait(ctx)
+	if err != nil {
+		return err
+	}
+	if c.cipher != nil {
+		encrypted, err := c.cipher.Encrypt(string(data))
+		if err != nil {
+			return err
+		}
+		data = []byte(encrypted)
+	}
+	return store.PutCertificate(ctx, key, data)
+}
+
+// Delete removes an entry; a missing entry is not an error
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	store, err := c.wait(ctx)
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteCertificate(ctx, key); err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// NewManager creates the ACME certificate manager for the configured
+// domains, agreeing to the CA's terms of service
+func NewManager(cfg config.ACMEConfig, cache autocert.Cache) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      cache,
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+		Client: &acme.Client{
+			DirectoryURL: cfg.DirectoryURL,
+			UserAgent:    buildinfo.UserAgent("cola-registry"),
+		},
+	}
+}
+
+// ServerConfig returns the TLS configuration of the HTTPS listener:
+// the certificate files of cfg, or certificates from manager when ACME is
+// enabled (manager is ignored otherwise). It answers TLS-ALPN-01
+// challenges itself.
+func ServerConfig(cfg config.TLSConfig, manager *autocert.Manager) (*tls.Config, error) {
+	var tlsConfig *tls.Config
+	if len(cfg.ACME.Domains) > 0 {
+		tlsConfig = manager.TLSConfig()
+	} else {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	tlsConfig.MinVersion = tls.VersionTLS12
+	fips.RestrictTLSConfig(tlsConfig)
+	return tlsConfig, nil
+}
+
+// ServeHTTPChallenges binds addr and answers ACME HTTP-01 challenges on
+// it, redirecting every other request to HTTPS. The returned server is
+// closed by the caller on shutdown.
+func ServeHTTPChallenges(addr string, manager *autocert.Manager, logger *slog.Logger) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind ACME challenge address: %w", err)
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           manager.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("ACME challenge server stopped", "error", err, "address", addr)
+		}
+	}()
+	logger.Info("Answering ACME HTTP-01 challenges", "address", addr)
+	return server, nil
+}
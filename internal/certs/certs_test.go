@@ -0,0 +1,113 @@
+package certs
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/criteo/command-launcher-registry/internal/config"
+	"github.com/criteo/command-launcher-registry/internal/secrets"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func newTestStore(t *testing.T) *storage.FileStorage {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	return store
+}
+
+func TestCache_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	cache := NewCache(nil)
+	cache.SetStore(store)
+
+	_, err := cache.Get(ctx, "registry.example.com")
+	assert.ErrorIs(t, err, autocert.ErrCacheMiss)
+
+	require.NoError(t, cache.Put(ctx, "registry.example.com", []byte("PEM")))
+	data, err := cache.Get(ctx, "registry.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("PEM"), data)
+
+	require.NoError(t, cache.Delete(ctx, "registry.example.com"))
+	require.NoError(t, cache.Delete(ctx, "registry.example.com"))
+	_, err = cache.Get(ctx, "registry.example.com")
+	assert.ErrorIs(t, err, autocert.ErrCacheMiss)
+}
+
+func TestCache_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	key := make([]byte, secrets.KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	cipher, err := secrets.NewCipher(key)
+	require.NoError(t, err)
+
+	store := newTestStore(t)
+	cache := NewCache(cipher)
+	cache.SetStore(store)
+	require.NoError(t, cache.Put(ctx, "acme_account+key", []byte("PRIVATE KEY")))
+
+	// Stored encrypted, returned in clear
+	stored, err := store.GetCertificate(ctx, "acme_account+key")
+	require.NoError(t, err)
+	assert.True(t, secrets.IsEncrypted(string(stored)))
+	data, err := cache.Get(ctx, "acme_account+key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("PRIVATE KEY"), data)
+
+	// Without the key, the entry cannot be read
+	plain := NewCache(nil)
+	plain.SetStore(store)
+	_, err = plain.Get(ctx, "acme_account+key")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, autocert.ErrCacheMiss)
+}
+
+func TestCache_WaitsForStore(t *testing.T) {
+	cache := NewCache(nil)
+
+	// Lookups during storage loading wait instead of missing
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := cache.Get(ctx, "registry.example.com")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(context.Background(), "registry.example.com")
+		done <- err
+	}()
+	cache.SetStore(newTestStore(t))
+	assert.ErrorIs(t, <-done, autocert.ErrCacheMiss)
+}
+
+func TestServerConfig(t *testing.T) {
+	cfg := config.TLSConfig{ACME: config.ACMEConfig{
+		Domains:      []string{"registry.example.com"},
+		DirectoryURL: "https://acme.example.com/directory",
+	}}
+	manager := NewManager(cfg.ACME, NewCache(nil))
+
+	tlsConfig, err := ServerConfig(cfg, manager)
+	require.NoError(t, err)
+	assert.NotNil(t, tlsConfig.GetCertificate)
+	assert.Contains(t, tlsConfig.NextProtos, "acme-tls/1")
+
+	// Hosts outside the configured domains are refused
+	assert.Error(t, manager.HostPolicy(context.Background(), "other.example.com"))
+	assert.NoError(t, manager.HostPolicy(context.Background(), "registry.example.com"))
+
+	_, err = ServerConfig(config.TLSConfig{CertFile: "missing.crt", KeyFile: "missing.key"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+// Package checksum parses and computes the archive checksums registered on
+// versions, written "<algorithm>:<hex digest>" (e.g. "sha512:9b71d2...").
+// It is shared by the server, which validates checksums, and the CLI client,
+// which computes and verifies them.
+package checksum
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"lukechampine.com/blake3"
+)
+
+// Supported algorithms
+const (
+	SHA256 = "sha256"
+	SHA512 = "sha512"
+	BLAKE3 = "blake3" // 256-bit digest
+)
+
+// Algorithms lists the supported algorithms, the default first
+var Algorithms = []string{SHA256, SHA512, BLAKE3}
+
+// FIPSApproved reports whether an algorithm is FIPS-approved; blake3 is not
+func FIPSApproved(algorithm string) bool {
+	return algorithm == SHA256 || algorithm == SHA512
+}
+
+// ErrUnsupportedAlgorithm is returned for an algorithm not in Algorithms
+var ErrUnsupportedAlgorithm = errors.New("unsupported checksum algorithm")
+
+// New returns a hash computing the given algorithm
+func New(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	case BLAKE3:
+		return blake3.New(32, nil), nil
+	}
+	return nil, fmt.Errorf("%w %q, expected one of %s", ErrUnsupportedAlgorithm, algorithm, strings.Join(Algorithms, ", "))
+}
+
+// Algorithm returns the algorithm prefix of a checksum, without validating it
+func Algorithm(checksum string) string {
+	algorithm, _, _ := strings.Cut(checksum, ":")
+	return algorithm
+}
+
+// Pattern returns a regular expression matching the checksums Parse
+// accepts, for schemas
+func Pattern() string {
+	alternatives := make([]string, len(Algorithms))
+	for i, algorithm := range Algorithms {
+		h, _ := New(algorithm)
+		alternatives[i] = fmt.Sprintf("%s:[a-f0-9]{%d}", algorithm, 2*h.Size())
+	}
+	return "^(" + strings.Join(alternatives, "|") + ")$"
+}
+
+// Parse splits a checksum into its algorithm and digest, and checks the
+// digest is lowercase hex of the algorithm's size
+func Parse(checksum string) (algorithm, digest string, err error) {
+	algorithm, digest, found := strings.Cut(checksum, ":")
+	if !found {
+		return "", "", fmt.Errorf("checksum must be <algorithm>:<hex digest>, with algorithm one of %s", strings.Join(Algorithms, ", "))
+	}
+	h, err := New(algorithm)
+	if err != nil {
+		return "", "", err
+	}
+	if len(digest) != 2*h.Size() || strings.Trim(digest, "0123456789abcdef") != "" {
+		return "", "", fmt.Errorf("%s digest must be %d lowercase hexadecimal characters", algorithm, 2*h.Size())
+	}
+	return algorithm, digest, nil
+}
+
+// Compute returns the checksum of r's content
+func Compute(r io.Reader, algorithm string) (string, error) {
+	h, err := New(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return algorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// File returns the checksum of a file
+func File(path, algorithm string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return Compute(f, algorithm)
+}
@@ -0,0 +1,44 @@
+package checksum
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompute(t *testing.T) {
+	for algorithm, expected := range map[string]string{
+		SHA256: "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		SHA512: "sha512:9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+		BLAKE3: "blake3:ea8f163db38682925e4491c5e58d4bb3506ef8c14eb78a86e908c5624a67200f",
+	} {
+		sum, err := Compute(strings.NewReader("hello"), algorithm)
+		require.NoError(t, err)
+		assert.Equal(t, expected, sum)
+
+		parsed, digest, err := Parse(sum)
+		require.NoError(t, err)
+		assert.Equal(t, algorithm, parsed)
+		assert.Equal(t, strings.TrimPrefix(sum, algorithm+":"), digest)
+	}
+
+	_, err := Compute(strings.NewReader("hello"), "md5")
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
+
+func TestParse(t *testing.T) {
+	for _, sum := range []string{
+		"",
+		strings.Repeat("a", 64),
+		"md5:" + strings.Repeat("a", 32),
+		"sha256:" + strings.Repeat("a", 63),
+		"sha256:" + strings.Repeat("A", 64),
+		"sha512:" + strings.Repeat("a", 64),
+		"blake3:" + strings.Repeat("g", 64),
+	} {
+		_, _, err := Parse(sum)
+		assert.Error(t, err, sum)
+	}
+}
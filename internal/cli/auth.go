@@ -20,16 +20,28 @@ var AuthCmd = &cobra.Command{
 // HashPasswordCmd represents the hash-password command
 var HashPasswordCmd = &cobra.Command{
 	Use:   "hash-password",
-	Short: "Generate bcrypt hash for a password",
-	Long:  `Generate a bcrypt hash for a password to use in users.yaml file.`,
-	RunE:  runHashPassword,
+	Short: "Generate a password hash for users.yaml",
+	Long: `Generate a password hash to use in users.yaml file.
+
+The hash uses bcrypt, or PBKDF2-HMAC-SHA256 in FIPS builds; --algorithm
+chooses explicitly, e.g. to prepare users.yaml for a server running with
+crypto.fips enabled.`,
+	RunE: runHashPassword,
 }
 
+var hashAlgorithm string
+
 func init() {
 	AuthCmd.AddCommand(HashPasswordCmd)
+
+	HashPasswordCmd.Flags().StringVar(&hashAlgorithm, "algorithm", "", "Hash algorithm (bcrypt|pbkdf2-sha256); default bcrypt, pbkdf2-sha256 in FIPS builds")
 }
 
 func runHashPassword(cmd *cobra.Command, args []string) error {
+	if hashAlgorithm != "" && hashAlgorithm != auth.HashBcrypt && hashAlgorithm != auth.HashPBKDF2 {
+		return fmt.Errorf("invalid --algorithm %q, expected %s or %s", hashAlgorithm, auth.HashBcrypt, auth.HashPBKDF2)
+	}
+
 	// Prompt for password
 	fmt.Print("Enter password: ")
 
@@ -45,14 +57,19 @@ func runHashPassword(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("password cannot be empty")
 	}
 
-	// Generate bcrypt hash
-	hash, err := auth.HashPassword(password)
+	// Generate hash
+	var hash string
+	if hashAlgorithm == "" {
+		hash, err = auth.HashPassword(password)
+	} else {
+		hash, err = auth.HashPasswordWith(password, hashAlgorithm)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	// Output hash
-	fmt.Println("\nBcrypt hash (use this in users.yaml):")
+	fmt.Println("\nPassword hash (use this in users.yaml):")
 	fmt.Println(hash)
 
 	return nil
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/criteo/command-launcher-registry/internal/server"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// MigrateCmd represents the migrate command
+var MigrateCmd = &cobra.Command{
+	Use:   "migrate --from <uri> --to <uri>",
+	Short: "Copy registry data from one storage backend to another",
+	Long: `Load the registry data of one storage backend and persist it to another,
+e.g. from file:// to oci://, then reload the target and check it holds the
+same data. Any storage URI the server accepts can be used on either side.
+
+The copy keeps the sync state, so cola-regctl sync clients and replicas
+carry on from their generation once the server points at the new backend.
+Stop writing to the source (or stop the server) while migrating: writes made
+meanwhile are not copied.
+
+A target that already holds registries is refused unless --force is given.
+The target is written with --codec, --compression and the encryption key,
+like the server would with the same storage settings.`,
+	Example: `  cola-registry migrate --from file://./data/registry.json --to oci://ghcr.io/acme/registry --to-token "$GHCR_TOKEN"
+  cola-registry migrate --from s3://s3.amazonaws.com/old/registry.json --to file:///data/registry.json --force`,
+	Args: cobra.NoArgs,
+	RunE: runMigrate,
+}
+
+func init() {
+	MigrateCmd.Flags().String("from", "", "Source storage URI")
+	MigrateCmd.Flags().String("to", "", "Target storage URI")
+	MigrateCmd.Flags().String("from-token", "", "Storage token of the source backend")
+	MigrateCmd.Flags().String("to-token", "", "Storage token of the target backend")
+	MigrateCmd.Flags().String("codec", "json", "Target codec (json|cbor)")
+	MigrateCmd.Flags().String("compression", "none", "Target compression (none|gzip|zstd)")
+	MigrateCmd.Flags().String("encryption-key-file", "", "File holding the base64 storage encryption key, for both backends")
+	MigrateCmd.Flags().Bool("force", false, "Overwrite a target that already holds registries")
+	MigrateCmd.Flags().Duration("timeout", 10*time.Minute, "Limit on the whole migration")
+	MigrateCmd.Flags().String("log-level", "warn", "Log level (debug|info|warn|error)")
+	MigrateCmd.MarkFlagRequired("from")
+	MigrateCmd.MarkFlagRequired("to")
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	fromRaw, _ := cmd.Flags().GetString("from")
+	toRaw, _ := cmd.Flags().GetString("to")
+	fromToken, _ := cmd.Flags().GetString("from-token")
+	toToken, _ := cmd.Flags().GetString("to-token")
+	codecName, _ := cmd.Flags().GetString("codec")
+	compressionName, _ := cmd.Flags().GetString("compression")
+	keyFile, _ := cmd.Flags().GetString("encryption-key-file")
+	force, _ := cmd.Flags().GetBool("force")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	logLevel, _ := cmd.Flags().GetString("log-level")
+
+	from, err := storage.ParseStorageURI(fromRaw)
+	if err != nil {
+		return fmt.Errorf("invalid --from: %w", err)
+	}
+	to, err := storage.ParseStorageURI(toRaw)
+	if err != nil {
+		return fmt.Errorf("invalid --to: %w", err)
+	}
+	if from.String() == to.String() {
+		return fmt.Errorf("--from and --to must be different backends")
+	}
+	codec, err := storage.ParseCodec(codecName)
+	if err != nil {
+		return err
+	}
+	compression, err := storage.ParseCompression(compressionName)
+	if err != nil {
+		return err
+	}
+	opts := storage.Options{Codec: codec, Compression: compression}
+	if keyFile != "" {
+		if opts.Envelope, err = storage.NewEnvelopeFromFile(keyFile); err != nil {
+			return err
+		}
+	}
+
+	logger := server.NewLogger(logLevel, "text")
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := migrateStorage(ctx, from, fromToken, to, toToken, opts, force, logger)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Migrated %d registries, %d packages and %d versions (generation %d, %d bytes): %s -> %s\n",
+		result.Registries, result.Packages, result.Versions, result.Generation, result.Bytes,
+		from.String(), to.String())
+	return nil
+}
+
+// migrateStorage copies the data of from to to, then reloads to from its
+// backend and compares it with from
+func migrateStorage(ctx context.Context, from *storage.StorageURI, fromToken string, to *storage.StorageURI, toToken string, opts storage.Options, force bool, logger *slog.Logger) (*storage.MigrationResult, error) {
+	source, err := storage.NewStorage(from, fromToken, opts, logger.With("storage_role", "source"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source storage: %w", err)
+	}
+	defer source.Close()
+	snapshotter, ok := source.(storage.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("storage scheme %s cannot be migrated from", from.Scheme)
+	}
+
+	target, err := openMigrationTarget(to, toToken, opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	empty, err := storage.IsEmpty(target)
+	if err != nil {
+		target.Close()
+		return nil, fmt.Errorf("failed to read target storage: %w", err)
+	}
+	if !empty && !force {
+		target.Close()
+		return nil, fmt.Errorf("target storage %s already holds registries (use --force to overwrite them)", to.String())
+	}
+
+	result, err := storage.Migrate(ctx, snapshotter, target)
+	if err != nil {
+		target.Close()
+		return nil, err
+	}
+	// Closing flushes the writes of debounced backends
+	if err := target.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close target storage: %w", err)
+	}
+
+	// Check what the target persisted, not what it holds in memory
+	reloaded, err := openMigrationTarget(to, toToken, opts, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload target storage: %w", err)
+	}
+	defer reloaded.Close()
+	if err := storage.VerifyMigration(snapshotter, reloaded); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func openMigrationTarget(uri *storage.StorageURI, token string, opts storage.Options, logger *slog.Logger) (storage.Replacer, error) {
+	backend, err := storage.NewStorage(uri, token, opts, logger.With("storage_role", "target"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load target storage: %w", err)
+	}
+	replacer, ok := backend.(storage.Replacer)
+	if !ok {
+		backend.Close()
+		return nil, fmt.Errorf("storage scheme %s cannot be migrated to", uri.Scheme)
+	}
+	return replacer, nil
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/operator"
+	"github.com/criteo/command-launcher-registry/internal/server"
+)
+
+// OperatorCmd represents the operator command
+var OperatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Reconcile Registry and Package resources of a Kubernetes cluster",
+	Long: `Run as a Kubernetes controller: Registry and Package custom resources
+(deploy/kubernetes/crds.yaml) are reconciled against the API of a registry
+server at every interval, and each resource reports the outcome in its Ready
+status condition. Teams using GitOps (e.g. Argo CD) can then manage
+registries and package metadata as manifests. Versions are still published
+through the API by release pipelines.
+
+The server is only written to when it differs from a spec, with If-Match,
+so concurrent changes are never overwritten blindly. Deleting a resource
+leaves the server untouched unless --prune is given.
+
+In a pod, the Kubernetes API is reached with the pod's service account.
+Elsewhere, point --kube-api at kubectl proxy.
+
+The registry server is authenticated with COLA_REGISTRY_SESSION_TOKEN
+(user:token), as for cola-regctl.`,
+	Example: `  cola-registry operator --url http://cola-registry:8080
+  cola-registry operator --url http://localhost:8080 --kube-api http://127.0.0.1:8001 --namespace tools`,
+	RunE: runOperator,
+}
+
+func init() {
+	OperatorCmd.Flags().String("url", os.Getenv("COLA_REGISTRY_URL"), "Registry server URL (default $COLA_REGISTRY_URL)")
+	OperatorCmd.Flags().String("kube-api", "", "Kubernetes API URL (default: in-cluster service account)")
+	OperatorCmd.Flags().String("kube-token-file", "", "Bearer token file for --kube-api")
+	OperatorCmd.Flags().String("namespace", "", "Only reconcile resources of this namespace (default: all)")
+	OperatorCmd.Flags().Duration("interval", 30*time.Second, "Time between reconciliation passes")
+	OperatorCmd.Flags().Bool("prune", false, "Delete registries and packages from the server along with their resources")
+	OperatorCmd.Flags().String("log-level", "info", "Log level (debug|info|warn|error)")
+	OperatorCmd.Flags().String("log-format", "json", "Log format (json|text)")
+}
+
+func runOperator(cmd *cobra.Command, args []string) error {
+	serverURL, _ := cmd.Flags().GetString("url")
+	kubeAPI, _ := cmd.Flags().GetString("kube-api")
+	kubeTokenFile, _ := cmd.Flags().GetString("kube-token-file")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	prune, _ := cmd.Flags().GetBool("prune")
+	logLevel, _ := cmd.Flags().GetString("log-level")
+	logFormat, _ := cmd.Flags().GetString("log-format")
+
+	if serverURL == "" {
+		return fmt.Errorf("registry server URL is required (--url or COLA_REGISTRY_URL)")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	kube := operator.NewKubeClient(kubeAPI, kubeTokenFile)
+	if kubeAPI == "" {
+		var err error
+		if kube, err = operator.InClusterClient(); err != nil {
+			return err
+		}
+	}
+
+	var token string
+	if session := os.Getenv("COLA_REGISTRY_SESSION_TOKEN"); session != "" {
+		token = base64.StdEncoding.EncodeToString([]byte(session))
+	}
+	api := client.NewClient(strings.TrimSuffix(serverURL, "/"), token, 30*time.Second, false)
+
+	logger := server.NewLogger(logLevel, logFormat)
+	logger.Info("Operator starting",
+		"server", serverURL,
+		"namespace", namespace,
+		"interval", interval.String(),
+		"prune", prune)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	op := operator.New(kube, api, operator.Options{Namespace: namespace, Prune: prune}, logger)
+	err := op.Run(ctx, interval)
+	logger.Info("Operator stopped")
+	return err
+}
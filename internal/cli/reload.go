@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/config"
+	"github.com/criteo/command-launcher-registry/internal/events"
+	"github.com/criteo/command-launcher-registry/internal/server"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// configReloader applies configuration changes to a running server.
+// Only the log level, rate limit, CORS origins, vanity hosts, basic auth
+// users, notification sinks and storage tokens are reloaded; other changes
+// are logged and need a restart.
+type configReloader struct {
+	mu          sync.Mutex
+	viper       *viper.Viper
+	startup     *config.Config // restart-only settings stay as they were here
+	logLevel    *slog.LevelVar
+	server      *server.Server
+	basicAuth   *auth.BasicAuth // nil unless auth.type=basic
+	dispatcher  *events.Dispatcher
+	credentials *storage.Credentials // nil for local storage
+	logger      *slog.Logger
+}
+
+// Reload re-reads the config file, users file and chat notifications file.
+// Everything is loaded and validated before anything is applied, so a
+// failed reload leaves the running configuration untouched.
+func (r *configReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := config.ReadConfigFile(r.viper); err != nil {
+		return err
+	}
+	cfg, err := config.LoadWithViper(r.viper)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	sinks, err := loadSinks(cfg, r.logger)
+	if err != nil {
+		return err
+	}
+	if r.basicAuth != nil {
+		if err := r.basicAuth.Reload(cfg.Auth.UsersFile); err != nil {
+			return err
+		}
+	}
+
+	r.logLevel.Set(server.ParseLogLevel(cfg.Logging.Level))
+	r.server.ApplyConfig(cfg)
+	r.dispatcher.SetSinks(sinks...)
+	if r.credentials != nil {
+		r.credentials.SetTokens(cfg.Storage.Token, cfg.Storage.SecondaryToken)
+	}
+
+	if ignored := restartRequired(r.startup, cfg); len(ignored) > 0 {
+		r.logger.Warn("Configuration changes require a restart and were not applied",
+			"settings", ignored)
+	}
+
+	r.logger.Info("Configuration reloaded",
+		"log_level", cfg.Logging.Level,
+		"rate_limit", cfg.Server.RateLimit,
+		"rate_limit_burst", cfg.Server.RateLimitBurst,
+		"anonymous_rate_limit", cfg.Server.AnonymousRateLimit,
+		"anonymous_rate_limit_burst", cfg.Server.AnonymousRateLimitBurst,
+		"trusted_proxies", cfg.Server.TrustedProxies,
+		"max_pending_writes", cfg.Server.MaxPendingWrites,
+		"capture_failed_requests", cfg.Server.CaptureFailedRequests,
+		"cors_origins", cfg.Server.CORSOrigins,
+		"vanity_hosts", cfg.Server.VanityHosts,
+		"notification_sinks", len(sinks),
+		"storage_secondary_token_set", cfg.Storage.SecondaryToken != "")
+	return nil
+}
+
+// restartRequired lists the settings that changed but cannot be reloaded
+func restartRequired(old, cfg *config.Config) []string {
+	var changed []string
+	check := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, name)
+		}
+	}
+	check("server.port", old.Server.Port, cfg.Server.Port)
+	check("server.host", old.Server.Host, cfg.Server.Host)
+	check("server.listeners", old.Server.Listeners, cfg.Server.Listeners)
+	check("server.request_timeout", old.Server.RequestTimeout, cfg.Server.RequestTimeout)
+	check("server.drain_duration", old.Server.DrainDuration, cfg.Server.DrainDuration)
+	check("server.shutdown_timeout", old.Server.ShutdownTimeout, cfg.Server.ShutdownTimeout)
+	check("server.validate_requests", old.Server.ValidateRequests, cfg.Server.ValidateRequests)
+	check("server.base_path", old.Server.BasePath, cfg.Server.BasePath)
+	check("server.external_url", old.Server.ExternalURL, cfg.Server.ExternalURL)
+	check("server.anonymous_read", old.Server.AnonymousRead, cfg.Server.AnonymousRead)
+	check("server.anonymous_access", old.Server.AnonymousAccess, cfg.Server.AnonymousAccess)
+	// Storage tokens are reloaded
+	oldStorage, newStorage := old.Storage, cfg.Storage
+	oldStorage.Token, oldStorage.SecondaryToken = "", ""
+	newStorage.Token, newStorage.SecondaryToken = "", ""
+	check("storage", oldStorage, newStorage)
+	check("auth.type", old.Auth.Type, cfg.Auth.Type)
+	check("logging.format", old.Logging.Format, cfg.Logging.Format)
+	check("cache", old.Cache, cfg.Cache)
+	check("signing", old.Signing, cfg.Signing)
+	check("encryption", old.Encryption, cfg.Encryption)
+	check("scheduler", old.Scheduler, cfg.Scheduler)
+	check("policy", old.Policy, cfg.Policy)
+	check("clients", old.Clients, cfg.Clients)
+	check("crypto", old.Crypto, cfg.Crypto)
+	check("tls", old.TLS, cfg.TLS)
+	return changed
+}
@@ -1,28 +1,56 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/acme/autocert"
 
+	"github.com/criteo/command-launcher-registry/docs"
 	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
+	"github.com/criteo/command-launcher-registry/internal/certs"
 	"github.com/criteo/command-launcher-registry/internal/config"
+	"github.com/criteo/command-launcher-registry/internal/events"
+	"github.com/criteo/command-launcher-registry/internal/fips"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/scheduler"
+	"github.com/criteo/command-launcher-registry/internal/secrets"
 	"github.com/criteo/command-launcher-registry/internal/server"
 	"github.com/criteo/command-launcher-registry/internal/server/handlers"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/signing"
 	"github.com/criteo/command-launcher-registry/internal/storage"
+	"github.com/criteo/command-launcher-registry/internal/verify"
 )
 
-// Exit codes
+// Exit codes, one per class of startup failure, so orchestrators and
+// operators can tell them apart without reading the logs
 const (
-	ExitCodeOK                   = 0
-	ExitCodeInvalidConfig        = 1
-	ExitCodeStorageInitFailed    = 2
-	ExitCodeServerStartupFailed  = 3
+	ExitCodeOK                  = 0
+	ExitCodeInvalidConfig       = 1 // Configuration, flags or files it references
+	ExitCodeStorageInitFailed   = 2 // Storage backend (and its cache) could not be loaded
+	ExitCodeServerStartupFailed = 3 // Server failed after binding, or its setup failed
+	ExitCodeAuthInitFailed      = 4 // Authenticator could not be initialized (users file)
+	ExitCodePortBindFailed      = 5 // Listen address could not be bound
 )
 
+// exitReasons names the exit codes in the final log line
+var exitReasons = map[int]string{
+	ExitCodeOK:                  "ok",
+	ExitCodeInvalidConfig:       "invalid_config",
+	ExitCodeStorageInitFailed:   "storage_init_failed",
+	ExitCodeServerStartupFailed: "server_startup_failed",
+	ExitCodeAuthInitFailed:      "auth_init_failed",
+	ExitCodePortBindFailed:      "port_bind_failed",
+}
+
 var v *viper.Viper
 
 // ServerCmd represents the server command
@@ -39,38 +67,80 @@ func init() {
 	// CLI flags - these take precedence over environment variables
 	ServerCmd.Flags().String("storage-uri", "", "Storage URI (e.g., file://./data/registry.json)")
 	ServerCmd.Flags().String("storage-token", "", "Storage authentication token (passed to storage backend)")
+	ServerCmd.Flags().String("storage-secondary-token", "", "Storage token used while the backend rejects --storage-token, for rotation")
+	ServerCmd.Flags().String("storage-codec", "", "Storage data format (json|cbor)")
+	ServerCmd.Flags().Bool("storage-pretty-json", false, "Write indented JSON to file:// storage (compact by default)")
+	ServerCmd.Flags().String("storage-compression", "", "Compress S3/GCS/OCI storage data (none|gzip|zstd)")
+	ServerCmd.Flags().Bool("fail-fast-on-storage-degraded", true, "Exit when the S3/GCS/OCI backend is unavailable at boot; when false, serve storage.cache_file read-only")
+	ServerCmd.Flags().Duration("backup-interval", 0, "Snapshot storage data this often, next to file/S3 storage or to backup.uri (0 disables)")
 	ServerCmd.Flags().Int("port", 0, "Server port")
 	ServerCmd.Flags().String("host", "", "Bind address")
+	ServerCmd.Flags().StringSlice("listen", nil, "Listen addresses as address=profile (all|public|internal), replacing --host and --port; repeatable")
 	ServerCmd.Flags().String("log-level", "", "Log level (debug|info|warn|error)")
 	ServerCmd.Flags().String("log-format", "", "Log format (json|text)")
 	ServerCmd.Flags().String("auth-type", "", "Authentication type (none|basic)")
+	ServerCmd.Flags().String("config", "", "YAML config file, re-read on SIGHUP")
 
 	// Bind CLI flags to viper
 	v.BindPFlag("storage.uri", ServerCmd.Flags().Lookup("storage-uri"))
 	v.BindPFlag("storage.token", ServerCmd.Flags().Lookup("storage-token"))
+	v.BindPFlag("storage.secondary_token", ServerCmd.Flags().Lookup("storage-secondary-token"))
+	v.BindPFlag("storage.codec", ServerCmd.Flags().Lookup("storage-codec"))
+	v.BindPFlag("storage.pretty_json", ServerCmd.Flags().Lookup("storage-pretty-json"))
+	v.BindPFlag("storage.compression", ServerCmd.Flags().Lookup("storage-compression"))
+	v.BindPFlag("storage.fail_fast_on_degraded", ServerCmd.Flags().Lookup("fail-fast-on-storage-degraded"))
+	v.BindPFlag("backup.interval", ServerCmd.Flags().Lookup("backup-interval"))
 	v.BindPFlag("server.port", ServerCmd.Flags().Lookup("port"))
 	v.BindPFlag("server.host", ServerCmd.Flags().Lookup("host"))
+	v.BindPFlag("server.listeners", ServerCmd.Flags().Lookup("listen"))
 	v.BindPFlag("logging.level", ServerCmd.Flags().Lookup("log-level"))
 	v.BindPFlag("logging.format", ServerCmd.Flags().Lookup("log-format"))
 	v.BindPFlag("auth.type", ServerCmd.Flags().Lookup("auth-type"))
+	v.BindPFlag("config_file", ServerCmd.Flags().Lookup("config"))
 }
 
 func runServer(cmd *cobra.Command, args []string) error {
-	// Load configuration (CLI flags > env vars > defaults)
+	return serve(v, serveOptions{})
+}
+
+// serveOptions are the features added by the standalone command
+type serveOptions struct {
+	ui   bool // Serve the web UI and the API description
+	demo bool // Seed an empty store with example data
+}
+
+// serve runs the server with the configuration read through v
+func serve(v *viper.Viper, opts serveOptions) error {
+	// Load configuration (CLI flags > env vars > config file > defaults)
+	if err := config.ReadConfigFile(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(nil, ExitCodeInvalidConfig)
+	}
 	cfg, err := config.LoadWithViper(v)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
-		os.Exit(ExitCodeInvalidConfig)
+		exit(nil, ExitCodeInvalidConfig)
 	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: invalid configuration: %v\n", err)
-		os.Exit(ExitCodeInvalidConfig)
+		exit(nil, ExitCodeInvalidConfig)
 	}
 
-	// Create logger
-	logger := server.NewLogger(cfg.Logging.Level, cfg.Logging.Format)
+	// Create logger (level can be changed by a config reload)
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(server.ParseLogLevel(cfg.Logging.Level))
+	logger := server.NewLeveledLogger(logLevel, cfg.Logging.Format)
+
+	// Restricted-crypto mode, before users files are read and TLS clients built
+	if cfg.Crypto.FIPS {
+		fips.Enable()
+	}
+	if fips.Enabled() {
+		logger.Info("FIPS mode enabled: only FIPS-approved algorithms are used",
+			"fips140_module", fips.Module())
+	}
 
 	// Log effective configuration at startup (with masked token)
 	logEffectiveConfig(cfg, logger)
@@ -81,49 +151,378 @@ func runServer(cmd *cobra.Command, args []string) error {
 		logger.Error("Failed to parse storage URI",
 			"error", err,
 			"storage_uri", cfg.Storage.URI)
-		os.Exit(ExitCodeInvalidConfig)
+		exit(logger, ExitCodeInvalidConfig)
 	}
 
-	// Initialize storage using factory
-	store, err := storage.NewStorage(storageURI, cfg.Storage.Token, logger)
+	// Storage encoding (the storage itself is loaded once the server listens)
+	codec, err := storage.ParseCodec(cfg.Storage.Codec)
 	if err != nil {
-		logger.Error("Failed to initialize storage",
-			"error", err,
-			"storage_uri", cfg.Storage.URI,
-			"scheme", storageURI.Scheme)
-		os.Exit(ExitCodeStorageInitFailed)
+		logger.Error("Invalid storage codec", "error", err)
+		exit(logger, ExitCodeInvalidConfig)
+	}
+	compression, err := storage.ParseCompression(cfg.Storage.Compression)
+	if err != nil {
+		logger.Error("Invalid storage compression", "error", err)
+		exit(logger, ExitCodeInvalidConfig)
+	}
+	var envelope *storage.Envelope
+	switch {
+	case cfg.Storage.EncryptionKey != "":
+		envelope, err = storage.NewEnvelopeFromBase64(cfg.Storage.EncryptionKey)
+	case cfg.Storage.EncryptionKeyFile != "":
+		envelope, err = storage.NewEnvelopeFromFile(cfg.Storage.EncryptionKeyFile)
+	}
+	if err != nil {
+		logger.Error("Invalid storage encryption key", "error", err)
+		exit(logger, ExitCodeInvalidConfig)
+	}
+	persistMode, err := storage.ParsePersistMode(cfg.Storage.PersistMode)
+	if err != nil {
+		logger.Error("Invalid storage persist mode", "error", err)
+		exit(logger, ExitCodeInvalidConfig)
+	}
+	gitAuthor, err := storage.ParseGitSignature(cfg.Storage.GitAuthor)
+	if err != nil {
+		logger.Error("Invalid storage Git author", "error", err)
+		exit(logger, ExitCodeInvalidConfig)
+	}
+	gitCommitter := gitAuthor
+	if cfg.Storage.GitCommitter != "" {
+		if gitCommitter, err = storage.ParseGitSignature(cfg.Storage.GitCommitter); err != nil {
+			logger.Error("Invalid storage Git committer", "error", err)
+			exit(logger, ExitCodeInvalidConfig)
+		}
+	}
+	packageNamePolicy, err := storage.ParsePackageNamePolicy(cfg.Policy.PackageNames)
+	if err != nil {
+		logger.Error("Invalid package name policy", "error", err)
+		exit(logger, ExitCodeInvalidConfig)
 	}
 
 	// Initialize authenticator
 	var authenticator auth.Authenticator
+	var basicAuth *auth.BasicAuth
+	var apiKeyAuth *auth.APIKeyAuth // API keys, read from storage once loaded
 	switch cfg.Auth.Type {
 	case "none":
 		authenticator = auth.NewNoAuth()
 		logger.Info("Authentication disabled (auth.type=none)")
 	case "basic":
-		authenticator, err = auth.NewBasicAuth(cfg.Auth.UsersFile, logger)
+		basicAuth, err = auth.NewBasicAuth(cfg.Auth.UsersFile, logger)
 		if err != nil {
 			logger.Error("Failed to initialize basic auth",
 				"error", err,
 				"users_file", cfg.Auth.UsersFile)
-			os.Exit(ExitCodeStorageInitFailed)
+			exit(logger, ExitCodeAuthInitFailed)
 		}
+		apiKeyAuth = auth.NewAPIKeyAuth(basicAuth, logger)
+		authenticator = apiKeyAuth
 	default:
 		logger.Error("Unsupported auth type", "auth_type", cfg.Auth.Type)
-		os.Exit(ExitCodeInvalidConfig)
+		exit(logger, ExitCodeInvalidConfig)
+	}
+
+	// Load index signing keys (signing disabled when none configured)
+	signingKeys, err := signing.LoadKeySet(cfg.Signing.KeyFiles)
+	if err != nil {
+		logger.Error("Failed to load index signing keys",
+			"error", err,
+			"key_files", cfg.Signing.KeyFiles)
+		exit(logger, ExitCodeInvalidConfig)
+	}
+	if signingKeys.Enabled() {
+		logger.Info("Index signing enabled",
+			"signing_kid", signingKeys.SigningKeyID(),
+			"published_keys", len(signingKeys.JWKS().Keys))
+	}
+
+	// Encryption key for sensitive custom values at rest (writes needing it fail without a key)
+	var valueCipher *secrets.Cipher
+	if cfg.Encryption.Key != "" {
+		valueCipher, err = secrets.NewCipherFromBase64(cfg.Encryption.Key)
+		if err != nil {
+			logger.Error("Invalid encryption key", "error", err)
+			exit(logger, ExitCodeInvalidConfig)
+		}
+	}
+
+	// Notification sinks. The dispatcher is always installed so a reload can
+	// enable notifications later.
+	sinks, err := loadSinks(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to load notification sinks",
+			"error", err,
+			"chat_file", cfg.Notify.ChatFile)
+		exit(logger, ExitCodeInvalidConfig)
 	}
+	dispatcher := events.NewDispatcher(logger, sinks...)
 
-	// Create server
-	srv := server.NewServer(cfg, logger, store, authenticator)
+	// Create server and listen while storage loads, so probes see 503 on
+	// /readyz instead of a refused connection
+	srv := server.NewServer(cfg, logger, authenticator)
+	if cfg.Server.ValidateRequests {
+		validator, err := middleware.NewRequestValidator(docs.OpenAPI, logger)
+		if err != nil {
+			logger.Error("Failed to load OpenAPI spec for request validation", "error", err)
+			exit(logger, ExitCodeServerStartupFailed)
+		}
+		srv.SetRequestValidator(validator)
+	}
+
+	// HTTPS: certificate files, or ACME certificates kept in storage
+	var certCache *certs.Cache
+	if cfg.TLS.Enabled() {
+		var manager *autocert.Manager
+		if len(cfg.TLS.ACME.Domains) > 0 {
+			certCache = certs.NewCache(valueCipher)
+			manager = certs.NewManager(cfg.TLS.ACME, certCache)
+			logger.Info("ACME certificate management enabled",
+				"domains", cfg.TLS.ACME.Domains,
+				"directory_url", cfg.TLS.ACME.DirectoryURL)
+		}
+		tlsConfig, err := certs.ServerConfig(cfg.TLS, manager)
+		if err != nil {
+			logger.Error("Failed to load TLS certificate",
+				"error", err,
+				"cert_file", cfg.TLS.CertFile,
+				"key_file", cfg.TLS.KeyFile)
+			exit(logger, ExitCodeInvalidConfig)
+		}
+		srv.SetTLSConfig(tlsConfig)
+
+		if manager != nil && cfg.TLS.ACME.HTTPPort > 0 {
+			challenges, err := certs.ServeHTTPChallenges(fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.TLS.ACME.HTTPPort), manager, logger)
+			if err != nil {
+				logger.Error("Failed to bind ACME challenge address",
+					"error", err,
+					"host", cfg.Server.Host,
+					"port", cfg.TLS.ACME.HTTPPort)
+				exit(logger, ExitCodePortBindFailed)
+			}
+			srv.OnShutdown(func() { challenges.Close() })
+		}
+	}
+
+	if err := srv.Listen(); err != nil {
+		logger.Error("Failed to bind server address",
+			"error", err,
+			"listeners", cfg.Server.ListenAddresses())
+		exit(logger, ExitCodePortBindFailed)
+	}
+
+	storageOpts := storage.Options{
+		Codec:         codec,
+		PrettyJSON:    cfg.Storage.PrettyJSON,
+		Compression:   compression,
+		Envelope:      envelope,
+		LoadTimeout:   cfg.Storage.LoadTimeout,
+		ReadTimeout:   cfg.Storage.ReadTimeout,
+		WriteTimeout:  cfg.Storage.WriteTimeout,
+		CacheFile:     cfg.Storage.CacheFile,
+		PersistMode:   persistMode,
+		FlushInterval: cfg.Storage.FlushInterval,
+		OCIRevisions:  cfg.Storage.OCIRevisions,
+		GitAuthor:     gitAuthor,
+		GitCommitter:  gitCommitter,
+		Retry: storage.RetryPolicy{
+			MaxAttempts: cfg.Storage.RetryMaxAttempts,
+			MinBackoff:  cfg.Storage.RetryMinBackoff,
+			MaxBackoff:  cfg.Storage.RetryMaxBackoff,
+			Budget:      cfg.Storage.RetryBudget,
+			Jitter:      cfg.Storage.RetryJitter,
+		},
+	}
+	// S3, GCS, OCI, DynamoDB and WebDAV tokens, rotated on reload and re-authentication
+	var credentials *storage.Credentials
+	if storageURI.IsS3Scheme() || storageURI.IsGCSScheme() || storageURI.IsOCIScheme() || storageURI.IsDynamoDBScheme() || storageURI.IsWebDAVScheme() {
+		credentials = storage.NewCredentials(cfg.Storage.Token, cfg.Storage.SecondaryToken, logger)
+		storageOpts.Credentials = credentials
+	}
+	store, err := openStorage(storageURI, cfg.Storage.Token, storageOpts, cfg.Storage.LoadTimeout, logger)
+	if err != nil {
+		logger.Error("Failed to initialize storage",
+			"error", err,
+			"category", storage.ErrorCategory(err),
+			"storage_uri", cfg.Storage.URI,
+			"scheme", storageURI.Scheme,
+			"load_timeout", cfg.Storage.LoadTimeout.String())
+		if !canServeCache(cfg, storageURI, err) {
+			exit(logger, ExitCodeStorageInitFailed)
+		}
+
+		// Degraded mode: serve the last copy of the data read-only
+		store, err = storage.OpenCache(cfg.Storage.CacheFile, storageOpts, logger)
+		if err != nil {
+			logger.Error("Failed to load storage cache file",
+				"error", err,
+				"cache_file", cfg.Storage.CacheFile)
+			exit(logger, ExitCodeStorageInitFailed)
+		}
+		srv.SetDegraded(true)
+	}
+
+	// Copy every write to the mirror backend, not while serving the cache
+	backend := store
+	var mirror *storage.MirrorStore
+	if cfg.Storage.MirrorURI != "" && !srv.Degraded() {
+		mirror, err = openMirror(cfg, store, storageOpts, logger)
+		if err != nil {
+			logger.Error("Failed to initialize storage mirror",
+				"error", err,
+				"category", storage.ErrorCategory(err),
+				"mirror_uri", cfg.Storage.MirrorURI)
+			exit(logger, ExitCodeStorageInitFailed)
+		}
+		store = mirror
+	}
+
+	// Reload data changed out-of-band, e.g. edited by hand or by another
+	// instance without a change feed
+	var watcher *storage.Watcher
+	if cfg.Storage.Watch && !srv.Degraded() {
+		if watched, ok := backend.(storage.Watched); ok {
+			watcher = storage.NewWatcher(watched, cfg.Storage.WatchInterval, logger)
+			srv.OnShutdown(func() { watcher.Close() })
+			logger.Info("Watching storage for out-of-band changes",
+				"interval", cfg.Storage.WatchInterval.String())
+		} else {
+			logger.Warn("Storage backend cannot be watched for out-of-band changes",
+				"storage_uri", cfg.Storage.URI)
+		}
+	}
+
+	// Change fan-out between instances sharing the backend, and the shared
+	// cache below encryption so sensitive values stay encrypted in Redis
+	var feed *storage.ChangeFeed
+	if cfg.Redis.URL != "" {
+		redisClient, err := storage.NewRedisClient(cfg.Redis.URL)
+		if err != nil {
+			logger.Error("Failed to connect to Redis", "error", err)
+			exit(logger, ExitCodeStorageInitFailed)
+		}
+		feed, err = storage.NewChangeFeed(redisClient, cfg.Redis.KeyPrefix, store, logger)
+		if err != nil {
+			logger.Error("Failed to subscribe to storage changes", "error", err)
+			exit(logger, ExitCodeStorageInitFailed)
+		}
+		srv.OnShutdown(func() {
+			feed.Close()
+			redisClient.Close()
+		})
+		if backend, ok := backend.(storage.Refresher); ok {
+			feed.Follow(backend)
+		}
+		if cfg.Redis.CacheTTL > 0 {
+			store = storage.NewRedisCache(store, redisClient, feed, cfg.Redis.KeyPrefix, cfg.Redis.CacheTTL, logger)
+		}
+		store = storage.NewFeedStore(store, feed, logger)
+		logger.Info("Redis change feed enabled",
+			"key_prefix", cfg.Redis.KeyPrefix,
+			"generation", feed.Generation(),
+			"cache_ttl", cfg.Redis.CacheTTL.String())
+	}
+
+	// Encrypt sensitive custom values, publish change events and apply the
+	// package name policy
+	store = storage.NewEncryptedStore(store, valueCipher, logger)
+	eventStore := events.NewStore(store, dispatcher, cfg.Server.ExternalURL)
+	store = eventStore
+	store = storage.NewPackageNameStore(store, packageNamePolicy, logger)
+	srv.SetStore(store)
+	if apiKeyAuth != nil {
+		apiKeyAuth.SetLookup(store.GetAPIKey)
+	}
+	if certCache != nil {
+		certCache.SetStore(store)
+	}
+	if opts.demo {
+		seeded, err := storage.SeedDemo(context.Background(), store)
+		if err != nil {
+			logger.Error("Failed to seed demo data", "error", err)
+			exit(logger, ExitCodeStorageInitFailed)
+		}
+		if seeded {
+			logger.Info("Demo data created", "registry", storage.DemoRegistry)
+		}
+	}
 
 	// Create all handlers
-	indexHandler := handlers.NewIndexHandler(store, logger)
+	indexHandler := handlers.NewIndexHandler(store, signingKeys, logger)
 	registryHandler := handlers.NewRegistryHandler(store, logger)
+	registryHandler.SetIndexMaxAge(cfg.Cache.IndexMaxAge)
+	installHandler := handlers.NewInstallHandler(store, cfg.Server.AnonymousRead, logger)
 	packageHandler := handlers.NewPackageHandler(store, logger)
 	versionHandler := handlers.NewVersionHandler(store, logger)
 	healthHandler := handlers.NewHealthHandler(store, logger)
+	if writeBehind, ok := backend.(storage.WriteBehind); ok {
+		healthHandler.SetWriteBehind(writeBehind)
+	}
+	if versioned, ok := backend.(storage.Versioned); ok {
+		healthHandler.SetVersioned(versioned)
+	}
+	if !srv.Degraded() {
+		pinger, _ := backend.(storage.Pinger)
+		persistence, _ := backend.(storage.PersistHealth)
+		healthHandler.SetBackend(storageURI.Scheme, pinger, persistence)
+	}
 	metricsHandler := handlers.NewMetricsHandler(logger)
+	metricsHandler.SetRouteMetrics(srv.RouteMetrics())
+	metricsHandler.SetStorage(store, srv.Degraded)
+	metricsHandler.SetMirror(mirror)
+	metricsHandler.SetWatcher(watcher)
+	metricsHandler.SetRateLimiter(srv.RateLimiter())
+	metricsHandler.SetWriteQueue(srv.WriteQueue())
+	metricsHandler.SetClientCounter(srv.ClientCounter())
+	metricsHandler.SetUserCounter(srv.UserCounter())
 	whoamiHandler := handlers.NewWhoamiHandler(authenticator, logger)
+	infoHandler := handlers.NewInfoHandler(models.ServerInfo{
+		Version:                  buildinfo.Version,
+		MinClientVersion:         cfg.Clients.MinVersion,
+		RecommendedClientVersion: cfg.Clients.RecommendedVersion,
+		AnonymousRead:            cfg.Server.AnonymousRead,
+		AnonymousAccess:          cfg.Server.AnonymousAccess,
+		FIPSMode:                 fips.Enabled(),
+		FIPS140Module:            fips.Module(),
+	}, logger)
+	signingHandler := handlers.NewSigningHandler(signingKeys, logger)
+	syncHandler := handlers.NewSyncHandler(store, logger)
+	meHandler := handlers.NewMeHandler(store, logger)
+	conflictHandler := handlers.NewConflictHandler(store, packageNamePolicy, logger)
+	graphQLHandler := handlers.NewGraphQLHandler(store, logger)
+	var linkChecker *verify.LinkChecker
+	if cfg.Scheduler.LinkCheckInterval > 0 {
+		linkChecker = verify.NewLinkChecker(store, cfg.Scheduler.LinkYankAfter, logger)
+	}
+	brokenLinksHandler := handlers.NewBrokenLinksHandler(store, linkChecker, logger)
+	schemaHandler := handlers.NewSchemaHandler(logger)
+	apiKeyHandler := handlers.NewAPIKeyHandler(store, logger)
+
+	// Reload configuration in place on SIGHUP or POST /api/v1/admin/reload
+	reloader := &configReloader{
+		viper:       v,
+		startup:     cfg,
+		logLevel:    logLevel,
+		server:      srv,
+		basicAuth:   basicAuth,
+		dispatcher:  dispatcher,
+		credentials: credentials,
+		logger:      logger,
+	}
+	srv.OnReload(reloader.Reload)
+	adminHandler := handlers.NewAdminHandler(store, reloader.Reload, logger)
+	adminHandler.SetCredentials(credentials)
+	adminHandler.SetRequestCapture(srv.RequestCapture())
+
+	// Web UI, standalone mode only
+	var uiHandler, openAPIHandler http.HandlerFunc
+	if opts.ui {
+		ui, err := handlers.NewUIHandler(docs.OpenAPI, logger)
+		if err != nil {
+			logger.Error("Failed to set up the web UI", "error", err)
+			exit(logger, ExitCodeServerStartupFailed)
+		}
+		uiHandler, openAPIHandler = ui.GetUI, ui.GetOpenAPI
+	}
 
 	// Set all handlers
 	srv.SetHandlers(server.HandlerSet{
@@ -132,34 +531,345 @@ func runServer(cmd *cobra.Command, args []string) error {
 		Health:         healthHandler.GetHealth,
 		Metrics:        metricsHandler.GetMetrics,
 		Whoami:         whoamiHandler.GetWhoami,
+		ServerInfo:     infoHandler.GetServerInfo,
 		ListRegistries: registryHandler.ListRegistries,
 		CreateRegistry: registryHandler.CreateRegistry,
 		GetRegistry:    registryHandler.GetRegistry,
 		UpdateRegistry: registryHandler.UpdateRegistry,
+		PatchRegistry:  registryHandler.PatchRegistry,
 		DeleteRegistry: registryHandler.DeleteRegistry,
+		CloneRegistry:  registryHandler.CloneRegistry,
 		ListPackages:   packageHandler.ListPackages,
 		CreatePackage:  packageHandler.CreatePackage,
 		GetPackage:     packageHandler.GetPackage,
 		UpdatePackage:  packageHandler.UpdatePackage,
+		PatchPackage:   packageHandler.PatchPackage,
 		DeletePackage:  packageHandler.DeletePackage,
 		ListVersions:   versionHandler.ListVersions,
 		CreateVersion:  versionHandler.CreateVersion,
 		GetVersion:     versionHandler.GetVersion,
 		DeleteVersion:  versionHandler.DeleteVersion,
+		CancelVersion:  versionHandler.CancelVersion,
+
+		BatchUpdatePackages: packageHandler.BatchUpdatePackages,
+		PackageHistory:      packageHandler.GetPackageHistory,
+		RegistryDashboard:   registryHandler.GetRegistryDashboard,
+		LockRegistry:        registryHandler.LockRegistry,
+		ListAPIKeys:         apiKeyHandler.ListAPIKeys,
+		CreateAPIKey:        apiKeyHandler.CreateAPIKey,
+		DeleteAPIKey:        apiKeyHandler.DeleteAPIKey,
+		BrokenLinks:         brokenLinksHandler.GetBrokenLinks,
+		InstallShell:        installHandler.GetShellScript,
+		InstallPowerShell:   installHandler.GetPowerShellScript,
+		CompareVersions:     versionHandler.CompareVersions,
+		JWKS:                signingHandler.GetJWKS,
+		ListSchemas:         schemaHandler.ListSchemas,
+		GetSchema:           schemaHandler.GetSchema,
+		Sync:                syncHandler.GetSync,
+		MyPackages:          meHandler.ListMyPackages,
+		PackageConflicts:    conflictHandler.ListPackageConflicts,
+		GraphQL:             graphQLHandler.ServeGraphQL,
+		AdminReload:         adminHandler.Reload,
+		AdminRestore:        adminHandler.Restore,
+		AdminVersions:       adminHandler.GetStorageVersions,
+		AdminRollback:       adminHandler.Rollback,
+		AdminQuarantine:     adminHandler.GetQuarantine,
+		AdminVerification:   adminHandler.GetVerification,
+		AdminCredentials:    adminHandler.GetStorageCredentials,
+		AdminReauthenticate: adminHandler.Reauthenticate,
+		AdminCaptured:       adminHandler.GetCapturedRequests,
+		AdminClearCaptured:  adminHandler.ClearCapturedRequests,
+		UI:                  uiHandler,
+		OpenAPI:             openAPIHandler,
+	})
+
+	// Start background jobs; they stop before storage is closed on shutdown
+	sched := scheduler.New(logger)
+	sched.Every("publish-scheduled-versions", cfg.Scheduler.PublishInterval, func(ctx context.Context) error {
+		released, err := storage.ReleaseDueVersions(ctx, store, time.Now())
+		if released > 0 {
+			logger.Info("Released scheduled versions", "count", released)
+		}
+		return err
 	})
+	if cfg.Scheduler.VerifyInterval > 0 {
+		verifier := verify.New(store, cfg.Scheduler.VerifySample, eventStore, logger)
+		adminHandler.SetVerifier(verifier)
+		sched.Every("verify-archives", cfg.Scheduler.VerifyInterval, verifier.Run)
+	}
+	if linkChecker != nil {
+		sched.Every("check-links", cfg.Scheduler.LinkCheckInterval, linkChecker.Run)
+	}
+	// Restores and rollbacks bypass the store wrappers: the mirror and the
+	// other instances are told here
+	replaced := func(ctx context.Context, generation uint64) {
+		if mirror != nil {
+			// A failed copy is logged, and caught up by the next write
+			mirror.Sync(ctx)
+		}
+		if feed != nil {
+			if err := feed.Publish(context.WithoutCancel(ctx), generation); err != nil {
+				logger.Warn("Failed to publish change", "generation", generation, "error", err)
+			}
+		}
+	}
+	// Out-of-band changes reach them the same way
+	if watcher != nil {
+		watcher.OnReload(replaced)
+	}
+	if versioned, ok := backend.(storage.Versioned); ok && !srv.Degraded() {
+		adminHandler.SetVersions(versioned, func(ctx context.Context, version string) error {
+			generation, err := versioned.Rollback(ctx, version)
+			if err != nil {
+				return err
+			}
+			eventStore.NotifyRolledBack(ctx, version)
+			replaced(ctx, generation)
+			return nil
+		})
+	}
+	if cfg.Backup.Interval > 0 && !srv.Degraded() {
+		backups, err := openBackups(cfg, backend, storageOpts, logger)
+		if err != nil {
+			logger.Error("Failed to initialize storage backups", "error", err)
+			exit(logger, ExitCodeStorageInitFailed)
+		}
+		metricsHandler.SetBackups(backups)
+		sched.Every("backup-storage", cfg.Backup.Interval, backups.Run)
+
+		if replacer, ok := backend.(storage.Replacer); ok {
+			adminHandler.SetRestore(func(ctx context.Context, backup string) error {
+				generation, err := backups.Restore(ctx, backup, replacer)
+				if err != nil {
+					return err
+				}
+				eventStore.NotifyRestored(ctx, backup)
+				replaced(ctx, generation)
+				return nil
+			})
+		}
+	}
+	sched.Start()
+	srv.OnShutdown(sched.Stop)
 
 	// Start server
-	logger.Info("Server ready to accept connections",
-		"address", fmt.Sprintf("http://%s:%d", cfg.Server.Host, cfg.Server.Port))
+	scheme := "http"
+	if cfg.TLS.Enabled() {
+		scheme = "https"
+	}
+	for _, listener := range cfg.Server.ListenAddresses() {
+		logger.Info("Server ready to accept connections",
+			"address", fmt.Sprintf("%s://%s%s", scheme, listener.Address, cfg.Server.BasePath),
+			"profile", listener.Profile)
+	}
 
 	if err := srv.Start(); err != nil {
 		logger.Error("Server stopped with error", "error", err)
-		os.Exit(ExitCodeServerStartupFailed)
+		exit(logger, ExitCodeServerStartupFailed)
 	}
 
+	logExit(logger, ExitCodeOK)
 	return nil
 }
 
+// canServeCache reports whether a storage initialization error is the
+// backend being unavailable and the configuration allows serving the cache
+// file read-only instead of exiting. Data that does not decode is never
+// worked around: the cache would hide it.
+func canServeCache(cfg *config.Config, uri *storage.StorageURI, err error) bool {
+	if cfg.Storage.FailFastOnDegraded || cfg.Storage.CacheFile == "" || uri.IsLocal() {
+		return false
+	}
+	if storage.ErrorCategory(err) == storage.CategoryData {
+		return false
+	}
+	if _, statErr := os.Stat(cfg.Storage.CacheFile); statErr != nil {
+		return false
+	}
+	return true
+}
+
+// exit logs the final line of the process and exits with code. Errors found
+// before the logger is created pass a nil logger; the line then goes to
+// stderr as JSON.
+func exit(logger *slog.Logger, code int) {
+	if logger == nil {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+	logExit(logger, code)
+	os.Exit(code)
+}
+
+// logExit logs the final line of the process, with its exit code
+func logExit(logger *slog.Logger, code int) {
+	level := slog.LevelError
+	if code == ExitCodeOK {
+		level = slog.LevelInfo
+	}
+	logger.Log(context.Background(), level, "Server exiting",
+		"exit_code", code,
+		"exit_reason", exitReasons[code])
+}
+
+// openStorage loads the storage backend, giving up after timeout (0 waits
+// indefinitely). On timeout the load keeps running until the process exits.
+func openStorage(uri *storage.StorageURI, token string, opts storage.Options, timeout time.Duration, logger *slog.Logger) (storage.Store, error) {
+	type result struct {
+		store storage.Store
+		err   error
+	}
+	start := time.Now()
+	logger.Info("Loading storage data",
+		"scheme", uri.Scheme,
+		"load_timeout", timeout.String())
+
+	done := make(chan result, 1)
+	go func() {
+		store, err := storage.NewStorage(uri, token, opts, logger)
+		done <- result{store, err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, res.err
+		}
+		logger.Info("Storage data loaded",
+			"scheme", uri.Scheme,
+			"duration_ms", time.Since(start).Milliseconds())
+		return res.store, nil
+	case <-expired:
+		return nil, fmt.Errorf("%w after %s", storage.ErrLoadTimeout, timeout)
+	}
+}
+
+// openBackups snapshots the data of backend to backup.uri, or next to the
+// storage.uri file or object
+func openBackups(cfg *config.Config, backend storage.Store, opts storage.Options, logger *slog.Logger) (*storage.Backups, error) {
+	source, ok := backend.(storage.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("storage scheme %s cannot be backed up", cfg.Storage.URI)
+	}
+	rawURI, token := cfg.Backup.URI, cfg.Backup.Token
+	if rawURI == "" {
+		rawURI = cfg.Storage.URI
+	}
+	if token == "" {
+		token = cfg.Storage.Token
+	}
+	uri, err := storage.ParseStorageURI(rawURI)
+	if err != nil {
+		return nil, err
+	}
+
+	// Backups authenticate with their own token, like a mirror
+	opts.Credentials = nil
+	policy := storage.BackupPolicy{Keep: cfg.Backup.Keep, MaxAge: cfg.Backup.MaxAge}
+	return storage.NewBackups(source, uri, token, opts, policy, logger.With("storage_role", "backup"))
+}
+
+// openMirror loads the storage.mirror_uri backend and wraps store to copy
+// its data there after each write. A mirror that differs from store, e.g.
+// because it missed writes while unavailable, is rewritten first; failing
+// that is logged and retried in the background.
+func openMirror(cfg *config.Config, store storage.Store, opts storage.Options, logger *slog.Logger) (*storage.MirrorStore, error) {
+	uri, err := storage.ParseStorageURI(cfg.Storage.MirrorURI)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := store.(storage.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("storage scheme %s cannot be mirrored", cfg.Storage.URI)
+	}
+
+	// The mirror authenticates with its own token, and is never served
+	// from a cache
+	opts.Credentials = nil
+	opts.CacheFile = ""
+	backend, err := openStorage(uri, cfg.Storage.MirrorToken, opts, cfg.Storage.LoadTimeout, logger.With("storage_role", "mirror"))
+	if err != nil {
+		return nil, err
+	}
+	replacer, ok := backend.(storage.Replacer)
+	if !ok {
+		backend.Close()
+		return nil, fmt.Errorf("storage scheme %s cannot serve as a mirror", uri.Scheme)
+	}
+
+	mirror := storage.NewMirrorStore(store, source, replacer, uri.String(), logger)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := mirror.Reconcile(ctx); err != nil {
+		logger.Warn("Failed to reconcile storage mirror, retrying in the background",
+			"mirror_uri", uri.String(),
+			"error", err)
+	}
+	return mirror, nil
+}
+
+// loadSinks builds the notification sinks enabled by the configuration
+func loadSinks(cfg *config.Config, logger *slog.Logger) ([]events.Sink, error) {
+	var sinks []events.Sink
+	if cfg.Notify.Email.SMTPHost != "" {
+		sinks = append(sinks, events.NewEmailSink(events.EmailOptions{
+			Host:     cfg.Notify.Email.SMTPHost,
+			Port:     cfg.Notify.Email.SMTPPort,
+			Username: cfg.Notify.Email.Username,
+			Password: cfg.Notify.Email.Password,
+			From:     cfg.Notify.Email.From,
+			Events:   cfg.Notify.Email.Events,
+		}, logger))
+		logger.Info("Email notifications enabled",
+			"smtp_host", cfg.Notify.Email.SMTPHost,
+			"smtp_port", cfg.Notify.Email.SMTPPort)
+	}
+	if cfg.Notify.ChatFile != "" {
+		chatSinks, err := loadChatSinks(cfg.Notify.ChatFile, logger)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, chatSinks...)
+	}
+	return sinks, nil
+}
+
+// loadChatSinks builds the Slack and Teams sinks configured in a chat notifications file
+func loadChatSinks(path string, logger *slog.Logger) ([]events.Sink, error) {
+	chatCfg, err := events.LoadChatConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinks []events.Sink
+	if len(chatCfg.Slack.Routes) > 0 {
+		slack, err := events.NewSlackSink(chatCfg.Slack, logger)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, slack)
+	}
+	if len(chatCfg.Teams.Routes) > 0 {
+		teams, err := events.NewTeamsSink(chatCfg.Teams, logger)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, teams)
+	}
+
+	logger.Info("Chat notifications enabled",
+		"chat_file", path,
+		"slack_routes", len(chatCfg.Slack.Routes),
+		"teams_routes", len(chatCfg.Teams.Routes))
+	return sinks, nil
+}
+
 // logEffectiveConfig logs the effective configuration at startup
 func logEffectiveConfig(cfg *config.Config, logger *slog.Logger) {
 	tokenDisplay := cfg.MaskToken()
@@ -171,11 +881,33 @@ func logEffectiveConfig(cfg *config.Config, logger *slog.Logger) {
 		"version", "1.0.0",
 		"storage_uri", cfg.Storage.URI,
 		"storage_token", tokenDisplay,
+		"storage_secondary_token_set", cfg.Storage.SecondaryToken != "",
+		"storage_codec", cfg.Storage.Codec,
+		"storage_pretty_json", cfg.Storage.PrettyJSON,
+		"storage_compression", cfg.Storage.Compression,
+		"storage_encryption", cfg.Storage.EncryptionKey != "" || cfg.Storage.EncryptionKeyFile != "",
+		"storage_load_timeout", cfg.Storage.LoadTimeout.String(),
+		"storage_read_timeout", cfg.Storage.ReadTimeout.String(),
+		"storage_write_timeout", cfg.Storage.WriteTimeout.String(),
+		"storage_cache_file", cfg.Storage.CacheFile,
+		"storage_fail_fast_on_degraded", cfg.Storage.FailFastOnDegraded,
+		"storage_persist_mode", cfg.Storage.PersistMode,
+		"storage_flush_interval", cfg.Storage.FlushInterval.String(),
+		"storage_mirror_uri", cfg.Storage.MirrorURI,
+		"storage_watch", cfg.Storage.Watch,
+		"backup_interval", cfg.Backup.Interval.String(),
+		"backup_uri", cfg.Backup.URI,
+		"tls_cert_file", cfg.TLS.CertFile,
+		"tls_acme_domains", cfg.TLS.ACME.Domains,
 		"port", cfg.Server.Port,
 		"host", cfg.Server.Host,
+		"listeners", cfg.Server.Listeners,
 		"log_level", cfg.Logging.Level,
 		"log_format", cfg.Logging.Format,
 		"auth_type", cfg.Auth.Type,
 		"auth_users_file", cfg.Auth.UsersFile,
+		"encryption_enabled", cfg.Encryption.Key != "",
+		"policy_package_names", cfg.Policy.PackageNames,
+		"redis_enabled", cfg.Redis.URL != "",
 	)
 }
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/criteo/command-launcher-registry/internal/config"
+)
+
+var standaloneViper *viper.Viper
+
+// StandaloneCmd represents the standalone command
+var StandaloneCmd = &cobra.Command{
+	Use:   "standalone",
+	Short: "Run a zero-configuration registry with the web UI",
+	Long: `Run the registry server with everything bundled in the binary: the web UI
+at /ui, the API description at /docs/openapi.json and file storage in the
+current directory. It listens on localhost only unless --host is given.
+
+All server configuration (flags, COLA_REGISTRY_* variables, --config) still
+applies; standalone only changes the defaults. With --demo, an empty store is
+seeded with an example registry to browse.`,
+	Example: `  cola-registry standalone
+  cola-registry standalone --demo
+  cola-registry standalone --host 0.0.0.0 --auth-type basic`,
+	RunE: runStandalone,
+}
+
+func init() {
+	standaloneViper = config.NewViper()
+	// Local defaults: private listener, readable logs
+	standaloneViper.SetDefault("server.host", "127.0.0.1")
+	standaloneViper.SetDefault("logging.format", "text")
+
+	StandaloneCmd.Flags().String("storage-uri", "", "Storage URI (default file://./data/registry.json)")
+	StandaloneCmd.Flags().Int("port", 0, "Server port (default 8080)")
+	StandaloneCmd.Flags().String("host", "", "Bind address (default 127.0.0.1)")
+	StandaloneCmd.Flags().String("log-level", "", "Log level (debug|info|warn|error)")
+	StandaloneCmd.Flags().String("auth-type", "", "Authentication type (none|basic)")
+	StandaloneCmd.Flags().String("config", "", "YAML config file, re-read on SIGHUP")
+	StandaloneCmd.Flags().Bool("demo", false, "Seed an empty store with an example registry")
+
+	standaloneViper.BindPFlag("storage.uri", StandaloneCmd.Flags().Lookup("storage-uri"))
+	standaloneViper.BindPFlag("server.port", StandaloneCmd.Flags().Lookup("port"))
+	standaloneViper.BindPFlag("server.host", StandaloneCmd.Flags().Lookup("host"))
+	standaloneViper.BindPFlag("logging.level", StandaloneCmd.Flags().Lookup("log-level"))
+	standaloneViper.BindPFlag("auth.type", StandaloneCmd.Flags().Lookup("auth-type"))
+	standaloneViper.BindPFlag("config_file", StandaloneCmd.Flags().Lookup("config"))
+}
+
+func runStandalone(cmd *cobra.Command, args []string) error {
+	demo, _ := cmd.Flags().GetBool("demo")
+	return serve(standaloneViper, serveOptions{ui: true, demo: demo})
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// StorageCmd represents the storage command
+var StorageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Storage utilities",
+	Long:  `Utilities for working with persisted registry data.`,
+}
+
+// ConvertStorageCmd represents the storage convert command
+var ConvertStorageCmd = &cobra.Command{
+	Use:   "convert <input> <output>",
+	Short: "Convert registry data between persistence formats",
+	Long: `Convert a persisted registry data file (a file:// storage file, or an S3
+object or OCI layer downloaded from the registry's storage) to another codec
+or compression. The input format is detected automatically.
+
+The server also reads any format regardless of its configuration and rewrites
+the data in the configured format on the next write; use this command to
+convert ahead of time or to inspect CBOR data as JSON.
+
+With --anonymize, the output can be shared in bug reports or with vendor
+support: emails become pseudonyms, sensitive custom values are masked, URLs
+lose their host and path, quarantined record data and TLS certificates are
+dropped. Anonymized data is for inspection, not for serving.
+
+Encrypted data (storage.encryption_key) is read with --encryption-key-file,
+and the output is encrypted with the same key unless --decrypt is given.`,
+	Example: `  cola-registry storage convert registry.json registry.cbor --codec cbor
+  cola-registry storage convert registry.cbor registry.json --codec json --pretty
+  cola-registry storage convert registry.json shareable.json --pretty --anonymize
+  cola-registry storage convert registry.json plain.json --encryption-key-file key.b64 --decrypt`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConvertStorage,
+}
+
+func init() {
+	ConvertStorageCmd.Flags().String("codec", "json", "Output codec (json|cbor)")
+	ConvertStorageCmd.Flags().Bool("pretty", false, "Indent JSON output")
+	ConvertStorageCmd.Flags().String("compression", "none", "Output compression (none|gzip|zstd)")
+	ConvertStorageCmd.Flags().Bool("anonymize", false, "Strip emails, sensitive custom values and internal URLs")
+	ConvertStorageCmd.Flags().String("encryption-key-file", "", "File holding the base64 storage encryption key")
+	ConvertStorageCmd.Flags().Bool("decrypt", false, "Write the output unencrypted")
+	StorageCmd.AddCommand(ConvertStorageCmd)
+}
+
+func runConvertStorage(cmd *cobra.Command, args []string) error {
+	codecName, _ := cmd.Flags().GetString("codec")
+	pretty, _ := cmd.Flags().GetBool("pretty")
+	compressionName, _ := cmd.Flags().GetString("compression")
+	anonymize, _ := cmd.Flags().GetBool("anonymize")
+	keyFile, _ := cmd.Flags().GetString("encryption-key-file")
+	decrypt, _ := cmd.Flags().GetBool("decrypt")
+
+	codec, err := storage.ParseCodec(codecName)
+	if err != nil {
+		return err
+	}
+	compression, err := storage.ParseCompression(compressionName)
+	if err != nil {
+		return err
+	}
+
+	var envelope *storage.Envelope
+	if keyFile != "" {
+		if envelope, err = storage.NewEnvelopeFromFile(keyFile); err != nil {
+			return err
+		}
+	}
+
+	input, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	data := input
+	if decrypt {
+		if data, err = envelope.Open(input); err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", args[0], err)
+		}
+		envelope = nil
+	}
+
+	convert := storage.Convert
+	if anonymize {
+		convert = storage.ConvertAnonymized
+	}
+	result, err := convert(data, storage.Options{
+		Codec:       codec,
+		PrettyJSON:  pretty,
+		Compression: compression,
+		Envelope:    envelope,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %w", args[0], err)
+	}
+
+	if err := os.WriteFile(args[1], result.Data, 0644); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Converted %d registries: %s (%s, %s, %d bytes) -> %s (%s, %s, %d bytes)\n",
+		result.RegistryCount,
+		args[0], result.SourceCodec, result.SourceCompression, len(input),
+		args[1], codec, compression, len(result.Data))
+	return nil
+}
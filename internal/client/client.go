@@ -8,8 +8,17 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
 )
 
+// ClientHeader identifies this client and its version to the server, which
+// records it in access logs and metrics
+const ClientHeader = "X-Cola-Client"
+
+// ClientName is the name this client reports to servers
+const ClientName = "cola-regctl"
+
 // Client wraps HTTP client for registry API calls
 type Client struct {
 	BaseURL    string
@@ -32,6 +41,12 @@ func NewClient(baseURL, token string, timeout time.Duration, verbose bool) *Clie
 
 // doRequest executes an HTTP request with authentication
 func (c *Client) doRequest(method, path string, body interface{}) (*http.Response, error) {
+	return c.doRequestWithHeader(method, path, body, nil)
+}
+
+// doRequestWithHeader executes an HTTP request with authentication and
+// extra headers
+func (c *Client) doRequestWithHeader(method, path string, body interface{}, header http.Header) (*http.Response, error) {
 	var reqBody io.Reader
 	if body != nil {
 		jsonData, err := json.Marshal(body)
@@ -52,6 +67,11 @@ func (c *Client) doRequest(method, path string, body interface{}) (*http.Respons
 		req.Header.Set("Content-Type", "application/json")
 	}
 	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", buildinfo.UserAgent(ClientName))
+	req.Header.Set(ClientHeader, ClientName+"/"+buildinfo.Version)
+	for key, values := range header {
+		req.Header[key] = values
+	}
 
 	// Add Basic Auth if token is provided
 	if c.Token != "" {
@@ -81,6 +101,12 @@ func (c *Client) Put(path string, body interface{}) (*http.Response, error) {
 	return c.doRequest("PUT", path, body)
 }
 
+// PutIfMatch executes a PUT request that only applies while the resource
+// still has the given ETag; the server answers 412 otherwise
+func (c *Client) PutIfMatch(path, etag string, body interface{}) (*http.Response, error) {
+	return c.doRequestWithHeader("PUT", path, body, http.Header{"If-Match": {etag}})
+}
+
 // Delete executes a DELETE request
 func (c *Client) Delete(path string) (*http.Response, error) {
 	return c.doRequest("DELETE", path, nil)
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/client/prompts"
+	"github.com/spf13/cobra"
+)
+
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Administer the registry server",
+	Long:  `Administrative operations on the registry server (admin scope required).`,
+}
+
+var adminRestoreCmd = &cobra.Command{
+	Use:   "restore <backup-ref>",
+	Short: "Restore the registry data from a backup",
+	Long: `Replace the whole registry data, in memory and in storage, with a backup
+taken by the server's scheduled backups. The backup is named by its snapshot
+name, e.g. registry-2024-05-01T12-00-00Z.json, as found in the backup
+directory or S3 prefix; /api/v1/metrics reports the last one.
+
+Every change made since the backup is lost. Sync clients and other instances
+see the restore as a new change.`,
+	Example: `  cola-regctl admin restore registry-2024-05-01T12-00-00Z.json`,
+	Args:    cobra.ExactArgs(1),
+	Run:     runAdminRestore,
+}
+
+var adminRollbackCmd = &cobra.Command{
+	Use:   "rollback --revision <revision>",
+	Short: "Roll the registry data back to a prior storage version",
+	Long: `Replace the whole registry data, in memory and in storage, with a prior
+version kept by the storage backend: a revision tag of OCI storage, given by
+its number (123) or tag (rev-000123), or an object version ID of S3 storage
+with bucket versioning. GET /api/v1/admin/storage-versions lists them.
+
+Every change made since that version is lost. The rolled back data is
+persisted as a new version, so the rollback can itself be rolled back.`,
+	Example: `  cola-regctl admin rollback --revision 123`,
+	Args:    cobra.NoArgs,
+	Run:     runAdminRollback,
+}
+
+func init() {
+	adminRollbackCmd.Flags().String("revision", "", "Storage version to roll back to")
+	adminRollbackCmd.MarkFlagRequired("revision")
+
+	adminCmd.AddCommand(adminRestoreCmd)
+	adminCmd.AddCommand(adminRollbackCmd)
+
+	rootCmd.AddCommand(adminCmd)
+}
+
+// adminRestoreResponse is the response of POST /api/v1/admin/restore
+type adminRestoreResponse struct {
+	Status string `json:"status"`
+	Backup string `json:"backup"`
+}
+
+func runAdminRestore(cmd *cobra.Command, args []string) {
+	backup := args[0]
+	c := getAuthenticatedClient()
+
+	// Prompt for confirmation unless --yes flag is set
+	if !flagYes {
+		if !prompts.ConfirmAction(fmt.Sprintf("This will replace all registry data with backup '%s'", backup)) {
+			fmt.Println("Restore cancelled")
+			return
+		}
+	}
+
+	resp, err := c.Post("/api/v1/admin/restore", map[string]string{"backup": backup})
+	if err != nil {
+		errors.ExitWithError(err, "failed to restore backup")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to restore backup: %s", string(body)))
+	}
+
+	var result adminRestoreResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+	if flagJSON {
+		output.OutputJSON(result, nil)
+	} else {
+		output.PrintSuccess(fmt.Sprintf("Restored registry data from backup '%s'", result.Backup))
+	}
+}
+
+// adminRollbackResponse is the response of POST /api/v1/admin/rollback
+type adminRollbackResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+func runAdminRollback(cmd *cobra.Command, args []string) {
+	revision, _ := cmd.Flags().GetString("revision")
+	c := getAuthenticatedClient()
+
+	// Prompt for confirmation unless --yes flag is set
+	if !flagYes {
+		if !prompts.ConfirmAction(fmt.Sprintf("This will replace all registry data with storage version '%s'", revision)) {
+			fmt.Println("Rollback cancelled")
+			return
+		}
+	}
+
+	resp, err := c.Post("/api/v1/admin/rollback", map[string]string{"version": revision})
+	if err != nil {
+		errors.ExitWithError(err, "failed to roll back")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to roll back: %s", string(body)))
+	}
+
+	var result adminRollbackResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+	if flagJSON {
+		output.OutputJSON(result, nil)
+	} else {
+		output.PrintSuccess(fmt.Sprintf("Rolled registry data back to storage version '%s'", result.Version))
+	}
+}
@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/client/prompts"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	apiKeyPackagePrefix string
+	apiKeyDescription   string
+	apiKeyExpiresIn     time.Duration
+)
+
+var apiKeyCmd = &cobra.Command{
+	Use:   "api-key",
+	Short: "Manage registry API keys for CI publishing",
+	Long: `Manage API keys restricted to one registry. A key can read its registry and
+publish versions of the packages whose names start with its prefix, nothing
+else. Registry admins create them for CI pipelines.`,
+}
+
+var apiKeyCreateCmd = &cobra.Command{
+	Use:   "create <registry>",
+	Short: "Create an API key",
+	Long: `Create an API key for a registry and print its token. The token is shown
+only once: store it as a CI secret and use it as COLA_REGISTRY_SESSION_TOKEN.`,
+	Example: `  # Key publishing the payments-* packages, for a year
+  cola-regctl api-key create tools --package-prefix payments- --description "payments CI" --expires-in 8760h`,
+	Args: cobra.ExactArgs(1),
+	Run:  runAPIKeyCreate,
+}
+
+var apiKeyListCmd = &cobra.Command{
+	Use:   "list <registry>",
+	Short: "List the API keys of a registry",
+	Args:  cobra.ExactArgs(1),
+	Run:   runAPIKeyList,
+}
+
+var apiKeyDeleteCmd = &cobra.Command{
+	Use:   "delete <registry> <id>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(2),
+	Run:   runAPIKeyDelete,
+}
+
+func init() {
+	apiKeyCmd.AddCommand(apiKeyCreateCmd)
+	apiKeyCmd.AddCommand(apiKeyListCmd)
+	apiKeyCmd.AddCommand(apiKeyDeleteCmd)
+
+	apiKeyCreateCmd.Flags().StringVar(&apiKeyPackagePrefix, "package-prefix", "", "Packages the key may publish versions of (default: every package)")
+	apiKeyCreateCmd.Flags().StringVar(&apiKeyDescription, "description", "", "What the key is used for")
+	apiKeyCreateCmd.Flags().DurationVar(&apiKeyExpiresIn, "expires-in", 0, "Revoke the key after this duration (default: never)")
+
+	rootCmd.AddCommand(apiKeyCmd)
+}
+
+// apiKeyCreated is a new API key with its token
+type apiKeyCreated struct {
+	models.APIKey
+	Token string `json:"token"`
+}
+
+func runAPIKeyCreate(cmd *cobra.Command, args []string) {
+	registryName := args[0]
+	if apiKeyExpiresIn < 0 {
+		errors.ExitWithCode(errors.ExitInvalidArguments, "--expires-in must be positive")
+	}
+
+	body := map[string]interface{}{
+		"package_prefix": apiKeyPackagePrefix,
+	}
+	if apiKeyDescription != "" {
+		body["description"] = apiKeyDescription
+	}
+	if apiKeyExpiresIn > 0 {
+		body["expires_at"] = time.Now().Add(apiKeyExpiresIn).UTC().Format(time.RFC3339)
+	}
+
+	c := getAuthenticatedClient()
+	resp, err := c.Post(fmt.Sprintf("/api/v1/registry/%s/api-keys", registryName), body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to create API key")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to create API key: %s", string(body)))
+	}
+
+	var key apiKeyCreated
+	if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+
+	if flagJSON {
+		output.OutputJSON(key, nil)
+		return
+	}
+	output.PrintSuccess(fmt.Sprintf("Created API key '%s' for registry '%s'", key.ID, registryName))
+	fmt.Println("Token (shown only once):")
+	fmt.Println(key.Token)
+}
+
+func runAPIKeyList(cmd *cobra.Command, args []string) {
+	registryName := args[0]
+
+	c := getAuthenticatedClient()
+	resp, err := c.Get(fmt.Sprintf("/api/v1/registry/%s/api-keys", registryName))
+	if err != nil {
+		errors.ExitWithError(err, "failed to list API keys")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to list API keys: %s", string(body)))
+	}
+
+	var keys []models.APIKey
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+
+	if flagJSON {
+		output.OutputJSON(keys, nil)
+		return
+	}
+	if len(keys) == 0 {
+		fmt.Println("No API keys found")
+		return
+	}
+
+	table := output.NewTableWriter()
+	table.WriteHeader("ID", "PACKAGE PREFIX", "DESCRIPTION", "CREATED BY", "EXPIRES")
+	for _, key := range keys {
+		prefix := key.PackagePrefix
+		if prefix == "" {
+			prefix = "*"
+		}
+		expires := "never"
+		if key.ExpiresAt != nil {
+			expires = key.ExpiresAt.Format(time.RFC3339)
+		}
+		table.WriteRow(key.ID, prefix, key.Description, key.CreatedBy, expires)
+	}
+	table.Flush()
+}
+
+func runAPIKeyDelete(cmd *cobra.Command, args []string) {
+	registryName, id := args[0], args[1]
+
+	if !flagYes {
+		if !prompts.ConfirmDeletion("API key", id, "") {
+			fmt.Println("Deletion cancelled")
+			return
+		}
+	}
+
+	c := getAuthenticatedClient()
+	resp, err := c.Delete(fmt.Sprintf("/api/v1/registry/%s/api-keys/%s", registryName, id))
+	if err != nil {
+		errors.ExitWithError(err, "failed to delete API key")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to delete API key: %s", string(body)))
+	}
+
+	if flagJSON {
+		output.OutputJSON(map[string]bool{"deleted": true}, nil)
+	} else {
+		output.PrintSuccess(fmt.Sprintf("Deleted API key '%s' of registry '%s'", id, registryName))
+	}
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/checksum"
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/spf13/cobra"
+)
+
+var checksumAlgorithm string
+
+var checksumCmd = &cobra.Command{
+	Use:   "checksum <file>...",
+	Short: "Compute the checksum of package archives",
+	Long: `Compute the checksum of package archives in the format the registry expects,
+<algorithm>:<hex digest>, ready to pass to 'version create --checksum'.
+
+Supported algorithms: ` + strings.Join(checksum.Algorithms, ", ") + `. No server is contacted.`,
+	Example: `  # sha256, the default
+  cola-regctl checksum deploy-1.2.0.zip
+
+  # Stronger digest for new artifacts
+  cola-regctl checksum --algorithm sha512 deploy-1.2.0.zip`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runChecksum,
+}
+
+func init() {
+	rootCmd.AddCommand(checksumCmd)
+
+	checksumCmd.Flags().StringVar(&checksumAlgorithm, "algorithm", checksum.SHA256, "Checksum algorithm ("+strings.Join(checksum.Algorithms, "|")+")")
+}
+
+func runChecksum(cmd *cobra.Command, args []string) {
+	if _, err := checksum.New(checksumAlgorithm); err != nil {
+		errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+	}
+
+	sums := make([]map[string]string, 0, len(args))
+	for _, file := range args {
+		sum, err := checksum.File(file, checksumAlgorithm)
+		if err != nil {
+			errors.ExitWithError(err, fmt.Sprintf("failed to read %s", file))
+		}
+		sums = append(sums, map[string]string{"file": file, "checksum": sum})
+	}
+
+	if flagJSON {
+		output.OutputJSON(sums, nil)
+		return
+	}
+	for _, sum := range sums {
+		fmt.Printf("%s  %s\n", sum["checksum"], sum["file"])
+	}
+}
@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/client/config"
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	consistencyServers  []string
+	consistencyRegistry string
+	consistencyBackend  string
+)
+
+// Divergence kinds reported by check-consistency
+const (
+	divergenceMissing   = "missing"
+	divergenceChecksum  = "checksum"
+	divergenceURL       = "url"
+	divergencePartition = "partition"
+)
+
+// consistencyMissing is the value shown for a source lacking a version
+const consistencyMissing = "(missing)"
+
+// consistencySource is the published index of one server or backend file,
+// keyed by "package@version"
+type consistencySource struct {
+	Name    string
+	Entries map[string]models.IndexEntry
+}
+
+// divergence is one difference between sources for a package version
+type divergence struct {
+	Package string            `json:"package"`
+	Version string            `json:"version"`
+	Kind    string            `json:"kind"`
+	Values  map[string]string `json:"values"` // source -> value
+}
+
+var checkConsistencyCmd = &cobra.Command{
+	Use:   "check-consistency",
+	Short: "Compare a registry across servers and storage",
+	Long: `Compare the published index of a registry across several servers, and
+optionally a storage file, and report divergences: versions missing from some
+sources and versions whose checksum, URL or partitions differ.
+
+--backend reads a JSON storage file, such as a file:// storage file or an S3
+object or OCI layer downloaded from a server's backend. Convert CBOR or
+compressed data first with 'cola-registry storage convert'. Embargoed versions
+in the file are ignored, like in index.json.
+
+Exits with code 8 when divergences are found.`,
+	Example: `  # Compare three regional servers
+  cola-regctl check-consistency --servers https://eu.example.com,https://us.example.com,https://ap.example.com --registry tools
+
+  # Compare a server with its backend object
+  cola-regctl check-consistency --servers https://eu.example.com --backend registry.json --registry tools`,
+	Args: cobra.NoArgs,
+	Run:  runCheckConsistency,
+}
+
+func init() {
+	rootCmd.AddCommand(checkConsistencyCmd)
+
+	checkConsistencyCmd.Flags().StringSliceVar(&consistencyServers, "servers", nil, "Comma-separated server URLs to compare")
+	checkConsistencyCmd.Flags().StringVar(&consistencyRegistry, "registry", "", "Registry to compare (required)")
+	checkConsistencyCmd.Flags().StringVar(&consistencyBackend, "backend", "", "JSON storage file to include in the comparison")
+	checkConsistencyCmd.MarkFlagRequired("registry")
+}
+
+func runCheckConsistency(cmd *cobra.Command, args []string) {
+	if len(consistencyServers)+boolToInt(consistencyBackend != "") < 2 {
+		errors.ExitWithCode(errors.ExitInvalidArguments, "at least two sources are required (--servers and/or --backend)")
+	}
+
+	var sources []consistencySource
+	for _, serverURL := range consistencyServers {
+		c := getAuthenticatedClientFor(config.NormalizeURL(serverURL))
+		entries, err := fetchRegistryIndex(c, consistencyRegistry)
+		if err != nil {
+			errors.ExitWithError(err, fmt.Sprintf("failed to fetch index from %s", serverURL))
+		}
+		sources = append(sources, newConsistencySource(serverURL, entries))
+	}
+	if consistencyBackend != "" {
+		entries, err := readBackendIndex(consistencyBackend, consistencyRegistry, time.Now())
+		if err != nil {
+			errors.ExitWithError(err, fmt.Sprintf("failed to read backend %s", consistencyBackend))
+		}
+		sources = append(sources, newConsistencySource(consistencyBackend, entries))
+	}
+
+	divergences := compareSources(sources)
+
+	if flagJSON {
+		names := make([]string, len(sources))
+		for i, s := range sources {
+			names[i] = s.Name
+		}
+		output.OutputJSON(map[string]interface{}{
+			"registry":    consistencyRegistry,
+			"sources":     names,
+			"consistent":  len(divergences) == 0,
+			"divergences": divergences,
+		}, nil)
+	} else if len(divergences) == 0 {
+		output.PrintSuccess(fmt.Sprintf("Registry '%s' is consistent across %d sources", consistencyRegistry, len(sources)))
+	} else {
+		headers := []string{"PACKAGE", "VERSION", "DIVERGENCE"}
+		for _, s := range sources {
+			headers = append(headers, s.Name)
+		}
+		table := output.NewTableWriter()
+		table.WriteHeader(headers...)
+		for _, d := range divergences {
+			row := []string{d.Package, d.Version, d.Kind}
+			for _, s := range sources {
+				row = append(row, d.Values[s.Name])
+			}
+			table.WriteRow(row...)
+		}
+		table.Flush()
+	}
+
+	if len(divergences) > 0 {
+		errors.ExitWithCode(errors.ExitInconsistent, fmt.Sprintf("registry '%s' has %d divergence(s)", consistencyRegistry, len(divergences)))
+	}
+}
+
+// fetchRegistryIndex fetches the published index.json of a registry
+func fetchRegistryIndex(c *client.Client, registryName string) ([]models.IndexEntry, error) {
+	resp, err := c.Get(fmt.Sprintf("/api/v1/registry/%s/index.json", registryName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var entries []models.IndexEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %w", err)
+	}
+	return entries, nil
+}
+
+// readBackendIndex builds the index a server would publish from a JSON
+// storage file, leaving out versions still embargoed at now
+func readBackendIndex(path, registryName string, now time.Time) ([]models.IndexEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var storage models.Storage
+	if err := json.Unmarshal(data, &storage); err != nil {
+		return nil, fmt.Errorf("not a JSON storage file (convert CBOR or compressed data with 'cola-registry storage convert'): %w", err)
+	}
+	models.Normalize(&storage)
+
+	registry, ok := storage.Registries[registryName]
+	if !ok {
+		return nil, fmt.Errorf("registry '%s' not found", registryName)
+	}
+	return registry.Index(now), nil
+}
+
+func newConsistencySource(name string, entries []models.IndexEntry) consistencySource {
+	s := consistencySource{Name: name, Entries: make(map[string]models.IndexEntry, len(entries))}
+	for _, e := range entries {
+		s.Entries[e.Name+"@"+e.Version] = e
+	}
+	return s
+}
+
+// compareSources reports the divergences between sources, sorted by package
+// and version. A version missing from some sources is reported once as
+// "missing"; field differences are only compared among sources that have it.
+func compareSources(sources []consistencySource) []divergence {
+	keys := make(map[string]models.IndexEntry)
+	for _, s := range sources {
+		for key, e := range s.Entries {
+			keys[key] = e
+		}
+	}
+
+	var divergences []divergence
+	for _, ref := range keys {
+		key := ref.Name + "@" + ref.Version
+
+		missing := false
+		for _, s := range sources {
+			if _, ok := s.Entries[key]; !ok {
+				missing = true
+			}
+		}
+		if missing {
+			values := make(map[string]string, len(sources))
+			for _, s := range sources {
+				if e, ok := s.Entries[key]; ok {
+					values[s.Name] = e.Checksum
+				} else {
+					values[s.Name] = consistencyMissing
+				}
+			}
+			divergences = append(divergences, divergence{Package: ref.Name, Version: ref.Version, Kind: divergenceMissing, Values: values})
+		}
+
+		fields := []struct {
+			kind  string
+			value func(models.IndexEntry) string
+		}{
+			{divergenceChecksum, func(e models.IndexEntry) string { return e.Checksum }},
+			{divergenceURL, func(e models.IndexEntry) string { return e.URL }},
+			{divergencePartition, func(e models.IndexEntry) string {
+				return strconv.Itoa(e.StartPartition) + "-" + strconv.Itoa(e.EndPartition)
+			}},
+		}
+		for _, field := range fields {
+			values := make(map[string]string, len(sources))
+			distinct := make(map[string]bool)
+			for _, s := range sources {
+				e, ok := s.Entries[key]
+				if !ok {
+					values[s.Name] = consistencyMissing
+					continue
+				}
+				values[s.Name] = field.value(e)
+				distinct[values[s.Name]] = true
+			}
+			if len(distinct) > 1 {
+				divergences = append(divergences, divergence{Package: ref.Name, Version: ref.Version, Kind: field.kind, Values: values})
+			}
+		}
+	}
+
+	sort.Slice(divergences, func(i, j int) bool {
+		a, b := divergences[i], divergences[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Version != b.Version {
+			return models.CompareVersions(a.Version, b.Version) < 0
+		}
+		return a.Kind < b.Kind
+	})
+	return divergences
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
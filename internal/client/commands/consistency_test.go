@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func entry(name, version, checksum string) models.IndexEntry {
+	return models.IndexEntry{
+		Name:           name,
+		Version:        version,
+		Checksum:       checksum,
+		URL:            "https://example.com/" + name + "-" + version + ".zip",
+		StartPartition: 0,
+		EndPartition:   9,
+	}
+}
+
+func TestCompareSourcesConsistent(t *testing.T) {
+	a := newConsistencySource("a", []models.IndexEntry{entry("tool", "1.0.0", "sha256:1")})
+	b := newConsistencySource("b", []models.IndexEntry{entry("tool", "1.0.0", "sha256:1")})
+
+	assert.Empty(t, compareSources([]consistencySource{a, b}))
+}
+
+func TestCompareSourcesDivergences(t *testing.T) {
+	moved := entry("tool", "1.0.0", "sha256:1")
+	moved.EndPartition = 4
+
+	a := newConsistencySource("a", []models.IndexEntry{
+		entry("tool", "1.0.0", "sha256:1"),
+		entry("tool", "2.0.0", "sha256:2"),
+		entry("other", "0.1.0", "sha256:x"),
+	})
+	b := newConsistencySource("b", []models.IndexEntry{
+		moved,
+		entry("tool", "2.0.0", "sha256:changed"),
+	})
+
+	got := compareSources([]consistencySource{a, b})
+	require.Len(t, got, 3)
+
+	assert.Equal(t, divergence{Package: "other", Version: "0.1.0", Kind: divergenceMissing,
+		Values: map[string]string{"a": "sha256:x", "b": consistencyMissing}}, got[0])
+	assert.Equal(t, divergence{Package: "tool", Version: "1.0.0", Kind: divergencePartition,
+		Values: map[string]string{"a": "0-9", "b": "0-4"}}, got[1])
+	assert.Equal(t, divergence{Package: "tool", Version: "2.0.0", Kind: divergenceChecksum,
+		Values: map[string]string{"a": "sha256:2", "b": "sha256:changed"}}, got[2])
+}
+
+func TestCompareSourcesMissingOnlyReportedOnce(t *testing.T) {
+	a := newConsistencySource("a", []models.IndexEntry{entry("tool", "1.0.0", "sha256:1")})
+	b := newConsistencySource("b", []models.IndexEntry{entry("tool", "1.0.0", "sha256:1")})
+	c := newConsistencySource("c", nil)
+
+	got := compareSources([]consistencySource{a, b, c})
+	require.Len(t, got, 1)
+	assert.Equal(t, divergenceMissing, got[0].Kind)
+}
+
+func TestReadBackendIndexSkipsPending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	data := `{"registries":{"tools":{"name":"tools","packages":{"tool":{"name":"tool","versions":{
+		"1.0.0":{"name":"tool","version":"1.0.0","checksum":"sha256:1","url":"https://example.com/1.zip","startPartition":0,"endPartition":9},
+		"2.0.0":{"name":"tool","version":"2.0.0","checksum":"sha256:2","url":"https://example.com/2.zip","startPartition":0,"endPartition":9,"publish_at":"2099-01-01T00:00:00Z"}
+	}}}}}}`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+
+	entries, err := readBackendIndex(path, "tools", time.Now())
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "1.0.0", entries[0].Version)
+
+	_, err = readBackendIndex(path, "missing", time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/client/download"
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	downloadOutput   string
+	downloadVerify   bool
+	downloadExtract  string
+	downloadParallel int
+)
+
+var downloadCmd = &cobra.Command{
+	Use:   "download <registry> <package> <version>",
+	Short: "Download a version's package archive",
+	Long: `Download the package archive of a version from its registered URL.
+
+With --verify, the archive's checksum must match the checksum registered for the
+version; otherwise the file is discarded and the command exits with code 7.
+Large archives are fetched with several concurrent ranged requests when the
+hosting server supports them. Registry credentials are not sent to the archive
+URL.`,
+	Example: `  # Download and verify a release
+  cola-regctl download tools deploy 1.2.0 --verify
+
+  # Smoke-test a release by unpacking it
+  cola-regctl download tools deploy 1.2.0 --verify --extract ./deploy-1.2.0`,
+	Args: cobra.ExactArgs(3),
+	Run:  runDownload,
+}
+
+func init() {
+	rootCmd.AddCommand(downloadCmd)
+
+	downloadCmd.Flags().StringVarP(&downloadOutput, "output", "o", "", "Destination file (default: file name from the URL)")
+	downloadCmd.Flags().BoolVar(&downloadVerify, "verify", false, "Verify the archive against the registered checksum")
+	downloadCmd.Flags().StringVar(&downloadExtract, "extract", "", "Extract the archive (zip or tar.gz) into this directory")
+	downloadCmd.Flags().IntVar(&downloadParallel, "parallel", 4, "Concurrent ranged requests for large archives (1 disables)")
+}
+
+func runDownload(cmd *cobra.Command, args []string) {
+	registryName, packageName, versionName := args[0], args[1], args[2]
+	c := getAuthenticatedClient()
+
+	version := fetchVersion(c, registryName, packageName, versionName)
+
+	dest := downloadOutput
+	if dest == "" {
+		dest = archiveFileName(version.URL, version.Name, version.Version)
+	}
+	expected := ""
+	if downloadVerify {
+		expected = version.Checksum
+	}
+
+	d := download.New(flagTimeout, downloadParallel)
+	result, err := d.Fetch(cmd.Context(), version.URL, dest, expected)
+	if err != nil {
+		if stderrors.Is(err, download.ErrChecksumMismatch) {
+			errors.ExitWithCode(errors.ExitVerifyFailed, err.Error())
+		}
+		errors.ExitWithError(err, fmt.Sprintf("failed to download %s", version.URL))
+	}
+
+	extracted := 0
+	if downloadExtract != "" {
+		extracted, err = download.Extract(result.Path, downloadExtract)
+		if err != nil {
+			errors.ExitWithError(err, "failed to extract archive")
+		}
+	}
+
+	if flagJSON {
+		output.OutputJSON(map[string]interface{}{
+			"registry":        registryName,
+			"package":         packageName,
+			"version":         versionName,
+			"url":             version.URL,
+			"path":            result.Path,
+			"size":            result.Size,
+			"checksum":        result.Checksum,
+			"verified":        downloadVerify,
+			"extracted_files": extracted,
+		}, nil)
+		return
+	}
+
+	output.PrintSuccess(fmt.Sprintf("Downloaded %s (%d bytes) to %s", version.URL, result.Size, result.Path))
+	if downloadVerify {
+		fmt.Printf("Checksum verified: %s\n", result.Checksum)
+	} else {
+		fmt.Printf("Checksum (not verified): %s\n", result.Checksum)
+	}
+	if downloadExtract != "" {
+		fmt.Printf("Extracted %d file(s) to %s\n", extracted, downloadExtract)
+	}
+}
+
+// fetchVersion retrieves a version's registry entry
+func fetchVersion(c *client.Client, registryName, packageName, versionName string) *models.Version {
+	resp, err := c.Get(fmt.Sprintf("/api/v1/registry/%s/package/%s/version/%s", registryName, packageName, versionName))
+	if err != nil {
+		errors.ExitWithError(err, "failed to get version")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to get version: %s", string(body)))
+	}
+
+	var version models.Version
+	if err := json.Unmarshal(body, &version); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+	return &version
+}
+
+// archiveFileName returns the file name of an archive URL, or
+// <package>-<version> when the URL has none
+func archiveFileName(rawURL, packageName, versionName string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if name := path.Base(u.Path); name != "." && name != "/" {
+			return name
+		}
+	}
+	return packageName + "-" + versionName
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/signing"
+	"github.com/spf13/cobra"
+)
+
+var (
+	indexSignature string
+	indexFile      string
+	indexJWKSFile  string
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Work with registry index.json files",
+	Long:  `Commands for working with the index.json served to Command Launcher clients.`,
+}
+
+var indexVerifyCmd = &cobra.Command{
+	Use:   "verify <registry>",
+	Short: "Verify the signature of a registry index",
+	Long: `Verify the signature of a registry index.json against the server's published keys.
+
+By default the index is fetched from the server and the signature is read from
+the X-Index-Signature response header. Use --file and --signature to verify a
+previously downloaded index, and --jwks to verify against a pinned key set
+instead of the one published by the server.`,
+	Example: `  # Verify the live index
+  cola-regctl index verify my-registry
+
+  # Verify a downloaded index with a pinned key set
+  cola-regctl index verify my-registry --file index.json --signature "$SIG" --jwks jwks.json`,
+	Args: cobra.ExactArgs(1),
+	Run:  runIndexVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexVerifyCmd)
+
+	indexVerifyCmd.Flags().StringVar(&indexSignature, "signature", "", "Detached JWS signature (default: X-Index-Signature header from the server)")
+	indexVerifyCmd.Flags().StringVar(&indexFile, "file", "", "Local index.json to verify instead of fetching it (requires --signature)")
+	indexVerifyCmd.Flags().StringVar(&indexJWKSFile, "jwks", "", "Local JWKS file to verify against instead of fetching it")
+}
+
+func runIndexVerify(cmd *cobra.Command, args []string) {
+	registryName := args[0]
+
+	if indexFile != "" && indexSignature == "" {
+		errors.ExitWithCode(errors.ExitInvalidArguments, "--signature is required when verifying a local --file")
+	}
+
+	var c *client.Client
+	if indexFile == "" || indexJWKSFile == "" {
+		c = getAuthenticatedClient()
+	}
+
+	// Load the index payload and its signature
+	var payload []byte
+	signature := indexSignature
+	if indexFile != "" {
+		data, err := os.ReadFile(indexFile)
+		if err != nil {
+			errors.ExitWithError(err, "failed to read index file")
+		}
+		payload = data
+	} else {
+		resp, err := c.Get(fmt.Sprintf("/api/v1/registry/%s/index.json", registryName))
+		if err != nil {
+			errors.ExitWithError(err, "failed to fetch index")
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			errors.ExitWithError(err, "failed to read response")
+		}
+		if resp.StatusCode != http.StatusOK {
+			errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to fetch index: %s", string(body)))
+		}
+		payload = body
+		if signature == "" {
+			signature = resp.Header.Get(signing.SignatureHeader)
+		}
+	}
+
+	if signature == "" {
+		errors.ExitWithCode(errors.ExitVerifyFailed, "index is not signed (no "+signing.SignatureHeader+" header)")
+	}
+
+	// Load the key set
+	var jwksData []byte
+	if indexJWKSFile != "" {
+		data, err := os.ReadFile(indexJWKSFile)
+		if err != nil {
+			errors.ExitWithError(err, "failed to read JWKS file")
+		}
+		jwksData = data
+	} else {
+		resp, err := c.Get("/api/v1/jwks.json")
+		if err != nil {
+			errors.ExitWithError(err, "failed to fetch JWKS")
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			errors.ExitWithError(err, "failed to read response")
+		}
+		if resp.StatusCode != http.StatusOK {
+			errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to fetch JWKS: %s", string(body)))
+		}
+		jwksData = body
+	}
+
+	var jwks signing.JWKS
+	if err := json.Unmarshal(jwksData, &jwks); err != nil {
+		errors.ExitWithError(err, "failed to parse JWKS")
+	}
+
+	kid, err := signing.Verify(payload, signature, &jwks)
+	if err != nil {
+		errors.ExitWithCode(errors.ExitVerifyFailed, fmt.Sprintf("signature verification failed: %v", err))
+	}
+
+	if flagJSON {
+		output.OutputJSON(map[string]interface{}{
+			"registry": registryName,
+			"valid":    true,
+			"kid":      kid,
+		}, nil)
+		return
+	}
+
+	output.PrintSuccess(fmt.Sprintf("Index for registry '%s' has a valid signature (kid %s)", registryName, kid))
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lockFrom   string
+	lockOutput string
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Pin exact package versions for fleet rollouts",
+	Long:  `Generate lockfiles pinning exact versions and checksums of registry packages.`,
+}
+
+var lockGenerateCmd = &cobra.Command{
+	Use:   "generate <registry> [package[@constraint]...]",
+	Short: "Generate a lockfile of exact versions",
+	Long: `Ask the server to pin each package to its highest released version meeting
+its constraint, and write the resulting lockfile. Committing the lockfile lets
+a fleet be rolled out to the same versions, with their checksums.
+
+Constraints combine comma-separated clauses: 1.2.3, !=1.2.3, >=1.2, <2,
+^1.2 (up to the next major), ~1.2.3 (up to the next minor) or 1.2.x.
+Pre-releases are only pinned when the constraint names one. Without packages,
+every package of the registry is pinned.
+
+With --from, packages and constraints are read from an existing lockfile, so
+rerunning the command moves each package to the newest version its constraint
+allows. Packages given as arguments are added, or replace those of the file.
+Nothing is written unless every package can be pinned.`,
+	Example: `  # Pin two packages
+  cola-regctl lock generate tools deployer@^2.1 linter@1.4.x --output cola.lock
+
+  # Refresh a committed lockfile within its constraints
+  cola-regctl lock generate tools --from cola.lock --output cola.lock`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runLockGenerate,
+}
+
+func init() {
+	lockCmd.AddCommand(lockGenerateCmd)
+
+	lockGenerateCmd.Flags().StringVar(&lockFrom, "from", "", "Read packages and constraints from this lockfile")
+	lockGenerateCmd.Flags().StringVarP(&lockOutput, "output", "o", "", "Write the lockfile to this file (default: standard output)")
+
+	rootCmd.AddCommand(lockCmd)
+}
+
+// parseLockRequirement parses a "package[@constraint]" argument
+func parseLockRequirement(arg string) (models.LockRequirement, error) {
+	name, constraint, _ := strings.Cut(arg, "@")
+	if name == "" {
+		return models.LockRequirement{}, fmt.Errorf("invalid package %q: name is required", arg)
+	}
+	if _, err := models.ParseConstraint(constraint); err != nil {
+		return models.LockRequirement{}, err
+	}
+	return models.LockRequirement{Name: name, Constraint: constraint}, nil
+}
+
+// lockRequirements merges the packages of a lockfile with those given as
+// arguments, which take precedence
+func lockRequirements(from *models.Lockfile, args []string) ([]models.LockRequirement, error) {
+	requirements := []models.LockRequirement{}
+	index := make(map[string]int)
+	if from != nil {
+		for _, pkg := range from.Packages {
+			index[pkg.Name] = len(requirements)
+			requirements = append(requirements, models.LockRequirement{Name: pkg.Name, Constraint: pkg.Constraint})
+		}
+	}
+	for _, arg := range args {
+		requirement, err := parseLockRequirement(arg)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[requirement.Name]; ok {
+			requirements[i] = requirement
+			continue
+		}
+		index[requirement.Name] = len(requirements)
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+func runLockGenerate(cmd *cobra.Command, args []string) {
+	registryName := args[0]
+
+	var from *models.Lockfile
+	if lockFrom != "" {
+		data, err := os.ReadFile(lockFrom)
+		if err != nil {
+			errors.ExitWithError(err, "failed to read lockfile")
+		}
+		from = &models.Lockfile{}
+		if err := json.Unmarshal(data, from); err != nil {
+			errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("invalid lockfile %s: %v", lockFrom, err))
+		}
+		if from.Registry != "" && from.Registry != registryName {
+			errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("lockfile %s is for registry '%s', not '%s'", lockFrom, from.Registry, registryName))
+		}
+		if len(from.Packages) == 0 && len(args) == 1 {
+			errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("lockfile %s lists no packages", lockFrom))
+		}
+	}
+	requirements, err := lockRequirements(from, args[1:])
+	if err != nil {
+		errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+	}
+
+	c := getAuthenticatedClient()
+	resp, err := c.Post(fmt.Sprintf("/api/v1/registry/%s/lock", registryName), models.LockRequest{Packages: requirements})
+	if err != nil {
+		errors.ExitWithError(err, "failed to generate lockfile")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to generate lockfile: %s", string(body)))
+	}
+
+	var lockfile models.Lockfile
+	if err := json.NewDecoder(resp.Body).Decode(&lockfile); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+	data, err := json.MarshalIndent(lockfile, "", "  ")
+	if err != nil {
+		errors.ExitWithError(err, "failed to encode lockfile")
+	}
+	data = append(data, '\n')
+
+	if lockOutput == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(lockOutput, data, 0644); err != nil {
+		errors.ExitWithError(err, "failed to write lockfile")
+	}
+	if flagJSON {
+		output.OutputJSON(map[string]interface{}{"registry": registryName, "output": lockOutput, "packages": len(lockfile.Packages)}, nil)
+	} else {
+		output.PrintSuccess(fmt.Sprintf("Locked %d package(s) of registry '%s' in %s", len(lockfile.Packages), registryName, lockOutput))
+	}
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestLockRequirements(t *testing.T) {
+	from := &models.Lockfile{Packages: []models.LockedPackage{
+		{Name: "deployer", Constraint: "^1", Version: "1.4.0"},
+		{Name: "linter", Version: "0.9.0"},
+	}}
+
+	requirements, err := lockRequirements(from, []string{"linter@0.9.x", "builder"})
+	require.NoError(t, err)
+	assert.Equal(t, []models.LockRequirement{
+		{Name: "deployer", Constraint: "^1"},
+		{Name: "linter", Constraint: "0.9.x"},
+		{Name: "builder"},
+	}, requirements)
+
+	requirements, err = lockRequirements(nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, requirements)
+
+	_, err = lockRequirements(nil, []string{"@1.0"})
+	assert.Error(t, err)
+	_, err = lockRequirements(nil, []string{"deployer@>>1"})
+	assert.Error(t, err)
+}
@@ -67,6 +67,7 @@ func runLogin(cmd *cobra.Command, args []string) {
 
 	// Test authentication by calling /api/v1/whoami
 	c := client.NewClient(serverURL, base64.StdEncoding.EncodeToString([]byte(token)), flagTimeout, flagVerbose)
+	checkClientVersion(c)
 	resp, err := c.Get("/api/v1/whoami")
 	if err != nil {
 		errors.ExitWithError(err, "failed to connect to server")
@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/criteo/command-launcher-registry/internal/checksum"
+	"github.com/criteo/command-launcher-registry/internal/client/download"
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mirrorDest    string
+	mirrorBaseURL string
+	mirrorJobs    int
+	mirrorPrune   bool
+)
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror <registry>",
+	Short: "Mirror a registry's archives to a local directory",
+	Long: `Download the archive of every version in a registry's index.json, verify
+it against its checksum, and write an index.json pointing at the mirrored
+files, for environments without access to the original hosting.
+
+Archives are stored as <dest>/<package>/<version>/<file name>. Running the
+command again only downloads versions whose archive is missing or does not
+match its checksum. The index is only rewritten when every archive was
+mirrored, so a failed run leaves the previous mirror usable; rerun to resume.
+
+URLs in the mirrored index are file:// URLs unless --base-url gives the
+address the directory is served from.`,
+	Example: `  # Mirror to a directory served by an internal web server
+  cola-regctl mirror tools --dest /srv/mirror/tools --base-url https://mirror.lab/tools
+
+  # Refresh, removing archives of versions no longer in the index
+  cola-regctl mirror tools --dest /srv/mirror/tools --base-url https://mirror.lab/tools --prune`,
+	Args: cobra.ExactArgs(1),
+	Run:  runMirror,
+}
+
+func init() {
+	rootCmd.AddCommand(mirrorCmd)
+
+	mirrorCmd.Flags().StringVar(&mirrorDest, "dest", "", "Mirror directory (required)")
+	mirrorCmd.Flags().StringVar(&mirrorBaseURL, "base-url", "", "URL the mirror directory is served from (default: file:// URLs)")
+	mirrorCmd.Flags().IntVar(&mirrorJobs, "jobs", 4, "Archives downloaded concurrently")
+	mirrorCmd.Flags().BoolVar(&mirrorPrune, "prune", false, "Remove mirrored archives of versions no longer in the index")
+	mirrorCmd.MarkFlagRequired("dest")
+}
+
+// mirrorFailure is an archive that could not be mirrored
+type mirrorFailure struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+	Error   string `json:"error"`
+}
+
+func runMirror(cmd *cobra.Command, args []string) {
+	registryName := args[0]
+	if mirrorJobs < 1 {
+		errors.ExitWithCode(errors.ExitInvalidArguments, "--jobs must be at least 1")
+	}
+	dest, err := filepath.Abs(mirrorDest)
+	if err != nil {
+		errors.ExitWithError(err, "invalid --dest")
+	}
+
+	c := getAuthenticatedClient()
+	entries, err := fetchRegistryIndex(c, registryName)
+	if err != nil {
+		errors.ExitWithError(err, "failed to fetch index")
+	}
+
+	progress := newProgress("mirror")
+	progress.Start(len(entries))
+
+	d := download.New(flagTimeout, 1)
+	var (
+		mu         sync.Mutex
+		downloaded int
+		skipped    int
+		failures   = make([]mirrorFailure, 0)
+		wg         sync.WaitGroup
+	)
+	processed := func() int { return downloaded + skipped + len(failures) }
+	jobs := make(chan int)
+	for w := 0; w < mirrorJobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				e := entries[i]
+				file := filepath.Join(dest, mirrorPath(e))
+
+				item := e.Name + "@" + e.Version
+
+				if sum, err := checksum.File(file, checksum.Algorithm(e.Checksum)); err == nil && strings.EqualFold(sum, e.Checksum) {
+					mu.Lock()
+					skipped++
+					progress.Item(item, "up_to_date", processed(), len(entries), nil)
+					mu.Unlock()
+					continue
+				}
+				_, err := d.Fetch(cmd.Context(), e.URL, file, e.Checksum)
+
+				mu.Lock()
+				if err != nil {
+					failures = append(failures, mirrorFailure{Package: e.Name, Version: e.Version, Error: err.Error()})
+					progress.Item(item, "failed", processed(), len(entries), err)
+				} else {
+					downloaded++
+					progress.Item(item, "downloaded", processed(), len(entries), nil)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Package != failures[j].Package {
+			return failures[i].Package < failures[j].Package
+		}
+		return failures[i].Version < failures[j].Version
+	})
+
+	pruned := 0
+	if len(failures) == 0 {
+		progress.Step("write_index")
+		mirrored := make([]models.IndexEntry, len(entries))
+		for i, e := range entries {
+			e.URL = mirrorURL(dest, mirrorBaseURL, mirrorPath(e))
+			mirrored[i] = e
+		}
+		if err := writeMirrorIndex(filepath.Join(dest, "index.json"), mirrored); err != nil {
+			progress.End(len(entries), len(entries), err)
+			errors.ExitWithError(err, "failed to write index")
+		}
+		if mirrorPrune {
+			progress.Step("prune")
+			pruned, err = pruneMirror(dest, entries)
+			if err != nil {
+				progress.End(len(entries), len(entries), err)
+				errors.ExitWithError(err, "failed to prune mirror")
+			}
+		}
+	}
+
+	var failed error
+	if len(failures) > 0 {
+		failed = fmt.Errorf("%d of %d archive(s) could not be mirrored; index not updated", len(failures), len(entries))
+	}
+	progress.End(len(entries), len(entries), failed)
+
+	if flagJSON {
+		output.OutputJSON(map[string]interface{}{
+			"registry":   registryName,
+			"dest":       dest,
+			"versions":   len(entries),
+			"downloaded": downloaded,
+			"skipped":    skipped,
+			"pruned":     pruned,
+			"failed":     failures,
+		}, nil)
+	} else {
+		for _, f := range failures {
+			output.PrintError(fmt.Sprintf("%s@%s: %s", f.Package, f.Version, f.Error))
+		}
+		if len(failures) == 0 {
+			output.PrintSuccess(fmt.Sprintf("Mirrored %d version(s) to %s: %d downloaded, %d up to date, %d pruned",
+				len(entries), dest, downloaded, skipped, pruned))
+		}
+	}
+
+	if failed != nil {
+		errors.ExitWithCode(errors.ExitGeneralError, failed.Error())
+	}
+}
+
+// mirrorPath is the slash-separated path of an entry's archive in the mirror
+func mirrorPath(e models.IndexEntry) string {
+	return path.Join(e.Name, e.Version, archiveFileName(e.URL, e.Name, e.Version))
+}
+
+// mirrorURL is the URL of a mirrored archive in the rewritten index
+func mirrorURL(dest, baseURL, rel string) string {
+	if baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/") + "/" + rel
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dest, rel))}).String()
+}
+
+// writeMirrorIndex writes the mirrored index atomically
+func writeMirrorIndex(file string, entries []models.IndexEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// pruneMirror removes version directories not referenced by entries
+func pruneMirror(dest string, entries []models.IndexEntry) (int, error) {
+	keep := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		keep[filepath.Join(dest, e.Name, e.Version)] = true
+	}
+
+	pruned := 0
+	packages, err := os.ReadDir(dest)
+	if err != nil {
+		return 0, err
+	}
+	for _, pkg := range packages {
+		if !pkg.IsDir() {
+			continue
+		}
+		pkgDir := filepath.Join(dest, pkg.Name())
+		versions, err := os.ReadDir(pkgDir)
+		if err != nil {
+			return pruned, err
+		}
+		for _, v := range versions {
+			dir := filepath.Join(pkgDir, v.Name())
+			if !v.IsDir() || keep[dir] {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				return pruned, err
+			}
+			pruned++
+		}
+		// Drop package directories left empty
+		if rest, err := os.ReadDir(pkgDir); err == nil && len(rest) == 0 {
+			os.Remove(pkgDir)
+		}
+	}
+	return pruned, nil
+}
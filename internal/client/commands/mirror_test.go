@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestMirrorPath(t *testing.T) {
+	assert.Equal(t, "deploy/1.0.0/deploy-linux.zip",
+		mirrorPath(models.IndexEntry{Name: "deploy", Version: "1.0.0", URL: "https://cdn.example.com/a/deploy-linux.zip?sig=x"}))
+	assert.Equal(t, "deploy/1.0.0/deploy-1.0.0",
+		mirrorPath(models.IndexEntry{Name: "deploy", Version: "1.0.0", URL: "https://cdn.example.com/"}))
+}
+
+func TestMirrorURL(t *testing.T) {
+	assert.Equal(t, "https://mirror.lab/tools/deploy/1.0.0/deploy.zip",
+		mirrorURL("/srv/mirror", "https://mirror.lab/tools/", "deploy/1.0.0/deploy.zip"))
+	assert.Equal(t, "file:///srv/mirror/deploy/1.0.0/deploy.zip",
+		mirrorURL("/srv/mirror", "", "deploy/1.0.0/deploy.zip"))
+}
+
+func TestPruneMirror(t *testing.T) {
+	dest := t.TempDir()
+	for _, dir := range []string{"deploy/1.0.0", "deploy/2.0.0", "old/0.1.0"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dest, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "index.json"), []byte("[]"), 0644))
+
+	pruned, err := pruneMirror(dest, []models.IndexEntry{{Name: "deploy", Version: "2.0.0"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, pruned)
+
+	assert.DirExists(t, filepath.Join(dest, "deploy", "2.0.0"))
+	assert.NoDirExists(t, filepath.Join(dest, "deploy", "1.0.0"))
+	assert.NoDirExists(t, filepath.Join(dest, "old"))
+	assert.FileExists(t, filepath.Join(dest, "index.json"))
+}
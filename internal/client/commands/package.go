@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/criteo/command-launcher-registry/internal/client/errors"
 	"github.com/criteo/command-launcher-registry/internal/client/output"
 	"github.com/criteo/command-launcher-registry/internal/client/prompts"
 	"github.com/criteo/command-launcher-registry/internal/client/validation"
+	"github.com/criteo/command-launcher-registry/internal/models"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +25,17 @@ var (
 	pkgCustomValues   []string
 	pkgClearMaint     bool
 	pkgClearCustomVal bool
+	pkgQuery          []string
+	pkgSort           string
+
+	// Batch update flags
+	batchNames          []string
+	batchMaintainer     string
+	batchAddMaintainers []string
+	batchRemMaintainers []string
+	batchSetValues      []string
+	batchUnsetValues    []string
+	batchDryRun         bool
 )
 
 var packageCmd = &cobra.Command{
@@ -39,8 +54,14 @@ var packageCreateCmd = &cobra.Command{
 var packageListCmd = &cobra.Command{
 	Use:   "list <registry>",
 	Short: "List all packages in a registry",
-	Args:  cobra.ExactArgs(1),
-	Run:   runPackageList,
+	Long: `List all packages in a registry.
+
+Use --custom to only list packages whose custom values match. Repeating a key
+matches any of its values; different keys must all match.`,
+	Example: `  # Packages owned by the payments team at tier 1 or 2
+  cola-regctl package list my-registry --custom team=payments --custom tier=1 --custom tier=2`,
+	Args: cobra.ExactArgs(1),
+	Run:  runPackageList,
 }
 
 var packageGetCmd = &cobra.Command{
@@ -64,6 +85,37 @@ var packageDeleteCmd = &cobra.Command{
 	Run:   runPackageDelete,
 }
 
+var packageHistoryCmd = &cobra.Command{
+	Use:   "history <registry> <package>",
+	Short: "Show the change history of a package",
+	Long: `Show the changes recorded for a package, newest first: creation, metadata
+edits, and versions published, scheduled, cancelled or deleted, with the user
+who made each change.
+
+The history of a deleted package remains available until its registry is
+deleted.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runPackageHistory,
+}
+
+var packageBatchUpdateCmd = &cobra.Command{
+	Use:   "batch-update <registry>",
+	Short: "Change the metadata of many packages at once",
+	Long: `Apply a metadata change to every package of a registry matching the filters,
+for organizational changes such as a team rename. All changed packages are
+written at once: either every package is updated or none is.
+
+Packages are selected with --name, --filter-maintainer and --custom; a package
+must match every filter given. Without filters every package is selected.
+Use --dry-run to list the packages that would change without writing anything.`,
+	Example: `  # Move the infra team's packages to the platform team
+  cola-regctl package batch-update my-registry --custom team=infra \
+    --remove-maintainer infra@example.com --add-maintainer platform@example.com \
+    --set team=platform --dry-run`,
+	Args: cobra.ExactArgs(1),
+	Run:  runPackageBatchUpdate,
+}
+
 func init() {
 	// Add subcommands
 	packageCmd.AddCommand(packageCreateCmd)
@@ -71,12 +123,18 @@ func init() {
 	packageCmd.AddCommand(packageGetCmd)
 	packageCmd.AddCommand(packageUpdateCmd)
 	packageCmd.AddCommand(packageDeleteCmd)
+	packageCmd.AddCommand(packageHistoryCmd)
+	packageCmd.AddCommand(packageBatchUpdateCmd)
 
 	// Create flags
 	packageCreateCmd.Flags().StringVar(&pkgDescription, "description", "", "Package description")
 	packageCreateCmd.Flags().StringSliceVar(&pkgMaintainers, "maintainer", []string{}, "Maintainer email (repeatable)")
 	packageCreateCmd.Flags().StringSliceVar(&pkgCustomValues, "custom-value", []string{}, "Custom key=value (repeatable)")
 
+	// List flags
+	packageListCmd.Flags().StringSliceVar(&pkgQuery, "custom", []string{}, "Filter by custom key=value (repeatable)")
+	packageListCmd.Flags().StringVar(&pkgSort, "sort", "", "Sort order (name_asc|name_desc|created_asc|created_desc|updated_asc|updated_desc)")
+
 	// Update flags
 	packageUpdateCmd.Flags().StringVar(&pkgDescription, "description", "", "Package description")
 	packageUpdateCmd.Flags().StringSliceVar(&pkgMaintainers, "maintainer", []string{}, "Maintainer email (repeatable, replaces all)")
@@ -84,6 +142,16 @@ func init() {
 	packageUpdateCmd.Flags().BoolVar(&pkgClearMaint, "clear-maintainers", false, "Clear all maintainers")
 	packageUpdateCmd.Flags().BoolVar(&pkgClearCustomVal, "clear-custom-values", false, "Clear all custom values")
 
+	// Batch update flags
+	packageBatchUpdateCmd.Flags().StringSliceVar(&batchNames, "name", []string{}, "Only packages with this name (repeatable)")
+	packageBatchUpdateCmd.Flags().StringVar(&batchMaintainer, "filter-maintainer", "", "Only packages listing this maintainer")
+	packageBatchUpdateCmd.Flags().StringSliceVar(&pkgQuery, "custom", []string{}, "Only packages with custom key=value (repeatable)")
+	packageBatchUpdateCmd.Flags().StringSliceVar(&batchAddMaintainers, "add-maintainer", []string{}, "Maintainer email to add (repeatable)")
+	packageBatchUpdateCmd.Flags().StringSliceVar(&batchRemMaintainers, "remove-maintainer", []string{}, "Maintainer email to remove (repeatable)")
+	packageBatchUpdateCmd.Flags().StringSliceVar(&batchSetValues, "set", []string{}, "Custom key=value to set (repeatable)")
+	packageBatchUpdateCmd.Flags().StringSliceVar(&batchUnsetValues, "unset", []string{}, "Custom value key to remove (repeatable)")
+	packageBatchUpdateCmd.Flags().BoolVar(&batchDryRun, "dry-run", false, "Show the packages that would change without updating them")
+
 	rootCmd.AddCommand(packageCmd)
 }
 
@@ -134,7 +202,25 @@ func runPackageList(cmd *cobra.Command, args []string) {
 	registryName := args[0]
 	c := getAuthenticatedClient()
 
-	resp, err := c.Get(fmt.Sprintf("/api/v1/registry/%s/package", registryName))
+	// Build custom value filters
+	query := url.Values{}
+	for _, cv := range pkgQuery {
+		key, value, err := validation.ValidateCustomValue(cv)
+		if err != nil {
+			errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+		}
+		query.Add("custom."+key, value)
+	}
+	if listSortQuery(pkgSort) != "" {
+		query.Set("sort", pkgSort)
+	}
+
+	path := fmt.Sprintf("/api/v1/registry/%s/package", registryName)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.Get(path)
 	if err != nil {
 		errors.ExitWithError(err, "failed to list packages")
 	}
@@ -158,13 +244,17 @@ func runPackageList(cmd *cobra.Command, args []string) {
 	if flagJSON {
 		output.OutputJSON(packages, nil)
 	} else {
+		if len(packages) == 0 && len(query) > 0 {
+			fmt.Printf("No packages matching the filters in registry '%s'\n", registryName)
+			return
+		}
 		if len(packages) == 0 {
 			fmt.Printf("No packages found in registry '%s'\n", registryName)
 			return
 		}
 
 		table := output.NewTableWriter()
-		table.WriteHeader("NAME", "DESCRIPTION", "VERSIONS")
+		table.WriteHeader("NAME", "DESCRIPTION", "VERSIONS", "UPDATED")
 		for _, pkg := range packages {
 			name := fmt.Sprintf("%v", pkg["name"])
 			description := fmt.Sprintf("%v", pkg["description"])
@@ -173,7 +263,7 @@ func runPackageList(cmd *cobra.Command, args []string) {
 			if vers, ok := pkg["versions"].(map[string]interface{}); ok {
 				versions = strconv.Itoa(len(vers))
 			}
-			table.WriteRow(name, description, versions)
+			table.WriteRow(name, description, versions, updatedAt(pkg))
 		}
 		table.Flush()
 	}
@@ -316,3 +406,135 @@ func runPackageDelete(cmd *cobra.Command, args []string) {
 		output.PrintSuccess(fmt.Sprintf("Deleted package '%s' from registry '%s'", packageName, registryName))
 	}
 }
+
+func runPackageHistory(cmd *cobra.Command, args []string) {
+	registryName := args[0]
+	packageName := args[1]
+	c := getAuthenticatedClient()
+
+	resp, err := c.Get(fmt.Sprintf("/api/v1/registry/%s/package/%s/history", registryName, packageName))
+	if err != nil {
+		errors.ExitWithError(err, "failed to get package history")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to get package history: %s", string(body)))
+	}
+
+	var history []models.ChangeRecord
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+
+	if flagJSON {
+		output.OutputJSON(history, nil)
+		return
+	}
+	if len(history) == 0 {
+		fmt.Printf("No history recorded for package '%s' in registry '%s'\n", packageName, registryName)
+		return
+	}
+
+	table := output.NewTableWriter()
+	table.WriteHeader("TIME", "TYPE", "ACTOR", "VERSION", "CHANGES")
+	for _, change := range history {
+		table.WriteRow(change.Time.Format(time.RFC3339), change.Type, change.Actor, change.Version, formatFieldChanges(change.Changes))
+	}
+	table.Flush()
+}
+
+// formatFieldChanges renders metadata edits as field: old -> new
+func formatFieldChanges(changes []models.FieldChange) string {
+	parts := make([]string, len(changes))
+	for i, change := range changes {
+		parts[i] = fmt.Sprintf("%s: %q -> %q", change.Field, change.Old, change.New)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func runPackageBatchUpdate(cmd *cobra.Command, args []string) {
+	registryName := args[0]
+	c := getAuthenticatedClient()
+
+	// Build filter
+	filter := make(map[string]interface{})
+	if len(batchNames) > 0 {
+		filter["names"] = batchNames
+	}
+	if batchMaintainer != "" {
+		filter["maintainer"] = batchMaintainer
+	}
+	if len(pkgQuery) > 0 {
+		custom := make(map[string][]string)
+		for _, cv := range pkgQuery {
+			key, value, err := validation.ValidateCustomValue(cv)
+			if err != nil {
+				errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+			}
+			custom[key] = append(custom[key], value)
+		}
+		filter["custom_values"] = custom
+	}
+
+	// Build patch
+	patch := make(map[string]interface{})
+	if len(batchAddMaintainers) > 0 {
+		patch["add_maintainers"] = batchAddMaintainers
+	}
+	if len(batchRemMaintainers) > 0 {
+		patch["remove_maintainers"] = batchRemMaintainers
+	}
+	if len(batchSetValues) > 0 {
+		values, err := validation.ParseCustomValues(batchSetValues)
+		if err != nil {
+			errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+		}
+		patch["set_custom_values"] = values
+	}
+	if len(batchUnsetValues) > 0 {
+		patch["unset_custom_values"] = batchUnsetValues
+	}
+	if len(patch) == 0 {
+		errors.ExitWithCode(errors.ExitInvalidArguments, "nothing to change: use --add-maintainer, --remove-maintainer, --set or --unset")
+	}
+
+	resp, err := c.Post(fmt.Sprintf("/api/v1/registry/%s/packages:batch-update", registryName), map[string]interface{}{
+		"filter":  filter,
+		"patch":   patch,
+		"dry_run": batchDryRun,
+	})
+	if err != nil {
+		errors.ExitWithError(err, "failed to update packages")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to update packages: %s", string(body)))
+	}
+
+	var result struct {
+		DryRun  bool                     `json:"dry_run"`
+		Matched int                      `json:"matched"`
+		Updated []map[string]interface{} `json:"updated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+
+	if flagJSON {
+		output.OutputJSON(result, nil)
+		return
+	}
+
+	for _, pkg := range result.Updated {
+		fmt.Printf("  %v\n", pkg["name"])
+	}
+	if result.DryRun {
+		output.PrintSuccess(fmt.Sprintf("Dry run: %d of %d matching package(s) in registry '%s' would be updated", len(result.Updated), result.Matched, registryName))
+	} else {
+		output.PrintSuccess(fmt.Sprintf("Updated %d of %d matching package(s) in registry '%s'", len(result.Updated), result.Matched, registryName))
+	}
+}
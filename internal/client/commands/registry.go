@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/criteo/command-launcher-registry/internal/client"
 	"github.com/criteo/command-launcher-registry/internal/client/auth"
@@ -15,6 +19,7 @@ import (
 	"github.com/criteo/command-launcher-registry/internal/client/output"
 	"github.com/criteo/command-launcher-registry/internal/client/prompts"
 	"github.com/criteo/command-launcher-registry/internal/client/validation"
+	"github.com/criteo/command-launcher-registry/internal/models"
 	"github.com/spf13/cobra"
 )
 
@@ -25,8 +30,67 @@ var (
 	regCustomValues   []string
 	regClearAdmins    bool
 	regClearCustomVal bool
+	regVersionPolicy  string
+	regSensitiveKeys  []string
+	regAnonymousRead  bool
+	regCloneVersions  bool
+	regAnnouncements  []string
+	regClearAnnounce  bool
+	regCacheTTL       time.Duration
+	regAliases        []string
+	regClearAliases   bool
+	regIndexAliases   bool
+	regExportOutput   string
+	regExportAnon     bool
+	regSort           string
 )
 
+// listSorts are the --sort orders of registry and package lists
+var listSorts = []string{"name_asc", "name_desc", "created_asc", "created_desc", "updated_asc", "updated_desc"}
+
+// listSortQuery validates a --sort order and returns its query parameter,
+// empty when no order is given
+func listSortQuery(sort string) string {
+	if sort == "" {
+		return ""
+	}
+	if !slices.Contains(listSorts, sort) {
+		errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("invalid --sort. Must be one of %s, got: '%s'", strings.Join(listSorts, ", "), sort))
+	}
+	return "sort=" + sort
+}
+
+// cacheTTLSeconds validates a --cache-ttl and returns it in whole seconds
+func cacheTTLSeconds(ttl time.Duration) int {
+	if ttl < 0 || ttl%time.Second != 0 {
+		errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("invalid --cache-ttl. Must be a whole number of seconds, got: '%s'", ttl))
+	}
+	return int(ttl.Seconds())
+}
+
+// parseAliases parses --alias old=new flags
+func parseAliases(flags []string) map[string]string {
+	aliases := make(map[string]string, len(flags))
+	for _, flag := range flags {
+		alias, target, ok := strings.Cut(flag, "=")
+		if !ok || alias == "" || target == "" {
+			errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("invalid --alias. Must be old=new, got: '%s'", flag))
+		}
+		aliases[alias] = target
+	}
+	return aliases
+}
+
+// updatedAt formats the updated_at of a listed registry or package
+func updatedAt(record map[string]interface{}) string {
+	value, _ := record["updated_at"].(string)
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
 var registryCmd = &cobra.Command{
 	Use:   "registry",
 	Short: "Manage registries",
@@ -68,6 +132,42 @@ var registryDeleteCmd = &cobra.Command{
 	Run:   runRegistryDelete,
 }
 
+var registryCloneCmd = &cobra.Command{
+	Use:   "clone <source> <new-name>",
+	Short: "Create a registry from an existing one",
+	Long: `Create a registry with the description, admins, custom values and
+packages of an existing registry, for example to bootstrap a team registry
+from a template. Versions are only copied with --include-versions.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runRegistryClone,
+}
+
+var registryExportCmd = &cobra.Command{
+	Use:   "export <name>",
+	Short: "Export a registry as JSON",
+	Long: `Export a registry with its packages and versions as JSON, to stdout or
+to a file.
+
+With --anonymize, the export can be attached to bug reports or sent to
+vendor support: emails become consistent pseudonyms, sensitive custom values
+are masked, and URLs keep only their scheme and file name, on a placeholder
+host. Names, versions, checksums and partitions are kept.`,
+	Example: `  cola-regctl registry export build-tools -o build-tools.json
+  cola-regctl registry export build-tools --anonymize > shareable.json`,
+	Args: cobra.ExactArgs(1),
+	Run:  runRegistryExport,
+}
+
+var registryBrokenLinksCmd = &cobra.Command{
+	Use:   "broken-links <name>",
+	Short: "List versions whose URL is unreachable",
+	Long: `List the versions of a registry whose URL failed the server's last link
+check, with the time they have been broken since. The server only checks
+links when scheduler.link_check_interval is set.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runRegistryBrokenLinks,
+}
+
 func init() {
 	// Add subcommands
 	registryCmd.AddCommand(registryCreateCmd)
@@ -75,11 +175,24 @@ func init() {
 	registryCmd.AddCommand(registryGetCmd)
 	registryCmd.AddCommand(registryUpdateCmd)
 	registryCmd.AddCommand(registryDeleteCmd)
+	registryCmd.AddCommand(registryCloneCmd)
+	registryCmd.AddCommand(registryExportCmd)
+	registryCmd.AddCommand(registryBrokenLinksCmd)
+
+	// List flags
+	registryListCmd.Flags().StringVar(&regSort, "sort", "", "Sort order (name_asc|name_desc|created_asc|created_desc|updated_asc|updated_desc)")
 
 	// Create flags
 	registryCreateCmd.Flags().StringVar(&regDescription, "description", "", "Registry description")
 	registryCreateCmd.Flags().StringSliceVar(&regAdmins, "admin", []string{}, "Admin email (repeatable)")
 	registryCreateCmd.Flags().StringSliceVar(&regCustomValues, "custom-value", []string{}, "Custom key=value (repeatable)")
+	registryCreateCmd.Flags().StringVar(&regVersionPolicy, "version-policy", "", "Accepted version format (semver|legacy, default semver)")
+	registryCreateCmd.Flags().StringSliceVar(&regSensitiveKeys, "sensitive-key", []string{}, "Custom value key to encrypt and mask (repeatable)")
+	registryCreateCmd.Flags().BoolVar(&regAnonymousRead, "anonymous-read", false, "Allow reads without credentials (default: server setting)")
+	registryCreateCmd.Flags().StringArrayVar(&regAnnouncements, "announcement", []string{}, "Notice shown by launchers and portals (repeatable)")
+	registryCreateCmd.Flags().DurationVar(&regCacheTTL, "cache-ttl", 0, "How long clients may cache index.json, e.g. 5m (default: server setting)")
+	registryCreateCmd.Flags().StringSliceVar(&regAliases, "alias", []string{}, "Renamed package old=new (repeatable)")
+	registryCreateCmd.Flags().BoolVar(&regIndexAliases, "index-aliases", false, "Also list aliased packages in index.json under their old name")
 
 	// Update flags
 	registryUpdateCmd.Flags().StringVar(&regDescription, "description", "", "Registry description")
@@ -87,6 +200,22 @@ func init() {
 	registryUpdateCmd.Flags().StringSliceVar(&regCustomValues, "custom-value", []string{}, "Custom key=value (repeatable, replaces all)")
 	registryUpdateCmd.Flags().BoolVar(&regClearAdmins, "clear-admins", false, "Clear all admins")
 	registryUpdateCmd.Flags().BoolVar(&regClearCustomVal, "clear-custom-values", false, "Clear all custom values")
+	registryUpdateCmd.Flags().StringVar(&regVersionPolicy, "version-policy", "", "Accepted version format (semver|legacy)")
+	registryUpdateCmd.Flags().StringSliceVar(&regSensitiveKeys, "sensitive-key", []string{}, "Custom value key to encrypt and mask (repeatable, replaces all)")
+	registryUpdateCmd.Flags().BoolVar(&regAnonymousRead, "anonymous-read", false, "Allow reads without credentials (default: server setting)")
+	registryUpdateCmd.Flags().StringArrayVar(&regAnnouncements, "announcement", []string{}, "Notice shown by launchers and portals (repeatable, replaces all)")
+	registryUpdateCmd.Flags().BoolVar(&regClearAnnounce, "clear-announcements", false, "Clear all announcements")
+	registryUpdateCmd.Flags().DurationVar(&regCacheTTL, "cache-ttl", 0, "How long clients may cache index.json, e.g. 5m (default: server setting)")
+	registryUpdateCmd.Flags().StringSliceVar(&regAliases, "alias", []string{}, "Renamed package old=new (repeatable, replaces all)")
+	registryUpdateCmd.Flags().BoolVar(&regClearAliases, "clear-aliases", false, "Clear all aliases")
+	registryUpdateCmd.Flags().BoolVar(&regIndexAliases, "index-aliases", false, "Also list aliased packages in index.json under their old name")
+
+	// Clone flags
+	registryCloneCmd.Flags().BoolVar(&regCloneVersions, "include-versions", false, "Also copy every version")
+
+	// Export flags
+	registryExportCmd.Flags().StringVarP(&regExportOutput, "output", "o", "", "Destination file (default: stdout)")
+	registryExportCmd.Flags().BoolVar(&regExportAnon, "anonymize", false, "Strip emails, sensitive custom values and internal URLs")
 
 	rootCmd.AddCommand(registryCmd)
 }
@@ -96,7 +225,12 @@ func getAuthenticatedClient() *client.Client {
 	if err != nil {
 		errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
 	}
+	return getAuthenticatedClientFor(serverURL)
+}
 
+// getAuthenticatedClientFor creates a client for an explicit server URL,
+// for commands that talk to several servers
+func getAuthenticatedClientFor(serverURL string) *client.Client {
 	token, err := auth.ResolveToken(flagToken)
 	if err != nil {
 		errors.ExitWithError(err, "failed to resolve authentication token")
@@ -107,7 +241,9 @@ func getAuthenticatedClient() *client.Client {
 	if token != "" {
 		encodedToken = base64.StdEncoding.EncodeToString([]byte(token))
 	}
-	return client.NewClient(serverURL, encodedToken, flagTimeout, flagVerbose)
+	c := client.NewClient(serverURL, encodedToken, flagTimeout, flagVerbose)
+	checkClientVersion(c)
+	return c
 }
 
 func runRegistryCreate(cmd *cobra.Command, args []string) {
@@ -133,6 +269,30 @@ func runRegistryCreate(cmd *cobra.Command, args []string) {
 	if len(customValues) > 0 {
 		reqBody["custom_values"] = customValues
 	}
+	if regVersionPolicy != "" {
+		if err := validation.ValidateVersionPolicy(regVersionPolicy); err != nil {
+			errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+		}
+		reqBody["version_policy"] = regVersionPolicy
+	}
+	if len(regSensitiveKeys) > 0 {
+		reqBody["sensitive_keys"] = regSensitiveKeys
+	}
+	if cmd.Flags().Changed("anonymous-read") {
+		reqBody["anonymous_read"] = regAnonymousRead
+	}
+	if len(regAnnouncements) > 0 {
+		reqBody["announcements"] = regAnnouncements
+	}
+	if cmd.Flags().Changed("cache-ttl") {
+		reqBody["cache_ttl"] = cacheTTLSeconds(regCacheTTL)
+	}
+	if len(regAliases) > 0 {
+		reqBody["aliases"] = parseAliases(regAliases)
+	}
+	if cmd.Flags().Changed("index-aliases") {
+		reqBody["index_aliases"] = regIndexAliases
+	}
 
 	resp, err := c.Post("/api/v1/registry", reqBody)
 	if err != nil {
@@ -155,7 +315,12 @@ func runRegistryCreate(cmd *cobra.Command, args []string) {
 func runRegistryList(cmd *cobra.Command, args []string) {
 	c := getAuthenticatedClient()
 
-	resp, err := c.Get("/api/v1/registry")
+	path := "/api/v1/registry"
+	if query := listSortQuery(regSort); query != "" {
+		path += "?" + query
+	}
+
+	resp, err := c.Get(path)
 	if err != nil {
 		errors.ExitWithError(err, "failed to list registries")
 	}
@@ -185,7 +350,7 @@ func runRegistryList(cmd *cobra.Command, args []string) {
 		}
 
 		table := output.NewTableWriter()
-		table.WriteHeader("NAME", "DESCRIPTION", "PACKAGES")
+		table.WriteHeader("NAME", "DESCRIPTION", "PACKAGES", "UPDATED")
 		for _, reg := range registries {
 			name := fmt.Sprintf("%v", reg["name"])
 			description := fmt.Sprintf("%v", reg["description"])
@@ -194,7 +359,7 @@ func runRegistryList(cmd *cobra.Command, args []string) {
 			if pkgs, ok := reg["packages"].(map[string]interface{}); ok {
 				packages = strconv.Itoa(len(pkgs))
 			}
-			table.WriteRow(name, description, packages)
+			table.WriteRow(name, description, packages, updatedAt(reg))
 		}
 		table.Flush()
 	}
@@ -230,6 +395,15 @@ func runRegistryGet(cmd *cobra.Command, args []string) {
 	} else {
 		fmt.Printf("Name: %v\n", registry["name"])
 		fmt.Printf("Description: %v\n", registry["description"])
+		if policy, ok := registry["version_policy"].(string); ok && policy != "" {
+			fmt.Printf("Version Policy: %s\n", policy)
+		}
+		if keys, ok := registry["sensitive_keys"].([]interface{}); ok && len(keys) > 0 {
+			fmt.Printf("Sensitive Keys: %v\n", keys)
+		}
+		if ttl, ok := registry["cache_ttl"].(float64); ok {
+			fmt.Printf("Index Cache TTL: %s\n", time.Duration(ttl)*time.Second)
+		}
 		if admins, ok := registry["admins"].([]interface{}); ok && len(admins) > 0 {
 			fmt.Print("Admins:")
 			for _, admin := range admins {
@@ -243,9 +417,74 @@ func runRegistryGet(cmd *cobra.Command, args []string) {
 				fmt.Printf("  %s: %v\n", k, v)
 			}
 		}
+		if announcements, ok := registry["announcements"].([]interface{}); ok && len(announcements) > 0 {
+			fmt.Print("Announcements:")
+			for _, announcement := range announcements {
+				fmt.Printf("\n  - %v", announcement)
+			}
+			fmt.Println()
+		}
+		if aliases, ok := registry["aliases"].(map[string]interface{}); ok && len(aliases) > 0 {
+			fmt.Println("Aliases:")
+			for alias, target := range aliases {
+				fmt.Printf("  %s -> %v\n", alias, target)
+			}
+		}
 	}
 }
 
+func runRegistryBrokenLinks(cmd *cobra.Command, args []string) {
+	name := args[0]
+	c := getAuthenticatedClient()
+
+	resp, err := c.Get("/api/v1/registry/" + name + "/broken-links")
+	if err != nil {
+		errors.ExitWithError(err, "failed to get broken links")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to get broken links: %s", string(body)))
+	}
+
+	var report struct {
+		Enabled bool `json:"enabled"`
+		Links   []struct {
+			Package     string `json:"package"`
+			Version     string `json:"version"`
+			URL         string `json:"url"`
+			Error       string `json:"error"`
+			BrokenSince string `json:"broken_since"`
+		} `json:"links"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+
+	if flagJSON {
+		output.OutputJSON(json.RawMessage(body), nil)
+		return
+	}
+	if !report.Enabled {
+		fmt.Println("Link checking is disabled on the server")
+		return
+	}
+	if len(report.Links) == 0 {
+		fmt.Println("No broken links found")
+		return
+	}
+	table := output.NewTableWriter()
+	table.WriteHeader("PACKAGE", "VERSION", "ERROR", "BROKEN SINCE", "URL")
+	for _, link := range report.Links {
+		table.WriteRow(link.Package, link.Version, link.Error, link.BrokenSince, link.URL)
+	}
+	table.Flush()
+}
+
 func runRegistryUpdate(cmd *cobra.Command, args []string) {
 	name := args[0]
 	c := getAuthenticatedClient()
@@ -257,6 +496,12 @@ func runRegistryUpdate(cmd *cobra.Command, args []string) {
 	if regClearCustomVal && len(regCustomValues) > 0 {
 		errors.ExitWithCode(errors.ExitInvalidArguments, "cannot use --clear-custom-values with --custom-value. Use one or the other")
 	}
+	if regClearAnnounce && len(regAnnouncements) > 0 {
+		errors.ExitWithCode(errors.ExitInvalidArguments, "cannot use --clear-announcements with --announcement. Use one or the other")
+	}
+	if regClearAliases && len(regAliases) > 0 {
+		errors.ExitWithCode(errors.ExitInvalidArguments, "cannot use --clear-aliases with --alias. Use one or the other")
+	}
 
 	// Validate and parse custom values
 	var customValues map[string]string
@@ -283,6 +528,34 @@ func runRegistryUpdate(cmd *cobra.Command, args []string) {
 	} else if len(customValues) > 0 {
 		reqBody["custom_values"] = customValues
 	}
+	if regVersionPolicy != "" {
+		if err := validation.ValidateVersionPolicy(regVersionPolicy); err != nil {
+			errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+		}
+		reqBody["version_policy"] = regVersionPolicy
+	}
+	if len(regSensitiveKeys) > 0 {
+		reqBody["sensitive_keys"] = regSensitiveKeys
+	}
+	if cmd.Flags().Changed("anonymous-read") {
+		reqBody["anonymous_read"] = regAnonymousRead
+	}
+	if regClearAnnounce {
+		reqBody["announcements"] = []string{}
+	} else if len(regAnnouncements) > 0 {
+		reqBody["announcements"] = regAnnouncements
+	}
+	if cmd.Flags().Changed("cache-ttl") {
+		reqBody["cache_ttl"] = cacheTTLSeconds(regCacheTTL)
+	}
+	if regClearAliases {
+		reqBody["aliases"] = map[string]string{}
+	} else if len(regAliases) > 0 {
+		reqBody["aliases"] = parseAliases(regAliases)
+	}
+	if cmd.Flags().Changed("index-aliases") {
+		reqBody["index_aliases"] = regIndexAliases
+	}
 
 	resp, err := c.Put("/api/v1/registry/"+name, reqBody)
 	if err != nil {
@@ -331,3 +604,97 @@ func runRegistryDelete(cmd *cobra.Command, args []string) {
 		output.PrintSuccess(fmt.Sprintf("Deleted registry '%s'", name))
 	}
 }
+
+func runRegistryClone(cmd *cobra.Command, args []string) {
+	source, newName := args[0], args[1]
+	c := getAuthenticatedClient()
+
+	resp, err := c.Post(fmt.Sprintf("/api/v1/registry/%s/clone", source), map[string]interface{}{
+		"new_name":         newName,
+		"include_versions": regCloneVersions,
+	})
+	if err != nil {
+		errors.ExitWithError(err, "failed to clone registry")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to read response")
+	}
+	if resp.StatusCode != http.StatusCreated {
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to clone registry: %s", string(body)))
+	}
+
+	var registry models.Registry
+	if err := json.Unmarshal(body, &registry); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+	versions := 0
+	for _, pkg := range registry.Packages {
+		versions += len(pkg.Versions)
+	}
+
+	if flagJSON {
+		output.OutputJSON(map[string]interface{}{
+			"source":   source,
+			"name":     newName,
+			"packages": len(registry.Packages),
+			"versions": versions,
+		}, nil)
+	} else {
+		output.PrintSuccess(fmt.Sprintf("Cloned registry '%s' to '%s' (%d packages, %d versions)", source, newName, len(registry.Packages), versions))
+	}
+}
+
+func runRegistryExport(cmd *cobra.Command, args []string) {
+	name := args[0]
+	c := getAuthenticatedClient()
+
+	resp, err := c.Get("/api/v1/registry/" + name)
+	if err != nil {
+		errors.ExitWithError(err, "failed to export registry")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to export registry: %s", string(body)))
+	}
+
+	var registry *models.Registry
+	if err := json.Unmarshal(body, &registry); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+	if regExportAnon {
+		registry = models.NewAnonymizer().Registry(registry)
+	}
+
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		errors.ExitWithError(err, "failed to encode registry")
+	}
+	data = append(data, '\n')
+
+	if regExportOutput == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(regExportOutput, data, 0644); err != nil {
+		errors.ExitWithError(err, "failed to write export")
+	}
+
+	if flagJSON {
+		output.OutputJSON(map[string]interface{}{
+			"name":       name,
+			"file":       regExportOutput,
+			"packages":   len(registry.Packages),
+			"anonymized": regExportAnon,
+		}, nil)
+	} else {
+		output.PrintSuccess(fmt.Sprintf("Exported registry '%s' to %s (%d packages)", name, regExportOutput, len(registry.Packages)))
+	}
+}
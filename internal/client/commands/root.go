@@ -1,11 +1,14 @@
 package commands
 
 import (
-	"fmt"
 	"os"
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
 )
 
 var (
@@ -16,6 +19,9 @@ var (
 	flagVerbose bool
 	flagTimeout time.Duration
 	flagYes     bool
+
+	flagVersionCheck string
+	flagProgress     string
 )
 
 // rootCmd represents the base command
@@ -25,6 +31,7 @@ var rootCmd = &cobra.Command{
 	Long: `cola-regctl is a command-line client for managing Command Launcher remote registries.
 
 It provides full CRUD operations for registries, packages, and versions via the REST API.`,
+	Version: buildinfo.Version,
 }
 
 // Execute executes the root command
@@ -40,6 +47,11 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&flagVerbose, "verbose", false, "Enable verbose logging")
 	rootCmd.PersistentFlags().DurationVar(&flagTimeout, "timeout", 30*time.Second, "HTTP request timeout")
 	rootCmd.PersistentFlags().BoolVarP(&flagYes, "yes", "y", false, "Skip confirmation prompts")
+	rootCmd.PersistentFlags().StringVar(&flagVersionCheck, "version-check", "", "When older than the server's minimum client version: refuse|warn|off (or use COLA_REGISTRY_VERSION_CHECK env var, default: refuse)")
+	rootCmd.PersistentFlags().StringVar(&flagProgress, "progress", output.ProgressNone, "Progress of mirror, sync and version create: none|json (line-delimited JSON events on stderr)")
+
+	rootCmd.SetVersionTemplate(`{{.Version}}
+`)
 
 	// Add subcommands
 	// These will be implemented in subsequent tasks
@@ -57,8 +69,11 @@ func getGlobalFlags() (url, token string, jsonOutput, verbose bool, timeout time
 	return flagURL, flagToken, flagJSON, flagVerbose, flagTimeout, flagYes
 }
 
-// printVersion prints version information (placeholder for now)
-func printVersion() {
-	fmt.Println("cola-regctl version 0.1.0")
-	os.Exit(0)
+// newProgress returns the reporter of --progress for operation
+func newProgress(operation string) *output.Progress {
+	p, err := output.NewProgress(flagProgress, operation, os.Stderr)
+	if err != nil {
+		errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+	}
+	return p
 }
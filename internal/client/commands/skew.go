@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/client/config"
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// checkedServers records the servers already checked by checkClientVersion,
+// so commands talking to a server several times only check it once
+var checkedServers = make(map[string]bool)
+
+// checkClientVersion compares this client's version with the versions the
+// server advertises at /api/v1/server-info. Older than the recommended
+// version prints a warning; older than the minimum exits unless the version
+// check mode is warn. Servers that cannot be queried, including those
+// predating server-info, are not checked.
+func checkClientVersion(c *client.Client) {
+	if checkedServers[c.BaseURL] {
+		return
+	}
+	checkedServers[c.BaseURL] = true
+
+	mode, err := config.ResolveVersionCheck(flagVersionCheck)
+	if err != nil {
+		errors.ExitWithCode(errors.ExitInvalidArguments, err.Error())
+	}
+	if mode == config.VersionCheckOff {
+		return
+	}
+
+	info, err := fetchServerInfo(c)
+	if err != nil {
+		if flagVerbose {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Client version check skipped: %v\n", err)
+		}
+		return
+	}
+
+	message, refuse := clientVersionSkew(buildinfo.Version, info, mode)
+	if refuse {
+		errors.ExitWithCode(errors.ExitClientOutdated, message)
+	}
+	if message != "" {
+		output.PrintWarning(message)
+	}
+}
+
+// fetchServerInfo reads the server's version and expected client versions
+func fetchServerInfo(c *client.Client) (*models.ServerInfo, error) {
+	resp, err := c.Get("/api/v1/server-info")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server-info returned status %d", resp.StatusCode)
+	}
+	var info models.ServerInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("invalid server-info response: %w", err)
+	}
+	return &info, nil
+}
+
+// clientVersionSkew returns the message to print for a client version, if
+// any, and whether the client must refuse to run
+func clientVersionSkew(clientVersion string, info *models.ServerInfo, mode string) (string, bool) {
+	switch info.CheckClient(clientVersion) {
+	case models.ClientUnsupported:
+		message := fmt.Sprintf("cola-regctl %s is older than %s, the minimum version supported by server %s; please upgrade",
+			clientVersion, info.MinClientVersion, info.Version)
+		return message, mode == config.VersionCheckRefuse
+	case models.ClientOutdated:
+		return fmt.Sprintf("cola-regctl %s is older than %s, the version recommended by server %s; please upgrade",
+			clientVersion, info.RecommendedClientVersion, info.Version), false
+	}
+	return "", false
+}
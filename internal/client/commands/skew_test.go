@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/client/config"
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestClientVersionSkew(t *testing.T) {
+	info := &models.ServerInfo{Version: "2.0.0", MinClientVersion: "1.2.0", RecommendedClientVersion: "1.4.0"}
+
+	message, refuse := clientVersionSkew("1.4.0", info, config.VersionCheckRefuse)
+	assert.Empty(t, message)
+	assert.False(t, refuse)
+
+	message, refuse = clientVersionSkew("1.3.0", info, config.VersionCheckRefuse)
+	assert.Contains(t, message, "recommended")
+	assert.False(t, refuse)
+
+	message, refuse = clientVersionSkew("1.0.0", info, config.VersionCheckRefuse)
+	assert.Contains(t, message, "minimum version supported by server 2.0.0")
+	assert.True(t, refuse)
+
+	// warn mode only prints the message
+	message, refuse = clientVersionSkew("1.0.0", info, config.VersionCheckWarn)
+	assert.NotEmpty(t, message)
+	assert.False(t, refuse)
+}
+
+func TestFetchServerInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/server-info" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(models.ServerInfo{Version: "2.0.0", MinClientVersion: "1.2.0"})
+	}))
+	defer srv.Close()
+
+	info, err := fetchServerInfo(client.NewClient(srv.URL, "", time.Second, false))
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", info.Version)
+	assert.Equal(t, "1.2.0", info.MinClientVersion)
+
+	// Servers predating server-info are reported as errors, and not checked
+	_, err = fetchServerInfo(client.NewClient(srv.URL+"/old", "", time.Second, false))
+	assert.Error(t, err)
+}
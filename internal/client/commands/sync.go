@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/spf13/cobra"
+)
+
+// syncSnapshot is the local replica written by `cola-regctl sync`
+type syncSnapshot struct {
+	Generation uint64          `json:"generation"`
+	Storage    *models.Storage `json:"storage"`
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <snapshot-file>",
+	Short: "Synchronize a local snapshot of the registry",
+	Long: `Synchronize a local snapshot of all registries using the differential sync API.
+
+The first run downloads everything; later runs only fetch the records changed
+since the generation stored in the snapshot. If the server's storage was reset
+behind the snapshot, a full resync is performed automatically.`,
+	Example: `  # Create or refresh a local snapshot
+  cola-regctl sync registry-snapshot.json`,
+	Args: cobra.ExactArgs(1),
+	Run:  runSync,
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(cmd *cobra.Command, args []string) {
+	path := args[0]
+
+	snapshot := &syncSnapshot{Storage: models.NewStorage()}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, snapshot); err != nil {
+			errors.ExitWithError(err, "failed to parse snapshot file")
+		}
+		if snapshot.Storage == nil {
+			snapshot.Storage = models.NewStorage()
+		}
+		models.Normalize(snapshot.Storage)
+	} else if !os.IsNotExist(err) {
+		errors.ExitWithError(err, "failed to read snapshot file")
+	}
+
+	c := getAuthenticatedClient()
+	progress := newProgress("sync")
+	progress.Start(0)
+
+	since := snapshot.Generation
+	applied, generation, gone := fetchAndApplyChanges(c, snapshot.Storage, since, progress)
+	fullResync := false
+	if gone {
+		// Server generation is behind ours: start over from an empty snapshot
+		fullResync = true
+		since = 0
+		snapshot.Storage = models.NewStorage()
+		progress.Step("full_resync")
+		applied, generation, _ = fetchAndApplyChanges(c, snapshot.Storage, since, progress)
+	}
+	snapshot.Generation = generation
+
+	progress.Step("write_snapshot")
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		errors.ExitWithError(err, "failed to encode snapshot")
+	}
+	tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		errors.ExitWithError(err, "failed to write snapshot file")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		errors.ExitWithError(err, "failed to write snapshot file")
+	}
+	progress.End(applied, applied, nil)
+
+	if flagJSON {
+		output.OutputJSON(map[string]interface{}{
+			"since":       since,
+			"generation":  generation,
+			"changes":     applied,
+			"full_resync": fullResync,
+		}, nil)
+		return
+	}
+
+	if fullResync {
+		fmt.Println("Server storage is behind the snapshot; performed a full resync")
+	}
+	output.PrintSuccess(fmt.Sprintf("Applied %d change(s) (generation %d -> %d)", applied, since, generation))
+}
+
+// syncProgressEvery is the number of changes applied between progress events
+const syncProgressEvery = 100
+
+// fetchAndApplyChanges streams the changes after since into store.
+// Returns gone=true, without applying anything, when the server answers 410.
+func fetchAndApplyChanges(c *client.Client, store *models.Storage, since uint64, progress *output.Progress) (applied int, generation uint64, gone bool) {
+	resp, err := c.Get(fmt.Sprintf("/api/v1/sync?since=%d", since))
+	if err != nil {
+		errors.ExitWithError(err, "failed to fetch changes")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return 0, 0, true
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to fetch changes: %s", string(body)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	ended := false
+	for scanner.Scan() {
+		var change models.SyncChange
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			errors.ExitWithError(err, "failed to parse sync stream")
+		}
+		if change.Type == models.SyncTypeEnd {
+			generation = change.Generation
+			ended = true
+			break
+		}
+		if err := store.ApplyChange(&change); err != nil {
+			errors.ExitWithError(err, "failed to apply change")
+		}
+		applied++
+		if applied%syncProgressEvery == 0 {
+			progress.Count(applied, 0)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errors.ExitWithError(err, "failed to read sync stream")
+	}
+	// Without the end record the stream was cut short; keep the old snapshot
+	if !ended {
+		errors.ExitWithCode(errors.ExitGeneralError, "sync stream ended unexpectedly")
+	}
+
+	return applied, generation, false
+}
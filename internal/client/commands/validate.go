@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/criteo/command-launcher-registry/internal/client/errors"
+	"github.com/criteo/command-launcher-registry/internal/client/output"
+)
+
+var (
+	validateFiles  []string
+	validateSchema string
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate local registry, package or version files",
+	Long: `Validate YAML or JSON files against the JSON Schemas published by the
+server at /api/v1/schemas, before submitting them.
+
+The schema is picked from the file's content unless --schema is given: a
+list is an index, a document with checksum or url is a version, one with
+admins, version_policy, sensitive_keys or packages is a registry, and
+anything else is a package. The server remains authoritative: rules
+spanning several fields, such as the registry's version policy, are only
+checked on submission.`,
+	Example: `  # Validate a package file
+  cola-regctl validate -f pkg.yaml
+
+  # Validate several version files
+  cola-regctl validate -f v1.json -f v2.json --schema version`,
+	Args: cobra.NoArgs,
+	Run:  runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().StringArrayVarP(&validateFiles, "file", "f", []string{}, "YAML or JSON file to validate (repeatable, required)")
+	validateCmd.Flags().StringVar(&validateSchema, "schema", "", "Schema to validate against (registry|package|version|index-entry|index, default: inferred)")
+	validateCmd.MarkFlagRequired("file")
+}
+
+// validationResult is the outcome of validating one file
+type validationResult struct {
+	File   string `json:"file"`
+	Schema string `json:"schema"`
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+}
+
+func runValidate(cmd *cobra.Command, args []string) {
+	c := getAuthenticatedClient()
+
+	// Fetch each schema once
+	compiled := make(map[string]*jsonschema.Schema)
+	results := make([]validationResult, 0, len(validateFiles))
+	for _, file := range validateFiles {
+		doc, err := readManifest(file)
+		if err != nil {
+			errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("%s: %v", file, err))
+		}
+
+		name := validateSchema
+		if name == "" {
+			name = inferSchema(doc)
+		}
+		schema, ok := compiled[name]
+		if !ok {
+			schema, err = fetchSchema(c.Get, c.BaseURL, name)
+			if err != nil {
+				errors.ExitWithError(err, fmt.Sprintf("failed to load schema '%s'", name))
+			}
+			compiled[name] = schema
+		}
+
+		result := validationResult{File: file, Schema: name, Valid: true}
+		if err := schema.Validate(doc); err != nil {
+			result.Valid = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	invalid := 0
+	for _, result := range results {
+		if !result.Valid {
+			invalid++
+		}
+	}
+
+	if flagJSON {
+		output.OutputJSON(results, nil)
+	} else {
+		for _, result := range results {
+			if result.Valid {
+				output.PrintSuccess(fmt.Sprintf("%s is a valid %s", result.File, result.Schema))
+			} else {
+				output.PrintError(fmt.Sprintf("%s is not a valid %s:\n%s", result.File, result.Schema, result.Error))
+			}
+		}
+	}
+
+	if invalid > 0 {
+		errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("%d of %d file(s) failed validation", invalid, len(results)))
+	}
+}
+
+// readManifest reads a YAML or JSON file into JSON values the validator
+// understands (YAML is a superset of JSON, so both go through the YAML parser)
+func readManifest(file string) (interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid YAML or JSON: %w", err)
+	}
+
+	// Round-trip through JSON so numbers, timestamps and maps have JSON types
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(normalized))
+}
+
+// inferSchema guesses which schema a document should be validated against
+func inferSchema(doc interface{}) string {
+	fields, ok := doc.(map[string]interface{})
+	if !ok {
+		return "index"
+	}
+	for _, key := range []string{"checksum", "url"} {
+		if _, ok := fields[key]; ok {
+			return "version"
+		}
+	}
+	for _, key := range []string{"admins", "version_policy", "sensitive_keys", "packages"} {
+		if _, ok := fields[key]; ok {
+			return "registry"
+		}
+	}
+	return "package"
+}
+
+// fetchSchema downloads and compiles a published schema. baseURL only names
+// the schema in validation errors.
+func fetchSchema(get func(path string) (*http.Response, error), baseURL, name string) (*jsonschema.Schema, error) {
+	path := "/api/v1/schemas/" + name
+	resp, err := get(path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to fetch schema '%s': %s", name, string(body)))
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(baseURL+path, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(baseURL + path)
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestInferSchema(t *testing.T) {
+	assert.Equal(t, "index", inferSchema([]interface{}{}))
+	assert.Equal(t, "version", inferSchema(map[string]interface{}{"name": "deploy", "checksum": "sha256:..."}))
+	assert.Equal(t, "registry", inferSchema(map[string]interface{}{"name": "tools", "admins": []interface{}{}}))
+	assert.Equal(t, "package", inferSchema(map[string]interface{}{"name": "deploy", "maintainers": []interface{}{}}))
+}
+
+func TestValidateManifest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		schema, ok := models.Schema(strings.TrimPrefix(r.URL.Path, "/api/v1/schemas/"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(schema)
+	}))
+	defer srv.Close()
+	get := func(path string) (*http.Response, error) { return http.Get(srv.URL + path) }
+
+	schema, err := fetchSchema(get, srv.URL, "version")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "v1.yaml")
+	require.NoError(t, os.WriteFile(valid, []byte(`name: deploy
+version: 1.2.0
+checksum: sha256:`+strings.Repeat("a", 64)+`
+url: https://example.com/deploy.zip
+startPartition: 0
+endPartition: 9
+publish_at: 2030-01-01T00:00:00Z
+`), 0644))
+	doc, err := readManifest(valid)
+	require.NoError(t, err)
+	assert.Equal(t, "version", inferSchema(doc))
+	assert.NoError(t, schema.Validate(doc))
+
+	invalid := filepath.Join(dir, "v2.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"name": "deploy", "version": "1.2.0", "checksum": "md5:x", "url": "https://example.com/a.zip", "endPartition": 12}`), 0644))
+	doc, err = readManifest(invalid)
+	require.NoError(t, err)
+	err = schema.Validate(doc)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/checksum")
+	assert.Contains(t, err.Error(), "/endPartition")
+
+	broken := filepath.Join(dir, "broken.yaml")
+	require.NoError(t, os.WriteFile(broken, []byte("name: [unclosed"), 0644))
+	_, err = readManifest(broken)
+	assert.Error(t, err)
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/criteo/command-launcher-registry/internal/checksum"
 	"github.com/criteo/command-launcher-registry/internal/client/errors"
 	"github.com/criteo/command-launcher-registry/internal/client/output"
 	"github.com/criteo/command-launcher-registry/internal/client/prompts"
@@ -16,11 +19,15 @@ import (
 var (
 	// Version command flags
 	versionChecksum     string
+	versionFile         string
+	versionAlgorithm    string
 	versionURL          string
 	versionStartPart    int
 	versionEndPart      int
 	versionStartPartSet bool
 	versionEndPartSet   bool
+	versionSort         string
+	versionPublishAt    string
 )
 
 var versionCmd = &cobra.Command{
@@ -50,6 +57,24 @@ var versionGetCmd = &cobra.Command{
 	Run:   runVersionGet,
 }
 
+var versionCompareCmd = &cobra.Command{
+	Use:   "compare <version-a> <version-b>",
+	Short: "Compare two versions using the server's ordering rules",
+	Long: `Compare two versions using the server's version ordering rules.
+
+Prints whether version-a is older than, equal to, or newer than version-b.
+Both strict semantic versions and legacy version strings are accepted.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runVersionCompare,
+}
+
+var versionCancelCmd = &cobra.Command{
+	Use:   "cancel <registry> <package> <version>",
+	Short: "Cancel a scheduled version before it is published",
+	Args:  cobra.ExactArgs(3),
+	Run:   runVersionCancel,
+}
+
 var versionDeleteCmd = &cobra.Command{
 	Use:   "delete <registry> <package> <version>",
 	Short: "Delete a version",
@@ -63,39 +88,29 @@ func init() {
 	versionCmd.AddCommand(versionListCmd)
 	versionCmd.AddCommand(versionGetCmd)
 	versionCmd.AddCommand(versionDeleteCmd)
+	versionCmd.AddCommand(versionCancelCmd)
+	versionCmd.AddCommand(versionCompareCmd)
 
 	// Create flags
-	versionCreateCmd.Flags().StringVar(&versionChecksum, "checksum", "", "Checksum in format 'sha256:hash' (required)")
+	versionCreateCmd.Flags().StringVar(&versionChecksum, "checksum", "", "Checksum in format '<algorithm>:<hex digest>' (sha256, sha512 or blake3)")
+	versionCreateCmd.Flags().StringVar(&versionFile, "file", "", "Compute the checksum from this archive instead of --checksum")
+	versionCreateCmd.Flags().StringVar(&versionAlgorithm, "algorithm", checksum.SHA256, "Checksum algorithm used with --file ("+strings.Join(checksum.Algorithms, "|")+")")
 	versionCreateCmd.Flags().StringVar(&versionURL, "url", "", "Download URL (required)")
 	versionCreateCmd.Flags().IntVar(&versionStartPart, "start-partition", 0, "Start partition (0-9)")
 	versionCreateCmd.Flags().IntVar(&versionEndPart, "end-partition", 9, "End partition (0-9)")
+	versionCreateCmd.Flags().StringVar(&versionPublishAt, "publish-at", "", "Publish time (RFC 3339); the version stays out of index.json until then")
+
+	// List flags
+	versionListCmd.Flags().StringVar(&versionSort, "sort", "", "Sort order (semver_asc|semver_desc)")
 
 	// Mark required flags
-	versionCreateCmd.MarkFlagRequired("checksum")
 	versionCreateCmd.MarkFlagRequired("url")
+	versionCreateCmd.MarkFlagsOneRequired("checksum", "file")
+	versionCreateCmd.MarkFlagsMutuallyExclusive("checksum", "file")
 
 	rootCmd.AddCommand(versionCmd)
 }
 
-func validateChecksum(checksum string) error {
-	if !strings.HasPrefix(checksum, "sha256:") {
-		return fmt.Errorf("checksum must start with 'sha256:'")
-	}
-
-	hash := strings.TrimPrefix(checksum, "sha256:")
-	if len(hash) != 64 {
-		return fmt.Errorf("sha256 hash must be exactly 64 hexadecimal characters")
-	}
-
-	for _, c := range hash {
-		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
-			return fmt.Errorf("sha256 hash must contain only hexadecimal characters")
-		}
-	}
-
-	return nil
-}
-
 func validatePartitionRange(start, end int) error {
 	if start < 0 || start > 9 {
 		return fmt.Errorf("start partition must be between 0 and 9")
@@ -114,9 +129,19 @@ func runVersionCreate(cmd *cobra.Command, args []string) {
 	packageName := args[1]
 	versionName := args[2]
 	c := getAuthenticatedClient()
-
-	// Validate checksum format
-	if err := validateChecksum(versionChecksum); err != nil {
+	progress := newProgress("publish")
+	progress.Start(0)
+
+	// Compute the checksum of the archive, or validate the one given
+	if versionFile != "" {
+		progress.Step("checksum")
+		sum, err := checksum.File(versionFile, versionAlgorithm)
+		if err != nil {
+			errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("cannot compute checksum: %s", err.Error()))
+		}
+		versionChecksum = sum
+	}
+	if _, _, err := checksum.Parse(versionChecksum); err != nil {
 		errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("invalid checksum: %s", err.Error()))
 	}
 
@@ -134,17 +159,28 @@ func runVersionCreate(cmd *cobra.Command, args []string) {
 		"startPartition": versionStartPart,
 		"endPartition":   versionEndPart,
 	}
+	if versionPublishAt != "" {
+		publishAt, err := time.Parse(time.RFC3339, versionPublishAt)
+		if err != nil {
+			errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("invalid --publish-at. Must be an RFC 3339 time (e.g. 2026-01-02T15:04:05Z), got: '%s'", versionPublishAt))
+		}
+		reqBody["publish_at"] = publishAt.UTC().Format(time.RFC3339)
+	}
 
+	progress.Step("create")
 	resp, err := c.Post(fmt.Sprintf("/api/v1/registry/%s/package/%s/version", registryName, packageName), reqBody)
 	if err != nil {
+		progress.End(0, 0, err)
 		errors.ExitWithError(err, "failed to create version")
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
+		progress.End(0, 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body)))
 		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to create version: %s", string(body)))
 	}
+	progress.End(1, 1, nil)
 
 	if flagJSON {
 		output.OutputJSON(map[string]string{
@@ -152,6 +188,8 @@ func runVersionCreate(cmd *cobra.Command, args []string) {
 			"package":  packageName,
 			"version":  versionName,
 		}, nil)
+	} else if versionPublishAt != "" {
+		output.PrintSuccess(fmt.Sprintf("Created version '%s' for package '%s' in registry '%s', scheduled for %s", versionName, packageName, registryName, versionPublishAt))
 	} else {
 		output.PrintSuccess(fmt.Sprintf("Created version '%s' for package '%s' in registry '%s'", versionName, packageName, registryName))
 	}
@@ -162,7 +200,16 @@ func runVersionList(cmd *cobra.Command, args []string) {
 	packageName := args[1]
 	c := getAuthenticatedClient()
 
-	resp, err := c.Get(fmt.Sprintf("/api/v1/registry/%s/package/%s/version", registryName, packageName))
+	if versionSort != "" && versionSort != "semver_asc" && versionSort != "semver_desc" {
+		errors.ExitWithCode(errors.ExitInvalidArguments, fmt.Sprintf("invalid --sort. Must be 'semver_asc' or 'semver_desc', got: '%s'", versionSort))
+	}
+
+	path := fmt.Sprintf("/api/v1/registry/%s/package/%s/version", registryName, packageName)
+	if versionSort != "" {
+		path += "?sort=" + versionSort
+	}
+
+	resp, err := c.Get(path)
 	if err != nil {
 		errors.ExitWithError(err, "failed to list versions")
 	}
@@ -259,6 +306,33 @@ func runVersionGet(cmd *cobra.Command, args []string) {
 			endPart = int(ep)
 		}
 		fmt.Printf("Partition Range: %d-%d\n", startPart, endPart)
+		if publishAt, ok := version["publish_at"].(string); ok {
+			fmt.Printf("Publish At: %s\n", publishAt)
+		}
+	}
+}
+
+func runVersionCancel(cmd *cobra.Command, args []string) {
+	registryName := args[0]
+	packageName := args[1]
+	versionName := args[2]
+	c := getAuthenticatedClient()
+
+	resp, err := c.Delete(fmt.Sprintf("/api/v1/registry/%s/package/%s/version/%s/schedule", registryName, packageName, versionName))
+	if err != nil {
+		errors.ExitWithError(err, "failed to cancel version")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to cancel version: %s", string(body)))
+	}
+
+	if flagJSON {
+		output.OutputJSON(map[string]bool{"cancelled": true}, nil)
+	} else {
+		output.PrintSuccess(fmt.Sprintf("Cancelled scheduled version '%s' of package '%s' in registry '%s'", versionName, packageName, registryName))
 	}
 }
 
@@ -293,3 +367,48 @@ func runVersionDelete(cmd *cobra.Command, args []string) {
 		output.PrintSuccess(fmt.Sprintf("Deleted version '%s' from package '%s' in registry '%s'", versionName, packageName, registryName))
 	}
 }
+
+func runVersionCompare(cmd *cobra.Command, args []string) {
+	a := args[0]
+	b := args[1]
+	c := getAuthenticatedClient()
+
+	query := url.Values{}
+	query.Set("a", a)
+	query.Set("b", b)
+
+	resp, err := c.Get("/api/v1/version/compare?" + query.Encode())
+	if err != nil {
+		errors.ExitWithError(err, "failed to compare versions")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to compare versions: %s", string(body)))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to read response")
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+
+	if flagJSON {
+		output.OutputJSON(result, nil)
+		return
+	}
+
+	switch r, _ := result["result"].(float64); {
+	case r < 0:
+		fmt.Printf("%s < %s\n", a, b)
+	case r > 0:
+		fmt.Printf("%s > %s\n", a, b)
+	default:
+		fmt.Printf("%s == %s\n", a, b)
+	}
+}
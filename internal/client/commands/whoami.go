@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/criteo/command-launcher-registry/internal/client"
 	"github.com/criteo/command-launcher-registry/internal/client/auth"
@@ -15,6 +16,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var whoamiPackages bool
+
 var whoamiCmd = &cobra.Command{
 	Use:   "whoami",
 	Short: "Show authentication status and server information",
@@ -22,7 +25,9 @@ var whoamiCmd = &cobra.Command{
 
 Resolves server URL and credentials using normal precedence:
 - URL: --url flag > COLA_REGISTRY_URL env var > stored URL
-- Token: --token flag > COLA_REGISTRY_SESSION_TOKEN env var > stored token`,
+- Token: --token flag > COLA_REGISTRY_SESSION_TOKEN env var > stored token
+
+Use --packages to also list the packages you maintain or administer.`,
 	Args: cobra.NoArgs,
 	Run:  runWhoami,
 }
@@ -71,15 +76,28 @@ func runWhoami(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	// Fetch the packages the user is responsible for
+	var packages []map[string]interface{}
+	if authenticated && whoamiPackages {
+		packages = fetchMyPackages(c)
+	}
+
 	if flagJSON {
-		output.OutputJSON(map[string]interface{}{
+		result := map[string]interface{}{
 			"server":        serverURL,
 			"authenticated": authenticated,
 			"username":      username,
-		}, nil)
+		}
+		if authenticated && whoamiPackages {
+			result["packages"] = packages
+		}
+		output.OutputJSON(result, nil)
 	} else {
 		if authenticated {
 			output.PrintSuccess(fmt.Sprintf("Authenticated to %s as %s", serverURL, username))
+			if whoamiPackages {
+				printMyPackages(packages)
+			}
 		} else if resp.StatusCode == http.StatusUnauthorized {
 			output.PrintError(fmt.Sprintf("Not authenticated to %s", serverURL))
 			fmt.Println("Run 'cola-regctl login' to authenticate")
@@ -93,6 +111,57 @@ func runWhoami(cmd *cobra.Command, args []string) {
 	}
 }
 
+// fetchMyPackages lists the packages the authenticated user maintains or administers
+func fetchMyPackages(c *client.Client) []map[string]interface{} {
+	resp, err := c.Get("/api/v1/me/packages")
+	if err != nil {
+		errors.ExitWithError(err, "failed to list packages")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errors.ExitWithError(err, "failed to read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		errors.HandleHTTPError(resp.StatusCode, fmt.Sprintf("failed to list packages: %s", string(body)))
+	}
+
+	var packages []map[string]interface{}
+	if err := json.Unmarshal(body, &packages); err != nil {
+		errors.ExitWithError(err, "failed to parse response")
+	}
+	return packages
+}
+
+func printMyPackages(packages []map[string]interface{}) {
+	if len(packages) == 0 {
+		fmt.Println("You are not a maintainer or admin of any package")
+		return
+	}
+
+	fmt.Println()
+	table := output.NewTableWriter()
+	table.WriteHeader("REGISTRY", "PACKAGE", "ROLES", "VERSIONS")
+	for _, pkg := range packages {
+		var roles []string
+		if list, ok := pkg["roles"].([]interface{}); ok {
+			for _, role := range list {
+				roles = append(roles, fmt.Sprintf("%v", role))
+			}
+		}
+		table.WriteRow(
+			fmt.Sprintf("%v", pkg["registry"]),
+			fmt.Sprintf("%v", pkg["package"]),
+			strings.Join(roles, ","),
+			fmt.Sprintf("%v", pkg["version_count"]),
+		)
+	}
+	table.Flush()
+}
+
 func init() {
 	rootCmd.AddCommand(whoamiCmd)
+
+	whoamiCmd.Flags().BoolVar(&whoamiPackages, "packages", false, "Also list the packages you maintain or administer")
 }
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	// VersionCheckEnvVar is the environment variable for the client version check mode
+	VersionCheckEnvVar = "COLA_REGISTRY_VERSION_CHECK"
+)
+
+// Client version check modes, applied when the client is older than the
+// minimum version the server advertises
+const (
+	VersionCheckRefuse = "refuse" // Exit with an error (default)
+	VersionCheckWarn   = "warn"   // Print a warning and carry on
+	VersionCheckOff    = "off"    // Do not query the server at all
+)
+
+// ResolveVersionCheck resolves the version check mode using precedence:
+// 1. flagMode (--version-check flag)
+// 2. Environment variable (COLA_REGISTRY_VERSION_CHECK)
+// 3. refuse
+func ResolveVersionCheck(flagMode string) (string, error) {
+	mode := flagMode
+	if mode == "" {
+		mode = os.Getenv(VersionCheckEnvVar)
+	}
+	switch mode {
+	case "":
+		return VersionCheckRefuse, nil
+	case VersionCheckRefuse, VersionCheckWarn, VersionCheckOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid version check mode '%s': must be refuse, warn or off", mode)
+}
@@ -0,0 +1,186 @@
+// Package download fetches package archives referenced by registry versions
+// and verifies them against their registered checksum.
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
+	"github.com/criteo/command-launcher-registry/internal/checksum"
+)
+
+// userAgent is sent to archive hosts
+var userAgent = buildinfo.UserAgent("cola-regctl")
+
+// MinParallelSize is the smallest archive split into ranged requests;
+// smaller ones are fetched with a single request
+const MinParallelSize = 8 << 20
+
+// ErrChecksumMismatch is returned when a downloaded archive does not match
+// the expected checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Downloader fetches archives over HTTP. Registry credentials are never sent:
+// archives are hosted outside the registry.
+type Downloader struct {
+	HTTPClient *http.Client
+	Parallel   int // Ranged requests per archive; 1 disables splitting
+}
+
+// New creates a downloader. timeout bounds connecting and waiting for
+// response headers, not the transfer itself, so large archives are not cut off.
+func New(timeout time.Duration, parallel int) *Downloader {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	if parallel < 1 {
+		parallel = 1
+	}
+	return &Downloader{
+		HTTPClient: &http.Client{Transport: transport},
+		Parallel:   parallel,
+	}
+}
+
+// Result describes a downloaded archive
+type Result struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"` // "<algorithm>:<hex>" of the downloaded file
+	Parts    int    `json:"parts"`    // Number of ranged requests used (1 when not split)
+}
+
+// Fetch downloads url to dest and computes its checksum, with the algorithm
+// of expected or sha256. When expected is not empty, a mismatching file is
+// removed and ErrChecksumMismatch returned.
+// The file is written to a temporary name and renamed once complete, so an
+// interrupted download never leaves a partial file at dest.
+func (d *Downloader) Fetch(ctx context.Context, url, dest, expected string) (*Result, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.part")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	size, parts, err := d.fetchInto(ctx, url, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := checksum.SHA256
+	if expected != "" {
+		algorithm = checksum.Algorithm(expected)
+	}
+	sum, err := checksum.File(tmpPath, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if expected != "" && !strings.EqualFold(sum, expected) {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expected, sum)
+	}
+
+	if err := os.Rename(tmpPath, dest); err != nil {
+		return nil, err
+	}
+	return &Result{Path: dest, Size: size, Checksum: sum, Parts: parts}, nil
+}
+
+// fetchInto writes the archive to f, using ranged requests when the server
+// supports them and the archive is large enough
+func (d *Downloader) fetchInto(ctx context.Context, url string, f *os.File) (int64, int, error) {
+	if d.Parallel > 1 {
+		if size, ok := d.rangeSupport(ctx, url); ok && size >= MinParallelSize {
+			return size, d.Parallel, d.fetchRanges(ctx, url, f, size)
+		}
+	}
+
+	resp, err := d.get(ctx, url, "")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	n, err := io.Copy(f, resp.Body)
+	return n, 1, err
+}
+
+// rangeSupport reports the archive size and whether byte ranges are accepted
+func (d *Downloader) rangeSupport(ctx context.Context, url string) (int64, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, false
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength <= 0 {
+		return 0, false
+	}
+	return resp.ContentLength, true
+}
+
+// fetchRanges downloads size bytes in d.Parallel concurrent ranged requests
+func (d *Downloader) fetchRanges(ctx context.Context, url string, f *os.File, size int64) error {
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	chunk := (size + int64(d.Parallel) - 1) / int64(d.Parallel)
+	for start := int64(0); start < size; start += chunk {
+		start, end := start, min(start+chunk, size)-1
+		g.Go(func() error {
+			resp, err := d.get(ctx, url, "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusPartialContent {
+				return fmt.Errorf("GET %s (range %d-%d): %s", url, start, end, resp.Status)
+			}
+			n, err := io.Copy(io.NewOffsetWriter(f, start), resp.Body)
+			if err != nil {
+				return err
+			}
+			if n != end-start+1 {
+				return fmt.Errorf("GET %s (range %d-%d): short read of %d bytes", url, start, end, n)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+func (d *Downloader) get(ctx context.Context, url, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	return d.HTTPClient.Do(req)
+}
@@ -0,0 +1,182 @@
+package download
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/checksum"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newArchiveServer serves data, counting ranged GET requests
+func newArchiveServer(t *testing.T, data []byte) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var ranged atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+			ranged.Add(1)
+		}
+		http.ServeContent(w, r, "archive.zip", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &ranged
+}
+
+func TestFetch_SingleRequest(t *testing.T) {
+	data := []byte("small archive")
+	srv, ranged := newArchiveServer(t, data)
+	dest := filepath.Join(t.TempDir(), "archive.zip")
+
+	result, err := New(5*time.Second, 4).Fetch(context.Background(), srv.URL+"/archive.zip", dest, checksumOf(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(len(data)), result.Size)
+	assert.Equal(t, checksumOf(data), result.Checksum)
+	assert.Equal(t, 1, result.Parts)
+	assert.Equal(t, int32(0), ranged.Load())
+
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestFetch_RangedRequests(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), MinParallelSize/16+3)
+	srv, ranged := newArchiveServer(t, data)
+	dest := filepath.Join(t.TempDir(), "archive.zip")
+
+	result, err := New(5*time.Second, 4).Fetch(context.Background(), srv.URL+"/archive.zip", dest, checksumOf(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, result.Parts)
+	assert.Equal(t, int32(4), ranged.Load())
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, got))
+}
+
+func TestFetch_ChecksumMismatch(t *testing.T) {
+	srv, _ := newArchiveServer(t, []byte("tampered"))
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "archive.zip")
+
+	_, err := New(5*time.Second, 1).Fetch(context.Background(), srv.URL, dest, checksumOf([]byte("original")))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+
+	// Neither the archive nor the temporary file is left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestFetch_ChecksumAlgorithm(t *testing.T) {
+	data := []byte("archive")
+	srv, _ := newArchiveServer(t, data)
+	expected, err := checksum.Compute(bytes.NewReader(data), checksum.BLAKE3)
+	require.NoError(t, err)
+
+	// The archive is hashed with the algorithm of the expected checksum
+	result, err := New(5*time.Second, 1).Fetch(context.Background(), srv.URL, filepath.Join(t.TempDir(), "archive.zip"), expected)
+	require.NoError(t, err)
+	assert.Equal(t, expected, result.Checksum)
+}
+
+func TestFetch_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := New(5*time.Second, 1).Fetch(context.Background(), srv.URL, filepath.Join(t.TempDir(), "a.zip"), "")
+	assert.Error(t, err)
+}
+
+func writeArchive(t *testing.T, name string, build func(f *os.File)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	build(f)
+	require.NoError(t, f.Close())
+	return path
+}
+
+func TestExtract_Zip(t *testing.T) {
+	archive := writeArchive(t, "pkg.zip", func(f *os.File) {
+		zw := zip.NewWriter(f)
+		w, _ := zw.Create("manifest.mf")
+		w.Write([]byte("pkgName: deploy"))
+		w, _ = zw.Create("bin/deploy")
+		w.Write([]byte("#!/bin/sh"))
+		require.NoError(t, zw.Close())
+	})
+	dir := t.TempDir()
+
+	n, err := Extract(archive, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	got, err := os.ReadFile(filepath.Join(dir, "bin", "deploy"))
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh", string(got))
+}
+
+func TestExtract_TarGz(t *testing.T) {
+	archive := writeArchive(t, "pkg.tar.gz", func(f *os.File) {
+		gz := gzip.NewWriter(f)
+		tw := tar.NewWriter(gz)
+		body := "pkgName: deploy"
+		tw.WriteHeader(&tar.Header{Name: "manifest.mf", Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(body))
+		require.NoError(t, tw.Close())
+		require.NoError(t, gz.Close())
+	})
+	dir := t.TempDir()
+
+	n, err := Extract(archive, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = os.Stat(filepath.Join(dir, "manifest.mf"))
+	assert.NoError(t, err)
+}
+
+func TestExtract_RejectsEscapingEntries(t *testing.T) {
+	archive := writeArchive(t, "evil.zip", func(f *os.File) {
+		zw := zip.NewWriter(f)
+		w, _ := zw.Create("../escaped")
+		w.Write([]byte("x"))
+		require.NoError(t, zw.Close())
+	})
+
+	_, err := Extract(archive, t.TempDir())
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "escapes"))
+}
+
+func TestExtract_UnsupportedFormat(t *testing.T) {
+	archive := writeArchive(t, "plain.txt", func(f *os.File) {
+		f.Write([]byte("not an archive"))
+	})
+
+	_, err := Extract(archive, t.TempDir())
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package download
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Magic numbers of the supported archive formats
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// Extract unpacks a zip or tar.gz archive into dir, detecting the format
+// from the file contents. Entries escaping dir are rejected.
+func Extract(archive, dir string) (int, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	n, _ := io.ReadFull(f, header)
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return extractZip(f, info.Size(), dir)
+	case bytes.HasPrefix(header, gzipMagic):
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return extractTarGz(f, dir)
+	default:
+		return 0, fmt.Errorf("unsupported archive format (expected zip or tar.gz)")
+	}
+}
+
+func extractZip(r io.ReaderAt, size int64, dir string) (int, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return 0, err
+	}
+	files := 0
+	for _, entry := range zr.File {
+		target, err := entryPath(dir, entry.Name)
+		if err != nil {
+			return files, err
+		}
+		if entry.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, err
+			}
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return files, err
+		}
+		err = writeFile(target, rc, entry.Mode())
+		rc.Close()
+		if err != nil {
+			return files, err
+		}
+		files++
+	}
+	return files, nil
+}
+
+func extractTarGz(r io.Reader, dir string) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	files := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		target, err := entryPath(dir, hdr.Name)
+		if err != nil {
+			return files, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, hdr.FileInfo().Mode()); err != nil {
+				return files, err
+			}
+			files++
+		default:
+			// Links and special files are not needed to run a package
+		}
+	}
+}
+
+// entryPath resolves an archive entry name inside dir
+func entryPath(dir, name string) (string, error) {
+	target := filepath.Join(dir, name)
+	if target != filepath.Clean(dir) && !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %q escapes the destination directory", name)
+	}
+	return target, nil
+}
+
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -15,6 +15,9 @@ const (
 	ExitConflict         = 4 // Conflict (409) - e.g., resource already exists
 	ExitAuthError        = 5 // Authentication error (401)
 	ExitPermissionDenied = 6 // Permission denied (403)
+	ExitVerifyFailed     = 7 // Signature or checksum verification failed
+	ExitInconsistent     = 8 // Servers or storage diverge (check-consistency)
+	ExitClientOutdated   = 9 // Client older than the minimum version the server supports
 )
 
 // ExitWithError prints error message and exits with appropriate code
@@ -0,0 +1,109 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress modes accepted by --progress
+const (
+	ProgressNone = "none"
+	ProgressJSON = "json"
+)
+
+// Progress event kinds
+const (
+	EventStart = "start" // The operation began; total is set when known
+	EventStep  = "step"  // The operation entered a phase (e.g. "checksum")
+	EventItem  = "item"  // An item (e.g. an archive) was processed
+	EventCount = "count" // Items processed so far, when they are too many to report each
+	EventEnd   = "end"   // The operation finished; status is succeeded or failed
+)
+
+// ProgressEvent is a line of --progress=json output
+type ProgressEvent struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"` // mirror | sync | publish
+	Event     string    `json:"event"`
+	Step      string    `json:"step,omitempty"`
+	Item      string    `json:"item,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Current   int       `json:"current,omitempty"`
+	Total     int       `json:"total,omitempty"` // 0 when unknown
+	Error     string    `json:"error,omitempty"`
+}
+
+// Progress reports the progress of a long-running operation as
+// line-delimited JSON events, so wrapper tooling can render it without
+// parsing human-readable output. It is written to stderr, leaving stdout
+// to the command's result. A Progress in mode none reports nothing; it is
+// safe for concurrent use.
+type Progress struct {
+	mu        sync.Mutex
+	enc       *json.Encoder // nil when reporting nothing
+	operation string
+}
+
+// NewProgress creates a progress reporter for operation writing to w
+func NewProgress(mode, operation string, w io.Writer) (*Progress, error) {
+	p := &Progress{operation: operation}
+	switch mode {
+	case "", ProgressNone:
+	case ProgressJSON:
+		p.enc = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("invalid progress mode '%s' (must be %s or %s)", mode, ProgressNone, ProgressJSON)
+	}
+	return p, nil
+}
+
+// Start reports the start of the operation over total items (0 if unknown)
+func (p *Progress) Start(total int) {
+	p.emit(ProgressEvent{Event: EventStart, Total: total})
+}
+
+// Step reports that the operation entered a phase
+func (p *Progress) Step(step string) {
+	p.emit(ProgressEvent{Event: EventStep, Step: step})
+}
+
+// Item reports the outcome of an item, current being the items processed so far
+func (p *Progress) Item(item, status string, current, total int, err error) {
+	p.emit(ProgressEvent{Event: EventItem, Item: item, Status: status, Current: current, Total: total, Error: errorString(err)})
+}
+
+// Count reports the number of items processed so far
+func (p *Progress) Count(current, total int) {
+	p.emit(ProgressEvent{Event: EventCount, Current: current, Total: total})
+}
+
+// End reports the end of the operation, failed when err is not nil
+func (p *Progress) End(current, total int, err error) {
+	status := "succeeded"
+	if err != nil {
+		status = "failed"
+	}
+	p.emit(ProgressEvent{Event: EventEnd, Status: status, Current: current, Total: total, Error: errorString(err)})
+}
+
+func (p *Progress) emit(event ProgressEvent) {
+	if p.enc == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	event.Operation = p.operation
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Progress is best effort: a closed stderr must not fail the operation
+	p.enc.Encode(event)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,54 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewProgress(ProgressJSON, "mirror", &buf)
+	require.NoError(t, err)
+
+	p.Start(2)
+	p.Item("deploy@1.0.0", "downloaded", 1, 2, nil)
+	p.Item("deploy@2.0.0", "failed", 2, 2, errors.New("checksum mismatch"))
+	p.Step("write_index")
+	p.End(2, 2, errors.New("1 archive could not be mirrored"))
+
+	var events []ProgressEvent
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event ProgressEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), "each line is a JSON event")
+		assert.Equal(t, "mirror", event.Operation)
+		assert.False(t, event.Time.IsZero())
+		events = append(events, event)
+	}
+	require.Len(t, events, 5)
+	assert.Equal(t, EventStart, events[0].Event)
+	assert.Equal(t, 2, events[0].Total)
+	assert.Equal(t, "deploy@2.0.0", events[2].Item)
+	assert.Equal(t, "checksum mismatch", events[2].Error)
+	assert.Equal(t, "write_index", events[3].Step)
+	assert.Equal(t, EventEnd, events[4].Event)
+	assert.Equal(t, "failed", events[4].Status)
+}
+
+func TestProgress_None(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewProgress(ProgressNone, "sync", &buf)
+	require.NoError(t, err)
+	p.Start(0)
+	p.End(0, 0, nil)
+	assert.Empty(t, buf.String())
+
+	_, err = NewProgress("bar", "sync", &buf)
+	assert.Error(t, err)
+}
@@ -26,3 +26,19 @@ func ConfirmDeletion(resourceType, resourceName, cascadeMessage string) bool {
 	response = strings.ToLower(strings.TrimSpace(response))
 	return response == "y" || response == "yes"
 }
+
+// ConfirmAction prompts user to confirm an operation described by message
+// Returns true if user confirms, false otherwise
+func ConfirmAction(message string) bool {
+	fmt.Printf("⚠ %s\n", message)
+	fmt.Print("Are you sure? [y/N]: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes"
+}
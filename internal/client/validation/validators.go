@@ -3,12 +3,15 @@ package validation
 import (
 	"fmt"
 	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/checksum"
 )
 
-// ValidateChecksum validates checksum format (must start with "sha256:")
-func ValidateChecksum(checksum string) error {
-	if !strings.HasPrefix(checksum, "sha256:") {
-		return fmt.Errorf("invalid checksum format. Expected 'sha256:hash', got: '%s'", checksum)
+// ValidateChecksum validates checksum format (sha256:, sha512: or blake3:
+// followed by the hex digest)
+func ValidateChecksum(sum string) error {
+	if _, _, err := checksum.Parse(sum); err != nil {
+		return fmt.Errorf("invalid checksum '%s': %w", sum, err)
 	}
 	return nil
 }
@@ -27,6 +30,14 @@ func ValidatePartitionRange(start, end int) error {
 	return nil
 }
 
+// ValidateVersionPolicy validates a registry version policy (semver or legacy)
+func ValidateVersionPolicy(policy string) error {
+	if policy != "semver" && policy != "legacy" {
+		return fmt.Errorf("invalid --version-policy. Must be 'semver' or 'legacy', got: '%s'", policy)
+	}
+	return nil
+}
+
 // ValidateCustomValue validates custom value format (key=value)
 func ValidateCustomValue(customValue string) (key, value string, err error) {
 	parts := strings.SplitN(customValue, "=", 2)
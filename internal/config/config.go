@@ -2,31 +2,133 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 
+	"github.com/criteo/command-launcher-registry/internal/models"
 	"github.com/criteo/command-launcher-registry/internal/storage"
 )
 
 // Config holds all configuration for the server
 type Config struct {
-	Server  ServerConfig  `mapstructure:"server"`
-	Storage StorageConfig `mapstructure:"storage"`
-	Auth    AuthConfig    `mapstructure:"auth"`
-	Logging LoggingConfig `mapstructure:"logging"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Logging    LoggingConfig    `mapstructure:"logging"`
+	Cache      CacheConfig      `mapstructure:"cache"`
+	Signing    SigningConfig    `mapstructure:"signing"`
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+	Notify     NotifyConfig     `mapstructure:"notify"`
+	Scheduler  SchedulerConfig  `mapstructure:"scheduler"`
+	Policy     PolicyConfig     `mapstructure:"policy"`
+	Clients    ClientsConfig    `mapstructure:"clients"`
+	Crypto     CryptoConfig     `mapstructure:"crypto"`
+	TLS        TLSConfig        `mapstructure:"tls"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	Backup     BackupConfig     `mapstructure:"backup"`
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Host string `mapstructure:"host"`
+	Port                    int           `mapstructure:"port"`
+	Host                    string        `mapstructure:"host"`
+	RateLimit               int           `mapstructure:"rate_limit"`                 // Requests per minute per user or client IP; 0 disables
+	RateLimitBurst          int           `mapstructure:"rate_limit_burst"`           // Requests allowed at once; 0 uses rate_limit
+	MaxPendingWrites        int           `mapstructure:"max_pending_writes"`         // Write requests in flight before 503; 0 is unbounded
+	CORSOrigins             []string      `mapstructure:"cors_origins"`               // Origins allowed to fetch index.json; "*" allows all
+	RequestTimeout          time.Duration `mapstructure:"request_timeout"`            // Per-request deadline; 0 disables
+	DrainDuration           time.Duration `mapstructure:"drain_duration"`             // Serving time with /readyz failing before shutdown
+	ShutdownTimeout         time.Duration `mapstructure:"shutdown_timeout"`           // Time in-flight requests get to complete at shutdown
+	ValidateRequests        bool          `mapstructure:"validate_requests"`          // Reject requests not matching the OpenAPI spec
+	BasePath                string        `mapstructure:"base_path"`                  // Prefix the whole API is served under (e.g. /cola); empty serves it at the root
+	ExternalURL             string        `mapstructure:"external_url"`               // Public URL of the API root, including any prefix, for absolute links
+	VanityHosts             []string      `mapstructure:"vanity_hosts"`               // host=registry entries serving the registry's index at host/index.json
+	AnonymousRead           bool          `mapstructure:"anonymous_read"`             // Allow reads without credentials; registries can override it
+	AnonymousAccess         string        `mapstructure:"anonymous_access"`           // Read routes served without credentials: all (default) or index (index.json only)
+	AnonymousRateLimit      int           `mapstructure:"anonymous_rate_limit"`       // Requests per minute per client IP without credentials; 0 uses rate_limit
+	AnonymousRateLimitBurst int           `mapstructure:"anonymous_rate_limit_burst"` // Requests allowed at once without credentials; 0 uses anonymous_rate_limit
+	TrustedProxies          []string      `mapstructure:"trusted_proxies"`            // IPs or CIDRs of proxies whose X-Forwarded-For is honored for client IPs
+	Listeners               []string      `mapstructure:"listeners"`                  // address=profile entries replacing host:port (e.g. [::]:8080=public)
+	CaptureFailedRequests   int           `mapstructure:"capture_failed_requests"`    // Failed write requests kept for GET /api/v1/admin/captured-requests; 0 disables
+}
+
+// MaxCaptureFailedRequests bounds server.capture_failed_requests, so the
+// captured requests stay small in memory
+const MaxCaptureFailedRequests = 1000
+
+// Anonymous access levels: the read routes served without credentials, when
+// the anonymous read policy allows them
+const (
+	AnonymousAccessAll   = "all"   // Every read route
+	AnonymousAccessIndex = "index" // Registry indexes only; the rest of the API requires credentials
+)
+
+// Listener profiles: the routes and middleware served on a listen address
+const (
+	ListenerAll      = "all"      // Every route, rate limited
+	ListenerPublic   = "public"   // Read routes only, rate limited; writes and admin routes are not served
+	ListenerInternal = "internal" // Every route, not rate limited, for trusted networks
+)
+
+// Listener is an address the server listens on and the profile it serves
+type Listener struct {
+	Address string // host:port; IPv6 hosts in brackets
+	Profile string
 }
 
 // StorageConfig holds storage configuration (URI-based)
 type StorageConfig struct {
-	URI   string `mapstructure:"uri"`   // Storage URI (e.g., file://./data/registry.json)
-	Token string `mapstructure:"token"` // Opaque token for storage authentication
+	URI            string        `mapstructure:"uri"`             // Storage URI (e.g., file://./data/registry.json)
+	Token          string        `mapstructure:"token"`           // Opaque token for storage authentication
+	SecondaryToken string        `mapstructure:"secondary_token"` // Used while the backend rejects Token, to rotate it without downtime
+	Codec          string        `mapstructure:"codec"`           // json | cbor
+	PrettyJSON     bool          `mapstructure:"pretty_json"`     // Indent file:// storage; persisted JSON is compact otherwise
+	Compression    string        `mapstructure:"compression"`     // none | gzip | zstd (S3, GCS and OCI only)
+	LoadTimeout    time.Duration `mapstructure:"load_timeout"`    // Limit on the initial load at startup; 0 waits indefinitely
+
+	// Encryption at rest of persisted data (see storage.Envelope); the key is
+	// a base64-encoded 32-byte AES key, given inline or in a file such as one
+	// written by a KMS or secret manager agent
+	EncryptionKey     string `mapstructure:"encryption_key"`
+	EncryptionKeyFile string `mapstructure:"encryption_key_file"`
+
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`  // Limit on each storage read; 0 disables it
+	WriteTimeout time.Duration `mapstructure:"write_timeout"` // Limit on each storage write, persisting included; 0 disables it
+
+	CacheFile          string `mapstructure:"cache_file"`            // Local copy of S3/GCS/OCI data, served read-only when the backend is down at boot
+	FailFastOnDegraded bool   `mapstructure:"fail_fast_on_degraded"` // Exit when the backend is down at boot instead of serving the cache
+
+	// When S3, GCS, OCI and WebDAV storage persist writes (see storage.PersistMode)
+	PersistMode   string        `mapstructure:"persist_mode"`   // immediate | debounced
+	FlushInterval time.Duration `mapstructure:"flush_interval"` // How long debounced writes wait to be persisted
+
+	// Secondary backend every write is copied to (see storage.MirrorStore)
+	MirrorURI   string `mapstructure:"mirror_uri"`   // Empty disables mirroring
+	MirrorToken string `mapstructure:"mirror_token"` // Storage token of the mirror backend
+
+	// Reloads of data changed out-of-band (file://, S3 and OCI; see storage.Watcher)
+	Watch         bool          `mapstructure:"watch"`          // Watch the backend and reload its changes
+	WatchInterval time.Duration `mapstructure:"watch_interval"` // How often the stored data version is polled
+
+	// Retries of failed S3, GCS, OCI, Git, DynamoDB and WebDAV operations (see storage.RetryPolicy)
+	RetryMaxAttempts int           `mapstructure:"retry_max_attempts"` // Attempts per operation, the first included; 1 disables retries
+	RetryMinBackoff  time.Duration `mapstructure:"retry_min_backoff"`  // Wait before the first retry, doubled on each retry
+	RetryMaxBackoff  time.Duration `mapstructure:"retry_max_backoff"`  // Longest wait between attempts
+	RetryBudget      float64       `mapstructure:"retry_budget"`       // Retries earned per operation once the reserve is spent; 0 disables the budget
+	RetryJitter      float64       `mapstructure:"retry_jitter"`       // Largest fraction of the backoff added at random; 0 disables it
+
+	// Revision tags OCI storage keeps (rev-000123), for rollback; 0 disables them
+	OCIRevisions int `mapstructure:"oci_revisions"`
+
+	// Identities signing the commits of Git storage, as "Name <email>"
+	GitAuthor    string `mapstructure:"git_author"`    // Empty is storage.DefaultGitSignature
+	GitCommitter string `mapstructure:"git_committer"` // Empty is the author
 }
 
 // AuthConfig holds authentication configuration
@@ -41,6 +143,113 @@ type LoggingConfig struct {
 	Format string `mapstructure:"format"` // json | text
 }
 
+// CacheConfig holds HTTP caching configuration per route group.
+// A max age of zero means clients must revalidate (ETag) on every request.
+type CacheConfig struct {
+	RegistryMaxAge time.Duration `mapstructure:"registry_max_age"` // GET registry endpoints
+	PackageMaxAge  time.Duration `mapstructure:"package_max_age"`  // GET package endpoints
+	VersionMaxAge  time.Duration `mapstructure:"version_max_age"`  // GET version endpoints
+	IndexMaxAge    time.Duration `mapstructure:"index_max_age"`    // GET index.json
+}
+
+// SigningConfig holds index.json signing configuration
+type SigningConfig struct {
+	// KeyFiles lists PEM-encoded Ed25519 keys. The first private key signs;
+	// every key (including public-only ones) is published in the JWKS so
+	// signatures stay verifiable across rotations. Empty disables signing.
+	KeyFiles []string `mapstructure:"key_files"`
+}
+
+// EncryptionConfig holds encryption-at-rest configuration
+type EncryptionConfig struct {
+	Key string `mapstructure:"key"` // Base64-encoded 32-byte key for sensitive custom values
+}
+
+// NotifyConfig holds event notification configuration
+type NotifyConfig struct {
+	Email    EmailNotifyConfig `mapstructure:"email"`
+	ChatFile string            `mapstructure:"chat_file"` // Slack/Teams routes (YAML); empty disables chat notifications
+}
+
+// EmailNotifyConfig holds SMTP configuration for maintainer emails
+type EmailNotifyConfig struct {
+	SMTPHost string   `mapstructure:"smtp_host"` // Empty disables email notifications
+	SMTPPort int      `mapstructure:"smtp_port"`
+	Username string   `mapstructure:"username"` // Empty disables SMTP authentication
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	Events   []string `mapstructure:"events"` // Event types to email; empty means all
+}
+
+// SchedulerConfig holds background job configuration.
+// An interval of zero disables the job.
+type SchedulerConfig struct {
+	PublishInterval time.Duration `mapstructure:"publish_interval"` // Release versions whose publish_at has passed
+	VerifyInterval  time.Duration `mapstructure:"verify_interval"`  // Re-download archives and check their checksum
+	VerifySample    int           `mapstructure:"verify_sample"`    // Archives verified per run
+
+	LinkCheckInterval time.Duration `mapstructure:"link_check_interval"` // Check that every version URL is reachable
+	LinkYankAfter     time.Duration `mapstructure:"link_yank_after"`     // Delete versions whose URL stays broken this long; 0 only reports them
+}
+
+// PolicyConfig holds server-wide publishing policies
+type PolicyConfig struct {
+	PackageNames string `mapstructure:"package_names"` // allow | warn | unique (across registries)
+}
+
+// ClientsConfig holds the cola-regctl versions advertised by
+// /api/v1/server-info; clients warn or refuse to run when older
+type ClientsConfig struct {
+	MinVersion         string `mapstructure:"min_version"`         // Older clients refuse to run; empty sets no minimum
+	RecommendedVersion string `mapstructure:"recommended_version"` // Older clients print a warning; empty sets no recommendation
+}
+
+// CryptoConfig holds the restricted-crypto mode, also enabled by FIPS builds
+type CryptoConfig struct {
+	FIPS bool `mapstructure:"fips"` // Only use FIPS-approved algorithms (see package fips)
+}
+
+// TLSConfig holds HTTPS configuration: a certificate from files, or one
+// obtained and renewed through ACME. Neither serves plain HTTP.
+type TLSConfig struct {
+	CertFile string     `mapstructure:"cert_file"` // PEM certificate chain; requires key_file
+	KeyFile  string     `mapstructure:"key_file"`  // PEM private key
+	ACME     ACMEConfig `mapstructure:"acme"`
+}
+
+// ACMEConfig holds automatic certificate management (e.g. Let's Encrypt).
+// Certificates and the account key are kept in the storage backend.
+type ACMEConfig struct {
+	Domains      []string `mapstructure:"domains"`       // Hostnames to obtain certificates for; empty disables ACME
+	Email        string   `mapstructure:"email"`         // Contact for expiry notices; optional
+	DirectoryURL string   `mapstructure:"directory_url"` // ACME directory; Let's Encrypt production by default
+	HTTPPort     int      `mapstructure:"http_port"`     // Port answering HTTP-01 challenges; 0 relies on TLS-ALPN-01 only
+}
+
+// RedisConfig holds the Redis server shared by the instances of a registry:
+// writes are announced on a channel so the others refresh their data, and
+// hot reads are cached
+type RedisConfig struct {
+	URL       string        `mapstructure:"url"`        // redis:// or rediss:// URL; empty disables both
+	KeyPrefix string        `mapstructure:"key_prefix"` // Prefix of keys and channels; instances sharing a backend use the same one
+	CacheTTL  time.Duration `mapstructure:"cache_ttl"`  // Lifetime of cached entries; 0 disables the cache
+}
+
+// BackupConfig holds the scheduled snapshots of the storage data (see
+// storage.Backups)
+type BackupConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // Time between snapshots; 0 disables backups
+	URI      string        `mapstructure:"uri"`      // file:// or S3 URI naming the snapshots; empty is storage.uri (file or S3)
+	Token    string        `mapstructure:"token"`    // Storage token of an S3 backup.uri; empty is storage.token
+	Keep     int           `mapstructure:"keep"`     // Most recent snapshots kept; 0 keeps them all
+	MaxAge   time.Duration `mapstructure:"max_age"`  // Older snapshots are deleted; 0 keeps them all
+}
+
+// Enabled reports whether the server serves HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || len(c.ACME.Domains) > 0
+}
+
 // Load loads configuration from environment variables and defaults
 // CLI flags take precedence and are bound via viper in the CLI layer
 func Load() (*Config, error) {
@@ -49,12 +258,91 @@ func Load() (*Config, error) {
 	// Set defaults
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.host", "0.0.0.0")
+	v.SetDefault("server.rate_limit", 100)
+	v.SetDefault("server.rate_limit_burst", 0)
+	v.SetDefault("server.max_pending_writes", 0)
+	v.SetDefault("server.cors_origins", "*")
+	v.SetDefault("server.request_timeout", "30s")
+	v.SetDefault("server.drain_duration", "0s")
+	v.SetDefault("server.shutdown_timeout", "30s")
+	v.SetDefault("server.validate_requests", true)
+	v.SetDefault("server.base_path", "")
+	v.SetDefault("server.external_url", "")
+	v.SetDefault("server.vanity_hosts", "")
+	v.SetDefault("server.anonymous_read", true)
+	v.SetDefault("server.anonymous_access", AnonymousAccessAll)
+	v.SetDefault("server.anonymous_rate_limit", 0)
+	v.SetDefault("server.anonymous_rate_limit_burst", 0)
+	v.SetDefault("server.trusted_proxies", "")
+	v.SetDefault("server.listeners", "")
+	v.SetDefault("server.capture_failed_requests", 0)
 	v.SetDefault("storage.uri", "file://./data/registry.json")
 	v.SetDefault("storage.token", "")
+	v.SetDefault("storage.secondary_token", "")
+	v.SetDefault("storage.codec", "json")
+	v.SetDefault("storage.pretty_json", false)
+	v.SetDefault("storage.compression", "none")
+	v.SetDefault("storage.encryption_key", "")
+	v.SetDefault("storage.encryption_key_file", "")
+	v.SetDefault("storage.load_timeout", "5m")
+	v.SetDefault("storage.read_timeout", "10s")
+	v.SetDefault("storage.write_timeout", "60s")
+	v.SetDefault("storage.cache_file", "")
+	v.SetDefault("storage.fail_fast_on_degraded", true)
+	v.SetDefault("storage.persist_mode", string(storage.PersistImmediate))
+	v.SetDefault("storage.flush_interval", storage.DefaultFlushInterval.String())
+	v.SetDefault("storage.mirror_uri", "")
+	v.SetDefault("storage.mirror_token", "")
+	v.SetDefault("storage.watch", false)
+	v.SetDefault("storage.watch_interval", storage.DefaultWatchInterval.String())
+	v.SetDefault("storage.retry_max_attempts", storage.DefaultRetryMaxAttempts)
+	v.SetDefault("storage.retry_min_backoff", storage.DefaultRetryMinBackoff.String())
+	v.SetDefault("storage.retry_max_backoff", storage.DefaultRetryMaxBackoff.String())
+	v.SetDefault("storage.retry_budget", storage.DefaultRetryBudget)
+	v.SetDefault("storage.retry_jitter", storage.DefaultRetryJitter)
+	v.SetDefault("storage.oci_revisions", storage.DefaultOCIRevisions)
+	v.SetDefault("storage.git_author", "")
+	v.SetDefault("storage.git_committer", "")
 	v.SetDefault("auth.type", "none")
 	v.SetDefault("auth.users_file", "./users.yaml")
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
+	v.SetDefault("cache.registry_max_age", "30s")
+	v.SetDefault("cache.package_max_age", "30s")
+	v.SetDefault("cache.version_max_age", "60s")
+	v.SetDefault("cache.index_max_age", "0s")
+	v.SetDefault("signing.key_files", "")
+	v.SetDefault("encryption.key", "")
+	v.SetDefault("notify.email.smtp_host", "")
+	v.SetDefault("notify.email.smtp_port", 587)
+	v.SetDefault("notify.email.username", "")
+	v.SetDefault("notify.email.password", "")
+	v.SetDefault("notify.email.from", "")
+	v.SetDefault("notify.email.events", "")
+	v.SetDefault("notify.chat_file", "")
+	v.SetDefault("scheduler.publish_interval", "30s")
+	v.SetDefault("scheduler.verify_interval", "0s")
+	v.SetDefault("scheduler.verify_sample", 20)
+	v.SetDefault("scheduler.link_check_interval", "0s")
+	v.SetDefault("scheduler.link_yank_after", "0s")
+	v.SetDefault("policy.package_names", "allow")
+	v.SetDefault("clients.min_version", "")
+	v.SetDefault("clients.recommended_version", "")
+	v.SetDefault("crypto.fips", false)
+	v.SetDefault("tls.cert_file", "")
+	v.SetDefault("tls.key_file", "")
+	v.SetDefault("tls.acme.domains", "")
+	v.SetDefault("tls.acme.email", "")
+	v.SetDefault("tls.acme.directory_url", "https://acme-v02.api.letsencrypt.org/directory")
+	v.SetDefault("tls.acme.http_port", 80)
+	v.SetDefault("redis.url", "")
+	v.SetDefault("redis.key_prefix", "cola-registry")
+	v.SetDefault("redis.cache_ttl", "5m")
+	v.SetDefault("backup.interval", "0s")
+	v.SetDefault("backup.uri", "")
+	v.SetDefault("backup.token", "")
+	v.SetDefault("backup.keep", 7)
+	v.SetDefault("backup.max_age", "0s")
 
 	// Bind environment variables with COLA_REGISTRY_ prefix
 	v.SetEnvPrefix("COLA_REGISTRY")
@@ -82,19 +370,115 @@ func LoadWithViper(v *viper.Viper) (*Config, error) {
 	return &cfg, nil
 }
 
+// ReadConfigFile reads the YAML config file named by the config_file key
+// (--config flag or COLA_REGISTRY_CONFIG_FILE), if any. Values from the file
+// rank below CLI flags and environment variables. Calling it again re-reads
+// the file, which is how the server reloads its configuration.
+func ReadConfigFile(v *viper.Viper) error {
+	path := v.GetString("config_file")
+	if path == "" {
+		return nil
+	}
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	return nil
+}
+
 // NewViper creates a new viper instance with defaults and environment binding
 func NewViper() *viper.Viper {
 	v := viper.New()
 
 	// Set defaults
+	v.SetDefault("config_file", "")
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.host", "0.0.0.0")
+	v.SetDefault("server.rate_limit", 100)
+	v.SetDefault("server.rate_limit_burst", 0)
+	v.SetDefault("server.max_pending_writes", 0)
+	v.SetDefault("server.cors_origins", "*")
+	v.SetDefault("server.request_timeout", "30s")
+	v.SetDefault("server.drain_duration", "0s")
+	v.SetDefault("server.shutdown_timeout", "30s")
+	v.SetDefault("server.validate_requests", true)
+	v.SetDefault("server.base_path", "")
+	v.SetDefault("server.external_url", "")
+	v.SetDefault("server.vanity_hosts", "")
+	v.SetDefault("server.anonymous_read", true)
+	v.SetDefault("server.anonymous_access", AnonymousAccessAll)
+	v.SetDefault("server.anonymous_rate_limit", 0)
+	v.SetDefault("server.anonymous_rate_limit_burst", 0)
+	v.SetDefault("server.trusted_proxies", "")
+	v.SetDefault("server.listeners", "")
+	v.SetDefault("server.capture_failed_requests", 0)
 	v.SetDefault("storage.uri", "file://./data/registry.json")
 	v.SetDefault("storage.token", "")
+	v.SetDefault("storage.secondary_token", "")
+	v.SetDefault("storage.codec", "json")
+	v.SetDefault("storage.pretty_json", false)
+	v.SetDefault("storage.compression", "none")
+	v.SetDefault("storage.encryption_key", "")
+	v.SetDefault("storage.encryption_key_file", "")
+	v.SetDefault("storage.load_timeout", "5m")
+	v.SetDefault("storage.read_timeout", "10s")
+	v.SetDefault("storage.write_timeout", "60s")
+	v.SetDefault("storage.cache_file", "")
+	v.SetDefault("storage.fail_fast_on_degraded", true)
+	v.SetDefault("storage.persist_mode", string(storage.PersistImmediate))
+	v.SetDefault("storage.flush_interval", storage.DefaultFlushInterval.String())
+	v.SetDefault("storage.mirror_uri", "")
+	v.SetDefault("storage.mirror_token", "")
+	v.SetDefault("storage.watch", false)
+	v.SetDefault("storage.watch_interval", storage.DefaultWatchInterval.String())
+	v.SetDefault("storage.retry_max_attempts", storage.DefaultRetryMaxAttempts)
+	v.SetDefault("storage.retry_min_backoff", storage.DefaultRetryMinBackoff.String())
+	v.SetDefault("storage.retry_max_backoff", storage.DefaultRetryMaxBackoff.String())
+	v.SetDefault("storage.retry_budget", storage.DefaultRetryBudget)
+	v.SetDefault("storage.retry_jitter", storage.DefaultRetryJitter)
+	v.SetDefault("storage.oci_revisions", storage.DefaultOCIRevisions)
+	v.SetDefault("storage.git_author", "")
+	v.SetDefault("storage.git_committer", "")
 	v.SetDefault("auth.type", "none")
 	v.SetDefault("auth.users_file", "./users.yaml")
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
+	v.SetDefault("cache.registry_max_age", "30s")
+	v.SetDefault("cache.package_max_age", "30s")
+	v.SetDefault("cache.version_max_age", "60s")
+	v.SetDefault("cache.index_max_age", "0s")
+	v.SetDefault("signing.key_files", "")
+	v.SetDefault("encryption.key", "")
+	v.SetDefault("notify.email.smtp_host", "")
+	v.SetDefault("notify.email.smtp_port", 587)
+	v.SetDefault("notify.email.username", "")
+	v.SetDefault("notify.email.password", "")
+	v.SetDefault("notify.email.from", "")
+	v.SetDefault("notify.email.events", "")
+	v.SetDefault("notify.chat_file", "")
+	v.SetDefault("scheduler.publish_interval", "30s")
+	v.SetDefault("scheduler.verify_interval", "0s")
+	v.SetDefault("scheduler.verify_sample", 20)
+	v.SetDefault("scheduler.link_check_interval", "0s")
+	v.SetDefault("scheduler.link_yank_after", "0s")
+	v.SetDefault("policy.package_names", "allow")
+	v.SetDefault("clients.min_version", "")
+	v.SetDefault("clients.recommended_version", "")
+	v.SetDefault("crypto.fips", false)
+	v.SetDefault("tls.cert_file", "")
+	v.SetDefault("tls.key_file", "")
+	v.SetDefault("tls.acme.domains", "")
+	v.SetDefault("tls.acme.email", "")
+	v.SetDefault("tls.acme.directory_url", "https://acme-v02.api.letsencrypt.org/directory")
+	v.SetDefault("tls.acme.http_port", 80)
+	v.SetDefault("redis.url", "")
+	v.SetDefault("redis.key_prefix", "cola-registry")
+	v.SetDefault("redis.cache_ttl", "5m")
+	v.SetDefault("backup.interval", "0s")
+	v.SetDefault("backup.uri", "")
+	v.SetDefault("backup.token", "")
+	v.SetDefault("backup.keep", 7)
+	v.SetDefault("backup.max_age", "0s")
 
 	// Bind environment variables with COLA_REGISTRY_ prefix
 	v.SetEnvPrefix("COLA_REGISTRY")
@@ -110,12 +494,129 @@ func (c *Config) Validate() error {
 	if c.Server.Port < 1 || c.Server.Port > 65535 {
 		return fmt.Errorf("server.port must be between 1 and 65535")
 	}
+	if c.Server.RateLimit < 0 {
+		return fmt.Errorf("server.rate_limit must not be negative")
+	}
+	if c.Server.RateLimitBurst < 0 {
+		return fmt.Errorf("server.rate_limit_burst must not be negative")
+	}
+	if c.Server.AnonymousRateLimit < 0 {
+		return fmt.Errorf("server.anonymous_rate_limit must not be negative")
+	}
+	if c.Server.AnonymousRateLimitBurst < 0 {
+		return fmt.Errorf("server.anonymous_rate_limit_burst must not be negative")
+	}
+	switch c.Server.AnonymousAccess {
+	case "", AnonymousAccessAll, AnonymousAccessIndex:
+	default:
+		return fmt.Errorf("server.anonymous_access must be %s or %s", AnonymousAccessAll, AnonymousAccessIndex)
+	}
+	if c.Server.MaxPendingWrites < 0 {
+		return fmt.Errorf("server.max_pending_writes must not be negative")
+	}
+	if c.Server.CaptureFailedRequests < 0 || c.Server.CaptureFailedRequests > MaxCaptureFailedRequests {
+		return fmt.Errorf("server.capture_failed_requests must be between 0 and %d", MaxCaptureFailedRequests)
+	}
+	if c.Server.RequestTimeout < 0 {
+		return fmt.Errorf("server.request_timeout must not be negative")
+	}
+	if c.Server.DrainDuration < 0 {
+		return fmt.Errorf("server.drain_duration must not be negative")
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		return fmt.Errorf("server.shutdown_timeout must not be negative")
+	}
+	if c.Server.BasePath != "" && (!strings.HasPrefix(c.Server.BasePath, "/") || strings.HasSuffix(c.Server.BasePath, "/")) {
+		return fmt.Errorf("server.base_path must start with '/' and not end with '/' (e.g. /cola)")
+	}
+	if c.Server.ExternalURL != "" {
+		u, err := url.Parse(c.Server.ExternalURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.HasSuffix(u.Path, "/") || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("server.external_url must be an http(s) URL without trailing slash (e.g. https://registry.example.com/cola)")
+		}
+	}
+	if _, err := ParseVanityHosts(c.Server.VanityHosts); err != nil {
+		return fmt.Errorf("invalid server.vanity_hosts: %w", err)
+	}
+	if _, err := ParseListeners(c.Server.Listeners); err != nil {
+		return fmt.Errorf("invalid server.listeners: %w", err)
+	}
+	if _, err := ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
 
 	// Validate storage URI
-	_, err := storage.ParseStorageURI(c.Storage.URI)
+	primary, err := storage.ParseStorageURI(c.Storage.URI)
 	if err != nil {
 		return fmt.Errorf("invalid storage URI: %w", err)
 	}
+	if c.Storage.MirrorURI != "" {
+		mirror, err := storage.ParseStorageURI(c.Storage.MirrorURI)
+		if err != nil {
+			return fmt.Errorf("invalid storage.mirror_uri: %w", err)
+		}
+		if mirror.String() == primary.String() {
+			return fmt.Errorf("storage.mirror_uri must differ from storage.uri")
+		}
+	}
+	if c.Storage.LoadTimeout < 0 {
+		return fmt.Errorf("storage.load_timeout must not be negative")
+	}
+	if c.Storage.ReadTimeout < 0 {
+		return fmt.Errorf("storage.read_timeout must not be negative")
+	}
+	if c.Storage.WriteTimeout < 0 {
+		return fmt.Errorf("storage.write_timeout must not be negative")
+	}
+	if c.Storage.WatchInterval < 0 {
+		return fmt.Errorf("storage.watch_interval must not be negative")
+	}
+	if c.Storage.SecondaryToken != "" && c.Storage.Token == "" {
+		return fmt.Errorf("storage.secondary_token requires storage.token")
+	}
+	if uri, err := storage.ParseStorageURI(c.Storage.URI); err == nil && uri.IsS3Scheme() && c.Storage.SecondaryToken != "" {
+		if _, _, err := storage.ParseS3Token(c.Storage.SecondaryToken); err != nil {
+			return fmt.Errorf("invalid storage.secondary_token: %w", err)
+		}
+	}
+	if c.Storage.RetryMaxAttempts < 0 || c.Storage.RetryMinBackoff < 0 || c.Storage.RetryMaxBackoff < 0 || c.Storage.RetryBudget < 0 {
+		return fmt.Errorf("storage.retry_max_attempts, storage.retry_min_backoff, storage.retry_max_backoff and storage.retry_budget must not be negative")
+	}
+	if c.Storage.RetryMaxBackoff > 0 && c.Storage.RetryMinBackoff > c.Storage.RetryMaxBackoff {
+		return fmt.Errorf("storage.retry_min_backoff must not exceed storage.retry_max_backoff")
+	}
+	if c.Storage.RetryJitter < 0 || c.Storage.RetryJitter > 1 {
+		return fmt.Errorf("storage.retry_jitter must be between 0 and 1")
+	}
+	if c.Storage.OCIRevisions < 0 {
+		return fmt.Errorf("storage.oci_revisions must not be negative")
+	}
+	if _, err := storage.ParseGitSignature(c.Storage.GitAuthor); err != nil {
+		return fmt.Errorf("invalid storage.git_author: %w", err)
+	}
+	if _, err := storage.ParseGitSignature(c.Storage.GitCommitter); err != nil {
+		return fmt.Errorf("invalid storage.git_committer: %w", err)
+	}
+	if _, err := storage.ParseCodec(c.Storage.Codec); err != nil {
+		return fmt.Errorf("invalid storage.codec: %w", err)
+	}
+	if _, err := storage.ParseCompression(c.Storage.Compression); err != nil {
+		return fmt.Errorf("invalid storage.compression: %w", err)
+	}
+	if c.Storage.EncryptionKey != "" && c.Storage.EncryptionKeyFile != "" {
+		return fmt.Errorf("storage.encryption_key and storage.encryption_key_file are mutually exclusive")
+	}
+	if c.Storage.EncryptionKey != "" {
+		if _, err := storage.NewEnvelopeFromBase64(c.Storage.EncryptionKey); err != nil {
+			return fmt.Errorf("invalid storage.encryption_key: %w", err)
+		}
+	}
+	if _, err := storage.ParsePersistMode(c.Storage.PersistMode); err != nil {
+		return fmt.Errorf("invalid storage.persist_mode: %w", err)
+	}
+	if c.Storage.FlushInterval < 0 {
+		return fmt.Errorf("storage.flush_interval must not be negative")
+	}
 
 	// Validate auth type
 	if c.Auth.Type != "none" && c.Auth.Type != "basic" {
@@ -133,9 +634,213 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("logging.format must be json or text")
 	}
 
+	// Validate cache max ages
+	if c.Cache.RegistryMaxAge < 0 || c.Cache.PackageMaxAge < 0 || c.Cache.VersionMaxAge < 0 || c.Cache.IndexMaxAge < 0 {
+		return fmt.Errorf("cache max ages must not be negative")
+	}
+
+	// Validate scheduler intervals
+	if c.Scheduler.PublishInterval < 0 {
+		return fmt.Errorf("scheduler.publish_interval must not be negative")
+	}
+	if c.Scheduler.VerifyInterval < 0 {
+		return fmt.Errorf("scheduler.verify_interval must not be negative")
+	}
+	if c.Scheduler.VerifyInterval > 0 && c.Scheduler.VerifySample < 1 {
+		return fmt.Errorf("scheduler.verify_sample must be at least 1")
+	}
+	if c.Scheduler.LinkCheckInterval < 0 || c.Scheduler.LinkYankAfter < 0 {
+		return fmt.Errorf("scheduler.link_check_interval and scheduler.link_yank_after must not be negative")
+	}
+	if c.Scheduler.LinkYankAfter > 0 && c.Scheduler.LinkCheckInterval == 0 {
+		return fmt.Errorf("scheduler.link_yank_after requires scheduler.link_check_interval")
+	}
+
+	// Validate backups
+	if c.Backup.Interval < 0 || c.Backup.MaxAge < 0 {
+		return fmt.Errorf("backup.interval and backup.max_age must not be negative")
+	}
+	if c.Backup.Keep < 0 {
+		return fmt.Errorf("backup.keep must not be negative")
+	}
+	if c.Backup.Interval > 0 {
+		backupURI := c.Backup.URI
+		if backupURI == "" {
+			backupURI = c.Storage.URI
+		}
+		uri, err := storage.ParseStorageURI(backupURI)
+		if err != nil {
+			return fmt.Errorf("invalid backup.uri: %w", err)
+		}
+		if !uri.IsFileScheme() && !uri.IsS3Scheme() {
+			return fmt.Errorf("backup.uri must be a file or S3 URI (storage.uri is used when empty)")
+		}
+	}
+
+	// Validate policies
+	if _, err := storage.ParsePackageNamePolicy(c.Policy.PackageNames); err != nil {
+		return fmt.Errorf("invalid policy.package_names: %w", err)
+	}
+
+	// Validate advertised client versions
+	for _, bound := range []struct{ name, version string }{
+		{"clients.min_version", c.Clients.MinVersion},
+		{"clients.recommended_version", c.Clients.RecommendedVersion},
+	} {
+		if bound.version == "" {
+			continue
+		}
+		if err := models.ValidateVersion(bound.version); err != nil {
+			return fmt.Errorf("%s must be a semantic version (e.g. 1.4.0)", bound.name)
+		}
+	}
+	if c.Clients.MinVersion != "" && c.Clients.RecommendedVersion != "" && models.CompareVersions(c.Clients.MinVersion, c.Clients.RecommendedVersion) > 0 {
+		return fmt.Errorf("clients.min_version must not be newer than clients.recommended_version")
+	}
+
+	// Validate email notifications
+	if c.Notify.Email.SMTPHost != "" {
+		if c.Notify.Email.SMTPPort < 1 || c.Notify.Email.SMTPPort > 65535 {
+			return fmt.Errorf("notify.email.smtp_port must be between 1 and 65535")
+		}
+		if c.Notify.Email.From == "" {
+			return fmt.Errorf("notify.email.from is required when notify.email.smtp_host is set")
+		}
+	}
+
+	// Validate TLS
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+	}
+	if len(c.TLS.ACME.Domains) > 0 {
+		if c.TLS.CertFile != "" {
+			return fmt.Errorf("tls.cert_file and tls.acme.domains are mutually exclusive")
+		}
+		if u, err := url.Parse(c.TLS.ACME.DirectoryURL); err != nil || u.Scheme != "https" {
+			return fmt.Errorf("tls.acme.directory_url must be an https URL")
+		}
+		if c.TLS.ACME.HTTPPort < 0 || c.TLS.ACME.HTTPPort > 65535 {
+			return fmt.Errorf("tls.acme.http_port must be between 0 and 65535")
+		}
+		if c.TLS.ACME.HTTPPort == c.Server.Port {
+			return fmt.Errorf("tls.acme.http_port must differ from server.port")
+		}
+	}
+
+	// Validate Redis cache
+	if c.Redis.URL != "" {
+		if u, err := url.Parse(c.Redis.URL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+			return fmt.Errorf("redis.url must be a redis:// or rediss:// URL")
+		}
+		if c.Redis.KeyPrefix == "" {
+			return fmt.Errorf("redis.key_prefix is required when redis.url is set")
+		}
+		if c.Redis.CacheTTL < 0 {
+			return fmt.Errorf("redis.cache_ttl must not be negative")
+		}
+	}
+
 	return nil
 }
 
+// ParseVanityHosts parses host=registry entries into a map of lowercase
+// hostnames to registry names. Blank entries are ignored; a hostname may
+// only be mapped once.
+func ParseVanityHosts(entries []string) (map[string]string, error) {
+	hosts := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, registry, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		registry = strings.TrimSpace(registry)
+		if !ok || host == "" || strings.ContainsAny(host, "/:") {
+			return nil, fmt.Errorf("'%s' must be host=registry (e.g. tools.example.com=build)", entry)
+		}
+		if err := models.ValidateName(registry); err != nil {
+			return nil, fmt.Errorf("'%s': invalid registry name: %w", entry, err)
+		}
+		if _, dup := hosts[host]; dup {
+			return nil, fmt.Errorf("host '%s' is mapped more than once", host)
+		}
+		hosts[host] = registry
+	}
+	return hosts, nil
+}
+
+// ParseTrustedProxies parses IP addresses and CIDR ranges into prefixes, a
+// single address matching only itself. Blank entries are ignored.
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' must be an IP address or CIDR range (e.g. 10.0.0.0/8)", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ParseListeners parses address=profile entries, the profile defaulting to
+// all. Blank entries are ignored; an address may only be listed once.
+func ParseListeners(entries []string) ([]Listener, error) {
+	var listeners []Listener
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		address, profile, ok := strings.Cut(entry, "=")
+		address = strings.TrimSpace(address)
+		profile = strings.TrimSpace(profile)
+		if !ok {
+			profile = ListenerAll
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' must be host:port=profile (e.g. [::]:8080=public)", entry)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("'%s': port must be between 1 and 65535", entry)
+		}
+		if host != "" && net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("'%s': host must be an IP address", entry)
+		}
+		switch profile {
+		case ListenerAll, ListenerPublic, ListenerInternal:
+		default:
+			return nil, fmt.Errorf("'%s': profile must be %s, %s or %s", entry, ListenerAll, ListenerPublic, ListenerInternal)
+		}
+		if seen[address] {
+			return nil, fmt.Errorf("address '%s' is listed more than once", address)
+		}
+		seen[address] = true
+		listeners = append(listeners, Listener{Address: address, Profile: profile})
+	}
+	return listeners, nil
+}
+
+// ListenAddresses returns the addresses of a validated configuration:
+// server.listeners, or host:port serving every route
+func (c ServerConfig) ListenAddresses() []Listener {
+	if listeners, _ := ParseListeners(c.Listeners); len(listeners) > 0 {
+		return listeners
+	}
+	return []Listener{{Address: net.JoinHostPort(c.Host, strconv.Itoa(c.Port)), Profile: ListenerAll}}
+}
+
 // GetParsedStorageURI returns the parsed storage URI
 func (c *Config) GetParsedStorageURI() (*storage.StorageURI, error) {
 	return storage.ParseStorageURI(c.Storage.URI)
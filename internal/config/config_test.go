@@ -1,9 +1,13 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMaskToken(t *testing.T) {
@@ -110,3 +114,452 @@ func TestValidate_StorageURI(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate_StorageCompression(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, "none", cfg.Storage.Compression)
+
+	for _, name := range []string{"none", "gzip", "zstd"} {
+		cfg.Storage.Compression = name
+		assert.NoError(t, cfg.Validate(), name)
+	}
+
+	cfg.Storage.Compression = "lz4"
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.compression")
+}
+
+func TestValidate_StorageEncryptionKey(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Storage.EncryptionKey)
+
+	cfg.Storage.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 32 bytes
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Storage.EncryptionKeyFile = "/run/secrets/registry-key"
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mutually exclusive")
+
+	cfg.Storage.EncryptionKeyFile = ""
+	cfg.Storage.EncryptionKey = "c2hvcnQ=" // 5 bytes
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.encryption_key")
+}
+
+func TestValidate_StorageCodec(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, "json", cfg.Storage.Codec)
+
+	cfg.Storage.Codec = "cbor"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Storage.Codec = "protobuf"
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.codec")
+}
+
+func TestValidate_StorageMirror(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Empty(t, cfg.Storage.MirrorURI)
+
+	cfg.Storage.MirrorURI = "file://./data/mirror.json"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Storage.MirrorURI = "ftp://backup/registry.json"
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid storage.mirror_uri")
+
+	cfg.Storage.MirrorURI = cfg.Storage.URI
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.mirror_uri must differ from storage.uri")
+}
+
+func TestValidate_StorageWatch(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.False(t, cfg.Storage.Watch)
+	assert.Equal(t, 30*time.Second, cfg.Storage.WatchInterval)
+
+	cfg.Storage.Watch = true
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Storage.WatchInterval = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.watch_interval must not be negative")
+}
+
+func TestValidate_StoragePersistMode(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, "immediate", cfg.Storage.PersistMode)
+	assert.Equal(t, 5*time.Second, cfg.Storage.FlushInterval)
+
+	cfg.Storage.PersistMode = "debounced"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Storage.PersistMode = "eventually"
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid storage.persist_mode")
+
+	cfg.Storage.PersistMode = "debounced"
+	cfg.Storage.FlushInterval = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.flush_interval must not be negative")
+}
+
+func TestValidate_StorageRetryJitter(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, 0.1, cfg.Storage.RetryJitter)
+
+	cfg.Storage.RetryJitter = 0
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Storage.RetryJitter = 1.5
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.retry_jitter must be between 0 and 1")
+}
+
+func TestValidate_StorageOCIRevisions(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, 10, cfg.Storage.OCIRevisions)
+
+	cfg.Storage.OCIRevisions = 0
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Storage.OCIRevisions = -1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.oci_revisions must not be negative")
+}
+
+func TestValidate_Backup(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Zero(t, cfg.Backup.Interval)
+	assert.Equal(t, 7, cfg.Backup.Keep)
+
+	// Next to file storage
+	cfg.Backup.Interval = time.Hour
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Storage.URI = "sqlite://./data/registry.db"
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "backup.uri must be a file or S3 URI")
+
+	cfg.Backup.URI = "file:///backups/registry.json"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Backup.Keep = -1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "backup.keep must not be negative")
+}
+
+func TestValidate_PackageNamePolicy(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, "allow", cfg.Policy.PackageNames)
+
+	cfg.Policy.PackageNames = "unique"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Policy.PackageNames = "strict"
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "policy.package_names")
+}
+
+func TestValidate_AnonymousTier(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, AnonymousAccessAll, cfg.Server.AnonymousAccess)
+	assert.Zero(t, cfg.Server.AnonymousRateLimit)
+
+	cfg.Server.AnonymousAccess = AnonymousAccessIndex
+	cfg.Server.AnonymousRateLimit = 30
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Server.AnonymousAccess = "none"
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server.anonymous_access")
+
+	cfg.Server.AnonymousAccess = AnonymousAccessAll
+	cfg.Server.AnonymousRateLimit = -1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server.anonymous_rate_limit")
+}
+
+func TestValidate_ServerBasePath(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, "", cfg.Server.BasePath)
+
+	for _, path := range []string{"/cola", "/tools/cola"} {
+		cfg.Server.BasePath = path
+		assert.NoError(t, cfg.Validate(), path)
+	}
+
+	for _, path := range []string{"cola", "/cola/", "/"} {
+		cfg.Server.BasePath = path
+		err = cfg.Validate()
+		assert.Error(t, err, path)
+		assert.Contains(t, err.Error(), "server.base_path")
+	}
+}
+
+func TestValidate_ServerExternalURL(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, "", cfg.Server.ExternalURL)
+
+	for _, u := range []string{"https://registry.example.com", "http://gateway:8080/cola"} {
+		cfg.Server.ExternalURL = u
+		assert.NoError(t, cfg.Validate(), u)
+	}
+
+	for _, u := range []string{"registry.example.com", "ftp://registry.example.com", "https://registry.example.com/", "https://registry.example.com/?x=1"} {
+		cfg.Server.ExternalURL = u
+		err = cfg.Validate()
+		assert.Error(t, err, u)
+		assert.Contains(t, err.Error(), "server.external_url")
+	}
+}
+
+func TestParseVanityHosts(t *testing.T) {
+	hosts, err := ParseVanityHosts([]string{"Tools.Example.com=build", " deploy.example.com = deploy ", ""})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"tools.example.com": "build", "deploy.example.com": "deploy"}, hosts)
+
+	for _, entries := range [][]string{
+		{"tools.example.com"},
+		{"=build"},
+		{"tools.example.com:8080=build"},
+		{"tools.example.com=Build!"},
+		{"tools.example.com=build", "TOOLS.example.com=deploy"},
+	} {
+		_, err := ParseVanityHosts(entries)
+		assert.Error(t, err, entries)
+	}
+
+	cfg, err := LoadWithViper(NewViper())
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Server.VanityHosts)
+	cfg.Server.VanityHosts = []string{"tools.example.com"}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server.vanity_hosts")
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.10 ", "", "fd00::/8", "10.1.2.3/16"})
+	require.NoError(t, err)
+	require.Len(t, proxies, 4)
+	assert.Equal(t, "10.0.0.0/8", proxies[0].String())
+	assert.Equal(t, "192.168.1.10/32", proxies[1].String())
+	assert.Equal(t, "fd00::/8", proxies[2].String())
+	assert.Equal(t, "10.1.0.0/16", proxies[3].String())
+
+	for _, entry := range []string{"proxy.example.com", "10.0.0.0/33", "10.0.0"} {
+		_, err := ParseTrustedProxies([]string{entry})
+		assert.Error(t, err, entry)
+	}
+
+	cfg, err := LoadWithViper(NewViper())
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Server.TrustedProxies)
+	cfg.Server.TrustedProxies = []string{"proxy.example.com"}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server.trusted_proxies")
+}
+
+func TestParseListeners(t *testing.T) {
+	listeners, err := ParseListeners([]string{"0.0.0.0:8080=public", " [::]:8080 = public ", "127.0.0.1:9090=internal", ":8081", ""})
+	require.NoError(t, err)
+	assert.Equal(t, []Listener{
+		{Address: "0.0.0.0:8080", Profile: ListenerPublic},
+		{Address: "[::]:8080", Profile: ListenerPublic},
+		{Address: "127.0.0.1:9090", Profile: ListenerInternal},
+		{Address: ":8081", Profile: ListenerAll},
+	}, listeners)
+
+	for _, entries := range [][]string{
+		{"8080=public"},
+		{"0.0.0.0:0=public"},
+		{"localhost:8080=public"},
+		{"0.0.0.0:8080=admin"},
+		{"0.0.0.0:8080=public", "0.0.0.0:8080=internal"},
+	} {
+		_, err := ParseListeners(entries)
+		assert.Error(t, err, entries)
+	}
+
+	cfg, err := LoadWithViper(NewViper())
+	require.NoError(t, err)
+	assert.Equal(t, []Listener{{Address: "0.0.0.0:8080", Profile: ListenerAll}}, cfg.Server.ListenAddresses())
+	cfg.Server.Listeners = []string{"[::1]:9090=internal"}
+	assert.Equal(t, []Listener{{Address: "[::1]:9090", Profile: ListenerInternal}}, cfg.Server.ListenAddresses())
+	cfg.Server.Listeners = []string{"0.0.0.0:8080=admin"}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "server.listeners")
+}
+
+func TestValidate_Clients(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	require.NoError(t, err)
+	assert.Equal(t, ClientsConfig{}, cfg.Clients)
+
+	cfg.Clients = ClientsConfig{MinVersion: "1.2.0", RecommendedVersion: "1.4.0"}
+	assert.NoError(t, cfg.Validate())
+
+	for _, clients := range []ClientsConfig{
+		{MinVersion: "latest"},
+		{RecommendedVersion: "1.4"},
+		{MinVersion: "1.5.0", RecommendedVersion: "1.4.0"},
+	} {
+		cfg.Clients = clients
+		err = cfg.Validate()
+		assert.Error(t, err, clients)
+		assert.Contains(t, err.Error(), "clients.", clients)
+	}
+}
+
+func TestValidate_TLS(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	require.NoError(t, err)
+	assert.False(t, cfg.TLS.Enabled())
+	assert.Equal(t, 80, cfg.TLS.ACME.HTTPPort)
+	defaults := cfg.TLS
+
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = "server.crt", "server.key"
+	assert.True(t, cfg.TLS.Enabled())
+	assert.NoError(t, cfg.Validate())
+
+	cfg.TLS = defaults
+	cfg.TLS.ACME.Domains = []string{"registry.example.com"}
+	assert.True(t, cfg.TLS.Enabled())
+	assert.NoError(t, cfg.Validate())
+
+	for name, mutate := range map[string]func(*TLSConfig){
+		"cert without key":  func(c *TLSConfig) { c.ACME.Domains = nil; c.CertFile = "server.crt" },
+		"cert and acme":     func(c *TLSConfig) { c.CertFile, c.KeyFile = "server.crt", "server.key" },
+		"plain directory":   func(c *TLSConfig) { c.ACME.DirectoryURL = "http://acme.example.com/directory" },
+		"server port reuse": func(c *TLSConfig) { c.ACME.HTTPPort = cfg.Server.Port },
+	} {
+		cfg.TLS = defaults
+		cfg.TLS.ACME.Domains = []string{"registry.example.com"}
+		mutate(&cfg.TLS)
+		err = cfg.Validate()
+		assert.Error(t, err, name)
+		assert.Contains(t, err.Error(), "tls.", name)
+	}
+}
+
+func TestValidate_Redis(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	require.NoError(t, err)
+	assert.Equal(t, "cola-registry", cfg.Redis.KeyPrefix)
+	assert.Equal(t, 5*time.Minute, cfg.Redis.CacheTTL)
+	defaults := cfg.Redis
+
+	cfg.Redis.URL = "rediss://:secret@redis.example.com:6380/1"
+	assert.NoError(t, cfg.Validate())
+
+	for name, mutate := range map[string]func(*RedisConfig){
+		"not a redis URL": func(c *RedisConfig) { c.URL = "http://redis.example.com" },
+		"empty prefix":    func(c *RedisConfig) { c.KeyPrefix = "" },
+		"negative ttl":    func(c *RedisConfig) { c.CacheTTL = -time.Second },
+	} {
+		cfg.Redis = defaults
+		cfg.Redis.URL = "redis://localhost:6379"
+		mutate(&cfg.Redis)
+		err = cfg.Validate()
+		assert.Error(t, err, name)
+		assert.Contains(t, err.Error(), "redis.", name)
+	}
+}
+
+func TestNewViper_CacheDefaults(t *testing.T) {
+	cfg, err := LoadWithViper(NewViper())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.Equal(t, 30*time.Second, cfg.Cache.RegistryMaxAge)
+	assert.Equal(t, 30*time.Second, cfg.Cache.PackageMaxAge)
+	assert.Equal(t, 60*time.Second, cfg.Cache.VersionMaxAge)
+	assert.Equal(t, time.Duration(0), cfg.Cache.IndexMaxAge)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestReadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: debug\nserver:\n  rate_limit: 500\n  cors_origins: [https://a.example.com]\n"), 0o600))
+
+	v := NewViper()
+	v.Set("config_file", path)
+	require.NoError(t, ReadConfigFile(v))
+
+	cfg, err := LoadWithViper(v)
+	require.NoError(t, err)
+	assert.Equal(t, "debug", cfg.Logging.Level)
+	assert.Equal(t, 500, cfg.Server.RateLimit)
+	assert.Equal(t, []string{"https://a.example.com"}, cfg.Server.CORSOrigins)
+
+	// Re-reading picks up edits
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: warn\n"), 0o600))
+	require.NoError(t, ReadConfigFile(v))
+	cfg, err = LoadWithViper(v)
+	require.NoError(t, err)
+	assert.Equal(t, "warn", cfg.Logging.Level)
+	assert.Equal(t, 100, cfg.Server.RateLimit)
+	assert.Equal(t, []string{"*"}, cfg.Server.CORSOrigins)
+}
+
+func TestReadConfigFile_NoFile(t *testing.T) {
+	assert.NoError(t, ReadConfigFile(NewViper()))
+}
@@ -0,0 +1,241 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/criteo/command-launcher-registry/internal/fips"
+	"github.com/criteo/command-launcher-registry/pkg/webhook"
+)
+
+// DefaultChatTemplate renders an event when neither the route nor the
+// platform configures a template
+const DefaultChatTemplate = `{{.Summary}}{{if .Actor}} (by {{.Actor}}){{end}}{{if .URL}} {{.URL}}{{end}}`
+
+// AllRegistries matches every registry in a chat route
+const AllRegistries = "*"
+
+// ChatRouteConfig routes events of one or all registries to a channel webhook
+type ChatRouteConfig struct {
+	Registry   string   `yaml:"registry"`           // Registry name, or "*" for all
+	WebhookURL string   `yaml:"webhook_url"`        // Incoming webhook of the channel
+	Events     []string `yaml:"events,omitempty"`   // Event types to post; empty means all
+	Template   string   `yaml:"template,omitempty"` // Overrides the platform template
+	Secret     string   `yaml:"secret,omitempty"`   // Signs requests for the receiver to verify (see package webhook)
+}
+
+// ChatPlatformConfig configures one chat platform
+type ChatPlatformConfig struct {
+	Template string            `yaml:"template,omitempty"` // Default template for this platform's routes
+	Routes   []ChatRouteConfig `yaml:"routes"`
+}
+
+// ChatConfig represents the structure of the chat notifications file
+type ChatConfig struct {
+	Slack ChatPlatformConfig `yaml:"slack"`
+	Teams ChatPlatformConfig `yaml:"teams"`
+}
+
+// LoadChatConfig reads a chat notifications file. Routes are validated when
+// the sinks are created.
+func LoadChatConfig(path string) (*ChatConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat notifications file: %w", err)
+	}
+
+	var cfg ChatConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse chat notifications file (invalid YAML syntax): %w", err)
+	}
+	return &cfg, nil
+}
+
+// chatRoute is a compiled ChatRouteConfig
+type chatRoute struct {
+	registry   string
+	webhookURL string
+	events     map[string]bool
+	template   *template.Template
+	secret     []byte // nil sends unsigned requests
+}
+
+func (r *chatRoute) matches(e Event) bool {
+	if r.registry != AllRegistries && r.registry != e.Registry {
+		return false
+	}
+	return r.events == nil || r.events[e.Type]
+}
+
+// chatPayloadFunc builds the webhook request body for a rendered message
+type chatPayloadFunc func(e Event, text string) interface{}
+
+// ChatSink posts events to Slack or Microsoft Teams incoming webhooks.
+// An event is posted to every route matching its registry and type.
+type ChatSink struct {
+	name    string
+	routes  []chatRoute
+	payload chatPayloadFunc
+	client  *http.Client
+	logger  *slog.Logger
+}
+
+// NewSlackSink creates a sink posting to Slack incoming webhooks
+func NewSlackSink(cfg ChatPlatformConfig, logger *slog.Logger) (*ChatSink, error) {
+	return newChatSink("slack", cfg, slackPayload, logger)
+}
+
+// NewTeamsSink creates a sink posting to Microsoft Teams incoming webhooks
+func NewTeamsSink(cfg ChatPlatformConfig, logger *slog.Logger) (*ChatSink, error) {
+	return newChatSink("teams", cfg, teamsPayload, logger)
+}
+
+func newChatSink(name string, cfg ChatPlatformConfig, payload chatPayloadFunc, logger *slog.Logger) (*ChatSink, error) {
+	defaultTemplate := cfg.Template
+	if defaultTemplate == "" {
+		defaultTemplate = DefaultChatTemplate
+	}
+
+	routes := make([]chatRoute, 0, len(cfg.Routes))
+	for i, rc := range cfg.Routes {
+		if rc.Registry == "" {
+			return nil, fmt.Errorf("%s route %d: registry is required (use %q for all registries)", name, i, AllRegistries)
+		}
+		if !strings.HasPrefix(rc.WebhookURL, "https://") && !strings.HasPrefix(rc.WebhookURL, "http://") {
+			return nil, fmt.Errorf("%s route %d: webhook_url must be an http(s) URL", name, i)
+		}
+
+		text := rc.Template
+		if text == "" {
+			text = defaultTemplate
+		}
+		tmpl, err := template.New(fmt.Sprintf("%s-%d", name, i)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s route %d: invalid template: %w", name, i, err)
+		}
+
+		route := chatRoute{
+			registry:   rc.Registry,
+			webhookURL: rc.WebhookURL,
+			template:   tmpl,
+		}
+		if rc.Secret != "" {
+			route.secret = []byte(rc.Secret)
+		}
+		if len(rc.Events) > 0 {
+			route.events = make(map[string]bool, len(rc.Events))
+			for _, t := range rc.Events {
+				route.events[t] = true
+			}
+		}
+		routes = append(routes, route)
+	}
+
+	return &ChatSink{
+		name:    name,
+		routes:  routes,
+		payload: payload,
+		client:  &http.Client{Transport: webhookTransport()},
+		logger:  logger,
+	}, nil
+}
+
+// Name identifies the sink in logs
+func (s *ChatSink) Name() string {
+	return s.name
+}
+
+// Send posts the event to every matching route.
+// Delivery continues after a failing route; the first error is returned.
+func (s *ChatSink) Send(ctx context.Context, e Event) error {
+	var firstErr error
+	for i := range s.routes {
+		route := &s.routes[i]
+		if !route.matches(e) {
+			continue
+		}
+		if err := s.post(ctx, route, e); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		s.logger.Debug("Chat notification posted",
+			"sink", s.name,
+			"route", route.registry,
+			"type", e.Type,
+			"registry", e.Registry)
+	}
+	return firstErr
+}
+
+func (s *ChatSink) post(ctx context.Context, route *chatRoute, e Event) error {
+	var text bytes.Buffer
+	if err := route.template.Execute(&text, e); err != nil {
+		return fmt.Errorf("failed to render template for route %s: %w", route.registry, err)
+	}
+
+	body, err := json.Marshal(s.payload(e, text.String()))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, route.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if route.secret != nil {
+		if err := webhook.Sign(req, body, route.secret, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// Webhook URLs embed credentials; never log them
+		return fmt.Errorf("webhook request for route %s failed", route.registry)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook for route %s returned status %d", route.registry, resp.StatusCode)
+	}
+	return nil
+}
+
+// slackEscaper escapes the characters Slack reserves for mrkdwn control sequences
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackPayload(e Event, text string) interface{} {
+	return map[string]string{"text": slackEscaper.Replace(text)}
+}
+
+func teamsPayload(e Event, text string) interface{} {
+	return map[string]string{
+		"@type":    "MessageCard",
+		"@context": "https://schema.org/extensions",
+		"summary":  e.Summary(),
+		"text":     text,
+	}
+}
+
+// webhookTransport returns the transport posting to webhooks, with TLS
+// restricted in FIPS mode
+func webhookTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	fips.RestrictTLS(transport)
+	return transport
+}
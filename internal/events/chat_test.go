@@ -0,0 +1,145 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/pkg/webhook"
+)
+
+// webhookRecorder records the JSON bodies posted to each path
+type webhookRecorder struct {
+	mu    sync.Mutex
+	posts map[string][]map[string]string
+}
+
+func newWebhookServer(t *testing.T) (*httptest.Server, *webhookRecorder) {
+	rec := &webhookRecorder{posts: make(map[string][]map[string]string)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		rec.mu.Lock()
+		rec.posts[r.URL.Path] = append(rec.posts[r.URL.Path], body)
+		rec.mu.Unlock()
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, rec
+}
+
+func TestChatSink_RoutesAndTemplates(t *testing.T) {
+	srv, rec := newWebhookServer(t)
+
+	sink, err := NewSlackSink(ChatPlatformConfig{
+		Template: "{{.Type}} {{.Registry}}/{{.Package}}",
+		Routes: []ChatRouteConfig{
+			{Registry: "*", WebhookURL: srv.URL + "/all"},
+			{Registry: "build", WebhookURL: srv.URL + "/build", Events: []string{VersionPublished}, Template: "<{{.Version}}> by {{.Actor}}"},
+			{Registry: "infra", WebhookURL: srv.URL + "/infra"},
+		},
+	}, testLogger())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, sink.Send(ctx, Event{Type: VersionPublished, Registry: "build", Package: "hotfix", Version: "1.0.0", Actor: "alice"}))
+	require.NoError(t, sink.Send(ctx, Event{Type: VersionDeleted, Registry: "build", Package: "hotfix", Version: "1.0.0"}))
+
+	assert.Equal(t, []map[string]string{
+		{"text": "version.published build/hotfix"},
+		{"text": "version.deleted build/hotfix"},
+	}, rec.posts["/all"])
+	// Route template, event filter and mrkdwn escaping
+	assert.Equal(t, []map[string]string{{"text": "&lt;1.0.0&gt; by alice"}}, rec.posts["/build"])
+	assert.Empty(t, rec.posts["/infra"])
+}
+
+func TestChatSink_SignedRoute(t *testing.T) {
+	verifier := webhook.NewVerifier(0, []byte("s3cret"))
+	var verified []error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verified = append(verified, verifier.Verify(r.Header, body, time.Now()))
+	}))
+	t.Cleanup(srv.Close)
+
+	sink, err := NewSlackSink(ChatPlatformConfig{Routes: []ChatRouteConfig{
+		{Registry: "*", WebhookURL: srv.URL + "/signed", Secret: "s3cret"},
+		{Registry: "*", WebhookURL: srv.URL + "/unsigned"},
+	}}, testLogger())
+	require.NoError(t, err)
+	require.NoError(t, sink.Send(context.Background(), Event{Type: VersionPublished, Registry: "build", Package: "hotfix", Version: "1.0.0"}))
+
+	require.Len(t, verified, 2)
+	assert.NoError(t, verified[0])
+	assert.ErrorIs(t, verified[1], webhook.ErrMissingSignature)
+}
+
+func TestChatSink_TeamsPayloadAndErrors(t *testing.T) {
+	srv, rec := newWebhookServer(t)
+
+	sink, err := NewTeamsSink(ChatPlatformConfig{
+		Routes: []ChatRouteConfig{
+			{Registry: "*", WebhookURL: srv.URL + "/broken"},
+			{Registry: "*", WebhookURL: srv.URL + "/teams"},
+		},
+	}, testLogger())
+	require.NoError(t, err)
+
+	e := Event{Type: PackageDeleted, Registry: "build", Package: "hotfix"}
+	err = sink.Send(context.Background(), e)
+	assert.ErrorContains(t, err, "status 500")
+
+	// A failing route does not prevent delivery to the others
+	require.Len(t, rec.posts["/teams"], 1)
+	post := rec.posts["/teams"][0]
+	assert.Equal(t, "MessageCard", post["@type"])
+	assert.Equal(t, e.Summary(), post["text"])
+}
+
+func TestNewChatSink_InvalidRoutes(t *testing.T) {
+	_, err := NewSlackSink(ChatPlatformConfig{Routes: []ChatRouteConfig{{WebhookURL: "https://hooks.example.com/x"}}}, testLogger())
+	assert.ErrorContains(t, err, "registry is required")
+
+	_, err = NewSlackSink(ChatPlatformConfig{Routes: []ChatRouteConfig{{Registry: "*", WebhookURL: "hooks.example.com"}}}, testLogger())
+	assert.ErrorContains(t, err, "webhook_url")
+
+	_, err = NewSlackSink(ChatPlatformConfig{Routes: []ChatRouteConfig{{Registry: "*", WebhookURL: "https://hooks.example.com/x", Template: "{{.Type"}}}, testLogger())
+	assert.ErrorContains(t, err, "invalid template")
+}
+
+func TestLoadChatConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+slack:
+  template: "{{.Summary}}"
+  routes:
+    - registry: build
+      webhook_url: https://hooks.slack.com/services/T/B/X
+      events: [version.published]
+teams:
+  routes:
+    - registry: "*"
+      webhook_url: https://example.webhook.office.com/webhookb2/x
+`), 0644))
+
+	cfg, err := LoadChatConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{{.Summary}}", cfg.Slack.Template)
+	require.Len(t, cfg.Slack.Routes, 1)
+	assert.Equal(t, []string{VersionPublished}, cfg.Slack.Routes[0].Events)
+	require.Len(t, cfg.Teams.Routes, 1)
+	assert.Equal(t, AllRegistries, cfg.Teams.Routes[0].Registry)
+}
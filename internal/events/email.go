@@ -0,0 +1,135 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailOptions configures the SMTP email sink
+type EmailOptions struct {
+	Host     string
+	Port     int
+	Username string // Empty disables SMTP authentication
+	Password string
+	From     string
+	Events   []string // Event types to email; empty means all
+}
+
+// sendMailFunc matches smtp.SendMail, replaced in tests
+type sendMailFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+// EmailSink emails the maintainers of the affected package.
+// Maintainers that are not email addresses are skipped.
+type EmailSink struct {
+	addr     string
+	from     string
+	auth     smtp.Auth
+	events   map[string]bool
+	logger   *slog.Logger
+	sendMail sendMailFunc
+}
+
+// NewEmailSink creates an email sink.
+// smtp.SendMail upgrades to STARTTLS when the server supports it.
+func NewEmailSink(opts EmailOptions, logger *slog.Logger) *EmailSink {
+	s := &EmailSink{
+		addr:     net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port)),
+		from:     opts.From,
+		logger:   logger,
+		sendMail: smtp.SendMail,
+	}
+	if opts.Username != "" {
+		s.auth = smtp.PlainAuth("", opts.Username, opts.Password, opts.Host)
+	}
+	if len(opts.Events) > 0 {
+		s.events = make(map[string]bool, len(opts.Events))
+		for _, t := range opts.Events {
+			s.events[t] = true
+		}
+	}
+	return s
+}
+
+// Name identifies the sink in logs
+func (s *EmailSink) Name() string {
+	return "email"
+}
+
+// Send emails the event to the package maintainers
+func (s *EmailSink) Send(ctx context.Context, e Event) error {
+	if s.events != nil && !s.events[e.Type] {
+		return nil
+	}
+
+	var recipients []string
+	for _, m := range e.Maintainers {
+		if strings.Contains(m, "@") {
+			recipients = append(recipients, m)
+		}
+	}
+	if len(recipients) == 0 {
+		s.logger.Debug("No maintainer email for event",
+			"type", e.Type,
+			"registry", e.Registry,
+			"package", e.Package)
+		return nil
+	}
+
+	if err := s.sendMail(s.addr, s.auth, s.from, recipients, s.message(e, recipients)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	s.logger.Info("Notification email sent",
+		"type", e.Type,
+		"registry", e.Registry,
+		"package", e.Package,
+		"recipients", len(recipients))
+	return nil
+}
+
+// message builds a plain-text RFC 5322 message for an event
+func (s *EmailSink) message(e Event, recipients []string) []byte {
+	var subject string
+	switch e.Type {
+	case VersionPublished:
+		subject = fmt.Sprintf("%s/%s %s published", e.Registry, e.Package, e.Version)
+	case VersionScheduled:
+		subject = fmt.Sprintf("%s/%s %s scheduled", e.Registry, e.Package, e.Version)
+	case VersionCancelled:
+		subject = fmt.Sprintf("%s/%s %s cancelled", e.Registry, e.Package, e.Version)
+	case VersionDeleted:
+		subject = fmt.Sprintf("%s/%s %s deleted", e.Registry, e.Package, e.Version)
+	case PackageDeleted:
+		subject = fmt.Sprintf("%s/%s deleted", e.Registry, e.Package)
+	case VersionChecksumMismatch:
+		subject = fmt.Sprintf("%s/%s %s archive no longer matches its checksum", e.Registry, e.Package, e.Version)
+	default:
+		subject = fmt.Sprintf("%s: %s/%s", e.Type, e.Registry, e.Package)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&b, "Subject: [cola-registry] %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(e.Summary() + "\r\n")
+	if e.URL != "" {
+		fmt.Fprintf(&b, "\r\n%s\r\n", e.URL)
+	}
+	if e.Actor != "" {
+		fmt.Fprintf(&b, "\r\nChanged by: %s\r\n", e.Actor)
+	}
+	fmt.Fprintf(&b, "Time: %s\r\n", e.Time.Format(time.RFC3339))
+	b.WriteString("\r\nYou receive this email because you are listed as a maintainer of this package.\r\n")
+	return b.Bytes()
+}
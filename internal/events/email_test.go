@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMail struct {
+	addr string
+	from string
+	to   []string
+	msg  string
+}
+
+func newTestEmailSink(opts EmailOptions) (*EmailSink, *[]sentMail) {
+	var sent []sentMail
+	sink := NewEmailSink(opts, testLogger())
+	sink.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, sentMail{addr: addr, from: from, to: to, msg: string(msg)})
+		return nil
+	}
+	return sink, &sent
+}
+
+func TestEmailSink_Send(t *testing.T) {
+	sink, sent := newTestEmailSink(EmailOptions{Host: "smtp.example.com", Port: 587, From: "registry@example.com"})
+
+	err := sink.Send(context.Background(), Event{
+		Type:        VersionPublished,
+		Time:        time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Actor:       "alice",
+		Registry:    "build",
+		Package:     "hotfix",
+		Version:     "1.2.0",
+		Maintainers: []string{"dev@example.com", "not-an-email", "ops@example.com"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, *sent, 1)
+	mail := (*sent)[0]
+	assert.Equal(t, "smtp.example.com:587", mail.addr)
+	assert.Equal(t, "registry@example.com", mail.from)
+	assert.Equal(t, []string{"dev@example.com", "ops@example.com"}, mail.to)
+	assert.Contains(t, mail.msg, "Subject: [cola-registry] build/hotfix 1.2.0 published\r\n")
+	assert.Contains(t, mail.msg, "Changed by: alice")
+}
+
+func TestEmailSink_Skips(t *testing.T) {
+	sink, sent := newTestEmailSink(EmailOptions{Host: "localhost", Port: 25, From: "registry@example.com", Events: []string{VersionDeleted}})
+
+	// Event type not selected
+	require.NoError(t, sink.Send(context.Background(), Event{Type: VersionPublished, Maintainers: []string{"dev@example.com"}}))
+	// No maintainer email address
+	require.NoError(t, sink.Send(context.Background(), Event{Type: VersionDeleted, Maintainers: []string{"dev"}}))
+
+	assert.Empty(t, *sent)
+}
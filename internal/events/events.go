@@ -0,0 +1,177 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	VersionPublished = "version.published"
+	VersionScheduled = "version.scheduled"
+	VersionCancelled = "version.cancelled"
+	VersionDeleted   = "version.deleted"
+	PackageDeleted   = "package.deleted"
+
+	// VersionChecksumMismatch reports an archive that no longer matches the
+	// checksum it was published with (see package verify)
+	VersionChecksumMismatch = "version.checksum_mismatch"
+
+	// StorageRestored reports the whole data replaced by a backup, for
+	// audit channels; it names no registry
+	StorageRestored = "storage.restored"
+
+	// StorageRolledBack reports the whole data replaced by a prior version
+	// kept by the storage backend, for audit channels; it names no registry
+	StorageRolledBack = "storage.rolled_back"
+)
+
+// queueSize bounds the number of events waiting for delivery.
+// Events published while the queue is full are dropped and logged.
+const queueSize = 256
+
+// sendTimeout bounds the delivery of one event to one sink
+const sendTimeout = 30 * time.Second
+
+// Event describes a change to the registry
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor,omitempty"` // Authenticated user who made the change
+	Registry string    `json:"registry"`
+	Package  string    `json:"package,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	URL      string    `json:"url,omitempty"`    // API link to the version, or to what remains after a deletion (needs server.external_url)
+	Backup   string    `json:"backup,omitempty"` // Snapshot restored by a storage.restored event
+
+	// Storage version restored by a storage.rolled_back event
+	StorageVersion string `json:"storage_version,omitempty"`
+
+	// Maintainers of the package when the event occurred, so sinks can
+	// notify them even after the package is deleted
+	Maintainers []string `json:"maintainers,omitempty"`
+}
+
+// Summary returns a one-sentence description of the event
+func (e Event) Summary() string {
+	switch e.Type {
+	case VersionPublished:
+		return fmt.Sprintf("Version %s of package %s was published to registry %s.", e.Version, e.Package, e.Registry)
+	case VersionScheduled:
+		return fmt.Sprintf("Version %s of package %s was scheduled for publication to registry %s.", e.Version, e.Package, e.Registry)
+	case VersionCancelled:
+		return fmt.Sprintf("Scheduled version %s of package %s was cancelled in registry %s.", e.Version, e.Package, e.Registry)
+	case VersionDeleted:
+		return fmt.Sprintf("Version %s of package %s was deleted from registry %s.", e.Version, e.Package, e.Registry)
+	case PackageDeleted:
+		return fmt.Sprintf("Package %s and all its versions were deleted from registry %s.", e.Package, e.Registry)
+	case VersionChecksumMismatch:
+		return fmt.Sprintf("The archive of version %s of package %s in registry %s no longer matches its checksum.", e.Version, e.Package, e.Registry)
+	case StorageRestored:
+		return fmt.Sprintf("The registry data was restored from backup %s.", e.Backup)
+	case StorageRolledBack:
+		return fmt.Sprintf("The registry data was rolled back to storage version %s.", e.StorageVersion)
+	default:
+		return fmt.Sprintf("Event %s affected package %s in registry %s.", e.Type, e.Package, e.Registry)
+	}
+}
+
+// Sink delivers events to an external system
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+	// Send delivers one event. Errors are logged by the dispatcher.
+	Send(ctx context.Context, e Event) error
+}
+
+// Dispatcher delivers events to sinks asynchronously, so slow or failing
+// sinks never delay API responses
+type Dispatcher struct {
+	mu        sync.RWMutex
+	sinks     []Sink
+	queue     chan Event
+	logger    *slog.Logger
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDispatcher creates a dispatcher and starts its delivery worker
+func NewDispatcher(logger *slog.Logger, sinks ...Sink) *Dispatcher {
+	d := &Dispatcher{
+		sinks:  sinks,
+		queue:  make(chan Event, queueSize),
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// SetSinks replaces the sinks (used by config reload).
+// Queued events are delivered to the new sinks.
+func (d *Dispatcher) SetSinks(sinks ...Sink) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sinks = sinks
+}
+
+func (d *Dispatcher) currentSinks() []Sink {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.sinks
+}
+
+// Publish queues an event for delivery. It never blocks.
+// Publishing on a nil dispatcher, or one without sinks, is a no-op.
+func (d *Dispatcher) Publish(e Event) {
+	if d == nil || len(d.currentSinks()) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	select {
+	case d.queue <- e:
+	default:
+		d.logger.Warn("Event queue full, dropping event",
+			"type", e.Type,
+			"registry", e.Registry,
+			"package", e.Package,
+			"version", e.Version)
+	}
+}
+
+// Close stops accepting events and waits for queued events to be delivered
+func (d *Dispatcher) Close() {
+	if d == nil {
+		return
+	}
+	d.closeOnce.Do(func() {
+		close(d.queue)
+		<-d.done
+	})
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for e := range d.queue {
+		for _, sink := range d.currentSinks() {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			err := sink.Send(ctx, e)
+			cancel()
+			if err != nil {
+				d.logger.Error("Event delivery failed",
+					"sink", sink.Name(),
+					"type", e.Type,
+					"registry", e.Registry,
+					"package", e.Package,
+					"version", e.Version,
+					"error", err)
+			}
+		}
+	}
+}
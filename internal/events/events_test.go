@@ -0,0 +1,170 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink collects delivered events
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Send(ctx context.Context, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func TestStore_PublishesEvents(t *testing.T) {
+	logger := testLogger()
+	fs, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	sink := &recordingSink{}
+	store := NewStore(fs, NewDispatcher(logger, sink), "")
+	ctx := auth.WithUser(context.Background(), &auth.User{Username: "alice"})
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", []string{"dev@example.com"}, nil)))
+	require.NoError(t, store.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip", 0, 9)))
+	require.NoError(t, store.DeleteVersion(ctx, "reg", "pkg", "1.0.0"))
+	require.NoError(t, store.DeletePackage(ctx, "reg", "pkg"))
+
+	// Failed writes publish nothing
+	assert.Error(t, store.DeletePackage(ctx, "reg", "pkg"))
+
+	// Close drains the queue
+	require.NoError(t, store.Close())
+
+	require.Len(t, sink.events, 3)
+	assert.Equal(t, VersionPublished, sink.events[0].Type)
+	assert.Equal(t, "1.0.0", sink.events[0].Version)
+	assert.Equal(t, VersionDeleted, sink.events[1].Type)
+	assert.Equal(t, PackageDeleted, sink.events[2].Type)
+	for _, e := range sink.events {
+		assert.Equal(t, "alice", e.Actor)
+		assert.Equal(t, []string{"dev@example.com"}, e.Maintainers)
+		assert.False(t, e.Time.IsZero())
+		assert.Empty(t, e.URL)
+	}
+}
+
+func TestStore_EventLinks(t *testing.T) {
+	logger := testLogger()
+	fs, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	sink := &recordingSink{}
+	store := NewStore(fs, NewDispatcher(logger, sink), "https://registry.example.com/cola")
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.NoError(t, store.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip", 0, 9)))
+	require.NoError(t, store.DeleteVersion(ctx, "reg", "pkg", "1.0.0"))
+	require.NoError(t, store.DeletePackage(ctx, "reg", "pkg"))
+	require.NoError(t, store.Close())
+
+	require.Len(t, sink.events, 3)
+	assert.Equal(t, "https://registry.example.com/cola/api/v1/registry/reg/package/pkg/version/1.0.0", sink.events[0].URL)
+	assert.Equal(t, "https://registry.example.com/cola/api/v1/registry/reg/package/pkg", sink.events[1].URL)
+	assert.Equal(t, "https://registry.example.com/cola/api/v1/registry/reg", sink.events[2].URL)
+}
+
+func TestStore_ScheduledVersionEvents(t *testing.T) {
+	logger := testLogger()
+	fs, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	sink := &recordingSink{}
+	store := NewStore(fs, NewDispatcher(logger, sink), "")
+	ctx := context.Background()
+
+	publishAt := time.Now().Add(time.Hour)
+	scheduled := func(version string, start, end int) *models.Version {
+		v := models.NewVersion("pkg", version, "sha256:a", "http://x/"+version+".zip", start, end)
+		v.PublishAt = &publishAt
+		return v
+	}
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.NoError(t, store.CreateVersion(ctx, "reg", "pkg", scheduled("1.0.0", 0, 4)))
+	require.NoError(t, store.CreateVersion(ctx, "reg", "pkg", scheduled("2.0.0", 5, 9)))
+	require.NoError(t, store.ReleaseVersion(ctx, "reg", "pkg", "1.0.0"))
+	require.NoError(t, store.CancelVersion(ctx, "reg", "pkg", "2.0.0"))
+	require.NoError(t, store.Close())
+
+	var types []string
+	for _, e := range sink.events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []string{VersionScheduled, VersionScheduled, VersionPublished, VersionCancelled}, types)
+}
+
+func TestStore_NotifyRestored(t *testing.T) {
+	logger := testLogger()
+	fs, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	sink := &recordingSink{}
+	store := NewStore(fs, NewDispatcher(logger, sink), "https://registry.example.com")
+	ctx := auth.WithUser(context.Background(), &auth.User{Username: "alice"})
+
+	store.NotifyRestored(ctx, "registry-2024-05-01T12-00-00Z.json")
+	require.NoError(t, store.Close())
+
+	require.Len(t, sink.events, 1)
+	e := sink.events[0]
+	assert.Equal(t, StorageRestored, e.Type)
+	assert.Equal(t, "alice", e.Actor)
+	assert.Empty(t, e.Registry)
+	assert.Empty(t, e.URL)
+	assert.Equal(t, "The registry data was restored from backup registry-2024-05-01T12-00-00Z.json.", e.Summary())
+}
+
+func TestStore_NotifyRolledBack(t *testing.T) {
+	logger := testLogger()
+	fs, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	sink := &recordingSink{}
+	store := NewStore(fs, NewDispatcher(logger, sink), "https://registry.example.com")
+	ctx := auth.WithUser(context.Background(), &auth.User{Username: "alice"})
+
+	store.NotifyRolledBack(ctx, "3HL4kqtJlcpXroDTDmJ")
+	require.NoError(t, store.Close())
+
+	require.Len(t, sink.events, 1)
+	e := sink.events[0]
+	assert.Equal(t, StorageRolledBack, e.Type)
+	assert.Equal(t, "alice", e.Actor)
+	assert.Empty(t, e.Registry)
+	assert.Equal(t, "The registry data was rolled back to storage version 3HL4kqtJlcpXroDTDmJ.", e.Summary())
+}
+
+func TestDispatcher_NilIsNoop(t *testing.T) {
+	var d *Dispatcher
+	d.Publish(Event{Type: VersionPublished})
+	d.Close()
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// Store wraps a storage.Store and publishes an event after each successful
+// write that sinks care about. Methods not overridden pass straight through.
+type Store struct {
+	storage.Store
+	dispatcher  *Dispatcher
+	externalURL string
+}
+
+// NewStore creates a store publishing events to dispatcher. Events link to
+// the API below externalURL; they carry no link if it is empty.
+func NewStore(store storage.Store, dispatcher *Dispatcher, externalURL string) *Store {
+	return &Store{
+		Store:       store,
+		dispatcher:  dispatcher,
+		externalURL: externalURL,
+	}
+}
+
+// CreateVersion creates a version and publishes VersionPublished, or
+// VersionScheduled if the version is embargoed
+func (s *Store) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	if err := s.Store.CreateVersion(ctx, registryName, packageName, v); err != nil {
+		return err
+	}
+	eventType := VersionPublished
+	if v.IsPending(time.Now()) {
+		eventType = VersionScheduled
+	}
+	s.publish(ctx, eventType, registryName, packageName, v.Version, s.maintainers(ctx, registryName, packageName))
+	return nil
+}
+
+// ReleaseVersion releases a scheduled version and publishes VersionPublished
+func (s *Store) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	if err := s.Store.ReleaseVersion(ctx, registryName, packageName, version); err != nil {
+		return err
+	}
+	s.publish(ctx, VersionPublished, registryName, packageName, version, s.maintainers(ctx, registryName, packageName))
+	return nil
+}
+
+// CancelVersion cancels a scheduled version and publishes VersionCancelled
+func (s *Store) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	if err := s.Store.CancelVersion(ctx, registryName, packageName, version); err != nil {
+		return err
+	}
+	s.publish(ctx, VersionCancelled, registryName, packageName, version, s.maintainers(ctx, registryName, packageName))
+	return nil
+}
+
+// DeleteVersion deletes a version and publishes VersionDeleted
+func (s *Store) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	if err := s.Store.DeleteVersion(ctx, registryName, packageName, version); err != nil {
+		return err
+	}
+	s.publish(ctx, VersionDeleted, registryName, packageName, version, s.maintainers(ctx, registryName, packageName))
+	return nil
+}
+
+// DeletePackage deletes a package and publishes PackageDeleted
+func (s *Store) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	// Capture maintainers before they are gone
+	maintainers := s.maintainers(ctx, registryName, packageName)
+	if err := s.Store.DeletePackage(ctx, registryName, packageName); err != nil {
+		return err
+	}
+	s.publish(ctx, PackageDeleted, registryName, packageName, "", maintainers)
+	return nil
+}
+
+// Notify publishes an event about a version that no write caused, such as
+// a failed verification of its archive
+func (s *Store) Notify(ctx context.Context, eventType, registryName, packageName, version string) {
+	s.publish(ctx, eventType, registryName, packageName, version, s.maintainers(ctx, registryName, packageName))
+}
+
+// NotifyRestored publishes a storage.restored event for the backup the data
+// was restored from
+func (s *Store) NotifyRestored(ctx context.Context, backup string) {
+	e := Event{Type: StorageRestored, Backup: backup}
+	if user := auth.UserFromContext(ctx); user != nil {
+		e.Actor = user.Username
+	}
+	s.dispatcher.Publish(e)
+}
+
+// NotifyRolledBack publishes a storage.rolled_back event for the storage
+// version the data was rolled back to
+func (s *Store) NotifyRolledBack(ctx context.Context, version string) {
+	e := Event{Type: StorageRolledBack, StorageVersion: version}
+	if user := auth.UserFromContext(ctx); user != nil {
+		e.Actor = user.Username
+	}
+	s.dispatcher.Publish(e)
+}
+
+// Close delivers pending events, then closes the underlying store
+func (s *Store) Close() error {
+	s.dispatcher.Close()
+	return s.Store.Close()
+}
+
+func (s *Store) publish(ctx context.Context, eventType, registryName, packageName, version string, maintainers []string) {
+	e := Event{
+		Type:        eventType,
+		Registry:    registryName,
+		Package:     packageName,
+		Version:     version,
+		Maintainers: maintainers,
+	}
+	if user := auth.UserFromContext(ctx); user != nil {
+		e.Actor = user.Username
+	}
+	e.URL = s.link(e)
+	s.dispatcher.Publish(e)
+}
+
+// link returns the API URL of the event's version, or of its package or
+// registry when the event deleted what it names
+func (s *Store) link(e Event) string {
+	if s.externalURL == "" {
+		return ""
+	}
+	link := s.externalURL + "/api/v1/registry/" + url.PathEscape(e.Registry)
+	if e.Type == PackageDeleted {
+		return link
+	}
+	link += "/package/" + url.PathEscape(e.Package)
+	if e.Type == VersionDeleted || e.Type == VersionCancelled {
+		return link
+	}
+	return link + "/version/" + url.PathEscape(e.Version)
+}
+
+// maintainers returns a copy of a package's maintainers, or nil if it cannot be read
+func (s *Store) maintainers(ctx context.Context, registryName, packageName string) []string {
+	pkg, err := s.Store.GetPackage(ctx, registryName, packageName)
+	if err != nil {
+		return nil
+	}
+	return append([]string(nil), pkg.Maintainers...)
+}
@@ -0,0 +1,68 @@
+// Package fips holds the restricted-crypto mode of the server. In this mode
+// the server only uses FIPS-approved algorithms: PBKDF2 password hashes
+// instead of bcrypt, sha256 or sha512 checksums, and TLS 1.2+ with AES-GCM
+// cipher suites and NIST curves for outgoing connections to storage backends.
+//
+// The mode is on when the binary is built with the fips build tag, when Go's
+// FIPS 140-3 module is enabled (GOFIPS140 at build time, or
+// GODEBUG=fips140=on), or when crypto.fips is set in the configuration.
+package fips
+
+import (
+	"crypto/fips140"
+	"crypto/tls"
+	"net/http"
+	"sync/atomic"
+)
+
+// configured is set by Enable, from the configuration
+var configured atomic.Bool
+
+// Enable turns the mode on for the rest of the process. It cannot be turned
+// off: settings derived from it, such as transports, are not rebuilt.
+func Enable() {
+	configured.Store(true)
+}
+
+// Enabled reports whether the restricted-crypto mode is on
+func Enabled() bool {
+	return buildTag || Module() || configured.Load()
+}
+
+// Module reports whether Go's FIPS 140-3 cryptographic module is in use,
+// as opposed to only restricting the algorithms the server chooses
+func Module() bool {
+	return fips140.Enabled()
+}
+
+// cipherSuites are the FIPS-approved TLS 1.2 cipher suites. TLS 1.3 suites
+// are not configurable; Go only offers AES-GCM ones in FIPS 140-3 mode.
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// RestrictTLS limits the TLS settings of a transport to FIPS-approved
+// versions, cipher suites and curves when the mode is on
+func RestrictTLS(transport *http.Transport) {
+	if !Enabled() {
+		return
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	RestrictTLSConfig(transport.TLSClientConfig)
+}
+
+// RestrictTLSConfig does the same as RestrictTLS for a client or server
+// TLS configuration
+func RestrictTLSConfig(config *tls.Config) {
+	if !Enabled() {
+		return
+	}
+	config.MinVersion = max(config.MinVersion, tls.VersionTLS12)
+	config.CipherSuites = cipherSuites
+	config.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+}
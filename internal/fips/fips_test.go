@@ -0,0 +1,31 @@
+package fips
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestrictTLS(t *testing.T) {
+	if !Enabled() {
+		transport := &http.Transport{}
+		RestrictTLS(transport)
+		assert.Nil(t, transport.TLSClientConfig, "transports are left alone outside FIPS mode")
+	}
+
+	Enable()
+	require.True(t, Enabled())
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS13, ServerName: "ghcr.io"}}
+	RestrictTLS(transport)
+	assert.Equal(t, uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion, "a stricter minimum is kept")
+	assert.Equal(t, "ghcr.io", transport.TLSClientConfig.ServerName)
+	assert.Equal(t, cipherSuites, transport.TLSClientConfig.CipherSuites)
+
+	transport = &http.Transport{}
+	RestrictTLS(transport)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+}
@@ -0,0 +1,6 @@
+//go:build fips
+
+package fips
+
+// buildTag turns the mode on in binaries built with -tags fips
+const buildTag = true
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxAliases is the number of package aliases a registry may declare
+const MaxAliases = 100
+
+// ResolveAlias returns the package an old package name was renamed to. A
+// package that exists under the name takes precedence over its alias.
+func (r *Registry) ResolveAlias(name string) (string, bool) {
+	if _, exists := r.Packages[name]; exists {
+		return "", false
+	}
+	target, ok := r.Aliases[name]
+	return target, ok
+}
+
+// Index returns the index.json entries of the registry at time now:
+// released versions, and those of aliased packages again under their old
+// name when the registry lists aliases in its index
+func (r *Registry) Index(now time.Time) []IndexEntry {
+	var entries []IndexEntry
+	for _, pkg := range r.Packages {
+		for _, ver := range pkg.Versions {
+			if !ver.IsPending(now) {
+				entries = append(entries, ver.ToIndexEntry())
+			}
+		}
+	}
+	if !r.IndexAliases {
+		return entries
+	}
+	for alias := range r.Aliases {
+		target, ok := r.ResolveAlias(alias)
+		pkg := r.Packages[target]
+		if !ok || pkg == nil {
+			continue
+		}
+		for _, ver := range pkg.Versions {
+			if !ver.IsPending(now) {
+				entry := ver.ToIndexEntry()
+				entry.Name = alias
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+// ValidateAliases validates the package aliases of a registry: old names
+// mapped to new ones, without chains
+func ValidateAliases(aliases map[string]string) error {
+	if len(aliases) > MaxAliases {
+		return &ValidationError{
+			Field:   "aliases",
+			Message: fmt.Sprintf("aliases must contain at most %d entries", MaxAliases),
+		}
+	}
+	for alias, target := range aliases {
+		if ValidateName(alias) != nil || ValidateName(target) != nil {
+			return &ValidationError{
+				Field:   "aliases",
+				Message: fmt.Sprintf("aliases entry '%s' -> '%s' must name packages matching pattern ^[a-z0-9][a-z0-9_-]*$", alias, target),
+			}
+		}
+		if alias == target {
+			return &ValidationError{
+				Field:   "aliases",
+				Message: fmt.Sprintf("aliases entry '%s' must not point to itself", alias),
+			}
+		}
+		if _, chained := aliases[target]; chained {
+			return &ValidationError{
+				Field:   "aliases",
+				Message: fmt.Sprintf("aliases entry '%s' must point to a package, not to the alias '%s'", alias, target),
+			}
+		}
+	}
+	return nil
+}
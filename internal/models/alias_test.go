@@ -0,0 +1,50 @@
+package models
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_IndexAliases(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	registry := NewRegistry("tools", "", nil, nil)
+	registry.Aliases = map[string]string{"deploy": "deployer", "gone": "missing"}
+	deployer := NewPackage("deployer", "", nil, nil)
+	deployer.Versions["1.0.0"] = NewVersion("deployer", "1.0.0", "sha256:a", "https://example.com/1.zip", 0, 9)
+	scheduled := NewVersion("deployer", "2.0.0", "sha256:b", "https://example.com/2.zip", 0, 9)
+	scheduled.PublishAt = &later
+	deployer.Versions["2.0.0"] = scheduled
+	registry.Packages["deployer"] = deployer
+
+	names := func() []string {
+		var names []string
+		for _, entry := range registry.Index(now) {
+			names = append(names, entry.Name+"@"+entry.Version)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	assert.Equal(t, []string{"deployer@1.0.0"}, names())
+
+	registry.IndexAliases = true
+	assert.Equal(t, []string{"deploy@1.0.0", "deployer@1.0.0"}, names())
+
+	// A package under the old name replaces the alias
+	registry.Packages["deploy"] = NewPackage("deploy", "", nil, nil)
+	assert.Equal(t, []string{"deployer@1.0.0"}, names())
+	_, ok := registry.ResolveAlias("deploy")
+	assert.False(t, ok)
+}
+
+func TestValidateAliases(t *testing.T) {
+	assert.NoError(t, ValidateAliases(nil))
+	assert.NoError(t, ValidateAliases(map[string]string{"deploy": "deployer", "build": "builder"}))
+	assert.Error(t, ValidateAliases(map[string]string{"deploy": "deploy"}))
+	assert.Error(t, ValidateAliases(map[string]string{"deploy": "Deployer"}))
+	assert.Error(t, ValidateAliases(map[string]string{"a": "b", "b": "c"}))
+}
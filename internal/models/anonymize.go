@@ -0,0 +1,177 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+)
+
+// AnonymousDomain is the domain of the pseudonymous emails and hosts
+// substituted by an Anonymizer (reserved, never resolves)
+const AnonymousDomain = "example.invalid"
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	urlPattern   = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.\-]*://[^\s"'<>]+`)
+)
+
+// Anonymizer strips personal and internal details from registry data so it
+// can be shared in bug reports: emails become pseudonyms, sensitive custom
+// values are masked, and URLs lose their host, credentials, query and all
+// but the last path segment. Names, versions, checksums and partitions are
+// kept. Pseudonyms are consistent within an anonymizer, so the same
+// maintainer or host stays recognizable across an export.
+type Anonymizer struct {
+	emails map[string]string
+	hosts  map[string]string
+}
+
+// NewAnonymizer creates an anonymizer with no pseudonyms assigned yet
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{
+		emails: make(map[string]string),
+		hosts:  make(map[string]string),
+	}
+}
+
+// Registry returns an anonymized copy of a registry, its packages and
+// versions included
+func (a *Anonymizer) Registry(r *Registry) *Registry {
+	anonymized := *r
+	anonymized.Description = a.Text(r.Description)
+	anonymized.Admins = a.list(r.Admins)
+	anonymized.Announcements = a.list(r.Announcements)
+	anonymized.CustomValues = a.customValues(r.CustomValues, r.SensitiveKeys)
+
+	if r.Packages != nil {
+		anonymized.Packages = make(map[string]*Package, len(r.Packages))
+		for name, pkg := range r.Packages {
+			anonymized.Packages[name] = a.pkg(pkg, r.SensitiveKeys)
+		}
+	}
+	return &anonymized
+}
+
+func (a *Anonymizer) pkg(p *Package, sensitiveKeys []string) *Package {
+	anonymized := *p
+	anonymized.Description = a.Text(p.Description)
+	anonymized.Maintainers = a.list(p.Maintainers)
+	anonymized.CustomValues = a.customValues(p.CustomValues, sensitiveKeys)
+
+	if p.Versions != nil {
+		anonymized.Versions = make(map[string]*Version, len(p.Versions))
+		for name, v := range p.Versions {
+			version := *v
+			version.URL = a.URL(v.URL)
+			anonymized.Versions[name] = &version
+		}
+	}
+	return &anonymized
+}
+
+// Storage returns an anonymized copy of a whole document: every registry,
+// and the package histories with their actors and recorded values.
+// Quarantined records keep their key and reason but lose their data, and
+// TLS certificates are left out.
+func (a *Anonymizer) Storage(s *Storage) *Storage {
+	anonymized := &Storage{
+		Registries: make(map[string]*Registry, len(s.Registries)),
+		Sync:       s.Sync,
+	}
+	for name, registry := range s.Registries {
+		anonymized.Registries[name] = a.Registry(registry)
+	}
+
+	if s.History != nil {
+		anonymized.History = make(map[string][]*ChangeRecord, len(s.History))
+		for key, records := range s.History {
+			var sensitiveKeys []string
+			if _, registryName, _, _ := SplitRecordKey(key); s.Registries[registryName] != nil {
+				sensitiveKeys = s.Registries[registryName].SensitiveKeys
+			}
+			changes := make([]*ChangeRecord, len(records))
+			for i, record := range records {
+				changes[i] = a.change(record, sensitiveKeys)
+			}
+			anonymized.History[key] = changes
+		}
+	}
+
+	for _, record := range s.Quarantine {
+		stripped := *record
+		stripped.Data = nil
+		anonymized.Quarantine = append(anonymized.Quarantine, &stripped)
+	}
+	return anonymized
+}
+
+func (a *Anonymizer) change(c *ChangeRecord, sensitiveKeys []string) *ChangeRecord {
+	anonymized := c.Masked(sensitiveKeys)
+	anonymized.Actor = a.Text(c.Actor)
+	for i, change := range anonymized.Changes {
+		change.Old = a.Text(change.Old)
+		change.New = a.Text(change.New)
+		anonymized.Changes[i] = change
+	}
+	return anonymized
+}
+
+// customValues masks sensitive values and anonymizes the others
+func (a *Anonymizer) customValues(values map[string]string, sensitiveKeys []string) map[string]string {
+	masked := MaskCustomValues(values, sensitiveKeys)
+	for key, value := range masked {
+		if value != MaskedValue {
+			masked[key] = a.Text(value)
+		}
+	}
+	return masked
+}
+
+func (a *Anonymizer) list(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	anonymized := make([]string, len(values))
+	for i, value := range values {
+		anonymized[i] = a.Text(value)
+	}
+	return anonymized
+}
+
+// Text replaces the emails and URLs found in free text
+func (a *Anonymizer) Text(s string) string {
+	s = urlPattern.ReplaceAllStringFunc(s, a.URL)
+	return emailPattern.ReplaceAllStringFunc(s, a.email)
+}
+
+// email returns the pseudonym of an email address
+func (a *Anonymizer) email(address string) string {
+	pseudonym, ok := a.emails[address]
+	if !ok {
+		pseudonym = fmt.Sprintf("user%d@%s", len(a.emails)+1, AnonymousDomain)
+		a.emails[address] = pseudonym
+	}
+	return pseudonym
+}
+
+// URL replaces the host of a URL with a pseudonym and keeps only the last
+// path segment, so the archive type stays visible. Values that are not
+// absolute URLs are returned as is.
+func (a *Anonymizer) URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	host, ok := a.hosts[u.Hostname()]
+	if !ok {
+		host = fmt.Sprintf("host%d.%s", len(a.hosts)+1, AnonymousDomain)
+		a.hosts[u.Hostname()] = host
+	}
+
+	anonymized := url.URL{Scheme: u.Scheme, Host: host, Path: "/"}
+	if base := path.Base(u.Path); base != "/" && base != "." {
+		anonymized.Path += base
+	}
+	return anonymized.String()
+}
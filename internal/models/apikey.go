@@ -0,0 +1,111 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts the ID of every API key, which is the username of the
+// key's credentials: "<id>:<secret>"
+const APIKeyPrefix = "colakey_"
+
+// Limits of API keys
+const (
+	MaxAPIKeysPerRegistry      = 50
+	MaxAPIKeyDescriptionLength = 256
+)
+
+// packagePrefixPattern matches package name prefixes: a package name, or
+// the start of one
+var packagePrefixPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// APIKey is a credential restricted to publishing versions of the packages
+// of one registry whose names start with a prefix, for CI pipelines. Only a
+// hash of its secret is stored; the secret is shown once, at creation.
+type APIKey struct {
+	ID            string     `json:"id"`
+	Registry      string     `json:"registry"`
+	PackagePrefix string     `json:"package_prefix"` // Empty for every package of the registry
+	Description   string     `json:"description,omitempty"`
+	SecretHash    string     `json:"secret_hash"` // sha256:<hex>
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+}
+
+// NewAPIKey creates an API key and returns it with its secret
+func NewAPIKey(registry, packagePrefix, description, createdBy string, expiresAt *time.Time) (*APIKey, string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+	return &APIKey{
+		ID:            APIKeyPrefix + id,
+		Registry:      registry,
+		PackagePrefix: packagePrefix,
+		Description:   description,
+		SecretHash:    hashAPIKeySecret(secret),
+		CreatedBy:     createdBy,
+		CreatedAt:     time.Now().UTC(),
+		ExpiresAt:     expiresAt,
+	}, secret, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Secrets are random, so a fast hash is enough to keep them unusable if
+// the storage leaks
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// IsAPIKeyID reports whether a username names an API key
+func IsAPIKeyID(username string) bool {
+	return strings.HasPrefix(username, APIKeyPrefix)
+}
+
+// Matches reports whether secret is the secret of the key
+func (k *APIKey) Matches(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(k.SecretHash)) == 1
+}
+
+// Expired reports whether the key has expired at time now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// AllowsPublish reports whether the key may publish versions of a package
+func (k *APIKey) AllowsPublish(registryName, packageName string) bool {
+	return registryName == k.Registry && strings.HasPrefix(packageName, k.PackagePrefix)
+}
+
+// ValidateAPIKey validates the settings of a new API key
+func ValidateAPIKey(k *APIKey) error {
+	if k.PackagePrefix != "" && (len(k.PackagePrefix) > 64 || !packagePrefixPattern.MatchString(k.PackagePrefix)) {
+		return &ValidationError{Field: "package_prefix", Message: "package_prefix must be at most 64 characters and match pattern ^[a-z0-9][a-z0-9_-]*$"}
+	}
+	if len(k.Description) > MaxAPIKeyDescriptionLength {
+		return &ValidationError{Field: "description", Message: fmt.Sprintf("description must be at most %d characters", MaxAPIKeyDescriptionLength)}
+	}
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		return &ValidationError{Field: "expires_at", Message: "expires_at must be in the future"}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, secret, err := NewAPIKey("tools", "payments-", "payments CI", "alice", nil)
+	require.NoError(t, err)
+
+	assert.True(t, IsAPIKeyID(key.ID))
+	assert.Len(t, key.ID, len(APIKeyPrefix)+16)
+	assert.Len(t, secret, 48)
+	assert.True(t, strings.HasPrefix(key.SecretHash, "sha256:"))
+	assert.NotContains(t, key.SecretHash, secret)
+	assert.True(t, key.Matches(secret))
+	assert.False(t, key.Matches(secret+"x"))
+	assert.False(t, key.Matches(""))
+
+	other, otherSecret, err := NewAPIKey("tools", "", "", "alice", nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, key.ID, other.ID)
+	assert.NotEqual(t, secret, otherSecret)
+}
+
+func TestAPIKey_AllowsPublish(t *testing.T) {
+	key := &APIKey{Registry: "tools", PackagePrefix: "payments-"}
+	assert.True(t, key.AllowsPublish("tools", "payments-cli"))
+	assert.False(t, key.AllowsPublish("tools", "deployer"))
+	assert.False(t, key.AllowsPublish("infra", "payments-cli"))
+
+	// Without a prefix, every package of the registry
+	key.PackagePrefix = ""
+	assert.True(t, key.AllowsPublish("tools", "deployer"))
+	assert.False(t, key.AllowsPublish("infra", "deployer"))
+}
+
+func TestAPIKey_Expired(t *testing.T) {
+	now := time.Now()
+	key := &APIKey{}
+	assert.False(t, key.Expired(now))
+
+	expiresAt := now.Add(time.Hour)
+	key.ExpiresAt = &expiresAt
+	assert.False(t, key.Expired(now))
+	assert.True(t, key.Expired(expiresAt))
+}
+
+func TestValidateAPIKey(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		key   APIKey
+		field string
+	}{
+		{name: "valid", key: APIKey{PackagePrefix: "payments-", Description: "CI", ExpiresAt: &future}},
+		{name: "every package", key: APIKey{}},
+		{name: "invalid prefix", key: APIKey{PackagePrefix: "Payments"}, field: "package_prefix"},
+		{name: "prefix too long", key: APIKey{PackagePrefix: strings.Repeat("a", 65)}, field: "package_prefix"},
+		{name: "description too long", key: APIKey{Description: strings.Repeat("a", MaxAPIKeyDescriptionLength+1)}, field: "description"},
+		{name: "expired", key: APIKey{ExpiresAt: &past}, field: "expires_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAPIKey(&tt.key)
+			if tt.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.field, validationErr.Field)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// PackageFilter selects the packages of a registry a batch update applies to.
+// A package matches when it meets every criterion that is set, so an empty
+// filter matches every package.
+type PackageFilter struct {
+	Names        []string         `json:"names,omitempty"`         // Any of these package names
+	Maintainer   string           `json:"maintainer,omitempty"`    // Packages listing this maintainer (case-insensitive)
+	CustomValues CustomValueQuery `json:"custom_values,omitempty"` // Same semantics as ?custom.<key>=<value>
+}
+
+// Matches reports whether p meets every criterion of the filter
+func (f PackageFilter) Matches(p *Package) bool {
+	if len(f.Names) > 0 && !slices.Contains(f.Names, p.Name) {
+		return false
+	}
+	if f.Maintainer != "" && !p.IsMaintainer(f.Maintainer) {
+		return false
+	}
+	for key, values := range f.CustomValues {
+		value, ok := p.CustomValues[key]
+		if !ok || !slices.Contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// PackagePatch is a metadata change applied to several packages at once.
+// Versions and descriptions are never changed by a patch.
+type PackagePatch struct {
+	AddMaintainers    []string          `json:"add_maintainers,omitempty"`
+	RemoveMaintainers []string          `json:"remove_maintainers,omitempty"`
+	SetCustomValues   map[string]string `json:"set_custom_values,omitempty"`
+	UnsetCustomValues []string          `json:"unset_custom_values,omitempty"`
+}
+
+// IsEmpty reports whether the patch changes nothing
+func (p PackagePatch) IsEmpty() bool {
+	return len(p.AddMaintainers) == 0 && len(p.RemoveMaintainers) == 0 &&
+		len(p.SetCustomValues) == 0 && len(p.UnsetCustomValues) == 0
+}
+
+// Validate checks the patch on its own; the patched packages must still be
+// validated, since they may end up with too many custom values
+func (p PackagePatch) Validate() error {
+	if p.IsEmpty() {
+		return &ValidationError{Field: "patch", Message: "patch must change at least one field"}
+	}
+	for _, m := range slices.Concat(p.AddMaintainers, p.RemoveMaintainers) {
+		if strings.TrimSpace(m) == "" {
+			return &ValidationError{Field: "patch", Message: "maintainers must not be empty"}
+		}
+	}
+	if err := ValidateCustomValues(p.SetCustomValues); err != nil {
+		return err
+	}
+	for _, key := range p.UnsetCustomValues {
+		if _, ok := p.SetCustomValues[key]; ok {
+			return &ValidationError{Field: "patch", Message: fmt.Sprintf("custom value '%s' cannot be both set and unset", key)}
+		}
+	}
+	return nil
+}
+
+// Apply returns a copy of pkg with the patch applied, and whether anything
+// changed. Maintainers are compared case-insensitively and keep their order;
+// added ones are appended. pkg itself is never modified.
+func (p PackagePatch) Apply(pkg *Package) (*Package, bool) {
+	patched := *pkg
+	patched.Maintainers = make([]string, 0, len(pkg.Maintainers)+len(p.AddMaintainers))
+	patched.CustomValues = maps.Clone(pkg.CustomValues)
+	changed := false
+
+	for _, m := range pkg.Maintainers {
+		if containsFold(p.RemoveMaintainers, m) {
+			changed = true
+			continue
+		}
+		patched.Maintainers = append(patched.Maintainers, m)
+	}
+	for _, m := range p.AddMaintainers {
+		if containsFold(patched.Maintainers, m) {
+			continue
+		}
+		patched.Maintainers = append(patched.Maintainers, m)
+		changed = true
+	}
+
+	for key, value := range p.SetCustomValues {
+		if current, ok := patched.CustomValues[key]; ok && current == value {
+			continue
+		}
+		if patched.CustomValues == nil {
+			patched.CustomValues = make(map[string]string)
+		}
+		patched.CustomValues[key] = value
+		changed = true
+	}
+	for _, key := range p.UnsetCustomValues {
+		if _, ok := patched.CustomValues[key]; ok {
+			delete(patched.CustomValues, key)
+			changed = true
+		}
+	}
+
+	return &patched, changed
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageFilterMatches(t *testing.T) {
+	pkg := &Package{
+		Name:         "deploy",
+		Maintainers:  []string{"Platform@example.com"},
+		CustomValues: map[string]string{"team": "platform", "tier": "1"},
+	}
+
+	assert.True(t, PackageFilter{}.Matches(pkg))
+	assert.True(t, PackageFilter{Names: []string{"lint", "deploy"}}.Matches(pkg))
+	assert.True(t, PackageFilter{Maintainer: "platform@example.com"}.Matches(pkg))
+	assert.True(t, PackageFilter{CustomValues: CustomValueQuery{"tier": {"1", "2"}}}.Matches(pkg))
+	assert.False(t, PackageFilter{Names: []string{"lint"}}.Matches(pkg))
+	assert.False(t, PackageFilter{Maintainer: "other@example.com"}.Matches(pkg))
+	assert.False(t, PackageFilter{Maintainer: "platform@example.com", CustomValues: CustomValueQuery{"team": {"payments"}}}.Matches(pkg))
+}
+
+func TestPackagePatchApply(t *testing.T) {
+	pkg := &Package{
+		Name:         "deploy",
+		Maintainers:  []string{"old-team@example.com", "alice@example.com"},
+		CustomValues: map[string]string{"team": "old", "legacy": "yes"},
+	}
+	patch := PackagePatch{
+		AddMaintainers:    []string{"new-team@example.com", "ALICE@example.com"},
+		RemoveMaintainers: []string{"Old-Team@example.com"},
+		SetCustomValues:   map[string]string{"team": "new"},
+		UnsetCustomValues: []string{"legacy"},
+	}
+
+	patched, changed := patch.Apply(pkg)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"alice@example.com", "new-team@example.com"}, patched.Maintainers)
+	assert.Equal(t, map[string]string{"team": "new"}, patched.CustomValues)
+
+	// The original package is untouched
+	assert.Equal(t, []string{"old-team@example.com", "alice@example.com"}, pkg.Maintainers)
+	assert.Equal(t, "old", pkg.CustomValues["team"])
+
+	// Applying again is a no-op
+	_, changed = patch.Apply(patched)
+	assert.False(t, changed)
+}
+
+func TestPackagePatchValidate(t *testing.T) {
+	assert.Error(t, PackagePatch{}.Validate())
+	assert.Error(t, PackagePatch{AddMaintainers: []string{" "}}.Validate())
+	assert.Error(t, PackagePatch{SetCustomValues: map[string]string{"bad key": "x"}}.Validate())
+	assert.Error(t, PackagePatch{SetCustomValues: map[string]string{"team": "x"}, UnsetCustomValues: []string{"team"}}.Validate())
+	assert.NoError(t, PackagePatch{SetCustomValues: map[string]string{"team": "x"}, UnsetCustomValues: []string{"legacy"}}.Validate())
+}
@@ -0,0 +1,7 @@
+package models
+
+// PackageNameConflict is a package name used by more than one registry
+type PackageNameConflict struct {
+	Name       string   `json:"name"`
+	Registries []string `json:"registries"`
+}
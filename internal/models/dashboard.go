@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Limits of registry announcements
+const (
+	MaxAnnouncements      = 10
+	MaxAnnouncementLength = 500
+)
+
+// DashboardRecentChanges is the number of changes listed in a registry dashboard
+const DashboardRecentChanges = 10
+
+// RegistryDashboard is the compact overview of a registry for launchers and
+// portals that list packages without fetching the whole index
+type RegistryDashboard struct {
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	PackageCount   int             `json:"package_count"`
+	VersionCount   int             `json:"version_count"` // Released versions, embargoed ones left out
+	Announcements  []string        `json:"announcements"`
+	NewestVersions []NewestVersion `json:"newest_versions"` // By package name
+	RecentChanges  []RecentChange  `json:"recent_changes"`  // Newest first
+	CacheTTL       int             `json:"cache_ttl"`       // Seconds clients may cache index.json, set by the handler
+}
+
+// NewestVersion is the highest released version of a package
+type NewestVersion struct {
+	Package     string `json:"package"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// RecentChange is a package change listed in a registry dashboard. Actors
+// and field edits are left out; they are in the package history.
+type RecentChange struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Package string    `json:"package"`
+	Version string    `json:"version,omitempty"`
+}
+
+// Dashboard builds the dashboard of a registry at time now from the change
+// histories of its packages, keyed by package name and newest first
+func Dashboard(r *Registry, histories map[string][]*ChangeRecord, now time.Time) *RegistryDashboard {
+	dashboard := &RegistryDashboard{
+		Name:           r.Name,
+		Description:    r.Description,
+		PackageCount:   len(r.Packages),
+		Announcements:  append([]string{}, r.Announcements...),
+		NewestVersions: []NewestVersion{},
+		RecentChanges:  []RecentChange{},
+	}
+
+	for name, pkg := range r.Packages {
+		var newest *Version
+		for _, v := range pkg.Versions {
+			if v.IsPending(now) {
+				continue
+			}
+			dashboard.VersionCount++
+			if newest == nil || CompareVersions(v.Version, newest.Version) > 0 {
+				newest = v
+			}
+		}
+		if newest != nil {
+			dashboard.NewestVersions = append(dashboard.NewestVersions, NewestVersion{
+				Package:     name,
+				Version:     newest.Version,
+				Description: pkg.Description,
+			})
+		}
+	}
+	sort.Slice(dashboard.NewestVersions, func(i, j int) bool {
+		return dashboard.NewestVersions[i].Package < dashboard.NewestVersions[j].Package
+	})
+
+	for name, history := range histories {
+		for _, record := range history {
+			dashboard.RecentChanges = append(dashboard.RecentChanges, RecentChange{
+				Time:    record.Time,
+				Type:    record.Type,
+				Package: name,
+				Version: record.Version,
+			})
+		}
+	}
+	sort.SliceStable(dashboard.RecentChanges, func(i, j int) bool {
+		a, b := dashboard.RecentChanges[i], dashboard.RecentChanges[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time)
+		}
+		return a.Package < b.Package
+	})
+	if len(dashboard.RecentChanges) > DashboardRecentChanges {
+		dashboard.RecentChanges = dashboard.RecentChanges[:DashboardRecentChanges]
+	}
+	return dashboard
+}
+
+// ValidateAnnouncements validates the announcements of a registry
+func ValidateAnnouncements(announcements []string) error {
+	if len(announcements) > MaxAnnouncements {
+		return &ValidationError{
+			Field:   "announcements",
+			Message: fmt.Sprintf("announcements must contain at most %d entries", MaxAnnouncements),
+		}
+	}
+	for _, announcement := range announcements {
+		if announcement == "" || len(announcement) > MaxAnnouncementLength {
+			return &ValidationError{
+				Field:   "announcements",
+				Message: fmt.Sprintf("announcements must be 1 to %d characters", MaxAnnouncementLength),
+			}
+		}
+	}
+	return nil
+}
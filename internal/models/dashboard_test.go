@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboard(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	registry := NewRegistry("tools", "Build tools", nil, nil)
+	deployer := NewPackage("deployer", "", nil, nil)
+	deployer.Versions["1.10.0"] = NewVersion("deployer", "1.10.0", "sha256:a", "https://example.com/a.zip", 0, 9)
+	deployer.Versions["1.9.0"] = NewVersion("deployer", "1.9.0", "sha256:a", "https://example.com/a.zip", 0, 9)
+	embargoed := NewVersion("deployer", "2.0.0", "sha256:a", "https://example.com/a.zip", 0, 9)
+	embargoed.PublishAt = &later
+	deployer.Versions["2.0.0"] = embargoed
+	registry.Packages["deployer"] = deployer
+	registry.Packages["empty"] = NewPackage("empty", "", nil, nil)
+
+	var history []*ChangeRecord
+	for i := 12; i > 0; i-- {
+		history = append(history, &ChangeRecord{Time: now.Add(-time.Duration(i) * time.Minute), Type: ChangeVersionPublished, Version: fmt.Sprintf("1.%d.0", i)})
+	}
+
+	dashboard := Dashboard(registry, map[string][]*ChangeRecord{
+		"deployer": history,
+		"empty":    {{Time: now, Type: ChangePackageCreated, Actor: "alice"}},
+	}, now)
+
+	assert.Equal(t, 2, dashboard.PackageCount)
+	assert.Equal(t, 2, dashboard.VersionCount)
+	assert.Equal(t, []NewestVersion{{Package: "deployer", Version: "1.10.0"}}, dashboard.NewestVersions)
+	assert.Len(t, dashboard.RecentChanges, DashboardRecentChanges)
+	assert.Equal(t, RecentChange{Time: now, Type: ChangePackageCreated, Package: "empty"}, dashboard.RecentChanges[0])
+	assert.Equal(t, "1.1.0", dashboard.RecentChanges[1].Version)
+	assert.NotNil(t, dashboard.Announcements)
+}
+
+func TestValidateAnnouncements(t *testing.T) {
+	assert.NoError(t, ValidateAnnouncements(nil))
+	assert.NoError(t, ValidateAnnouncements([]string{"Maintenance on Friday"}))
+	assert.Error(t, ValidateAnnouncements([]string{""}))
+	assert.Error(t, ValidateAnnouncements(make([]string, MaxAnnouncements+1)))
+}
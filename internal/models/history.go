@@ -0,0 +1,117 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change types in a package's history. They match the event types sinks
+// receive, plus the package changes no sink is notified of.
+const (
+	ChangePackageCreated   = "package.created"
+	ChangePackageUpdated   = "package.updated"
+	ChangePackageDeleted   = "package.deleted"
+	ChangeVersionPublished = "version.published"
+	ChangeVersionScheduled = "version.scheduled"
+	ChangeVersionCancelled = "version.cancelled"
+	ChangeVersionDeleted   = "version.deleted"
+)
+
+// HistoryLimit is the number of changes kept per package; older ones are
+// dropped as new ones are recorded
+const HistoryLimit = 200
+
+// ChangeRecord is one entry of a package's change history
+type ChangeRecord struct {
+	Time    time.Time     `json:"time"`
+	Type    string        `json:"type"`
+	Actor   string        `json:"actor,omitempty"`   // Authenticated user who made the change
+	Version string        `json:"version,omitempty"` // Version changes only
+	Changes []FieldChange `json:"changes,omitempty"` // Metadata edits (package.updated)
+}
+
+// FieldChange is one metadata field edited by a package update. Lists are
+// comma-separated; Old or New is empty when the field was added or removed.
+type FieldChange struct {
+	Field string `json:"field"` // description, maintainers or custom_values.<key>
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// DiffPackage lists the metadata edits between two revisions of a package,
+// custom values sorted by key. Values of sensitive keys are stored
+// encrypted with a fresh nonce on every write, so only their addition or
+// removal is reported, masked.
+func DiffPackage(old, updated *Package, sensitiveKeys []string) []FieldChange {
+	var changes []FieldChange
+	if old.Description != updated.Description {
+		changes = append(changes, FieldChange{Field: "description", Old: old.Description, New: updated.Description})
+	}
+	oldMaintainers := strings.Join(old.Maintainers, ",")
+	newMaintainers := strings.Join(updated.Maintainers, ",")
+	if oldMaintainers != newMaintainers {
+		changes = append(changes, FieldChange{Field: "maintainers", Old: oldMaintainers, New: newMaintainers})
+	}
+
+	sensitive := make(map[string]bool, len(sensitiveKeys))
+	for _, key := range sensitiveKeys {
+		sensitive[key] = true
+	}
+	keys := make([]string, 0, len(old.CustomValues)+len(updated.CustomValues))
+	for key := range old.CustomValues {
+		keys = append(keys, key)
+	}
+	for key := range updated.CustomValues {
+		if _, ok := old.CustomValues[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		oldValue, hadKey := old.CustomValues[key]
+		newValue, hasKey := updated.CustomValues[key]
+		field := "custom_values." + key
+		switch {
+		case sensitive[key]:
+			if hadKey != hasKey {
+				change := FieldChange{Field: field}
+				if hadKey {
+					change.Old = MaskedValue
+				} else {
+					change.New = MaskedValue
+				}
+				changes = append(changes, change)
+			}
+		case !hadKey || !hasKey || oldValue != newValue:
+			changes = append(changes, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+// Masked returns a copy of the change with the sensitive custom values it
+// records masked, including values recorded before their key became
+// sensitive
+func (c *ChangeRecord) Masked(sensitiveKeys []string) *ChangeRecord {
+	masked := *c
+	if len(c.Changes) == 0 {
+		return &masked
+	}
+	masked.Changes = make([]FieldChange, len(c.Changes))
+	for i, change := range c.Changes {
+		for _, key := range sensitiveKeys {
+			if change.Field != "custom_values."+key {
+				continue
+			}
+			if change.Old != "" {
+				change.Old = MaskedValue
+			}
+			if change.New != "" {
+				change.New = MaskedValue
+			}
+		}
+		masked.Changes[i] = change
+	}
+	return &masked
+}
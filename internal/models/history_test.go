@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffPackage(t *testing.T) {
+	old := NewPackage("deployer", "Deploys", []string{"a@example.com"}, map[string]string{"team": "infra", "tier": "1", "api_key": "enc:1"})
+	updated := NewPackage("deployer", "Deploys things", []string{"a@example.com", "b@example.com"}, map[string]string{"team": "platform", "owner": "b", "api_key": "enc:2"})
+
+	assert.Equal(t, []FieldChange{
+		{Field: "description", Old: "Deploys", New: "Deploys things"},
+		{Field: "maintainers", Old: "a@example.com", New: "a@example.com,b@example.com"},
+		{Field: "custom_values.owner", New: "b"},
+		{Field: "custom_values.team", Old: "infra", New: "platform"},
+		{Field: "custom_values.tier", Old: "1"},
+	}, DiffPackage(old, updated, []string{"api_key"}))
+
+	// Sensitive keys are reported only when added or removed, masked
+	delete(updated.CustomValues, "api_key")
+	changes := DiffPackage(old, updated, []string{"api_key"})
+	assert.Contains(t, changes, FieldChange{Field: "custom_values.api_key", Old: MaskedValue})
+
+	assert.Empty(t, DiffPackage(old, old, nil))
+}
+
+func TestChangeRecordMasked(t *testing.T) {
+	change := &ChangeRecord{Type: ChangePackageUpdated, Changes: []FieldChange{
+		{Field: "custom_values.token", Old: "old-secret", New: "new-secret"},
+		{Field: "custom_values.team", New: "build"},
+	}}
+
+	masked := change.Masked([]string{"token"})
+	assert.Equal(t, FieldChange{Field: "custom_values.token", Old: MaskedValue, New: MaskedValue}, masked.Changes[0])
+	assert.Equal(t, FieldChange{Field: "custom_values.team", New: "build"}, masked.Changes[1])
+
+	// Original is untouched
+	assert.Equal(t, "old-secret", change.Changes[0].Old)
+}
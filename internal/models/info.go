@@ -0,0 +1,42 @@
+package models
+
+// Client version skew levels reported by ServerInfo.CheckClient
+const (
+	ClientSupported   = "supported"
+	ClientOutdated    = "outdated"    // Older than the recommended version
+	ClientUnsupported = "unsupported" // Older than the minimum version
+)
+
+// ServerInfo describes the server and the client versions it expects.
+// Client versions are optional; an empty one sets no bound.
+type ServerInfo struct {
+	Version                  string `json:"version"`
+	MinClientVersion         string `json:"min_client_version,omitempty"`
+	RecommendedClientVersion string `json:"recommended_client_version,omitempty"`
+	AnonymousRead            bool   `json:"anonymous_read"`   // Whether reads need no credentials by default
+	AnonymousAccess          string `json:"anonymous_access"` // Read routes served without credentials: all or index
+	FIPSMode                 bool   `json:"fips_mode"`        // Only FIPS-approved algorithms are used
+	FIPS140Module            bool   `json:"fips140_module"`   // Go's FIPS 140-3 cryptographic module is in use
+}
+
+// CheckClient reports whether a client version is supported, outdated or
+// unsupported. Versions that do not parse, such as development builds, are
+// always supported.
+func (i *ServerInfo) CheckClient(clientVersion string) string {
+	client, err := ParseVersion(clientVersion)
+	if err != nil {
+		return ClientSupported
+	}
+	olderThan := func(bound string) bool {
+		parsed, err := ParseVersion(bound)
+		return err == nil && client.Compare(parsed) < 0
+	}
+
+	switch {
+	case olderThan(i.MinClientVersion):
+		return ClientUnsupported
+	case olderThan(i.RecommendedClientVersion):
+		return ClientOutdated
+	}
+	return ClientSupported
+}
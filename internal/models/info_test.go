@@ -0,0 +1,22 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerInfo_CheckClient(t *testing.T) {
+	info := &ServerInfo{Version: "2.0.0", MinClientVersion: "1.2.0", RecommendedClientVersion: "1.4.0"}
+
+	assert.Equal(t, ClientSupported, info.CheckClient("1.4.0"))
+	assert.Equal(t, ClientSupported, info.CheckClient("2.1.0"))
+	assert.Equal(t, ClientOutdated, info.CheckClient("1.3.9"))
+	assert.Equal(t, ClientOutdated, info.CheckClient("1.2.0"))
+	assert.Equal(t, ClientUnsupported, info.CheckClient("1.2.0-rc.1"))
+	assert.Equal(t, ClientUnsupported, info.CheckClient("1.0.0"))
+
+	// Development builds and servers without bounds never complain
+	assert.Equal(t, ClientSupported, info.CheckClient("dev"))
+	assert.Equal(t, ClientSupported, (&ServerInfo{Version: "2.0.0"}).CheckClient("0.1.0"))
+}
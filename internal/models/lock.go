@@ -0,0 +1,323 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxLockPackages is the maximum number of packages in a lock request
+const MaxLockPackages = 1000
+
+// VersionConstraint selects the versions of a package a lockfile may pin.
+// Clauses separated by commas must all match:
+//
+//   - "" or "*": any version
+//   - "1.2.3" or "=1.2.3": that version ("1.2" equals "1.2.0")
+//   - "!=1.2.3", ">1.2", ">=1.2", "<2", "<=2.1": comparisons
+//   - "^1.2.3": compatible versions, up to the next major (minor for 0.x)
+//   - "~1.2.3": patch versions, up to the next minor (major for "~1")
+//   - "1.2.x" or "1.2.*": any version with that prefix
+//
+// Pre-releases only match when a clause names a pre-release, so a fleet is
+// never pinned to one by accident.
+type VersionConstraint struct {
+	raw        string
+	clauses    []constraintClause
+	preRelease bool // Pre-releases may match
+}
+
+// constraintClause compares versions to a bound
+type constraintClause struct {
+	op    string // One of = != > >= < <=
+	bound *ParsedVersion
+}
+
+// ParseConstraint parses a version constraint
+func ParseConstraint(constraint string) (*VersionConstraint, error) {
+	c := &VersionConstraint{raw: strings.TrimSpace(constraint)}
+	if c.raw == "" {
+		return c, nil
+	}
+	for _, part := range strings.Split(c.raw, ",") {
+		clauses, preRelease, err := parseConstraintPart(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+		}
+		c.preRelease = c.preRelease || preRelease
+		c.clauses = append(c.clauses, clauses...)
+	}
+	return c, nil
+}
+
+// parseConstraintPart expands one comma-separated part into clauses, and
+// reports whether it names a pre-release
+func parseConstraintPart(part string) ([]constraintClause, bool, error) {
+	if part == "*" {
+		return nil, false, nil
+	}
+	if part == "" {
+		return nil, false, fmt.Errorf("empty clause")
+	}
+
+	op := ""
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(part, prefix) {
+			op = prefix
+			part = strings.TrimSpace(strings.TrimPrefix(part, prefix))
+			break
+		}
+	}
+
+	// Wildcards: "1.2.x" is ">=1.2.0, <1.3.0"
+	if i := wildcardIndex(part); i >= 0 {
+		if op != "" && op != "=" {
+			return nil, false, fmt.Errorf("wildcard %q cannot follow %q", part, op)
+		}
+		if i == 0 {
+			return nil, false, nil
+		}
+		prefix, err := parseBound(strings.Join(strings.Split(part, ".")[:i], "."))
+		if err != nil {
+			return nil, false, err
+		}
+		if len(prefix.PreRelease) > 0 {
+			return nil, false, fmt.Errorf("wildcard %q cannot have a pre-release", part)
+		}
+		return rangeClauses(prefix, i-1), false, nil
+	}
+
+	bound, err := parseBound(part)
+	if err != nil {
+		return nil, false, err
+	}
+	preRelease := len(bound.PreRelease) > 0
+	switch op {
+	case "", "=":
+		return []constraintClause{{op: "=", bound: bound}}, preRelease, nil
+	case "^":
+		// Bump the first non-zero component; "^0.0.3" only allows 0.0.3
+		bump := len(bound.Numbers) - 1
+		for i, n := range bound.Numbers {
+			if n != 0 {
+				bump = i
+				break
+			}
+		}
+		return append([]constraintClause{{op: ">=", bound: bound}}, rangeClauses(bound, bump)[1:]...), preRelease, nil
+	case "~":
+		bump := min(1, len(bound.Numbers)-1)
+		return append([]constraintClause{{op: ">=", bound: bound}}, rangeClauses(bound, bump)[1:]...), preRelease, nil
+	default:
+		return []constraintClause{{op: op, bound: bound}}, preRelease, nil
+	}
+}
+
+// wildcardIndex returns the index of the first wildcard component, or -1
+func wildcardIndex(version string) int {
+	for i, component := range strings.Split(version, ".") {
+		if component == "x" || component == "X" || component == "*" {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseBound parses the version of a clause; build metadata is not allowed
+func parseBound(version string) (*ParsedVersion, error) {
+	parsed, err := ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Build != "" {
+		return nil, fmt.Errorf("build metadata is not allowed in constraints")
+	}
+	return parsed, nil
+}
+
+// rangeClauses returns the clauses matching every version sharing the
+// components of v up to index last: ">= v[:last+1], < v[:last] with v[last]+1"
+func rangeClauses(v *ParsedVersion, last int) []constraintClause {
+	lower := &ParsedVersion{Numbers: append([]uint64{}, v.Numbers[:last+1]...)}
+	upper := &ParsedVersion{Numbers: append([]uint64{}, v.Numbers[:last+1]...)}
+	upper.Numbers[last]++
+	// "<1.3" must not admit 1.3.0 pre-releases
+	upper.PreRelease = []string{"0"}
+	return []constraintClause{{op: ">=", bound: lower}, {op: "<", bound: upper}}
+}
+
+// Matches reports whether version meets every clause. Versions that cannot
+// be parsed only match an empty constraint.
+func (c *VersionConstraint) Matches(version string) bool {
+	parsed, err := ParseVersion(version)
+	if err != nil {
+		return c.raw == ""
+	}
+	if len(parsed.PreRelease) > 0 && !c.preRelease {
+		return false
+	}
+	for _, clause := range c.clauses {
+		cmp := parsed.Compare(clause.bound)
+		var ok bool
+		switch clause.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the constraint as given
+func (c *VersionConstraint) String() string {
+	return c.raw
+}
+
+// LockRequirement is a package to pin, with an optional version constraint
+type LockRequirement struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// LockRequest lists the packages to pin. An empty list pins every package
+// of the registry.
+type LockRequest struct {
+	Packages []LockRequirement `json:"packages"`
+}
+
+// Validate checks the request before resolution
+func (r *LockRequest) Validate() error {
+	if len(r.Packages) > MaxLockPackages {
+		return &ValidationError{Field: "packages", Message: fmt.Sprintf("at most %d packages can be locked at once", MaxLockPackages)}
+	}
+	seen := make(map[string]bool, len(r.Packages))
+	for _, req := range r.Packages {
+		if req.Name == "" {
+			return &ValidationError{Field: "packages", Message: "package name is required"}
+		}
+		if seen[req.Name] {
+			return &ValidationError{Field: "packages", Message: fmt.Sprintf("package '%s' is listed more than once", req.Name)}
+		}
+		seen[req.Name] = true
+		if _, err := ParseConstraint(req.Constraint); err != nil {
+			return &ValidationError{Field: "packages", Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// Lockfile pins exact versions of packages of a registry, so a fleet can be
+// rolled out to the same versions from a committed file
+type Lockfile struct {
+	Registry    string          `json:"registry"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Packages    []LockedPackage `json:"packages"` // By package name
+}
+
+// LockedPackage is the version a package is pinned to
+type LockedPackage struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint,omitempty"` // As requested
+	Version    string `json:"version"`
+	Checksum   string `json:"checksum"`
+	URL        string `json:"url"`
+}
+
+// UnresolvedPackage is a requested package no version could be pinned for
+type UnresolvedPackage struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// LockError lists the packages a lock request could not resolve
+type LockError struct {
+	Unresolved []UnresolvedPackage
+}
+
+func (e *LockError) Error() string {
+	names := make([]string, len(e.Unresolved))
+	for i, u := range e.Unresolved {
+		names[i] = u.Name
+	}
+	return fmt.Sprintf("cannot lock %s", strings.Join(names, ", "))
+}
+
+// Lock pins each requested package of r to its highest released version at
+// time now meeting the constraint. Partitions are ignored: a lockfile pins
+// the same version for the whole fleet. Under the legacy version policy,
+// suffixes such as "-build5" are not pre-releases and are always eligible.
+// The request must be valid.
+func Lock(r *Registry, req *LockRequest, now time.Time) (*Lockfile, error) {
+	requirements := req.Packages
+	if len(requirements) == 0 {
+		for name := range r.Packages {
+			requirements = append(requirements, LockRequirement{Name: name})
+		}
+	}
+
+	lockfile := &Lockfile{Registry: r.Name, GeneratedAt: now.UTC(), Packages: []LockedPackage{}}
+	var lockErr LockError
+	for _, requirement := range requirements {
+		constraint, err := ParseConstraint(requirement.Constraint)
+		if err != nil {
+			return nil, err
+		}
+		if r.VersionPolicy == VersionPolicyLegacy {
+			constraint.preRelease = true
+		}
+		unresolved := UnresolvedPackage{Name: requirement.Name, Constraint: requirement.Constraint}
+
+		pkg, ok := r.Packages[requirement.Name]
+		if !ok {
+			unresolved.Reason = "package not found"
+			lockErr.Unresolved = append(lockErr.Unresolved, unresolved)
+			continue
+		}
+		var best *Version
+		for _, v := range pkg.Versions {
+			if v.IsPending(now) || !constraint.Matches(v.Version) {
+				continue
+			}
+			if best == nil || CompareVersions(v.Version, best.Version) > 0 {
+				best = v
+			}
+		}
+		if best == nil {
+			unresolved.Reason = "no released version matches"
+			lockErr.Unresolved = append(lockErr.Unresolved, unresolved)
+			continue
+		}
+		lockfile.Packages = append(lockfile.Packages, LockedPackage{
+			Name:       requirement.Name,
+			Constraint: requirement.Constraint,
+			Version:    best.Version,
+			Checksum:   best.Checksum,
+			URL:        best.URL,
+		})
+	}
+
+	if len(lockErr.Unresolved) > 0 {
+		sort.Slice(lockErr.Unresolved, func(i, j int) bool {
+			return lockErr.Unresolved[i].Name < lockErr.Unresolved[j].Name
+		})
+		return nil, &lockErr
+	}
+	sort.Slice(lockfile.Packages, func(i, j int) bool {
+		return lockfile.Packages[i].Name < lockfile.Packages[j].Name
+	})
+	return lockfile, nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionConstraint_Matches(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{constraint: "", matches: []string{"1.0.0", "not-a-version"}, rejects: []string{"2.0.0-rc.1"}},
+		{constraint: "*", matches: []string{"0.1.0", "9.9.9"}, rejects: []string{"1.0.0-rc.1"}},
+		{constraint: "1.2", matches: []string{"1.2.0", "1.2"}, rejects: []string{"1.2.1"}},
+		{constraint: "=1.2.3", matches: []string{"1.2.3", "1.2.3+build.1"}, rejects: []string{"1.2.4"}},
+		{constraint: ">=1.2, <2", matches: []string{"1.2.0", "1.9.9"}, rejects: []string{"1.1.9", "2.0.0", "2.0.0-rc.1"}},
+		{constraint: "!=1.5.0", matches: []string{"1.4.0"}, rejects: []string{"1.5.0"}},
+		{constraint: ">1.2.3", matches: []string{"1.2.4"}, rejects: []string{"1.2.3"}},
+		{constraint: "<=2.1", matches: []string{"2.1.0"}, rejects: []string{"2.1.1"}},
+		{constraint: "^1.2.3", matches: []string{"1.2.3", "1.9.0"}, rejects: []string{"1.2.2", "2.0.0", "2.0.0-alpha"}},
+		{constraint: "^0.2.3", matches: []string{"0.2.9"}, rejects: []string{"0.3.0"}},
+		{constraint: "^0.0.3", matches: []string{"0.0.3"}, rejects: []string{"0.0.4"}},
+		{constraint: "~1.2.3", matches: []string{"1.2.9"}, rejects: []string{"1.3.0", "1.2.2"}},
+		{constraint: "~1", matches: []string{"1.9.0"}, rejects: []string{"2.0.0"}},
+		{constraint: "1.2.x", matches: []string{"1.2.0", "1.2.15"}, rejects: []string{"1.3.0", "1.1.9", "1.2.1-rc.1"}},
+		{constraint: "1.*", matches: []string{"1.0.0", "1.99.0"}, rejects: []string{"2.0.0"}},
+		{constraint: ">=2.0.0-rc.1", matches: []string{"2.0.0-rc.2", "2.0.0", "2.1.0"}, rejects: []string{"2.0.0-beta"}},
+		{constraint: ">=1.0", rejects: []string{"not-a-version"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			require.NoError(t, err)
+			for _, v := range tt.matches {
+				assert.True(t, c.Matches(v), "%s should match %s", tt.constraint, v)
+			}
+			for _, v := range tt.rejects {
+				assert.False(t, c.Matches(v), "%s should not match %s", tt.constraint, v)
+			}
+		})
+	}
+}
+
+func TestParseConstraint_Invalid(t *testing.T) {
+	for _, constraint := range []string{"1.0,", ">=", "^1.x", "abc", "1.0+build", "1.2.x-rc", "=>1.0"} {
+		_, err := ParseConstraint(constraint)
+		assert.Error(t, err, constraint)
+	}
+}
+
+func TestLock(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	registry := NewRegistry("tools", "", nil, nil)
+	deployer := NewPackage("deployer", "", nil, nil)
+	for _, v := range []string{"1.0.0", "1.4.0", "2.0.0", "2.1.0-rc.1"} {
+		deployer.Versions[v] = NewVersion("deployer", v, "sha256:"+v, "https://example.com/deployer-"+v+".zip", 0, 9)
+	}
+	embargoed := NewVersion("deployer", "2.2.0", "sha256:2.2.0", "https://example.com/deployer-2.2.0.zip", 0, 9)
+	embargoed.PublishAt = &later
+	deployer.Versions["2.2.0"] = embargoed
+	registry.Packages["deployer"] = deployer
+	linter := NewPackage("linter", "", nil, nil)
+	linter.Versions["0.9.0"] = NewVersion("linter", "0.9.0", "sha256:l", "https://example.com/linter.zip", 0, 4)
+	registry.Packages["linter"] = linter
+
+	t.Run("pins the highest matching released version", func(t *testing.T) {
+		lockfile, err := Lock(registry, &LockRequest{Packages: []LockRequirement{
+			{Name: "linter"},
+			{Name: "deployer", Constraint: "^1.0"},
+		}}, now)
+		require.NoError(t, err)
+		assert.Equal(t, "tools", lockfile.Registry)
+		assert.Equal(t, now, lockfile.GeneratedAt)
+		assert.Equal(t, []LockedPackage{
+			{Name: "deployer", Constraint: "^1.0", Version: "1.4.0", Checksum: "sha256:1.4.0", URL: "https://example.com/deployer-1.4.0.zip"},
+			{Name: "linter", Version: "0.9.0", Checksum: "sha256:l", URL: "https://example.com/linter.zip"},
+		}, lockfile.Packages)
+	})
+
+	t.Run("empty request pins every package", func(t *testing.T) {
+		lockfile, err := Lock(registry, &LockRequest{}, now)
+		require.NoError(t, err)
+		require.Len(t, lockfile.Packages, 2)
+		// Neither the pre-release nor the embargoed version is pinned
+		assert.Equal(t, "2.0.0", lockfile.Packages[0].Version)
+
+		lockfile, err = Lock(registry, &LockRequest{}, later)
+		require.NoError(t, err)
+		assert.Equal(t, "2.2.0", lockfile.Packages[0].Version)
+	})
+
+	t.Run("unresolved packages are all reported", func(t *testing.T) {
+		_, err := Lock(registry, &LockRequest{Packages: []LockRequirement{
+			{Name: "missing"},
+			{Name: "deployer", Constraint: ">=3"},
+			{Name: "linter"},
+		}}, now)
+		var lockErr *LockError
+		require.ErrorAs(t, err, &lockErr)
+		assert.Equal(t, []UnresolvedPackage{
+			{Name: "deployer", Constraint: ">=3", Reason: "no released version matches"},
+			{Name: "missing", Reason: "package not found"},
+		}, lockErr.Unresolved)
+	})
+
+	t.Run("legacy suffixes are not pre-releases", func(t *testing.T) {
+		legacy := NewRegistry("legacy", "", nil, nil)
+		legacy.VersionPolicy = VersionPolicyLegacy
+		pkg := NewPackage("tool", "", nil, nil)
+		for _, v := range []string{"2024.06.01-build5", "2024.05.30"} {
+			pkg.Versions[v] = NewVersion("tool", v, "sha256:"+v, "https://example.com/tool.zip", 0, 9)
+		}
+		legacy.Packages["tool"] = pkg
+
+		lockfile, err := Lock(legacy, &LockRequest{Packages: []LockRequirement{{Name: "tool", Constraint: ">=2024.06"}}}, now)
+		require.NoError(t, err)
+		assert.Equal(t, "2024.06.01-build5", lockfile.Packages[0].Version)
+	})
+}
+
+func TestLockRequest_Validate(t *testing.T) {
+	assert.NoError(t, (&LockRequest{}).Validate())
+	assert.NoError(t, (&LockRequest{Packages: []LockRequirement{{Name: "a", Constraint: "^1"}}}).Validate())
+	assert.Error(t, (&LockRequest{Packages: []LockRequirement{{Name: ""}}}).Validate())
+	assert.Error(t, (&LockRequest{Packages: []LockRequirement{{Name: "a"}, {Name: "a"}}}).Validate())
+	assert.Error(t, (&LockRequest{Packages: []LockRequirement{{Name: "a", Constraint: ">>1"}}}).Validate())
+}
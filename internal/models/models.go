@@ -1,12 +1,50 @@
 package models
 
+import (
+	"strings"
+	"time"
+)
+
+// Version policies control which version strings a registry accepts
+const (
+	VersionPolicySemver = "semver" // Strict semantic versioning (default)
+	VersionPolicyLegacy = "legacy" // Also accepts historical formats like 1.0 or 2024.06.01-build5
+)
+
 // Registry represents a named container for packages
 type Registry struct {
-	Name         string              `json:"name"`
-	Description  string              `json:"description"`
-	Admins       []string            `json:"admins,omitempty"`
-	CustomValues map[string]string   `json:"custom_values,omitempty"`
-	Packages     map[string]*Package `json:"packages"`
+	Name          string              `json:"name"`
+	Description   string              `json:"description"`
+	Admins        []string            `json:"admins,omitempty"`
+	CustomValues  map[string]string   `json:"custom_values,omitempty"`
+	VersionPolicy string              `json:"version_policy,omitempty"` // semver (default) | legacy
+	SensitiveKeys []string            `json:"sensitive_keys,omitempty"` // Custom value keys encrypted at rest and masked in responses
+	AnonymousRead *bool               `json:"anonymous_read,omitempty"` // Overrides server.anonymous_read for this registry
+	Announcements []string            `json:"announcements,omitempty"`  // Short notices shown by launchers and portals
+	CacheTTL      *int                `json:"cache_ttl,omitempty"`      // Seconds clients may cache index.json; overrides cache.index_max_age
+	Aliases       map[string]string   `json:"aliases,omitempty"`        // Old package name -> new name, for renamed packages
+	IndexAliases  bool                `json:"index_aliases,omitempty"`  // Also list aliased packages in index.json under their old name
+	CreatedAt     *time.Time          `json:"created_at,omitempty"`     // Set by storage
+	UpdatedAt     *time.Time          `json:"updated_at,omitempty"`     // Last change to the registry, its packages or their versions
+	Packages      map[string]*Package `json:"packages"`
+}
+
+// AllowsAnonymousRead reports whether the registry may be read without
+// credentials: its own setting if any, serverDefault otherwise
+func (r *Registry) AllowsAnonymousRead(serverDefault bool) bool {
+	if r.AnonymousRead != nil {
+		return *r.AnonymousRead
+	}
+	return serverDefault
+}
+
+// IndexMaxAge returns how long clients may cache the registry index: its
+// own cache_ttl if any, serverDefault otherwise
+func (r *Registry) IndexMaxAge(serverDefault time.Duration) time.Duration {
+	if r.CacheTTL != nil {
+		return time.Duration(*r.CacheTTL) * time.Second
+	}
+	return serverDefault
 }
 
 // Package represents metadata for a command bundle within a registry
@@ -15,6 +53,8 @@ type Package struct {
 	Description  string              `json:"description"`
 	Maintainers  []string            `json:"maintainers,omitempty"`
 	CustomValues map[string]string   `json:"custom_values,omitempty"`
+	CreatedAt    *time.Time          `json:"created_at,omitempty"` // Set by storage
+	UpdatedAt    *time.Time          `json:"updated_at,omitempty"` // Last change to the package or its versions
 	Versions     map[string]*Version `json:"versions"`
 }
 
@@ -26,6 +66,10 @@ type Version struct {
 	URL            string `json:"url"`            // Download URL
 	StartPartition int    `json:"startPartition"` // 0-9
 	EndPartition   int    `json:"endPartition"`   // 0-9
+
+	// PublishAt embargoes the version: it is stored but left out of index.json
+	// until then. Cleared by the scheduler once the version is released.
+	PublishAt *time.Time `json:"publish_at,omitempty"`
 }
 
 // IndexEntry represents an entry in the registry index.json (Command Launcher format)
@@ -40,13 +84,24 @@ type IndexEntry struct {
 
 // Storage is the root storage structure
 type Storage struct {
-	Registries map[string]*Registry `json:"registries"`
+	Registries map[string]*Registry       `json:"registries"`
+	Sync       *SyncState                 `json:"sync,omitempty"`       // Change generations for differential sync
+	History    map[string][]*ChangeRecord `json:"history,omitempty"`    // Package change histories by record key (registry/package)
+	Quarantine []*QuarantinedRecord       `json:"quarantine,omitempty"` // Records set aside because they could not be loaded
+
+	// TLS certificates and ACME account keys obtained by the server, by
+	// name (see Store.GetCertificate); not registry data
+	Certificates map[string][]byte `json:"certificates,omitempty"`
+
+	// API keys restricted to publishing in one registry, by ID
+	APIKeys map[string]*APIKey `json:"api_keys,omitempty"`
 }
 
 // NewStorage creates an empty storage structure
 func NewStorage() *Storage {
 	return &Storage{
 		Registries: make(map[string]*Registry),
+		History:    make(map[string][]*ChangeRecord),
 	}
 }
 
@@ -90,6 +145,11 @@ func NewVersion(name, version, checksum, url string, startPartition, endPartitio
 	}
 }
 
+// IsPending reports whether the version is embargoed at time now
+func (v *Version) IsPending(now time.Time) bool {
+	return v.PublishAt != nil && v.PublishAt.After(now)
+}
+
 // ToIndexEntry converts a Version to an IndexEntry
 func (v *Version) ToIndexEntry() IndexEntry {
 	return IndexEntry{
@@ -101,3 +161,23 @@ func (v *Version) ToIndexEntry() IndexEntry {
 		EndPartition:   v.EndPartition,
 	}
 }
+
+// IsAdmin reports whether a user is listed as an admin of the registry.
+// Entries are compared case-insensitively with the username.
+func (r *Registry) IsAdmin(username string) bool {
+	return containsFold(r.Admins, username)
+}
+
+// IsMaintainer reports whether a user is listed as a maintainer of the package
+func (p *Package) IsMaintainer(username string) bool {
+	return containsFold(p.Maintainers, username)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
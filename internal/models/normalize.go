@@ -0,0 +1,101 @@
+package models
+
+// Normalize brings decoded storage data to the shape the New functions
+// build, so code reading it never meets a nil map or a nil entry: missing
+// maps become empty, null entries are dropped, and records missing their
+// name take it from their map key. It must run after every decode of
+// stored or received data; it is idempotent and accepts nil.
+func Normalize(s *Storage) {
+	if s == nil {
+		return
+	}
+	if s.Registries == nil {
+		s.Registries = make(map[string]*Registry)
+	}
+	for name, registry := range s.Registries {
+		if registry == nil {
+			delete(s.Registries, name)
+			continue
+		}
+		if registry.Name == "" {
+			registry.Name = name
+		}
+		NormalizeRegistry(registry)
+	}
+	if s.History == nil {
+		s.History = make(map[string][]*ChangeRecord)
+	}
+	for key, history := range s.History {
+		kept := history[:0]
+		for _, change := range history {
+			if change != nil {
+				kept = append(kept, change)
+			}
+		}
+		s.History[key] = kept
+	}
+	kept := s.Quarantine[:0]
+	for _, record := range s.Quarantine {
+		if record != nil {
+			kept = append(kept, record)
+		}
+	}
+	s.Quarantine = kept
+	if s.Sync != nil {
+		if s.Sync.Records == nil {
+			s.Sync.Records = make(map[string]uint64)
+		}
+		if s.Sync.Tombstones == nil {
+			s.Sync.Tombstones = make(map[string]uint64)
+		}
+	}
+}
+
+// NormalizeRegistry applies Normalize to a registry and its packages
+func NormalizeRegistry(r *Registry) {
+	if r == nil {
+		return
+	}
+	if r.CustomValues == nil {
+		r.CustomValues = make(map[string]string)
+	}
+	if r.Packages == nil {
+		r.Packages = make(map[string]*Package)
+	}
+	for name, pkg := range r.Packages {
+		if pkg == nil {
+			delete(r.Packages, name)
+			continue
+		}
+		if pkg.Name == "" {
+			pkg.Name = name
+		}
+		NormalizePackage(pkg)
+	}
+}
+
+// NormalizePackage applies Normalize to a package and its versions.
+// Versions also get the package name, which index.json entries carry.
+func NormalizePackage(p *Package) {
+	if p == nil {
+		return
+	}
+	if p.CustomValues == nil {
+		p.CustomValues = make(map[string]string)
+	}
+	if p.Versions == nil {
+		p.Versions = make(map[string]*Version)
+	}
+	for version, v := range p.Versions {
+		if v == nil {
+			delete(p.Versions, version)
+			continue
+		}
+		if v.Name == "" {
+			v.Name = p.Name
+		}
+		if v.Version == "" {
+			v.Version = version
+		}
+	}
+}
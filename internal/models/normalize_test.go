@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize_PartialJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty object", `{}`},
+		{"null registries", `{"registries": null}`},
+		{"null registry", `{"registries": {"tools": null}}`},
+		{"registry without packages", `{"registries": {"tools": {"name": "tools"}}}`},
+		{"null packages", `{"registries": {"tools": {"packages": null}}}`},
+		{"null package", `{"registries": {"tools": {"packages": {"hello": null}}}}`},
+		{"package without versions", `{"registries": {"tools": {"packages": {"hello": {}}}}}`},
+		{"null version", `{"registries": {"tools": {"packages": {"hello": {"versions": {"1.0.0": null}}}}}}`},
+		{"version without names", `{"registries": {"tools": {"packages": {"hello": {"versions": {"1.0.0": {"checksum": "sha256:x"}}}}}}}`},
+		{"sync without maps", `{"registries": {}, "sync": {"generation": 3}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data Storage
+			require.NoError(t, json.Unmarshal([]byte(tt.data), &data))
+			Normalize(&data)
+			assertNormalized(t, &data)
+		})
+	}
+}
+
+func TestNormalize_Defaults(t *testing.T) {
+	var data Storage
+	require.NoError(t, json.Unmarshal([]byte(`{"registries": {"tools": {"packages": {"hello": {"versions": {"1.0.0": {}, "2.0.0": null}}, "bye": null}}}}`), &data))
+	Normalize(&data)
+
+	registry := data.Registries["tools"]
+	require.NotNil(t, registry)
+	assert.Equal(t, "tools", registry.Name)
+	assert.Len(t, registry.Packages, 1)
+
+	pkg := registry.Packages["hello"]
+	require.NotNil(t, pkg)
+	assert.Equal(t, "hello", pkg.Name)
+	require.Len(t, pkg.Versions, 1)
+	assert.Equal(t, "hello", pkg.Versions["1.0.0"].Name)
+	assert.Equal(t, "1.0.0", pkg.Versions["1.0.0"].Version)
+}
+
+func TestNormalize_KeepsNames(t *testing.T) {
+	// Names that disagree with their keys are left for validation to report
+	registry := &Registry{Name: "other", Packages: map[string]*Package{"hello": {Name: "renamed"}}}
+	data := &Storage{Registries: map[string]*Registry{"tools": registry}}
+	Normalize(data)
+	assert.Equal(t, "other", registry.Name)
+	assert.Equal(t, "renamed", registry.Packages["hello"].Name)
+}
+
+func TestNormalize_Nil(t *testing.T) {
+	assert.NotPanics(t, func() {
+		Normalize(nil)
+		NormalizeRegistry(nil)
+		NormalizePackage(nil)
+	})
+}
+
+func FuzzNormalize(f *testing.F) {
+	f.Add(`{}`)
+	f.Add(`{"registries": {"tools": null}}`)
+	f.Add(`{"registries": {"tools": {"packages": {"hello": {"versions": {"1.0.0": null}}}}}}`)
+	f.Add(`{"registries": {"tools": {"custom_values": null, "packages": {"hello": {"custom_values": {}}}}}, "sync": {}}`)
+	f.Fuzz(func(t *testing.T, raw string) {
+		var data Storage
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return
+		}
+		Normalize(&data)
+		assertNormalized(t, &data)
+
+		// Normalizing again changes nothing
+		before, err := json.Marshal(&data)
+		require.NoError(t, err)
+		Normalize(&data)
+		after, err := json.Marshal(&data)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(before), string(after))
+	})
+}
+
+// assertNormalized checks that every map can be read and written
+func assertNormalized(t *testing.T, data *Storage) {
+	t.Helper()
+	require.NotNil(t, data.Registries)
+	for name, registry := range data.Registries {
+		require.NotNil(t, registry, "registry %s", name)
+		require.True(t, registry.Name != "" || name == "", "registry %s has no name", name)
+		require.NotNil(t, registry.CustomValues)
+		require.NotNil(t, registry.Packages)
+		for name, pkg := range registry.Packages {
+			require.NotNil(t, pkg, "package %s", name)
+			require.True(t, pkg.Name != "" || name == "", "package %s has no name", name)
+			require.NotNil(t, pkg.CustomValues)
+			require.NotNil(t, pkg.Versions)
+			for version, v := range pkg.Versions {
+				require.NotNil(t, v, "version %s", version)
+				require.True(t, v.Name != "" || pkg.Name == "", "version %s has no package name", version)
+				require.True(t, v.Version != "" || version == "", "version %s has no version", version)
+			}
+		}
+	}
+	if data.Sync != nil {
+		require.NotNil(t, data.Sync.Records)
+		require.NotNil(t, data.Sync.Tombstones)
+	}
+}
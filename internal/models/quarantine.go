@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// QuarantinedRecord is a stored registry, package or version that could not
+// be loaded, typically after a hand edit. It is set aside with the reason it
+// was rejected, so the rest of the data stays available and the record can
+// be fixed and recreated through the API.
+type QuarantinedRecord struct {
+	Key           string          `json:"key"`            // Record key (registry/package/version)
+	Type          string          `json:"type"`           // registry, package or version
+	Reason        string          `json:"reason"`         // Why the record was rejected
+	Data          json.RawMessage `json:"data,omitempty"` // The record as stored, when it could be read
+	QuarantinedAt time.Time       `json:"quarantined_at"`
+}
+
+// QuarantineCounts counts quarantined records by type
+type QuarantineCounts struct {
+	Registries int `json:"registries"`
+	Packages   int `json:"packages"`
+	Versions   int `json:"versions"`
+}
+
+// CountQuarantined counts records by type
+func CountQuarantined(records []*QuarantinedRecord) QuarantineCounts {
+	var counts QuarantineCounts
+	for _, record := range records {
+		switch record.Type {
+		case SyncTypeRegistry:
+			counts.Registries++
+		case SyncTypePackage:
+			counts.Packages++
+		default:
+			counts.Versions++
+		}
+	}
+	return counts
+}
+
+// NewQuarantinedRecord builds the quarantine entry of a decoded record
+// rejected for err
+func NewQuarantinedRecord(key string, record interface{}, err error) *QuarantinedRecord {
+	recordType, _, _, _ := SplitRecordKey(key)
+	q := &QuarantinedRecord{Key: key, Type: recordType, Reason: err.Error()}
+	if data, marshalErr := json.Marshal(record); marshalErr == nil {
+		q.Data = data
+	}
+	return q
+}
+
+// QuarantineInvalid moves the records of normalized storage that the
+// registry cannot serve out of s.Registries and returns them: registries
+// and packages with an invalid name, and versions with an unparsable
+// version, checksum, URL or partition range. Versions are checked against
+// the lenient legacy policy, since a registry's policy may have been
+// tightened after they were published.
+func QuarantineInvalid(s *Storage) []*QuarantinedRecord {
+	var quarantined []*QuarantinedRecord
+	for registryName, registry := range s.Registries {
+		if err := ValidateName(registryName); err != nil {
+			quarantined = append(quarantined, NewQuarantinedRecord(RecordKey(registryName), registry, err))
+			delete(s.Registries, registryName)
+			continue
+		}
+		for packageName, pkg := range registry.Packages {
+			if err := ValidateName(packageName); err != nil {
+				quarantined = append(quarantined, NewQuarantinedRecord(RecordKey(registryName, packageName), pkg, err))
+				delete(registry.Packages, packageName)
+				continue
+			}
+			for version, v := range pkg.Versions {
+				err := ValidateVersionDataWithPolicy(v, VersionPolicyLegacy)
+				if err == nil && v.Version != version {
+					err = &ValidationError{Field: "version", Message: "version does not match its key '" + version + "'"}
+				}
+				if err != nil {
+					quarantined = append(quarantined, NewQuarantinedRecord(RecordKey(registryName, packageName, version), v, err))
+					delete(pkg.Versions, version)
+				}
+			}
+		}
+	}
+	return quarantined
+}
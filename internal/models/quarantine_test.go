@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantineInvalid(t *testing.T) {
+	checksum := "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	s := NewStorage()
+	registry := NewRegistry("tools", "", nil, nil)
+	registry.VersionPolicy = VersionPolicySemver
+	pkg := NewPackage("deployer", "", nil, nil)
+	pkg.Versions["1.0.0"] = NewVersion("deployer", "1.0.0", checksum, "https://e/1.zip", 0, 9)
+	// Legacy versions stay even if the registry policy was tightened since
+	pkg.Versions["1.0"] = NewVersion("deployer", "1.0", checksum, "https://e/1.zip", 0, 9)
+	pkg.Versions["2.0.0"] = NewVersion("deployer", "2.0.0", checksum, "https://e/2.zip", 5, 2)
+	pkg.Versions["3.0.0"] = NewVersion("deployer", "3.0.1", checksum, "https://e/3.zip", 0, 9)
+	registry.Packages["deployer"] = pkg
+	registry.Packages["-bad"] = NewPackage("-bad", "", nil, nil)
+	s.Registries["tools"] = registry
+
+	quarantined := QuarantineInvalid(s)
+	require.Len(t, quarantined, 3)
+	assert.ElementsMatch(t, []string{"1.0.0", "1.0"}, keysOf(pkg.Versions))
+	assert.NotContains(t, registry.Packages, "-bad")
+	assert.Equal(t, QuarantineCounts{Packages: 1, Versions: 2}, CountQuarantined(quarantined))
+	for _, record := range quarantined {
+		assert.NotEmpty(t, record.Data)
+	}
+
+	assert.Empty(t, QuarantineInvalid(s))
+}
+
+func keysOf(versions map[string]*Version) []string {
+	keys := make([]string, 0, len(versions))
+	for key := range versions {
+		keys = append(keys, key)
+	}
+	return keys
+}
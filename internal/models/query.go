@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// CustomQueryPrefix prefixes query parameters that filter on custom values
+// (e.g. ?custom.team=payments)
+const CustomQueryPrefix = "custom."
+
+// maxCustomQueryKeys bounds the number of keys in a query, matching the
+// number of custom values a package may hold
+const maxCustomQueryKeys = 20
+
+// CustomValueQuery filters packages by custom values.
+// A package matches when, for every key, its value equals one of the listed
+// values (AND across keys, OR within a key).
+type CustomValueQuery map[string][]string
+
+// ParseCustomValueQuery extracts custom.<key>=<value> parameters from a URL query.
+// Other parameters are ignored. Returns an empty query when none are present.
+func ParseCustomValueQuery(params url.Values) (CustomValueQuery, error) {
+	query := make(CustomValueQuery)
+	for param, values := range params {
+		if !strings.HasPrefix(param, CustomQueryPrefix) {
+			continue
+		}
+		key := strings.TrimPrefix(param, CustomQueryPrefix)
+		if !customKeyPattern.MatchString(key) {
+			return nil, &ValidationError{
+				Field:   param,
+				Message: fmt.Sprintf("custom value key '%s' must match pattern ^[a-zA-Z_][a-zA-Z0-9_-]{0,63}$", key),
+			}
+		}
+		query[key] = append(query[key], values...)
+	}
+
+	if len(query) > maxCustomQueryKeys {
+		return nil, &ValidationError{
+			Field:   "custom",
+			Message: fmt.Sprintf("at most %d custom value keys can be queried", maxCustomQueryKeys),
+		}
+	}
+	return query, nil
+}
+
+// Keys returns the queried keys in sorted order
+func (q CustomValueQuery) Keys() []string {
+	keys := make([]string, 0, len(q))
+	for key := range q {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
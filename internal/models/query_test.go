@@ -0,0 +1,22 @@
+package models
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCustomValueQuery(t *testing.T) {
+	params, err := url.ParseQuery("custom.team=payments&custom.tier=1&custom.tier=2&sort=semver_asc")
+	require.NoError(t, err)
+
+	query, err := ParseCustomValueQuery(params)
+	require.NoError(t, err)
+	assert.Equal(t, CustomValueQuery{"team": {"payments"}, "tier": {"1", "2"}}, query)
+	assert.Equal(t, []string{"team", "tier"}, query.Keys())
+
+	_, err = ParseCustomValueQuery(url.Values{"custom.bad key": {"x"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package models
+
+import "sort"
+
+// SchemaDialect is the JSON Schema draft the published schemas follow
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaBuilders build the published JSON Schemas from the same patterns and
+// limits as the Validate functions, so the two cannot drift apart. The
+// server remains authoritative: rules spanning several fields (partition
+// order, the registry's version policy) are only checked on submission.
+var schemaBuilders = map[string]func() map[string]interface{}{
+	"registry":    registrySchema,
+	"package":     packageSchema,
+	"version":     versionSchema,
+	"index-entry": indexEntrySchema,
+	"index": func() map[string]interface{} {
+		return map[string]interface{}{
+			"title":       "Index",
+			"description": "Registry index.json, as read by Command Launcher",
+			"type":        "array",
+			"items":       indexEntrySchema(),
+		}
+	},
+}
+
+// SchemaNames returns the names of the published JSON Schemas, sorted
+func SchemaNames() []string {
+	names := make([]string, 0, len(schemaBuilders))
+	for name := range schemaBuilders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Schema returns the JSON Schema of an API payload by name, or false if
+// there is no such schema. A new map is built on every call.
+func Schema(name string) (map[string]interface{}, bool) {
+	build, ok := schemaBuilders[name]
+	if !ok {
+		return nil, false
+	}
+	schema := build()
+	schema["$schema"] = SchemaDialect
+	return schema, true
+}
+
+func registrySchema() map[string]interface{} {
+	return map[string]interface{}{
+		"title":    "Registry",
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]interface{}{
+			"name":           nameSchema(),
+			"description":    descriptionSchema(),
+			"admins":         stringListSchema("Admin usernames or emails"),
+			"custom_values":  customValuesSchema(),
+			"version_policy": map[string]interface{}{"type": "string", "enum": []string{VersionPolicySemver, VersionPolicyLegacy}},
+			"sensitive_keys": map[string]interface{}{
+				"type":        "array",
+				"description": "Custom value keys encrypted at rest and masked in responses",
+				"maxItems":    20,
+				"uniqueItems": true,
+				"items":       map[string]interface{}{"type": "string", "pattern": customKeyPattern.String()},
+			},
+			"anonymous_read": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Whether the registry can be read without credentials; the server's anonymous_read setting applies when absent",
+			},
+			"announcements": map[string]interface{}{
+				"type":        "array",
+				"description": "Short notices shown by launchers and portals",
+				"maxItems":    MaxAnnouncements,
+				"items":       map[string]interface{}{"type": "string", "minLength": 1, "maxLength": MaxAnnouncementLength},
+			},
+			"cache_ttl": map[string]interface{}{
+				"type":        "integer",
+				"description": "Seconds clients may cache index.json; the server's cache.index_max_age applies when absent",
+				"minimum":     0,
+				"maximum":     MaxCacheTTL,
+			},
+			"aliases": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Old package names mapped to the packages they were renamed to",
+				"maxProperties":        MaxAliases,
+				"propertyNames":        map[string]interface{}{"pattern": namePattern.String()},
+				"additionalProperties": map[string]interface{}{"type": "string", "pattern": namePattern.String()},
+			},
+			"index_aliases": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Whether index.json also lists aliased packages under their old name",
+			},
+			"packages": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Packages by name (responses only)",
+				"additionalProperties": packageSchema(),
+			},
+		},
+	}
+}
+
+func packageSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"title":    "Package",
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]interface{}{
+			"name":          nameSchema(),
+			"description":   descriptionSchema(),
+			"maintainers":   stringListSchema("Maintainer usernames or emails"),
+			"custom_values": customValuesSchema(),
+			"versions": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Versions by version string (responses only)",
+				"additionalProperties": versionSchema(),
+			},
+		},
+	}
+}
+
+func versionSchema() map[string]interface{} {
+	schema := indexEntrySchema()
+	schema["title"] = "Version"
+	schema["description"] = "startPartition must not exceed endPartition; this is checked by the server"
+	schema["properties"].(map[string]interface{})["publish_at"] = map[string]interface{}{
+		"type":        "string",
+		"format":      "date-time",
+		"description": "Embargo the version until this time",
+	}
+	return schema
+}
+
+func indexEntrySchema() map[string]interface{} {
+	partition := map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 9}
+	return map[string]interface{}{
+		"title":    "IndexEntry",
+		"type":     "object",
+		"required": []string{"name", "version", "checksum", "url"},
+		"properties": map[string]interface{}{
+			"name": nameSchema(),
+			"version": map[string]interface{}{
+				"type":        "string",
+				"maxLength":   64,
+				"description": "Semantic version; registries with the legacy version policy also accept formats like 1.0",
+				"anyOf": []interface{}{
+					map[string]interface{}{"pattern": versionPattern.String()},
+					map[string]interface{}{"pattern": legacyVersionPattern.String()},
+				},
+			},
+			"checksum":       map[string]interface{}{"type": "string", "pattern": checksumPattern.String()},
+			"url":            map[string]interface{}{"type": "string", "maxLength": 2048, "pattern": "^https?://"},
+			"startPartition": partition,
+			"endPartition":   partition,
+		},
+	}
+}
+
+func nameSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "minLength": 1, "maxLength": 64, "pattern": namePattern.String()}
+}
+
+func descriptionSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "maxLength": 4096}
+}
+
+func stringListSchema(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"description": description,
+		"items":       map[string]interface{}{"type": "string"},
+	}
+}
+
+func customValuesSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"maxProperties":        20,
+		"propertyNames":        map[string]interface{}{"pattern": customKeyPattern.String()},
+		"additionalProperties": map[string]interface{}{"type": "string", "maxLength": 1024},
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compileTestSchema(t *testing.T, name string) *jsonschema.Schema {
+	t.Helper()
+	schema, ok := Schema(name)
+	require.True(t, ok)
+
+	// Compile the schema as served, from its JSON encoding
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(string(data)))
+	require.NoError(t, err)
+
+	c := jsonschema.NewCompiler()
+	require.NoError(t, c.AddResource(name+".json", doc))
+	compiled, err := c.Compile(name + ".json")
+	require.NoError(t, err)
+	return compiled
+}
+
+func validateAgainstSchema(t *testing.T, schema *jsonschema.Schema, doc string) error {
+	t.Helper()
+	inst, err := jsonschema.UnmarshalJSON(strings.NewReader(doc))
+	require.NoError(t, err)
+	return schema.Validate(inst)
+}
+
+func TestSchemasCompile(t *testing.T) {
+	assert.Equal(t, []string{"index", "index-entry", "package", "registry", "version"}, SchemaNames())
+	for _, name := range SchemaNames() {
+		compileTestSchema(t, name)
+	}
+	_, ok := Schema("missing")
+	assert.False(t, ok)
+}
+
+// The schemas must accept and reject the same payloads as the validators
+func TestSchemasMatchValidation(t *testing.T) {
+	pkgSchema := compileTestSchema(t, "package")
+	for _, doc := range []string{
+		`{"name": "deploy", "maintainers": ["alice"], "custom_values": {"team": "platform"}}`,
+		`{"name": "Deploy"}`,
+		`{"name": ""}`,
+		`{"name": "deploy", "custom_values": {"bad key": "x"}}`,
+		`{"name": "deploy", "description": "` + strings.Repeat("x", 4097) + `"}`,
+	} {
+		var pkg Package
+		require.NoError(t, json.Unmarshal([]byte(doc), &pkg))
+		assert.Equal(t, ValidatePackage(&pkg) == nil, validateAgainstSchema(t, pkgSchema, doc) == nil, doc)
+	}
+
+	versionSchema := compileTestSchema(t, "version")
+	checksum := "sha256:" + strings.Repeat("a", 64)
+	for _, doc := range []string{
+		`{"name": "deploy", "version": "1.2.3", "checksum": "` + checksum + `", "url": "https://example.com/a.zip", "startPartition": 0, "endPartition": 9}`,
+		`{"name": "deploy", "version": "1.2.3", "checksum": "sha256:xyz", "url": "https://example.com/a.zip"}`,
+		`{"name": "deploy", "version": "1.2.3", "checksum": "sha512:` + strings.Repeat("b", 128) + `", "url": "https://example.com/a.zip"}`,
+		`{"name": "deploy", "version": "1.2.3", "checksum": "sha512:` + strings.Repeat("b", 64) + `", "url": "https://example.com/a.zip"}`,
+		`{"name": "deploy", "version": "1.2.3", "checksum": "blake3:` + strings.Repeat("c", 64) + `", "url": "https://example.com/a.zip"}`,
+		`{"name": "deploy", "version": "1.2.3", "checksum": "md5:` + strings.Repeat("d", 32) + `", "url": "https://example.com/a.zip"}`,
+		`{"name": "deploy", "version": "1.2.3", "checksum": "` + checksum + `", "url": "ftp://example.com/a.zip"}`,
+		`{"name": "deploy", "version": "1.2.3", "checksum": "` + checksum + `", "url": "https://example.com/a.zip", "endPartition": 10}`,
+		`{"name": "deploy", "version": "v1", "checksum": "` + checksum + `", "url": "https://example.com/a.zip"}`,
+	} {
+		var v Version
+		require.NoError(t, json.Unmarshal([]byte(doc), &v))
+		assert.Equal(t, ValidateVersionDataWithPolicy(&v, VersionPolicyLegacy) == nil, validateAgainstSchema(t, versionSchema, doc) == nil, doc)
+	}
+}
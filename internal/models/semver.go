@@ -0,0 +1,165 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParsedVersion is a version string broken down into its orderable parts.
+// Both strict semantic versions and legacy version strings (e.g. "1.0",
+// "2024.06.01-build5") parse into this form, so they share ordering rules.
+type ParsedVersion struct {
+	Numbers    []uint64 // Numeric release components (major, minor, patch, ...)
+	PreRelease []string // Dot-separated pre-release identifiers (empty for releases)
+	Build      string   // Build metadata (ignored for ordering)
+}
+
+// ParseVersion parses a version string into its numeric components,
+// pre-release identifiers and build metadata.
+// It does not enforce a policy; use ValidateVersionWithPolicy for that.
+func ParseVersion(version string) (*ParsedVersion, error) {
+	if version == "" {
+		return nil, fmt.Errorf("version is empty")
+	}
+
+	parsed := &ParsedVersion{}
+
+	// Split off build metadata first ("+..." never takes part in ordering)
+	if idx := strings.Index(version, "+"); idx >= 0 {
+		parsed.Build = version[idx+1:]
+		version = version[:idx]
+	}
+
+	// Split off pre-release ("-..." after the numeric part)
+	release := version
+	if idx := strings.Index(version, "-"); idx >= 0 {
+		release = version[:idx]
+		pre := version[idx+1:]
+		if pre == "" {
+			return nil, fmt.Errorf("empty pre-release in version %q", version)
+		}
+		parsed.PreRelease = strings.Split(pre, ".")
+	}
+
+	for _, part := range strings.Split(release, ".") {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid numeric component %q in version %q", part, version)
+		}
+		parsed.Numbers = append(parsed.Numbers, n)
+	}
+
+	return parsed, nil
+}
+
+// Compare compares two parsed versions and returns -1, 0 or 1.
+// Missing numeric components count as zero, so "1.0" equals "1.0.0".
+// A release sorts after any of its pre-releases, and pre-release identifiers
+// follow semver precedence (numeric < alphanumeric, numeric compared by value).
+func (v *ParsedVersion) Compare(other *ParsedVersion) int {
+	n := len(v.Numbers)
+	if len(other.Numbers) > n {
+		n = len(other.Numbers)
+	}
+	for i := 0; i < n; i++ {
+		var a, b uint64
+		if i < len(v.Numbers) {
+			a = v.Numbers[i]
+		}
+		if i < len(other.Numbers) {
+			b = other.Numbers[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// A version without pre-release has higher precedence
+	switch {
+	case len(v.PreRelease) == 0 && len(other.PreRelease) == 0:
+		return 0
+	case len(v.PreRelease) == 0:
+		return 1
+	case len(other.PreRelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.PreRelease) && i < len(other.PreRelease); i++ {
+		if c := compareIdentifiers(v.PreRelease[i], other.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	// All shared identifiers equal: the shorter set has lower precedence
+	switch {
+	case len(v.PreRelease) < len(other.PreRelease):
+		return -1
+	case len(v.PreRelease) > len(other.PreRelease):
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifiers compares two pre-release identifiers using semver rules
+func compareIdentifiers(a, b string) int {
+	aNum, aErr := strconv.ParseUint(a, 10, 64)
+	bNum, bErr := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		if aNum < bNum {
+			return -1
+		}
+		if aNum > bNum {
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		// Numeric identifiers have lower precedence than alphanumeric ones
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// CompareVersions compares two version strings and returns -1, 0 or 1.
+// Versions that cannot be parsed sort before parseable ones and are
+// compared lexically among themselves, so ordering is always total.
+func CompareVersions(a, b string) int {
+	pa, errA := ParseVersion(a)
+	pb, errB := ParseVersion(b)
+
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+
+	if c := pa.Compare(pb); c != 0 {
+		return c
+	}
+	// Equal precedence (e.g. "1.0" vs "1.0.0" or differing build metadata):
+	// fall back to the raw string so ordering stays deterministic
+	return strings.Compare(a, b)
+}
+
+// SortVersions sorts versions in place by version precedence.
+// When descending is true the newest version comes first.
+func SortVersions(versions []*Version, descending bool) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		c := CompareVersions(versions[i].Version, versions[j].Version)
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
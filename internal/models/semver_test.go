@@ -0,0 +1,108 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateVersionWithPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		policy    string
+		wantError bool
+	}{
+		{name: "semver accepted by default", version: "1.2.3", policy: "", wantError: false},
+		{name: "semver pre-release", version: "2.1.3-alpha.1", policy: VersionPolicySemver, wantError: false},
+		{name: "two components rejected by semver", version: "1.0", policy: VersionPolicySemver, wantError: true},
+		{name: "date build rejected by semver", version: "2024.06.01-build5", policy: "", wantError: true},
+		{name: "two components accepted by legacy", version: "1.0", policy: VersionPolicyLegacy, wantError: false},
+		{name: "date build accepted by legacy", version: "2024.06.01-build5", policy: VersionPolicyLegacy, wantError: false},
+		{name: "four components accepted by legacy", version: "1.2.3.4", policy: VersionPolicyLegacy, wantError: false},
+		{name: "semver accepted by legacy", version: "1.2.3+build.7", policy: VersionPolicyLegacy, wantError: false},
+		{name: "leading v rejected by legacy", version: "v1.0", policy: VersionPolicyLegacy, wantError: true},
+		{name: "empty rejected by legacy", version: "", policy: VersionPolicyLegacy, wantError: true},
+		{name: "trailing dot rejected by legacy", version: "1.", policy: VersionPolicyLegacy, wantError: true},
+		{name: "path characters rejected by legacy", version: "1.0/evil", policy: VersionPolicyLegacy, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateVersionWithPolicy(tt.version, tt.policy)
+			if tt.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateVersionPolicy(t *testing.T) {
+	assert.NoError(t, ValidateVersionPolicy(""))
+	assert.NoError(t, ValidateVersionPolicy(VersionPolicySemver))
+	assert.NoError(t, ValidateVersionPolicy(VersionPolicyLegacy))
+	assert.Error(t, ValidateVersionPolicy("calver"))
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "2.0.0", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta", 1},
+		{"1.2.3", "1.2.3", 0},
+		// Legacy normalization: missing components count as zero
+		{"1.0", "1.0.1", -1},
+		{"1.1", "1.0.9", 1},
+		{"2024.06.01", "2024.6.2", -1},
+		{"2024.06.01-build5", "2024.06.01", -1},
+		{"1.2.3.4", "1.2.3", 1},
+		// Unparseable versions sort first
+		{"garbage", "0.0.1", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_vs_"+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CompareVersions(tt.a, tt.b))
+			assert.Equal(t, -tt.expected, CompareVersions(tt.b, tt.a))
+		})
+	}
+}
+
+func TestCompareVersions_EqualPrecedenceIsDeterministic(t *testing.T) {
+	// "1.0" and "1.0.0" have equal precedence but must still order stably
+	assert.NotEqual(t, 0, CompareVersions("1.0", "1.0.0"))
+	assert.Equal(t, -CompareVersions("1.0", "1.0.0"), CompareVersions("1.0.0", "1.0"))
+}
+
+func TestSortVersions(t *testing.T) {
+	versions := []*Version{
+		{Version: "1.10.0"},
+		{Version: "1.2.0"},
+		{Version: "1.2.0-rc.1"},
+		{Version: "2.0.0"},
+		{Version: "1.9.3"},
+	}
+
+	SortVersions(versions, false)
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.Version)
+	}
+	assert.Equal(t, []string{"1.2.0-rc.1", "1.2.0", "1.9.3", "1.10.0", "2.0.0"}, got)
+
+	SortVersions(versions, true)
+	got = got[:0]
+	for _, v := range versions {
+		got = append(got, v.Version)
+	}
+	assert.Equal(t, []string{"2.0.0", "1.10.0", "1.9.3", "1.2.0", "1.2.0-rc.1"}, got)
+}
@@ -0,0 +1,101 @@
+package models
+
+import "fmt"
+
+// MaskedValue replaces sensitive custom values in API responses for non-admin callers
+const MaskedValue = "********"
+
+// IsSensitiveKey reports whether a custom value key is marked sensitive on the registry
+func (r *Registry) IsSensitiveKey(key string) bool {
+	for _, k := range r.SensitiveKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSensitiveKeys validates the sensitive_keys list of a registry
+func ValidateSensitiveKeys(keys []string) error {
+	if len(keys) > 20 {
+		return &ValidationError{
+			Field:   "sensitive_keys",
+			Message: "sensitive_keys must contain at most 20 keys",
+		}
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !customKeyPattern.MatchString(key) {
+			return &ValidationError{
+				Field:   "sensitive_keys",
+				Message: fmt.Sprintf("sensitive_keys entry '%s' must match pattern ^[a-zA-Z_][a-zA-Z0-9_-]{0,63}$", key),
+			}
+		}
+		if seen[key] {
+			return &ValidationError{
+				Field:   "sensitive_keys",
+				Message: fmt.Sprintf("sensitive_keys entry '%s' is duplicated", key),
+			}
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// MaskCustomValues returns a copy of values with every sensitive key masked
+func MaskCustomValues(values map[string]string, sensitiveKeys []string) map[string]string {
+	if values == nil {
+		return nil
+	}
+
+	masked := make(map[string]string, len(values))
+	for k, v := range values {
+		masked[k] = v
+	}
+	for _, key := range sensitiveKeys {
+		if _, ok := masked[key]; ok {
+			masked[key] = MaskedValue
+		}
+	}
+	return masked
+}
+
+// RestoreMaskedValues replaces sensitive values that were sent back as
+// MaskedValue (e.g. after a GET-modify-PUT) with their existing values,
+// so clients that never saw the secret cannot overwrite it by accident.
+func RestoreMaskedValues(incoming, existing map[string]string, sensitiveKeys []string) {
+	for _, key := range sensitiveKeys {
+		if incoming[key] != MaskedValue {
+			continue
+		}
+		if value, ok := existing[key]; ok {
+			incoming[key] = value
+		} else {
+			delete(incoming, key)
+		}
+	}
+}
+
+// Masked returns a copy of the registry with sensitive custom values masked,
+// including those of its packages. Versions are shared, not copied.
+func (r *Registry) Masked() *Registry {
+	masked := *r
+	masked.CustomValues = MaskCustomValues(r.CustomValues, r.SensitiveKeys)
+
+	if r.Packages != nil {
+		masked.Packages = make(map[string]*Package, len(r.Packages))
+		for name, pkg := range r.Packages {
+			masked.Packages[name] = pkg.Masked(r.SensitiveKeys)
+		}
+	}
+	return &masked
+}
+
+// Masked returns a copy of the package with the given sensitive custom values masked
+func (p *Package) Masked(sensitiveKeys []string) *Package {
+	masked := *p
+	masked.CustomValues = MaskCustomValues(p.CustomValues, sensitiveKeys)
+	return &masked
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryMasked(t *testing.T) {
+	registry := NewRegistry("tools", "", nil, map[string]string{"api_key": "secret", "team": "build"})
+	registry.SensitiveKeys = []string{"api_key"}
+	registry.Packages["deployer"] = NewPackage("deployer", "", nil, map[string]string{"api_key": "pkg-secret"})
+
+	masked := registry.Masked()
+	assert.Equal(t, MaskedValue, masked.CustomValues["api_key"])
+	assert.Equal(t, "build", masked.CustomValues["team"])
+	assert.Equal(t, MaskedValue, masked.Packages["deployer"].CustomValues["api_key"])
+
+	// Original is untouched
+	assert.Equal(t, "secret", registry.CustomValues["api_key"])
+	assert.Equal(t, "pkg-secret", registry.Packages["deployer"].CustomValues["api_key"])
+}
+
+func TestRestoreMaskedValues(t *testing.T) {
+	existing := map[string]string{"api_key": "secret"}
+	incoming := map[string]string{"api_key": MaskedValue, "token": MaskedValue, "team": "build"}
+
+	RestoreMaskedValues(incoming, existing, []string{"api_key", "token"})
+	assert.Equal(t, map[string]string{"api_key": "secret", "team": "build"}, incoming)
+}
+
+func TestValidateCustomValues_RejectsCiphertext(t *testing.T) {
+	assert.NoError(t, ValidateCustomValues(map[string]string{"api_key": "secret", "note": "see enc:v1:"}))
+
+	// A value looking encrypted would be stored in plaintext and then taken
+	// for ciphertext
+	err := ValidateCustomValues(map[string]string{"api_key": "enc:v1:c2VjcmV0"})
+	var verr *ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Error(t, ValidatePackage(NewPackage("deployer", "", nil, map[string]string{"api_key": "enc:v1:"})))
+	assert.Error(t, ValidateRegistry(NewRegistry("tools", "", nil, map[string]string{"api_key": "enc:v1:"})))
+	assert.Error(t, PackagePatch{SetCustomValues: map[string]string{"api_key": "enc:v1:"}}.Validate())
+}
+
+func TestValidateSensitiveKeys(t *testing.T) {
+	assert.NoError(t, ValidateSensitiveKeys(nil))
+	assert.NoError(t, ValidateSensitiveKeys([]string{"api_key", "internal-url"}))
+	assert.Error(t, ValidateSensitiveKeys([]string{"1bad"}))
+	assert.Error(t, ValidateSensitiveKeys([]string{"dup", "dup"}))
+}
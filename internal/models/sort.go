@@ -0,0 +1,70 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fields registries and packages can be sorted by
+const (
+	SortByName      = "name"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+)
+
+// sortKey holds what a registry or package is sorted on
+type sortKey struct {
+	name      string
+	createdAt *time.Time
+	updatedAt *time.Time
+}
+
+// compare orders keys by field, then by name. Records without timestamps,
+// written before they were tracked, come first as the oldest.
+func (k sortKey) compare(other sortKey, field string) int {
+	var c int
+	switch field {
+	case SortByCreatedAt:
+		c = timeOrZero(k.createdAt).Compare(timeOrZero(other.createdAt))
+	case SortByUpdatedAt:
+		c = timeOrZero(k.updatedAt).Compare(timeOrZero(other.updatedAt))
+	}
+	if c == 0 {
+		c = strings.Compare(k.name, other.name)
+	}
+	return c
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func sortRecords(records interface{}, key func(i int) sortKey, field string, descending bool) {
+	sort.SliceStable(records, func(i, j int) bool {
+		c := key(i).compare(key(j), field)
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// SortRegistries sorts registries in place by field (name, created_at or
+// updated_at), ties broken by name. When descending is true the last name
+// or the newest timestamp comes first.
+func SortRegistries(registries []*Registry, field string, descending bool) {
+	sortRecords(registries, func(i int) sortKey {
+		return sortKey{registries[i].Name, registries[i].CreatedAt, registries[i].UpdatedAt}
+	}, field, descending)
+}
+
+// SortPackages sorts packages in place like SortRegistries
+func SortPackages(packages []*Package, field string, descending bool) {
+	sortRecords(packages, func(i int) sortKey {
+		return sortKey{packages[i].Name, packages[i].CreatedAt, packages[i].UpdatedAt}
+	}, field, descending)
+}
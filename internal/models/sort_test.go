@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortPackages(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	names := func(packages []*Package) []string {
+		var out []string
+		for _, p := range packages {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+	packages := []*Package{
+		{Name: "b", CreatedAt: day(1), UpdatedAt: day(5)},
+		{Name: "legacy"}, // Written before timestamps were tracked
+		{Name: "a", CreatedAt: day(2), UpdatedAt: day(3)},
+		{Name: "c", CreatedAt: day(2), UpdatedAt: day(4)},
+	}
+
+	SortPackages(packages, SortByName, false)
+	assert.Equal(t, []string{"a", "b", "c", "legacy"}, names(packages))
+
+	SortPackages(packages, SortByCreatedAt, false)
+	assert.Equal(t, []string{"legacy", "b", "a", "c"}, names(packages))
+
+	SortPackages(packages, SortByUpdatedAt, true)
+	assert.Equal(t, []string{"b", "c", "a", "legacy"}, names(packages))
+}
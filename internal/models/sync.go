@@ -0,0 +1,158 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Record types in the differential sync stream
+const (
+	SyncTypeRegistry = "registry"
+	SyncTypePackage  = "package"
+	SyncTypeVersion  = "version"
+	SyncTypeEnd      = "end" // Final record carrying the generation to resume from
+)
+
+// Sync operations
+const (
+	SyncOpUpsert = "upsert"
+	SyncOpDelete = "delete"
+)
+
+// SyncState tracks the generation at which each record last changed.
+// Every write bumps Generation; deleted records leave a tombstone so replicas
+// can apply deletions. Deleting a registry or package drops the entries of
+// its children, since the parent tombstone covers them.
+type SyncState struct {
+	Generation uint64            `json:"generation"`
+	Records    map[string]uint64 `json:"records"`    // record key -> generation of last change
+	Tombstones map[string]uint64 `json:"tombstones"` // deleted record key -> generation of deletion
+}
+
+// NewSyncState creates an empty sync state
+func NewSyncState() *SyncState {
+	return &SyncState{
+		Records:    make(map[string]uint64),
+		Tombstones: make(map[string]uint64),
+	}
+}
+
+// SyncChange is one record of the differential sync stream.
+// Data holds the record without its children (registry without packages,
+// package without versions) and is empty for deletions.
+type SyncChange struct {
+	Generation uint64          `json:"generation"`
+	Type       string          `json:"type"`
+	Op         string          `json:"op,omitempty"`
+	Registry   string          `json:"registry,omitempty"`
+	Package    string          `json:"package,omitempty"`
+	Version    string          `json:"version,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// RecordKey builds the sync record key for a registry, package or version.
+// Names and versions never contain '/', so keys are unambiguous.
+func RecordKey(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+// SplitRecordKey splits a record key into its type and name parts
+func SplitRecordKey(key string) (recordType, registry, pkg, version string) {
+	parts := strings.SplitN(key, "/", 3)
+	switch len(parts) {
+	case 1:
+		return SyncTypeRegistry, parts[0], "", ""
+	case 2:
+		return SyncTypePackage, parts[0], parts[1], ""
+	default:
+		return SyncTypeVersion, parts[0], parts[1], parts[2]
+	}
+}
+
+// ApplyChange applies a sync record to a local copy of the storage.
+// Records may arrive before their parents (a registry updated after its
+// packages sorts later), so missing parents are created as placeholders.
+func (s *Storage) ApplyChange(c *SyncChange) error {
+	switch c.Type {
+	case SyncTypeRegistry:
+		if c.Op == SyncOpDelete {
+			delete(s.Registries, c.Registry)
+			return nil
+		}
+		var r Registry
+		if err := json.Unmarshal(c.Data, &r); err != nil {
+			return fmt.Errorf("invalid registry record %s: %w", c.Registry, err)
+		}
+		NormalizeRegistry(&r)
+		r.Packages = s.ensureRegistry(c.Registry).Packages
+		s.Registries[c.Registry] = &r
+
+	case SyncTypePackage:
+		if c.Op == SyncOpDelete {
+			if registry, ok := s.Registries[c.Registry]; ok {
+				delete(registry.Packages, c.Package)
+			}
+			return nil
+		}
+		var p Package
+		if err := json.Unmarshal(c.Data, &p); err != nil {
+			return fmt.Errorf("invalid package record %s/%s: %w", c.Registry, c.Package, err)
+		}
+		NormalizePackage(&p)
+		p.Versions = s.ensurePackage(c.Registry, c.Package).Versions
+		s.Registries[c.Registry].Packages[c.Package] = &p
+
+	case SyncTypeVersion:
+		if c.Op == SyncOpDelete {
+			if registry, ok := s.Registries[c.Registry]; ok {
+				if pkg, ok := registry.Packages[c.Package]; ok {
+					delete(pkg.Versions, c.Version)
+				}
+			}
+			return nil
+		}
+		var v Version
+		if err := json.Unmarshal(c.Data, &v); err != nil {
+			return fmt.Errorf("invalid version record %s/%s/%s: %w", c.Registry, c.Package, c.Version, err)
+		}
+		s.ensurePackage(c.Registry, c.Package).Versions[c.Version] = &v
+
+	case SyncTypeEnd:
+		// Nothing to apply
+
+	default:
+		return fmt.Errorf("unknown sync record type %q", c.Type)
+	}
+	return nil
+}
+
+// ensureRegistry returns the named registry, creating a placeholder if missing
+func (s *Storage) ensureRegistry(name string) *Registry {
+	if s.Registries == nil {
+		s.Registries = make(map[string]*Registry)
+	}
+	registry, ok := s.Registries[name]
+	if !ok {
+		registry = NewRegistry(name, "", nil, nil)
+		s.Registries[name] = registry
+	}
+	if registry.Packages == nil {
+		registry.Packages = make(map[string]*Package)
+	}
+	return registry
+}
+
+// ensurePackage returns the named package, creating placeholders if missing
+func (s *Storage) ensurePackage(registryName, packageName string) *Package {
+	registry := s.ensureRegistry(registryName)
+	pkg, ok := registry.Packages[packageName]
+	if !ok {
+		pkg = NewPackage(packageName, "", nil, nil)
+		registry.Packages[packageName] = pkg
+	}
+	if pkg.Versions == nil {
+		pkg.Versions = make(map[string]*Version)
+	}
+	return pkg
+}
@@ -5,6 +5,9 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/checksum"
+	"github.com/criteo/command-launcher-registry/internal/secrets"
 )
 
 var (
@@ -14,8 +17,12 @@ var (
 	// Semantic version pattern (simplified - supports major.minor.patch with optional pre-release and build metadata)
 	versionPattern = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
 
-	// Checksum pattern: sha256: followed by 64 hex characters
-	checksumPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	// Legacy version pattern: dot-separated numeric components with an optional
+	// "-suffix" and "+build" (e.g., 1.0, 2024.06.01-build5)
+	legacyVersionPattern = regexp.MustCompile(`^[0-9]+(?:\.[0-9]+)*(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?$`)
+
+	// Checksum pattern: algorithm prefix followed by the hex digest
+	checksumPattern = regexp.MustCompile(checksum.Pattern())
 
 	// Custom values key pattern
 	customKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]{0,63}$`)
@@ -64,13 +71,57 @@ func ValidateVersion(version string) error {
 	return nil
 }
 
-// ValidateChecksum validates SHA256 checksum format
-func ValidateChecksum(checksum string) error {
-	if len(checksum) == 0 {
+// ValidateVersionPolicy validates a registry version policy
+func ValidateVersionPolicy(policy string) error {
+	switch policy {
+	case "", VersionPolicySemver, VersionPolicyLegacy:
+		return nil
+	default:
+		return &ValidationError{Field: "version_policy", Message: "version_policy must be 'semver' or 'legacy'"}
+	}
+}
+
+// MaxCacheTTL is the longest index cache lifetime a registry may set, in seconds
+const MaxCacheTTL = 7 * 24 * 60 * 60
+
+// ValidateCacheTTL validates the index cache lifetime of a registry
+func ValidateCacheTTL(ttl *int) error {
+	if ttl != nil && (*ttl < 0 || *ttl > MaxCacheTTL) {
+		return &ValidationError{Field: "cache_ttl", Message: fmt.Sprintf("cache_ttl must be between 0 and %d seconds", MaxCacheTTL)}
+	}
+	return nil
+}
+
+// ValidateVersionWithPolicy validates a version string according to a registry version policy.
+// Strict semver is used unless the policy is "legacy".
+func ValidateVersionWithPolicy(version, policy string) error {
+	if policy != VersionPolicyLegacy {
+		return ValidateVersion(version)
+	}
+
+	if len(version) == 0 {
+		return &ValidationError{Field: "version", Message: "version is required"}
+	}
+	if len(version) > 64 {
+		return &ValidationError{Field: "version", Message: "version must be at most 64 characters"}
+	}
+	if !legacyVersionPattern.MatchString(version) {
+		return &ValidationError{Field: "version", Message: "version must be valid semantic version or legacy version (e.g., 1.0, 2024.06.01-build5)"}
+	}
+	if _, err := ParseVersion(version); err != nil {
+		return &ValidationError{Field: "version", Message: err.Error()}
+	}
+	return nil
+}
+
+// ValidateChecksum validates checksum format: sha256, sha512 or blake3
+// prefix and hex digest
+func ValidateChecksum(sum string) error {
+	if len(sum) == 0 {
 		return &ValidationError{Field: "checksum", Message: "checksum is required"}
 	}
-	if !checksumPattern.MatchString(checksum) {
-		return &ValidationError{Field: "checksum", Message: "checksum must match format sha256:[64 hex characters]"}
+	if _, _, err := checksum.Parse(sum); err != nil {
+		return &ValidationError{Field: "checksum", Message: err.Error()}
 	}
 	return nil
 }
@@ -149,6 +200,15 @@ func ValidateCustomValues(customValues map[string]string) error {
 				Message: fmt.Sprintf("custom_values value for key '%s' must be at most 1024 characters", key),
 			}
 		}
+
+		// Storage takes such values for ciphertext it already encrypted,
+		// and would keep a client's as is, in plaintext
+		if secrets.IsEncrypted(value) {
+			return &ValidationError{
+				Field:   "custom_values",
+				Message: fmt.Sprintf("custom_values value for key '%s' must not start with '%s'", key, secrets.Prefix),
+			}
+		}
 	}
 
 	return nil
@@ -165,6 +225,21 @@ func ValidateRegistry(r *Registry) error {
 	if err := ValidateCustomValues(r.CustomValues); err != nil {
 		return err
 	}
+	if err := ValidateVersionPolicy(r.VersionPolicy); err != nil {
+		return err
+	}
+	if err := ValidateSensitiveKeys(r.SensitiveKeys); err != nil {
+		return err
+	}
+	if err := ValidateAnnouncements(r.Announcements); err != nil {
+		return err
+	}
+	if err := ValidateCacheTTL(r.CacheTTL); err != nil {
+		return err
+	}
+	if err := ValidateAliases(r.Aliases); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -182,9 +257,14 @@ func ValidatePackage(p *Package) error {
 	return nil
 }
 
-// ValidateVersionData validates version data
+// ValidateVersionData validates version data using strict semver
 func ValidateVersionData(v *Version) error {
-	if err := ValidateVersion(v.Version); err != nil {
+	return ValidateVersionDataWithPolicy(v, VersionPolicySemver)
+}
+
+// ValidateVersionDataWithPolicy validates version data using the given registry version policy
+func ValidateVersionDataWithPolicy(v *Version, policy string) error {
+	if err := ValidateVersionWithPolicy(v.Version, policy); err != nil {
 		return err
 	}
 	if err := ValidateChecksum(v.Checksum); err != nil {
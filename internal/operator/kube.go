@@ -0,0 +1,183 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
+	"github.com/criteo/command-launcher-registry/internal/fips"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into pods
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubeClient is a minimal client of the Kubernetes API, limited to what the
+// operator needs: listing its custom resources and patching their metadata
+// and status
+type KubeClient struct {
+	baseURL    string
+	tokenFile  string // Re-read on each request: projected tokens rotate
+	httpClient *http.Client
+}
+
+// InClusterClient returns a client using the service account of the pod
+func InClusterClient() (*KubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster (KUBERNETES_SERVICE_HOST is not set); use --kube-api")
+	}
+
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid cluster CA in %s", filepath.Join(serviceAccountDir, "ca.crt"))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	fips.RestrictTLS(transport)
+
+	return &KubeClient{
+		baseURL:    "https://" + net.JoinHostPort(host, port),
+		tokenFile:  filepath.Join(serviceAccountDir, "token"),
+		httpClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// NewKubeClient returns a client of the API at apiURL, such as the
+// address of kubectl proxy. tokenFile may be empty.
+func NewKubeClient(apiURL, tokenFile string) *KubeClient {
+	return &KubeClient{
+		baseURL:    strings.TrimSuffix(apiURL, "/"),
+		tokenFile:  tokenFile,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// resourcePath returns the path of a collection of custom resources, in
+// every namespace when namespace is empty
+func resourcePath(plural, namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/%s", Group, Version, plural)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, url.PathEscape(namespace), plural)
+}
+
+// objectPath returns the path of a custom resource
+func objectPath(plural string, meta ObjectMeta) string {
+	return resourcePath(plural, meta.Namespace) + "/" + url.PathEscape(meta.Name)
+}
+
+// do sends a request to the API and decodes the response into out, if not nil
+func (k *KubeClient) do(ctx context.Context, method, path, contentType string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, k.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", buildinfo.UserAgent("cola-registry-operator"))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if k.tokenFile != "" {
+		token, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Kubernetes errors are Status objects with a message
+		var status struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, status.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// ListRegistries lists the Registry resources of a namespace, or of every
+// namespace when namespace is empty
+func (k *KubeClient) ListRegistries(ctx context.Context, namespace string) ([]RegistryResource, error) {
+	var list struct {
+		Items []RegistryResource `json:"items"`
+	}
+	if err := k.do(ctx, http.MethodGet, resourcePath(RegistryPlural, namespace), "", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// ListPackages lists the Package resources of a namespace, or of every
+// namespace when namespace is empty
+func (k *KubeClient) ListPackages(ctx context.Context, namespace string) ([]PackageResource, error) {
+	var list struct {
+		Items []PackageResource `json:"items"`
+	}
+	if err := k.do(ctx, http.MethodGet, resourcePath(PackagePlural, namespace), "", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// PatchStatus replaces the status of a resource through its status
+// subresource
+func (k *KubeClient) PatchStatus(ctx context.Context, plural string, meta ObjectMeta, status Status) error {
+	patch := map[string]interface{}{"status": status}
+	return k.do(ctx, http.MethodPatch, objectPath(plural, meta)+"/status", "application/merge-patch+json", patch, nil)
+}
+
+// SetFinalizers replaces the finalizers of a resource. The resource version
+// makes the patch fail if the resource changed since it was listed.
+func (k *KubeClient) SetFinalizers(ctx context.Context, plural string, meta ObjectMeta, finalizers []string) error {
+	if finalizers == nil {
+		finalizers = []string{}
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers,
+			"resourceVersion": meta.ResourceVersion,
+		},
+	}
+	return k.do(ctx, http.MethodPatch, objectPath(plural, meta), "application/merge-patch+json", patch, nil)
+}
@@ -0,0 +1,416 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// Operator reconciles Registry and Package custom resources against the
+// registry server API, so a cluster managed with GitOps drives the registry.
+// The server is only written to when it differs from a spec, with If-Match,
+// so changes made meanwhile through the API are never overwritten blindly.
+// Each resource reports the outcome in its Ready condition.
+type Operator struct {
+	kube      *KubeClient
+	api       *client.Client
+	namespace string // Empty watches every namespace
+	prune     bool   // Delete from the server along with resources
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+// Options configures an operator
+type Options struct {
+	Namespace string // Namespace of the resources; empty for every namespace
+	Prune     bool   // Delete registries and packages along with their resources
+}
+
+// New creates an operator
+func New(kube *KubeClient, api *client.Client, opts Options, logger *slog.Logger) *Operator {
+	return &Operator{
+		kube:      kube,
+		api:       api,
+		namespace: opts.Namespace,
+		prune:     opts.Prune,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Run reconciles every interval until ctx is done. Failed passes are
+// logged and retried at the next interval.
+func (o *Operator) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := o.Reconcile(ctx); err != nil {
+			o.logger.Error("Reconciliation failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile makes one pass over every resource: registries first, so the
+// packages of a registry created in the same pass find it
+func (o *Operator) Reconcile(ctx context.Context) error {
+	registries, err := o.kube.ListRegistries(ctx, o.namespace)
+	if err != nil {
+		return fmt.Errorf("failed to list registries: %w", err)
+	}
+	packages, err := o.kube.ListPackages(ctx, o.namespace)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	for i := range registries {
+		o.reconcileRegistry(ctx, &registries[i])
+	}
+	sensitiveKeys := make(map[string][]string) // By registry, for this pass
+	for i := range packages {
+		o.reconcilePackage(ctx, &packages[i], sensitiveKeys)
+	}
+	return nil
+}
+
+// outcome is the result of reconciling one resource
+type outcome struct {
+	ready   bool
+	reason  string
+	message string
+}
+
+// apiOutcome maps a failed API response to an outcome
+func apiOutcome(resp *http.Response, action string) outcome {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var apiErr apierrors.ErrorResponse
+	message := string(body)
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		message = apiErr.Error.Message
+	}
+	message = fmt.Sprintf("%s: HTTP %d: %s", action, resp.StatusCode, message)
+
+	switch {
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity:
+		return outcome{reason: ReasonInvalidSpec, message: message}
+	case resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict:
+		return outcome{reason: ReasonConflict, message: message + " (retried at the next pass)"}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return outcome{reason: ReasonForbidden, message: message}
+	case apiErr.Error.Code == apierrors.ErrCodeRegistryNotFound:
+		return outcome{reason: ReasonRegistryNotFound, message: message}
+	default:
+		return outcome{reason: ReasonServerError, message: message}
+	}
+}
+
+// requestOutcome maps a request that could not be sent to an outcome
+func requestOutcome(err error, action string) outcome {
+	return outcome{reason: ReasonServerError, message: fmt.Sprintf("%s: %v", action, err)}
+}
+
+// reconcileRegistry brings the server in line with a Registry resource
+func (o *Operator) reconcileRegistry(ctx context.Context, res *RegistryResource) {
+	name := res.RegistryName()
+	path := "/api/v1/registry/" + url.PathEscape(name)
+	logger := o.logger.With("kind", "Registry", "namespace", res.Metadata.Namespace, "resource", res.Metadata.Name, "registry", name)
+
+	if !o.handleFinalizer(ctx, RegistryPlural, &res.Metadata, path, logger) {
+		return
+	}
+
+	desired := res.Desired()
+	if err := models.ValidateRegistry(desired); err != nil {
+		o.setStatus(ctx, RegistryPlural, res.Metadata, &res.Status, outcome{reason: ReasonInvalidSpec, message: err.Error()}, logger)
+		return
+	}
+
+	var current models.Registry
+	etag, result, found := o.get(path, &current, "get registry")
+	if result != nil {
+		o.setStatus(ctx, RegistryPlural, res.Metadata, &res.Status, *result, logger)
+		return
+	}
+	if !found {
+		o.setStatus(ctx, RegistryPlural, res.Metadata, &res.Status,
+			o.write(func() (*http.Response, error) { return o.api.Post("/api/v1/registry", desired) },
+				http.StatusCreated, ReasonCreated, "create registry", logger), logger)
+		return
+	}
+
+	keepSensitiveValues(desired.CustomValues, current.CustomValues, desired.SensitiveKeys)
+	if registryInSync(&current, desired) {
+		o.setStatus(ctx, RegistryPlural, res.Metadata, &res.Status, outcome{ready: true, reason: ReasonInSync, message: "Registry matches the spec"}, logger)
+		return
+	}
+	o.setStatus(ctx, RegistryPlural, res.Metadata, &res.Status,
+		o.write(func() (*http.Response, error) { return o.api.PutIfMatch(path, etag, desired) },
+			http.StatusOK, ReasonUpdated, "update registry", logger), logger)
+}
+
+// reconcilePackage brings the server in line with a Package resource.
+// sensitiveKeys caches the sensitive keys of registries for the pass.
+func (o *Operator) reconcilePackage(ctx context.Context, res *PackageResource, sensitiveKeys map[string][]string) {
+	name := res.PackageName()
+	registryPath := "/api/v1/registry/" + url.PathEscape(res.Spec.Registry)
+	path := registryPath + "/package/" + url.PathEscape(name)
+	logger := o.logger.With("kind", "Package", "namespace", res.Metadata.Namespace, "resource", res.Metadata.Name,
+		"registry", res.Spec.Registry, "package", name)
+
+	if !o.handleFinalizer(ctx, PackagePlural, &res.Metadata, path, logger) {
+		return
+	}
+
+	desired := res.Desired()
+	if res.Spec.Registry == "" {
+		o.setStatus(ctx, PackagePlural, res.Metadata, &res.Status, outcome{reason: ReasonInvalidSpec, message: "spec.registry is required"}, logger)
+		return
+	}
+	if err := models.ValidatePackage(desired); err != nil {
+		o.setStatus(ctx, PackagePlural, res.Metadata, &res.Status, outcome{reason: ReasonInvalidSpec, message: err.Error()}, logger)
+		return
+	}
+
+	keys, cached := sensitiveKeys[res.Spec.Registry]
+	if !cached {
+		var registry models.Registry
+		_, result, found := o.get(registryPath, &registry, "get registry")
+		if result == nil && !found {
+			result = &outcome{reason: ReasonRegistryNotFound, message: fmt.Sprintf("Registry '%s' does not exist", res.Spec.Registry)}
+		}
+		if result != nil {
+			o.setStatus(ctx, PackagePlural, res.Metadata, &res.Status, *result, logger)
+			return
+		}
+		keys = registry.SensitiveKeys
+		sensitiveKeys[res.Spec.Registry] = keys
+	}
+
+	var current models.Package
+	etag, result, found := o.get(path, &current, "get package")
+	if result != nil {
+		o.setStatus(ctx, PackagePlural, res.Metadata, &res.Status, *result, logger)
+		return
+	}
+	if !found {
+		o.setStatus(ctx, PackagePlural, res.Metadata, &res.Status,
+			o.write(func() (*http.Response, error) { return o.api.Post(registryPath+"/package", desired) },
+				http.StatusCreated, ReasonCreated, "create package", logger), logger)
+		return
+	}
+
+	keepSensitiveValues(desired.CustomValues, current.CustomValues, keys)
+	if packageInSync(&current, desired) {
+		o.setStatus(ctx, PackagePlural, res.Metadata, &res.Status, outcome{ready: true, reason: ReasonInSync, message: "Package matches the spec"}, logger)
+		return
+	}
+	o.setStatus(ctx, PackagePlural, res.Metadata, &res.Status,
+		o.write(func() (*http.Response, error) { return o.api.PutIfMatch(path, etag, desired) },
+			http.StatusOK, ReasonUpdated, "update package", logger), logger)
+}
+
+// get reads a resource of the server into out and returns its ETag. found
+// is false when the server answers 404; any other failure is returned as an
+// outcome.
+func (o *Operator) get(path string, out interface{}, action string) (etag string, failure *outcome, found bool) {
+	resp, err := o.api.Get(path)
+	if err != nil {
+		result := requestOutcome(err, action)
+		return "", &result, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil, false
+	}
+	if resp.StatusCode != http.StatusOK {
+		result := apiOutcome(resp, action)
+		return "", &result, false
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		result := requestOutcome(fmt.Errorf("invalid response: %w", err), action)
+		return "", &result, false
+	}
+	return resp.Header.Get("ETag"), nil, true
+}
+
+// write sends a write to the server and maps its response to an outcome
+func (o *Operator) write(send func() (*http.Response, error), expected int, reason, action string, logger *slog.Logger) outcome {
+	resp, err := send()
+	if err != nil {
+		return requestOutcome(err, action)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expected {
+		return apiOutcome(resp, action)
+	}
+	logger.Info("Reconciled resource", "action", action)
+	return outcome{ready: true, reason: reason, message: fmt.Sprintf("Reconciled (%s)", action)}
+}
+
+// handleFinalizer deletes the server side of a resource being deleted, and
+// adds the finalizer to live resources when pruning is on. It returns false
+// when the resource is being deleted, so it is not reconciled.
+func (o *Operator) handleFinalizer(ctx context.Context, plural string, meta *ObjectMeta, path string, logger *slog.Logger) bool {
+	hasFinalizer := slices.Contains(meta.Finalizers, Finalizer)
+
+	if meta.DeletionTimestamp != nil {
+		if !hasFinalizer {
+			return false
+		}
+		resp, err := o.api.Delete(path)
+		if err != nil {
+			logger.Error("Failed to delete from the server", "error", err)
+			return false
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			logger.Error("Failed to delete from the server", "status", resp.StatusCode)
+			return false
+		}
+		finalizers := slices.DeleteFunc(slices.Clone(meta.Finalizers), func(f string) bool { return f == Finalizer })
+		if err := o.kube.SetFinalizers(ctx, plural, *meta, finalizers); err != nil {
+			logger.Error("Failed to remove finalizer", "error", err)
+			return false
+		}
+		logger.Info("Deleted from the server along with its resource")
+		return false
+	}
+
+	if o.prune && !hasFinalizer {
+		finalizers := append(slices.Clone(meta.Finalizers), Finalizer)
+		if err := o.kube.SetFinalizers(ctx, plural, *meta, finalizers); err != nil {
+			// Reconcile anyway; the finalizer is added at a later pass
+			logger.Warn("Failed to add finalizer", "error", err)
+		}
+	}
+	return true
+}
+
+// setStatus records an outcome in the Ready condition of a resource. The
+// status is only patched when it changes, so passes over resources in sync
+// do not write to the cluster.
+func (o *Operator) setStatus(ctx context.Context, plural string, meta ObjectMeta, current *Status, result outcome, logger *slog.Logger) {
+	if !result.ready {
+		logger.Warn("Resource not reconciled", "reason", result.reason, "message", result.message)
+	}
+
+	condition := Condition{
+		Type:               ConditionReady,
+		Status:             "False",
+		ObservedGeneration: meta.Generation,
+		Reason:             result.reason,
+		Message:            result.message,
+		LastTransitionTime: o.now().UTC().Truncate(time.Second),
+	}
+	if result.ready {
+		condition.Status = "True"
+		// Once written, an update shows as in sync at the next pass: keep
+		// the reason of the last write until the spec changes again
+		if previous := current.condition(ConditionReady); result.reason == ReasonInSync && previous != nil &&
+			previous.Status == "True" && previous.ObservedGeneration == meta.Generation {
+			condition.Reason, condition.Message = previous.Reason, previous.Message
+		}
+	}
+
+	status := Status{ObservedGeneration: meta.Generation}
+	for _, c := range current.Conditions {
+		if c.Type != ConditionReady {
+			status.Conditions = append(status.Conditions, c)
+		}
+	}
+	if previous := current.condition(ConditionReady); previous != nil {
+		if previous.Status == condition.Status {
+			condition.LastTransitionTime = previous.LastTransitionTime
+		}
+		if *previous == condition && current.ObservedGeneration == meta.Generation {
+			return
+		}
+	}
+	status.Conditions = append(status.Conditions, condition)
+
+	if err := o.kube.PatchStatus(ctx, plural, meta, status); err != nil {
+		logger.Error("Failed to update status", "error", err)
+	}
+}
+
+// keepSensitiveValues sends back masked the current values of sensitive
+// keys the spec leaves out, which the server then keeps: secrets can be set
+// through the API instead of being written in resources
+func keepSensitiveValues(desired, current map[string]string, sensitiveKeys []string) {
+	for _, key := range sensitiveKeys {
+		if _, inSpec := desired[key]; inSpec {
+			continue
+		}
+		if _, ok := current[key]; ok {
+			desired[key] = models.MaskedValue
+		}
+	}
+}
+
+// customValuesInSync compares custom values. A masked value on either side
+// cannot be compared and is taken as equal.
+func customValuesInSync(current, desired map[string]string) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+	for key, want := range desired {
+		got, ok := current[key]
+		if !ok {
+			return false
+		}
+		if got != want && got != models.MaskedValue && want != models.MaskedValue {
+			return false
+		}
+	}
+	return true
+}
+
+// listsEqual compares string lists, nil being equal to empty
+func listsEqual(a, b []string) bool {
+	return len(a) == 0 && len(b) == 0 || slices.Equal(a, b)
+}
+
+// registryInSync reports whether the server registry matches the spec
+func registryInSync(current, desired *models.Registry) bool {
+	policy := func(p string) string {
+		if p == "" {
+			return models.VersionPolicySemver
+		}
+		return p
+	}
+	return current.Description == desired.Description &&
+		listsEqual(current.Admins, desired.Admins) &&
+		customValuesInSync(current.CustomValues, desired.CustomValues) &&
+		policy(current.VersionPolicy) == policy(desired.VersionPolicy) &&
+		listsEqual(current.SensitiveKeys, desired.SensitiveKeys) &&
+		reflect.DeepEqual(current.AnonymousRead, desired.AnonymousRead) &&
+		listsEqual(current.Announcements, desired.Announcements) &&
+		reflect.DeepEqual(current.CacheTTL, desired.CacheTTL) &&
+		maps.Equal(current.Aliases, desired.Aliases) &&
+		current.IndexAliases == desired.IndexAliases
+}
+
+// packageInSync reports whether the server package matches the spec
+func packageInSync(current, desired *models.Package) bool {
+	return current.Description == desired.Description &&
+		listsEqual(current.Maintainers, desired.Maintainers) &&
+		customValuesInSync(current.CustomValues, desired.CustomValues)
+}
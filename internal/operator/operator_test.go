@@ -0,0 +1,246 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/client"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/handlers"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// fakeKube serves Registry and Package resources of a single namespace,
+// recording the patches it receives
+type fakeKube struct {
+	mu         sync.Mutex
+	objects    map[string]map[string]interface{} // By "plural/name"
+	statusSeen int                               // Status patches received
+}
+
+func newFakeKube(t *testing.T) (*fakeKube, *KubeClient) {
+	k := &fakeKube{objects: make(map[string]map[string]interface{})}
+	srv := httptest.NewServer(http.HandlerFunc(k.serve))
+	t.Cleanup(srv.Close)
+	return k, NewKubeClient(srv.URL, "")
+}
+
+func (k *fakeKube) add(t *testing.T, plural, manifest string) {
+	var obj map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(manifest), &obj))
+	meta := obj["metadata"].(map[string]interface{})
+	meta["namespace"] = "tools"
+	if meta["generation"] == nil {
+		meta["generation"] = 1
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.objects[plural+"/"+meta["name"].(string)] = obj
+}
+
+func (k *fakeKube) get(t *testing.T, plural, name string, into interface{}) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	data, err := json.Marshal(k.objects[plural+"/"+name])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, into))
+}
+
+func (k *fakeKube) serve(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	prefix := "/apis/" + Group + "/" + Version + "/"
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1:
+		items := []interface{}{}
+		for key, obj := range k.objects {
+			if strings.HasPrefix(key, parts[0]+"/") {
+				items = append(items, obj)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+	case r.Method == http.MethodPatch && len(parts) >= 2:
+		obj, ok := k.objects[parts[0]+"/"+parts[1]]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		var patch map[string]map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &patch)
+		if len(parts) == 3 && parts[2] == "status" {
+			k.statusSeen++
+			obj["status"] = patch["status"]
+		} else {
+			obj["metadata"].(map[string]interface{})["finalizers"] = patch["metadata"]["finalizers"]
+		}
+		w.Write([]byte("{}"))
+	default:
+		http.Error(w, `{"message":"unsupported"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// newRegistryServer serves the registry and package API over a file store
+func newRegistryServer(t *testing.T) (storage.Store, *client.Client) {
+	logger := slog.Default()
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	registries := handlers.NewRegistryHandler(store, logger)
+	packages := handlers.NewPackageHandler(store, logger)
+	etags := middleware.CacheControl(0, true)
+	router := chi.NewRouter()
+	router.Post("/api/v1/registry", registries.CreateRegistry)
+	router.With(etags).Get("/api/v1/registry/{name}", registries.GetRegistry)
+	router.Put("/api/v1/registry/{name}", registries.UpdateRegistry)
+	router.Delete("/api/v1/registry/{name}", registries.DeleteRegistry)
+	router.Post("/api/v1/registry/{name}/package", packages.CreatePackage)
+	router.With(etags).Get("/api/v1/registry/{name}/package/{package}", packages.GetPackage)
+	router.Put("/api/v1/registry/{name}/package/{package}", packages.UpdatePackage)
+	router.Delete("/api/v1/registry/{name}/package/{package}", packages.DeletePackage)
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return store, client.NewClient(srv.URL, "", 10*time.Second, false)
+}
+
+func readyCondition(t *testing.T, status Status) Condition {
+	condition := status.condition(ConditionReady)
+	require.NotNil(t, condition)
+	return *condition
+}
+
+func TestOperator_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	kube, kubeClient := newFakeKube(t)
+	store, api := newRegistryServer(t)
+	op := New(kubeClient, api, Options{Namespace: "tools"}, slog.Default())
+
+	kube.add(t, RegistryPlural, `{"metadata":{"name":"build"},"spec":{"description":"Build tools","admins":["alice"],"customValues":{"team":"ci"}}}`)
+	kube.add(t, PackagePlural, `{"metadata":{"name":"deployer"},"spec":{"registry":"build","description":"Deploys","maintainers":["bob"]}}`)
+	kube.add(t, PackagePlural, `{"metadata":{"name":"orphan"},"spec":{"registry":"missing"}}`)
+
+	// First pass creates the registry, then its package
+	require.NoError(t, op.Reconcile(ctx))
+
+	registry, err := store.GetRegistry(ctx, "build")
+	require.NoError(t, err)
+	assert.Equal(t, "Build tools", registry.Description)
+	assert.Equal(t, map[string]string{"team": "ci"}, registry.CustomValues)
+	pkg, err := store.GetPackage(ctx, "build", "deployer")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, pkg.Maintainers)
+
+	var res RegistryResource
+	kube.get(t, RegistryPlural, "build", &res)
+	condition := readyCondition(t, res.Status)
+	assert.Equal(t, "True", condition.Status)
+	assert.Equal(t, ReasonCreated, condition.Reason)
+	assert.Equal(t, int64(1), res.Status.ObservedGeneration)
+
+	var orphan PackageResource
+	kube.get(t, PackagePlural, "orphan", &orphan)
+	condition = readyCondition(t, orphan.Status)
+	assert.Equal(t, "False", condition.Status)
+	assert.Equal(t, ReasonRegistryNotFound, condition.Reason)
+
+	// A pass with nothing to change writes nothing
+	statusPatches := kube.statusSeen
+	require.NoError(t, op.Reconcile(ctx))
+	assert.Equal(t, statusPatches, kube.statusSeen)
+
+	// Drift made through the API is reverted; versions are kept
+	require.NoError(t, store.CreateVersion(ctx, "build", "deployer", &models.Version{
+		Name: "deployer", Version: "1.0.0", Checksum: "sha256:" + strings.Repeat("a", 64),
+		URL: "https://example.com/deployer-1.0.0.zip", EndPartition: 9,
+	}))
+	pkg.Description = "Changed by hand"
+	require.NoError(t, store.UpdatePackage(ctx, "build", pkg))
+	require.NoError(t, op.Reconcile(ctx))
+	pkg, err = store.GetPackage(ctx, "build", "deployer")
+	require.NoError(t, err)
+	assert.Equal(t, "Deploys", pkg.Description)
+	assert.Contains(t, pkg.Versions, "1.0.0")
+
+	var deployer PackageResource
+	kube.get(t, PackagePlural, "deployer", &deployer)
+	assert.Equal(t, ReasonUpdated, readyCondition(t, deployer.Status).Reason)
+
+	// Invalid specs are reported against their generation, not applied
+	kube.add(t, RegistryPlural, `{"metadata":{"name":"build","generation":2},"spec":{"description":"Build and release tools","versionPolicy":"bogus"}}`)
+	require.NoError(t, op.Reconcile(ctx))
+	kube.get(t, RegistryPlural, "build", &res)
+	condition = readyCondition(t, res.Status)
+	assert.Equal(t, "False", condition.Status)
+	assert.Equal(t, ReasonInvalidSpec, condition.Reason)
+	assert.Equal(t, int64(2), condition.ObservedGeneration)
+	registry, err = store.GetRegistry(ctx, "build")
+	require.NoError(t, err)
+	assert.Equal(t, "Build tools", registry.Description, "invalid specs are not applied")
+}
+
+func TestOperator_SensitiveValuesOutsideSpec(t *testing.T) {
+	ctx := context.Background()
+	kube, kubeClient := newFakeKube(t)
+	store, api := newRegistryServer(t)
+	op := New(kubeClient, api, Options{}, slog.Default())
+
+	// The secret is set through the API, not written in the resource
+	registry := models.NewRegistry("build", "Build tools", nil, map[string]string{"token": "s3cret"})
+	registry.SensitiveKeys = []string{"token"}
+	require.NoError(t, store.CreateRegistry(ctx, registry))
+	kube.add(t, RegistryPlural, `{"metadata":{"name":"build"},"spec":{"description":"Build tools","sensitiveKeys":["token"],"customValues":{"team":"ci"}}}`)
+
+	require.NoError(t, op.Reconcile(ctx))
+	registry, err := store.GetRegistry(ctx, "build")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "ci", "token": "s3cret"}, registry.CustomValues)
+
+	var res RegistryResource
+	kube.get(t, RegistryPlural, "build", &res)
+	assert.Equal(t, ReasonUpdated, readyCondition(t, res.Status).Reason)
+}
+
+func TestOperator_Prune(t *testing.T) {
+	ctx := context.Background()
+	kube, kubeClient := newFakeKube(t)
+	store, api := newRegistryServer(t)
+	op := New(kubeClient, api, Options{Prune: true}, slog.Default())
+
+	kube.add(t, RegistryPlural, `{"metadata":{"name":"build"},"spec":{}}`)
+	require.NoError(t, op.Reconcile(ctx))
+
+	var res RegistryResource
+	kube.get(t, RegistryPlural, "build", &res)
+	assert.Equal(t, []string{Finalizer}, res.Metadata.Finalizers)
+	_, err := store.GetRegistry(ctx, "build")
+	require.NoError(t, err)
+
+	// Deleting the resource deletes the registry, then releases the resource
+	kube.add(t, RegistryPlural, `{"metadata":{"name":"build","deletionTimestamp":"2026-01-02T03:04:05Z","finalizers":["`+Finalizer+`"]},"spec":{}}`)
+	require.NoError(t, op.Reconcile(ctx))
+	_, err = store.GetRegistry(ctx, "build")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	kube.get(t, RegistryPlural, "build", &res)
+	assert.Empty(t, res.Metadata.Finalizers)
+}
@@ -0,0 +1,171 @@
+package operator
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// Group and version of the custom resources, as declared by the CRDs in
+// deploy/kubernetes/crds.yaml
+const (
+	Group   = "cola.criteo.com"
+	Version = "v1alpha1"
+)
+
+// Plural names of the custom resources, used in API paths
+const (
+	RegistryPlural = "registries"
+	PackagePlural  = "packages"
+)
+
+// Finalizer is set on resources when pruning is on, so deleting a resource
+// deletes its registry or package from the server first
+const Finalizer = Group + "/prune"
+
+// ConditionReady is the condition reporting whether the server matches the
+// spec of a resource
+const ConditionReady = "Ready"
+
+// Reasons of the Ready condition
+const (
+	ReasonCreated          = "Created"
+	ReasonUpdated          = "Updated"
+	ReasonInSync           = "InSync"
+	ReasonInvalidSpec      = "InvalidSpec"
+	ReasonRegistryNotFound = "RegistryNotFound"
+	ReasonConflict         = "Conflict"
+	ReasonForbidden        = "Forbidden"
+	ReasonServerError      = "ServerError"
+)
+
+// ObjectMeta is the part of Kubernetes object metadata the operator reads
+type ObjectMeta struct {
+	Name              string     `json:"name"`
+	Namespace         string     `json:"namespace,omitempty"`
+	Generation        int64      `json:"generation,omitempty"`
+	ResourceVersion   string     `json:"resourceVersion,omitempty"`
+	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+	Finalizers        []string   `json:"finalizers,omitempty"`
+}
+
+// Condition is a Kubernetes status condition
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"` // True | False | Unknown
+	ObservedGeneration int64     `json:"observedGeneration,omitempty"`
+	Reason             string    `json:"reason"`
+	Message            string    `json:"message"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// Status is the status of both resource kinds
+type Status struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// condition returns the condition of a type, or nil
+func (s *Status) condition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// RegistrySpec is the desired state of a registry. Packages are managed
+// by Package resources, or through the API.
+type RegistrySpec struct {
+	Name          string            `json:"name,omitempty"` // Defaults to metadata.name
+	Description   string            `json:"description,omitempty"`
+	Admins        []string          `json:"admins,omitempty"`
+	CustomValues  map[string]string `json:"customValues,omitempty"`
+	VersionPolicy string            `json:"versionPolicy,omitempty"`
+	SensitiveKeys []string          `json:"sensitiveKeys,omitempty"`
+	AnonymousRead *bool             `json:"anonymousRead,omitempty"`
+	Announcements []string          `json:"announcements,omitempty"`
+	CacheTTL      *int              `json:"cacheTTL,omitempty"`
+	Aliases       map[string]string `json:"aliases,omitempty"`
+	IndexAliases  bool              `json:"indexAliases,omitempty"`
+}
+
+// RegistryResource is a Registry custom resource
+type RegistryResource struct {
+	Metadata ObjectMeta   `json:"metadata"`
+	Spec     RegistrySpec `json:"spec"`
+	Status   Status       `json:"status,omitempty"`
+}
+
+// RegistryName returns the name of the registry on the server
+func (r *RegistryResource) RegistryName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Metadata.Name
+}
+
+// Desired returns the registry the server should hold
+func (r *RegistryResource) Desired() *models.Registry {
+	registry := &models.Registry{
+		Name:          r.RegistryName(),
+		Description:   r.Spec.Description,
+		Admins:        slices.Clone(r.Spec.Admins),
+		CustomValues:  make(map[string]string, len(r.Spec.CustomValues)),
+		VersionPolicy: r.Spec.VersionPolicy,
+		SensitiveKeys: slices.Clone(r.Spec.SensitiveKeys),
+		AnonymousRead: r.Spec.AnonymousRead,
+		Announcements: slices.Clone(r.Spec.Announcements),
+		CacheTTL:      r.Spec.CacheTTL,
+		Aliases:       maps.Clone(r.Spec.Aliases),
+		IndexAliases:  r.Spec.IndexAliases,
+	}
+	for key, value := range r.Spec.CustomValues {
+		registry.CustomValues[key] = value
+	}
+	models.NormalizeRegistry(registry)
+	return registry
+}
+
+// PackageSpec is the desired metadata of a package. Versions are published
+// through the API, by release pipelines.
+type PackageSpec struct {
+	Registry     string            `json:"registry"`       // Name of the registry on the server
+	Name         string            `json:"name,omitempty"` // Defaults to metadata.name
+	Description  string            `json:"description,omitempty"`
+	Maintainers  []string          `json:"maintainers,omitempty"`
+	CustomValues map[string]string `json:"customValues,omitempty"`
+}
+
+// PackageResource is a Package custom resource
+type PackageResource struct {
+	Metadata ObjectMeta  `json:"metadata"`
+	Spec     PackageSpec `json:"spec"`
+	Status   Status      `json:"status,omitempty"`
+}
+
+// PackageName returns the name of the package on the server
+func (p *PackageResource) PackageName() string {
+	if p.Spec.Name != "" {
+		return p.Spec.Name
+	}
+	return p.Metadata.Name
+}
+
+// Desired returns the package metadata the server should hold
+func (p *PackageResource) Desired() *models.Package {
+	pkg := &models.Package{
+		Name:         p.PackageName(),
+		Description:  p.Spec.Description,
+		Maintainers:  slices.Clone(p.Spec.Maintainers),
+		CustomValues: make(map[string]string, len(p.Spec.CustomValues)),
+	}
+	for key, value := range p.Spec.CustomValues {
+		pkg.CustomValues[key] = value
+	}
+	models.NormalizePackage(pkg)
+	return pkg
+}
@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic background work
+type Job func(ctx context.Context) error
+
+type job struct {
+	name     string
+	interval time.Duration
+	fn       Job
+}
+
+// Scheduler runs jobs at fixed intervals in the background.
+// Each job runs in its own goroutine; a run never overlaps the previous run
+// of the same job. Jobs run once right after Start so work that fell due
+// while the server was down is picked up immediately.
+type Scheduler struct {
+	jobs   []job
+	logger *slog.Logger
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a scheduler with no jobs
+func New(logger *slog.Logger) *Scheduler {
+	return &Scheduler{logger: logger}
+}
+
+// Every registers a job to run every interval. Must be called before Start.
+// Jobs with a non-positive interval are disabled.
+func (s *Scheduler) Every(name string, interval time.Duration, fn Job) {
+	if interval <= 0 {
+		s.logger.Info("Scheduled job disabled", "job", name)
+		return
+	}
+	s.jobs = append(s.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+// Start starts running the registered jobs
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+		s.logger.Info("Scheduled job started",
+			"job", j.name,
+			"interval", j.interval.String())
+	}
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		s.run(ctx, j)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, j job) {
+	start := time.Now()
+	if err := j.fn(ctx); err != nil {
+		s.logger.Error("Scheduled job failed",
+			"job", j.name,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"error", err)
+		return
+	}
+	s.logger.Debug("Scheduled job completed",
+		"job", j.name,
+		"duration_ms", time.Since(start).Milliseconds())
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func TestScheduler_RunsJobsUntilStopped(t *testing.T) {
+	s := New(testLogger())
+
+	var runs, failures atomic.Int32
+	s.Every("count", 10*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	s.Every("fail", 10*time.Millisecond, func(ctx context.Context) error {
+		failures.Add(1)
+		return errors.New("boom")
+	})
+	s.Start()
+
+	// Failing jobs keep being scheduled
+	assert.Eventually(t, func() bool {
+		return runs.Load() >= 3 && failures.Load() >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	s.Stop()
+	stopped := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load())
+}
+
+func TestScheduler_RunsImmediatelyOnStart(t *testing.T) {
+	s := New(testLogger())
+
+	ran := make(chan struct{}, 1)
+	s.Every("once", time.Hour, func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+	s.Start()
+	defer s.Stop()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run on start")
+	}
+}
+
+func TestScheduler_DisabledJob(t *testing.T) {
+	s := New(testLogger())
+	s.Every("disabled", 0, func(ctx context.Context) error {
+		t.Error("disabled job ran")
+		return nil
+	})
+	assert.Empty(t, s.jobs)
+
+	// Stop before Start is a no-op
+	s.Stop()
+}
@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher_RoundTrip(t *testing.T) {
+	c, err := NewCipher([]byte(strings.Repeat("a", KeySize)))
+	require.NoError(t, err)
+
+	encrypted, err := c.Encrypt("https://internal.example.com")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "internal.example.com")
+
+	// Encrypting twice is a no-op
+	again, err := c.Encrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, encrypted, again)
+
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "https://internal.example.com", decrypted)
+
+	// Plain values pass through
+	plain, err := c.Decrypt("plain")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", plain)
+}
+
+func TestCipher_WrongKey(t *testing.T) {
+	c1, err := NewCipher([]byte(strings.Repeat("a", KeySize)))
+	require.NoError(t, err)
+	c2, err := NewCipher([]byte(strings.Repeat("b", KeySize)))
+	require.NoError(t, err)
+
+	encrypted, err := c1.Encrypt("secret")
+	require.NoError(t, err)
+
+	_, err = c2.Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = c1.Decrypt(Prefix + "not-base64!")
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestNewCipherFromBase64(t *testing.T) {
+	_, err := NewCipherFromBase64(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", KeySize))))
+	assert.NoError(t, err)
+
+	_, err = NewCipherFromBase64(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+
+	_, err = NewCipherFromBase64("%%%")
+	assert.Error(t, err)
+}
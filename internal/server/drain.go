@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// Drain takes the server out of rotation ahead of shutdown: /readyz fails,
+// so load balancers stop routing new requests to it, and responses close
+// their connection instead of keeping it alive, while requests are still
+// served for server.drain_duration. Drain blocks until then; later calls
+// wait for the same deadline.
+func (s *Server) Drain() {
+	s.drainOnce.Do(func() {
+		s.draining.Store(true)
+		for _, httpServer := range s.httpServers {
+			httpServer.SetKeepAlivesEnabled(false)
+		}
+		s.logger.Info("Draining connections",
+			"drain_duration", s.config.Server.DrainDuration.String(),
+			"in_flight", s.inFlight.Load())
+		time.AfterFunc(s.config.Server.DrainDuration, func() { close(s.drained) })
+	})
+	<-s.drained
+}
+
+// Draining reports whether the server is being taken out of rotation
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// InFlight returns the number of requests being served
+func (s *Server) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// preStop starts draining and answers once the drain duration has passed,
+// so a Kubernetes preStop hook holds SIGTERM until load balancers have
+// noticed the failing readiness probe
+func (s *Server) preStop(w http.ResponseWriter, r *http.Request) {
+	drained := make(chan struct{})
+	go func() {
+		s.Drain()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-r.Context().Done():
+		return
+	}
+	s.writeReadiness(w, http.StatusOK, "draining")
+}
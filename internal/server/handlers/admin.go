@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+	"github.com/criteo/command-launcher-registry/internal/verify"
+)
+
+// AdminHandler handles administrative operations
+type AdminHandler struct {
+	store    storage.Store
+	reload   func() error
+	restore  func(ctx context.Context, backup string) error
+	versions storage.Versioned // nil unless the backend keeps versions
+	rollback func(ctx context.Context, version string) error
+	verifier *verify.Verifier     // nil when archive verification is disabled
+	creds    *storage.Credentials // nil for local storage
+	capture  *middleware.RequestCapture
+	logger   *slog.Logger
+}
+
+// NewAdminHandler creates a new admin handler.
+// reload re-reads the server configuration, as on SIGHUP.
+func NewAdminHandler(store storage.Store, reload func() error, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{
+		store:  store,
+		reload: reload,
+		logger: logger,
+	}
+}
+
+// SetRestore enables restoring backups: restore replaces the data with the
+// named backup
+func (h *AdminHandler) SetRestore(restore func(ctx context.Context, backup string) error) {
+	h.restore = restore
+}
+
+// SetVersions lists the versions of the data versions keeps, and enables
+// rolling back to them: rollback replaces the data with the given version
+func (h *AdminHandler) SetVersions(versions storage.Versioned, rollback func(ctx context.Context, version string) error) {
+	h.versions = versions
+	h.rollback = rollback
+}
+
+// SetVerifier reports the archive verifications of verifier
+func (h *AdminHandler) SetVerifier(verifier *verify.Verifier) {
+	h.verifier = verifier
+}
+
+// SetCredentials reports and re-authenticates the storage tokens of creds
+func (h *AdminHandler) SetCredentials(creds *storage.Credentials) {
+	h.creds = creds
+}
+
+// SetRequestCapture reports the failed write requests captured by capture
+func (h *AdminHandler) SetRequestCapture(capture *middleware.RequestCapture) {
+	h.capture = capture
+}
+
+// ReloadResponse represents the reload response
+type ReloadResponse struct {
+	Status string `json:"status"`
+}
+
+// Reload handles POST /api/v1/admin/reload
+func (h *AdminHandler) Reload(w http.ResponseWriter, r *http.Request) {
+	var actor string
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		actor = user.Username
+	}
+
+	if err := h.reload(); err != nil {
+		h.logger.Error("Configuration reload failed, keeping current configuration",
+			"user", actor,
+			"error", err)
+		apierrors.WriteError(w, apierrors.ErrCodeInternalError, "Configuration reload failed: "+err.Error(), http.StatusInternalServerError, nil)
+		return
+	}
+
+	h.logger.Info("Configuration reloaded via API",
+		"user", actor,
+		"remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ReloadResponse{Status: "reloaded"})
+}
+
+// RestoreRequest names the backup to restore
+type RestoreRequest struct {
+	Backup string `json:"backup"` // Snapshot name, e.g. registry-2024-05-01T12-00-00Z.json
+}
+
+// RestoreResponse represents the restore response
+type RestoreResponse struct {
+	Status string `json:"status"`
+	Backup string `json:"backup"`
+}
+
+// Restore handles POST /api/v1/admin/restore
+func (h *AdminHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	if h.restore == nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Storage backups are not configured", http.StatusBadRequest, nil)
+		return
+	}
+
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+	if req.Backup == "" {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "backup is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	var actor string
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		actor = user.Username
+	}
+
+	if err := h.restore(r.Context(), req.Backup); err != nil {
+		if errors.Is(err, storage.ErrBackupNotFound) {
+			apierrors.WriteError(w, apierrors.ErrCodeBackupNotFound, "Backup not found", http.StatusNotFound, map[string]string{"backup": req.Backup})
+			return
+		}
+		// The error names buckets and paths: logged, not returned
+		h.logger.Error("Storage restore failed, keeping current data",
+			"user", actor,
+			"backup", req.Backup,
+			"remote_addr", r.RemoteAddr,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Storage restore failed, current data kept")
+		return
+	}
+
+	// Audit trail: the data of every registry was replaced
+	h.logger.Warn("Storage restored from backup via API",
+		"user", actor,
+		"backup", req.Backup,
+		"remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RestoreResponse{Status: "restored", Backup: req.Backup})
+}
+
+// StorageVersionsResponse lists the versions of the data the storage keeps
+type StorageVersionsResponse struct {
+	Current  string                `json:"current"`
+	Versions []storage.DataVersion `json:"versions"`
+}
+
+// GetStorageVersions handles GET /api/v1/admin/storage-versions
+func (h *AdminHandler) GetStorageVersions(w http.ResponseWriter, r *http.Request) {
+	if h.versions == nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Storage backend does not keep versions", http.StatusBadRequest, nil)
+		return
+	}
+	current, err := h.versions.CurrentVersion(r.Context())
+	if err != nil {
+		apierrors.WriteStorageFailure(w, err, "Failed to read storage version")
+		return
+	}
+	versions, err := h.versions.Versions(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list storage versions", "error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to list storage versions")
+		return
+	}
+	if versions == nil {
+		versions = []storage.DataVersion{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(StorageVersionsResponse{Current: current, Versions: versions})
+}
+
+// RollbackRequest names the storage version to roll back to
+type RollbackRequest struct {
+	Version string `json:"version"`
+}
+
+// RollbackResponse represents the rollback response
+type RollbackResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+// Rollback handles POST /api/v1/admin/rollback
+func (h *AdminHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	if h.rollback == nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Storage backend does not keep versions", http.StatusBadRequest, nil)
+		return
+	}
+
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+	if req.Version == "" {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "version is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	var actor string
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		actor = user.Username
+	}
+
+	if err := h.rollback(r.Context(), req.Version); err != nil {
+		if errors.Is(err, storage.ErrVersionNotFound) {
+			apierrors.WriteError(w, apierrors.ErrCodeStorageVersionNotFound, "Storage version not found", http.StatusNotFound, map[string]string{"version": req.Version})
+			return
+		}
+		// The error names buckets and paths: logged, not returned
+		h.logger.Error("Storage rollback failed, keeping current data",
+			"user", actor,
+			"version", req.Version,
+			"remote_addr", r.RemoteAddr,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Storage rollback failed, current data kept")
+		return
+	}
+
+	// Audit trail: the data of every registry was replaced
+	h.logger.Warn("Storage rolled back via API",
+		"user", actor,
+		"version", req.Version,
+		"remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RollbackResponse{Status: "rolled_back", Version: req.Version})
+}
+
+// QuarantineResponse represents the quarantined records response
+type QuarantineResponse struct {
+	Counts  models.QuarantineCounts     `json:"counts"`
+	Records []*models.QuarantinedRecord `json:"records"`
+}
+
+// GetQuarantine handles GET /api/v1/admin/quarantine
+func (h *AdminHandler) GetQuarantine(w http.ResponseWriter, r *http.Request) {
+	records, err := h.store.GetQuarantine(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to read quarantined records", "error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to read quarantined records")
+		return
+	}
+	if records == nil {
+		records = []*models.QuarantinedRecord{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(QuarantineResponse{
+		Counts:  models.CountQuarantined(records),
+		Records: records,
+	})
+}
+
+// GetVerification handles GET /api/v1/admin/verification
+func (h *AdminHandler) GetVerification(w http.ResponseWriter, r *http.Request) {
+	report := verify.Report{Counts: map[string]int{}, Problems: []verify.Result{}}
+	if h.verifier != nil {
+		report = h.verifier.Report()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// StorageCredentialsResponse reports the storage token in use
+type StorageCredentialsResponse struct {
+	Enabled bool `json:"enabled"` // False for local storage, which uses no token
+	*storage.CredentialStatus
+}
+
+// GetStorageCredentials handles GET /api/v1/admin/storage-credentials
+func (h *AdminHandler) GetStorageCredentials(w http.ResponseWriter, r *http.Request) {
+	var response StorageCredentialsResponse
+	if h.creds != nil {
+		status := h.creds.Status()
+		response = StorageCredentialsResponse{Enabled: true, CredentialStatus: &status}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Reauthenticate handles POST /api/v1/admin/storage-credentials/reauthenticate
+func (h *AdminHandler) Reauthenticate(w http.ResponseWriter, r *http.Request) {
+	if h.creds == nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Local storage does not use storage tokens", http.StatusBadRequest, nil)
+		return
+	}
+
+	var actor string
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		actor = user.Username
+	}
+
+	status, err := h.creds.Reauthenticate(r.Context())
+	if err != nil {
+		h.logger.Error("Storage re-authentication failed",
+			"user", actor,
+			"active", status.Active,
+			"error", err)
+		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Storage re-authentication failed: "+err.Error(), http.StatusServiceUnavailable, map[string]string{"active": status.Active})
+		return
+	}
+
+	h.logger.Info("Re-authenticated to storage via API",
+		"user", actor,
+		"active", status.Active)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(StorageCredentialsResponse{Enabled: true, CredentialStatus: &status})
+}
+
+// CapturedRequestsResponse represents the captured failed write requests
+type CapturedRequestsResponse struct {
+	Enabled  bool                         `json:"enabled"`  // False unless server.capture_failed_requests is set
+	Requests []middleware.CapturedRequest `json:"requests"` // Newest first
+}
+
+// GetCapturedRequests handles GET /api/v1/admin/captured-requests
+func (h *AdminHandler) GetCapturedRequests(w http.ResponseWriter, r *http.Request) {
+	response := CapturedRequestsResponse{Requests: []middleware.CapturedRequest{}}
+	if h.capture != nil {
+		response.Enabled = h.capture.Enabled()
+		response.Requests = h.capture.Records()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ClearCapturedRequests handles DELETE /api/v1/admin/captured-requests
+func (h *AdminHandler) ClearCapturedRequests(w http.ResponseWriter, r *http.Request) {
+	if h.capture != nil {
+		h.capture.Clear()
+	}
+
+	var actor string
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		actor = user.Username
+	}
+	h.logger.Info("Captured requests cleared via API", "user", actor)
+
+	w.WriteHeader(http.StatusNoContent)
+}
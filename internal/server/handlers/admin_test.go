@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestAdminHandler_Reload(t *testing.T) {
+	var reloadErr error
+	calls := 0
+	handler := NewAdminHandler(nil, func() error {
+		calls++
+		return reloadErr
+	}, slog.Default())
+
+	rec := httptest.NewRecorder()
+	handler.Reload(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/reload", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"reloaded"}`, rec.Body.String())
+
+	reloadErr = errors.New("failed to read users file")
+	rec = httptest.NewRecorder()
+	handler.Reload(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/reload", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "failed to read users file")
+	assert.Equal(t, 2, calls)
+}
+
+func TestAdminHandler_Restore(t *testing.T) {
+	handler := NewAdminHandler(nil, nil, slog.Default())
+	restore := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Restore(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/restore", strings.NewReader(body)))
+		return rec
+	}
+
+	// Without backups, nothing can be restored
+	assert.Equal(t, http.StatusBadRequest, restore(`{"backup":"registry-2024-05-01T12-00-00Z.json"}`).Code)
+
+	var restored []string
+	handler.SetRestore(func(ctx context.Context, backup string) error {
+		if backup != "registry-2024-05-01T12-00-00Z.json" {
+			return storage.ErrBackupNotFound
+		}
+		restored = append(restored, backup)
+		return nil
+	})
+
+	rec := restore(`{"backup":"registry-2024-05-01T12-00-00Z.json"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"restored","backup":"registry-2024-05-01T12-00-00Z.json"}`, rec.Body.String())
+	assert.Equal(t, []string{"registry-2024-05-01T12-00-00Z.json"}, restored)
+
+	rec = restore(`{"backup":"registry.json"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "BACKUP_NOT_FOUND")
+
+	assert.Equal(t, http.StatusBadRequest, restore(`{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, restore(`not json`).Code)
+	assert.Len(t, restored, 1)
+
+	// Storage errors are logged, not returned
+	handler.SetRestore(func(ctx context.Context, backup string) error {
+		return fmt.Errorf("%w: GET s3://internal-backups/registry.json: access denied", storage.ErrStorageUnavailable)
+	})
+	rec = restore(`{"backup":"registry-2024-05-01T12-00-00Z.json"}`)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "STORAGE_UNAVAILABLE")
+	assert.NotContains(t, rec.Body.String(), "internal-backups")
+}
+
+// fakeVersioned keeps the versions of the data in memory
+type fakeVersioned struct {
+	current  string
+	versions []storage.DataVersion
+}
+
+func (f *fakeVersioned) CurrentVersion(ctx context.Context) (string, error) {
+	return f.current, nil
+}
+
+func (f *fakeVersioned) Versions(ctx context.Context) ([]storage.DataVersion, error) {
+	return f.versions, nil
+}
+
+func (f *fakeVersioned) Rollback(ctx context.Context, id string) (uint64, error) {
+	for _, v := range f.versions {
+		if v.ID == id {
+			f.current = id
+			return 1, nil
+		}
+	}
+	return 0, storage.ErrVersionNotFound
+}
+
+func TestAdminHandler_Rollback(t *testing.T) {
+	handler := NewAdminHandler(nil, nil, slog.Default())
+	rollback := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.Rollback(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/rollback", strings.NewReader(body)))
+		return rec
+	}
+	list := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.GetStorageVersions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/storage-versions", nil))
+		return rec
+	}
+
+	// Without a versioned backend, there is nothing to roll back to
+	assert.Equal(t, http.StatusBadRequest, rollback(`{"version":"v1"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, list().Code)
+
+	versions := &fakeVersioned{current: "v2", versions: []storage.DataVersion{{ID: "v2", Current: true}, {ID: "v1"}}}
+	handler.SetVersions(versions, func(ctx context.Context, version string) error {
+		_, err := versions.Rollback(ctx, version)
+		return err
+	})
+
+	rec := list()
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response StorageVersionsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "v2", response.Current)
+	assert.Len(t, response.Versions, 2)
+
+	rec = rollback(`{"version":"v1"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"rolled_back","version":"v1"}`, rec.Body.String())
+	assert.Equal(t, "v1", versions.current)
+
+	rec = rollback(`{"version":"v0"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "STORAGE_VERSION_NOT_FOUND")
+
+	assert.Equal(t, http.StatusBadRequest, rollback(`{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, rollback(`not json`).Code)
+
+	// Storage errors are logged, not returned
+	handler.SetVersions(versions, func(ctx context.Context, version string) error {
+		return fmt.Errorf("%w: PUT s3://internal-registry/registry.json: access denied", storage.ErrStorageUnavailable)
+	})
+	rec = rollback(`{"version":"v2"}`)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "STORAGE_UNAVAILABLE")
+	assert.NotContains(t, rec.Body.String(), "internal-registry")
+}
+
+func TestAdminHandler_GetQuarantine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"registries": {"tools": {"packages": {"-bad": {}}}}}`), 0o644))
+	store, err := storage.NewFileStorage(path, "", slog.Default())
+	require.NoError(t, err)
+
+	handler := NewAdminHandler(store, nil, slog.Default())
+	rec := httptest.NewRecorder()
+	handler.GetQuarantine(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/quarantine", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response QuarantineResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Counts.Packages)
+	require.Len(t, response.Records, 1)
+	assert.Equal(t, "tools/-bad", response.Records[0].Key)
+}
+
+func TestAdminHandler_GetVerification_Disabled(t *testing.T) {
+	handler := NewAdminHandler(nil, nil, slog.Default())
+	rec := httptest.NewRecorder()
+	handler.GetVerification(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/verification", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"enabled":false,"versions":0,"counts":{},"problems":[]}`, rec.Body.String())
+}
+
+func TestAdminHandler_StorageCredentials(t *testing.T) {
+	// Local storage uses no token
+	handler := NewAdminHandler(nil, nil, slog.Default())
+	rec := httptest.NewRecorder()
+	handler.GetStorageCredentials(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/storage-credentials", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"enabled":false}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.Reauthenticate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/storage-credentials/reauthenticate", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	handler.SetCredentials(storage.NewCredentials("primary-token", "secondary-token", slog.Default()))
+	rec = httptest.NewRecorder()
+	handler.GetStorageCredentials(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/storage-credentials", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"enabled":true,"active":"primary","secondary_configured":true}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.Reauthenticate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/storage-credentials/reauthenticate", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response StorageCredentialsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.True(t, response.Enabled)
+	assert.Equal(t, storage.CredentialPrimary, response.Active)
+	assert.NotNil(t, response.SwitchedAt)
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// APIKeyHandler manages the API keys of registries, which registry admins
+// hand out to CI pipelines
+type APIKeyHandler struct {
+	store  storage.Store
+	logger *slog.Logger
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(store storage.Store, logger *slog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// CreateAPIKeyRequest represents the API key creation request
+type CreateAPIKeyRequest struct {
+	PackagePrefix string     `json:"package_prefix"`
+	Description   string     `json:"description,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyResponse represents an API key, without its secret
+type APIKeyResponse struct {
+	ID            string     `json:"id"`
+	Registry      string     `json:"registry"`
+	PackagePrefix string     `json:"package_prefix"`
+	Description   string     `json:"description,omitempty"`
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyCreatedResponse represents a new API key with its token, which is
+// only ever returned here
+type APIKeyCreatedResponse struct {
+	APIKeyResponse
+	Token string `json:"token"` // <id>:<secret>, the HTTP Basic credentials of the key
+}
+
+func presentAPIKey(key *models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:            key.ID,
+		Registry:      key.Registry,
+		PackagePrefix: key.PackagePrefix,
+		Description:   key.Description,
+		CreatedBy:     key.CreatedBy,
+		CreatedAt:     key.CreatedAt,
+		ExpiresAt:     key.ExpiresAt,
+	}
+}
+
+// registryAdmin looks up a registry and checks the caller administers it:
+// holds the admin scope or is listed in its admins. API keys never do. On
+// failure it writes an error response and returns nil.
+func (h *APIKeyHandler) registryAdmin(w http.ResponseWriter, r *http.Request, registryName string) *models.Registry {
+	registry, err := h.store.GetRegistry(r.Context(), registryName)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return nil
+		}
+
+		h.logger.Error("Failed to get registry",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve registry")
+		return nil
+	}
+
+	user := auth.UserFromContext(r.Context())
+	if user == nil || user.APIKey != nil || !(user.HasScope(auth.ScopeAdmin) || registry.IsAdmin(user.Username)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil
+	}
+	return registry
+}
+
+// CreateAPIKey handles POST /api/v1/registry/:name/api-keys
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+	if h.registryAdmin(w, r, registryName) == nil {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	key, secret, err := models.NewAPIKey(registryName, req.PackagePrefix, req.Description, user.Username, req.ExpiresAt)
+	if err != nil {
+		h.logger.Error("Failed to generate API key",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteError(w, apierrors.ErrCodeInternalError, "Failed to generate API key", http.StatusInternalServerError, nil)
+		return
+	}
+	if err := models.ValidateAPIKey(key); err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	existing, err := h.store.ListAPIKeys(r.Context(), registryName)
+	if err != nil {
+		h.logger.Error("Failed to list API keys",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve API keys")
+		return
+	}
+	if len(existing) >= models.MaxAPIKeysPerRegistry {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError,
+			fmt.Sprintf("registry already has %d API keys, delete unused ones first", models.MaxAPIKeysPerRegistry),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if err := h.store.CreateAPIKey(r.Context(), key); err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to create API key",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to create API key")
+		return
+	}
+
+	h.logger.Info("API key created",
+		"registry", registryName,
+		"id", key.ID,
+		"package_prefix", key.PackagePrefix,
+		"user", user.Username,
+		"remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(APIKeyCreatedResponse{
+		APIKeyResponse: presentAPIKey(key),
+		Token:          key.ID + ":" + secret,
+	})
+}
+
+// ListAPIKeys handles GET /api/v1/registry/:name/api-keys
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+	if h.registryAdmin(w, r, registryName) == nil {
+		return
+	}
+
+	keys, err := h.store.ListAPIKeys(r.Context(), registryName)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to list API keys",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve API keys")
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, presentAPIKey(key))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteAPIKey handles DELETE /api/v1/registry/:name/api-keys/:id
+func (h *APIKeyHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+	id := chi.URLParam(r, "id")
+	if h.registryAdmin(w, r, registryName) == nil {
+		return
+	}
+
+	if err := h.store.DeleteAPIKey(r.Context(), registryName, id); err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "api_key")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to delete API key",
+			"registry", registryName,
+			"id", id,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to delete API key")
+		return
+	}
+
+	h.logger.Info("API key deleted",
+		"registry", registryName,
+		"id", id,
+		"remote_addr", r.RemoteAddr)
+
+	w.WriteHeader(http.StatusNoContent)
+}
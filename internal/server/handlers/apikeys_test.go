@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestAPIKeyHandler(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", []string{"Alice"}, nil)))
+
+	handler := NewAPIKeyHandler(store, logger)
+	router := chi.NewRouter()
+	router.Get("/api/v1/registry/{name}/api-keys", handler.ListAPIKeys)
+	router.Post("/api/v1/registry/{name}/api-keys", handler.CreateAPIKey)
+	router.Delete("/api/v1/registry/{name}/api-keys/{id}", handler.DeleteAPIKey)
+
+	send := func(method, path, body string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if user != nil {
+			req = req.WithContext(auth.WithUser(req.Context(), user))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	alice := &auth.User{Username: "alice"}
+
+	// Registry admins create keys; the token is returned once
+	rec := send(http.MethodPost, "/api/v1/registry/tools/api-keys", `{"package_prefix":"payments-","description":"payments CI"}`, alice)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created APIKeyCreatedResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	assert.Equal(t, "tools", created.Registry)
+	assert.Equal(t, "payments-", created.PackagePrefix)
+	assert.Equal(t, "alice", created.CreatedBy)
+	id, secret, ok := strings.Cut(created.Token, ":")
+	require.True(t, ok)
+	assert.Equal(t, created.ID, id)
+	key, err := store.GetAPIKey(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, key.Matches(secret))
+
+	// Listing never returns secrets or their hashes
+	rec = send(http.MethodGet, "/api/v1/registry/tools/api-keys", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "secret_hash")
+	assert.NotContains(t, rec.Body.String(), "token")
+	var keys []APIKeyResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&keys))
+	assert.Equal(t, []APIKeyResponse{created.APIKeyResponse}, keys)
+
+	// Other users, and API keys themselves, cannot manage keys
+	for _, user := range []*auth.User{nil, {Username: "bob"}, {Username: id, APIKey: key}} {
+		assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/registry/tools/api-keys", "", user).Code)
+		assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api/v1/registry/tools/api-keys", `{}`, user).Code)
+	}
+	admin := &auth.User{Username: "root", Scopes: []string{auth.ScopeAdmin}}
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/v1/registry/tools/api-keys", "", admin).Code)
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/api/v1/registry/tools/api-keys", `{"package_prefix":"Payments"}`, alice).Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/api/v1/registry/missing/api-keys", `{}`, admin).Code)
+
+	rec = send(http.MethodDelete, "/api/v1/registry/tools/api-keys/"+id, "", alice)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = send(http.MethodDelete, "/api/v1/registry/tools/api-keys/"+id, "", alice)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "API_KEY_NOT_FOUND")
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+	"github.com/criteo/command-launcher-registry/internal/verify"
+)
+
+// BrokenLinksHandler reports version URLs found broken by the link checker
+type BrokenLinksHandler struct {
+	store   storage.Store
+	checker *verify.LinkChecker // nil when link checking is disabled
+	logger  *slog.Logger
+}
+
+// NewBrokenLinksHandler creates a new broken links handler
+func NewBrokenLinksHandler(store storage.Store, checker *verify.LinkChecker, logger *slog.Logger) *BrokenLinksHandler {
+	return &BrokenLinksHandler{
+		store:   store,
+		checker: checker,
+		logger:  logger,
+	}
+}
+
+// BrokenLinksResponse represents the broken links of a registry
+type BrokenLinksResponse struct {
+	Registry  string        `json:"registry"`
+	Enabled   bool          `json:"enabled"`
+	CheckedAt *time.Time    `json:"checked_at,omitempty"` // Last run of the link checker
+	Links     []verify.Link `json:"links"`
+}
+
+// GetBrokenLinks handles GET /api/v1/registry/:name/broken-links
+func (h *BrokenLinksHandler) GetBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+
+	if _, err := h.store.GetRegistry(r.Context(), registryName); err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to get registry",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve broken links")
+		return
+	}
+
+	response := BrokenLinksResponse{Registry: registryName, Links: []verify.Link{}}
+	if h.checker != nil {
+		response.Enabled = true
+		links, checkedAt := h.checker.Broken(registryName)
+		response.Links = links
+		if !checkedAt.IsZero() {
+			response.CheckedAt = &checkedAt
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+	"github.com/criteo/command-launcher-registry/internal/verify"
+)
+
+func TestBrokenLinksHandler(t *testing.T) {
+	logger := slog.Default()
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(context.Background(), models.NewRegistry("tools", "", nil, nil)))
+
+	get := func(checker *verify.LinkChecker, name string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Get("/api/v1/registry/{name}/broken-links", NewBrokenLinksHandler(store, checker, logger).GetBrokenLinks)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/registry/"+name+"/broken-links", nil))
+		return rec
+	}
+
+	rec := get(nil, "tools")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"registry":"tools","enabled":false,"links":[]}`, rec.Body.String())
+
+	// Not run yet
+	rec = get(verify.NewLinkChecker(store, 0, logger), "tools")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"registry":"tools","enabled":true,"links":[]}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get(nil, "missing").Code)
+}
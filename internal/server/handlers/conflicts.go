@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// ConflictHandler reports names that collide across registries
+type ConflictHandler struct {
+	store  storage.Store
+	policy storage.PackageNamePolicy
+	logger *slog.Logger
+}
+
+// NewConflictHandler creates a new conflict handler
+func NewConflictHandler(store storage.Store, policy storage.PackageNamePolicy, logger *slog.Logger) *ConflictHandler {
+	return &ConflictHandler{
+		store:  store,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// PackageConflictReport lists package names used by more than one registry
+type PackageConflictReport struct {
+	Policy    storage.PackageNamePolicy    `json:"policy"`
+	Conflicts []models.PackageNameConflict `json:"conflicts"`
+}
+
+// ListPackageConflicts handles GET /api/v1/conflicts/packages
+// Collisions created before the unique policy was enabled are still listed.
+func (h *ConflictHandler) ListPackageConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := storage.PackageNameConflicts(r.Context(), h.store)
+	if err != nil {
+		h.logger.Error("Failed to list package name conflicts", "error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to list package name conflicts")
+		return
+	}
+
+	h.logger.Debug("Package name conflicts listed", "count", len(conflicts))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PackageConflictReport{
+		Policy:    h.policy,
+		Conflicts: conflicts,
+	})
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// maxGraphQLBodySize bounds the size of a GraphQL request body
+const maxGraphQLBodySize = 1 << 20
+
+// GraphQLHandler serves a read-only GraphQL view of registries, packages,
+// versions and their counts, for dashboards that need several levels of
+// nesting in a single request. The schema has no mutations: writes go
+// through the REST endpoints.
+type GraphQLHandler struct {
+	store  storage.Store
+	logger *slog.Logger
+	schema graphql.Schema
+}
+
+// NewGraphQLHandler creates a new GraphQL handler
+func NewGraphQLHandler(store storage.Store, logger *slog.Logger) *GraphQLHandler {
+	h := &GraphQLHandler{
+		store:  store,
+		logger: logger,
+	}
+	// The schema is static, so an error is a programming error caught by tests
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: h.queryType()})
+	if err != nil {
+		panic("invalid GraphQL schema: " + err.Error())
+	}
+	h.schema = schema
+	return h
+}
+
+// GraphQLRequest is a GraphQL query sent as a POST body
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// ServeGraphQL handles GET and POST /api/v1/graphql
+// GET takes the query in ?query= (and ?operationName=); POST takes a JSON body.
+// As usual for GraphQL, query errors are reported in the "errors" field of a
+// 200 response.
+func (h *GraphQLHandler) ServeGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBodySize)).Decode(&req); err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+	if req.Query == "" {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "query is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		RootObject:     map[string]interface{}{"request": r},
+		Context:        r.Context(),
+	})
+	if result.HasErrors() {
+		h.logger.Debug("GraphQL query failed",
+			"errors", len(result.Errors),
+			"first_error", result.Errors[0].Message,
+			"remote_addr", r.RemoteAddr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// graphQLStats counts the objects below a registry, or in every registry
+type graphQLStats struct {
+	Registries        int
+	Packages          int
+	Versions          int
+	ScheduledVersions int
+}
+
+func (s *graphQLStats) add(registry *models.Registry, now time.Time) {
+	s.Registries++
+	s.Packages += len(registry.Packages)
+	for _, pkg := range registry.Packages {
+		s.Versions += len(pkg.Versions)
+		for _, v := range pkg.Versions {
+			if v.IsPending(now) {
+				s.ScheduledVersions++
+			}
+		}
+	}
+}
+
+// customValue is a custom value entry; GraphQL has no map type
+type customValue struct {
+	Key   string
+	Value string
+}
+
+func (h *GraphQLHandler) queryType() *graphql.Object {
+	customValueType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CustomValue",
+		Fields: graphql.Fields{
+			"key":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	customValuesField := func(values func(p graphql.ResolveParams) map[string]string) *graphql.Field {
+		return &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(customValueType))),
+			Description: "Custom values sorted by key; sensitive values are masked for non-admins",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				m := values(p)
+				entries := make([]customValue, 0, len(m))
+				for key, value := range m {
+					entries = append(entries, customValue{Key: key, Value: value})
+				}
+				sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+				return entries, nil
+			},
+		}
+	}
+
+	statsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Stats",
+		Fields: graphql.Fields{
+			"registries":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"packages":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"versions":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"scheduledVersions": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "Versions embargoed until their publish time"},
+		},
+	})
+
+	versionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Version",
+		Fields: graphql.Fields{
+			"package":        &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveVersion(func(v *models.Version) interface{} { return v.Name })},
+			"version":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"checksum":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"url":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"startPartition": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"endPartition":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"publishAt": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "Set on scheduled versions",
+				Resolve: resolveVersion(func(v *models.Version) interface{} {
+					if v.PublishAt == nil {
+						return nil
+					}
+					return *v.PublishAt
+				}),
+			},
+			"pending": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Whether the version is embargoed and left out of index.json",
+				Resolve:     resolveVersion(func(v *models.Version) interface{} { return v.IsPending(time.Now()) }),
+			},
+		},
+	})
+
+	packageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Package",
+		Fields: graphql.Fields{
+			"name":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"maintainers":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), Resolve: resolvePackage(func(p *models.Package) interface{} { return nonNil(p.Maintainers) })},
+			"customValues": customValuesField(func(p graphql.ResolveParams) map[string]string { return p.Source.(*models.Package).CustomValues }),
+			"versions": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(versionType))),
+				Description: "Versions in ascending version order",
+				Resolve: resolvePackage(func(p *models.Package) interface{} {
+					return sortedVersions(p, false)
+				}),
+			},
+			"version": &graphql.Field{
+				Type: versionType,
+				Args: graphql.FieldConfigArgument{
+					"version": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if v, ok := p.Source.(*models.Package).Versions[p.Args["version"].(string)]; ok {
+						return v, nil
+					}
+					return nil, nil
+				},
+			},
+			"latestVersion": &graphql.Field{
+				Type:        versionType,
+				Description: "Highest version, including scheduled ones",
+				Resolve: resolvePackage(func(p *models.Package) interface{} {
+					if versions := sortedVersions(p, true); len(versions) > 0 {
+						return versions[0]
+					}
+					return nil
+				}),
+			},
+		},
+	})
+
+	registryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Registry",
+		Fields: graphql.Fields{
+			"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"admins":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), Resolve: resolveRegistry(func(r *models.Registry) interface{} { return nonNil(r.Admins) })},
+			"versionPolicy": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveRegistry(func(r *models.Registry) interface{} {
+				if r.VersionPolicy == "" {
+					return models.VersionPolicySemver
+				}
+				return r.VersionPolicy
+			})},
+			"customValues": customValuesField(func(p graphql.ResolveParams) map[string]string { return p.Source.(*models.Registry).CustomValues }),
+			"packages": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(packageType))),
+				Description: "Packages sorted by name, optionally only those listing a maintainer",
+				Args: graphql.FieldConfigArgument{
+					"maintainer": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					registry := p.Source.(*models.Registry)
+					maintainer, _ := p.Args["maintainer"].(string)
+					packages := make([]*models.Package, 0, len(registry.Packages))
+					for _, pkg := range registry.Packages {
+						if maintainer == "" || pkg.IsMaintainer(maintainer) {
+							packages = append(packages, pkg)
+						}
+					}
+					sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+					return packages, nil
+				},
+			},
+			"package": &graphql.Field{
+				Type: packageType,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if pkg, ok := p.Source.(*models.Registry).Packages[p.Args["name"].(string)]; ok {
+						return pkg, nil
+					}
+					return nil, nil
+				},
+			},
+			"stats": &graphql.Field{
+				Type: graphql.NewNonNull(statsType),
+				Resolve: resolveRegistry(func(r *models.Registry) interface{} {
+					var stats graphQLStats
+					stats.add(r, time.Now())
+					return stats
+				}),
+			},
+		},
+	})
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"registries": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(registryType))),
+				Description: "Registries sorted by name",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.registries(p)
+				},
+			},
+			"registry": &graphql.Field{
+				Type: registryType,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					registry, err := h.store.GetRegistry(p.Context, p.Args["name"].(string))
+					if err == storage.ErrNotFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, h.storageError(err)
+					}
+					return presentRegistry(graphQLRequest(p), registry), nil
+				},
+			},
+			"stats": &graphql.Field{
+				Type:        graphql.NewNonNull(statsType),
+				Description: "Counts across every registry",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					registries, err := h.registries(p)
+					if err != nil {
+						return nil, err
+					}
+					var stats graphQLStats
+					now := time.Now()
+					for _, registry := range registries {
+						stats.add(registry, now)
+					}
+					return stats, nil
+				},
+			},
+		},
+	})
+}
+
+// registries lists every registry as the caller may see it, sorted by name
+func (h *GraphQLHandler) registries(p graphql.ResolveParams) ([]*models.Registry, error) {
+	registries, err := h.store.ListRegistries(p.Context)
+	if err != nil {
+		return nil, h.storageError(err)
+	}
+	r := graphQLRequest(p)
+	presented := make([]*models.Registry, 0, len(registries))
+	for _, registry := range registries {
+		presented = append(presented, presentRegistry(r, registry))
+	}
+	sort.Slice(presented, func(i, j int) bool { return presented[i].Name < presented[j].Name })
+	return presented, nil
+}
+
+// storageError logs a storage failure and returns the error shown to the caller
+func (h *GraphQLHandler) storageError(err error) error {
+	h.logger.Error("GraphQL query failed to read storage", "error", err)
+	_, msg, _ := apierrors.MapStorageError(err, "registry")
+	return &graphQLError{msg}
+}
+
+type graphQLError struct{ message string }
+
+func (e *graphQLError) Error() string { return e.message }
+
+// graphQLRequest returns the HTTP request a query is executed for
+func graphQLRequest(p graphql.ResolveParams) *http.Request {
+	return p.Info.RootValue.(map[string]interface{})["request"].(*http.Request)
+}
+
+func resolveRegistry(fn func(*models.Registry) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*models.Registry)), nil
+	}
+}
+
+func resolvePackage(fn func(*models.Package) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*models.Package)), nil
+	}
+}
+
+func resolveVersion(fn func(*models.Version) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*models.Version)), nil
+	}
+}
+
+func sortedVersions(p *models.Package, descending bool) []*models.Version {
+	versions := make([]*models.Version, 0, len(p.Versions))
+	for _, v := range p.Versions {
+		versions = append(versions, v)
+	}
+	models.SortVersions(versions, descending)
+	return versions
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
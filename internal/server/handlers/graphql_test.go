@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGraphQLHandler(t *testing.T) *GraphQLHandler {
+	t.Helper()
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	tools := models.NewRegistry("tools", "Team tools", []string{"alice"}, map[string]string{"token": "s3cret"})
+	tools.SensitiveKeys = []string{"token"}
+	require.NoError(t, store.CreateRegistry(ctx, tools))
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("infra", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deploy", "Deployer", []string{"bob"}, map[string]string{"team": "platform"})))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("lint", "", []string{"carol"}, nil)))
+
+	publishAt := time.Now().Add(time.Hour)
+	for _, v := range []*models.Version{
+		{Name: "deploy", Version: "1.2.0", Checksum: "sha256:0000000000000000000000000000000000000000000000000000000000000001", URL: "https://example.com/1.zip", EndPartition: 4},
+		{Name: "deploy", Version: "1.10.0", Checksum: "sha256:0000000000000000000000000000000000000000000000000000000000000002", URL: "https://example.com/2.zip", StartPartition: 5, EndPartition: 9, PublishAt: &publishAt},
+	} {
+		require.NoError(t, store.CreateVersion(ctx, "tools", "deploy", v))
+	}
+
+	return NewGraphQLHandler(store, logger)
+}
+
+func graphQLPost(t *testing.T, h *GraphQLHandler, user *auth.User, body string) map[string]interface{} {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(body))
+	if user != nil {
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeGraphQL(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	return result
+}
+
+func TestGraphQLHandler_NestedQuery(t *testing.T) {
+	h := newTestGraphQLHandler(t)
+
+	result := graphQLPost(t, h, &auth.User{Username: "bob"}, `{"query": "{ registries { name stats { packages versions scheduledVersions } } registry(name: \"tools\") { customValues { key value } package(name: \"deploy\") { maintainers versions { version pending } latestVersion { version startPartition } } } stats { registries packages versions } }"}`)
+	require.Nil(t, result["errors"])
+
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "infra", "stats": map[string]interface{}{"packages": 0.0, "versions": 0.0, "scheduledVersions": 0.0}},
+		map[string]interface{}{"name": "tools", "stats": map[string]interface{}{"packages": 2.0, "versions": 2.0, "scheduledVersions": 1.0}},
+	}, data["registries"])
+	assert.Equal(t, map[string]interface{}{"registries": 2.0, "packages": 2.0, "versions": 2.0}, data["stats"])
+
+	registry := data["registry"].(map[string]interface{})
+	// Sensitive values are masked for non-admins
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "token", "value": models.MaskedValue}}, registry["customValues"])
+
+	pkg := registry["package"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"bob"}, pkg["maintainers"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"version": "1.2.0", "pending": false},
+		map[string]interface{}{"version": "1.10.0", "pending": true},
+	}, pkg["versions"])
+	assert.Equal(t, map[string]interface{}{"version": "1.10.0", "startPartition": 5.0}, pkg["latestVersion"])
+}
+
+func TestGraphQLHandler_Admin(t *testing.T) {
+	h := newTestGraphQLHandler(t)
+
+	result := graphQLPost(t, h, &auth.User{Username: "root", Scopes: []string{auth.ScopeAdmin}}, `{"query": "query($name: String!) { registry(name: $name) { customValues { value } } }", "variables": {"name": "tools"}}`)
+	require.Nil(t, result["errors"])
+	registry := result["data"].(map[string]interface{})["registry"].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"value": "s3cret"}}, registry["customValues"])
+}
+
+func TestGraphQLHandler_Errors(t *testing.T) {
+	h := newTestGraphQLHandler(t)
+
+	// Missing objects resolve to null
+	result := graphQLPost(t, h, nil, `{"query": "{ registry(name: \"missing\") { name } }"}`)
+	assert.Nil(t, result["errors"])
+	assert.Equal(t, map[string]interface{}{"registry": nil}, result["data"])
+
+	// The schema is read-only
+	result = graphQLPost(t, h, nil, `{"query": "mutation { deleteRegistry(name: \"tools\") }"}`)
+	assert.NotEmpty(t, result["errors"])
+
+	// Unknown fields are reported, not ignored
+	result = graphQLPost(t, h, nil, `{"query": "{ registries { secret } }"}`)
+	assert.NotEmpty(t, result["errors"])
+
+	// GET with the query in the URL
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/graphql?query="+url.QueryEscape("{ stats { packages } }"), nil)
+	rec := httptest.NewRecorder()
+	h.ServeGraphQL(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data": {"stats": {"packages": 2}}}`, rec.Body.String())
+
+	// An empty query is a bad request
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(`{}`))
+	rec = httptest.NewRecorder()
+	h.ServeGraphQL(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -1,17 +1,38 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/criteo/command-launcher-registry/internal/storage"
 )
 
+// healthPingTimeout bounds the check of the storage backend, so a backend
+// that hangs reports degraded rather than timing the health check out
+const healthPingTimeout = 5 * time.Second
+
+// healthPingTTL is how long the result of a backend check is reused, so
+// unauthenticated /health traffic cannot turn into backend requests
+const healthPingTTL = 5 * time.Second
+
 // HealthHandler handles health check requests
 type HealthHandler struct {
-	store  storage.Store
-	logger *slog.Logger
+	store       storage.Store
+	writeBehind storage.WriteBehind   // nil when not reported
+	versioned   storage.Versioned     // nil when not reported
+	pinger      storage.Pinger        // nil when the backend is not checked
+	persistence storage.PersistHealth // nil when not reported
+	backend     string                // URI scheme of the checked backend
+	logger      *slog.Logger
+
+	pingMu     sync.Mutex // Held during a check, so concurrent requests share it
+	lastPing   CheckResult
+	lastPingAt time.Time
 }
 
 // NewHealthHandler creates a new health handler
@@ -22,19 +43,85 @@ func NewHealthHandler(store storage.Store, logger *slog.Logger) *HealthHandler {
 	}
 }
 
+// SetWriteBehind reports the writes of the storage backend not persisted
+// yet, when it persists them in the background
+func (h *HealthHandler) SetWriteBehind(writeBehind storage.WriteBehind) {
+	h.writeBehind = writeBehind
+}
+
+// SetVersioned reports the version of the stored data, when the storage
+// keeps versions
+func (h *HealthHandler) SetVersioned(versioned storage.Versioned) {
+	h.versioned = versioned
+}
+
+// SetBackend checks that the storage backend, named by its URI scheme, is
+// reachable (at most every healthPingTTL, see GetHealth), and reports
+// whether its writes reach it. Either may be nil.
+func (h *HealthHandler) SetBackend(scheme string, pinger storage.Pinger, persistence storage.PersistHealth) {
+	h.backend = scheme
+	h.pinger = pinger
+	h.persistence = persistence
+}
+
 // HealthResponse represents the health check response
 type HealthResponse struct {
-	Status string                 `json:"status"`
-	Checks map[string]CheckResult `json:"checks"`
+	Status         string                 `json:"status"`
+	Checks         map[string]CheckResult `json:"checks"`
+	PendingWrites  *storage.PendingWrites `json:"pending_writes,omitempty"`  // nil unless storage writes are debounced
+	StorageVersion string                 `json:"storage_version,omitempty"` // Version of the stored data, when the storage keeps versions
 }
 
 // CheckResult represents a single health check result
 type CheckResult struct {
-	Status  string `json:"status"`
-	Message string `json:"message,omitempty"`
+	Status    string     `json:"status"`
+	Message   string     `json:"message,omitempty"`
+	Backend   string     `json:"backend,omitempty"`    // URI scheme of the storage backend checked
+	LatencyMS float64    `json:"latency_ms,omitempty"` // Of the backend check
+	CheckedAt *time.Time `json:"checked_at,omitempty"` // Of the backend check, reused for healthPingTTL
+}
+
+// checkStorage checks storage connectivity, using ListRegistries as a basic
+// connectivity check
+func checkStorage(ctx context.Context, store storage.Store) CheckResult {
+	if _, err := store.ListRegistries(ctx); err != nil {
+		return CheckResult{Status: "unhealthy", Message: err.Error()}
+	}
+	return CheckResult{Status: "healthy"}
 }
 
-// GetHealth handles GET /api/v1/health
+// pingBackend checks the storage backend is reachable, reusing the result
+// of a check made less than healthPingTTL ago unless deep. An unreachable
+// backend only degrades the instance: reads are served from memory.
+func (h *HealthHandler) pingBackend(ctx context.Context, deep bool) CheckResult {
+	h.pingMu.Lock()
+	defer h.pingMu.Unlock()
+	if !deep && !h.lastPingAt.IsZero() && time.Since(h.lastPingAt) < healthPingTTL {
+		return h.lastPing
+	}
+
+	// Not cut short by the client going away: the result is shared
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), healthPingTimeout)
+	defer cancel()
+	start := time.Now()
+	err := h.pinger.Ping(ctx)
+	checkedAt := time.Now().UTC()
+	result := CheckResult{
+		Status:    "healthy",
+		Backend:   h.backend,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: &checkedAt,
+	}
+	if err != nil {
+		result.Status = "degraded"
+		result.Message = err.Error()
+	}
+	h.lastPing, h.lastPingAt = result, checkedAt
+	return result
+}
+
+// GetHealth handles GET /api/v1/health. Admins can ask for a fresh backend
+// check with ?deep=1; other callers get the last one.
 func (h *HealthHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
 	response := HealthResponse{
 		Status: "healthy",
@@ -42,16 +129,11 @@ func (h *HealthHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check storage connectivity
-	// We use ListRegistries as a basic connectivity check
-	_, err := h.store.ListRegistries(r.Context())
-	if err != nil {
-		response.Checks["storage"] = CheckResult{
-			Status:  "unhealthy",
-			Message: err.Error(),
-		}
+	response.Checks["storage"] = checkStorage(r.Context(), h.store)
+	if response.Checks["storage"].Status != "healthy" {
 		response.Status = "unhealthy"
 
-		h.logger.Error("Health check failed: storage unhealthy", "error", err)
+		h.logger.Error("Health check failed: storage unhealthy", "error", response.Checks["storage"].Message)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -59,11 +141,56 @@ func (h *HealthHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response.Checks["storage"] = CheckResult{
-		Status: "healthy",
+	if h.pinger != nil {
+		deep := r.URL.Query().Get("deep") == "1" && isAdmin(r)
+		response.Checks["backend"] = h.pingBackend(r.Context(), deep)
+		if response.Checks["backend"].Status != "healthy" {
+			response.Status = "degraded"
+			h.logger.Warn("Health check degraded: storage backend unreachable",
+				"backend", h.backend,
+				"error", response.Checks["backend"].Message)
+		}
+	}
+
+	// Writes kept in memory only are reported without failing the check:
+	// the instance holding them must keep running to persist them
+	pendingReported := false
+	if h.writeBehind != nil {
+		if pending, ok := h.writeBehind.PendingWrites(); ok {
+			pendingReported = true
+			response.PendingWrites = &pending
+			if pending.LastError != "" {
+				response.Status = "degraded"
+				response.Checks["persistence"] = CheckResult{
+					Status:  "degraded",
+					Message: fmt.Sprintf("%d writes not persisted: %s", pending.Count, pending.LastError),
+				}
+			} else {
+				response.Checks["persistence"] = CheckResult{Status: "healthy"}
+			}
+		}
+	}
+	// Failed writes were refused with STORAGE_UNAVAILABLE: degraded until
+	// one succeeds
+	if h.persistence != nil && !pendingReported {
+		if status := h.persistence.PersistStatus(); status.LastError != "" {
+			response.Status = "degraded"
+			response.Checks["persistence"] = CheckResult{
+				Status:  "degraded",
+				Message: fmt.Sprintf("%d writes failed: %s", status.Failures, status.LastError),
+			}
+		} else {
+			response.Checks["persistence"] = CheckResult{Status: "healthy"}
+		}
+	}
+
+	if h.versioned != nil {
+		if version, err := h.versioned.CurrentVersion(r.Context()); err == nil {
+			response.StorageVersion = version
+		}
 	}
 
-	// Return healthy response
+	// Return healthy or degraded response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+type fakePinger struct {
+	err   error
+	pings int
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	p.pings++
+	return p.err
+}
+
+type fakePersistHealth struct{ status storage.PersistStatus }
+
+func (p *fakePersistHealth) PersistStatus() storage.PersistStatus { return p.status }
+
+func TestHealthHandler_Backend(t *testing.T) {
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", slog.Default())
+	require.NoError(t, err)
+	handler := NewHealthHandler(store, slog.Default())
+	pinger := &fakePinger{}
+	persistence := &fakePersistHealth{}
+	handler.SetBackend("s3", pinger, persistence)
+
+	// Admins asking for a deep check get a fresh one
+	admin := &auth.User{Username: "root", Scopes: []string{auth.ScopeAdmin}}
+	health := func() HealthResponse {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health?deep=1", nil)
+		handler.GetHealth(rec, req.WithContext(auth.WithUser(req.Context(), admin)))
+		// Degraded instances keep serving reads from memory
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response HealthResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+
+	response := health()
+	assert.Equal(t, "healthy", response.Status)
+	assert.Equal(t, "healthy", response.Checks["backend"].Status)
+	assert.Equal(t, "s3", response.Checks["backend"].Backend)
+	assert.Equal(t, "healthy", response.Checks["persistence"].Status)
+
+	pinger.err = errors.New("connection refused")
+	response = health()
+	assert.Equal(t, "degraded", response.Status)
+	assert.Equal(t, "degraded", response.Checks["backend"].Status)
+	assert.Equal(t, "connection refused", response.Checks["backend"].Message)
+
+	// Failing writes degrade the instance even when the backend answers
+	pinger.err = nil
+	persistence.status = storage.PersistStatus{Failures: 3, LastError: "OCI storage error during push"}
+	response = health()
+	assert.Equal(t, "degraded", response.Status)
+	assert.Equal(t, "healthy", response.Checks["backend"].Status)
+	assert.Equal(t, "degraded", response.Checks["persistence"].Status)
+	assert.Equal(t, "3 writes failed: OCI storage error during push", response.Checks["persistence"].Message)
+}
+
+func TestHealthHandler_BackendCheckReused(t *testing.T) {
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", slog.Default())
+	require.NoError(t, err)
+	handler := NewHealthHandler(store, slog.Default())
+	pinger := &fakePinger{}
+	handler.SetBackend("s3", pinger, nil)
+
+	health := func(target string, user *auth.User) CheckResult {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if user != nil {
+			req = req.WithContext(auth.WithUser(req.Context(), user))
+		}
+		handler.GetHealth(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response HealthResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response.Checks["backend"]
+	}
+
+	// Unauthenticated checks, deep or not, reuse the last backend check
+	first := health("/api/v1/health", nil)
+	require.NotNil(t, first.CheckedAt)
+	pinger.err = errors.New("connection refused")
+	for _, target := range []string{"/api/v1/health", "/api/v1/health?deep=1"} {
+		check := health(target, nil)
+		assert.Equal(t, "healthy", check.Status, target)
+		assert.Equal(t, first.CheckedAt, check.CheckedAt, target)
+	}
+	assert.Equal(t, 1, pinger.pings)
+
+	// So do users without the admin scope
+	assert.Equal(t, "healthy", health("/api/v1/health?deep=1", &auth.User{Username: "alice"}).Status)
+	assert.Equal(t, 1, pinger.pings)
+
+	// Admins get a fresh one, reused in turn
+	admin := &auth.User{Username: "root", Scopes: []string{auth.ScopeAdmin}}
+	assert.Equal(t, "degraded", health("/api/v1/health?deep=1", admin).Status)
+	assert.Equal(t, "degraded", health("/api/v1/health", nil).Status)
+	assert.Equal(t, 2, pinger.pings)
+
+	// Once the result is older than healthPingTTL, the next request checks
+	handler.lastPingAt = handler.lastPingAt.Add(-healthPingTTL)
+	pinger.err = nil
+	assert.Equal(t, "healthy", health("/api/v1/health", nil).Status)
+	assert.Equal(t, 3, pinger.pings)
+}
@@ -1,31 +1,42 @@
 package handlers
 
 import (
-	"encoding/json"
+	"bytes"
+	"crypto/sha256"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/signing"
 	"github.com/criteo/command-launcher-registry/internal/storage"
 )
 
 // IndexHandler handles registry index.json requests
 type IndexHandler struct {
 	store  storage.Store
+	keys   *signing.KeySet
 	logger *slog.Logger
 }
 
-// NewIndexHandler creates a new index handler
-func NewIndexHandler(store storage.Store, logger *slog.Logger) *IndexHandler {
+// NewIndexHandler creates a new index handler.
+// When keys can sign, every index response carries a detached JWS signature.
+// Indexes support HEAD, conditional and byte-range requests.
+func NewIndexHandler(store storage.Store, keys *signing.KeySet, logger *slog.Logger) *IndexHandler {
 	return &IndexHandler{
 		store:  store,
+		keys:   keys,
 		logger: logger,
 	}
 }
 
-// GetIndex handles GET /api/v1/registry/:name/index.json
+// GetIndex handles GET and HEAD /api/v1/registry/:name/index.json
 func (h *IndexHandler) GetIndex(w http.ResponseWriter, r *http.Request) {
 	registryName := chi.URLParam(r, "name")
 
@@ -41,19 +52,143 @@ func (h *IndexHandler) GetIndex(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("Failed to get registry index",
 			"registry", registryName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to retrieve index", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve index")
 		return
 	}
 
-	// Log index request
+	item := func(i int) interface{} {
+		return entries[i]
+	}
+	signed := h.keys.Enabled()
 	h.logger.Info("Registry index served",
 		"registry", registryName,
-		"entry_count", len(entries))
+		"entry_count", len(entries),
+		"signed", signed)
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	// A registry cache_ttl replaces the server-wide index cache lifetime
+	var lastModified time.Time
+	if registry, err := h.store.GetRegistry(r.Context(), registryName); err == nil {
+		if registry.CacheTTL != nil {
+			w.Header().Set("Cache-Control", middleware.CacheDirective(registry.IndexMaxAge(0), false))
+		}
+		lastModified = indexModTime(registry)
+	}
+
+	// Unsigned indexes are streamed; signed ones are encoded up front so the
+	// signature header covers the exact response bytes, and so are range
+	// requests, which need the body to slice
+	if !signed && r.Header.Get("Range") == "" {
+		if err := h.streamIndex(w, r, lastModified, len(entries), item); err != nil {
+			h.logger.Error("Failed to write registry index",
+				"registry", registryName,
+				"error", err)
+		}
+		return
+	}
+
+	var body bytes.Buffer
+	if err := encodeJSONArray(&body, len(entries), item); err != nil {
+		h.logger.Error("Failed to encode registry index",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteError(w, apierrors.ErrCodeInternalError, "Failed to encode index", http.StatusInternalServerError, nil)
+		return
+	}
+
+	if signed {
+		signature, err := h.keys.Sign(body.Bytes())
+		if err != nil {
+			h.logger.Error("Failed to sign registry index",
+				"registry", registryName,
+				"error", err)
+			apierrors.WriteError(w, apierrors.ErrCodeInternalError, "Failed to sign index", http.StatusInternalServerError, nil)
+			return
+		}
+		w.Header().Set(signing.SignatureHeader, signature)
+	}
+
+	// ServeContent answers HEAD, conditional and range requests
+	sum := sha256.Sum256(body.Bytes())
+	etag := middleware.ETag(sum[:])
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(body.Bytes()))
+}
+
+// streamIndex streams an index without holding it in memory. A first
+// encoding pass into a hash yields the ETag and Content-Length, so HEAD and
+// conditional requests are answered without sending the body.
+func (h *IndexHandler) streamIndex(w http.ResponseWriter, r *http.Request, lastModified time.Time, n int, item func(i int) interface{}) error {
+	hash := sha256.New()
+	counter := &countingWriter{w: hash}
+	if err := encodeJSONArray(counter, n, item); err != nil {
+		return err
+	}
+	etag := middleware.ETag(hash.Sum(nil))
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
 
-	// Return JSON array
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.FormatInt(counter.n, 10))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(entries)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	return encodeJSONArray(w, n, item)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no entity
+// tag is given and the modification time is known, as http.ServeContent does
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return middleware.ETagMatches(ifNoneMatch, etag)
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.After(since)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// indexModTime returns the modification time of a registry's index: the
+// newest UpdatedAt of the registry and its packages, recorded by storage so
+// every replica answers alike. It is in whole seconds as HTTP dates carry
+// no more, and zero for data stored without timestamps, which leaves the
+// index without Last-Modified.
+func indexModTime(registry *models.Registry) time.Time {
+	var newest time.Time
+	if registry.UpdatedAt != nil {
+		newest = *registry.UpdatedAt
+	}
+	for _, pkg := range registry.Packages {
+		if pkg.UpdatedAt != nil && pkg.UpdatedAt.After(newest) {
+			newest = *pkg.UpdatedAt
+		}
+	}
+	if newest.IsZero() {
+		return newest
+	}
+	return newest.UTC().Truncate(time.Second)
 }
 
 // HandleOptions handles OPTIONS /api/v1/registry/:name/index.json (CORS preflight)
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/signing"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestIndexHandler_HeadAndRanges(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, nil)))
+	checksum := "sha256:" + strings.Repeat("a", 64)
+	require.NoError(t, store.CreateVersion(ctx, "tools", "deployer", models.NewVersion("deployer", "1.0.0", checksum, "https://example.com/d.zip", 0, 9)))
+
+	// Streamed (unsigned) and buffered (signed) indexes behave the same
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	for _, keyFiles := range [][]string{nil, {keyFile}} {
+		keys, err := signing.LoadKeySet(keyFiles)
+		require.NoError(t, err)
+		name := "unsigned"
+		if keys.Enabled() {
+			name = "signed"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			handler := NewIndexHandler(store, keys, logger)
+			router := chi.NewRouter()
+			router.Get("/api/v1/registry/{name}/index.json", handler.GetIndex)
+			router.Head("/api/v1/registry/{name}/index.json", handler.GetIndex)
+
+			serve := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, "/api/v1/registry/tools/index.json", nil)
+				for k, v := range headers {
+					req.Header.Set(k, v)
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				return rec
+			}
+
+			rec := serve(http.MethodGet, nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			body := rec.Body.String()
+			etag := rec.Header().Get("ETag")
+			lastModified := rec.Header().Get("Last-Modified")
+			assert.NotEmpty(t, etag)
+			// The last write recorded by storage, the same on every replica
+			registry, err := store.GetRegistry(ctx, "tools")
+			require.NoError(t, err)
+			assert.Equal(t, registry.UpdatedAt.UTC().Format(http.TimeFormat), lastModified)
+			assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+			assert.Equal(t, strconv.Itoa(len(body)), rec.Header().Get("Content-Length"))
+			assert.Equal(t, keys.Enabled(), rec.Header().Get(signing.SignatureHeader) != "")
+
+			// HEAD carries the same headers without the body
+			rec = serve(http.MethodHead, nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Empty(t, rec.Body.String())
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			assert.Equal(t, lastModified, rec.Header().Get("Last-Modified"))
+			assert.Equal(t, strconv.Itoa(len(body)), rec.Header().Get("Content-Length"))
+
+			// Conditional requests, by ETag or date
+			assert.Equal(t, http.StatusNotModified, serve(http.MethodGet, map[string]string{"If-None-Match": etag}).Code)
+			assert.Equal(t, http.StatusNotModified, serve(http.MethodHead, map[string]string{"If-Modified-Since": lastModified}).Code)
+			assert.Equal(t, http.StatusOK, serve(http.MethodGet, map[string]string{"If-Modified-Since": "Mon, 01 Jan 2001 00:00:00 GMT"}).Code)
+
+			// Byte ranges, honoured only while If-Range still matches
+			rec = serve(http.MethodGet, map[string]string{"Range": "bytes=10-"})
+			require.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, body[10:], rec.Body.String())
+			assert.Equal(t, "bytes 10-"+strconv.Itoa(len(body)-1)+"/"+strconv.Itoa(len(body)), rec.Header().Get("Content-Range"))
+
+			rec = serve(http.MethodGet, map[string]string{"Range": "bytes=0-9", "If-Range": `"stale"`})
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, body, rec.Body.String())
+
+			assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, serve(http.MethodGet, map[string]string{"Range": "bytes=100000-"}).Code)
+		})
+	}
+}
+
+func TestIndexHandler_CacheTTL(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("stable", "", nil, nil)))
+	nightly := models.NewRegistry("nightly", "", nil, nil)
+	ttl := 0
+	nightly.CacheTTL = &ttl
+	require.NoError(t, store.CreateRegistry(ctx, nightly))
+
+	keys, err := signing.LoadKeySet(nil)
+	require.NoError(t, err)
+	registryHandler := NewRegistryHandler(store, logger)
+	registryHandler.SetIndexMaxAge(time.Hour)
+	router := chi.NewRouter()
+	router.With(middleware.CacheControl(time.Hour, false)).Get("/api/v1/registry/{name}/index.json", NewIndexHandler(store, keys, logger).GetIndex)
+	router.Get("/api/v1/registry/{name}/summary.json", registryHandler.GetRegistryDashboard)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+	dashboardTTL := func(registry string) int {
+		var dashboard models.RegistryDashboard
+		require.NoError(t, json.Unmarshal(serve("/api/v1/registry/"+registry+"/summary.json").Body.Bytes(), &dashboard))
+		return dashboard.CacheTTL
+	}
+
+	// Without cache_ttl the server setting applies
+	assert.Equal(t, "public, max-age=3600, must-revalidate", serve("/api/v1/registry/stable/index.json").Header().Get("Cache-Control"))
+	assert.Equal(t, 3600, dashboardTTL("stable"))
+
+	// A registry cache_ttl replaces it, zero asking clients to revalidate
+	assert.Equal(t, "public, no-cache", serve("/api/v1/registry/nightly/index.json").Header().Get("Cache-Control"))
+	assert.Equal(t, 0, dashboardTTL("nightly"))
+
+	ttl = 300
+	nightly.CacheTTL = &ttl
+	require.NoError(t, store.UpdateRegistry(ctx, nightly))
+	assert.Equal(t, "public, max-age=300, must-revalidate", serve("/api/v1/registry/nightly/index.json").Header().Get("Cache-Control"))
+	assert.Equal(t, 300, dashboardTTL("nightly"))
+}
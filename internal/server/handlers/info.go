@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// InfoHandler describes the server to clients
+type InfoHandler struct {
+	info   models.ServerInfo
+	logger *slog.Logger
+}
+
+// NewInfoHandler creates a new server info handler
+func NewInfoHandler(info models.ServerInfo, logger *slog.Logger) *InfoHandler {
+	return &InfoHandler{
+		info:   info,
+		logger: logger,
+	}
+}
+
+// GetServerInfo handles GET /api/v1/server-info
+// Returns the server version and the client versions it expects, so
+// cola-regctl can detect that it is too old for the server.
+func (h *InfoHandler) GetServerInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.info); err != nil {
+		h.logger.Error("Failed to encode server info", "error", err)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// InstallHandler serves scripts configuring Command Launcher to use a
+// registry, so onboarding a machine is a single command
+type InstallHandler struct {
+	store         storage.Store
+	anonymousRead bool // server.anonymous_read
+	logger        *slog.Logger
+}
+
+// NewInstallHandler creates a new install script handler
+func NewInstallHandler(store storage.Store, anonymousRead bool, logger *slog.Logger) *InstallHandler {
+	return &InstallHandler{
+		store:         store,
+		anonymousRead: anonymousRead,
+		logger:        logger,
+	}
+}
+
+// installScript is the data of the install script templates
+type installScript struct {
+	Registry     string
+	RemoteURL    string // Command Launcher appends /index.json
+	RequiresAuth bool
+	Version      string
+}
+
+var shellInstallScript = template.Must(template.New("install.sh").Funcs(template.FuncMap{
+	"quote": func(s string) string { return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'" },
+}).Parse(`#!/bin/sh
+# Configures Command Launcher to use the {{.Registry}} registry.
+# Generated by cola-registry {{.Version}}. Usage:
+#   curl -fsSL <this URL> | sh
+# Set COLA_LAUNCHER to the launcher binary if it is not cola or cdt.
+set -eu
+
+REGISTRY_NAME={{quote .Registry}}
+REMOTE_URL={{quote .RemoteURL}}
+
+LAUNCHER="${COLA_LAUNCHER:-}"
+if [ -z "$LAUNCHER" ]; then
+  for candidate in cola cdt; do
+    if command -v "$candidate" >/dev/null 2>&1; then
+      LAUNCHER="$candidate"
+      break
+    fi
+  done
+fi
+if [ -z "$LAUNCHER" ]; then
+  echo "Command Launcher not found: install it first, or set COLA_LAUNCHER to its binary" >&2
+  exit 1
+fi
+
+# Replace a previous remote of the same name
+"$LAUNCHER" remote delete "$REGISTRY_NAME" >/dev/null 2>&1 || true
+"$LAUNCHER" remote add "$REGISTRY_NAME" "$REMOTE_URL"
+
+echo "Command Launcher ($LAUNCHER) now uses registry $REGISTRY_NAME at $REMOTE_URL"
+{{- if .RequiresAuth}}
+echo "Note: this registry is not open to anonymous reads. Command Launcher must send"
+echo "credentials of this registry server, e.g. https://<user>:<token>@host/... in the"
+echo "remote URL; ask the registry administrators for an account."
+{{- end}}
+`))
+
+var powerShellInstallScript = template.Must(template.New("install.ps1").Funcs(template.FuncMap{
+	"quote": func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" },
+}).Parse(`# Configures Command Launcher to use the {{.Registry}} registry.
+# Generated by cola-registry {{.Version}}. Usage:
+#   irm <this URL> | iex
+# Set $env:COLA_LAUNCHER to the launcher binary if it is not cola or cdt.
+$ErrorActionPreference = 'Stop'
+
+$RegistryName = {{quote .Registry}}
+$RemoteUrl = {{quote .RemoteURL}}
+
+$Launcher = $env:COLA_LAUNCHER
+if (-not $Launcher) {
+    foreach ($Candidate in @('cola', 'cdt')) {
+        if (Get-Command $Candidate -ErrorAction SilentlyContinue) {
+            $Launcher = $Candidate
+            break
+        }
+    }
+}
+if (-not $Launcher) {
+    Write-Error 'Command Launcher not found: install it first, or set $env:COLA_LAUNCHER to its binary'
+    exit 1
+}
+
+# Replace a previous remote of the same name
+& $Launcher remote delete $RegistryName *> $null
+& $Launcher remote add $RegistryName $RemoteUrl
+if ($LASTEXITCODE -ne 0) {
+    exit $LASTEXITCODE
+}
+
+Write-Host "Command Launcher ($Launcher) now uses registry $RegistryName at $RemoteUrl"
+{{- if .RequiresAuth}}
+Write-Host 'Note: this registry is not open to anonymous reads. Command Launcher must send'
+Write-Host 'credentials of this registry server, e.g. https://<user>:<token>@host/... in the'
+Write-Host 'remote URL; ask the registry administrators for an account.'
+{{- end}}
+`))
+
+// GetShellScript handles GET /api/v1/registry/:name/install.sh
+func (h *InstallHandler) GetShellScript(w http.ResponseWriter, r *http.Request) {
+	h.serveScript(w, r, shellInstallScript, "text/x-shellscript; charset=utf-8")
+}
+
+// GetPowerShellScript handles GET /api/v1/registry/:name/install.ps1
+func (h *InstallHandler) GetPowerShellScript(w http.ResponseWriter, r *http.Request) {
+	h.serveScript(w, r, powerShellInstallScript, "text/plain; charset=utf-8")
+}
+
+func (h *InstallHandler) serveScript(w http.ResponseWriter, r *http.Request, tmpl *template.Template, contentType string) {
+	registryName := chi.URLParam(r, "name")
+
+	registry, err := h.store.GetRegistry(r.Context(), registryName)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to get registry",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to generate install script")
+		return
+	}
+
+	remoteURL, ok := absoluteAPILink(r, "/registry/"+url.PathEscape(registryName))
+	if !ok {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Cannot build the registry URL from the Host header; the server needs server.external_url", http.StatusBadRequest, nil)
+		return
+	}
+
+	var script bytes.Buffer
+	err = tmpl.Execute(&script, installScript{
+		Registry:     registryName,
+		RemoteURL:    remoteURL,
+		RequiresAuth: !registry.AllowsAnonymousRead(h.anonymousRead),
+		Version:      buildinfo.Version,
+	})
+	if err != nil {
+		h.logger.Error("Failed to render install script",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteError(w, apierrors.ErrCodeInternalError, "Failed to generate install script", http.StatusInternalServerError, nil)
+		return
+	}
+
+	h.logger.Debug("Install script served",
+		"registry", registryName,
+		"script", tmpl.Name())
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(script.Bytes())
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestInstallHandler(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	private := false
+	closed := models.NewRegistry("closed", "", nil, nil)
+	closed.AnonymousRead = &private
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreateRegistry(ctx, closed))
+
+	handler := NewInstallHandler(store, true, logger)
+	router := chi.NewRouter()
+	router.Get("/api/v1/registry/{name}/install.sh", handler.GetShellScript)
+	router.Get("/api/v1/registry/{name}/install.ps1", handler.GetPowerShellScript)
+
+	get := func(h http.Handler, path, host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(router, "/api/v1/registry/tools/install.sh", "registry.example.com:8080")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/x-shellscript; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "REGISTRY_NAME='tools'\n")
+	assert.Contains(t, rec.Body.String(), "REMOTE_URL='http://registry.example.com:8080/api/v1/registry/tools'\n")
+	assert.NotContains(t, rec.Body.String(), "anonymous reads")
+
+	rec = get(router, "/api/v1/registry/closed/install.ps1", "registry.example.com")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "$RemoteUrl = 'http://registry.example.com/api/v1/registry/closed'\n")
+	assert.Contains(t, rec.Body.String(), "not open to anonymous reads")
+
+	// The external URL wins over the request host
+	external := middleware.WithExternalURL("https://gateway.example.com/cola")(router)
+	rec = get(external, "/api/v1/registry/tools/install.sh", "internal:8080")
+	assert.Contains(t, rec.Body.String(), "REMOTE_URL='https://gateway.example.com/cola/api/v1/registry/tools'\n")
+
+	// A host that could escape the script quoting is refused
+	assert.Equal(t, http.StatusBadRequest, get(router, "/api/v1/registry/tools/install.sh", "evil';rm -rf ~;'").Code)
+	assert.Equal(t, http.StatusNotFound, get(router, "/api/v1/registry/missing/install.sh", "registry.example.com").Code)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+)
+
+// Link is a HAL link to a related resource: an absolute URL when
+// server.external_url is set, otherwise a path including the base path
+type Link struct {
+	Href string `json:"href"`
+}
+
+// VersionLinks are the resources a version response links to
+type VersionLinks struct {
+	Self     Link `json:"self"`
+	Package  Link `json:"package"`
+	Registry Link `json:"registry"`
+	Index    Link `json:"index"`
+}
+
+// VersionResponse is a version as returned by GET: the stored fields, the
+// names of its registry and package, and HAL-style links, so clients can
+// navigate without building URLs themselves
+type VersionResponse struct {
+	*models.Version
+	Registry string       `json:"registry"`
+	Package  string       `json:"package"`
+	Links    VersionLinks `json:"_links"`
+}
+
+// presentVersion wraps a version of registryName/packageName with its links
+func presentVersion(r *http.Request, registryName, packageName string, v *models.Version) VersionResponse {
+	registry := apiLink(r, "/registry/"+url.PathEscape(registryName))
+	pkg := registry + "/package/" + url.PathEscape(packageName)
+	return VersionResponse{
+		Version:  v,
+		Registry: registryName,
+		Package:  packageName,
+		Links: VersionLinks{
+			Self:     Link{Href: pkg + "/version/" + url.PathEscape(v.Version)},
+			Package:  Link{Href: pkg},
+			Registry: Link{Href: registry},
+			Index:    Link{Href: registry + "/index.json"},
+		},
+	}
+}
+
+// apiPath returns the link to an API v1 resource: below the external URL
+// if one is configured, else below the base path the request was received
+// under
+func apiLink(r *http.Request, path string) string {
+	if externalURL := middleware.ExternalURL(r.Context()); externalURL != "" {
+		return externalURL + "/api/v1" + path
+	}
+	return middleware.BasePath(r.Context()) + "/api/v1" + path
+}
+
+// absoluteAPILink returns the absolute URL of an API v1 resource: below the
+// external URL if one is configured, else built from the request. It fails
+// when the Host header is not a plain host name, address or port.
+func absoluteAPILink(r *http.Request, path string) (string, bool) {
+	if middleware.ExternalURL(r.Context()) != "" {
+		return apiLink(r, path), true
+	}
+	if r.Host == "" || strings.Trim(r.Host, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-:[]") != "" {
+		return "", false
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + apiLink(r, path), true
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// Roles a user can hold on a package
+const (
+	RoleMaintainer = "maintainer" // Listed in the package maintainers
+	RoleAdmin      = "admin"      // Listed in the registry admins
+)
+
+// MeHandler handles requests about the authenticated user's own resources
+type MeHandler struct {
+	store  storage.Store
+	logger *slog.Logger
+}
+
+// NewMeHandler creates a new me handler
+func NewMeHandler(store storage.Store, logger *slog.Logger) *MeHandler {
+	return &MeHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// MyPackage is a package the authenticated user is responsible for
+type MyPackage struct {
+	Registry     string   `json:"registry"`
+	Package      string   `json:"package"`
+	Description  string   `json:"description"`
+	Roles        []string `json:"roles"`
+	VersionCount int      `json:"version_count"`
+}
+
+// ListMyPackages handles GET /api/v1/me/packages
+// Returns every package, across all registries, where the authenticated user
+// is a maintainer or an admin of the registry. Requires authentication.
+func (h *MeHandler) ListMyPackages(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		apierrors.WriteError(w, apierrors.ErrCodeUnauthorized, "Authentication required", http.StatusUnauthorized, nil)
+		return
+	}
+
+	registries, err := h.store.ListRegistries(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list registries",
+			"user", user.Username,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to list packages")
+		return
+	}
+
+	packages := make([]MyPackage, 0)
+	for _, registry := range registries {
+		registryAdmin := registry.IsAdmin(user.Username)
+		for _, pkg := range registry.Packages {
+			var roles []string
+			if pkg.IsMaintainer(user.Username) {
+				roles = append(roles, RoleMaintainer)
+			}
+			if registryAdmin {
+				roles = append(roles, RoleAdmin)
+			}
+			if len(roles) == 0 {
+				continue
+			}
+
+			packages = append(packages, MyPackage{
+				Registry:     registry.Name,
+				Package:      pkg.Name,
+				Description:  pkg.Description,
+				Roles:        roles,
+				VersionCount: len(pkg.Versions),
+			})
+		}
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Registry != packages[j].Registry {
+			return packages[i].Registry < packages[j].Registry
+		}
+		return packages[i].Package < packages[j].Package
+	})
+
+	h.logger.Debug("User packages listed",
+		"user", user.Username,
+		"count", len(packages))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(packages)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeHandler_ListMyPackages(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", []string{"Alice"}, nil)))
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("infra", "", []string{"bob"}, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", []string{"carol"}, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "infra", models.NewPackage("dns", "", []string{"alice"}, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "infra", models.NewPackage("vpn", "", []string{"bob"}, nil)))
+
+	handler := NewMeHandler(store, logger)
+
+	tests := []struct {
+		name     string
+		user     *auth.User
+		status   int
+		expected []MyPackage
+	}{
+		{
+			name:   "maintainer and registry admin",
+			user:   &auth.User{Username: "alice"},
+			status: http.StatusOK,
+			expected: []MyPackage{
+				{Registry: "infra", Package: "dns", Description: "", Roles: []string{RoleMaintainer}},
+				{Registry: "tools", Package: "deployer", Description: "", Roles: []string{RoleAdmin}},
+			},
+		},
+		{
+			name:     "nothing owned",
+			user:     &auth.User{Username: "dave"},
+			status:   http.StatusOK,
+			expected: []MyPackage{},
+		},
+		{
+			name:   "unauthenticated",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me/packages", nil)
+			if tt.user != nil {
+				req = req.WithContext(auth.WithUser(req.Context(), tt.user))
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ListMyPackages(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+			var got []MyPackage
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
@@ -1,33 +1,43 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
-	"sync/atomic"
+	"strconv"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/storage"
 )
 
+// MetricsSchemaVersion is the version of the MetricsResponse format. It is
+// bumped when fields change meaning or are removed; fields may be added
+// without a bump. Version 1 was the unversioned format with by_type and
+// by_status only.
+const MetricsSchemaVersion = 2
+
+// processStart approximates the start of the server process, for uptime
+var processStart = time.Now()
+
+// storageCheckTimeout bounds the storage check of a metrics request
+const storageCheckTimeout = 5 * time.Second
+
 // MetricsHandler handles metrics requests
 type MetricsHandler struct {
 	logger *slog.Logger
 
-	// Atomic counters for thread-safe increments
-	totalRequests     atomic.Uint64
-	indexRequests     atomic.Uint64
-	registryCreates   atomic.Uint64
-	registryReads     atomic.Uint64
-	registryUpdates   atomic.Uint64
-	registryDeletes   atomic.Uint64
-	packageCreates    atomic.Uint64
-	packageReads      atomic.Uint64
-	packageUpdates    atomic.Uint64
-	packageDeletes    atomic.Uint64
-	versionCreates    atomic.Uint64
-	versionReads      atomic.Uint64
-	versionDeletes    atomic.Uint64
-	authFailures      atomic.Uint64
-	rateLimitExceeded atomic.Uint64
-	validationErrors  atomic.Uint64
+	routes      *middleware.RouteMetrics  // nil when not reported
+	store       storage.Store             // nil when not reported
+	degraded    func() bool               // Serving the storage cache read-only
+	mirror      *storage.MirrorStore      // nil when not mirroring
+	watcher     *storage.Watcher          // nil when not watching
+	backups     *storage.Backups          // nil when not backing up
+	rateLimiter *middleware.RateLimiter   // nil when not reported
+	writeQueue  *middleware.WriteQueue    // nil when not reported
+	clients     *middleware.ClientCounter // nil when not reported
+	users       *middleware.UserCounter   // nil when not reported
 }
 
 // NewMetricsHandler creates a new metrics handler
@@ -37,105 +47,197 @@ func NewMetricsHandler(logger *slog.Logger) *MetricsHandler {
 	}
 }
 
-// MetricsResponse represents the metrics response
-type MetricsResponse struct {
-	Total    uint64            `json:"total_requests"`
-	ByType   map[string]uint64 `json:"by_type"`
-	ByStatus map[string]uint64 `json:"by_status"`
+// SetRouteMetrics reports the requests, errors and latencies per route
+func (h *MetricsHandler) SetRouteMetrics(routes *middleware.RouteMetrics) {
+	h.routes = routes
 }
 
-// GetMetrics handles GET /api/v1/metrics
-func (h *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
-	response := MetricsResponse{
-		Total: h.totalRequests.Load(),
-		ByType: map[string]uint64{
-			"index_requests":   h.indexRequests.Load(),
-			"registry_creates": h.registryCreates.Load(),
-			"registry_reads":   h.registryReads.Load(),
-			"registry_updates": h.registryUpdates.Load(),
-			"registry_deletes": h.registryDeletes.Load(),
-			"package_creates":  h.packageCreates.Load(),
-			"package_reads":    h.packageReads.Load(),
-			"package_updates":  h.packageUpdates.Load(),
-			"package_deletes":  h.packageDeletes.Load(),
-			"version_creates":  h.versionCreates.Load(),
-			"version_reads":    h.versionReads.Load(),
-			"version_deletes":  h.versionDeletes.Load(),
-		},
-		ByStatus: map[string]uint64{
-			"auth_failures":       h.authFailures.Load(),
-			"rate_limit_exceeded": h.rateLimitExceeded.Load(),
-			"validation_errors":   h.validationErrors.Load(),
-		},
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+// SetStorage reports the health of store; degraded reports whether the
+// server serves the storage cache read-only
+func (h *MetricsHandler) SetStorage(store storage.Store, degraded func() bool) {
+	h.store = store
+	h.degraded = degraded
 }
 
-// Request counter methods
-
-func (h *MetricsHandler) IncrementTotalRequests() {
-	h.totalRequests.Add(1)
+// SetMirror reports the lag and failures of the storage mirror
+func (h *MetricsHandler) SetMirror(mirror *storage.MirrorStore) {
+	h.mirror = mirror
 }
 
-func (h *MetricsHandler) IncrementIndexRequests() {
-	h.indexRequests.Add(1)
+// SetWatcher reports the out-of-band storage changes reloaded
+func (h *MetricsHandler) SetWatcher(watcher *storage.Watcher) {
+	h.watcher = watcher
 }
 
-func (h *MetricsHandler) IncrementRegistryCreates() {
-	h.registryCreates.Add(1)
+// SetBackups reports the scheduled snapshots of the storage data
+func (h *MetricsHandler) SetBackups(backups *storage.Backups) {
+	h.backups = backups
 }
 
-func (h *MetricsHandler) IncrementRegistryReads() {
-	h.registryReads.Add(1)
+// SetRateLimiter reports the rate limiter's rejections and per-client usage
+func (h *MetricsHandler) SetRateLimiter(limiter *middleware.RateLimiter) {
+	h.rateLimiter = limiter
 }
 
-func (h *MetricsHandler) IncrementRegistryUpdates() {
-	h.registryUpdates.Add(1)
+// SetWriteQueue reports the depth and rejections of the write queue
+func (h *MetricsHandler) SetWriteQueue(queue *middleware.WriteQueue) {
+	h.writeQueue = queue
 }
 
-func (h *MetricsHandler) IncrementRegistryDeletes() {
-	h.registryDeletes.Add(1)
+// SetClientCounter reports the requests per client software and version
+func (h *MetricsHandler) SetClientCounter(clients *middleware.ClientCounter) {
+	h.clients = clients
 }
 
-func (h *MetricsHandler) IncrementPackageCreates() {
-	h.packageCreates.Add(1)
+// SetUserCounter reports the requests, authentication failures and rate
+// limit rejections per user
+func (h *MetricsHandler) SetUserCounter(users *middleware.UserCounter) {
+	h.users = users
 }
 
-func (h *MetricsHandler) IncrementPackageReads() {
-	h.packageReads.Add(1)
+// MetricsResponse represents the metrics response. Its format is
+// documented in docs/openapi.yaml and versioned by SchemaVersion.
+type MetricsResponse struct {
+	SchemaVersion int                              `json:"schema_version"`
+	StartedAt     time.Time                        `json:"started_at"`
+	UptimeSeconds int64                            `json:"uptime_seconds"`
+	Storage       *StorageMetrics                  `json:"storage,omitempty"`
+	Total         uint64                           `json:"total_requests"`
+	Routes        map[string]middleware.RouteStats `json:"routes,omitempty"` // By "METHOD pattern"
+	ByStatusCode  map[string]uint64                `json:"by_status_code,omitempty"`
+	ByType        map[string]uint64                `json:"by_type"`              // Deprecated: derived from routes
+	ByStatus      map[string]uint64                `json:"by_status"`            // Deprecated: use by_status_code
+	RateLimit     []middleware.RateLimitUsage      `json:"rate_limit,omitempty"` // Per-client usage, admins only
+	WriteQueue    *middleware.WriteQueueStats      `json:"write_queue,omitempty"`
+	ByClient      map[string]uint64                `json:"by_client,omitempty"`
+	ByUser        map[string]middleware.UserStats  `json:"by_user,omitempty"` // Admins only
+}
+
+// StorageMetrics reports the health of storage
+type StorageMetrics struct {
+	Status  string               `json:"status"` // healthy | degraded | unhealthy
+	Message string               `json:"message,omitempty"`
+	CheckMs float64              `json:"check_ms"`         // Duration of the check
+	Mirror  *storage.MirrorStats `json:"mirror,omitempty"` // nil when not mirroring
+	Watch   *storage.WatchStats  `json:"watch,omitempty"`  // nil when not watching
+	Backup  *storage.BackupStats `json:"backup,omitempty"` // nil when not backing up
+}
+
+// legacyTypes maps the routes counted by the by_type counters of schema
+// version 1 to their counter
+var legacyTypes = map[string]string{
+	"GET /api/v1/registry/{name}/index.json":                             "index_requests",
+	"POST /api/v1/registry":                                              "registry_creates",
+	"GET /api/v1/registry/{name}":                                        "registry_reads",
+	"PUT /api/v1/registry/{name}":                                        "registry_updates",
+	"PATCH /api/v1/registry/{name}":                                      "registry_updates",
+	"DELETE /api/v1/registry/{name}":                                     "registry_deletes",
+	"POST /api/v1/registry/{name}/package":                               "package_creates",
+	"GET /api/v1/registry/{name}/package/{package}":                      "package_reads",
+	"PUT /api/v1/registry/{name}/package/{package}":                      "package_updates",
+	"PATCH /api/v1/registry/{name}/package/{package}":                    "package_updates",
+	"DELETE /api/v1/registry/{name}/package/{package}":                   "package_deletes",
+	"POST /api/v1/registry/{name}/package/{package}/version":             "version_creates",
+	"GET /api/v1/registry/{name}/package/{package}/version/{version}":    "version_reads",
+	"DELETE /api/v1/registry/{name}/package/{package}/version/{version}": "version_deletes",
 }
 
-func (h *MetricsHandler) IncrementPackageUpdates() {
-	h.packageUpdates.Add(1)
-}
+// GetMetrics handles GET /api/v1/metrics
+func (h *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	response := MetricsResponse{
+		SchemaVersion: MetricsSchemaVersion,
+		StartedAt:     processStart.UTC().Truncate(time.Second),
+		UptimeSeconds: int64(now.Sub(processStart).Seconds()),
+		ByType:        make(map[string]uint64),
+		ByStatus: map[string]uint64{
+			"auth_failures":       0,
+			"rate_limit_exceeded": 0,
+			"validation_errors":   0,
+		},
+	}
+	for _, name := range legacyTypes {
+		response.ByType[name] = 0
+	}
 
-func (h *MetricsHandler) IncrementPackageDeletes() {
-	h.packageDeletes.Add(1)
-}
+	if h.store != nil {
+		response.Storage = h.storageMetrics(r.Context())
+	}
 
-func (h *MetricsHandler) IncrementVersionCreates() {
-	h.versionCreates.Add(1)
-}
+	if h.routes != nil {
+		response.Routes = h.routes.Routes()
+		for key, stats := range response.Routes {
+			response.Total += stats.Requests
+			if name, ok := legacyTypes[key]; ok {
+				response.ByType[name] += stats.Requests
+			}
+		}
+		statuses := h.routes.Statuses()
+		response.ByStatusCode = make(map[string]uint64, len(statuses))
+		for status, n := range statuses {
+			response.ByStatusCode[strconv.Itoa(status)] = n
+		}
+		response.ByStatus["validation_errors"] = statuses[http.StatusBadRequest] + statuses[http.StatusUnprocessableEntity]
+	}
 
-func (h *MetricsHandler) IncrementVersionReads() {
-	h.versionReads.Add(1)
-}
+	if h.rateLimiter != nil {
+		response.ByStatus["rate_limit_exceeded"] += h.rateLimiter.Limited()
+		// Keys name users and client IPs
+		if isAdmin(r) {
+			response.RateLimit = h.rateLimiter.Usage()
+		}
+	}
 
-func (h *MetricsHandler) IncrementVersionDeletes() {
-	h.versionDeletes.Add(1)
-}
+	if h.writeQueue != nil {
+		stats := h.writeQueue.Stats()
+		response.WriteQueue = &stats
+		response.ByStatus["write_queue_full"] = stats.Rejected
+	}
 
-func (h *MetricsHandler) IncrementAuthFailures() {
-	h.authFailures.Add(1)
-}
+	if h.clients != nil {
+		response.ByClient = h.clients.Counts()
+	}
 
-func (h *MetricsHandler) IncrementRateLimitExceeded() {
-	h.rateLimitExceeded.Add(1)
+	if h.users != nil {
+		response.ByStatus["auth_failures"] += h.users.AuthFailures()
+		// Keys name users
+		if isAdmin(r) {
+			response.ByUser = h.users.Stats()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
 
-func (h *MetricsHandler) IncrementValidationErrors() {
-	h.validationErrors.Add(1)
+// storageMetrics checks storage as /health does
+func (h *MetricsHandler) storageMetrics(ctx context.Context) *StorageMetrics {
+	ctx, cancel := context.WithTimeout(ctx, storageCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	check := checkStorage(ctx, h.store)
+	metrics := &StorageMetrics{
+		Status:  check.Status,
+		Message: check.Message,
+		CheckMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if metrics.Status == "healthy" && h.degraded != nil && h.degraded() {
+		metrics.Status = "degraded"
+		metrics.Message = "Serving the storage cache read-only: the backend was unavailable at startup"
+	}
+	if h.mirror != nil {
+		stats := h.mirror.Stats(ctx)
+		metrics.Mirror = &stats
+	}
+	if h.watcher != nil {
+		stats := h.watcher.Stats()
+		metrics.Watch = &stats
+	}
+	if h.backups != nil {
+		stats := h.backups.Stats()
+		metrics.Backup = &stats
+	}
+	return metrics
 }
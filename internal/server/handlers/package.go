@@ -2,6 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 
@@ -41,6 +44,7 @@ func (h *PackageHandler) CreatePackage(w http.ResponseWriter, r *http.Request) {
 		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
 		return
 	}
+	models.NormalizePackage(&pkg)
 
 	// Validate package
 	if err := models.ValidatePackage(&pkg); err != nil {
@@ -53,11 +57,6 @@ func (h *PackageHandler) CreatePackage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Initialize versions map if nil
-	if pkg.Versions == nil {
-		pkg.Versions = make(map[string]*models.Version)
-	}
-
 	// Create package
 	if err := h.store.CreatePackage(r.Context(), registryName, &pkg); err != nil {
 		if err == storage.ErrNotFound {
@@ -65,17 +64,27 @@ func (h *PackageHandler) CreatePackage(w http.ResponseWriter, r *http.Request) {
 			apierrors.WriteError(w, code, msg, status, nil)
 			return
 		}
-		if err == storage.ErrAlreadyExists {
+		if err == storage.ErrAlreadyExists || err == storage.ErrEncryptionNotConfigured {
 			code, msg, status := apierrors.MapStorageError(err, "package")
 			apierrors.WriteError(w, code, msg, status, nil)
 			return
 		}
+		var nameErr *storage.PackageNameError
+		if errors.As(err, &nameErr) {
+			h.logger.Warn("Package name rejected by policy",
+				"registry", registryName,
+				"package", pkg.Name,
+				"other_registries", nameErr.Registries,
+				"remote_addr", r.RemoteAddr)
+			apierrors.WriteError(w, apierrors.ErrCodePackageNameTaken, nameErr.Error(), http.StatusConflict, nil)
+			return
+		}
 
 		h.logger.Error("Failed to create package",
 			"registry", registryName,
 			"package", pkg.Name,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to create package", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to create package")
 		return
 	}
 
@@ -87,10 +96,15 @@ func (h *PackageHandler) CreatePackage(w http.ResponseWriter, r *http.Request) {
 		"custom_values", len(pkg.CustomValues),
 		"remote_addr", r.RemoteAddr)
 
+	sensitiveKeys, ok := h.sensitiveKeys(w, r, registryName)
+	if !ok {
+		return
+	}
+
 	// Return created package
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(pkg)
+	json.NewEncoder(w).Encode(presentPackage(r, &pkg, sensitiveKeys))
 }
 
 // GetPackage handles GET /api/v1/registry/:name/package/:package
@@ -117,7 +131,12 @@ func (h *PackageHandler) GetPackage(w http.ResponseWriter, r *http.Request) {
 			"registry", registryName,
 			"package", packageName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to retrieve package", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve package")
+		return
+	}
+
+	sensitiveKeys, ok := h.sensitiveKeys(w, r, registryName)
+	if !ok {
 		return
 	}
 
@@ -130,7 +149,60 @@ func (h *PackageHandler) GetPackage(w http.ResponseWriter, r *http.Request) {
 	// Return package
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(pkg)
+	json.NewEncoder(w).Encode(presentPackage(r, pkg, sensitiveKeys))
+}
+
+// GetPackageHistory handles GET /api/v1/registry/:name/package/:package/history
+// Changes are listed newest first; the history of a deleted package remains
+// readable until its registry is deleted.
+func (h *PackageHandler) GetPackageHistory(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+	packageName := chi.URLParam(r, "package")
+
+	history, err := h.store.GetPackageHistory(r.Context(), registryName, packageName)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			// Determine if registry or package not found
+			if _, regErr := h.store.GetRegistry(r.Context(), registryName); regErr == storage.ErrNotFound {
+				code, msg, status := apierrors.MapStorageError(err, "registry")
+				apierrors.WriteError(w, code, msg, status, nil)
+			} else {
+				code, msg, status := apierrors.MapStorageError(err, "package")
+				apierrors.WriteError(w, code, msg, status, nil)
+			}
+			return
+		}
+
+		h.logger.Error("Failed to get package history",
+			"registry", registryName,
+			"package", packageName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve package history")
+		return
+	}
+
+	sensitiveKeys, ok := h.sensitiveKeys(w, r, registryName)
+	if !ok {
+		return
+	}
+	if isAdmin(r) {
+		sensitiveKeys = nil
+	}
+
+	h.logger.Debug("Package history retrieved",
+		"registry", registryName,
+		"package", packageName,
+		"change_count", len(history))
+
+	err = writeJSONArray(w, r, len(history), func(i int) interface{} {
+		return history[i].Masked(sensitiveKeys)
+	})
+	if err != nil {
+		h.logger.Error("Failed to write package history",
+			"registry", registryName,
+			"package", packageName,
+			"error", err)
+	}
 }
 
 // UpdatePackage handles PUT /api/v1/registry/:name/package/:package
@@ -151,6 +223,84 @@ func (h *PackageHandler) UpdatePackage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.replacePackage(w, r, registryName, packageName, func(*models.Package, []string) (*models.Package, error) {
+		return &pkg, nil
+	})
+}
+
+// PatchPackage handles PATCH /api/v1/registry/:name/package/:package
+// Applies a JSON merge patch (RFC 7386) to the package as the caller reads
+// it; versions cannot be changed this way.
+func (h *PackageHandler) PatchPackage(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+	packageName := chi.URLParam(r, "package")
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Failed to read request body", http.StatusBadRequest, nil)
+		return
+	}
+
+	h.replacePackage(w, r, registryName, packageName, func(existing *models.Package, sensitiveKeys []string) (*models.Package, error) {
+		var pkg models.Package
+		if err := mergePatch(presentPackage(r, existing, sensitiveKeys), patch, &pkg); err != nil {
+			h.logger.Warn("Failed to apply package patch",
+				"registry", registryName,
+				"package", packageName,
+				"error", err,
+				"remote_addr", r.RemoteAddr)
+			return nil, err
+		}
+		return &pkg, nil
+	})
+}
+
+// writePackageNotFound reports a missing package, or its missing registry
+func (h *PackageHandler) writePackageNotFound(w http.ResponseWriter, r *http.Request, registryName string, err error) {
+	if _, regErr := h.store.GetRegistry(r.Context(), registryName); regErr == storage.ErrNotFound {
+		code, msg, status := apierrors.MapStorageError(err, "registry")
+		apierrors.WriteError(w, code, msg, status, nil)
+	} else {
+		code, msg, status := apierrors.MapStorageError(err, "package")
+		apierrors.WriteError(w, code, msg, status, nil)
+	}
+}
+
+// replacePackage replaces the metadata of a package with that built from the
+// existing package, after checking If-Match. Versions are preserved.
+func (h *PackageHandler) replacePackage(w http.ResponseWriter, r *http.Request, registryName, packageName string, build func(existing *models.Package, sensitiveKeys []string) (*models.Package, error)) {
+	defer lockConditional(r)()
+
+	// Get existing package to preserve versions
+	existing, err := h.store.GetPackage(r.Context(), registryName, packageName)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			h.writePackageNotFound(w, r, registryName, err)
+			return
+		}
+
+		h.logger.Error("Failed to get existing package",
+			"registry", registryName,
+			"package", packageName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve package")
+		return
+	}
+	sensitiveKeys, ok := h.sensitiveKeys(w, r, registryName)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, presentPackage(r, existing, sensitiveKeys)) {
+		return
+	}
+
+	pkg, err := build(existing, sensitiveKeys)
+	if err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+	models.NormalizePackage(pkg)
+
 	// Ensure name in URL matches name in body
 	if pkg.Name != packageName {
 		h.logger.Warn("Package name mismatch",
@@ -162,7 +312,7 @@ func (h *PackageHandler) UpdatePackage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate package
-	if err := models.ValidatePackage(&pkg); err != nil {
+	if err := models.ValidatePackage(pkg); err != nil {
 		h.logger.Warn("Package validation failed",
 			"registry", registryName,
 			"package", pkg.Name,
@@ -172,43 +322,21 @@ func (h *PackageHandler) UpdatePackage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get existing package to preserve versions
-	existing, err := h.store.GetPackage(r.Context(), registryName, packageName)
-	if err != nil {
-		if err == storage.ErrNotFound {
-			// Determine if registry or package not found
-			if _, regErr := h.store.GetRegistry(r.Context(), registryName); regErr == storage.ErrNotFound {
-				code, msg, status := apierrors.MapStorageError(err, "registry")
-				apierrors.WriteError(w, code, msg, status, nil)
-			} else {
-				code, msg, status := apierrors.MapStorageError(err, "package")
-				apierrors.WriteError(w, code, msg, status, nil)
-			}
-			return
-		}
-
-		h.logger.Error("Failed to get existing package",
-			"registry", registryName,
-			"package", packageName,
-			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to retrieve package", http.StatusInternalServerError, nil)
-		return
-	}
-
 	// Preserve versions from existing package
 	pkg.Versions = existing.Versions
 
+	// Keep sensitive values that were sent back masked
+	models.RestoreMaskedValues(pkg.CustomValues, existing.CustomValues, sensitiveKeys)
+
 	// Update package
-	if err := h.store.UpdatePackage(r.Context(), registryName, &pkg); err != nil {
+	if err := h.store.UpdatePackage(r.Context(), registryName, pkg); err != nil {
 		if err == storage.ErrNotFound {
-			// Determine if registry or package not found
-			if _, regErr := h.store.GetRegistry(r.Context(), registryName); regErr == storage.ErrNotFound {
-				code, msg, status := apierrors.MapStorageError(err, "registry")
-				apierrors.WriteError(w, code, msg, status, nil)
-			} else {
-				code, msg, status := apierrors.MapStorageError(err, "package")
-				apierrors.WriteError(w, code, msg, status, nil)
-			}
+			h.writePackageNotFound(w, r, registryName, err)
+			return
+		}
+		if err == storage.ErrEncryptionNotConfigured {
+			code, msg, status := apierrors.MapStorageError(err, "package")
+			apierrors.WriteError(w, code, msg, status, nil)
 			return
 		}
 
@@ -216,7 +344,7 @@ func (h *PackageHandler) UpdatePackage(w http.ResponseWriter, r *http.Request) {
 			"registry", registryName,
 			"package", packageName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to update package", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to update package")
 		return
 	}
 
@@ -228,10 +356,12 @@ func (h *PackageHandler) UpdatePackage(w http.ResponseWriter, r *http.Request) {
 		"custom_values", len(pkg.CustomValues),
 		"remote_addr", r.RemoteAddr)
 
-	// Return updated package
+	// Return updated package, with the ETag for further conditional writes
+	presented := presentPackage(r, pkg, sensitiveKeys)
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", representationETag(presented))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(pkg)
+	json.NewEncoder(w).Encode(presented)
 }
 
 // DeletePackage handles DELETE /api/v1/registry/:name/package/:package
@@ -239,6 +369,17 @@ func (h *PackageHandler) DeletePackage(w http.ResponseWriter, r *http.Request) {
 	registryName := chi.URLParam(r, "name")
 	packageName := chi.URLParam(r, "package")
 
+	defer lockConditional(r)()
+	if r.Header.Get("If-Match") != "" {
+		existing, err := h.store.GetPackage(r.Context(), registryName, packageName)
+		if err == nil {
+			sensitiveKeys, ok := h.sensitiveKeys(w, r, registryName)
+			if !ok || !checkIfMatch(w, r, presentPackage(r, existing, sensitiveKeys)) {
+				return
+			}
+		}
+	}
+
 	// Delete package (cascade delete handled by storage layer)
 	if err := h.store.DeletePackage(r.Context(), registryName, packageName); err != nil {
 		if err == storage.ErrNotFound {
@@ -257,7 +398,7 @@ func (h *PackageHandler) DeletePackage(w http.ResponseWriter, r *http.Request) {
 			"registry", registryName,
 			"package", packageName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to delete package", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to delete package")
 		return
 	}
 
@@ -272,11 +413,27 @@ func (h *PackageHandler) DeletePackage(w http.ResponseWriter, r *http.Request) {
 }
 
 // ListPackages handles GET /api/v1/registry/:name/package
+// Packages can be filtered by custom values with ?custom.<key>=<value>.
 func (h *PackageHandler) ListPackages(w http.ResponseWriter, r *http.Request) {
 	registryName := chi.URLParam(r, "name")
 
-	// Get all packages from storage
-	packages, err := h.store.ListPackages(r.Context(), registryName)
+	query, err := models.ParseCustomValueQuery(r.URL.Query())
+	if err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+	sortField, descending, ok := parseRecordSort(w, r)
+	if !ok {
+		return
+	}
+
+	// Get packages from storage, using the custom value index when filtering
+	var packages []*models.Package
+	if len(query) > 0 {
+		packages, err = h.store.FindPackages(r.Context(), registryName, query)
+	} else {
+		packages, err = h.store.ListPackages(r.Context(), registryName)
+	}
 	if err != nil {
 		if err == storage.ErrNotFound {
 			code, msg, status := apierrors.MapStorageError(err, "registry")
@@ -287,17 +444,136 @@ func (h *PackageHandler) ListPackages(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("Failed to list packages",
 			"registry", registryName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to list packages", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to list packages")
 		return
 	}
 
+	// Mask sensitive custom values for non-admin callers
+	sensitiveKeys, ok := h.sensitiveKeys(w, r, registryName)
+	if !ok {
+		return
+	}
+	// Sensitive values are encrypted with a random nonce and must not be probed
+	for _, key := range sensitiveKeys {
+		if _, ok := query[key]; ok {
+			apierrors.WriteError(w, apierrors.ErrCodeValidationError, fmt.Sprintf("custom value '%s' is sensitive and cannot be queried", key), http.StatusBadRequest, nil)
+			return
+		}
+	}
+
+	if sortField != "" {
+		models.SortPackages(packages, sortField, descending)
+	}
+
 	// Log retrieval
 	h.logger.Debug("Packages listed",
 		"registry", registryName,
-		"count", len(packages))
+		"query_keys", len(query),
+		"count", len(packages),
+		"sort", r.URL.Query().Get("sort"))
+
+	// Stream packages, masking each one as it is encoded
+	err = writeJSONArray(w, r, len(packages), func(i int) interface{} {
+		return presentPackage(r, packages[i], sensitiveKeys)
+	})
+	if err != nil {
+		h.logger.Error("Failed to write package list",
+			"registry", registryName,
+			"error", err)
+	}
+}
+
+// BatchUpdateRequest is the body of a batch package update
+type BatchUpdateRequest struct {
+	Filter models.PackageFilter `json:"filter"`
+	Patch  models.PackagePatch  `json:"patch"`
+	DryRun bool                 `json:"dry_run"`
+}
+
+// BatchUpdateResponse reports the packages a batch update changed, or would
+// change with dry_run
+type BatchUpdateResponse struct {
+	DryRun  bool              `json:"dry_run"`
+	Matched int               `json:"matched"`
+	Updated []*models.Package `json:"updated"`
+}
+
+// BatchUpdatePackages handles POST /api/v1/registry/:name/packages:batch-update
+func (h *PackageHandler) BatchUpdatePackages(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+
+	var req BatchUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("Failed to decode batch update request",
+			"registry", registryName,
+			"error", err,
+			"remote_addr", r.RemoteAddr)
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+	if err := req.Patch.Validate(); err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	sensitiveKeys, ok := h.sensitiveKeys(w, r, registryName)
+	if !ok {
+		return
+	}
+	// Sensitive values are encrypted with a random nonce and must not be probed
+	for _, key := range sensitiveKeys {
+		if _, ok := req.Filter.CustomValues[key]; ok {
+			apierrors.WriteError(w, apierrors.ErrCodeValidationError, fmt.Sprintf("custom value '%s' is sensitive and cannot be queried", key), http.StatusBadRequest, nil)
+			return
+		}
+	}
+
+	matched, updated, err := storage.BatchUpdatePackages(r.Context(), h.store, registryName, req.Filter, req.Patch, req.DryRun)
+	if err != nil {
+		var verr *models.ValidationError
+		if errors.As(err, &verr) {
+			apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+			return
+		}
+		if err == storage.ErrNotFound {
+			// A package deleted while the batch was prepared also ends here
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			if _, regErr := h.store.GetRegistry(r.Context(), registryName); regErr == nil {
+				code, msg, status = apierrors.MapStorageError(err, "package")
+			}
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+		if err == storage.ErrEncryptionNotConfigured {
+			code, msg, status := apierrors.MapStorageError(err, "package")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to batch update packages",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to update packages")
+		return
+	}
+
+	h.logger.Info("Packages batch updated",
+		"registry", registryName,
+		"matched", matched,
+		"updated", len(updated),
+		"dry_run", req.DryRun,
+		"remote_addr", r.RemoteAddr)
+
+	resp := BatchUpdateResponse{
+		DryRun:  req.DryRun,
+		Matched: matched,
+		Updated: make([]*models.Package, 0, len(updated)),
+	}
+	for _, pkg := range updated {
+		resp.Updated = append(resp.Updated, presentPackage(r, pkg, sensitiveKeys))
+	}
 
-	// Return packages
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(packages)
+	json.NewEncoder(w).Encode(resp)
 }
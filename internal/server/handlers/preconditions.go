@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+)
+
+// conditionalWrites serializes writes carrying If-Match, so the resource
+// cannot change between the precondition check and the write made by
+// another conditional request of this instance
+var conditionalWrites sync.Mutex
+
+// lockConditional holds conditionalWrites when r carries If-Match and
+// returns the function releasing it
+func lockConditional(r *http.Request) func() {
+	if r.Header.Get("If-Match") == "" {
+		return func() {}
+	}
+	conditionalWrites.Lock()
+	return conditionalWrites.Unlock
+}
+
+// representationETag returns the ETag a GET response carries for v: the
+// hash of its JSON encoding, as computed by middleware.CacheControl
+func representationETag(v interface{}) string {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(v)
+	sum := sha256.Sum256(body.Bytes())
+	return middleware.ETag(sum[:])
+}
+
+// ifMatchFails reports whether an If-Match header value fails to match
+// etag. Strong comparison is used, as required for If-Match.
+func ifMatchFails(ifMatch, etag string) bool {
+	if ifMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return false
+		}
+	}
+	return true
+}
+
+// checkIfMatch enforces the If-Match header of a write against the current
+// representation of the resource, as the caller reads it. On failure it
+// writes 412 Precondition Failed with the current ETag and returns false.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, current interface{}) bool {
+	etag := representationETag(current)
+	if !ifMatchFails(r.Header.Get("If-Match"), etag) {
+		return true
+	}
+	w.Header().Set("ETag", etag)
+	apierrors.WriteError(w, apierrors.ErrCodePreconditionFailed, "Resource changed since it was read (If-Match does not match its ETag)", http.StatusPreconditionFailed, nil)
+	return false
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to the JSON encoding of
+// current and decodes the result into target
+func mergePatch(current interface{}, patch []byte, target interface{}) error {
+	var patchValue interface{}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return err
+	}
+	merged, err := json.Marshal(mergeValue(doc, patchValue))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, target)
+}
+
+// mergeValue merges a patch value into a document value: objects are merged
+// member by member, null members are removed, anything else replaces
+func mergeValue(doc, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]interface{})
+	if !ok {
+		docObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(docObject, key)
+			continue
+		}
+		docObject[key] = mergeValue(docObject[key], value)
+	}
+	return docObject
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestMergePatch(t *testing.T) {
+	current := map[string]interface{}{
+		"name":          "tools",
+		"description":   "Build tools",
+		"admins":        []string{"a@example.com"},
+		"custom_values": map[string]string{"team": "infra", "tier": "1"},
+	}
+	var merged map[string]interface{}
+	err := mergePatch(current, []byte(`{"description":null,"admins":["b@example.com"],"custom_values":{"tier":null,"owner":"x"}}`), &merged)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"name":          "tools",
+		"admins":        []interface{}{"b@example.com"},
+		"custom_values": map[string]interface{}{"team": "infra", "owner": "x"},
+	}, merged)
+
+	assert.Error(t, mergePatch(current, []byte(`{`), &merged))
+}
+
+func TestConditionalWrites(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Build tools", []string{"a@example.com"}, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "Deploys", nil, map[string]string{"team": "infra"})))
+
+	registries := NewRegistryHandler(store, logger)
+	packages := NewPackageHandler(store, logger)
+	router := chi.NewRouter()
+	router.With(middleware.CacheControl(0, false)).Get("/registry/{name}", registries.GetRegistry)
+	router.Patch("/registry/{name}", registries.PatchRegistry)
+	router.Put("/registry/{name}", registries.UpdateRegistry)
+	router.Delete("/registry/{name}", registries.DeleteRegistry)
+	router.With(middleware.CacheControl(0, false)).Get("/registry/{name}/package/{package}", packages.GetPackage)
+	router.Patch("/registry/{name}/package/{package}", packages.PatchPackage)
+	router.Delete("/registry/{name}/package/{package}", packages.DeletePackage)
+
+	do := func(method, path, ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The ETag of a read is accepted by a conditional write
+	etag := do(http.MethodGet, "/registry/tools", "", "").Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	rec := do(http.MethodPatch, "/registry/tools", etag, `{"description":"Platform tools"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var registry models.Registry
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&registry))
+	assert.Equal(t, "Platform tools", registry.Description)
+	assert.Equal(t, []string{"a@example.com"}, registry.Admins)
+	assert.Contains(t, registry.Packages, "deployer")
+
+	// The write returns the ETag a new read sees
+	newETag := rec.Header().Get("ETag")
+	assert.Equal(t, newETag, do(http.MethodGet, "/registry/tools", "", "").Header().Get("ETag"))
+
+	// A stale ETag fails every write
+	rec = do(http.MethodPatch, "/registry/tools", etag, `{"description":"Stale"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, newETag, rec.Header().Get("ETag"))
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodPut, "/registry/tools", etag, `{"name":"tools"}`).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, "/registry/tools", etag, "").Code)
+
+	// The name cannot be patched
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPatch, "/registry/tools", "", `{"name":"other"}`).Code)
+
+	// Packages: patch custom values, keep the rest
+	pkgETag := do(http.MethodGet, "/registry/tools/package/deployer", "", "").Header().Get("ETag")
+	rec = do(http.MethodPatch, "/registry/tools/package/deployer", pkgETag, `{"custom_values":{"team":null,"owner":"platform"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var pkg models.Package
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&pkg))
+	assert.Equal(t, "Deploys", pkg.Description)
+	assert.Equal(t, map[string]string{"owner": "platform"}, pkg.CustomValues)
+
+	assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, "/registry/tools/package/deployer", pkgETag, "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/registry/tools/package/deployer", "*", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPatch, "/registry/tools/package/deployer", "", `{}`).Code)
+}
@@ -2,20 +2,25 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/auth"
 	"github.com/criteo/command-launcher-registry/internal/models"
 	"github.com/criteo/command-launcher-registry/internal/storage"
 )
 
 // RegistryHandler handles registry CRUD operations
 type RegistryHandler struct {
-	store  storage.Store
-	logger *slog.Logger
+	store       storage.Store
+	logger      *slog.Logger
+	indexMaxAge time.Duration // cache.index_max_age, reported by registries without cache_ttl
 }
 
 // NewRegistryHandler creates a new registry handler
@@ -26,6 +31,12 @@ func NewRegistryHandler(store storage.Store, logger *slog.Logger) *RegistryHandl
 	}
 }
 
+// SetIndexMaxAge sets the index cache lifetime of registries without a
+// cache_ttl of their own
+func (h *RegistryHandler) SetIndexMaxAge(maxAge time.Duration) {
+	h.indexMaxAge = maxAge
+}
+
 // CreateRegistry handles POST /api/v1/registry
 func (h *RegistryHandler) CreateRegistry(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("CreateRegistry handler called",
@@ -44,6 +55,7 @@ func (h *RegistryHandler) CreateRegistry(w http.ResponseWriter, r *http.Request)
 		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
 		return
 	}
+	models.NormalizeRegistry(&registry)
 
 	// Validate registry
 	if err := models.ValidateRegistry(&registry); err != nil {
@@ -55,14 +67,9 @@ func (h *RegistryHandler) CreateRegistry(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Initialize packages map if nil
-	if registry.Packages == nil {
-		registry.Packages = make(map[string]*models.Package)
-	}
-
 	// Create registry
 	if err := h.store.CreateRegistry(r.Context(), &registry); err != nil {
-		if err == storage.ErrAlreadyExists {
+		if err == storage.ErrAlreadyExists || err == storage.ErrEncryptionNotConfigured {
 			code, msg, status := apierrors.MapStorageError(err, "registry")
 			apierrors.WriteError(w, code, msg, status, nil)
 			return
@@ -71,7 +78,7 @@ func (h *RegistryHandler) CreateRegistry(w http.ResponseWriter, r *http.Request)
 		h.logger.Error("Failed to create registry",
 			"name", registry.Name,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to create registry", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to create registry")
 		return
 	}
 
@@ -85,7 +92,7 @@ func (h *RegistryHandler) CreateRegistry(w http.ResponseWriter, r *http.Request)
 	// Return created registry
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(registry)
+	json.NewEncoder(w).Encode(presentRegistry(r, &registry))
 }
 
 // GetRegistry handles GET /api/v1/registry/:name
@@ -104,7 +111,7 @@ func (h *RegistryHandler) GetRegistry(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("Failed to get registry",
 			"registry", registryName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to retrieve registry", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve registry")
 		return
 	}
 
@@ -116,7 +123,7 @@ func (h *RegistryHandler) GetRegistry(w http.ResponseWriter, r *http.Request) {
 	// Return registry
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(registry)
+	json.NewEncoder(w).Encode(presentRegistry(r, registry))
 }
 
 // UpdateRegistry handles PUT /api/v1/registry/:name
@@ -135,26 +142,41 @@ func (h *RegistryHandler) UpdateRegistry(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Ensure name in URL matches name in body
-	if registry.Name != registryName {
-		h.logger.Warn("Registry name mismatch",
-			"url_name", registryName,
-			"body_name", registry.Name,
-			"remote_addr", r.RemoteAddr)
-		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Registry name in URL must match name in body", http.StatusBadRequest, nil)
-		return
-	}
+	h.replaceRegistry(w, r, registryName, func(*models.Registry) (*models.Registry, error) {
+		return &registry, nil
+	})
+}
 
-	// Validate registry
-	if err := models.ValidateRegistry(&registry); err != nil {
-		h.logger.Warn("Registry validation failed",
-			"name", registry.Name,
-			"error", err,
-			"remote_addr", r.RemoteAddr)
-		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+// PatchRegistry handles PATCH /api/v1/registry/:name
+// Applies a JSON merge patch (RFC 7386) to the registry as the caller reads
+// it; packages cannot be changed this way.
+func (h *RegistryHandler) PatchRegistry(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Failed to read request body", http.StatusBadRequest, nil)
 		return
 	}
 
+	h.replaceRegistry(w, r, registryName, func(existing *models.Registry) (*models.Registry, error) {
+		var registry models.Registry
+		if err := mergePatch(presentRegistry(r, existing), patch, &registry); err != nil {
+			h.logger.Warn("Failed to apply registry patch",
+				"registry", registryName,
+				"error", err,
+				"remote_addr", r.RemoteAddr)
+			return nil, err
+		}
+		return &registry, nil
+	})
+}
+
+// replaceRegistry replaces the settings of a registry with those built from
+// the existing registry, after checking If-Match. Packages are preserved.
+func (h *RegistryHandler) replaceRegistry(w http.ResponseWriter, r *http.Request, registryName string, build func(existing *models.Registry) (*models.Registry, error)) {
+	defer lockConditional(r)()
+
 	// Get existing registry to preserve packages
 	existing, err := h.store.GetRegistry(r.Context(), registryName)
 	if err != nil {
@@ -167,16 +189,50 @@ func (h *RegistryHandler) UpdateRegistry(w http.ResponseWriter, r *http.Request)
 		h.logger.Error("Failed to get existing registry",
 			"registry", registryName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to retrieve registry", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve registry")
+		return
+	}
+	if !checkIfMatch(w, r, presentRegistry(r, existing)) {
+		return
+	}
+
+	registry, err := build(existing)
+	if err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+	models.NormalizeRegistry(registry)
+
+	// Ensure name in URL matches name in body
+	if registry.Name != registryName {
+		h.logger.Warn("Registry name mismatch",
+			"url_name", registryName,
+			"body_name", registry.Name,
+			"remote_addr", r.RemoteAddr)
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Registry name in URL must match name in body", http.StatusBadRequest, nil)
+		return
+	}
+
+	// Validate registry
+	if err := models.ValidateRegistry(registry); err != nil {
+		h.logger.Warn("Registry validation failed",
+			"name", registry.Name,
+			"error", err,
+			"remote_addr", r.RemoteAddr)
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
 		return
 	}
 
 	// Preserve packages from existing registry
 	registry.Packages = existing.Packages
 
+	// Keep sensitive values that were sent back masked
+	models.RestoreMaskedValues(registry.CustomValues, existing.CustomValues,
+		append(append([]string{}, existing.SensitiveKeys...), registry.SensitiveKeys...))
+
 	// Update registry
-	if err := h.store.UpdateRegistry(r.Context(), &registry); err != nil {
-		if err == storage.ErrNotFound {
+	if err := h.store.UpdateRegistry(r.Context(), registry); err != nil {
+		if err == storage.ErrNotFound || err == storage.ErrEncryptionNotConfigured {
 			code, msg, status := apierrors.MapStorageError(err, "registry")
 			apierrors.WriteError(w, code, msg, status, nil)
 			return
@@ -185,7 +241,7 @@ func (h *RegistryHandler) UpdateRegistry(w http.ResponseWriter, r *http.Request)
 		h.logger.Error("Failed to update registry",
 			"registry", registryName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to update registry", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to update registry")
 		return
 	}
 
@@ -196,16 +252,26 @@ func (h *RegistryHandler) UpdateRegistry(w http.ResponseWriter, r *http.Request)
 		"custom_values", len(registry.CustomValues),
 		"remote_addr", r.RemoteAddr)
 
-	// Return updated registry
+	// Return updated registry, with the ETag for further conditional writes
+	presented := presentRegistry(r, registry)
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", representationETag(presented))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(registry)
+	json.NewEncoder(w).Encode(presented)
 }
 
 // DeleteRegistry handles DELETE /api/v1/registry/:name
 func (h *RegistryHandler) DeleteRegistry(w http.ResponseWriter, r *http.Request) {
 	registryName := chi.URLParam(r, "name")
 
+	defer lockConditional(r)()
+	if r.Header.Get("If-Match") != "" {
+		existing, err := h.store.GetRegistry(r.Context(), registryName)
+		if err == nil && !checkIfMatch(w, r, presentRegistry(r, existing)) {
+			return
+		}
+	}
+
 	// Delete registry (cascade delete handled by storage layer)
 	if err := h.store.DeleteRegistry(r.Context(), registryName); err != nil {
 		if err == storage.ErrNotFound {
@@ -217,7 +283,7 @@ func (h *RegistryHandler) DeleteRegistry(w http.ResponseWriter, r *http.Request)
 		h.logger.Error("Failed to delete registry",
 			"registry", registryName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to delete registry", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to delete registry")
 		return
 	}
 
@@ -230,23 +296,251 @@ func (h *RegistryHandler) DeleteRegistry(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// CloneRegistryRequest is the body of a registry clone request
+type CloneRegistryRequest struct {
+	NewName         string `json:"new_name"`
+	IncludeVersions bool   `json:"include_versions"`
+}
+
+// CloneRegistry handles POST /api/v1/registry/:name/clone
+// Creates a registry with the settings and packages (and optionally the
+// versions) of an existing one.
+func (h *RegistryHandler) CloneRegistry(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+
+	var req CloneRegistryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("Failed to decode registry clone request",
+			"registry", registryName,
+			"error", err,
+			"remote_addr", r.RemoteAddr)
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+	if err := models.ValidateName(req.NewName); err != nil {
+		if verr, ok := err.(*models.ValidationError); ok {
+			verr.Field = "new_name"
+		}
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	clone, err := storage.CloneRegistry(r.Context(), h.store, registryName, req.NewName, req.IncludeVersions)
+	if err != nil {
+		var nameErr *storage.PackageNameError
+		switch {
+		case err == storage.ErrNotFound || err == storage.ErrEncryptionNotConfigured:
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+		case err == storage.ErrAlreadyExists:
+			apierrors.WriteError(w, apierrors.ErrCodeRegistryAlreadyExists, "Registry '"+req.NewName+"' already exists", http.StatusConflict, nil)
+		case errors.As(err, &nameErr):
+			apierrors.WriteError(w, apierrors.ErrCodePackageNameTaken, nameErr.Error(), http.StatusConflict, nil)
+		default:
+			h.logger.Error("Failed to clone registry",
+				"registry", registryName,
+				"new_name", req.NewName,
+				"error", err)
+			apierrors.WriteStorageFailure(w, err, "Failed to clone registry")
+		}
+		return
+	}
+
+	h.logger.Info("Registry cloned",
+		"registry", registryName,
+		"new_name", req.NewName,
+		"include_versions", req.IncludeVersions,
+		"package_count", len(clone.Packages),
+		"remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(presentRegistry(r, clone))
+}
+
+// Sort orders supported by ListRegistries and ListPackages (?sort=...)
+var recordSorts = map[string]struct {
+	field      string
+	descending bool
+}{
+	"name_asc":     {models.SortByName, false},
+	"name_desc":    {models.SortByName, true},
+	"created_asc":  {models.SortByCreatedAt, false},
+	"created_desc": {models.SortByCreatedAt, true},
+	"updated_asc":  {models.SortByUpdatedAt, false},
+	"updated_desc": {models.SortByUpdatedAt, true},
+}
+
+// parseRecordSort returns the field and direction of ?sort, an empty field
+// when absent. It writes a validation error and returns false if invalid.
+func parseRecordSort(w http.ResponseWriter, r *http.Request) (string, bool, bool) {
+	value := r.URL.Query().Get("sort")
+	if value == "" {
+		return "", false, true
+	}
+	order, ok := recordSorts[value]
+	if !ok {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "sort must be one of name_asc, name_desc, created_asc, created_desc, updated_asc or updated_desc", http.StatusBadRequest, nil)
+		return "", false, false
+	}
+	return order.field, order.descending, true
+}
+
 // ListRegistries handles GET /api/v1/registry
 func (h *RegistryHandler) ListRegistries(w http.ResponseWriter, r *http.Request) {
+	// Validate sort order before touching storage
+	sortField, descending, ok := parseRecordSort(w, r)
+	if !ok {
+		return
+	}
+
 	// Get all registries from storage
 	registries, err := h.store.ListRegistries(r.Context())
 	if err != nil {
 		h.logger.Error("Failed to list registries",
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to list registries", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to list registries")
 		return
 	}
 
+	// Anonymous callers only get here when server.anonymous_read is on;
+	// hide the registries that opted out of it
+	if auth.UserFromContext(r.Context()) == nil {
+		readable := make([]*models.Registry, 0, len(registries))
+		for _, registry := range registries {
+			if registry.AllowsAnonymousRead(true) {
+				readable = append(readable, registry)
+			}
+		}
+		registries = readable
+	}
+
+	if sortField != "" {
+		models.SortRegistries(registries, sortField, descending)
+	}
+
 	// Log retrieval
 	h.logger.Debug("Registries listed",
-		"count", len(registries))
+		"count", len(registries),
+		"sort", r.URL.Query().Get("sort"))
+
+	// Stream registries, masking sensitive custom values for non-admin callers
+	err = writeJSONArray(w, r, len(registries), func(i int) interface{} {
+		return presentRegistry(r, registries[i])
+	})
+	if err != nil {
+		h.logger.Error("Failed to write registry list",
+			"error", err)
+	}
+}
+
+// GetRegistryDashboard handles GET /api/v1/registry/:name/summary.json
+// Returns package and version counts, the newest version of each package,
+// the latest package changes, the registry announcements and how long
+// clients may cache the index.
+func (h *RegistryHandler) GetRegistryDashboard(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+
+	registry, err := h.store.GetRegistry(r.Context(), registryName)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to get registry",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve registry dashboard")
+		return
+	}
+
+	histories := make(map[string][]*models.ChangeRecord, len(registry.Packages))
+	for packageName := range registry.Packages {
+		history, err := h.store.GetPackageHistory(r.Context(), registryName, packageName)
+		if err == storage.ErrNotFound {
+			// Deleted since the registry was read
+			continue
+		}
+		if err != nil {
+			h.logger.Error("Failed to get package history",
+				"registry", registryName,
+				"package", packageName,
+				"error", err)
+			apierrors.WriteStorageFailure(w, err, "Failed to retrieve registry dashboard")
+			return
+		}
+		histories[packageName] = history
+	}
+
+	dashboard := models.Dashboard(registry, histories, time.Now())
+	dashboard.CacheTTL = int(registry.IndexMaxAge(h.indexMaxAge).Seconds())
+	h.logger.Debug("Registry dashboard served",
+		"registry", registryName,
+		"package_count", dashboard.PackageCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dashboard)
+}
+
+// LockRegistry handles POST /api/v1/registry/:name/lock
+// Pins each requested package to its highest released version meeting the
+// constraint, for a lockfile committed alongside a fleet rollout. Packages
+// that cannot be pinned are listed in the error details.
+func (h *RegistryHandler) LockRegistry(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+
+	var req models.LockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("Failed to decode lock request",
+			"registry", registryName,
+			"error", err,
+			"remote_addr", r.RemoteAddr)
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "Invalid JSON in request body", http.StatusBadRequest, nil)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	registry, err := h.store.GetRegistry(r.Context(), registryName)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to get registry",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to lock registry packages")
+		return
+	}
+
+	lockfile, err := models.Lock(registry, &req, time.Now())
+	if err != nil {
+		var lockErr *models.LockError
+		if errors.As(err, &lockErr) {
+			details := make(map[string]string, len(lockErr.Unresolved))
+			for _, u := range lockErr.Unresolved {
+				details[u.Name] = u.Reason
+			}
+			apierrors.WriteError(w, apierrors.ErrCodeLockUnresolved, "Some packages cannot be locked", http.StatusUnprocessableEntity, details)
+			return
+		}
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	h.logger.Debug("Registry packages locked",
+		"registry", registryName,
+		"package_count", len(lockfile.Packages))
 
-	// Return registries
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(registries)
+	json.NewEncoder(w).Encode(lockfile)
 }
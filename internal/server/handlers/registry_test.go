@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/secrets"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryHandler_ListRegistriesAnonymous(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	private := false
+	hidden := models.NewRegistry("hidden", "", nil, nil)
+	hidden.AnonymousRead = &private
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreateRegistry(ctx, hidden))
+
+	handler := NewRegistryHandler(store, logger)
+
+	list := func(user *auth.User) []string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil)
+		if user != nil {
+			req = req.WithContext(auth.WithUser(req.Context(), user))
+		}
+		rec := httptest.NewRecorder()
+		handler.ListRegistries(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var registries []models.Registry
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&registries))
+		names := make([]string, len(registries))
+		for i, registry := range registries {
+			names[i] = registry.Name
+		}
+		return names
+	}
+
+	// Registries that opted out of anonymous reads are only listed to
+	// authenticated callers
+	assert.ElementsMatch(t, []string{"tools"}, list(nil))
+	assert.ElementsMatch(t, []string{"tools", "hidden"}, list(&auth.User{Username: "alice"}))
+}
+
+func TestRegistryHandler_ListRegistriesSorted(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	for _, name := range []string{"tools", "apps", "infra"} {
+		require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry(name, "", nil, nil)))
+		time.Sleep(time.Millisecond)
+	}
+	// A package change makes its registry the latest updated
+	require.NoError(t, store.CreatePackage(ctx, "apps", models.NewPackage("deployer", "", nil, nil)))
+
+	handler := NewRegistryHandler(store, logger)
+	list := func(sort string) (int, []string) {
+		rec := httptest.NewRecorder()
+		handler.ListRegistries(rec, httptest.NewRequest(http.MethodGet, "/api/v1/registry?sort="+sort, nil))
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		var registries []models.Registry
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&registries))
+		names := make([]string, len(registries))
+		for i, registry := range registries {
+			require.NotNil(t, registry.CreatedAt)
+			names[i] = registry.Name
+		}
+		return rec.Code, names
+	}
+
+	_, names := list("name_asc")
+	assert.Equal(t, []string{"apps", "infra", "tools"}, names)
+	_, names = list("created_asc")
+	assert.Equal(t, []string{"tools", "apps", "infra"}, names)
+	_, names = list("updated_desc")
+	assert.Equal(t, []string{"apps", "infra", "tools"}, names)
+
+	code, _ := list("size")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestRegistryHandler_GetRegistryDashboard(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	registry := models.NewRegistry("tools", "Build tools", nil, nil)
+	registry.Announcements = []string{"deployer 1.x is deprecated"}
+	require.NoError(t, store.CreateRegistry(ctx, registry))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "Deploys services", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("linter", "", nil, nil)))
+	for i, version := range []string{"1.0.0", "2.0.0", "1.5.0"} {
+		require.NoError(t, store.CreateVersion(ctx, "tools", "deployer",
+			models.NewVersion("deployer", version, "sha256:abc", "https://example.com/deployer.zip", i, i)))
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/v1/registry/{name}/summary.json", NewRegistryHandler(store, logger).GetRegistryDashboard)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/registry/tools/summary.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var dashboard models.RegistryDashboard
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&dashboard))
+	assert.Equal(t, 2, dashboard.PackageCount)
+	assert.Equal(t, 3, dashboard.VersionCount)
+	assert.Equal(t, []string{"deployer 1.x is deprecated"}, dashboard.Announcements)
+	assert.Equal(t, []models.NewestVersion{{Package: "deployer", Version: "2.0.0", Description: "Deploys services"}}, dashboard.NewestVersions)
+	require.Len(t, dashboard.RecentChanges, 5)
+	assert.Equal(t, models.ChangeVersionPublished, dashboard.RecentChanges[0].Type)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/registry/missing/summary.json", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRegistryHandler_LockRegistry(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, nil)))
+	for i, version := range []string{"1.0.0", "1.2.0", "2.0.0"} {
+		require.NoError(t, store.CreateVersion(ctx, "tools", "deployer",
+			models.NewVersion("deployer", version, "sha256:abc", "https://example.com/deployer-"+version+".zip", i, i)))
+	}
+
+	router := chi.NewRouter()
+	router.Post("/api/v1/registry/{name}/lock", NewRegistryHandler(store, logger).LockRegistry)
+	lock := func(registry, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/registry/"+registry+"/lock", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := lock("tools", `{"packages":[{"name":"deployer","constraint":"^1"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var lockfile models.Lockfile
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&lockfile))
+	require.Len(t, lockfile.Packages, 1)
+	assert.Equal(t, "1.2.0", lockfile.Packages[0].Version)
+	assert.Equal(t, "https://example.com/deployer-1.2.0.zip", lockfile.Packages[0].URL)
+
+	rec = lock("tools", `{"packages":[{"name":"deployer","constraint":">=3"},{"name":"linter"}]}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"deployer":"no released version matches"`)
+	assert.Contains(t, rec.Body.String(), `"linter":"package not found"`)
+
+	assert.Equal(t, http.StatusBadRequest, lock("tools", `{"packages":[{"name":"deployer","constraint":"^^1"}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, lock("tools", `not json`).Code)
+	assert.Equal(t, http.StatusNotFound, lock("missing", `{}`).Code)
+}
+
+func TestRegistryHandler_CreateReturnsTimestamps(t *testing.T) {
+	logger := slog.Default()
+	fs, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	cipher, err := secrets.NewCipher([]byte(strings.Repeat("k", secrets.KeySize)))
+	require.NoError(t, err)
+	store := storage.NewEncryptedStore(fs, cipher, logger)
+
+	router := chi.NewRouter()
+	router.Post("/api/v1/registry", NewRegistryHandler(store, logger).CreateRegistry)
+	router.Post("/api/v1/registry/{name}/package", NewPackageHandler(store, logger).CreatePackage)
+	create := func(path, body string) map[string]any {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created map[string]any
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+		return created
+	}
+
+	// The store encrypts a copy, the response still carries the
+	// timestamps storage set on it
+	for _, request := range []struct{ path, body string }{
+		{"/api/v1/registry", `{"name":"tools","sensitive_keys":["token"],"custom_values":{"token":"secret"}}`},
+		{"/api/v1/registry/tools/package", `{"name":"deployer","custom_values":{"token":"secret"}}`},
+	} {
+		created := create(request.path, request.body)
+		assert.NotEmpty(t, created["created_at"], request.path)
+		assert.NotEmpty(t, created["updated_at"], request.path)
+	}
+}
+
+func TestRegistryHandler_RejectsCiphertextValues(t *testing.T) {
+	logger := slog.Default()
+	fs, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	cipher, err := secrets.NewCipher([]byte(strings.Repeat("k", secrets.KeySize)))
+	require.NoError(t, err)
+	store := storage.NewEncryptedStore(fs, cipher, logger)
+	require.NoError(t, store.CreateRegistry(context.Background(), &models.Registry{Name: "tools", SensitiveKeys: []string{"token"}}))
+
+	router := chi.NewRouter()
+	router.Post("/api/v1/registry", NewRegistryHandler(store, logger).CreateRegistry)
+	router.Patch("/api/v1/registry/{name}", NewRegistryHandler(store, logger).PatchRegistry)
+	router.Post("/api/v1/registry/{name}/package", NewPackageHandler(store, logger).CreatePackage)
+	serve := func(method, path, body string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	// Sensitive values sent looking encrypted would be stored in plaintext
+	value := `{"token":"` + secrets.Prefix + `c2VjcmV0"}`
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/v1/registry", `{"name":"infra","sensitive_keys":["token"],"custom_values":`+value+`}`))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPatch, "/api/v1/registry/tools", `{"custom_values":`+value+`}`))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/v1/registry/tools/package", `{"name":"deployer","custom_values":`+value+`}`))
+	_, err = fs.GetPackage(context.Background(), "tools", "deployer")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// SchemaHandler publishes JSON Schemas of the API payloads, so clients can
+// validate files before submitting them
+type SchemaHandler struct {
+	logger *slog.Logger
+}
+
+// NewSchemaHandler creates a new schema handler
+func NewSchemaHandler(logger *slog.Logger) *SchemaHandler {
+	return &SchemaHandler{
+		logger: logger,
+	}
+}
+
+// SchemaRef names a published schema and where to fetch it
+type SchemaRef struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// ListSchemas handles GET /api/v1/schemas
+func (h *SchemaHandler) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	names := models.SchemaNames()
+	refs := make([]SchemaRef, 0, len(names))
+	for _, name := range names {
+		refs = append(refs, SchemaRef{Name: name, URL: apiLink(r, "/schemas/"+name)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(refs)
+}
+
+// GetSchema handles GET /api/v1/schemas/:schema
+func (h *SchemaHandler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "schema")
+
+	schema, ok := models.Schema(name)
+	if !ok {
+		apierrors.WriteError(w, apierrors.ErrCodeSchemaNotFound, fmt.Sprintf("No schema named '%s'", name), http.StatusNotFound, nil)
+		return
+	}
+
+	h.logger.Debug("Schema served", "schema", name)
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(schema)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// isAdmin reports whether the caller holds the admin scope
+func isAdmin(r *http.Request) bool {
+	return auth.UserFromContext(r.Context()).HasScope(auth.ScopeAdmin)
+}
+
+// presentRegistry returns the registry as the caller may see it:
+// sensitive custom values are masked unless the caller is an admin
+func presentRegistry(r *http.Request, registry *models.Registry) *models.Registry {
+	if isAdmin(r) {
+		return registry
+	}
+	return registry.Masked()
+}
+
+// presentPackage returns the package as the caller may see it
+func presentPackage(r *http.Request, pkg *models.Package, sensitiveKeys []string) *models.Package {
+	if isAdmin(r) || len(sensitiveKeys) == 0 {
+		return pkg
+	}
+	return pkg.Masked(sensitiveKeys)
+}
+
+// sensitiveKeys looks up the sensitive custom value keys of a registry.
+// On failure it writes an error response and returns false, so handlers
+// never fall back to serving unmasked values.
+func (h *PackageHandler) sensitiveKeys(w http.ResponseWriter, r *http.Request, registryName string) ([]string, bool) {
+	registry, err := h.store.GetRegistry(r.Context(), registryName)
+	if err != nil {
+		code, msg, status := apierrors.MapStorageError(err, "registry")
+		if code == apierrors.ErrCodeRegistryNotFound {
+			apierrors.WriteError(w, code, msg, status, nil)
+			return nil, false
+		}
+
+		h.logger.Error("Failed to get registry",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve registry")
+		return nil, false
+	}
+	return registry.SensitiveKeys, true
+}
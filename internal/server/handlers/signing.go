@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/signing"
+)
+
+// SigningHandler publishes the public keys used to sign index.json
+type SigningHandler struct {
+	keys   *signing.KeySet
+	logger *slog.Logger
+}
+
+// NewSigningHandler creates a new signing handler
+func NewSigningHandler(keys *signing.KeySet, logger *slog.Logger) *SigningHandler {
+	return &SigningHandler{
+		keys:   keys,
+		logger: logger,
+	}
+}
+
+// GetJWKS handles GET /api/v1/jwks.json
+// Returns an empty key set when signing is disabled.
+func (h *SigningHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	jwks := h.keys.JWKS()
+
+	h.logger.Debug("JWKS served", "key_count", len(jwks.Keys))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(jwks)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+)
+
+// streamFlushEvery is the number of array elements written between flushes
+const streamFlushEvery = 500
+
+// encodeJSONArray writes n elements as a JSON array, one element at a time,
+// so the encoded body is never held in memory. The output is byte-for-byte
+// what json.Encoder produces for the equivalent slice (except that an empty
+// list is "[]", never "null").
+func encodeJSONArray(w io.Writer, n int, item func(i int) interface{}) error {
+	flusher, _ := w.(http.Flusher)
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		data, err := json.Marshal(item(i))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if flusher != nil && (i+1)%streamFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+// writeJSONArray streams a list response with chunked transfer encoding.
+// The ETag is derived from a first encoding pass into a hash, so conditional
+// requests are answered with 304 without buffering the body; the caching
+// middleware passes the streamed response through unchanged.
+// Errors after the status line was sent can only be logged by the caller.
+func writeJSONArray(w http.ResponseWriter, r *http.Request, n int, item func(i int) interface{}) error {
+	hash := sha256.New()
+	if err := encodeJSONArray(hash, n, item); err != nil {
+		return err
+	}
+	etag := middleware.ETag(hash.Sum(nil))
+	w.Header().Set("ETag", etag)
+
+	if middleware.ETagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	return encodeJSONArray(w, n, item)
+}
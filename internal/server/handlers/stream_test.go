@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeJSONArray_MatchesEncoder(t *testing.T) {
+	versions := []*models.Version{
+		models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip?a=1&b=<2>", 0, 4),
+		models.NewVersion("pkg", "2.0.0", "sha256:b", "http://x/2.zip", 5, 9),
+	}
+
+	var expected bytes.Buffer
+	require.NoError(t, json.NewEncoder(&expected).Encode(versions))
+
+	var streamed bytes.Buffer
+	require.NoError(t, encodeJSONArray(&streamed, len(versions), func(i int) interface{} {
+		return versions[i]
+	}))
+	assert.Equal(t, expected.String(), streamed.String())
+
+	// Empty lists are arrays, never null
+	streamed.Reset()
+	require.NoError(t, encodeJSONArray(&streamed, 0, nil))
+	assert.Equal(t, "[]\n", streamed.String())
+}
+
+func TestWriteJSONArray_ThroughCacheMiddleware(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	handler := middleware.CacheControl(30*time.Second, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, writeJSONArray(w, r, len(items), func(i int) interface{} {
+			return items[i]
+		}))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "[\"a\",\"b\",\"c\"]\n", rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=30, must-revalidate", rec.Header().Get("Cache-Control"))
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// Revalidation is answered without a body
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// SyncGenerationHeader carries the current storage generation on sync responses
+const SyncGenerationHeader = "X-Sync-Generation"
+
+// SyncHandler handles differential sync requests
+type SyncHandler struct {
+	store  storage.Store
+	logger *slog.Logger
+}
+
+// NewSyncHandler creates a new sync handler
+func NewSyncHandler(store storage.Store, logger *slog.Logger) *SyncHandler {
+	return &SyncHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// GetSync handles GET /api/v1/sync?since=<generation>
+// Streams the records changed after the given generation as NDJSON, one
+// change per line, terminated by an "end" record carrying the generation
+// to pass as since on the next cycle. Sensitive values stay encrypted.
+func (h *SyncHandler) GetSync(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			apierrors.WriteError(w, apierrors.ErrCodeValidationError, "since must be a non-negative integer generation", http.StatusBadRequest, nil)
+			return
+		}
+		since = parsed
+	}
+
+	changes, generation, err := h.store.Changes(r.Context(), since)
+	if err != nil {
+		if err == storage.ErrGenerationAhead {
+			w.Header().Set(SyncGenerationHeader, strconv.FormatUint(generation, 10))
+		} else {
+			h.logger.Error("Failed to compute sync changes",
+				"since", since,
+				"error", err)
+		}
+		code, msg, status := apierrors.MapStorageError(err, "")
+		apierrors.WriteError(w, code, msg, status, nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set(SyncGenerationHeader, strconv.FormatUint(generation, 10))
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for i := range changes {
+		if err := encoder.Encode(&changes[i]); err != nil {
+			h.logger.Error("Failed to stream sync change",
+				"since", since,
+				"error", err)
+			return
+		}
+		// Flush periodically so large streams reach the client progressively
+		if flusher != nil && i%100 == 99 {
+			flusher.Flush()
+		}
+	}
+	encoder.Encode(&models.SyncChange{
+		Generation: generation,
+		Type:       models.SyncTypeEnd,
+	})
+
+	h.logger.Info("Sync changes served",
+		"since", since,
+		"generation", generation,
+		"change_count", len(changes))
+}
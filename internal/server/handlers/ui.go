@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// uiPage is the single-page web UI: registries, their packages and recent
+// changes, and the API reference, built from the public API alone
+//
+//go:embed ui/index.html
+var uiPage []byte
+
+// UIHandler serves the bundled web UI and the API description it renders
+// (standalone mode)
+type UIHandler struct {
+	openAPI []byte // OpenAPI description as JSON
+	logger  *slog.Logger
+}
+
+// NewUIHandler creates a UI handler from the OpenAPI description of the
+// API, in YAML or JSON
+func NewUIHandler(openAPI []byte, logger *slog.Logger) (*UIHandler, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI description: %w", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI description: %w", err)
+	}
+	return &UIHandler{openAPI: data, logger: logger}, nil
+}
+
+// GetUI handles GET /ui
+func (h *UIHandler) GetUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(uiPage)
+}
+
+// GetOpenAPI handles GET /docs/openapi.json
+func (h *UIHandler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.openAPI)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/docs"
+)
+
+func TestUIHandler(t *testing.T) {
+	h, err := NewUIHandler(docs.OpenAPI, slog.Default())
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	h.GetUI(w, httptest.NewRequest(http.MethodGet, "/ui", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<title>COLA Registry</title>")
+
+	w = httptest.NewRecorder()
+	h.GetOpenAPI(w, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Contains(t, spec, "paths")
+
+	_, err = NewUIHandler([]byte("not: [an openapi"), slog.Default())
+	assert.Error(t, err)
+}
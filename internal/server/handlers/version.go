@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/criteo/command-launcher-registry/internal/apierrors"
+	"github.com/criteo/command-launcher-registry/internal/checksum"
+	"github.com/criteo/command-launcher-registry/internal/fips"
 	"github.com/criteo/command-launcher-registry/internal/models"
 	"github.com/criteo/command-launcher-registry/internal/storage"
 )
@@ -44,8 +48,24 @@ func (h *VersionHandler) CreateVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Look up the registry to apply its version policy (strict semver by default)
+	registry, err := h.store.GetRegistry(r.Context(), registryName)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			code, msg, status := apierrors.MapStorageError(err, "registry")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to get registry",
+			"registry", registryName,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve registry")
+		return
+	}
+
 	// Validate version
-	if err := models.ValidateVersionData(&version); err != nil {
+	if err := models.ValidateVersionDataWithPolicy(&version, registry.VersionPolicy); err != nil {
 		h.logger.Warn("Version validation failed",
 			"registry", registryName,
 			"package", packageName,
@@ -55,6 +75,16 @@ func (h *VersionHandler) CreateVersion(w http.ResponseWriter, r *http.Request) {
 		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
 		return
 	}
+	if algorithm := checksum.Algorithm(version.Checksum); fips.Enabled() && !checksum.FIPSApproved(algorithm) {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError,
+			fmt.Sprintf("checksum algorithm %s is not allowed in FIPS mode, use sha256 or sha512", algorithm), http.StatusBadRequest, nil)
+		return
+	}
+
+	// A publish_at in the past means publish now; keep the record clean
+	if version.PublishAt != nil && !version.IsPending(time.Now()) {
+		version.PublishAt = nil
+	}
 
 	// Create version
 	if err := h.store.CreateVersion(r.Context(), registryName, packageName, &version); err != nil {
@@ -85,7 +115,7 @@ func (h *VersionHandler) CreateVersion(w http.ResponseWriter, r *http.Request) {
 			"package", packageName,
 			"version", version.Version,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to create version", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to create version")
 		return
 	}
 
@@ -96,6 +126,7 @@ func (h *VersionHandler) CreateVersion(w http.ResponseWriter, r *http.Request) {
 		"version", version.Version,
 		"partitions", version.StartPartition,
 		"partition_end", version.EndPartition,
+		"scheduled", version.PublishAt != nil,
 		"remote_addr", r.RemoteAddr)
 
 	// Return created version
@@ -133,7 +164,7 @@ func (h *VersionHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
 			"package", packageName,
 			"version", versionNum,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to retrieve version", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to retrieve version")
 		return
 	}
 
@@ -143,10 +174,10 @@ func (h *VersionHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
 		"package", packageName,
 		"version", versionNum)
 
-	// Return version
+	// Return version with its context and links
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(version)
+	json.NewEncoder(w).Encode(presentVersion(r, registryName, packageName, version))
 }
 
 // DeleteVersion handles DELETE /api/v1/registry/:name/package/:package/version/:version
@@ -177,7 +208,7 @@ func (h *VersionHandler) DeleteVersion(w http.ResponseWriter, r *http.Request) {
 			"package", packageName,
 			"version", versionNum,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to delete version", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to delete version")
 		return
 	}
 
@@ -192,11 +223,72 @@ func (h *VersionHandler) DeleteVersion(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// CancelVersion handles DELETE /api/v1/registry/:name/package/:package/version/:version/schedule
+// It removes a version that is scheduled but not yet published.
+func (h *VersionHandler) CancelVersion(w http.ResponseWriter, r *http.Request) {
+	registryName := chi.URLParam(r, "name")
+	packageName := chi.URLParam(r, "package")
+	versionNum := chi.URLParam(r, "version")
+
+	if err := h.store.CancelVersion(r.Context(), registryName, packageName, versionNum); err != nil {
+		if err == storage.ErrNotFound {
+			// Determine what was not found
+			if _, regErr := h.store.GetRegistry(r.Context(), registryName); regErr == storage.ErrNotFound {
+				code, msg, status := apierrors.MapStorageError(err, "registry")
+				apierrors.WriteError(w, code, msg, status, nil)
+			} else if _, pkgErr := h.store.GetPackage(r.Context(), registryName, packageName); pkgErr == storage.ErrNotFound {
+				code, msg, status := apierrors.MapStorageError(err, "package")
+				apierrors.WriteError(w, code, msg, status, nil)
+			} else {
+				code, msg, status := apierrors.MapStorageError(err, "version")
+				apierrors.WriteError(w, code, msg, status, nil)
+			}
+			return
+		}
+		if err == storage.ErrNotScheduled {
+			code, msg, status := apierrors.MapStorageError(err, "version")
+			apierrors.WriteError(w, code, msg, status, nil)
+			return
+		}
+
+		h.logger.Error("Failed to cancel scheduled version",
+			"registry", registryName,
+			"package", packageName,
+			"version", versionNum,
+			"error", err)
+		apierrors.WriteStorageFailure(w, err, "Failed to cancel version")
+		return
+	}
+
+	h.logger.Info("Scheduled version cancelled",
+		"registry", registryName,
+		"package", packageName,
+		"version", versionNum,
+		"remote_addr", r.RemoteAddr)
+
+	// Return 204 No Content
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sort orders supported by ListVersions (?sort=...)
+const (
+	SortSemverAsc  = "semver_asc"
+	SortSemverDesc = "semver_desc"
+)
+
 // ListVersions handles GET /api/v1/registry/:name/package/:package/version
+// Supports ?sort=semver_asc|semver_desc for version-aware ordering.
 func (h *VersionHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	registryName := chi.URLParam(r, "name")
 	packageName := chi.URLParam(r, "package")
 
+	// Validate sort order before touching storage
+	sortOrder := r.URL.Query().Get("sort")
+	if sortOrder != "" && sortOrder != SortSemverAsc && sortOrder != SortSemverDesc {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, "sort must be 'semver_asc' or 'semver_desc'", http.StatusBadRequest, nil)
+		return
+	}
+
 	// Get all versions from storage
 	versions, err := h.store.ListVersions(r.Context(), registryName, packageName)
 	if err != nil {
@@ -216,18 +308,77 @@ func (h *VersionHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 			"registry", registryName,
 			"package", packageName,
 			"error", err)
-		apierrors.WriteError(w, apierrors.ErrCodeStorageUnavailable, "Failed to list versions", http.StatusInternalServerError, nil)
+		apierrors.WriteStorageFailure(w, err, "Failed to list versions")
 		return
 	}
 
+	// Apply version-aware ordering if requested
+	if sortOrder != "" {
+		models.SortVersions(versions, sortOrder == SortSemverDesc)
+	}
+
 	// Log retrieval
 	h.logger.Debug("Versions listed",
 		"registry", registryName,
 		"package", packageName,
-		"count", len(versions))
+		"count", len(versions),
+		"sort", sortOrder)
+
+	// Stream versions
+	err = writeJSONArray(w, r, len(versions), func(i int) interface{} {
+		return presentVersion(r, registryName, packageName, versions[i])
+	})
+	if err != nil {
+		h.logger.Error("Failed to write version list",
+			"registry", registryName,
+			"package", packageName,
+			"error", err)
+	}
+}
+
+// CompareResponse represents the result of comparing two versions
+type CompareResponse struct {
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Result int    `json:"result"` // -1 if a < b, 0 if equal precedence, 1 if a > b
+}
+
+// CompareVersions handles GET /api/v1/version/compare?a=<version>&b=<version>
+// Both strict semver and legacy version strings are accepted.
+func (h *VersionHandler) CompareVersions(w http.ResponseWriter, r *http.Request) {
+	a := r.URL.Query().Get("a")
+	b := r.URL.Query().Get("b")
+
+	parsedA, err := parseComparable("a", a)
+	if err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+	parsedB, err := parseComparable("b", b)
+	if err != nil {
+		apierrors.WriteError(w, apierrors.ErrCodeValidationError, err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	response := CompareResponse{
+		A:      a,
+		B:      b,
+		Result: parsedA.Compare(parsedB),
+	}
 
-	// Return versions
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(versions)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseComparable validates a version query parameter with the most permissive
+// (legacy) policy and parses it for comparison
+func parseComparable(param, version string) (*models.ParsedVersion, error) {
+	if version == "" {
+		return nil, fmt.Errorf("query parameter '%s' is required", param)
+	}
+	if err := models.ValidateVersionWithPolicy(version, models.VersionPolicyLegacy); err != nil {
+		return nil, fmt.Errorf("query parameter '%s' is not a valid version: %s", param, version)
+	}
+	return models.ParseVersion(version)
 }
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestVersionHandler_Links(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, nil)))
+	checksum := "sha256:" + strings.Repeat("a", 64)
+	require.NoError(t, store.CreateVersion(ctx, "tools", "deployer", models.NewVersion("deployer", "1.0.0+build.1", checksum, "https://example.com/d.zip", 0, 9)))
+
+	handler := NewVersionHandler(store, logger)
+	router := chi.NewRouter()
+	router.Get("/api/v1/registry/{name}/package/{package}/version", handler.ListVersions)
+	router.Get("/api/v1/registry/{name}/package/{package}/version/{version}", handler.GetVersion)
+
+	expected := `{
+		"name": "deployer",
+		"version": "1.0.0+build.1",
+		"checksum": "` + checksum + `",
+		"url": "https://example.com/d.zip",
+		"startPartition": 0,
+		"endPartition": 9,
+		"registry": "tools",
+		"package": "deployer",
+		"_links": {
+			"self": {"href": "/api/v1/registry/tools/package/deployer/version/1.0.0+build.1"},
+			"package": {"href": "/api/v1/registry/tools/package/deployer"},
+			"registry": {"href": "/api/v1/registry/tools"},
+			"index": {"href": "/api/v1/registry/tools/index.json"}
+		}
+	}`
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/registry/tools/package/deployer/version/1.0.0+build.1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, expected, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/registry/tools/package/deployer/version", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "["+expected+"]", rec.Body.String())
+
+	// Links include the base path the API is served under
+	rec = httptest.NewRecorder()
+	middleware.StripBasePath("/cola")(router).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cola/api/v1/registry/tools/package/deployer/version/1.0.0+build.1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, strings.ReplaceAll(expected, `"/api/v1/`, `"/cola/api/v1/`), rec.Body.String())
+
+	// An external URL makes links absolute; it already includes any prefix
+	rec = httptest.NewRecorder()
+	external := middleware.WithExternalURL("https://gateway.example.com/cola")(router)
+	middleware.StripBasePath("/cola")(external).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cola/api/v1/registry/tools/package/deployer/version/1.0.0+build.1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, strings.ReplaceAll(expected, `"/api/v1/`, `"https://gateway.example.com/cola/api/v1/`), rec.Body.String())
+}
@@ -7,25 +7,18 @@ import (
 
 // NewLogger creates a new structured logger
 func NewLogger(level, format string) *slog.Logger {
-	// Parse log level
-	var logLevel slog.Level
-	switch level {
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "info":
-		logLevel = slog.LevelInfo
-	case "warn":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
-	default:
-		logLevel = slog.LevelInfo
-	}
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(ParseLogLevel(level))
+	return NewLeveledLogger(levelVar, format)
+}
 
+// NewLeveledLogger creates a structured logger whose level can be changed
+// at runtime through levelVar (used by config reload)
+func NewLeveledLogger(levelVar *slog.LevelVar, format string) *slog.Logger {
 	// Create handler based on format
 	var handler slog.Handler
 	opts := &slog.HandlerOptions{
-		Level: logLevel,
+		Level: levelVar,
 	}
 
 	if format == "json" {
@@ -36,3 +29,20 @@ func NewLogger(level, format string) *slog.Logger {
 
 	return slog.New(handler)
 }
+
+// ParseLogLevel converts a configured log level to a slog level.
+// Unknown levels fall back to info.
+func ParseLogLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "info":
+		return slog.LevelInfo
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+)
+
+// AnonymousRead returns middleware for read routes. Like OptionalAuth it
+// identifies callers presenting valid credentials; anonymous callers are
+// answered 401 unless allowed reports that the requested resource may be
+// read without credentials.
+func AnonymousRead(authenticator auth.Authenticator, allowed func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, err := authenticate(authenticator, r); err == nil {
+				next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), user)))
+				return
+			}
+			if !allowed(r) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="COLA Registry"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// apiKeyServerPaths are the API routes about the server itself that API
+// keys may read, besides their registry
+var apiKeyServerPaths = map[string]bool{
+	"/api/v1/health":          true,
+	"/api/v1/server-info":     true,
+	"/api/v1/whoami":          true,
+	"/api/v1/version/compare": true,
+	"/api/v1/jwks.json":       true,
+}
+
+// RestrictAPIKeys confines callers authenticated with an API key to their
+// registry: they may read it, lock it, and publish versions of the packages
+// whose names start with the key's prefix. Any other API route is answered 403.
+// Other callers are not affected.
+func RestrictAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil || user.APIKey == nil || apiKeyAllows(user.APIKey, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
+func apiKeyAllows(key *models.APIKey, r *http.Request) bool {
+	path := r.URL.Path
+	registryPath := "/api/v1/registry/" + key.Registry
+	switch r.Method {
+	case http.MethodOptions:
+		return true
+	case http.MethodGet, http.MethodHead:
+		return !strings.HasPrefix(path, "/api/v1/") ||
+			apiKeyServerPaths[path] ||
+			strings.HasPrefix(path, "/api/v1/schemas") ||
+			path == registryPath ||
+			strings.HasPrefix(path, registryPath+"/")
+	case http.MethodPost:
+		if path == registryPath+"/lock" { // Reads only
+			return true
+		}
+		// POST /api/v1/registry/{name}/package/{package}/version
+		rest, ok := strings.CutPrefix(path, registryPath+"/package/")
+		if !ok {
+			return false
+		}
+		packageName, ok := strings.CutSuffix(rest, "/version")
+		return ok && !strings.Contains(packageName, "/") && key.AllowsPublish(key.Registry, packageName)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestRestrictAPIKeys(t *testing.T) {
+	key, secret, err := models.NewAPIKey("tools", "payments-", "", "alice", nil)
+	require.NoError(t, err)
+	expiresAt := time.Now().Add(-time.Minute)
+	expired, expiredSecret, err := models.NewAPIKey("tools", "", "", "alice", nil)
+	require.NoError(t, err)
+	expired.ExpiresAt = &expiresAt
+
+	apiKeys := auth.NewAPIKeyAuth(userAuth{}, slog.Default())
+	apiKeys.SetLookup(func(_ context.Context, id string) (*models.APIKey, error) {
+		for _, k := range []*models.APIKey{key, expired} {
+			if k.ID == id {
+				return k, nil
+			}
+		}
+		return nil, assert.AnError
+	})
+	handler := Identify(apiKeys)(RestrictAPIKeys(RequireUser(apiKeys)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+
+	send := func(method, path, username, password string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.SetBasicAuth(username, password)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodPost, "/api/v1/registry/tools/package/payments-cli/version", http.StatusOK},
+		{http.MethodGet, "/api/v1/registry/tools/package/deployer", http.StatusOK},
+		{http.MethodGet, "/api/v1/registry/tools/index.json", http.StatusOK},
+		{http.MethodPost, "/api/v1/registry/tools/lock", http.StatusOK},
+		{http.MethodGet, "/api/v1/whoami", http.StatusOK},
+		// Other packages, registries and operations
+		{http.MethodPost, "/api/v1/registry/tools/package/deployer/version", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/registry/infra/package/payments-cli/version", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/registry/infra", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/registry/toolsmith", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/registry", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/registry/tools/package", http.StatusForbidden},
+		{http.MethodDelete, "/api/v1/registry/tools/package/payments-cli/version/1.0.0", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/registry/tools/api-keys", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/admin/quarantine", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.status, send(tt.method, tt.path, key.ID, secret), "%s %s", tt.method, tt.path)
+	}
+
+	// Users are not restricted; wrong or expired keys are rejected
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/v1/registry/infra/package", "alice", "secret"))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/v1/whoami", key.ID, "wrong"))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/v1/whoami", expired.ID, expiredSecret))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/v1/whoami", models.APIKeyPrefix+"missing", secret))
+}
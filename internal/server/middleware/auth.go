@@ -1,27 +1,130 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/criteo/command-launcher-registry/internal/auth"
 )
 
-// RequireAuth returns middleware that requires authentication for write operations
-// Read operations (GET) are allowed without authentication
+// identity is the outcome of authenticating a request in Identify
+type identity struct {
+	user *auth.User
+	err  error
+}
+
+type identityContextKey struct{}
+
+// Identify returns middleware authenticating requests that carry
+// credentials before they are routed, so the rate limiter can count them
+// against their user. Requests are never rejected here; the outcome is
+// reused by the middleware below, so credentials are checked once.
+func Identify(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				user, err := authenticator.Authenticate(r)
+				ctx := context.WithValue(r.Context(), identityContextKey{}, &identity{user: user, err: err})
+				if err == nil {
+					ctx = auth.WithUser(ctx, user)
+				}
+				r = r.WithContext(ctx)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authenticate returns the outcome of Identify for the request, or
+// authenticates it when Identify did not run
+func authenticate(authenticator auth.Authenticator, r *http.Request) (*auth.User, error) {
+	if id, ok := r.Context().Value(identityContextKey{}).(*identity); ok {
+		return id.user, id.err
+	}
+	return authenticator.Authenticate(r)
+}
+
+// RequireAuth returns middleware that requires authentication for every
+// method but the safe ones (GET, HEAD and OPTIONS), which are allowed
+// without it: a method added later is protected without being listed
 func RequireAuth(authenticator auth.Authenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Check if this is a write operation
-			if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodDelete {
+			if !isSafe(r.Method) {
 				// Require authentication
-				if _, err := authenticator.Authenticate(r); err != nil {
+				user, err := authenticate(authenticator, r)
+				if err != nil {
 					w.Header().Set("WWW-Authenticate", `Basic realm="COLA Registry"`)
 					http.Error(w, "Unauthorized", http.StatusUnauthorized)
 					return
 				}
+				r = r.WithContext(auth.WithUser(r.Context(), user))
 			}
 
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// isSafe reports whether a method only reads
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// RequireUser returns middleware that requires authentication for every
+// method, including reads, and stores the user in the request context
+func RequireUser(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := authenticate(authenticator, r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Basic realm="COLA Registry"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), user)))
+		})
+	}
+}
+
+// OptionalAuth returns middleware that identifies the caller when valid
+// credentials are present, without rejecting anonymous requests.
+// Handlers read the user with auth.UserFromContext.
+func OptionalAuth(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, err := authenticate(authenticator, r); err == nil {
+				r = r.WithContext(auth.WithUser(r.Context(), user))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope returns middleware that requires an authenticated user holding
+// scope for every method, and stores the user in the request context
+func RequireScope(authenticator auth.Authenticator, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := authenticate(authenticator, r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Basic realm="COLA Registry"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !user.HasScope(scope) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), user)))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type basePathContextKey struct{}
+
+type externalURLContextKey struct{}
+
+// BasePath returns the prefix the request was received under, to prepend to
+// the paths of links in responses ("" when the API is served at the root)
+func BasePath(ctx context.Context) string {
+	basePath, _ := ctx.Value(basePathContextKey{}).(string)
+	return basePath
+}
+
+// ExternalURL returns the public URL of the API root recorded by
+// WithExternalURL, or "" when links should stay relative to the host
+func ExternalURL(ctx context.Context) string {
+	externalURL, _ := ctx.Value(externalURLContextKey{}).(string)
+	return externalURL
+}
+
+// WithExternalURL returns middleware recording the URL clients reach the
+// server at (behind a proxy, not the address it binds to), so responses can
+// carry absolute links. An empty externalURL records nothing.
+func WithExternalURL(externalURL string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if externalURL == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), externalURLContextKey{}, externalURL)))
+		})
+	}
+}
+
+// StripBasePath returns middleware serving the API below basePath (e.g.
+// /cola) for reverse proxies routing services by path: the prefix is removed
+// before routing and recorded for BasePath. Requests outside the prefix get
+// 404. An empty basePath serves the API at the root.
+func StripBasePath(basePath string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if basePath == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, ok := trimBasePath(r.URL.Path, basePath)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			u := *r.URL
+			u.Path = path
+			if u.RawPath != "" {
+				u.RawPath, _ = trimBasePath(u.RawPath, basePath)
+			}
+			r2 := r.WithContext(context.WithValue(r.Context(), basePathContextKey{}, basePath))
+			r2.URL = &u
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// trimBasePath removes basePath from path, and reports whether path was
+// below it
+func trimBasePath(path, basePath string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, basePath)
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return "", false
+	}
+	if rest == "" {
+		return "/", true
+	}
+	return rest, true
+}
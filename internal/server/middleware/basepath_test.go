@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripBasePath(t *testing.T) {
+	handler := StripBasePath("/cola")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(BasePath(r.Context()) + " " + r.URL.Path))
+	}))
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/cola/api/v1/health", http.StatusOK, "/cola /api/v1/health"},
+		{"/cola/readyz", http.StatusOK, "/cola /readyz"},
+		{"/cola/", http.StatusOK, "/cola /"},
+		{"/cola", http.StatusOK, "/cola /"},
+		{"/api/v1/health", http.StatusNotFound, ""},
+		{"/colab/api/v1/health", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("no base path", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		StripBasePath("")(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cola/api/v1/health", nil))
+		assert.Equal(t, "/cola /api/v1/health", rec.Body.String())
+	})
+}
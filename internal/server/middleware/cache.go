@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cacheWriter buffers a response so an ETag can be computed from its body.
+// Handlers that stream large bodies set the ETag themselves before writing;
+// their responses are passed through unbuffered.
+type cacheWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	directive   string
+	statusCode  int
+	body        bytes.Buffer
+	passthrough bool
+}
+
+func (cw *cacheWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *cacheWriter) WriteHeader(code int) {
+	if cw.passthrough {
+		return
+	}
+	if cw.header.Get("ETag") != "" {
+		cw.passthrough = true
+		if code == http.StatusOK || code == http.StatusPartialContent || code == http.StatusNotModified {
+			setCacheHeaders(cw.header, cw.directive)
+		}
+		cw.w.WriteHeader(code)
+		return
+	}
+	cw.statusCode = code
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if !cw.passthrough && cw.body.Len() == 0 && cw.header.Get("ETag") != "" {
+		cw.WriteHeader(cw.statusCode)
+	}
+	if cw.passthrough {
+		return cw.w.Write(b)
+	}
+	return cw.body.Write(b)
+}
+
+// Flush sends streamed responses progressively
+func (cw *cacheWriter) Flush() {
+	if f, ok := cw.w.(http.Flusher); cw.passthrough && ok {
+		f.Flush()
+	}
+}
+
+// ETag formats a content hash as a strong entity tag
+func ETag(sum []byte) string {
+	if len(sum) > 16 {
+		sum = sum[:16]
+	}
+	return `"` + hex.EncodeToString(sum) + `"`
+}
+
+// setCacheHeaders sets the caching headers of a cacheable response. A
+// Cache-Control directive set by the handler is kept, for resources with
+// their own cache lifetime.
+func setCacheHeaders(h http.Header, directive string) {
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", directive)
+	}
+	// Admins may see unmasked values, so responses differ per caller
+	h.Add("Vary", "Authorization")
+}
+
+// CacheControl returns middleware that sets Cache-Control and ETag headers on
+// successful GET responses and answers matching If-None-Match requests with
+// 304 Not Modified.
+// A maxAge of zero still sends an ETag but asks clients to revalidate on
+// every request (no-cache). Private responses are never stored by shared caches.
+func CacheControl(maxAge time.Duration, private bool) func(http.Handler) http.Handler {
+	directive := CacheDirective(maxAge, private)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &cacheWriter{
+				w:          w,
+				header:     w.Header(),
+				directive:  directive,
+				statusCode: http.StatusOK,
+			}
+			next.ServeHTTP(cw, r)
+			if cw.passthrough {
+				return
+			}
+
+			// Only successful responses are cacheable
+			if cw.statusCode != http.StatusOK {
+				w.WriteHeader(cw.statusCode)
+				w.Write(cw.body.Bytes())
+				return
+			}
+
+			sum := sha256.Sum256(cw.body.Bytes())
+			etag := ETag(sum[:])
+			w.Header().Set("ETag", etag)
+			setCacheHeaders(w.Header(), directive)
+
+			if ETagMatches(r.Header.Get("If-None-Match"), etag) {
+				w.Header().Del("Content-Type")
+				w.Header().Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			w.Write(cw.body.Bytes())
+		})
+	}
+}
+
+// CacheDirective returns the Cache-Control directive of responses cacheable
+// for maxAge, as set by CacheControl
+func CacheDirective(maxAge time.Duration, private bool) string {
+	scope := "public"
+	if private {
+		scope = "private"
+	}
+	if maxAge <= 0 {
+		return scope + ", no-cache"
+	}
+	return fmt.Sprintf("%s, max-age=%d, must-revalidate", scope, int(maxAge.Seconds()))
+}
+
+// ETagMatches reports whether an If-None-Match header value matches etag.
+// Weak comparison is used, as required for If-None-Match.
+func ETagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// Limits of captured requests
+const (
+	maxCapturedBody     = 64 << 10 // Request bodies kept, in bytes
+	maxCapturedResponse = 4 << 10  // Response bodies kept, in bytes
+	redactedValue       = "[REDACTED]"
+)
+
+// capturedUser returns the user of a captured request: its UserLabel, or
+// the username claimed by Basic credentials that failed. Captures are
+// bounded, so unlike user counts they can keep what the caller claimed.
+func capturedUser(r *http.Request) string {
+	label := UserLabel(r)
+	if label != labelUnauthenticated {
+		return label
+	}
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		return username
+	}
+	return label
+}
+
+// sensitiveFieldPattern matches the header and JSON field names whose values
+// are never captured
+var sensitiveFieldPattern = regexp.MustCompile(`(?i)authorization|cookie|password|secret|token|credential|api[-_]?key`)
+
+// CapturedRequest is a failed write request kept for debugging, with its
+// credentials and secrets redacted
+type CapturedRequest struct {
+	ID           uint64            `json:"id"`
+	Time         time.Time         `json:"time"`
+	RequestID    string            `json:"request_id,omitempty"`
+	User         string            `json:"user"`
+	Method       string            `json:"method"`
+	Path         string            `json:"path"`
+	Query        string            `json:"query,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         json.RawMessage   `json:"body,omitempty"`         // Sanitized JSON
+	BodyOmitted  string            `json:"body_omitted,omitempty"` // Why the body was not kept
+	Status       int               `json:"status"`
+	Response     string            `json:"response,omitempty"` // Start of the response body
+	DurationMsec float64           `json:"duration_msec"`
+	Curl         string            `json:"curl"` // Replays the request against $COLA_REGISTRY_URL
+}
+
+// RequestCapture keeps the last failed write requests (POST, PUT, PATCH and
+// DELETE answered 4xx or 5xx) in a ring buffer, so a failure reported by a
+// user can be reproduced without asking them to rerun it with verbose
+// logging. Headers and JSON fields named like credentials are redacted, as
+// are custom values, which may be sensitive. The size can be changed at
+// runtime with SetSize (used by config reload); 0 disables capture. It must
+// run after Identify.
+type RequestCapture struct {
+	mu      sync.Mutex
+	size    int
+	records []CapturedRequest // Oldest first
+	nextID  uint64
+}
+
+// NewRequestCapture creates a request capture
+// size: failed requests kept, zero to disable capture
+func NewRequestCapture(size int) *RequestCapture {
+	capture := &RequestCapture{}
+	capture.SetSize(size)
+	return capture
+}
+
+// SetSize changes the number of failed requests kept, dropping the oldest
+// ones beyond it
+func (c *RequestCapture) SetSize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	if len(c.records) > size {
+		c.records = append([]CapturedRequest(nil), c.records[len(c.records)-size:]...)
+	}
+}
+
+// Enabled reports whether failed requests are captured
+func (c *RequestCapture) Enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size > 0
+}
+
+// Records returns the captured requests, newest first
+func (c *RequestCapture) Records() []CapturedRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	records := make([]CapturedRequest, len(c.records))
+	for i, record := range c.records {
+		records[len(records)-1-i] = record
+	}
+	return records
+}
+
+// Clear drops the captured requests
+func (c *RequestCapture) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = nil
+}
+
+func (c *RequestCapture) add(record CapturedRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size == 0 {
+		return
+	}
+	c.nextID++
+	record.ID = c.nextID
+	if len(c.records) >= c.size {
+		c.records = c.records[len(c.records)-c.size+1:]
+	}
+	c.records = append(c.records, record)
+}
+
+// captureWriter records the status and the start of a response body
+type captureWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.statusCode = code
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if room := maxCapturedResponse - cw.body.Len(); room > 0 {
+		cw.body.Write(b[:min(room, len(b))])
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends streamed responses progressively
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Handler returns the middleware capturing failed write requests
+func (c *RequestCapture) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWrite(r.Method) || !c.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Keep the start of the body, and hand the whole body to next
+		head, _ := io.ReadAll(io.LimitReader(r.Body, maxCapturedBody+1))
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+
+		start := time.Now()
+		wrapped := &captureWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+		if wrapped.statusCode < http.StatusBadRequest {
+			return
+		}
+
+		record := CapturedRequest{
+			Time:         start.UTC(),
+			RequestID:    tracing.RequestID(r.Context()),
+			User:         capturedUser(r),
+			Method:       r.Method,
+			Path:         r.URL.Path,
+			Query:        r.URL.RawQuery,
+			Headers:      sanitizeHeaders(r.Header),
+			Status:       wrapped.statusCode,
+			Response:     wrapped.body.String(),
+			DurationMsec: float64(time.Since(start)) / float64(time.Millisecond),
+		}
+		switch {
+		case len(head) == 0:
+		case len(head) > maxCapturedBody:
+			record.BodyOmitted = fmt.Sprintf("larger than %d bytes", maxCapturedBody)
+		default:
+			body, err := sanitizeJSON(head)
+			if err != nil {
+				record.BodyOmitted = "not JSON"
+			} else {
+				record.Body = body
+			}
+		}
+		record.Curl = curlCommand(record)
+		c.add(record)
+	})
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// readCloser reads from a reader and closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func sanitizeHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if sensitiveFieldPattern.MatchString(name) {
+			headers[name] = redactedValue
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// sanitizeJSON redacts the fields of a JSON document named like secrets,
+// and every custom value
+func sanitizeJSON(data []byte) (json.RawMessage, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redact(doc))
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			switch {
+			case sensitiveFieldPattern.MatchString(key):
+				v[key] = redactedValue
+			case key == "custom_values":
+				if values, ok := value.(map[string]interface{}); ok {
+					for name := range values {
+						values[name] = redactedValue
+					}
+				}
+			default:
+				v[key] = redact(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
+	return v
+}
+
+// curlCommand returns a command sending a captured request again, with the
+// credentials of whoever runs it
+func curlCommand(record CapturedRequest) string {
+	target := record.Path
+	if record.Query != "" {
+		target += "?" + record.Query
+	}
+	parts := []string{"curl", "-X", record.Method, `-u "$COLA_REGISTRY_SESSION_TOKEN"`, `"$COLA_REGISTRY_URL"` + shellQuote(target)}
+
+	var names []string
+	for name := range record.Headers {
+		if name == "Content-Type" || strings.HasPrefix(name, "If-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, "-H", shellQuote(name+": "+record.Headers[name]))
+	}
+	if record.Body != nil {
+		parts = append(parts, "--data-raw", shellQuote(string(record.Body)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+)
+
+func TestRequestCapture(t *testing.T) {
+	capture := NewRequestCapture(2)
+	handler := capture.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler still reads the whole body
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "fail") {
+			apierrors.WriteError(w, apierrors.ErrCodeInternalError, "Failed to create version", http.StatusInternalServerError, nil)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	send := func(method, body string) {
+		req := httptest.NewRequest(method, "/api/v1/registry/tools/package/deployer/version?dry_run=true", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("alice", "hunter2")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Successful writes and reads are not captured
+	send(http.MethodPost, `{"version":"1.0.0"}`)
+	send(http.MethodGet, `fail`)
+	assert.Empty(t, capture.Records())
+
+	send(http.MethodPost, `{"version":"1.0.0","note":"fail","custom_values":{"owner":"alice"},"nested":[{"api_key":"abc"}]}`)
+	records := capture.Records()
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, uint64(1), record.ID)
+	assert.Equal(t, "alice", record.User)
+	assert.Equal(t, http.MethodPost, record.Method)
+	assert.Equal(t, "/api/v1/registry/tools/package/deployer/version", record.Path)
+	assert.Equal(t, "dry_run=true", record.Query)
+	assert.Equal(t, http.StatusInternalServerError, record.Status)
+	assert.Contains(t, record.Response, "Failed to create version")
+	assert.Equal(t, redactedValue, record.Headers["Authorization"])
+	assert.Equal(t, "application/json", record.Headers["Content-Type"])
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(record.Body, &body))
+	assert.Equal(t, "1.0.0", body["version"])
+	assert.Equal(t, map[string]interface{}{"owner": redactedValue}, body["custom_values"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"api_key": redactedValue}}, body["nested"])
+	assert.NotContains(t, record.Curl, "hunter2")
+	assert.Contains(t, record.Curl, `curl -X POST -u "$COLA_REGISTRY_SESSION_TOKEN" "$COLA_REGISTRY_URL"'/api/v1/registry/tools/package/deployer/version?dry_run=true'`)
+	assert.Contains(t, record.Curl, `-H 'Content-Type: application/json'`)
+
+	// Bodies that are not JSON are not kept; the oldest requests are dropped
+	send(http.MethodPut, `fail: not json`)
+	send(http.MethodDelete, `fail`)
+	records = capture.Records()
+	require.Len(t, records, 2)
+	assert.Equal(t, []uint64{3, 2}, []uint64{records[0].ID, records[1].ID})
+	assert.Equal(t, "not JSON", records[1].BodyOmitted)
+	assert.Nil(t, records[1].Body)
+
+	capture.SetSize(1)
+	assert.Len(t, capture.Records(), 1)
+	capture.SetSize(0)
+	assert.Empty(t, capture.Records())
+	send(http.MethodPost, `fail`)
+	assert.Empty(t, capture.Records())
+	assert.False(t, capture.Enabled())
+}
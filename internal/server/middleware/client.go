@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ClientHeader identifies the client software and version sending a
+// request, e.g. cola-regctl/1.4.0
+const ClientHeader = "X-Cola-Client"
+
+// maxClients bounds the clients counted separately, so made-up headers
+// cannot grow the counts without limit; further clients count as "other"
+const maxClients = 100
+
+// clientPattern matches a product name with an optional version
+var clientPattern = regexp.MustCompile(`^[A-Za-z0-9._+-]{1,64}(/[A-Za-z0-9._+-]{1,64})?$`)
+
+// ClientName returns the client a request comes from: the X-Cola-Client
+// header, or else the first product of the User-Agent (curl/8.5.0,
+// Go-http-client/1.1, ...), or "unknown" when neither is usable
+func ClientName(r *http.Request) string {
+	if client := r.Header.Get(ClientHeader); clientPattern.MatchString(client) {
+		return client
+	}
+	if product, _, _ := strings.Cut(r.UserAgent(), " "); clientPattern.MatchString(product) {
+		return product
+	}
+	return "unknown"
+}
+
+// ClientCounter counts requests per client, as named by ClientName
+type ClientCounter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// NewClientCounter creates a client counter
+func NewClientCounter() *ClientCounter {
+	return &ClientCounter{counts: make(map[string]uint64)}
+}
+
+// Handler returns the middleware counting requests
+func (c *ClientCounter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.count(ClientName(r))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *ClientCounter) count(client string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.counts[client]; !exists && len(c.counts) >= maxClients {
+		client = "other"
+	}
+	c.counts[client]++
+}
+
+// Counts returns the number of requests per client since startup
+func (c *ClientCounter) Counts() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]uint64, len(c.counts))
+	for client, n := range c.counts {
+		counts[client] = n
+	}
+	return counts
+}
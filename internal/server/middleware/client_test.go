@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientName(t *testing.T) {
+	request := func(client, userAgent string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil)
+		if client != "" {
+			req.Header.Set(ClientHeader, client)
+		}
+		req.Header.Set("User-Agent", userAgent)
+		return req
+	}
+
+	assert.Equal(t, "cola-regctl/1.4.0", ClientName(request("cola-regctl/1.4.0", "cola-regctl/1.4.0 (linux; amd64)")))
+	assert.Equal(t, "curl/8.5.0", ClientName(request("", "curl/8.5.0")))
+	assert.Equal(t, "Mozilla/5.0", ClientName(request("not a client", "Mozilla/5.0 (X11; Linux x86_64)")))
+	assert.Equal(t, "unknown", ClientName(request("", "")))
+}
+
+func TestClientCounter(t *testing.T) {
+	counter := NewClientCounter()
+	handler := counter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(client string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil)
+		req.Header.Set(ClientHeader, client)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("cola-regctl/1.4.0")
+	send("cola-regctl/1.4.0")
+	send("cola-regctl/1.3.2")
+	assert.Equal(t, map[string]uint64{"cola-regctl/1.4.0": 2, "cola-regctl/1.3.2": 1}, counter.Counts())
+
+	// Clients beyond the bound are counted together
+	for i := 0; i < maxClients; i++ {
+		send(fmt.Sprintf("tool/%d", i))
+	}
+	counts := counter.Counts()
+	assert.Len(t, counts, maxClients+1)
+	assert.Equal(t, uint64(2), counts["other"])
+	assert.Equal(t, uint64(2), counts["cola-regctl/1.4.0"])
+}
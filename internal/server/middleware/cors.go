@@ -3,27 +3,67 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
-// CORS returns middleware that handles CORS for index.json endpoint only
-func CORS() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Check if this is an index.json endpoint
-			if strings.HasSuffix(r.URL.Path, "/index.json") {
-				// Set CORS headers
-				w.Header().Set("Access-Control-Allow-Origin", "*")
-				w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+// CORS handles CORS for the index.json endpoint only.
+// Allowed origins can be changed at runtime with SetAllowedOrigins.
+type CORS struct {
+	origins atomic.Pointer[[]string]
+}
+
+// NewCORS creates the CORS middleware. "*" allows any origin.
+func NewCORS(allowedOrigins []string) *CORS {
+	c := &CORS{}
+	c.SetAllowedOrigins(allowedOrigins)
+	return c
+}
+
+// SetAllowedOrigins replaces the allowed origins
+func (c *CORS) SetAllowedOrigins(allowedOrigins []string) {
+	origins := append([]string(nil), allowedOrigins...)
+	c.origins.Store(&origins)
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for a request
+// origin, or "" if the origin is not allowed
+func (c *CORS) allowOrigin(origin string) string {
+	for _, allowed := range *c.origins.Load() {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// Handler returns the CORS middleware
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Check if this is an index.json endpoint
+		if strings.HasSuffix(r.URL.Path, "/index.json") {
+			allowOrigin := c.allowOrigin(r.Header.Get("Origin"))
+			if allowOrigin != "*" {
+				w.Header().Add("Vary", "Origin")
+			}
+
+			// Set CORS headers
+			if allowOrigin != "" {
+				w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+				w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
 				w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
+				w.Header().Set("Access-Control-Expose-Headers", "ETag, Content-Range, X-Index-Signature")
+			}
 
-				// Handle OPTIONS preflight
-				if r.Method == http.MethodOptions {
-					w.WriteHeader(http.StatusOK)
-					return
-				}
+			// Handle OPTIONS preflight
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
 			}
+		}
 
-			next.ServeHTTP(w, r)
-		})
-	}
+		next.ServeHTTP(w, r)
+	})
 }
@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -19,20 +19,44 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// Logging returns middleware that logs requests
+// Flush sends streamed responses progressively
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// RequestIDHeader carries the request ID, taken from the request when the
+// client or a proxy set a valid one, and returned in every response
+const RequestIDHeader = "X-Request-ID"
+
+// Logging returns middleware that logs requests under a request ID, also
+// carried by the request context down to storage backend calls
 func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
-			// Generate request ID
-			requestID := uuid.New().String()
+			// Reuse the caller's request ID or generate one
+			requestID := r.Header.Get(RequestIDHeader)
+			if !tracing.ValidRequestID(requestID) {
+				requestID = tracing.NewRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+			r = r.WithContext(tracing.WithRequestID(r.Context(), requestID))
+			client := ClientName(r)
 
 			// Log request start
 			logger.Info("Request received",
 				"request_id", requestID,
 				"method", r.Method,
 				"endpoint", r.URL.Path,
+				"client", client,
 				"remote_addr", r.RemoteAddr)
 
 			// Wrap response writer to capture status code
@@ -52,6 +76,7 @@ func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 				"endpoint", r.URL.Path,
 				"status_code", wrapped.statusCode,
 				"duration_ms", duration.Milliseconds(),
+				"client", client,
 				"remote_addr", r.RemoteAddr,
 			)
 		})
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+func TestLogging_RequestID(t *testing.T) {
+	var seen string
+	handler := Logging(slog.New(slog.NewTextHandler(io.Discard, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = tracing.RequestID(r.Context())
+	}))
+
+	serve := func(requestID string) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		if requestID != "" {
+			req.Header.Set(RequestIDHeader, requestID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+		return seen
+	}
+
+	// A valid ID from the client or a proxy is kept, others are replaced
+	assert.Equal(t, "edge-42", serve("edge-42"))
+	assert.NotEqual(t, "bad id", serve("bad id"))
+	assert.NotEmpty(t, serve(""))
+}
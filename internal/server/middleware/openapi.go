@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+)
+
+// RequestValidator checks requests against the OpenAPI description of the
+// API before they reach the handlers, so the spec and the server cannot
+// silently drift apart.
+type RequestValidator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+	logger  *slog.Logger
+}
+
+// NewRequestValidator loads an OpenAPI document. Operations are matched
+// against absolute request paths: below the path of the first server, or of
+// the operation's own servers when it has some (e.g. /readyz).
+func NewRequestValidator(spec []byte, logger *slog.Logger) (*RequestValidator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
+	}
+	// propertyNames is valid OpenAPI 3.1 but unknown to the 3.0 validator
+	if err := doc.Validate(loader.Context, openapi3.AllowExtraSiblingFields("propertyNames")); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+
+	base, err := serversPath(doc.Servers)
+	if err != nil {
+		return nil, err
+	}
+	paths := openapi3.NewPaths()
+	for path, item := range doc.Paths.Map() {
+		prefix := base
+		if len(item.Servers) > 0 {
+			if prefix, err = serversPath(item.Servers); err != nil {
+				return nil, err
+			}
+			item.Servers = nil
+		}
+		paths.Set(prefix+path, item)
+	}
+	doc.Paths = paths
+	doc.Servers = nil
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI router: %w", err)
+	}
+
+	return &RequestValidator{
+		router: router,
+		options: &openapi3filter.Options{
+			// Credentials are checked by the auth middleware
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+		logger: logger,
+	}, nil
+}
+
+// serversPath returns the path of the first server URL, without trailing slash
+func serversPath(servers openapi3.Servers) (string, error) {
+	if len(servers) == 0 {
+		return "", nil
+	}
+	u, err := url.Parse(servers[0].URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid OpenAPI server URL '%s': %w", servers[0].URL, err)
+	}
+	return strings.TrimSuffix(u.Path, "/"), nil
+}
+
+// Handler returns middleware that answers 400 VALIDATION_ERROR when the
+// path parameters, query or body of a request do not match its operation.
+// Requests matching no operation are left to the router.
+func (v *RequestValidator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, params, err := v.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Handlers decode JSON whatever the content type says (curl -d sends
+		// application/x-www-form-urlencoded), so validate bodies as such
+		if body := route.Operation.RequestBody; body != nil && body.Value != nil && body.Value.Content.Get("application/json") != nil {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Route:      route,
+			Options:    v.options,
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			message := describeRequestError(err)
+			v.logger.Debug("Request rejected by OpenAPI validation",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"error", message)
+			apierrors.WriteError(w, apierrors.ErrCodeValidationError, message, http.StatusBadRequest, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// describeRequestError turns a validation error into a one-line message,
+// without the schema dump kin-openapi appends
+func describeRequestError(err error) string {
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return err.Error()
+	}
+
+	where := "request body"
+	if reqErr.Parameter != nil {
+		where = fmt.Sprintf("%s parameter '%s'", reqErr.Parameter.In, reqErr.Parameter.Name)
+	}
+	var schemaErr *openapi3.SchemaError
+	if errors.As(reqErr.Err, &schemaErr) {
+		if ptr := schemaErr.JSONPointer(); len(ptr) > 0 {
+			where = fmt.Sprintf("%s field '%s'", where, strings.Join(ptr, "."))
+		}
+		return fmt.Sprintf("%s: %s", where, schemaErr.Reason)
+	}
+	if reqErr.Err != nil {
+		return fmt.Sprintf("%s: %v", where, reqErr.Err)
+	}
+	return fmt.Sprintf("%s: %s", where, reqErr.Reason)
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/docs"
+)
+
+func TestRequestValidator(t *testing.T) {
+	validator, err := NewRequestValidator(docs.OpenAPI, slog.Default())
+	require.NoError(t, err)
+
+	var body string
+	handler := validator.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+		message string
+	}{
+		{"valid body", http.MethodPost, "/api/v1/registry/tools/package", `{"name":"hello"}`, http.StatusNoContent, ""},
+		{"invalid body field", http.MethodPost, "/api/v1/registry/tools/package", `{"name":"Hello World"}`, http.StatusBadRequest, "field 'name'"},
+		{"missing body field", http.MethodPost, "/api/v1/registry", `{"description":"no name"}`, http.StatusBadRequest, "request body"},
+		{"malformed body", http.MethodPost, "/api/v1/registry", `{`, http.StatusBadRequest, "request body"},
+		{"invalid query", http.MethodGet, "/api/v1/registry/tools/package/hello/version?sort=bogus", "", http.StatusBadRequest, "query parameter 'sort'"},
+		{"root path", http.MethodGet, "/readyz", "", http.StatusNoContent, ""},
+		{"not in spec", http.MethodGet, "/api/v1/unknown", "", http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body = ""
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+			if tt.status == http.StatusBadRequest {
+				assert.Contains(t, rec.Body.String(), "VALIDATION_ERROR")
+				assert.Contains(t, rec.Body.String(), tt.message)
+			} else {
+				// The handler still gets the whole body
+				assert.Equal(t, tt.body, body)
+			}
+		})
+	}
+}
@@ -1,31 +1,71 @@
 package middleware
 
 import (
+	"math"
+	"net"
 	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
 )
 
-// rateLimiter tracks request rates per IP
-type rateLimiter struct {
-	mu      sync.Mutex
-	clients map[string]*clientLimiter
+// idleTimeout is how long a client's usage is kept without requests. Its
+// bucket is only dropped once full again, so dropping it changes nothing.
+const idleTimeout = 10 * time.Minute
+
+// RateLimiter limits requests per client with a token bucket: each client
+// may send a burst of requests at once, then one request every
+// minute/limit. Clients are authenticated users, identified by Identify,
+// or else client IPs (see clientIP). Anonymous clients share the limit of
+// users unless given their own with SetAnonymousLimit. Limits and trusted
+// proxies can be changed at runtime (used by config reload).
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	burst     int
+	anonLimit int // 0 uses limit
+	anonBurst int
+	trusted   []netip.Prefix // Proxies whose X-Forwarded-For is honored
+	clients   map[string]*clientLimiter
+	limited   atomic.Uint64 // Requests rejected since startup, all clients
 }
 
-// clientLimiter tracks requests for a single client
+// clientLimiter is the token bucket and usage of a single client
 type clientLimiter struct {
-	tokens     int
-	lastRefill time.Time
+	bucket    *rate.Limiter
+	anonymous bool
+	allowed   uint64
+	limited   uint64
+	lastSeen  time.Time
+}
+
+// RateLimitUsage reports a client's usage of the rate limit
+type RateLimitUsage struct {
+	Key      string    `json:"key"`     // user:<name> or ip:<address>
+	Allowed  uint64    `json:"allowed"` // Requests served
+	Limited  uint64    `json:"limited"` // Requests rejected with 429
+	Tokens   float64   `json:"tokens"`  // Requests the client may send right now
+	LastSeen time.Time `json:"last_seen"`
 }
 
-// NewRateLimiter creates a rate limiting middleware
-// limit: requests per minute
-func NewRateLimiter(limit int) func(http.Handler) http.Handler {
-	limiter := &rateLimiter{
+// NewRateLimiter creates a rate limiter
+// limit: requests per minute, zero disables rate limiting
+// burst: requests allowed at once, zero for limit
+func NewRateLimiter(limit, burst int) *RateLimiter {
+	limiter := &RateLimiter{
 		clients: make(map[string]*clientLimiter),
 	}
+	limiter.SetLimit(limit, burst)
 
-	// Cleanup old clients every minute
+	// Cleanup idle clients every minute
 	go func() {
 		ticker := time.NewTicker(time.Minute)
 		defer ticker.Stop()
@@ -34,79 +74,223 @@ func NewRateLimiter(limit int) func(http.Handler) http.Handler {
 		}
 	}()
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			clientIP := getClientIP(r)
+	return limiter
+}
 
-			if !limiter.allow(clientIP, limit) {
-				w.Header().Set("Retry-After", "60")
-				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
-				return
-			}
+// SetLimit changes the number of requests allowed per minute and at once.
+// Existing clients keep the tokens they have left.
+func (rl *RateLimiter) SetLimit(limit, burst int) {
+	if burst <= 0 {
+		burst = limit
+	}
 
-			next.ServeHTTP(w, r)
-		})
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit = limit
+	rl.burst = burst
+	rl.applyLocked()
+}
+
+// SetAnonymousLimit changes the number of requests allowed per minute and
+// at once to clients without credentials; limit zero gives them the limit
+// of authenticated users
+func (rl *RateLimiter) SetAnonymousLimit(limit, burst int) {
+	if burst <= 0 {
+		burst = limit
 	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.anonLimit = limit
+	rl.anonBurst = burst
+	rl.applyLocked()
 }
 
-// allow checks if a request is allowed
-func (rl *rateLimiter) allow(clientIP string, limit int) bool {
+// SetTrustedProxies changes the proxies whose X-Forwarded-For and X-Real-IP
+// headers are honored to find the client IP of anonymous requests. Without
+// any, clients are keyed on the connection's address.
+func (rl *RateLimiter) SetTrustedProxies(proxies []netip.Prefix) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
+	rl.trusted = proxies
+}
 
+// applyLocked updates the buckets of existing clients to their tier's
+// limits; they keep the tokens they have left
+func (rl *RateLimiter) applyLocked() {
 	now := time.Now()
+	for _, client := range rl.clients {
+		limit, burst := rl.tier(client.anonymous)
+		client.bucket.SetLimitAt(now, perMinute(limit))
+		client.bucket.SetBurstAt(now, burst)
+	}
+}
 
-	client, exists := rl.clients[clientIP]
-	if !exists {
-		client = &clientLimiter{
-			tokens:     limit,
-			lastRefill: now,
+// tier returns the limits of authenticated or anonymous clients
+func (rl *RateLimiter) tier(anonymous bool) (limit, burst int) {
+	if anonymous && rl.anonLimit > 0 {
+		return rl.anonLimit, rl.anonBurst
+	}
+	return rl.limit, rl.burst
+}
+
+// Limit returns the number of requests allowed per minute
+func (rl *RateLimiter) Limit() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limit
+}
+
+// Handler returns the rate limiting middleware. Rejected requests get 429
+// with Retry-After set to the seconds until the client's next token.
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, anonymous := rl.clientKey(r)
+		if wait := rl.reserve(key, anonymous); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
 		}
-		rl.clients[clientIP] = client
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reserve takes a token from the client's bucket, and returns zero when
+// the request is allowed or how long until a token is available otherwise
+func (rl *RateLimiter) reserve(key string, anonymous bool) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	limit, burst := rl.tier(anonymous)
+	if limit <= 0 {
+		return 0
 	}
 
-	// Refill tokens based on time elapsed
-	elapsed := now.Sub(client.lastRefill)
-	if elapsed >= time.Minute {
-		// Full refill
-		client.tokens = limit
-		client.lastRefill = now
+	now := time.Now()
+	client, exists := rl.clients[key]
+	if !exists {
+		client = &clientLimiter{bucket: rate.NewLimiter(perMinute(limit), burst), anonymous: anonymous}
+		rl.clients[key] = client
 	}
+	client.lastSeen = now
 
-	// Check if request allowed
-	if client.tokens > 0 {
-		client.tokens--
-		return true
+	reservation := client.bucket.ReserveN(now, 1)
+	if wait := reservation.DelayFrom(now); wait > 0 {
+		// Rejected requests do not consume the token they would wait for
+		reservation.CancelAt(now)
+		client.limited++
+		rl.limited.Add(1)
+		return wait
 	}
+	client.allowed++
+	return 0
+}
 
-	return false
+// Usage returns the usage of the clients seen recently, sorted by key
+func (rl *RateLimiter) Usage() []RateLimitUsage {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	usage := make([]RateLimitUsage, 0, len(rl.clients))
+	for key, client := range rl.clients {
+		usage = append(usage, RateLimitUsage{
+			Key:      key,
+			Allowed:  client.allowed,
+			Limited:  client.limited,
+			Tokens:   math.Floor(client.bucket.TokensAt(now)),
+			LastSeen: client.lastSeen.UTC(),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Key < usage[j].Key })
+	return usage
+}
+
+// Limited returns the number of requests rejected since startup
+func (rl *RateLimiter) Limited() uint64 {
+	return rl.limited.Load()
 }
 
-// cleanup removes old client entries
-func (rl *rateLimiter) cleanup() {
+// cleanup removes idle client entries
+func (rl *RateLimiter) cleanup() {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
 	now := time.Now()
-	for ip, client := range rl.clients {
-		if now.Sub(client.lastRefill) > 2*time.Minute {
-			delete(rl.clients, ip)
+	for key, client := range rl.clients {
+		if now.Sub(client.lastSeen) > idleTimeout && client.bucket.TokensAt(now) >= float64(client.bucket.Burst()) {
+			delete(rl.clients, key)
 		}
 	}
 }
 
-// getClientIP extracts client IP from request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (if behind proxy)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
+// perMinute converts a limit per minute to a token refill rate
+func perMinute(limit int) rate.Limit {
+	return rate.Every(time.Minute / time.Duration(max(limit, 1)))
+}
+
+// clientKey identifies the client a request is counted against: the user
+// authenticated by Identify, or the client IP of an anonymous caller
+func (rl *RateLimiter) clientKey(r *http.Request) (key string, anonymous bool) {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return "user:" + user.Username, false
 	}
+	rl.mu.Lock()
+	trusted := rl.trusted
+	rl.mu.Unlock()
+	return "ip:" + clientIP(r, trusted), true
+}
 
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+// clientIP returns the IP of the client behind a request: the address of
+// the connection, unless it is a trusted proxy. X-Forwarded-For is then
+// walked from the right, each hop appended by the proxy it reached, and the
+// first untrusted hop is the client; hops further left are set by the
+// client and could be forged. X-Real-IP is used when set by a trusted proxy
+// without X-Forwarded-For.
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	// RemoteAddr without the port, which changes with each connection
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remote = host
+	}
+	if !isTrusted(remote, trusted) {
+		return remote
 	}
 
-	// Use RemoteAddr
-	return r.RemoteAddr
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+		return remote
+	}
+	for i := len(hops) - 1; i > 0; i-- {
+		if !isTrusted(hops[i], trusted) {
+			return hops[i]
+		}
+	}
+	return hops[0] // Every hop is a trusted proxy
+}
+
+// isTrusted reports whether ip is in one of the trusted prefixes
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
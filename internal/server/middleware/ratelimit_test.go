@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+)
+
+func TestRateLimiter_Burst(t *testing.T) {
+	// 60/min refills a token every second; three may be spent at once
+	limiter := NewRateLimiter(60, 3)
+	handler := limiter.Handler(okHandler)
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Connections from the same IP share a bucket whatever their port
+	for _, port := range []string{"1001", "1002", "1003"} {
+		assert.Equal(t, http.StatusOK, get("10.0.0.1:"+port).Code)
+	}
+	rec := get("10.0.0.1:1004")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// Other clients have their own bucket
+	assert.Equal(t, http.StatusOK, get("10.0.0.2:1001").Code)
+
+	usage := limiter.Usage()
+	require.Len(t, usage, 2)
+	assert.Equal(t, "ip:10.0.0.1", usage[0].Key)
+	assert.Equal(t, uint64(3), usage[0].Allowed)
+	assert.Equal(t, uint64(1), usage[0].Limited)
+	assert.Equal(t, float64(0), usage[0].Tokens)
+	assert.Equal(t, "ip:10.0.0.2", usage[1].Key)
+	assert.Equal(t, uint64(1), limiter.Limited())
+}
+
+func TestRateLimiter_RetryAfter(t *testing.T) {
+	// A token every 30 seconds: the wait reported is the actual refill time
+	limiter := NewRateLimiter(2, 1)
+	handler := limiter.Handler(okHandler)
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, get().Code)
+	rec := get()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+}
+
+func TestRateLimiter_KeyedByUser(t *testing.T) {
+	limiter := NewRateLimiter(60, 1)
+	handler := Identify(userAuth{})(limiter.Handler(okHandler))
+
+	get := func(remoteAddr, user string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		req.RemoteAddr = remoteAddr
+		if user != "" {
+			req.SetBasicAuth(user, "secret")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A user is limited across addresses, and separately from anonymous
+	// callers sharing an address
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1000", "alice"))
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.2:1000", "alice"))
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1000", "bob"))
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1000", ""))
+
+	// Invalid credentials count against the IP
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:1000", "mallory"))
+
+	keys := make([]string, 0)
+	for _, usage := range limiter.Usage() {
+		keys = append(keys, usage.Key)
+	}
+	assert.Equal(t, []string{"ip:10.0.0.1", "user:alice", "user:bob"}, keys)
+}
+
+func TestRateLimiter_AnonymousTier(t *testing.T) {
+	limiter := NewRateLimiter(60, 3)
+	limiter.SetAnonymousLimit(60, 1)
+	handler := Identify(userAuth{})(limiter.Handler(okHandler))
+
+	get := func(remoteAddr, user string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		req.RemoteAddr = remoteAddr
+		if user != "" {
+			req.SetBasicAuth(user, "secret")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1000", ""))
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:1000", ""))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, get("10.0.0.1:1000", "alice"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:1000", "alice"))
+
+	// Without an anonymous limit, anonymous callers get the users' limit
+	limiter.SetAnonymousLimit(0, 0)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, get("10.0.0.2:1000", ""))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.2:1000", ""))
+}
+
+func TestRateLimiter_TrustedProxies(t *testing.T) {
+	limiter := NewRateLimiter(60, 1)
+	handler := limiter.Handler(okHandler)
+
+	get := func(remoteAddr string, header http.Header) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		req.RemoteAddr = remoteAddr
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	forwarded := func(hops string) http.Header { return http.Header{"X-Forwarded-For": {hops}} }
+
+	// Without trusted proxies, forwarding headers cannot buy a new bucket
+	assert.Equal(t, http.StatusOK, get("203.0.113.1:1000", forwarded("198.51.100.1")))
+	assert.Equal(t, http.StatusTooManyRequests, get("203.0.113.1:1000", forwarded("198.51.100.2")))
+	assert.Equal(t, http.StatusTooManyRequests, get("203.0.113.1:1000", http.Header{"X-Real-Ip": {"198.51.100.3"}}))
+
+	limiter.SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	keys := func() []string {
+		keys := make([]string, 0)
+		for _, usage := range limiter.Usage() {
+			keys = append(keys, usage.Key)
+		}
+		return keys
+	}
+
+	// Behind trusted proxies, the right-most untrusted hop is the client:
+	// hops to its left were sent by the client itself
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1000", forwarded("1.2.3.4, 198.51.100.10, 10.0.0.2")))
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:1000", forwarded("5.6.7.8, 198.51.100.10, 10.0.0.2")))
+	assert.Contains(t, keys(), "ip:198.51.100.10")
+
+	// X-Real-IP when the proxy sets no X-Forwarded-For
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1000", http.Header{"X-Real-Ip": {"198.51.100.11"}}))
+	assert.Contains(t, keys(), "ip:198.51.100.11")
+
+	// Untrusted connections still ignore the headers
+	assert.Equal(t, http.StatusTooManyRequests, get("203.0.113.1:1000", forwarded("198.51.100.12")))
+	assert.NotContains(t, keys(), "ip:198.51.100.12")
+}
+
+// userAuth accepts any basic auth user but mallory
+type userAuth struct{}
+
+func (userAuth) Authenticate(r *http.Request) (*auth.User, error) {
+	username, _, ok := r.BasicAuth()
+	if !ok || username == "mallory" {
+		return nil, errors.New("invalid credentials")
+	}
+	return &auth.User{Username: username}, nil
+}
+
+func (a userAuth) Middleware() func(http.Handler) http.Handler {
+	return RequireUser(a)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestRateLimiter_SetLimit(t *testing.T) {
+	limiter := NewRateLimiter(1, 0)
+	handler := limiter.Handler(okHandler)
+
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, http.StatusTooManyRequests, get())
+
+	// Zero disables rate limiting without a restart
+	limiter.SetLimit(0, 0)
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, http.StatusOK, get())
+}
+
+func TestCORS_SetAllowedOrigins(t *testing.T) {
+	cors := NewCORS([]string{"*"})
+	handler := cors.Handler(okHandler)
+
+	get := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/registry/tools/index.json", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, "*", get("https://a.example.com").Header().Get("Access-Control-Allow-Origin"))
+
+	cors.SetAllowedOrigins([]string{"https://a.example.com"})
+	rec := get("https://a.example.com")
+	assert.Equal(t, "https://a.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+	assert.Empty(t, get("https://b.example.com").Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// latencySamples is the number of recent latencies kept per route, from
+// which percentiles are computed
+const latencySamples = 1024
+
+// maxRoutes bounds the routes counted separately; routes are fixed, this
+// only guards against unexpected patterns. Further routes count as "other".
+const maxRoutes = 200
+
+// UnroutedKey counts requests answered before routing (e.g. rate limited)
+// or matching no route
+const UnroutedKey = "unrouted"
+
+// RouteStats reports the requests and latencies of a route
+type RouteStats struct {
+	Requests     uint64  `json:"requests"`
+	ClientErrors uint64  `json:"client_errors"` // 4xx responses
+	ServerErrors uint64  `json:"server_errors"` // 5xx responses
+	P50Ms        float64 `json:"p50_ms"`        // Over the last 1024 requests
+	P90Ms        float64 `json:"p90_ms"`
+	P99Ms        float64 `json:"p99_ms"`
+}
+
+// routeRecorder accumulates the requests of a route
+type routeRecorder struct {
+	requests     uint64
+	clientErrors uint64
+	serverErrors uint64
+	latencies    []time.Duration // Ring of the last latencySamples
+	next         int
+}
+
+// RouteMetrics counts requests and records their latencies per route, as
+// "METHOD pattern" (GET /api/v1/registry/{name}), and counts responses per
+// status code
+type RouteMetrics struct {
+	mu       sync.Mutex
+	routes   map[string]*routeRecorder
+	statuses map[int]uint64
+}
+
+// NewRouteMetrics creates route metrics
+func NewRouteMetrics() *RouteMetrics {
+	return &RouteMetrics{
+		routes:   make(map[string]*routeRecorder),
+		statuses: make(map[int]uint64),
+	}
+}
+
+// Handler returns the middleware recording requests. It must run on the
+// router, whose routing context names the route once the request is served.
+func (m *RouteMetrics) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+		m.record(routeKey(r), wrapped.statusCode, time.Since(start))
+	})
+}
+
+// routeKey names the route a request was served by
+func routeKey(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return UnroutedKey
+	}
+	pattern := rctx.RoutePattern()
+	if pattern == "" {
+		return UnroutedKey
+	}
+	// Subrouters register their root as "/"
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return r.Method + " " + pattern
+}
+
+func (m *RouteMetrics) record(key string, status int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	route, exists := m.routes[key]
+	if !exists {
+		if len(m.routes) >= maxRoutes {
+			key = "other"
+		}
+		if route = m.routes[key]; route == nil {
+			route = &routeRecorder{}
+			m.routes[key] = route
+		}
+	}
+	route.requests++
+	switch {
+	case status >= 500:
+		route.serverErrors++
+	case status >= 400:
+		route.clientErrors++
+	}
+	if len(route.latencies) < latencySamples {
+		route.latencies = append(route.latencies, latency)
+	} else {
+		route.latencies[route.next] = latency
+		route.next = (route.next + 1) % latencySamples
+	}
+	m.statuses[status]++
+}
+
+// Routes returns the stats of every route served since startup
+func (m *RouteMetrics) Routes() map[string]RouteStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	routes := make(map[string]RouteStats, len(m.routes))
+	for key, route := range m.routes {
+		sorted := slices.Clone(route.latencies)
+		slices.Sort(sorted)
+		routes[key] = RouteStats{
+			Requests:     route.requests,
+			ClientErrors: route.clientErrors,
+			ServerErrors: route.serverErrors,
+			P50Ms:        percentileMs(sorted, 50),
+			P90Ms:        percentileMs(sorted, 90),
+			P99Ms:        percentileMs(sorted, 99),
+		}
+	}
+	return routes
+}
+
+// Statuses returns the number of responses per status code since startup
+func (m *RouteMetrics) Statuses() map[int]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make(map[int]uint64, len(m.statuses))
+	for status, n := range m.statuses {
+		statuses[status] = n
+	}
+	return statuses
+}
+
+// percentileMs returns the nearest-rank percentile of sorted latencies in
+// milliseconds, rounded to the microsecond
+func percentileMs(sorted []time.Duration, percentile int) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(float64(percentile) / 100 * float64(len(sorted))))
+	latency := sorted[max(rank, 1)-1]
+	return math.Round(float64(latency.Microseconds())) / 1000
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMetrics_Percentiles(t *testing.T) {
+	metrics := NewRouteMetrics()
+	for i := 1; i <= 100; i++ {
+		metrics.record("GET /api/v1/registry/{name}", http.StatusOK, time.Duration(i)*time.Millisecond)
+	}
+	metrics.record("GET /api/v1/registry/{name}", http.StatusNotFound, time.Millisecond)
+	metrics.record("GET /api/v1/registry/{name}", http.StatusBadGateway, time.Millisecond)
+
+	stats := metrics.Routes()["GET /api/v1/registry/{name}"]
+	assert.Equal(t, uint64(102), stats.Requests)
+	assert.Equal(t, uint64(1), stats.ClientErrors)
+	assert.Equal(t, uint64(1), stats.ServerErrors)
+	assert.Equal(t, 49.0, stats.P50Ms)
+	assert.Equal(t, 90.0, stats.P90Ms)
+	assert.Equal(t, 99.0, stats.P99Ms)
+	assert.Equal(t, map[int]uint64{200: 100, 404: 1, 502: 1}, metrics.Statuses())
+}
+
+func TestRouteMetrics_KeepsRecentLatencies(t *testing.T) {
+	metrics := NewRouteMetrics()
+	for i := 0; i < latencySamples; i++ {
+		metrics.record("GET /", http.StatusOK, time.Second)
+	}
+	// Older samples are replaced by newer ones
+	for i := 0; i < latencySamples; i++ {
+		metrics.record("GET /", http.StatusOK, time.Millisecond)
+	}
+	stats := metrics.Routes()["GET /"]
+	assert.Equal(t, uint64(2*latencySamples), stats.Requests)
+	assert.Equal(t, 1.0, stats.P99Ms)
+}
+
+func TestRouteMetrics_BoundedCardinality(t *testing.T) {
+	metrics := NewRouteMetrics()
+	for i := 0; i < maxRoutes+3; i++ {
+		metrics.record(fmt.Sprintf("GET /route/%d", i), http.StatusOK, time.Millisecond)
+	}
+	routes := metrics.Routes()
+	assert.Len(t, routes, maxRoutes+1)
+	assert.Equal(t, uint64(3), routes["other"].Requests)
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TrimTrailingSlash routes /api/v1/registry/ like /api/v1/registry, so every
+// route answers with or without a trailing slash (chi only accepts both for
+// routes mounted with Route). The request is rewritten rather than
+// redirected, so clients do not have to replay bodies.
+func TrimTrailingSlash(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) > 1 && strings.HasSuffix(r.URL.Path, "/") {
+			r.URL.Path = trimSlashes(r.URL.Path)
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = trimSlashes(r.URL.RawPath)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trimSlashes removes trailing slashes, keeping the root path
+func trimSlashes(path string) string {
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+	return "/"
+}
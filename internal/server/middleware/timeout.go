@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+)
+
+// timeoutWriter guards the response while the handler runs in its own
+// goroutine. Once the request timed out, handler writes are discarded.
+type timeoutWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	dst := tw.w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	return tw.w.Write(b)
+}
+
+// Flush lets streaming handlers (e.g. sync) keep flushing
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Timeout returns middleware that gives each request a deadline, carried by
+// the request context. If the handler has not started responding when the
+// deadline passes, the client gets 504 REQUEST_TIMEOUT and whatever the
+// handler writes afterwards is discarded. Handlers that already started
+// responding (streams) run to completion. A non-positive timeout disables it.
+func Timeout(timeout time.Duration, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{w: w, header: make(http.Header)}
+			finished := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					finished <- recover()
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+			}()
+
+			select {
+			case p := <-finished:
+				if p != nil {
+					panic(p)
+				}
+				return
+			case <-ctx.Done():
+			}
+
+			tw.mu.Lock()
+			if tw.wroteHeader || ctx.Err() != context.DeadlineExceeded {
+				// Response already under way, or the client went away
+				tw.mu.Unlock()
+				if p := <-finished; p != nil {
+					panic(p)
+				}
+				return
+			}
+			tw.timedOut = true
+			tw.mu.Unlock()
+
+			logger.Warn("Request timed out",
+				"method", r.Method,
+				"endpoint", r.URL.Path,
+				"timeout", timeout.String(),
+				"remote_addr", r.RemoteAddr)
+			apierrors.WriteError(w, apierrors.ErrCodeRequestTimeout, "Request did not complete within "+timeout.String(), http.StatusGatewayTimeout, nil)
+		})
+	}
+}
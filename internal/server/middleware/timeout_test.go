@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	logger := slog.Default()
+
+	t.Run("fast handler", func(t *testing.T) {
+		handler := Timeout(time.Second, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline := r.Context().Deadline()
+			assert.True(t, hasDeadline)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/registry", nil))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `{}`, rec.Body.String())
+	})
+
+	t.Run("slow handler", func(t *testing.T) {
+		cancelled := make(chan error, 1)
+		handler := Timeout(20*time.Millisecond, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			cancelled <- r.Context().Err()
+			w.Header().Set("X-Late", "1")
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil))
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Contains(t, rec.Body.String(), "REQUEST_TIMEOUT")
+		assert.Equal(t, context.DeadlineExceeded, <-cancelled)
+		assert.Empty(t, rec.Header().Get("X-Late"))
+	})
+
+	t.Run("stream already started", func(t *testing.T) {
+		handler := Timeout(20*time.Millisecond, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("a"))
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("b"))
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/sync", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "ab", rec.Body.String())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		handler := Timeout(0, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline := r.Context().Deadline()
+			assert.False(t, hasDeadline)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
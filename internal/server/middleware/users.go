@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+)
+
+// maxUsers bounds the users counted separately; further users count as
+// "other". Only authenticated users take a slot, so failed credentials
+// cannot crowd out the real ones.
+const maxUsers = 100
+
+// Labels of the requests not counted against a user
+const (
+	labelAnonymous       = "anonymous"       // No credentials
+	labelUnauthenticated = "unauthenticated" // Credentials that failed, whatever username they claim
+)
+
+// UserStats counts the requests of a user
+type UserStats struct {
+	Requests     uint64 `json:"requests"`
+	AuthFailures uint64 `json:"auth_failures"` // 401 and 403 responses
+	RateLimited  uint64 `json:"rate_limited"`  // 429 responses
+}
+
+// UserCounter counts requests, authentication failures and rate limit
+// rejections per user, so a client overloading the API can be told apart.
+// It must run after Identify.
+type UserCounter struct {
+	mu    sync.Mutex
+	users map[string]*UserStats
+}
+
+// NewUserCounter creates a user counter
+func NewUserCounter() *UserCounter {
+	return &UserCounter{users: make(map[string]*UserStats)}
+}
+
+// UserLabel returns the user a request is counted against: the user
+// authenticated by Identify, "unauthenticated" when credentials were sent
+// but failed, or "anonymous". Claimed usernames are not trusted, so callers
+// cannot make up labels.
+func UserLabel(r *http.Request) string {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return user.Username
+	}
+	if r.Header.Get("Authorization") != "" {
+		return labelUnauthenticated
+	}
+	return labelAnonymous
+}
+
+// Handler returns the middleware counting requests by the status of their
+// response
+func (c *UserCounter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := UserLabel(r)
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+		c.count(user, wrapped.statusCode)
+	})
+}
+
+func (c *UserCounter) count(user string, status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats, exists := c.users[user]
+	if !exists {
+		if user != labelAnonymous && user != labelUnauthenticated && c.slots() >= maxUsers {
+			user = "other"
+		}
+		if stats = c.users[user]; stats == nil {
+			stats = &UserStats{}
+			c.users[user] = stats
+		}
+	}
+	stats.Requests++
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		stats.AuthFailures++
+	case http.StatusTooManyRequests:
+		stats.RateLimited++
+	}
+}
+
+// slots returns the users counted separately, leaving out the fixed labels;
+// called with the lock held
+func (c *UserCounter) slots() int {
+	slots := len(c.users)
+	for _, label := range []string{labelAnonymous, labelUnauthenticated, "other"} {
+		if _, ok := c.users[label]; ok {
+			slots--
+		}
+	}
+	return slots
+}
+
+// Stats returns the counts per user since startup
+func (c *UserCounter) Stats() map[string]UserStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make(map[string]UserStats, len(c.users))
+	for user, s := range c.users {
+		stats[user] = *s
+	}
+	return stats
+}
+
+// AuthFailures returns the authentication failures of all users
+func (c *UserCounter) AuthFailures() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total uint64
+	for _, s := range c.users {
+		total += s.AuthFailures
+	}
+	return total
+}
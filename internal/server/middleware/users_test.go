@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserCounter(t *testing.T) {
+	counter := NewUserCounter()
+	limiter := NewRateLimiter(60, 2)
+	authenticator := userAuth{}
+	handler := Identify(authenticator)(counter.Handler(limiter.Handler(RequireUser(authenticator)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))))
+
+	send := func(username string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		if username != "" {
+			req.SetBasicAuth(username, "secret")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("alice"))
+	assert.Equal(t, http.StatusOK, send("alice"))
+	assert.Equal(t, http.StatusTooManyRequests, send("alice"))
+	// Failed credentials are not counted against the username they claim
+	assert.Equal(t, http.StatusUnauthorized, send("mallory"))
+	assert.Equal(t, http.StatusUnauthorized, send(""))
+
+	assert.Equal(t, map[string]UserStats{
+		"alice":           {Requests: 3, RateLimited: 1},
+		"unauthenticated": {Requests: 1, AuthFailures: 1},
+		"anonymous":       {Requests: 1, AuthFailures: 1},
+	}, counter.Stats())
+	assert.Equal(t, uint64(2), counter.AuthFailures())
+}
+
+func TestUserCounter_BoundedCardinality(t *testing.T) {
+	counter := NewUserCounter()
+	for i := 0; i < maxUsers+5; i++ {
+		counter.count(fmt.Sprintf("user%d", i), http.StatusOK)
+	}
+	counter.count("user0", http.StatusUnauthorized)
+
+	stats := counter.Stats()
+	assert.Len(t, stats, maxUsers+1)
+	assert.Equal(t, UserStats{Requests: 5}, stats["other"])
+	assert.Equal(t, UserStats{Requests: 2, AuthFailures: 1}, stats["user0"])
+
+	// Requests without a user keep their own labels once the slots are taken
+	counter.count(labelUnauthenticated, http.StatusUnauthorized)
+	counter.count(labelAnonymous, http.StatusOK)
+	stats = counter.Stats()
+	assert.Equal(t, UserStats{Requests: 1, AuthFailures: 1}, stats["unauthenticated"])
+	assert.Equal(t, UserStats{Requests: 1}, stats["anonymous"])
+	assert.Equal(t, UserStats{Requests: 5}, stats["other"])
+}
+
+func TestUserCounter_FailedLoginsTakeNoSlots(t *testing.T) {
+	counter := NewUserCounter()
+	handler := Identify(userAuth{})(counter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	// mallory is refused by userAuth; whatever the claimed username, failed
+	// logins share one label
+	for i := 0; i < maxUsers+5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil)
+		req.SetBasicAuth("mallory", fmt.Sprintf("guess%d", i))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/registry", nil)
+	req.SetBasicAuth("alice", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	stats := counter.Stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, uint64(maxUsers+5), stats["unauthenticated"].Requests)
+	assert.Equal(t, uint64(1), stats["alice"].Requests)
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// VanityHosts serves a registry's index at the root of a dedicated hostname
+// (e.g. tools.example.com/index.json), routed by the Host header.
+// The mapping can be changed at runtime with SetHosts.
+type VanityHosts struct {
+	hosts atomic.Pointer[map[string]string]
+}
+
+// NewVanityHosts creates the vanity host middleware from a map of hostnames
+// to registry names. An empty map serves nothing extra.
+func NewVanityHosts(hosts map[string]string) *VanityHosts {
+	v := &VanityHosts{}
+	v.SetHosts(hosts)
+	return v
+}
+
+// SetHosts replaces the hostname to registry mapping. Hostnames are matched
+// case-insensitively and without port.
+func (v *VanityHosts) SetHosts(hosts map[string]string) {
+	normalized := make(map[string]string, len(hosts))
+	for host, registry := range hosts {
+		normalized[strings.ToLower(host)] = registry
+	}
+	v.hosts.Store(&normalized)
+}
+
+// registryFor returns the registry mapped to the request's host, if any
+func (v *VanityHosts) registryFor(r *http.Request) (string, bool) {
+	hosts := *v.hosts.Load()
+	if len(hosts) == 0 {
+		return "", false
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	registry, ok := hosts[strings.ToLower(host)]
+	return registry, ok
+}
+
+// Handler returns middleware rewriting /index.json on a mapped hostname to
+// the registry's index route below basePath. It must run before
+// StripBasePath, since vanity hostnames are served at their root; every
+// other request passes through unchanged.
+func (v *VanityHosts) Handler(basePath string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/index.json" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			registry, ok := v.registryFor(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			u := *r.URL
+			u.Path = basePath + "/api/v1/registry/" + registry + "/index.json"
+			u.RawPath = ""
+			r2 := r.Clone(r.Context())
+			r2.URL = &u
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
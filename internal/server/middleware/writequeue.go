@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+)
+
+// WriteQueue bounds the write requests in flight. Storage applies writes
+// one at a time, so writes beyond the first wait for it; once the bound is
+// reached, further writes are rejected right away with 503 and a
+// Retry-After estimated from recent write latencies, rather than waiting
+// until they time out. The bound can be changed at runtime with SetMax
+// (used by config reload).
+type WriteQueue struct {
+	max      atomic.Int64
+	pending  atomic.Int64
+	rejected atomic.Uint64
+	latency  atomic.Int64 // Moving average of write latencies, in nanoseconds
+}
+
+// WriteQueueStats reports the state of the write queue
+type WriteQueueStats struct {
+	Pending        int64   `json:"pending"`          // Writes running or waiting for storage
+	MaxPending     int64   `json:"max_pending"`      // 0 when unbounded
+	Rejected       uint64  `json:"rejected"`         // Writes rejected with 503 since startup
+	AvgLatencyMsec float64 `json:"avg_latency_msec"` // Recent write latency, waiting included
+}
+
+// NewWriteQueue creates a write queue
+// max: write requests in flight at most, zero for no bound
+func NewWriteQueue(max int) *WriteQueue {
+	queue := &WriteQueue{}
+	queue.SetMax(max)
+	return queue
+}
+
+// SetMax changes the number of write requests allowed in flight. Writes
+// already admitted are not affected.
+func (q *WriteQueue) SetMax(max int) {
+	q.max.Store(int64(max))
+}
+
+// Handler returns the middleware admitting write requests to the queue
+func (q *WriteQueue) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		max := q.max.Load()
+		if pending := q.pending.Add(1); max > 0 && pending > max {
+			q.pending.Add(-1)
+			q.rejected.Add(1)
+			w.Header().Set("Retry-After", strconv.Itoa(q.retryAfter()))
+			apierrors.WriteError(w, apierrors.ErrCodeWriteQueueFull,
+				"Too many writes in progress, retry later", http.StatusServiceUnavailable, nil)
+			return
+		}
+		defer q.pending.Add(-1)
+
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		q.observe(time.Since(start))
+	})
+}
+
+// observe folds a write latency into the moving average
+func (q *WriteQueue) observe(latency time.Duration) {
+	for {
+		old := q.latency.Load()
+		updated := int64(latency)
+		if old > 0 {
+			updated = old + (int64(latency)-old)/8
+		}
+		if q.latency.CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
+
+// retryAfter estimates the seconds until the queue has room: a write
+// admitted to a full queue recently took the average latency to complete
+func (q *WriteQueue) retryAfter() int {
+	return max(1, int(math.Ceil(time.Duration(q.latency.Load()).Seconds())))
+}
+
+// Stats returns the current state of the queue
+func (q *WriteQueue) Stats() WriteQueueStats {
+	return WriteQueueStats{
+		Pending:        q.pending.Load(),
+		MaxPending:     q.max.Load(),
+		Rejected:       q.rejected.Load(),
+		AvgLatencyMsec: float64(q.latency.Load()) / float64(time.Millisecond),
+	}
+}
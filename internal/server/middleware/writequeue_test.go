@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
+)
+
+func TestWriteQueue_Backpressure(t *testing.T) {
+	queue := NewWriteQueue(1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := queue.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	post := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/registry", nil))
+		return rec
+	}
+	done := make(chan *httptest.ResponseRecorder)
+	admit := func() {
+		go func() { done <- post() }()
+		<-started
+	}
+	finish := func() int {
+		release <- struct{}{}
+		return (<-done).Code
+	}
+
+	// The first write holds the only place in the queue
+	admit()
+	assert.Equal(t, int64(1), queue.Stats().Pending)
+
+	rec := post()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	var body apierrors.ErrorResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, apierrors.ErrCodeWriteQueueFull, body.Error.Code)
+
+	assert.Equal(t, http.StatusCreated, finish())
+	stats := queue.Stats()
+	assert.Equal(t, int64(0), stats.Pending)
+	assert.Equal(t, int64(1), stats.MaxPending)
+	assert.Equal(t, uint64(1), stats.Rejected)
+
+	// Zero lifts the bound
+	queue.SetMax(0)
+	admit()
+	admit()
+	assert.Equal(t, int64(2), queue.Stats().Pending)
+	assert.Equal(t, http.StatusCreated, finish())
+	assert.Equal(t, http.StatusCreated, finish())
+}
+
+func TestWriteQueue_RetryAfter(t *testing.T) {
+	queue := NewWriteQueue(1)
+	queue.observe(2500 * time.Millisecond)
+	assert.Equal(t, 3, queue.retryAfter())
+}
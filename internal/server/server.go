@@ -2,16 +2,24 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/criteo/command-launcher-registry/internal/apierrors"
 	"github.com/criteo/command-launcher-registry/internal/auth"
 	"github.com/criteo/command-launcher-registry/internal/config"
 	"github.com/criteo/command-launcher-registry/internal/server/middleware"
@@ -25,26 +33,93 @@ type HandlerSet struct {
 	Health       http.HandlerFunc
 	Metrics      http.HandlerFunc
 	Whoami       http.HandlerFunc
+	ServerInfo   http.HandlerFunc
 
 	// Registry handlers
 	ListRegistries http.HandlerFunc
 	CreateRegistry http.HandlerFunc
 	GetRegistry    http.HandlerFunc
 	UpdateRegistry http.HandlerFunc
+	PatchRegistry  http.HandlerFunc
 	DeleteRegistry http.HandlerFunc
+	CloneRegistry  http.HandlerFunc
+
+	// Compact registry overview for launchers and portals
+	RegistryDashboard http.HandlerFunc
+
+	// Lockfile of exact versions for fleet rollouts
+	LockRegistry http.HandlerFunc
+
+	// Registry-scoped API keys for CI publishing
+	ListAPIKeys  http.HandlerFunc
+	CreateAPIKey http.HandlerFunc
+	DeleteAPIKey http.HandlerFunc
+
+	// Version URLs found unreachable by the link checker
+	BrokenLinks http.HandlerFunc
+
+	// Scripts configuring Command Launcher to use a registry
+	InstallShell      http.HandlerFunc
+	InstallPowerShell http.HandlerFunc
 
 	// Package handlers
 	ListPackages  http.HandlerFunc
 	CreatePackage http.HandlerFunc
 	GetPackage    http.HandlerFunc
 	UpdatePackage http.HandlerFunc
+	PatchPackage  http.HandlerFunc
 	DeletePackage http.HandlerFunc
 
+	// Change history of a package
+	PackageHistory http.HandlerFunc
+
+	// Metadata change applied to many packages at once
+	BatchUpdatePackages http.HandlerFunc
+
 	// Version handlers
 	ListVersions  http.HandlerFunc
 	CreateVersion http.HandlerFunc
 	GetVersion    http.HandlerFunc
 	DeleteVersion http.HandlerFunc
+	CancelVersion http.HandlerFunc
+
+	// Version utilities
+	CompareVersions http.HandlerFunc
+
+	// Index signing public keys
+	JWKS http.HandlerFunc
+
+	// JSON Schemas of the API payloads
+	ListSchemas http.HandlerFunc
+	GetSchema   http.HandlerFunc
+
+	// Differential sync for replicas
+	Sync http.HandlerFunc
+
+	// Packages the authenticated user is responsible for
+	MyPackages http.HandlerFunc
+
+	// Names colliding across registries
+	PackageConflicts http.HandlerFunc
+
+	// Read-only GraphQL view of the catalog
+	GraphQL http.HandlerFunc
+
+	// Administration
+	AdminReload         http.HandlerFunc
+	AdminRestore        http.HandlerFunc // Replaces the data with a backup
+	AdminVersions       http.HandlerFunc // Versions of the data the storage keeps
+	AdminRollback       http.HandlerFunc // Replaces the data with one of those versions
+	AdminQuarantine     http.HandlerFunc // Records set aside while loading the data
+	AdminVerification   http.HandlerFunc // Archives whose checksum no longer matches
+	AdminCredentials    http.HandlerFunc // Storage token in use
+	AdminReauthenticate http.HandlerFunc // Re-authenticates to storage, primary token first
+	AdminCaptured       http.HandlerFunc // Failed write requests, for debugging
+	AdminClearCaptured  http.HandlerFunc
+
+	// Bundled web UI and the API description it renders (standalone mode)
+	UI      http.HandlerFunc
+	OpenAPI http.HandlerFunc
 }
 
 // Server represents the HTTP server
@@ -53,61 +128,257 @@ type Server struct {
 	logger        *slog.Logger
 	store         storage.Store
 	authenticator auth.Authenticator
-	httpServer    *http.Server
+	httpServers   []*http.Server // One per listen address
 	handlers      HandlerSet
+	shutdownHooks []func()
+	reload        func() error
+	rateLimiter   *middleware.RateLimiter
+	writeQueue    *middleware.WriteQueue
+	clients       *middleware.ClientCounter
+	users         *middleware.UserCounter
+	capture       *middleware.RequestCapture
+	routes        *middleware.RouteMetrics
+	cors          *middleware.CORS
+	vanityHosts   *middleware.VanityHosts
+	validator     *middleware.RequestValidator // nil disables request validation
+	tlsConfig     *tls.Config                  // nil serves plain HTTP
+	serverErr     chan error
+	routers       atomic.Pointer[map[string]*chi.Mux] // By listener profile; nil while storage is loading
+	degraded      atomic.Bool                         // serving the storage cache read-only
+	draining      atomic.Bool                         // /readyz fails ahead of shutdown
+	drainOnce     sync.Once
+	drained       chan struct{} // Closed once the drain duration has passed
+	inFlight      atomic.Int64  // Requests being served
 }
 
-// NewServer creates a new server instance
-func NewServer(cfg *config.Config, logger *slog.Logger, store storage.Store, authenticator auth.Authenticator) *Server {
+// NewServer creates a new server instance. The store is set with SetStore
+// once loaded, so the server can listen (and report not ready) meanwhile.
+func NewServer(cfg *config.Config, logger *slog.Logger, authenticator auth.Authenticator) *Server {
 	return &Server{
 		config:        cfg,
 		logger:        logger,
-		store:         store,
 		authenticator: authenticator,
+		rateLimiter:   newRateLimiter(cfg),
+		writeQueue:    middleware.NewWriteQueue(cfg.Server.MaxPendingWrites),
+		clients:       middleware.NewClientCounter(),
+		users:         middleware.NewUserCounter(),
+		capture:       middleware.NewRequestCapture(cfg.Server.CaptureFailedRequests),
+		routes:        middleware.NewRouteMetrics(),
+		cors:          middleware.NewCORS(cfg.Server.CORSOrigins),
+		vanityHosts:   middleware.NewVanityHosts(vanityHosts(cfg)),
+		drained:       make(chan struct{}),
 	}
 }
 
-// Start starts the HTTP server
-func (s *Server) Start() error {
-	// Create router
-	router := s.setupRouter()
-
-	// Create HTTP server
-	addr := fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port)
-	s.httpServer = &http.Server{
-		Addr:         addr,
-		Handler:      router,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 120 * time.Second, // Must be longer than OCI push timeout (60s)
-		IdleTimeout:  120 * time.Second,
+// newRateLimiter creates the rate limiter of a configuration, with its
+// anonymous tier and trusted proxies
+func newRateLimiter(cfg *config.Config) *middleware.RateLimiter {
+	limiter := middleware.NewRateLimiter(cfg.Server.RateLimit, cfg.Server.RateLimitBurst)
+	limiter.SetAnonymousLimit(cfg.Server.AnonymousRateLimit, cfg.Server.AnonymousRateLimitBurst)
+	limiter.SetTrustedProxies(trustedProxies(cfg))
+	return limiter
+}
+
+// ApplyConfig updates the HTTP settings that can change without a restart
+// (rate limits and trusted proxies, write queue bound, CORS origins, vanity
+// hosts and failed request capture). Other fields of cfg are ignored.
+func (s *Server) ApplyConfig(cfg *config.Config) {
+	s.rateLimiter.SetLimit(cfg.Server.RateLimit, cfg.Server.RateLimitBurst)
+	s.rateLimiter.SetAnonymousLimit(cfg.Server.AnonymousRateLimit, cfg.Server.AnonymousRateLimitBurst)
+	s.rateLimiter.SetTrustedProxies(trustedProxies(cfg))
+	s.writeQueue.SetMax(cfg.Server.MaxPendingWrites)
+	s.cors.SetAllowedOrigins(cfg.Server.CORSOrigins)
+	s.vanityHosts.SetHosts(vanityHosts(cfg))
+	s.capture.SetSize(cfg.Server.CaptureFailedRequests)
+}
+
+// vanityHosts returns the hostname to registry mapping of a validated
+// configuration
+func vanityHosts(cfg *config.Config) map[string]string {
+	hosts, _ := config.ParseVanityHosts(cfg.Server.VanityHosts)
+	return hosts
+}
+
+// trustedProxies returns the trusted proxies of a validated configuration
+func trustedProxies(cfg *config.Config) []netip.Prefix {
+	proxies, _ := config.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	return proxies
+}
+
+// RateLimiter returns the server's rate limiter, for metrics
+func (s *Server) RateLimiter() *middleware.RateLimiter {
+	return s.rateLimiter
+}
+
+// WriteQueue returns the server's write queue, for metrics
+func (s *Server) WriteQueue() *middleware.WriteQueue {
+	return s.writeQueue
+}
+
+// ClientCounter returns the server's per-client request counts, for metrics
+func (s *Server) ClientCounter() *middleware.ClientCounter {
+	return s.clients
+}
+
+// UserCounter returns the server's per-user request counts, for metrics
+func (s *Server) UserCounter() *middleware.UserCounter {
+	return s.users
+}
+
+// RequestCapture returns the server's failed write requests, for the admin
+// API
+func (s *Server) RequestCapture() *middleware.RequestCapture {
+	return s.capture
+}
+
+// RouteMetrics returns the server's per-route request stats, for metrics
+func (s *Server) RouteMetrics() *middleware.RouteMetrics {
+	return s.routes
+}
+
+// OnReload registers the function run when the server receives SIGHUP
+func (s *Server) OnReload(fn func() error) {
+	s.reload = fn
+}
+
+// SetStore sets the store, closed on shutdown (called once storage is loaded)
+func (s *Server) SetStore(store storage.Store) {
+	s.store = store
+}
+
+// SetDegraded marks the server as serving cached data read-only because
+// the storage backend was unavailable at startup; /readyz reports it
+func (s *Server) SetDegraded(degraded bool) {
+	s.degraded.Store(degraded)
+}
+
+// Degraded reports whether the server serves cached data read-only
+func (s *Server) Degraded() bool {
+	return s.degraded.Load()
+}
+
+// Listen binds the server addresses and starts serving before storage is
+// loaded. Until Start installs the routes, /readyz and every other request
+// get 503 so orchestrators keep traffic away. Start listens if needed.
+func (s *Server) Listen() error {
+	addresses := s.config.Server.ListenAddresses()
+
+	// Bind every address before serving any, so a failure leaves none open
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		listener, err := net.Listen(listenNetwork(address.Address), address.Address)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("server error: %w", err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	s.serverErr = make(chan error, len(addresses))
+	for i, address := range addresses {
+		httpServer := &http.Server{
+			Addr:         address.Address,
+			Handler:      s.handler(address.Profile),
+			TLSConfig:    s.tlsConfig,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 120 * time.Second, // Must be longer than OCI push timeout (60s)
+			IdleTimeout:  120 * time.Second,
+		}
+		s.httpServers = append(s.httpServers, httpServer)
+
+		// Log server start
+		s.logger.Info("Starting server",
+			"address", address.Address,
+			"profile", address.Profile,
+			"tls", s.tlsConfig != nil,
+			"storage_uri", s.config.Storage.URI,
+			"auth_type", s.config.Auth.Type)
+
+		// Serve in goroutine
+		listener := listeners[i]
+		go func() {
+			var err error
+			if s.tlsConfig != nil {
+				// Certificates come from TLSConfig
+				err = httpServer.ServeTLS(listener, "", "")
+			} else {
+				err = httpServer.Serve(listener)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				s.serverErr <- err
+			}
+		}()
+	}
+	return nil
+}
+
+// listenNetwork restricts IP literals to their family, so 0.0.0.0:8080 and
+// [::]:8080 can both be listed without the IPv6 socket taking IPv4 traffic
+func listenNetwork(address string) string {
+	host, _, _ := net.SplitHostPort(address)
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
 	}
+}
 
-	// Log server start
-	s.logger.Info("Starting server",
-		"host", s.config.Server.Host,
-		"port", s.config.Server.Port,
-		"storage_uri", s.config.Storage.URI,
-		"auth_type", s.config.Auth.Type)
+// installRouters builds the router of each listener profile, which makes
+// the server ready
+func (s *Server) installRouters() {
+	routers := make(map[string]*chi.Mux)
+	for _, address := range s.config.Server.ListenAddresses() {
+		if routers[address.Profile] == nil {
+			routers[address.Profile] = s.setupRouter(address.Profile)
+		}
+	}
+	s.routers.Store(&routers)
+}
 
-	// Start server in goroutine
-	serverErr := make(chan error, 1)
-	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverErr <- err
+// Start installs the routes, which makes the server ready, and blocks until
+// shutdown
+func (s *Server) Start() error {
+	if s.httpServers == nil {
+		if err := s.Listen(); err != nil {
+			return err
 		}
-	}()
+	}
+	s.installRouters()
+	serverErr := s.serverErr
 
 	// Setup graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP reloads configuration in place; connections are not interrupted
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
 	// Wait for shutdown signal or server error
-	select {
-	case err := <-serverErr:
-		return fmt.Errorf("server error: %w", err)
-	case sig := <-quit:
-		s.logger.Info("Shutdown signal received", "signal", sig.String())
-		return s.Shutdown()
+	for {
+		select {
+		case err := <-serverErr:
+			return fmt.Errorf("server error: %w", err)
+		case sig := <-quit:
+			s.logger.Info("Shutdown signal received", "signal", sig.String())
+			return s.Shutdown()
+		case <-hup:
+			if s.reload == nil {
+				s.logger.Warn("Reload signal received but reload is not configured")
+				continue
+			}
+			s.logger.Info("Reload signal received", "signal", syscall.SIGHUP.String())
+			if err := s.reload(); err != nil {
+				s.logger.Error("Configuration reload failed, keeping current configuration", "error", err)
+			}
+		}
 	}
 }
 
@@ -115,17 +386,32 @@ func (s *Server) Start() error {
 func (s *Server) Shutdown() error {
 	s.logger.Info("Initiating graceful shutdown")
 
-	// Create shutdown context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Keep serving until load balancers stop routing to the server; a
+	// preStop hook may already have waited
+	s.Drain()
+
+	// In-flight requests get server.shutdown_timeout to complete
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Server.ShutdownTimeout)
 	defer cancel()
 
-	// Shutdown HTTP server
-	if err := s.httpServer.Shutdown(ctx); err != nil {
-		s.logger.Error("Server shutdown failed", "error", err)
-		return err
+	// Shutdown HTTP servers
+	s.logger.Info("Stopping listeners", "in_flight", s.inFlight.Load())
+	for _, httpServer := range s.httpServers {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			s.logger.Error("Server shutdown failed", "error", err, "address", httpServer.Addr, "in_flight", s.inFlight.Load())
+			return err
+		}
+	}
+
+	// Stop background work before the store goes away
+	for _, hook := range s.shutdownHooks {
+		hook()
 	}
 
 	// Close storage
+	if s.store == nil {
+		return nil
+	}
 	if err := s.store.Close(); err != nil {
 		s.logger.Error("Storage close failed", "error", err)
 		return err
@@ -135,23 +421,99 @@ func (s *Server) Shutdown() error {
 	return nil
 }
 
-// setupRouter configures the HTTP router with middleware and routes
-func (s *Server) setupRouter() *chi.Mux {
+// setupRouter configures the HTTP router of a listener profile with
+// middleware and routes
+func (s *Server) setupRouter(profile string) *chi.Mux {
 	router := chi.NewRouter()
 
+	// Public listeners only serve reads; internal ones are not rate limited
+	writes := profile != config.ListenerPublic
+	rateLimited := profile != config.ListenerInternal
+
 	// Global middleware (applied to all routes)
+	router.Use(middleware.TrimTrailingSlash) // Same routes with or without a trailing slash
 	router.Use(middleware.Logging(s.logger))
-	router.Use(middleware.NewRateLimiter(100)) // 100 req/min per IP
-	router.Use(middleware.CORS())
+	router.Use(s.routes.Handler)  // Requests and latencies per route
+	router.Use(s.clients.Handler) // Requests per X-Cola-Client or User-Agent
+	router.Use(middleware.Identify(s.authenticator))
+	router.Use(s.users.Handler) // Requests, auth failures and 429s per user
+	router.Use(middleware.RestrictAPIKeys)
+	if writes {
+		router.Use(s.capture.Handler) // Last server.capture_failed_requests failed writes
+	}
+	if rateLimited {
+		router.Use(s.rateLimiter.Handler) // server.rate_limit req/min per user, server.anonymous_rate_limit per IP
+	}
+	router.Use(s.cors.Handler)
+	router.Use(middleware.Timeout(s.config.Server.RequestTimeout, s.logger))
+	if s.validator != nil {
+		router.Use(s.validator.Handler) // Requests must match the OpenAPI spec
+	}
+
+	// Cache policies per route group (index.json has its own policy)
+	cache := s.config.Cache
+	indexCache := middleware.CacheControl(cache.IndexMaxAge, false)
+	registryCache := middleware.CacheControl(cache.RegistryMaxAge, false)
+	packageCache := middleware.CacheControl(cache.PackageMaxAge, false)
+	versionCache := middleware.CacheControl(cache.VersionMaxAge, false)
+
+	// Read routes: anonymous callers are served when server.anonymous_read
+	// or the registry's own setting allows it, and only registry indexes
+	// with server.anonymous_access index. Callers with credentials are
+	// identified, so admins see sensitive custom values.
+	indexReadable := middleware.AnonymousRead(s.authenticator, s.allowsAnonymousRead)
+	readable := indexReadable
+	if s.config.Server.AnonymousAccess == config.AnonymousAccessIndex {
+		readable = middleware.AnonymousRead(s.authenticator, func(*http.Request) bool { return false })
+	}
+
+	// Write routes: authenticated, then admitted to the bounded write queue
+	writable := []func(http.Handler) http.Handler{middleware.RequireAuth(s.authenticator), s.writeQueue.Handler}
+
+	// Readiness probe: the router only exists once storage is loaded.
+	// Degraded servers stay ready, they still serve reads.
+	// Draining servers fail it while still serving requests.
+	router.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case s.draining.Load():
+			s.writeReadiness(w, http.StatusServiceUnavailable, "draining")
+		case s.degraded.Load():
+			s.writeReadiness(w, http.StatusOK, "degraded")
+		default:
+			s.writeReadiness(w, http.StatusOK, "ready")
+		}
+	})
+	router.Get("/livez", func(w http.ResponseWriter, r *http.Request) {
+		s.writeReadiness(w, http.StatusOK, "alive")
+	})
+	// Pre-stop hook: starts draining, which cannot be undone, so it is
+	// only served on internal listeners, where callers are trusted
+	if profile == config.ListenerInternal {
+		router.Post("/prestop", s.preStop)
+	}
+
+	// Web UI and API description, outside /api/v1 (standalone mode)
+	if s.handlers.UI != nil {
+		router.Get("/ui", s.handlers.UI)
+	}
+	if s.handlers.OpenAPI != nil {
+		router.Get("/docs/openapi.json", s.handlers.OpenAPI)
+	}
 
 	// API v1 routes
 	router.Route("/api/v1", func(r chi.Router) {
-		// Health and metrics endpoints (no auth required)
+		// Health and metrics endpoints (no auth required; admins also get
+		// per-client rate limit usage)
 		if s.handlers.Health != nil {
 			r.Get("/health", s.handlers.Health)
 		}
 		if s.handlers.Metrics != nil {
-			r.Get("/metrics", s.handlers.Metrics)
+			r.With(middleware.OptionalAuth(s.authenticator)).Get("/metrics", s.handlers.Metrics)
+		}
+
+		// Server version and expected client versions (no auth required)
+		if s.handlers.ServerInfo != nil {
+			r.Get("/server-info", s.handlers.ServerInfo)
 		}
 
 		// Whoami endpoint (auth required)
@@ -159,90 +521,236 @@ func (s *Server) setupRouter() *chi.Mux {
 			r.Get("/whoami", s.handlers.Whoami)
 		}
 
-		// Registry index endpoint (no auth required for GET)
-		r.Get("/registry/{name}/index.json", s.serveIndexPlaceholder)
+		// Version comparison utility (no auth required)
+		if s.handlers.CompareVersions != nil {
+			r.Get("/version/compare", s.handlers.CompareVersions)
+		}
+
+		// Index signing public keys (no auth required)
+		if s.handlers.JWKS != nil {
+			r.Get("/jwks.json", s.handlers.JWKS)
+		}
+
+		// Payload JSON Schemas (no auth required)
+		if s.handlers.ListSchemas != nil {
+			r.Get("/schemas", s.handlers.ListSchemas)
+		}
+		if s.handlers.GetSchema != nil {
+			r.Get("/schemas/{schema}", s.handlers.GetSchema)
+		}
+
+		// Differential sync stream (auth required, including reads)
+		if s.handlers.Sync != nil {
+			r.With(middleware.RequireUser(s.authenticator)).Get("/sync", s.handlers.Sync)
+		}
+
+		// Packages maintained or administered by the caller (auth required)
+		if s.handlers.MyPackages != nil {
+			r.With(middleware.RequireUser(s.authenticator)).Get("/me/packages", s.handlers.MyPackages)
+		}
+
+		// Package names used by several registries (auth required, since
+		// they span registries that may not allow anonymous reads)
+		if s.handlers.PackageConflicts != nil {
+			r.With(middleware.RequireUser(s.authenticator)).Get("/conflicts/packages", s.handlers.PackageConflicts)
+		}
+
+		// Read-only GraphQL queries (auth required, for the same reason)
+		if s.handlers.GraphQL != nil {
+			r.With(middleware.RequireUser(s.authenticator)).Get("/graphql", s.handlers.GraphQL)
+			r.With(middleware.RequireUser(s.authenticator)).Post("/graphql", s.handlers.GraphQL)
+		}
+
+		// Configuration reload (admin scope required)
+		if writes && s.handlers.AdminReload != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Post("/admin/reload", s.handlers.AdminReload)
+		}
+		// Restore from a storage backup (admin scope required)
+		if writes && s.handlers.AdminRestore != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Post("/admin/restore", s.handlers.AdminRestore)
+		}
+		// Versions of the data kept by the storage, and rollbacks to them
+		// (admin scope required)
+		if writes && s.handlers.AdminVersions != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Get("/admin/storage-versions", s.handlers.AdminVersions)
+		}
+		if writes && s.handlers.AdminRollback != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Post("/admin/rollback", s.handlers.AdminRollback)
+		}
+		// Records quarantined while loading the data (admin scope required)
+		if writes && s.handlers.AdminQuarantine != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Get("/admin/quarantine", s.handlers.AdminQuarantine)
+		}
+		// Archive verification report (admin scope required)
+		if writes && s.handlers.AdminVerification != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Get("/admin/verification", s.handlers.AdminVerification)
+		}
+		// Storage token in use, and re-authentication (admin scope required)
+		if writes && s.handlers.AdminCredentials != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Get("/admin/storage-credentials", s.handlers.AdminCredentials)
+		}
+		if writes && s.handlers.AdminReauthenticate != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Post("/admin/storage-credentials/reauthenticate", s.handlers.AdminReauthenticate)
+		}
+		// Failed write requests captured for debugging (admin scope required)
+		if writes && s.handlers.AdminCaptured != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Get("/admin/captured-requests", s.handlers.AdminCaptured)
+		}
+		if writes && s.handlers.AdminClearCaptured != nil {
+			r.With(middleware.RequireScope(s.authenticator, auth.ScopeAdmin)).Delete("/admin/captured-requests", s.handlers.AdminClearCaptured)
+		}
+
+		// Registry index endpoint (anonymous read policy for GET and HEAD)
+		r.With(indexReadable, indexCache).Get("/registry/{name}/index.json", s.serveIndexPlaceholder)
+		r.With(indexReadable, indexCache).Head("/registry/{name}/index.json", s.serveIndexPlaceholder)
 		r.Options("/registry/{name}/index.json", s.handleOptionsPlaceholder)
 
 		// Registry endpoints
 		r.Route("/registry", func(r chi.Router) {
-			// List registries (auth required)
+			// List registries (anonymous read policy; anonymous callers only
+			// see the registries they may read)
 			if s.handlers.ListRegistries != nil {
-				r.With(middleware.RequireAuth(s.authenticator)).Get("/", s.handlers.ListRegistries)
+				r.With(readable, middleware.CacheControl(cache.RegistryMaxAge, true)).Get("/", s.handlers.ListRegistries)
 			}
 
 			// Create registry (auth required)
-			if s.handlers.CreateRegistry != nil {
-				r.With(middleware.RequireAuth(s.authenticator)).Post("/", s.handlers.CreateRegistry)
+			if writes && s.handlers.CreateRegistry != nil {
+				r.With(writable...).Post("/", s.handlers.CreateRegistry)
 			}
 
 			// Single registry operations
 			r.Route("/{name}", func(r chi.Router) {
-				// Get registry (no auth required)
+				// Get registry (anonymous read policy)
 				if s.handlers.GetRegistry != nil {
-					r.Get("/", s.handlers.GetRegistry)
+					r.With(readable, registryCache).Get("/", s.handlers.GetRegistry)
+				}
+
+				// Registry dashboard (anonymous read policy)
+				if s.handlers.RegistryDashboard != nil {
+					r.With(readable, registryCache).Get("/summary.json", s.handlers.RegistryDashboard)
+				}
+
+				// Install scripts (anonymous read policy)
+				if s.handlers.InstallShell != nil {
+					r.With(readable, registryCache).Get("/install.sh", s.handlers.InstallShell)
+				}
+				if s.handlers.InstallPowerShell != nil {
+					r.With(readable, registryCache).Get("/install.ps1", s.handlers.InstallPowerShell)
+				}
+
+				// Broken version URLs (anonymous read policy)
+				if s.handlers.BrokenLinks != nil {
+					r.With(readable).Get("/broken-links", s.handlers.BrokenLinks)
+				}
+
+				// Lockfile generation (anonymous read policy; reads only)
+				if s.handlers.LockRegistry != nil {
+					r.With(readable).Post("/lock", s.handlers.LockRegistry)
 				}
 
 				// Update registry (auth required)
-				if s.handlers.UpdateRegistry != nil {
-					r.With(middleware.RequireAuth(s.authenticator)).Put("/", s.handlers.UpdateRegistry)
+				if writes && s.handlers.UpdateRegistry != nil {
+					r.With(writable...).Put("/", s.handlers.UpdateRegistry)
+				}
+
+				// Patch registry (auth required)
+				if writes && s.handlers.PatchRegistry != nil {
+					r.With(writable...).Patch("/", s.handlers.PatchRegistry)
 				}
 
 				// Delete registry (auth required)
-				if s.handlers.DeleteRegistry != nil {
-					r.With(middleware.RequireAuth(s.authenticator)).Delete("/", s.handlers.DeleteRegistry)
+				if writes && s.handlers.DeleteRegistry != nil {
+					r.With(writable...).Delete("/", s.handlers.DeleteRegistry)
+				}
+
+				// Clone registry (auth required)
+				if writes && s.handlers.CloneRegistry != nil {
+					r.With(writable...).Post("/clone", s.handlers.CloneRegistry)
+				}
+
+				// API keys for CI publishing (registry admins)
+				if writes && s.handlers.ListAPIKeys != nil {
+					r.With(middleware.RequireUser(s.authenticator)).Get("/api-keys", s.handlers.ListAPIKeys)
+				}
+				if writes && s.handlers.CreateAPIKey != nil {
+					r.With(writable...).Post("/api-keys", s.handlers.CreateAPIKey)
+				}
+				if writes && s.handlers.DeleteAPIKey != nil {
+					r.With(writable...).Delete("/api-keys/{id}", s.handlers.DeleteAPIKey)
+				}
+
+				// Batch update packages (auth required)
+				if writes && s.handlers.BatchUpdatePackages != nil {
+					r.With(writable...).Post("/packages:batch-update", s.handlers.BatchUpdatePackages)
 				}
 
 				// Package endpoints
 				r.Route("/package", func(r chi.Router) {
-					// List packages (no auth required)
+					// List packages (anonymous read policy)
 					if s.handlers.ListPackages != nil {
-						r.Get("/", s.handlers.ListPackages)
+						r.With(readable, packageCache).Get("/", s.handlers.ListPackages)
 					}
 
 					// Create package (auth required)
-					if s.handlers.CreatePackage != nil {
-						r.With(middleware.RequireAuth(s.authenticator)).Post("/", s.handlers.CreatePackage)
+					if writes && s.handlers.CreatePackage != nil {
+						r.With(writable...).Post("/", s.handlers.CreatePackage)
 					}
 
-					// Single package operations
+					// Single package operations (reads through the old
+					// name of a renamed package are redirected)
 					r.Route("/{package}", func(r chi.Router) {
-						// Get package (no auth required)
+						// Get package (anonymous read policy)
 						if s.handlers.GetPackage != nil {
-							r.Get("/", s.handlers.GetPackage)
+							r.With(readable, s.redirectAliases, packageCache).Get("/", s.handlers.GetPackage)
 						}
 
 						// Update package (auth required)
-						if s.handlers.UpdatePackage != nil {
-							r.With(middleware.RequireAuth(s.authenticator)).Put("/", s.handlers.UpdatePackage)
+						if writes && s.handlers.UpdatePackage != nil {
+							r.With(writable...).Put("/", s.handlers.UpdatePackage)
+						}
+
+						// Patch package (auth required)
+						if writes && s.handlers.PatchPackage != nil {
+							r.With(writable...).Patch("/", s.handlers.PatchPackage)
 						}
 
 						// Delete package (auth required)
-						if s.handlers.DeletePackage != nil {
-							r.With(middleware.RequireAuth(s.authenticator)).Delete("/", s.handlers.DeletePackage)
+						if writes && s.handlers.DeletePackage != nil {
+							r.With(writable...).Delete("/", s.handlers.DeletePackage)
+						}
+
+						// Package change history (anonymous read policy)
+						if s.handlers.PackageHistory != nil {
+							r.With(readable, s.redirectAliases, packageCache).Get("/history", s.handlers.PackageHistory)
 						}
 
 						// Version endpoints
 						r.Route("/version", func(r chi.Router) {
-							// List versions (no auth required)
+							// List versions (anonymous read policy)
 							if s.handlers.ListVersions != nil {
-								r.Get("/", s.handlers.ListVersions)
+								r.With(readable, s.redirectAliases, versionCache).Get("/", s.handlers.ListVersions)
 							}
 
 							// Create version (auth required)
-							if s.handlers.CreateVersion != nil {
-								r.With(middleware.RequireAuth(s.authenticator)).Post("/", s.handlers.CreateVersion)
+							if writes && s.handlers.CreateVersion != nil {
+								r.With(writable...).Post("/", s.handlers.CreateVersion)
 							}
 
 							// Single version operations
 							r.Route("/{version}", func(r chi.Router) {
-								// Get version (no auth required)
+								// Get version (anonymous read policy)
 								if s.handlers.GetVersion != nil {
-									r.Get("/", s.handlers.GetVersion)
+									r.With(readable, s.redirectAliases, versionCache).Get("/", s.handlers.GetVersion)
 								}
 
 								// Delete version (auth required)
-								if s.handlers.DeleteVersion != nil {
-									r.With(middleware.RequireAuth(s.authenticator)).Delete("/", s.handlers.DeleteVersion)
+								if writes && s.handlers.DeleteVersion != nil {
+									r.With(writable...).Delete("/", s.handlers.DeleteVersion)
+								}
+
+								// Cancel a scheduled version (auth required)
+								if writes && s.handlers.CancelVersion != nil {
+									r.With(writable...).Delete("/schedule", s.handlers.CancelVersion)
 								}
 							})
 						})
@@ -255,6 +763,131 @@ func (s *Server) setupRouter() *chi.Mux {
 	return router
 }
 
+// allowsAnonymousRead reports whether a read route may be served without
+// credentials: per the registry's own setting when the route names an
+// existing registry, per server.anonymous_read otherwise. On a private
+// server, a missing registry is answered 401 rather than 404, so anonymous
+// callers cannot probe registry names.
+func (s *Server) allowsAnonymousRead(r *http.Request) bool {
+	serverDefault := s.config.Server.AnonymousRead
+	name := chi.URLParam(r, "name")
+	if name == "" {
+		return serverDefault
+	}
+	registry, err := s.store.GetRegistry(r.Context(), name)
+	if err != nil {
+		return serverDefault
+	}
+	return registry.AllowsAnonymousRead(serverDefault)
+}
+
+// redirectAliases answers reads of a renamed package through its old name
+// with a 308 redirect to the same route under the new name, per the
+// registry's aliases. Writes are not redirected: they must name the package.
+func (s *Server) redirectAliases(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.store == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		registryName := chi.URLParam(r, "name")
+		alias := chi.URLParam(r, "package")
+		registry, err := s.store.GetRegistry(r.Context(), registryName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		target, ok := registry.ResolveAlias(alias)
+		prefix := "/api/v1/registry/" + registryName + "/package/" + alias
+		if !ok || !strings.HasPrefix(r.URL.Path, prefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		location := middleware.BasePath(r.Context()) + "/api/v1/registry/" + registryName + "/package/" + target + strings.TrimPrefix(r.URL.Path, prefix)
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		s.logger.Debug("Redirecting package alias",
+			"registry", registryName,
+			"alias", alias,
+			"package", target)
+		http.Redirect(w, r, location, http.StatusPermanentRedirect)
+	})
+}
+
+// OnShutdown registers a function to run during graceful shutdown, after the
+// HTTP server stops and before storage is closed
+func (s *Server) OnShutdown(fn func()) {
+	s.shutdownHooks = append(s.shutdownHooks, fn)
+}
+
+// handler serves the API of a listener profile below server.base_path,
+// linking to it at server.external_url when set, and registry indexes at
+// the root of their vanity hosts
+func (s *Server) handler(profile string) http.Handler {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.serveHTTP(profile, w, r)
+	})
+	h = middleware.WithExternalURL(s.config.Server.ExternalURL)(h)
+	h = middleware.StripBasePath(s.config.Server.BasePath)(h)
+	return s.vanityHosts.Handler(s.config.Server.BasePath)(h)
+}
+
+// serveHTTP dispatches to the router of profile, or answers 503 while
+// storage is loading
+func (s *Server) serveHTTP(profile string, w http.ResponseWriter, r *http.Request) {
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+
+	if routers := s.routers.Load(); routers != nil {
+		(*routers)[profile].ServeHTTP(w, r)
+		return
+	}
+
+	// The process is alive while loading, only not ready
+	if r.URL.Path == "/livez" {
+		s.writeReadiness(w, http.StatusOK, "alive")
+		return
+	}
+
+	w.Header().Set("Retry-After", "5")
+	if r.URL.Path == "/readyz" {
+		s.writeReadiness(w, http.StatusServiceUnavailable, "loading")
+		return
+	}
+	apierrors.WriteError(w, apierrors.ErrCodeStorageLoading, "Server is starting: storage data is still loading", http.StatusServiceUnavailable, nil)
+}
+
+// ReadinessResponse represents the /readyz, /livez and /prestop responses
+type ReadinessResponse struct {
+	Status   string `json:"status"`              // loading | ready | degraded | draining, or alive for /livez
+	InFlight int64  `json:"in_flight,omitempty"` // Requests being served, while draining
+}
+
+func (s *Server) writeReadiness(w http.ResponseWriter, status int, state string) {
+	response := ReadinessResponse{Status: state}
+	if state == "draining" {
+		// This request is not counted
+		response.InFlight = max(s.inFlight.Load()-1, 0)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetTLSConfig makes the server serve HTTPS with tlsConfig (called before
+// Listen)
+func (s *Server) SetTLSConfig(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+}
+
+// SetRequestValidator makes the server reject requests that do not match
+// the OpenAPI spec before they reach the handlers
+func (s *Server) SetRequestValidator(validator *middleware.RequestValidator) {
+	s.validator = validator
+}
+
 // SetHandlers sets all handlers (called from main to avoid import cycle)
 func (s *Server) SetHandlers(handlers HandlerSet) {
 	s.handlers = handlers
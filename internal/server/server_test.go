@@ -0,0 +1,615 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/docs"
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/config"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/server/middleware"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestServer_NotReadyUntilStarted(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(HandlerSet{
+		Health: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) },
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.serveHTTP(config.ListenerAll, rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// While storage loads, everything is unavailable
+	rec := serve("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"loading"}`, rec.Body.String())
+	assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+
+	rec = serve("/api/v1/health")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "STORAGE_LOADING")
+
+	// but alive
+	rec = serve("/livez")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"alive"}`, rec.Body.String())
+
+	// Installing the routes makes the server ready
+	srv.installRouters()
+
+	rec = serve("/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ready"}`, rec.Body.String())
+
+	rec = serve("/api/v1/health")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusOK, serve("/livez").Code)
+
+	// Serving the storage cache keeps the server ready, reporting it
+	srv.SetDegraded(true)
+	rec = serve("/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"degraded"}`, rec.Body.String())
+}
+
+// TestServer_RoutesMatchOpenAPI keeps the router and docs/openapi.yaml in
+// sync: every route is documented and every documented operation is routed
+func TestServer_RoutesMatchOpenAPI(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {}))
+
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(docs.OpenAPI)
+	require.NoError(t, err)
+
+	// Spec paths are relative to /api/v1, except those with their own servers
+	specPath := func(item *openapi3.PathItem, path string) string {
+		if len(item.Servers) > 0 {
+			return path
+		}
+		return "/api/v1" + path
+	}
+
+	// Internal listeners serve every route, some of them only there
+	routed := make(map[string]bool)
+	for _, profile := range []string{config.ListenerAll, config.ListenerInternal} {
+		err = chi.Walk(srv.setupRouter(profile), func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			if len(route) > 1 {
+				route = strings.TrimSuffix(route, "/")
+			}
+			routed[method+" "+route] = true
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+specPath(item, path)] = true
+		}
+	}
+
+	for route := range routed {
+		assert.True(t, documented[route], "route %s is not in docs/openapi.yaml", route)
+	}
+	for operation := range documented {
+		assert.True(t, routed[operation], "operation %s is not routed", operation)
+	}
+}
+
+func TestServer_TrailingSlash(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	router := srv.setupRouter(config.ListenerAll)
+
+	for _, path := range []string{
+		"/readyz",
+		"/api/v1/health",
+		"/api/v1/whoami",
+		"/api/v1/schemas/package",
+		"/api/v1/registry",
+		"/api/v1/registry/tools",
+		"/api/v1/registry/tools/package",
+		"/api/v1/registry/tools/package/deployer/version/1.0.0",
+	} {
+		for _, requested := range []string{path, path + "/", path + "//"} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, requested, nil))
+			assert.Equal(t, http.StatusOK, rec.Code, requested)
+			if path != "/readyz" {
+				assert.Equal(t, path, rec.Body.String(), requested)
+			}
+		}
+	}
+}
+
+func TestServer_StreamsFlushed(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	cfg.Server.CaptureFailedRequests = 10
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	// Streaming handlers flush what they wrote so far, and only continue
+	// once the client read it: through every middleware of the chain
+	release := make(chan struct{}, 1)
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first\n"))
+		flusher, ok := w.(http.Flusher)
+		if !assert.True(t, ok, r.URL.Path) {
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-release:
+			w.Write([]byte("second\n"))
+		case <-time.After(5 * time.Second):
+			w.Write([]byte("not flushed\n"))
+		}
+	}))
+	srv.installRouters()
+	server := httptest.NewServer(srv.handler(config.ListenerAll))
+	defer server.Close()
+
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/sync"},
+		{http.MethodPost, "/api/v1/registry"}, // Also captured
+	} {
+		req, err := http.NewRequest(request.method, server.URL+request.path, strings.NewReader("{}"))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		reader := bufio.NewReader(resp.Body)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "first\n", line)
+		release <- struct{}{}
+		line, err = reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "second\n", line, request.path)
+		resp.Body.Close()
+	}
+}
+
+func TestServer_ListenerProfiles(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	cfg.Server.RateLimit = 1
+	cfg.Server.RateLimitBurst = 3
+	cfg.Server.Listeners = []string{"0.0.0.0:8080=public", "[::]:8080=public", "127.0.0.1:9090=internal"}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.installRouters()
+	assert.Len(t, *srv.routers.Load(), 2)
+
+	serve := func(profile, method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		rec := httptest.NewRecorder()
+		srv.handler(profile).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Public listeners serve reads only
+	assert.Equal(t, http.StatusOK, serve(config.ListenerPublic, http.MethodGet, "/api/v1/registry/tools/index.json"))
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(config.ListenerPublic, http.MethodDelete, "/api/v1/registry/tools"))
+	assert.Equal(t, http.StatusNotFound, serve(config.ListenerPublic, http.MethodPost, "/api/v1/admin/reload"))
+	// and are rate limited (a burst of 3)
+	assert.Equal(t, http.StatusTooManyRequests, serve(config.ListenerPublic, http.MethodGet, "/api/v1/registry/tools/index.json"))
+
+	// Internal listeners serve everything, without rate limit
+	assert.Equal(t, http.StatusOK, serve(config.ListenerInternal, http.MethodDelete, "/api/v1/registry/tools"))
+	assert.Equal(t, http.StatusOK, serve(config.ListenerInternal, http.MethodPut, "/api/v1/registry/tools"))
+	assert.Equal(t, http.StatusOK, serve(config.ListenerInternal, http.MethodPost, "/api/v1/admin/reload"))
+	assert.Equal(t, http.StatusOK, serve(config.ListenerInternal, http.MethodGet, "/api/v1/registry/tools/index.json"))
+}
+
+func TestServer_Drain(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	cfg.Server.DrainDuration = 100 * time.Millisecond
+	cfg.Server.Listeners = []string{"127.0.0.1:8080=public", "127.0.0.1:8081=all", "127.0.0.1:9090=internal"}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	release := make(chan struct{})
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("slow") {
+			<-release
+		}
+	}))
+	srv.installRouters()
+
+	serveMethod := func(profile, method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.handler(profile).ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+	serve := func(profile, path string) *httptest.ResponseRecorder {
+		return serveMethod(profile, http.MethodGet, path)
+	}
+
+	// Only internal listeners can take the server out of rotation, and
+	// only with a POST
+	for _, profile := range []string{config.ListenerPublic, config.ListenerAll} {
+		assert.Equal(t, http.StatusNotFound, serveMethod(profile, http.MethodPost, "/prestop").Code, profile)
+		assert.Equal(t, http.StatusNotFound, serve(profile, "/prestop").Code, profile)
+	}
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(config.ListenerInternal, "/prestop").Code)
+	assert.False(t, srv.Draining())
+	assert.Equal(t, http.StatusOK, serve(config.ListenerPublic, "/readyz").Code)
+
+	// A request in flight
+	slow := make(chan int)
+	go func() { slow <- serve(config.ListenerInternal, "/api/v1/registry/tools?slow").Code }()
+	require.Eventually(t, func() bool { return srv.InFlight() == 1 }, time.Second, time.Millisecond)
+
+	start := time.Now()
+	preStop := make(chan *httptest.ResponseRecorder)
+	go func() { preStop <- serveMethod(config.ListenerInternal, http.MethodPost, "/prestop") }()
+	require.Eventually(t, srv.Draining, time.Second, time.Millisecond)
+
+	// While draining, readiness fails and requests are still served
+	rec := serve(config.ListenerPublic, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"draining","in_flight":2}`, rec.Body.String())
+	assert.Equal(t, http.StatusOK, serve(config.ListenerPublic, "/api/v1/registry/tools/index.json").Code)
+
+	// The hook answers once the drain duration has passed
+	rec = <-preStop
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.GreaterOrEqual(t, time.Since(start), cfg.Server.DrainDuration)
+	close(release)
+	assert.Equal(t, http.StatusOK, <-slow)
+	assert.Zero(t, srv.InFlight())
+}
+
+func TestListenNetwork(t *testing.T) {
+	assert.Equal(t, "tcp4", listenNetwork("0.0.0.0:8080"))
+	assert.Equal(t, "tcp6", listenNetwork("[::]:8080"))
+	assert.Equal(t, "tcp", listenNetwork(":8080"))
+}
+
+func TestServer_RouteMetrics(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {}))
+	router := srv.setupRouter(config.ListenerAll)
+
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/registry/tools"},
+		{http.MethodGet, "/api/v1/registry/build/"},
+		{http.MethodPost, "/api/v1/registry"},
+		{http.MethodDelete, "/api/v1/registry/tools/package/deployer/version/1.0.0"},
+		{http.MethodGet, "/nowhere"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(request.method, request.path, nil))
+	}
+
+	routes := srv.RouteMetrics().Routes()
+	assert.Equal(t, uint64(2), routes["GET /api/v1/registry/{name}"].Requests)
+	assert.Equal(t, uint64(1), routes["POST /api/v1/registry"].Requests)
+	assert.Equal(t, uint64(1), routes["DELETE /api/v1/registry/{name}/package/{package}/version/{version}"].Requests)
+	assert.Equal(t, uint64(1), routes[middleware.UnroutedKey].ClientErrors)
+}
+
+func TestServer_BasePath(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	cfg.Server.BasePath = "/cola"
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	srv.installRouters()
+	handler := srv.handler(config.ListenerAll)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/cola/readyz").Code)
+	rec := serve("/cola/api/v1/registry/tools")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/api/v1/registry/tools", rec.Body.String())
+
+	// Nothing is served outside the base path
+	assert.Equal(t, http.StatusNotFound, serve("/readyz").Code)
+	assert.Equal(t, http.StatusNotFound, serve("/api/v1/registry/tools").Code)
+}
+
+func TestServer_VanityHosts(t *testing.T) {
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	cfg.Server.BasePath = "/cola"
+	cfg.Server.VanityHosts = []string{"tools.example.com=build"}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	srv.installRouters()
+	handler := srv.handler(config.ListenerAll)
+
+	serve := func(host, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The index is served at the root of the vanity host, port and case aside
+	for _, host := range []string{"tools.example.com", "Tools.Example.com:8080"} {
+		rec := serve(host, "/index.json")
+		assert.Equal(t, http.StatusOK, rec.Code, host)
+		assert.Equal(t, "/api/v1/registry/build/index.json", rec.Body.String(), host)
+	}
+
+	// Other paths and hosts are routed as usual
+	assert.Equal(t, http.StatusNotFound, serve("tools.example.com", "/api/v1/registry/build").Code)
+	assert.Equal(t, http.StatusOK, serve("tools.example.com", "/cola/api/v1/registry/build").Code)
+	assert.Equal(t, http.StatusNotFound, serve("other.example.com", "/index.json").Code)
+
+	// The mapping is reloadable
+	cfg2 := *cfg
+	cfg2.Server.VanityHosts = []string{"other.example.com=deploy"}
+	srv.ApplyConfig(&cfg2)
+	assert.Equal(t, http.StatusNotFound, serve("tools.example.com", "/index.json").Code)
+	assert.Equal(t, "/api/v1/registry/deploy/index.json", serve("other.example.com", "/index.json").Body.String())
+}
+
+func TestServer_AnonymousRead(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	private, public := false, true
+	require.NoError(t, store.CreateRegistry(ctx, &models.Registry{Name: "default"}))
+	require.NoError(t, store.CreateRegistry(ctx, &models.Registry{Name: "private", AnonymousRead: &private}))
+	require.NoError(t, store.CreateRegistry(ctx, &models.Registry{Name: "public", AnonymousRead: &public}))
+
+	newRouter := func(anonymousRead bool) *chi.Mux {
+		cfg, err := config.LoadWithViper(config.NewViper())
+		require.NoError(t, err)
+		cfg.Server.AnonymousRead = anonymousRead
+		srv := NewServer(cfg, logger, tokenAuth{})
+		srv.SetStore(store)
+		srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.URL.Path))
+		}))
+		return srv.setupRouter(config.ListenerAll)
+	}
+	serve := func(router *chi.Mux, method, path string, authenticated bool) int {
+		req := httptest.NewRequest(method, path, nil)
+		if authenticated {
+			req.Header.Set("Authorization", "Bearer valid")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	paths := func(registry string) []string {
+		return []string{
+			"/api/v1/registry/" + registry,
+			"/api/v1/registry/" + registry + "/index.json",
+			"/api/v1/registry/" + registry + "/package",
+			"/api/v1/registry/" + registry + "/package/deployer",
+			"/api/v1/registry/" + registry + "/package/deployer/history",
+			"/api/v1/registry/" + registry + "/package/deployer/version",
+			"/api/v1/registry/" + registry + "/package/deployer/version/1.0.0",
+		}
+	}
+
+	for _, anonymousRead := range []bool{true, false} {
+		router := newRouter(anonymousRead)
+		expected := map[string]bool{
+			"default": anonymousRead,
+			"private": false,
+			"public":  true,
+			"missing": anonymousRead,
+		}
+		for registry, allowed := range expected {
+			for _, path := range paths(registry) {
+				want := http.StatusUnauthorized
+				if allowed {
+					want = http.StatusOK
+				}
+				assert.Equal(t, want, serve(router, http.MethodGet, path, false), "anonymous_read=%v %s", anonymousRead, path)
+				assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, path, true), path)
+			}
+		}
+		assert.Equal(t, expected["default"], serve(router, http.MethodHead, "/api/v1/registry/default/index.json", false) == http.StatusOK)
+
+		listStatus := http.StatusUnauthorized
+		if anonymousRead {
+			listStatus = http.StatusOK
+		}
+		assert.Equal(t, listStatus, serve(router, http.MethodGet, "/api/v1/registry", false))
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/registry", true))
+
+		// Queries spanning registries always need credentials
+		assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, "/api/v1/graphql", false))
+		assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, "/api/v1/conflicts/packages", false))
+	}
+}
+
+func TestServer_WritesRequireAuth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	srv := NewServer(cfg, logger, tokenAuth{})
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {}))
+	router := srv.setupRouter(config.ListenerAll)
+
+	serve := func(method, path string, authenticated bool) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"description":"rewritten"}`))
+		if authenticated {
+			req.Header.Set("Authorization", "Bearer valid")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, path := range []string{"/api/v1/registry/tools", "/api/v1/registry/tools/package/deployer"} {
+		for _, method := range []string{http.MethodPatch, http.MethodPut, http.MethodDelete} {
+			assert.Equal(t, http.StatusUnauthorized, serve(method, path, false), "%s %s", method, path)
+			assert.Equal(t, http.StatusOK, serve(method, path, true), "%s %s", method, path)
+		}
+	}
+}
+
+func TestServer_AnonymousTier(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(context.Background(), &models.Registry{Name: "tools"}))
+
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	cfg.Server.AnonymousAccess = config.AnonymousAccessIndex
+	cfg.Server.AnonymousRateLimit = 2
+	srv := NewServer(cfg, logger, tokenAuth{})
+	srv.SetStore(store)
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	router := srv.setupRouter(config.ListenerAll)
+
+	serve := func(path, remoteAddr string, authenticated bool) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if authenticated {
+			req.Header.Set("Authorization", "Bearer valid")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Anonymous callers only get registry indexes
+	assert.Equal(t, http.StatusOK, serve("/api/v1/registry/tools/index.json", "10.0.0.1:1000", false))
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/registry/tools/package", "10.0.0.1:1000", false))
+	assert.Equal(t, http.StatusOK, serve("/api/v1/registry/tools/package", "10.0.0.1:1000", true))
+
+	// and their own, lower, rate limit
+	assert.Equal(t, http.StatusTooManyRequests, serve("/api/v1/registry/tools/index.json", "10.0.0.1:1000", false))
+	assert.Equal(t, http.StatusOK, serve("/api/v1/registry/tools/index.json", "10.0.0.2:1000", false))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve("/api/v1/registry/tools/index.json", "10.0.0.1:1000", true))
+	}
+}
+
+// tokenAuth accepts the bearer token "valid"
+type tokenAuth struct{}
+
+func (tokenAuth) Authenticate(r *http.Request) (*auth.User, error) {
+	if r.Header.Get("Authorization") != "Bearer valid" {
+		return nil, errors.New("invalid credentials")
+	}
+	return &auth.User{Username: "alice"}, nil
+}
+
+func (a tokenAuth) Middleware() func(http.Handler) http.Handler {
+	return middleware.RequireUser(a)
+}
+
+// handlerSetOf returns a HandlerSet with every handler set to h
+func handlerSetOf(h http.HandlerFunc) HandlerSet {
+	var handlers HandlerSet
+	fields := reflect.ValueOf(&handlers).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		fields.Field(i).Set(reflect.ValueOf(h))
+	}
+	return handlers
+}
+
+func TestServer_PackageAliases(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	registry := models.NewRegistry("tools", "", nil, nil)
+	registry.Aliases = map[string]string{"deployer": "deployer-ng", "builder": "builder-ng"}
+	require.NoError(t, store.CreateRegistry(ctx, registry))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer-ng", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("builder", "", nil, nil)))
+
+	cfg, err := config.LoadWithViper(config.NewViper())
+	require.NoError(t, err)
+	cfg.Server.BasePath = "/cola"
+	srv := NewServer(cfg, logger, auth.NewNoAuth())
+	srv.SetStore(store)
+	srv.SetHandlers(handlerSetOf(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	srv.installRouters()
+	handler := srv.handler(config.ListenerAll)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	// Reads through the old name are redirected, query included
+	rec := serve(http.MethodGet, "/cola/api/v1/registry/tools/package/deployer/version/1.0.0?pretty=1")
+	assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+	assert.Equal(t, "/cola/api/v1/registry/tools/package/deployer-ng/version/1.0.0?pretty=1", rec.Header().Get("Location"))
+	rec = serve(http.MethodGet, "/cola/api/v1/registry/tools/package/deployer")
+	assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+	assert.Equal(t, "/cola/api/v1/registry/tools/package/deployer-ng", rec.Header().Get("Location"))
+
+	// Writes are not, and an existing package wins over its alias
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "/cola/api/v1/registry/tools/package/deployer").Code)
+	rec = serve(http.MethodGet, "/cola/api/v1/registry/tools/package/builder")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/api/v1/registry/tools/package/builder", rec.Body.String())
+}
@@ -0,0 +1,257 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Algorithm is the JWS algorithm used for index signatures
+const Algorithm = "EdDSA"
+
+// SignatureHeader is the HTTP response header carrying the index.json signature
+const SignatureHeader = "X-Index-Signature"
+
+var (
+	// ErrNoSigningKey is returned when signing is requested without a private key
+	ErrNoSigningKey = errors.New("no signing key configured")
+
+	// ErrUnknownKey is returned when a signature references a key not in the key set
+	ErrUnknownKey = errors.New("signature key not found in key set")
+
+	// ErrInvalidSignature is returned when a signature does not match the payload
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Key is an Ed25519 key identified by its RFC 7638 thumbprint.
+// Private is nil for verification-only keys (e.g. keys being retired).
+type Key struct {
+	ID      string
+	Public  ed25519.PublicKey
+	Private ed25519.PrivateKey
+}
+
+// KeySet holds every key published in the JWKS.
+// The first key with a private part signs; all keys verify, which allows
+// clients to keep validating signatures made before a rotation.
+type KeySet struct {
+	keys   []*Key
+	signer *Key
+}
+
+// JWK is a single public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// jwsHeader is the protected header of a detached JWS
+type jwsHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// LoadKeySet loads PEM-encoded Ed25519 keys from the given files.
+// Files may contain a PKCS#8 private key ("PRIVATE KEY") or a PKIX public key
+// ("PUBLIC KEY"). The first private key becomes the signing key.
+func LoadKeySet(paths []string) (*KeySet, error) {
+	ks := &KeySet{}
+	seen := make(map[string]bool)
+
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+		}
+
+		key, err := parseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key %s: %w", path, err)
+		}
+
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate signing key %s (kid %s)", path, key.ID)
+		}
+		seen[key.ID] = true
+
+		ks.keys = append(ks.keys, key)
+		if ks.signer == nil && key.Private != nil {
+			ks.signer = key
+		}
+	}
+
+	if len(ks.keys) > 0 && ks.signer == nil {
+		return nil, fmt.Errorf("no private key among signing keys: at least one key must be able to sign")
+	}
+
+	return ks, nil
+}
+
+// parseKey parses a single PEM block into a Key
+func parseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		priv, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not Ed25519")
+		}
+		return NewKey(priv.Public().(ed25519.PublicKey), priv), nil
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is not Ed25519")
+		}
+		return NewKey(pub, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+// NewKey creates a key and derives its key ID from the public key
+func NewKey(pub ed25519.PublicKey, priv ed25519.PrivateKey) *Key {
+	return &Key{
+		ID:      Thumbprint(pub),
+		Public:  pub,
+		Private: priv,
+	}
+}
+
+// Thumbprint returns the RFC 7638 JWK thumbprint of an Ed25519 public key
+func Thumbprint(pub ed25519.PublicKey) string {
+	// Members in lexicographic order, no whitespace (RFC 7638 section 3)
+	canonical := fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, base64.RawURLEncoding.EncodeToString(pub))
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Enabled reports whether the key set can sign
+func (ks *KeySet) Enabled() bool {
+	return ks != nil && ks.signer != nil
+}
+
+// SigningKeyID returns the ID of the current signing key, or "" if disabled
+func (ks *KeySet) SigningKeyID() string {
+	if !ks.Enabled() {
+		return ""
+	}
+	return ks.signer.ID
+}
+
+// Sign returns a detached compact JWS (RFC 7515 appendix F) over payload
+func (ks *KeySet) Sign(payload []byte) (string, error) {
+	if !ks.Enabled() {
+		return "", ErrNoSigningKey
+	}
+
+	header, err := json.Marshal(jwsHeader{Algorithm: Algorithm, KeyID: ks.signer.ID})
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	sig := ed25519.Sign(ks.signer.Private, signingInput(protected, payload))
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// JWKS returns the public keys of the set as a JSON Web Key Set
+func (ks *KeySet) JWKS() *JWKS {
+	jwks := &JWKS{Keys: []JWK{}}
+	if ks == nil {
+		return jwks
+	}
+	for _, key := range ks.keys {
+		jwks.Keys = append(jwks.Keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(key.Public),
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: Algorithm,
+		})
+	}
+	return jwks
+}
+
+// Verify checks a detached JWS over payload against the keys in jwks and
+// returns the ID of the key that produced the signature
+func Verify(payload []byte, signature string, jwks *JWKS) (string, error) {
+	parts := strings.Split(strings.TrimSpace(signature), ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return "", fmt.Errorf("%w: expected detached JWS '<header>..<signature>'", ErrInvalidSignature)
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return "", fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if header.Algorithm != Algorithm {
+		return "", fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, header.Algorithm)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyID != header.KeyID {
+			continue
+		}
+		if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" {
+			return "", fmt.Errorf("%w: key %s is not Ed25519", ErrInvalidSignature, jwk.KeyID)
+		}
+		pub, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return "", fmt.Errorf("%w: key %s is malformed", ErrInvalidSignature, jwk.KeyID)
+		}
+		if !ed25519.Verify(pub, signingInput(parts[0], payload), sig) {
+			return "", ErrInvalidSignature
+		}
+		return header.KeyID, nil
+	}
+
+	return "", fmt.Errorf("%w: kid %s", ErrUnknownKey, header.KeyID)
+}
+
+// signingInput builds the JWS signing input for a detached payload
+func signingInput(protected string, payload []byte) []byte {
+	return []byte(protected + "." + base64.RawURLEncoding.EncodeToString(payload))
+}
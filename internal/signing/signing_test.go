@@ -0,0 +1,113 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKey writes an Ed25519 key as PEM and returns its path
+func writeKey(t *testing.T, dir, name string, private bool) (string, ed25519.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var block *pem.Block
+	if private {
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		require.NoError(t, err)
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		require.NoError(t, err)
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	}
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0600))
+	return path, pub
+}
+
+func TestSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	current, _ := writeKey(t, dir, "current.pem", true)
+
+	ks, err := LoadKeySet([]string{current})
+	require.NoError(t, err)
+	require.True(t, ks.Enabled())
+
+	payload := []byte(`[{"name":"hotfix","version":"1.0.0"}]` + "\n")
+	sig, err := ks.Sign(payload)
+	require.NoError(t, err)
+
+	kid, err := Verify(payload, sig, ks.JWKS())
+	require.NoError(t, err)
+	assert.Equal(t, ks.SigningKeyID(), kid)
+
+	// Tampered payload fails
+	_, err = Verify([]byte(`[]`), sig, ks.JWKS())
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, _ := writeKey(t, dir, "old.pem", true)
+	newKey, _ := writeKey(t, dir, "new.pem", true)
+
+	// Before rotation: old key signs
+	before, err := LoadKeySet([]string{oldKey})
+	require.NoError(t, err)
+	payload := []byte(`[]`)
+	oldSig, err := before.Sign(payload)
+	require.NoError(t, err)
+
+	// After rotation: new key signs, old key still published
+	after, err := LoadKeySet([]string{newKey, oldKey})
+	require.NoError(t, err)
+	assert.Len(t, after.JWKS().Keys, 2)
+	assert.NotEqual(t, before.SigningKeyID(), after.SigningKeyID())
+
+	kid, err := Verify(payload, oldSig, after.JWKS())
+	require.NoError(t, err)
+	assert.Equal(t, before.SigningKeyID(), kid)
+
+	// Once the old key is removed, its signatures are rejected
+	_, err = Verify(payload, oldSig, (&KeySet{keys: after.keys[:1]}).JWKS())
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	priv, _ := writeKey(t, dir, "priv.pem", true)
+	pub, _ := writeKey(t, dir, "pub.pem", false)
+
+	// Public keys are verification-only; the private key signs even if listed later
+	ks, err := LoadKeySet([]string{pub, priv})
+	require.NoError(t, err)
+	assert.Len(t, ks.JWKS().Keys, 2)
+	assert.Equal(t, ks.JWKS().Keys[1].KeyID, ks.SigningKeyID())
+
+	// Only public keys cannot sign
+	_, err = LoadKeySet([]string{pub})
+	assert.Error(t, err)
+
+	// Duplicate keys are rejected
+	_, err = LoadKeySet([]string{priv, priv})
+	assert.Error(t, err)
+
+	// No keys disables signing
+	ks, err = LoadKeySet(nil)
+	require.NoError(t, err)
+	assert.False(t, ks.Enabled())
+	assert.Empty(t, ks.JWKS().Keys)
+
+	_, err = LoadKeySet([]string{filepath.Join(dir, "missing.pem")})
+	assert.Error(t, err)
+}
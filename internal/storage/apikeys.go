@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// CreateAPIKey stores a new API key for an existing registry. Like
+// certificates, API keys are neither synced nor part of any history.
+func (b *BaseStorage) CreateAPIKey(ctx context.Context, key *models.APIKey, persist PersistFunc) error {
+	ctx, unlock, err := b.lock(ctx, "create_api_key")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, exists := b.data.Registries[key.Registry]; !exists {
+		return ErrNotFound
+	}
+	if _, exists := b.data.APIKeys[key.ID]; exists {
+		return ErrAlreadyExists
+	}
+	if b.data.APIKeys == nil {
+		b.data.APIKeys = make(map[string]*models.APIKey)
+	}
+	b.data.APIKeys[key.ID] = key
+
+	if persist != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
+			// Rollback
+			delete(b.data.APIKeys, key.ID)
+			b.logger.Error("Storage write failed",
+				"operation", "create_api_key",
+				"registry", key.Registry,
+				"id", key.ID,
+				"error", err)
+			return persistError(ctx, err)
+		}
+	}
+
+	b.logger.Info("API key created",
+		"registry", key.Registry,
+		"id", key.ID,
+		"package_prefix", key.PackagePrefix)
+	return nil
+}
+
+// GetAPIKey returns an API key by ID
+func (b *BaseStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	key, exists := b.data.APIKeys[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns the API keys of a registry, oldest first
+func (b *BaseStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if _, exists := b.data.Registries[registryName]; !exists {
+		return nil, ErrNotFound
+	}
+	keys := []*models.APIKey{}
+	for _, key := range b.data.APIKeys {
+		if key.Registry == registryName {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (b *BaseStorage) DeleteAPIKey(ctx context.Context, registryName, id string, persist PersistFunc) error {
+	ctx, unlock, err := b.lock(ctx, "delete_api_key")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	key, exists := b.data.APIKeys[id]
+	if !exists || key.Registry != registryName {
+		return ErrNotFound
+	}
+	delete(b.data.APIKeys, id)
+
+	if persist != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
+			// Rollback
+			b.data.APIKeys[id] = key
+			b.logger.Error("Storage write failed",
+				"operation", "delete_api_key",
+				"registry", registryName,
+				"id", id,
+				"error", err)
+			return persistError(ctx, err)
+		}
+	}
+
+	b.logger.Info("API key deleted",
+		"registry", registryName,
+		"id", id)
+	return nil
+}
+
+// dropAPIKeysLocked deletes the API keys of a deleted registry, so they do
+// not apply to a registry created later under the same name. It returns a
+// function restoring them.
+// Caller MUST hold the write lock.
+func (b *BaseStorage) dropAPIKeysLocked(registryName string) func() {
+	var dropped []*models.APIKey
+	for id, key := range b.data.APIKeys {
+		if key.Registry == registryName {
+			dropped = append(dropped, key)
+			delete(b.data.APIKeys, id)
+		}
+	}
+	return func() {
+		for _, key := range dropped {
+			b.data.APIKeys[key.ID] = key
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestBaseStorage_APIKeys(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	key, _, err := models.NewAPIKey("tools", "payments-", "", "alice", nil)
+	require.NoError(t, err)
+	assert.ErrorIs(t, bs.CreateAPIKey(ctx, key, nil), ErrNotFound)
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil), nil))
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("infra", "", nil, nil), nil))
+	require.NoError(t, bs.CreateAPIKey(ctx, key, nil))
+	assert.ErrorIs(t, bs.CreateAPIKey(ctx, key, nil), ErrAlreadyExists)
+
+	other, _, err := models.NewAPIKey("infra", "", "", "bob", nil)
+	require.NoError(t, err)
+	require.NoError(t, bs.CreateAPIKey(ctx, other, nil))
+
+	got, err := bs.GetAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	keys, err := bs.ListAPIKeys(ctx, "tools")
+	require.NoError(t, err)
+	assert.Equal(t, []*models.APIKey{key}, keys)
+	_, err = bs.ListAPIKeys(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// A key is only deleted through its own registry
+	assert.ErrorIs(t, bs.DeleteAPIKey(ctx, "infra", key.ID, nil), ErrNotFound)
+
+	// A failed write keeps the key
+	failing := func(context.Context) error { return errors.New("disk full") }
+	assert.Error(t, bs.DeleteAPIKey(ctx, "tools", key.ID, failing))
+	_, err = bs.GetAPIKey(ctx, key.ID)
+	assert.NoError(t, err)
+
+	require.NoError(t, bs.DeleteAPIKey(ctx, "tools", key.ID, nil))
+	_, err = bs.GetAPIKey(ctx, key.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting a registry deletes its keys, unless the write fails
+	assert.Error(t, bs.DeleteRegistry(ctx, "infra", failing))
+	_, err = bs.GetAPIKey(ctx, other.ID)
+	assert.NoError(t, err)
+	require.NoError(t, bs.DeleteRegistry(ctx, "infra", nil))
+	_, err = bs.GetAPIKey(ctx, other.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,372 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// backupTimeFormat stamps snapshot names: sortable, and valid in file names
+// and object keys alike
+const backupTimeFormat = "2006-01-02T15-04-05Z"
+
+// backupTimeout bounds a backup run, retention included
+const backupTimeout = 5 * time.Minute
+
+// BackupPolicy decides which snapshots a Backups job keeps. The most
+// recent snapshot is always kept.
+type BackupPolicy struct {
+	Keep   int           // Most recent snapshots kept; 0 keeps them all
+	MaxAge time.Duration // Older snapshots are deleted; 0 keeps them all
+}
+
+// BackupStats reports the snapshots of a Backups job
+type BackupStats struct {
+	URI          string     `json:"uri"`                     // Snapshot location, password redacted
+	Backups      uint64     `json:"backups"`                 // Successful snapshots
+	Failures     uint64     `json:"failures"`                // Failed snapshots
+	Snapshots    int        `json:"snapshots"`               // Snapshots kept after the last run
+	LastSuccess  *time.Time `json:"last_success,omitempty"`  // Time of the last successful snapshot
+	LastSnapshot string     `json:"last_snapshot,omitempty"` // Name of the last successful snapshot
+	LastError    string     `json:"last_error,omitempty"`    // Error of the last run, absent once one succeeds
+	LastErrorAt  *time.Time `json:"last_error_at,omitempty"`
+}
+
+// backupTarget is where a Backups job writes its snapshots: a directory or
+// an S3 prefix. Names are relative to it.
+type backupTarget interface {
+	put(ctx context.Context, name string, data []byte) error
+	get(ctx context.Context, name string) ([]byte, error)
+	list(ctx context.Context) ([]string, error)
+	remove(ctx context.Context, name string) error
+}
+
+// Backups snapshots the data of a backend to timestamped files or objects,
+// e.g. registry-2024-05-01T12-00-00Z.json, and deletes the snapshots its
+// policy no longer keeps. Run is meant to be scheduled; Restore brings a
+// snapshot back.
+type Backups struct {
+	source Snapshotter
+	target backupTarget
+	prefix string // Snapshot names are prefix, time and ext
+	ext    string
+	policy BackupPolicy
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	stats BackupStats
+}
+
+// NewBackups snapshots the data of source next to the file or S3 object uri
+// names: registry.json gives registry-<time>.json snapshots in the same
+// directory or prefix. token authenticates S3 URIs; opts.Compression and
+// opts.Envelope compress and encrypt the snapshots.
+func NewBackups(source Snapshotter, uri *StorageURI, token string, opts Options, policy BackupPolicy, logger *slog.Logger) (*Backups, error) {
+	var target backupTarget
+	var name string
+	switch {
+	case uri.IsFileScheme():
+		target = fileBackupTarget{dir: filepath.Dir(uri.Path), compression: opts.Compression, envelope: opts.Envelope}
+		name = filepath.Base(uri.Path)
+	case uri.IsS3Scheme():
+		client, err := newS3Client(uri, token, opts, logger)
+		if err != nil {
+			return nil, err
+		}
+		dir := path.Dir(uri.S3Key())
+		if dir == "." {
+			dir = ""
+		} else {
+			dir += "/"
+		}
+		target = s3BackupTarget{client: client, prefix: dir}
+		name = path.Base(uri.S3Key())
+	default:
+		return nil, fmt.Errorf("storage scheme %s cannot hold backups (must be file or S3)", uri.Scheme)
+	}
+
+	ext := path.Ext(name)
+	return &Backups{
+		source: source,
+		target: target,
+		prefix: strings.TrimSuffix(name, ext) + "-",
+		ext:    ext,
+		policy: policy,
+		logger: logger,
+		stats:  BackupStats{URI: uri.String()},
+	}, nil
+}
+
+// Stats returns the snapshots so far
+func (b *Backups) Stats() BackupStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// Run writes a snapshot, then deletes the ones the policy no longer keeps.
+// Failing to delete them is logged, and retried with the next run.
+func (b *Backups) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(tracing.WithOperation(ctx, "backup"), backupTimeout)
+	defer cancel()
+
+	start := time.Now()
+	now := start.UTC()
+	name, err := b.snapshot(ctx, now)
+	if err != nil {
+		b.mu.Lock()
+		b.stats.Failures++
+		b.stats.LastError = err.Error()
+		b.stats.LastErrorAt = &now
+		b.mu.Unlock()
+		return err
+	}
+
+	kept, deleted, err := b.prune(ctx, now)
+	if err != nil {
+		b.logger.Warn("Failed to delete expired storage backups", "error", err)
+	}
+	b.mu.Lock()
+	b.stats.Backups++
+	b.stats.Snapshots = kept
+	b.stats.LastSuccess = &now
+	b.stats.LastSnapshot = name
+	b.stats.LastError = ""
+	b.stats.LastErrorAt = nil
+	b.mu.Unlock()
+	b.logger.Info("Storage backed up",
+		"snapshot", name,
+		"kept", kept,
+		"deleted", deleted,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// snapshot writes the data of the source as of now
+func (b *Backups) snapshot(ctx context.Context, now time.Time) (string, error) {
+	data, err := b.source.MarshalData()
+	if err != nil {
+		return "", fmt.Errorf("failed to export storage data: %w", err)
+	}
+	name := b.prefix + now.Format(backupTimeFormat) + b.ext
+	if err := b.target.put(ctx, name, data); err != nil {
+		return "", fmt.Errorf("failed to write backup %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// prune deletes the snapshots the policy does not keep, and returns how many
+// are kept and deleted
+func (b *Backups) prune(ctx context.Context, now time.Time) (kept, deleted int, err error) {
+	names, err := b.target.list(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	type snapshot struct {
+		name string
+		at   time.Time
+	}
+	var snapshots []snapshot
+	for _, name := range names {
+		// Other files next to the snapshots are left alone
+		if at, ok := b.snapshotTime(name); ok {
+			snapshots = append(snapshots, snapshot{name: name, at: at})
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].at.After(snapshots[j].at) })
+
+	var errs []error
+	for i, s := range snapshots {
+		expired := i > 0 &&
+			((b.policy.Keep > 0 && i >= b.policy.Keep) ||
+				(b.policy.MaxAge > 0 && now.Sub(s.at) > b.policy.MaxAge))
+		if !expired {
+			kept++
+			continue
+		}
+		if err := b.target.remove(ctx, s.name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete backup %s: %w", s.name, err))
+			kept++
+			continue
+		}
+		deleted++
+	}
+	return kept, deleted, errors.Join(errs...)
+}
+
+// snapshotTime returns the time a snapshot name was taken at, and false if
+// the name is not one of this job's snapshots
+func (b *Backups) snapshotTime(name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, b.prefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, b.ext)
+	if !ok {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(backupTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// Restore replaces the data of into with the snapshot name, and returns the
+// generation of the restored data. The restore is a write like any other:
+// sync clients and other instances see the records of the snapshot changed,
+// and those it lacks deleted, at a new generation. ErrBackupNotFound is
+// returned unless name is a snapshot of this job.
+func (b *Backups) Restore(ctx context.Context, name string, into Replacer) (uint64, error) {
+	ctx, cancel := context.WithTimeout(tracing.WithOperation(ctx, "restore"), backupTimeout)
+	defer cancel()
+
+	// Only the snapshots listed can be read, so name cannot reach elsewhere
+	if _, ok := b.snapshotTime(name); !ok {
+		return 0, ErrBackupNotFound
+	}
+	names, err := b.target.list(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list backups: %w", err)
+	}
+	if !slices.Contains(names, name) {
+		return 0, ErrBackupNotFound
+	}
+
+	raw, err := b.target.get(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read backup %s: %w", name, err)
+	}
+	restored, err := restoreData(ctx, raw, into)
+	if err != nil {
+		return 0, err
+	}
+	b.logger.Info("Storage restored from backup",
+		"snapshot", name,
+		"generation", restored.Sync.Generation,
+		"registry_count", len(restored.Registries))
+	return restored.Sync.Generation, nil
+}
+
+// restoreData replaces the data of into with raw, a prior state of it, at a
+// new generation: sync clients and other instances see the records of raw
+// changed, and those it lacks deleted. Returns the restored data.
+func restoreData(ctx context.Context, raw []byte, into Replacer) (*models.Storage, error) {
+	restored, _, err := loadStorage(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode restored data: %w", err)
+	}
+	currentRaw, err := into.MarshalData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export storage data: %w", err)
+	}
+	current, _, err := decodeStorage(currentRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode storage data: %w", err)
+	}
+	initSyncState(current)
+	rebaseSyncState(restored, current.Sync)
+
+	// The backend persists the data in its own codec
+	if raw, err = encodeStorage(CodecJSON, restored, false); err != nil {
+		return nil, fmt.Errorf("failed to encode restored data: %w", err)
+	}
+	if err := into.ReplaceData(ctx, raw); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// fileBackupTarget keeps snapshots in a local directory
+type fileBackupTarget struct {
+	dir         string
+	compression Compression
+	envelope    *Envelope // Encrypts the snapshots when set
+}
+
+func (t fileBackupTarget) put(ctx context.Context, name string, data []byte) error {
+	data, err := compressData(t.compression, data)
+	if err != nil {
+		return err
+	}
+	if data, err = t.envelope.Seal(data); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(t.dir, name), data)
+}
+
+func (t fileBackupTarget) get(ctx context.Context, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(t.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if data, err = t.envelope.Open(data); err != nil {
+		return nil, err
+	}
+	data, _, err = decompressData(data)
+	return data, err
+}
+
+func (t fileBackupTarget) list(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (t fileBackupTarget) remove(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(t.dir, name))
+}
+
+// s3BackupTarget keeps snapshots below a prefix of an S3 bucket
+type s3BackupTarget struct {
+	client *S3Client
+	prefix string // Empty or ending with a slash
+}
+
+func (t s3BackupTarget) put(ctx context.Context, name string, data []byte) error {
+	return t.client.UploadObject(ctx, t.prefix+name, data)
+}
+
+func (t s3BackupTarget) get(ctx context.Context, name string) ([]byte, error) {
+	return t.client.DownloadObject(ctx, t.prefix+name)
+}
+
+func (t s3BackupTarget) list(ctx context.Context) ([]string, error) {
+	keys, err := t.client.ListObjects(ctx, t.prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// Objects of nested prefixes are not snapshots
+		if name := strings.TrimPrefix(key, t.prefix); !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (t s3BackupTarget) remove(ctx context.Context, name string) error {
+	return t.client.RemoveObject(ctx, t.prefix+name)
+}
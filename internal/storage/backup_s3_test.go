@@ -0,0 +1,58 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestBackups_S3(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "bucket")
+	base := strings.Replace(s3.URL, "http://", "s3+http://", 1) + "/bucket/"
+	uri, err := storage.ParseStorageURI(base + "registry.json?region=us-east-1")
+	require.NoError(t, err)
+	opts := storage.Options{Retry: storage.RetryPolicy{MaxAttempts: 1}}
+	store, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", opts, newConformanceLogger())
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+
+	// An earlier snapshot, deleted once the new one is written
+	earlier, err := storage.ParseStorageURI(base + "backups/registry-2000-01-01T00-00-00Z.json?region=us-east-1")
+	require.NoError(t, err)
+	_, err = storage.NewS3Storage(earlier, "ACCESSKEY:SECRETKEY", opts, newConformanceLogger())
+	require.NoError(t, err)
+
+	backupURI, err := storage.ParseStorageURI(base + "backups/registry.json?region=us-east-1")
+	require.NoError(t, err)
+	backups, err := storage.NewBackups(store, backupURI, "ACCESSKEY:SECRETKEY", opts, storage.BackupPolicy{Keep: 1}, newConformanceLogger())
+	require.NoError(t, err)
+	require.NoError(t, backups.Run(ctx))
+
+	stats := backups.Stats()
+	assert.Equal(t, uint64(1), stats.Backups)
+	assert.Equal(t, 1, stats.Snapshots)
+	assert.True(t, strings.HasPrefix(stats.LastSnapshot, "registry-"), stats.LastSnapshot)
+
+	// The snapshot is a storage object of its own
+	snapshot, err := storage.ParseStorageURI(base + "backups/" + stats.LastSnapshot + "?region=us-east-1")
+	require.NoError(t, err)
+	restored, err := storage.NewS3Storage(snapshot, "ACCESSKEY:SECRETKEY", opts, newConformanceLogger())
+	require.NoError(t, err)
+	_, err = restored.GetRegistry(ctx, "tools")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"backups/" + stats.LastSnapshot, "registry.json"}, s3.Keys())
+
+	// Restoring reads the snapshot back
+	require.NoError(t, store.DeleteRegistry(ctx, "tools"))
+	_, err = backups.Restore(ctx, stats.LastSnapshot, store)
+	require.NoError(t, err)
+	_, err = store.GetRegistry(ctx, "tools")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackups_FileRetention(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStorage(filepath.Join(dir, "registry.json"), "", newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+
+	// Earlier snapshots, and files that are not snapshots
+	now := time.Now().UTC()
+	for _, age := range []time.Duration{time.Hour, 2 * time.Hour, 48 * time.Hour} {
+		name := "registry-" + now.Add(-age).Format(backupTimeFormat) + ".json"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "registry-notes.json"), []byte("{}"), 0644))
+
+	uri, err := ParseStorageURI("file://" + filepath.Join(dir, "registry.json"))
+	require.NoError(t, err)
+	backups, err := NewBackups(store, uri, "", Options{}, BackupPolicy{Keep: 3, MaxAge: 24 * time.Hour}, newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, backups.Run(ctx))
+
+	stats := backups.Stats()
+	assert.Equal(t, uint64(1), stats.Backups)
+	assert.Equal(t, 3, stats.Snapshots)
+	assert.NotNil(t, stats.LastSuccess)
+
+	// The oldest snapshot is past MaxAge; the newest holds the data
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Len(t, names, 5) // registry.json, registry-notes.json and 3 snapshots
+	assert.NotContains(t, names, "registry-"+now.Add(-48*time.Hour).Format(backupTimeFormat)+".json")
+	assert.Contains(t, names, "registry-notes.json")
+
+	raw, err := os.ReadFile(filepath.Join(dir, stats.LastSnapshot))
+	require.NoError(t, err)
+	data, _, err := decodeStorage(raw)
+	require.NoError(t, err)
+	assert.Contains(t, data.Registries, "tools")
+
+	// Keep: 1 leaves the newest snapshot only
+	backups.policy = BackupPolicy{Keep: 1}
+	require.NoError(t, backups.Run(ctx))
+	assert.Equal(t, 1, backups.Stats().Snapshots)
+}
+
+func TestBackups_Restore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStorage(filepath.Join(dir, "registry.json"), "", newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, nil)))
+
+	uri, err := ParseStorageURI("file://" + filepath.Join(dir, "registry.json"))
+	require.NoError(t, err)
+	backups, err := NewBackups(store, uri, "", Options{}, BackupPolicy{}, newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, backups.Run(ctx))
+	snapshot := backups.Stats().LastSnapshot
+
+	// Changes made after the snapshot
+	require.NoError(t, store.DeletePackage(ctx, "tools", "deployer"))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("linter", "", nil, nil)))
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)))
+	_, before, err := store.Changes(ctx, 0)
+	require.NoError(t, err)
+
+	for _, name := range []string{"registry-notes.json", "../" + snapshot, "registry-2000-01-01T00-00-00Z.json"} {
+		_, err := backups.Restore(ctx, name, store)
+		assert.ErrorIs(t, err, ErrBackupNotFound, name)
+	}
+
+	generation, err := backups.Restore(ctx, snapshot, store)
+	require.NoError(t, err)
+	assert.Equal(t, before+1, generation)
+	_, err = store.GetPackage(ctx, "tools", "deployer")
+	assert.NoError(t, err)
+	_, err = store.GetPackage(ctx, "tools", "linter")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.GetRegistry(ctx, "games")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Sync clients see the restore as a new generation
+	changes, current, err := store.Changes(ctx, before)
+	require.NoError(t, err)
+	assert.Equal(t, generation, current)
+	ops := make(map[string]string)
+	for _, c := range changes {
+		ops[c.Type+" "+c.Registry+"/"+c.Package] = c.Op
+	}
+	assert.Equal(t, models.SyncOpDelete, ops["registry games/"])
+	assert.Equal(t, models.SyncOpDelete, ops["package tools/linter"])
+	assert.Equal(t, models.SyncOpUpsert, ops["package tools/deployer"])
+
+	// The restore is persisted
+	reloaded, err := NewFileStorage(filepath.Join(dir, "registry.json"), "", newTestS3Logger())
+	require.NoError(t, err)
+	_, err = reloaded.GetPackage(ctx, "tools", "deployer")
+	assert.NoError(t, err)
+}
+
+func TestBackups_FileCompressed(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStorage(filepath.Join(dir, "registry.json"), "", newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+
+	uri, err := ParseStorageURI("file://" + filepath.Join(dir, "registry.json"))
+	require.NoError(t, err)
+	backups, err := NewBackups(store, uri, "", Options{Compression: CompressionZstd}, BackupPolicy{}, newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, backups.Run(ctx))
+	snapshot := backups.Stats().LastSnapshot
+
+	// Snapshots are compressed like S3, GCS and OCI data...
+	raw, err := os.ReadFile(filepath.Join(dir, snapshot))
+	require.NoError(t, err)
+	_, compression, err := decompressData(raw)
+	require.NoError(t, err)
+	assert.Equal(t, CompressionZstd, compression)
+
+	// ...and restored all the same
+	require.NoError(t, store.DeleteRegistry(ctx, "tools"))
+	_, err = backups.Restore(ctx, snapshot, store)
+	require.NoError(t, err)
+	_, err = store.GetRegistry(ctx, "tools")
+	assert.NoError(t, err)
+}
+
+func TestNewBackups_UnsupportedScheme(t *testing.T) {
+	uri, err := ParseStorageURI("sqlite://./data/registry.db")
+	require.NoError(t, err)
+	_, err = NewBackups(nil, uri, "", Options{}, BackupPolicy{}, newTestS3Logger())
+	assert.ErrorContains(t, err, "cannot hold backups")
+}
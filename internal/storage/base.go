@@ -2,9 +2,9 @@ package storage
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/criteo/command-launcher-registry/internal/models"
 )
@@ -13,16 +13,32 @@ import (
 // It handles locking, validation, and data manipulation. Concrete backends (FileStorage,
 // OCIStorage) embed this and provide their own persistence mechanisms.
 type BaseStorage struct {
-	mu     sync.RWMutex
-	data   *models.Storage
-	logger *slog.Logger
+	mu          sync.RWMutex
+	data        *models.Storage
+	customIndex customValueIndex
+	logger      *slog.Logger
+	codec       Codec // format of persisted data (JSON when empty)
+	prettyJSON  bool  // indent persisted JSON; compact otherwise
+
+	// Per-operation deadlines; zero leaves only the caller's deadline
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	cacheFile string    // local copy of remote data, see writeCache
+	envelope  *Envelope // encrypts the files written (storage and cache); nil writes plain data
+
+	writeBehind   *writeBehind  // nil persists each write, see setPersistMode
+	persistHealth persistHealth // outcome of the writes, see runPersist
 }
 
 // NewBaseStorage creates a new BaseStorage with empty data
 func NewBaseStorage(logger *slog.Logger) *BaseStorage {
+	data := models.NewStorage()
+	initSyncState(data)
 	return &BaseStorage{
-		data:   models.NewStorage(),
-		logger: logger,
+		data:        data,
+		customIndex: newCustomValueIndex(data),
+		logger:      logger,
 	}
 }
 
@@ -30,7 +46,10 @@ func NewBaseStorage(logger *slog.Logger) *BaseStorage {
 func (b *BaseStorage) SetData(data *models.Storage) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	initSyncState(data)
+	backfillTimestamps(data)
 	b.data = data
+	b.customIndex = newCustomValueIndex(data)
 }
 
 // GetData returns a copy of the current data (used by backends for persistence)
@@ -40,19 +59,20 @@ func (b *BaseStorage) GetData() *models.Storage {
 	return b.data
 }
 
-// MarshalData serializes the storage data to JSON.
+// MarshalData serializes the storage data with the configured codec.
 // NOTE: Caller must NOT hold the lock - this method acquires its own lock.
 // For use within locked contexts, use marshalDataLocked instead.
 func (b *BaseStorage) MarshalData() ([]byte, error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return json.MarshalIndent(b.data, "", "  ")
+	return b.marshalDataLocked()
 }
 
 // marshalDataLocked serializes data without acquiring lock.
+// JSON output is compact unless pretty JSON was requested.
 // Caller MUST hold at least a read lock.
 func (b *BaseStorage) marshalDataLocked() ([]byte, error) {
-	return json.MarshalIndent(b.data, "", "  ")
+	return encodeStorage(b.codec, b.data, b.prettyJSON)
 }
 
 // getDataLocked returns the data without acquiring lock.
@@ -61,50 +81,77 @@ func (b *BaseStorage) getDataLocked() *models.Storage {
 	return b.data
 }
 
-// UnmarshalData deserializes JSON data into storage
-func (b *BaseStorage) UnmarshalData(jsonData []byte) error {
-	var data models.Storage
-	if err := json.Unmarshal(jsonData, &data); err != nil {
+// UnmarshalData deserializes JSON or CBOR data into storage.
+// The codec is detected from the data, independent of the configured one.
+// Records that cannot be loaded are quarantined rather than failing the
+// load; only a document that cannot be read at all is an error.
+func (b *BaseStorage) UnmarshalData(raw []byte) error {
+	data, quarantined, err := loadStorage(raw)
+	if err != nil {
 		return err
 	}
-	// Initialize maps if nil
-	if data.Registries == nil {
-		data.Registries = make(map[string]*models.Registry)
-	}
+	b.setLoadedData(data, quarantined)
+	return nil
+}
+
+// setLoadedData installs data decoded by loadStorage, with the records it
+// quarantined
+func (b *BaseStorage) setLoadedData(data *models.Storage, quarantined []*models.QuarantinedRecord) {
+	initSyncState(data)
+	backfillTimestamps(data)
 	b.mu.Lock()
-	b.data = &data
+	b.data = data
+	b.dropQuarantinedLocked(quarantined)
+	b.customIndex = newCustomValueIndex(data)
+	b.discardPending()
 	b.mu.Unlock()
-	return nil
+
+	if len(quarantined) > 0 {
+		counts := models.CountQuarantined(quarantined)
+		b.logger.Warn("Quarantined invalid records, see GET /api/v1/admin/quarantine",
+			"registries", counts.Registries,
+			"packages", counts.Packages,
+			"versions", counts.Versions)
+		for _, record := range quarantined {
+			b.logger.Warn("Quarantined record", "key", record.Key, "reason", record.Reason)
+		}
+	}
 }
 
 // PersistFunc is a callback function that backends implement for persistence
-type PersistFunc func() error
+type PersistFunc func(ctx context.Context) error
 
 // CreateRegistry creates a new registry in memory.
 // The persist callback is called after the in-memory operation succeeds.
 // If persist fails, the in-memory change is rolled back.
 func (b *BaseStorage) CreateRegistry(ctx context.Context, r *models.Registry, persist PersistFunc) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, unlock, err := b.lock(ctx, "create_registry")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Check if already exists
 	if _, exists := b.data.Registries[r.Name]; exists {
 		return ErrAlreadyExists
 	}
 
-	// Add to storage
+	// Add to storage; timestamps are set by storage only
+	r.CreatedAt, r.UpdatedAt = nil, nil
 	b.data.Registries[r.Name] = r
+	undo := b.changeLocked(models.RecordKey(r.Name), false)
 
 	// Persist
 	if persist != nil {
-		if err := persist(); err != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
 			// Rollback in-memory change
+			undo()
 			delete(b.data.Registries, r.Name)
 			b.logger.Error("Storage write failed",
 				"operation", "create_registry",
 				"registry", r.Name,
 				"error", err)
-			return ErrStorageUnavailable
+			return persistError(ctx, err)
 		}
 	}
 
@@ -114,8 +161,11 @@ func (b *BaseStorage) CreateRegistry(ctx context.Context, r *models.Registry, pe
 
 // GetRegistry retrieves a registry by name
 func (b *BaseStorage) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	registry, exists := b.data.Registries[name]
 	if !exists {
@@ -128,8 +178,11 @@ func (b *BaseStorage) GetRegistry(ctx context.Context, name string) (*models.Reg
 // UpdateRegistry updates registry metadata.
 // The persist callback is called after the in-memory operation succeeds.
 func (b *BaseStorage) UpdateRegistry(ctx context.Context, r *models.Registry, persist PersistFunc) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, unlock, err := b.lock(ctx, "update_registry")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Check if exists
 	existing, exists := b.data.Registries[r.Name]
@@ -137,22 +190,25 @@ func (b *BaseStorage) UpdateRegistry(ctx context.Context, r *models.Registry, pe
 		return ErrNotFound
 	}
 
-	// Preserve packages
+	// Preserve packages and creation time
 	r.Packages = existing.Packages
+	r.CreatedAt = existing.CreatedAt
 
 	// Update in storage
 	b.data.Registries[r.Name] = r
+	undo := b.changeLocked(models.RecordKey(r.Name), false)
 
 	// Persist
 	if persist != nil {
-		if err := persist(); err != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
 			// Rollback
+			undo()
 			b.data.Registries[r.Name] = existing
 			b.logger.Error("Storage write failed",
 				"operation", "update_registry",
 				"registry", r.Name,
 				"error", err)
-			return ErrStorageUnavailable
+			return persistError(ctx, err)
 		}
 	}
 
@@ -163,8 +219,11 @@ func (b *BaseStorage) UpdateRegistry(ctx context.Context, r *models.Registry, pe
 // DeleteRegistry deletes a registry and all its packages.
 // The persist callback is called after the in-memory operation succeeds.
 func (b *BaseStorage) DeleteRegistry(ctx context.Context, name string, persist PersistFunc) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, unlock, err := b.lock(ctx, "delete_registry")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Check if exists
 	registry, exists := b.data.Registries[name]
@@ -174,20 +233,28 @@ func (b *BaseStorage) DeleteRegistry(ctx context.Context, name string, persist P
 
 	// Delete from storage (in-memory)
 	delete(b.data.Registries, name)
+	undo := b.changeLocked(models.RecordKey(name), true)
+	undoHistory := b.dropHistoryLocked(name)
+	undoKeys := b.dropAPIKeysLocked(name)
 
 	// Persist
 	if persist != nil {
-		if err := persist(); err != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
 			// Rollback
+			undoKeys()
+			undoHistory()
+			undo()
 			b.data.Registries[name] = registry
 			b.logger.Error("Storage write failed",
 				"operation", "delete_registry",
 				"registry", name,
 				"error", err)
-			return ErrStorageUnavailable
+			return persistError(ctx, err)
 		}
 	}
 
+	delete(b.customIndex, name)
+
 	b.logger.Info("Registry deleted",
 		"registry", name,
 		"packages_deleted", len(registry.Packages))
@@ -196,8 +263,11 @@ func (b *BaseStorage) DeleteRegistry(ctx context.Context, name string, persist P
 
 // ListRegistries returns all registries
 func (b *BaseStorage) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	registries := make([]*models.Registry, 0, len(b.data.Registries))
 	for _, r := range b.data.Registries {
@@ -210,8 +280,11 @@ func (b *BaseStorage) ListRegistries(ctx context.Context) ([]*models.Registry, e
 // CreatePackage creates a new package in a registry.
 // The persist callback is called after the in-memory operation succeeds.
 func (b *BaseStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package, persist PersistFunc) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, unlock, err := b.lock(ctx, "create_package")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Get registry
 	registry, exists := b.data.Registries[registryName]
@@ -224,23 +297,30 @@ func (b *BaseStorage) CreatePackage(ctx context.Context, registryName string, p
 		return ErrAlreadyExists
 	}
 
-	// Add package
+	// Add package; timestamps are set by storage only
+	p.CreatedAt, p.UpdatedAt = nil, nil
 	registry.Packages[p.Name] = p
+	undo := b.changeLocked(models.RecordKey(registryName, p.Name), false)
+	undoHistory := b.recordLocked(ctx, registryName, p.Name, models.ChangeRecord{Type: models.ChangePackageCreated})
 
 	// Persist
 	if persist != nil {
-		if err := persist(); err != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
 			// Rollback
+			undoHistory()
+			undo()
 			delete(registry.Packages, p.Name)
 			b.logger.Error("Storage write failed",
 				"operation", "create_package",
 				"registry", registryName,
 				"package", p.Name,
 				"error", err)
-			return ErrStorageUnavailable
+			return persistError(ctx, err)
 		}
 	}
 
+	b.customIndex.add(registryName, p)
+
 	b.logger.Info("Package created",
 		"registry", registryName,
 		"package", p.Name)
@@ -249,8 +329,11 @@ func (b *BaseStorage) CreatePackage(ctx context.Context, registryName string, p
 
 // GetPackage retrieves a package from a registry
 func (b *BaseStorage) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	registry, exists := b.data.Registries[registryName]
 	if !exists {
@@ -268,8 +351,11 @@ func (b *BaseStorage) GetPackage(ctx context.Context, registryName, packageName
 // UpdatePackage updates package metadata (preserves versions).
 // The persist callback is called after the in-memory operation succeeds.
 func (b *BaseStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package, persist PersistFunc) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, unlock, err := b.lock(ctx, "update_package")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Get registry
 	registry, exists := b.data.Registries[registryName]
@@ -283,23 +369,34 @@ func (b *BaseStorage) UpdatePackage(ctx context.Context, registryName string, p
 		return ErrNotFound
 	}
 
-	// Update package
+	// Update package, preserving its creation time
+	p.CreatedAt = oldPackage.CreatedAt
 	registry.Packages[p.Name] = p
+	undo := b.changeLocked(models.RecordKey(registryName, p.Name), false)
+	undoHistory := func() {}
+	if changes := models.DiffPackage(oldPackage, p, registry.SensitiveKeys); len(changes) > 0 {
+		undoHistory = b.recordLocked(ctx, registryName, p.Name, models.ChangeRecord{Type: models.ChangePackageUpdated, Changes: changes})
+	}
 
 	// Persist
 	if persist != nil {
-		if err := persist(); err != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
 			// Rollback
+			undoHistory()
+			undo()
 			registry.Packages[p.Name] = oldPackage
 			b.logger.Error("Storage write failed",
 				"operation", "update_package",
 				"registry", registryName,
 				"package", p.Name,
 				"error", err)
-			return ErrStorageUnavailable
+			return persistError(ctx, err)
 		}
 	}
 
+	b.customIndex.remove(registryName, oldPackage)
+	b.customIndex.add(registryName, p)
+
 	b.logger.Info("Package updated",
 		"registry", registryName,
 		"package", p.Name)
@@ -307,11 +404,83 @@ func (b *BaseStorage) UpdatePackage(ctx context.Context, registryName string, p
 	return nil
 }
 
+// UpdatePackages replaces several packages of a registry with a single
+// persist. Either every package is updated or none is: a missing package
+// fails the whole batch with ErrNotFound.
+func (b *BaseStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package, persist PersistFunc) error {
+	ctx, unlock, err := b.lock(ctx, "update_packages")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Get registry
+	registry, exists := b.data.Registries[registryName]
+	if !exists {
+		return ErrNotFound
+	}
+
+	// Check every package exists before changing anything
+	oldPackages := make([]*models.Package, len(packages))
+	for i, p := range packages {
+		old, exists := registry.Packages[p.Name]
+		if !exists {
+			return ErrNotFound
+		}
+		oldPackages[i] = old
+	}
+
+	// Update packages
+	undos := make([]func(), len(packages))
+	undoHistories := make([]func(), len(packages))
+	for i, p := range packages {
+		p.CreatedAt = oldPackages[i].CreatedAt
+		registry.Packages[p.Name] = p
+		undos[i] = b.changeLocked(models.RecordKey(registryName, p.Name), false)
+		undoHistories[i] = func() {}
+		if changes := models.DiffPackage(oldPackages[i], p, registry.SensitiveKeys); len(changes) > 0 {
+			undoHistories[i] = b.recordLocked(ctx, registryName, p.Name, models.ChangeRecord{Type: models.ChangePackageUpdated, Changes: changes})
+		}
+	}
+
+	// Persist
+	if persist != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
+			// Rollback in reverse order, so generations are restored correctly
+			for i := len(packages) - 1; i >= 0; i-- {
+				undoHistories[i]()
+				undos[i]()
+				registry.Packages[packages[i].Name] = oldPackages[i]
+			}
+			b.logger.Error("Storage write failed",
+				"operation", "update_packages",
+				"registry", registryName,
+				"count", len(packages),
+				"error", err)
+			return persistError(ctx, err)
+		}
+	}
+
+	for i, p := range packages {
+		b.customIndex.remove(registryName, oldPackages[i])
+		b.customIndex.add(registryName, p)
+	}
+
+	b.logger.Info("Packages updated",
+		"registry", registryName,
+		"count", len(packages))
+
+	return nil
+}
+
 // DeletePackage deletes a package and all its versions.
 // The persist callback is called after the in-memory operation succeeds.
 func (b *BaseStorage) DeletePackage(ctx context.Context, registryName, packageName string, persist PersistFunc) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, unlock, err := b.lock(ctx, "delete_package")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Get registry
 	registry, exists := b.data.Registries[registryName]
@@ -327,21 +496,27 @@ func (b *BaseStorage) DeletePackage(ctx context.Context, registryName, packageNa
 
 	// Delete package
 	delete(registry.Packages, packageName)
+	undo := b.changeLocked(models.RecordKey(registryName, packageName), true)
+	undoHistory := b.recordLocked(ctx, registryName, packageName, models.ChangeRecord{Type: models.ChangePackageDeleted})
 
 	// Persist
 	if persist != nil {
-		if err := persist(); err != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
 			// Rollback
+			undoHistory()
+			undo()
 			registry.Packages[packageName] = pkg
 			b.logger.Error("Storage write failed",
 				"operation", "delete_package",
 				"registry", registryName,
 				"package", packageName,
 				"error", err)
-			return ErrStorageUnavailable
+			return persistError(ctx, err)
 		}
 	}
 
+	b.customIndex.remove(registryName, pkg)
+
 	b.logger.Info("Package deleted",
 		"registry", registryName,
 		"package", packageName,
@@ -351,8 +526,11 @@ func (b *BaseStorage) DeletePackage(ctx context.Context, registryName, packageNa
 
 // ListPackages returns all packages in a registry
 func (b *BaseStorage) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	registry, exists := b.data.Registries[registryName]
 	if !exists {
@@ -371,8 +549,11 @@ func (b *BaseStorage) ListPackages(ctx context.Context, registryName string) ([]
 // Enforces immutability and partition overlap validation.
 // The persist callback is called after the in-memory operation succeeds.
 func (b *BaseStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version, persist PersistFunc) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, unlock, err := b.lock(ctx, "create_version")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Get registry
 	registry, exists := b.data.Registries[registryName]
@@ -403,11 +584,19 @@ func (b *BaseStorage) CreateVersion(ctx context.Context, registryName, packageNa
 
 	// Add version
 	pkg.Versions[v.Version] = v
+	undo := b.changeLocked(models.RecordKey(registryName, packageName, v.Version), false)
+	changeType := models.ChangeVersionPublished
+	if v.IsPending(time.Now()) {
+		changeType = models.ChangeVersionScheduled
+	}
+	undoHistory := b.recordLocked(ctx, registryName, packageName, models.ChangeRecord{Type: changeType, Version: v.Version})
 
 	// Persist
 	if persist != nil {
-		if err := persist(); err != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
 			// Rollback
+			undoHistory()
+			undo()
 			delete(pkg.Versions, v.Version)
 			b.logger.Error("Storage write failed",
 				"operation", "create_version",
@@ -415,7 +604,7 @@ func (b *BaseStorage) CreateVersion(ctx context.Context, registryName, packageNa
 				"package", packageName,
 				"version", v.Version,
 				"error", err)
-			return ErrStorageUnavailable
+			return persistError(ctx, err)
 		}
 	}
 
@@ -428,8 +617,11 @@ func (b *BaseStorage) CreateVersion(ctx context.Context, registryName, packageNa
 
 // GetVersion retrieves a specific version
 func (b *BaseStorage) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	registry, exists := b.data.Registries[registryName]
 	if !exists {
@@ -452,8 +644,11 @@ func (b *BaseStorage) GetVersion(ctx context.Context, registryName, packageName,
 // DeleteVersion deletes a specific version.
 // The persist callback is called after the in-memory operation succeeds.
 func (b *BaseStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string, persist PersistFunc) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	ctx, unlock, err := b.lock(ctx, "delete_version")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Get registry
 	registry, exists := b.data.Registries[registryName]
@@ -475,11 +670,15 @@ func (b *BaseStorage) DeleteVersion(ctx context.Context, registryName, packageNa
 
 	// Delete version
 	delete(pkg.Versions, version)
+	undo := b.changeLocked(models.RecordKey(registryName, packageName, version), true)
+	undoHistory := b.recordLocked(ctx, registryName, packageName, models.ChangeRecord{Type: models.ChangeVersionDeleted, Version: version})
 
 	// Persist
 	if persist != nil {
-		if err := persist(); err != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
 			// Rollback
+			undoHistory()
+			undo()
 			pkg.Versions[version] = ver
 			b.logger.Error("Storage write failed",
 				"operation", "delete_version",
@@ -487,7 +686,7 @@ func (b *BaseStorage) DeleteVersion(ctx context.Context, registryName, packageNa
 				"package", packageName,
 				"version", version,
 				"error", err)
-			return ErrStorageUnavailable
+			return persistError(ctx, err)
 		}
 	}
 
@@ -500,8 +699,11 @@ func (b *BaseStorage) DeleteVersion(ctx context.Context, registryName, packageNa
 
 // ListVersions returns all versions for a package
 func (b *BaseStorage) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	registry, exists := b.data.Registries[registryName]
 	if !exists {
@@ -523,21 +725,17 @@ func (b *BaseStorage) ListVersions(ctx context.Context, registryName, packageNam
 
 // GetRegistryIndex generates the registry index (Command Launcher format)
 func (b *BaseStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	registry, exists := b.data.Registries[registryName]
 	if !exists {
 		return nil, ErrNotFound
 	}
 
-	// Flatten all package versions into index entries
-	var entries []models.IndexEntry
-	for _, pkg := range registry.Packages {
-		for _, ver := range pkg.Versions {
-			entries = append(entries, ver.ToIndexEntry())
-		}
-	}
-
-	return entries, nil
+	// Flatten all package versions into index entries, leaving out embargoed ones
+	return registry.Index(time.Now()), nil
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/criteo/command-launcher-registry/internal/models"
@@ -70,6 +72,65 @@ func TestBaseStorage_MarshalUnmarshalData(t *testing.T) {
 	assert.Equal(t, "test-reg", data.Registries["test-reg"].Name)
 }
 
+func TestBaseStorage_UnmarshalData_Partial(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	// Hand-edited or older data may leave out maps or hold null entries
+	raw := `{"registries": {"tools": {"name": "tools", "packages": {"hello": {"name": "hello"}, "gone": null}}, "empty": {}}}`
+	require.NoError(t, bs.UnmarshalData([]byte(raw)))
+
+	_, err := bs.GetPackage(ctx, "tools", "gone")
+	assert.Equal(t, ErrNotFound, err)
+
+	v := models.NewVersion("hello", "1.0.0", "sha256:"+strings.Repeat("a", 64), "https://example.com/hello.zip", 0, 9)
+	require.NoError(t, bs.CreateVersion(ctx, "tools", "hello", v, nil))
+
+	require.NoError(t, bs.CreatePackage(ctx, "empty", models.NewPackage("first", "", nil, nil), nil))
+	registry, err := bs.GetRegistry(ctx, "empty")
+	require.NoError(t, err)
+	assert.Equal(t, "empty", registry.Name)
+}
+
+func TestBaseStorage_MarshalDataCompactByDefault(t *testing.T) {
+	bs := newTestBaseStorage()
+	require.NoError(t, bs.CreateRegistry(context.Background(), models.NewRegistry("test-reg", "Test Registry", nil, nil), nil))
+
+	compact, err := bs.MarshalData()
+	require.NoError(t, err)
+	assert.NotContains(t, string(compact), "\n")
+
+	bs.prettyJSON = true
+	pretty, err := bs.MarshalData()
+	require.NoError(t, err)
+	assert.Contains(t, string(pretty), "\n  \"registries\"")
+	assert.Less(t, len(compact), len(pretty))
+}
+
+func TestNewStorage_FilePrettyJSON(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	for _, pretty := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "registry.json")
+		uri, err := ParseStorageURI("file://" + path)
+		require.NoError(t, err)
+
+		store, err := NewStorage(uri, "", Options{PrettyJSON: pretty}, logger)
+		require.NoError(t, err)
+		require.NoError(t, store.CreateRegistry(context.Background(), models.NewRegistry("test-reg", "Test Registry", nil, nil)))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, pretty, strings.Contains(string(data), "\n  "), "pretty=%v", pretty)
+
+		// Either encoding loads back
+		reopened, err := NewFileStorage(path, "", logger)
+		require.NoError(t, err)
+		_, err = reopened.GetRegistry(context.Background(), "test-reg")
+		assert.NoError(t, err)
+	}
+}
+
 func TestBaseStorage_CreateRegistry(t *testing.T) {
 	bs := newTestBaseStorage()
 	ctx := context.Background()
@@ -103,7 +164,7 @@ func TestBaseStorage_CreateRegistry_WithPersistCallback(t *testing.T) {
 	ctx := context.Background()
 
 	persistCalled := false
-	persistFunc := func() error {
+	persistFunc := func(context.Context) error {
 		persistCalled = true
 		return nil
 	}
@@ -118,7 +179,7 @@ func TestBaseStorage_CreateRegistry_PersistFailure_Rollback(t *testing.T) {
 	bs := newTestBaseStorage()
 	ctx := context.Background()
 
-	persistFunc := func() error {
+	persistFunc := func(context.Context) error {
 		return assert.AnError
 	}
 
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// BatchUpdatePackages applies patch to the packages of a registry matching
+// filter and writes the changed ones with a single UpdatePackages call, so
+// the batch is persisted once and either fully applied or not at all.
+// Packages the patch leaves unchanged are skipped. With dryRun nothing is
+// written. It returns the number of matching packages and the patched
+// packages sorted by name.
+func BatchUpdatePackages(ctx context.Context, store Store, registryName string, filter models.PackageFilter, patch models.PackagePatch, dryRun bool) (int, []*models.Package, error) {
+	// Narrow down with the custom value index, then apply the other criteria
+	candidates, err := store.FindPackages(ctx, registryName, filter.CustomValues)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	matched := 0
+	changed := make([]*models.Package, 0)
+	for _, pkg := range candidates {
+		if !filter.Matches(pkg) {
+			continue
+		}
+		matched++
+
+		patched, ok := patch.Apply(pkg)
+		if !ok {
+			continue
+		}
+		if err := models.ValidatePackage(patched); err != nil {
+			var verr *models.ValidationError
+			if errors.As(err, &verr) {
+				return 0, nil, &models.ValidationError{
+					Field:   verr.Field,
+					Message: fmt.Sprintf("package '%s': %s", pkg.Name, verr.Message),
+				}
+			}
+			return 0, nil, err
+		}
+		changed = append(changed, patched)
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i].Name < changed[j].Name
+	})
+
+	if dryRun || len(changed) == 0 {
+		return matched, changed, nil
+	}
+	if err := store.UpdatePackages(ctx, registryName, changed); err != nil {
+		return 0, nil, err
+	}
+	return matched, changed, nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBatchStorage(t *testing.T) *FileStorage {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, fs.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	for _, p := range []struct{ name, team string }{{"deploy", "infra"}, {"lint", "infra"}, {"billing", "payments"}} {
+		require.NoError(t, fs.CreatePackage(ctx, "tools", &models.Package{
+			Name:         p.name,
+			Maintainers:  []string{p.team + "@example.com"},
+			CustomValues: map[string]string{"team": p.team},
+			Versions:     map[string]*models.Version{},
+		}))
+	}
+	return fs
+}
+
+func TestBatchUpdatePackages(t *testing.T) {
+	fs := newTestBatchStorage(t)
+	ctx := context.Background()
+	_, before, err := fs.Changes(ctx, 0)
+	require.NoError(t, err)
+
+	filter := models.PackageFilter{CustomValues: models.CustomValueQuery{"team": {"infra"}}}
+	patch := models.PackagePatch{
+		AddMaintainers:    []string{"platform@example.com"},
+		RemoveMaintainers: []string{"infra@example.com"},
+		SetCustomValues:   map[string]string{"team": "platform"},
+	}
+
+	matched, changed, err := BatchUpdatePackages(ctx, fs, "tools", filter, patch, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, matched)
+	require.Len(t, changed, 2)
+	assert.Equal(t, "deploy", changed[0].Name)
+	assert.Equal(t, "lint", changed[1].Name)
+
+	pkg, err := fs.GetPackage(ctx, "tools", "lint")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"platform@example.com"}, pkg.Maintainers)
+	assert.Equal(t, "platform", pkg.CustomValues["team"])
+
+	// The custom value index follows the update
+	found, err := fs.FindPackages(ctx, "tools", models.CustomValueQuery{"team": {"infra"}})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	_, after, err := fs.Changes(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, before+2, after)
+
+	// Running again matches nothing left to change
+	matched, changed, err = BatchUpdatePackages(ctx, fs, "tools", models.PackageFilter{Maintainer: "platform@example.com"}, patch, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, matched)
+	assert.Empty(t, changed)
+}
+
+func TestBatchUpdatePackages_DryRun(t *testing.T) {
+	fs := newTestBatchStorage(t)
+	ctx := context.Background()
+
+	patch := models.PackagePatch{SetCustomValues: map[string]string{"owner": "finance"}}
+	matched, changed, err := BatchUpdatePackages(ctx, fs, "tools", models.PackageFilter{Names: []string{"billing"}}, patch, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, matched)
+	require.Len(t, changed, 1)
+	assert.Equal(t, "finance", changed[0].CustomValues["owner"])
+
+	pkg, err := fs.GetPackage(ctx, "tools", "billing")
+	require.NoError(t, err)
+	assert.NotContains(t, pkg.CustomValues, "owner")
+}
+
+func TestBatchUpdatePackages_Errors(t *testing.T) {
+	fs := newTestBatchStorage(t)
+	ctx := context.Background()
+	patch := models.PackagePatch{SetCustomValues: map[string]string{"owner": "finance"}}
+
+	_, _, err := BatchUpdatePackages(ctx, fs, "missing", models.PackageFilter{}, patch, false)
+	assert.Equal(t, ErrNotFound, err)
+
+	// A package ending up with too many custom values fails the whole batch
+	full := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		full["key"+strconv.Itoa(i)] = "x"
+	}
+	pkg, err := fs.GetPackage(ctx, "tools", "lint")
+	require.NoError(t, err)
+	updated := *pkg
+	updated.CustomValues = full
+	require.NoError(t, fs.UpdatePackage(ctx, "tools", &updated))
+
+	_, _, err = BatchUpdatePackages(ctx, fs, "tools", models.PackageFilter{}, patch, false)
+	var verr *models.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Contains(t, verr.Message, "package 'lint'")
+
+	billing, err := fs.GetPackage(ctx, "tools", "billing")
+	require.NoError(t, err)
+	assert.NotContains(t, billing.CustomValues, "owner")
+}
+
+func TestBaseStorage_UpdatePackages_PersistFailure_Rollback(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil), nil))
+	for _, name := range []string{"deploy", "lint"} {
+		require.NoError(t, bs.CreatePackage(ctx, "tools", &models.Package{Name: name, Versions: map[string]*models.Version{}}, nil))
+	}
+	_, before, err := bs.Changes(ctx, 0)
+	require.NoError(t, err)
+
+	err = bs.UpdatePackages(ctx, "tools", []*models.Package{
+		{Name: "deploy", Description: "changed"},
+		{Name: "lint", Description: "changed"},
+	}, func(context.Context) error { return assert.AnError })
+	assert.ErrorIs(t, err, ErrStorageUnavailable)
+
+	pkg, err := bs.GetPackage(ctx, "tools", "deploy")
+	require.NoError(t, err)
+	assert.Empty(t, pkg.Description)
+	_, after, err := bs.Changes(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	// A missing package fails the batch before anything changes
+	err = bs.UpdatePackages(ctx, "tools", []*models.Package{
+		{Name: "deploy", Description: "changed"},
+		{Name: "missing"},
+	}, nil)
+	assert.Equal(t, ErrNotFound, err)
+	pkg, err = bs.GetPackage(ctx, "tools", "deploy")
+	require.NoError(t, err)
+	assert.Empty(t, pkg.Description)
+}
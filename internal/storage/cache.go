@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// ErrReadOnly is returned by writes to a store serving a local cache while
+// its backend is unavailable
+var ErrReadOnly = errors.New("storage is read-only")
+
+// writeCache saves a copy of the data last loaded from or pushed to a remote
+// backend, for OpenCache to serve when the backend is unavailable at boot.
+// A cache that cannot be written is logged, never returned: the backend
+// remains the source of truth.
+func (b *BaseStorage) writeCache(data []byte) {
+	if b.cacheFile == "" {
+		return
+	}
+	data, err := b.envelope.Seal(data)
+	if err == nil {
+		err = writeFileAtomic(b.cacheFile, data)
+	}
+	if err != nil {
+		b.logger.Warn("Failed to write storage cache file",
+			"cache_file", b.cacheFile,
+			"error", err)
+	}
+}
+
+// writeFileAtomic replaces path with data (temp file + rename), so readers
+// never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tempFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // No-op once renamed
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return os.Rename(tempPath, path)
+}
+
+// CacheStorage serves the local copy of a remote backend's data, read-only.
+// It stands in for the backend when the server starts while the backend is
+// unavailable and storage.fail_fast_on_degraded is off: reads are served as
+// of the last successful load or write, and writes return ErrReadOnly.
+type CacheStorage struct {
+	*BaseStorage
+}
+
+// OpenCache loads the cache file written by an S3 or OCI backend
+// (Options.CacheFile)
+func OpenCache(path string, opts Options, logger *slog.Logger) (*CacheStorage, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage cache file: %w", err)
+	}
+
+	c := &CacheStorage{
+		BaseStorage: NewBaseStorage(logger),
+	}
+	c.readTimeout = opts.ReadTimeout
+	if raw, err = opts.Envelope.Open(raw); err != nil {
+		return nil, fmt.Errorf("failed to decrypt storage cache file: %w", err)
+	}
+	if err := c.UnmarshalData(raw); err != nil {
+		return nil, fmt.Errorf("failed to parse storage cache file (invalid JSON or CBOR): %w", err)
+	}
+
+	info, _ := os.Stat(path)
+	logger.Warn("Serving storage cache file read-only",
+		"cache_file", path,
+		"cached_at", info.ModTime().UTC(),
+		"registry_count", len(c.GetData().Registries))
+	return c, nil
+}
+
+// CreateRegistry is rejected: the cache is read-only
+func (c *CacheStorage) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return ErrReadOnly
+}
+
+// UpdateRegistry is rejected: the cache is read-only
+func (c *CacheStorage) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return ErrReadOnly
+}
+
+// DeleteRegistry is rejected: the cache is read-only
+func (c *CacheStorage) DeleteRegistry(ctx context.Context, name string) error {
+	return ErrReadOnly
+}
+
+// CreatePackage is rejected: the cache is read-only
+func (c *CacheStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return ErrReadOnly
+}
+
+// UpdatePackage is rejected: the cache is read-only
+func (c *CacheStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return ErrReadOnly
+}
+
+// UpdatePackages is rejected: the cache is read-only
+func (c *CacheStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return ErrReadOnly
+}
+
+// DeletePackage is rejected: the cache is read-only
+func (c *CacheStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return ErrReadOnly
+}
+
+// CreateVersion is rejected: the cache is read-only
+func (c *CacheStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return ErrReadOnly
+}
+
+// DeleteVersion is rejected: the cache is read-only
+func (c *CacheStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return ErrReadOnly
+}
+
+// ReleaseVersion is rejected: the cache is read-only
+func (c *CacheStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return ErrReadOnly
+}
+
+// CancelVersion is rejected: the cache is read-only
+func (c *CacheStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return ErrReadOnly
+}
+
+// PutCertificate is rejected: the cache is read-only
+func (c *CacheStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return ErrReadOnly
+}
+
+// DeleteCertificate is rejected: the cache is read-only
+func (c *CacheStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return ErrReadOnly
+}
+
+// CreateAPIKey is rejected: the cache is read-only
+func (c *CacheStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return ErrReadOnly
+}
+
+// DeleteAPIKey is rejected: the cache is read-only
+func (c *CacheStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return ErrReadOnly
+}
+
+// Close closes the storage (no-op, the cache file is left as is)
+func (c *CacheStorage) Close() error {
+	return nil
+}
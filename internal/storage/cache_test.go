@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestOpenCache_ReadOnly(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache", "registry.cache")
+
+	// A backend writes its data to the cache file
+	bs := newTestBaseStorage()
+	bs.cacheFile = path
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("tools", "Build tools", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "tools", models.NewPackage("deployer", "Deploys things", nil, nil), nil))
+	data, err := bs.MarshalData()
+	require.NoError(t, err)
+	bs.writeCache(data)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temp file left behind")
+
+	cache, err := OpenCache(path, Options{}, logger)
+	require.NoError(t, err)
+	var _ Store = cache
+
+	// Reads are served from the cache
+	pkg, err := cache.GetPackage(ctx, "tools", "deployer")
+	require.NoError(t, err)
+	assert.Equal(t, "Deploys things", pkg.Description)
+
+	// Writes are rejected
+	assert.ErrorIs(t, cache.CreateRegistry(ctx, models.NewRegistry("infra", "", nil, nil)), ErrReadOnly)
+	assert.ErrorIs(t, cache.DeletePackage(ctx, "tools", "deployer"), ErrReadOnly)
+	_, err = cache.GetRegistry(ctx, "infra")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = OpenCache(filepath.Join(dir, "missing"), Options{}, logger)
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+)
+
+// GetCertificate returns a stored TLS certificate or ACME key by name
+func (b *BaseStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	data, exists := b.data.Certificates[name]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(data), nil
+}
+
+// PutCertificate stores a TLS certificate or ACME key, replacing any
+// previous one of the same name. Certificates are not registry records:
+// they are neither synced nor part of any history.
+func (b *BaseStorage) PutCertificate(ctx context.Context, name string, data []byte, persist PersistFunc) error {
+	ctx, unlock, err := b.lock(ctx, "put_certificate")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if b.data.Certificates == nil {
+		b.data.Certificates = make(map[string][]byte)
+	}
+	old, existed := b.data.Certificates[name]
+	b.data.Certificates[name] = bytes.Clone(data)
+
+	if persist != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
+			// Rollback
+			if existed {
+				b.data.Certificates[name] = old
+			} else {
+				delete(b.data.Certificates, name)
+			}
+			b.logger.Error("Storage write failed",
+				"operation", "put_certificate",
+				"name", name,
+				"error", err)
+			return persistError(ctx, err)
+		}
+	}
+
+	b.logger.Info("Certificate stored", "name", name)
+	return nil
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (b *BaseStorage) DeleteCertificate(ctx context.Context, name string, persist PersistFunc) error {
+	ctx, unlock, err := b.lock(ctx, "delete_certificate")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	old, exists := b.data.Certificates[name]
+	if !exists {
+		return ErrNotFound
+	}
+	delete(b.data.Certificates, name)
+
+	if persist != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
+			// Rollback
+			b.data.Certificates[name] = old
+			b.logger.Error("Storage write failed",
+				"operation", "delete_certificate",
+				"name", name,
+				"error", err)
+			return persistError(ctx, err)
+		}
+	}
+
+	b.logger.Info("Certificate deleted", "name", name)
+	return nil
+}
@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// NewRedisClient connects to the Redis server at url (redis:// or rediss://)
+func NewRedisClient(url string) (*redis.Client, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return client, nil
+}
+
+// ChangeFeed broadcasts the data generation (see Store.Changes) reached by
+// writes to every instance sharing a backend, over a Redis channel. The
+// newest generation is also kept in a key, for instances starting later.
+type ChangeFeed struct {
+	client     *redis.Client
+	prefix     string
+	logger     *slog.Logger
+	generation atomic.Uint64 // Newest generation published
+
+	mu       sync.Mutex
+	handlers []func(generation uint64)
+
+	subscription *redis.PubSub
+	stop         chan struct{}
+	done         sync.WaitGroup
+}
+
+// NewChangeFeed subscribes to the channel of prefix. The generation of
+// store is the starting point when nothing was published yet.
+func NewChangeFeed(client *redis.Client, prefix string, store Store, logger *slog.Logger) (*ChangeFeed, error) {
+	f := &ChangeFeed{
+		client: client,
+		prefix: prefix,
+		logger: logger,
+		stop:   make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Subscribe before reading the generation, so no change is missed
+	f.subscription = client.Subscribe(ctx, f.channel())
+	if _, err := f.subscription.Receive(ctx); err != nil {
+		f.subscription.Close()
+		return nil, fmt.Errorf("failed to subscribe to Redis channel: %w", err)
+	}
+	published, err := client.Get(ctx, f.generationKey()).Uint64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		f.subscription.Close()
+		return nil, fmt.Errorf("failed to read published generation: %w", err)
+	}
+	local, err := storeGeneration(ctx, store)
+	if err != nil {
+		f.subscription.Close()
+		return nil, err
+	}
+	f.observe(max(published, local))
+
+	f.done.Add(1)
+	go f.listen()
+	return f, nil
+}
+
+// storeGeneration returns the current data generation of a store
+func storeGeneration(ctx context.Context, store Store) (uint64, error) {
+	// No change is newer than the largest generation: only the current
+	// generation is returned, along with ErrGenerationAhead
+	_, generation, err := store.Changes(ctx, math.MaxUint64)
+	if err != nil && !errors.Is(err, ErrGenerationAhead) {
+		return 0, err
+	}
+	return generation, nil
+}
+
+func (f *ChangeFeed) channel() string       { return f.prefix + ":changes" }
+func (f *ChangeFeed) generationKey() string { return f.prefix + ":generation" }
+
+// Generation returns the newest generation published
+func (f *ChangeFeed) Generation() uint64 {
+	return f.generation.Load()
+}
+
+// OnChange registers fn, called with each newer generation published by
+// any instance, this one included
+func (f *ChangeFeed) OnChange(fn func(generation uint64)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = append(f.handlers, fn)
+}
+
+// observe records a published generation and notifies the handlers,
+// ignoring generations already seen
+func (f *ChangeFeed) observe(generation uint64) {
+	for {
+		current := f.generation.Load()
+		if generation <= current {
+			return
+		}
+		if f.generation.CompareAndSwap(current, generation) {
+			break
+		}
+	}
+	f.mu.Lock()
+	handlers := f.handlers
+	f.mu.Unlock()
+	for _, fn := range handlers {
+		fn(generation)
+	}
+}
+
+// listen follows the generations published until Close
+func (f *ChangeFeed) listen() {
+	defer f.done.Done()
+	for msg := range f.subscription.Channel() {
+		generation, err := strconv.ParseUint(msg.Payload, 10, 64)
+		if err != nil {
+			f.logger.Warn("Ignoring invalid change message", "payload", msg.Payload)
+			continue
+		}
+		f.observe(generation)
+	}
+}
+
+// Publish stores and broadcasts a generation reached by a write
+func (f *ChangeFeed) Publish(ctx context.Context, generation uint64) error {
+	f.observe(generation)
+
+	pipe := f.client.TxPipeline()
+	pipe.Set(ctx, f.generationKey(), generation, 0)
+	pipe.Publish(ctx, f.channel(), strconv.FormatUint(generation, 10))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Close stops following the channel and waits for a running refresh
+func (f *ChangeFeed) Close() error {
+	close(f.stop)
+	err := f.subscription.Close()
+	f.done.Wait()
+	return err
+}
+
+// Refresher is implemented by backends that can re-read their data, so an
+// instance can catch up with writes made by another one
+type Refresher interface {
+	Store
+	Refresh(ctx context.Context) error
+}
+
+// Follow refreshes backend whenever a generation newer than its data is
+// published. Refreshes run one at a time; generations published meanwhile
+// are caught up by a single further refresh.
+func (f *ChangeFeed) Follow(backend Refresher) {
+	pending := make(chan struct{}, 1)
+	f.OnChange(func(uint64) {
+		select {
+		case pending <- struct{}{}:
+		default: // A refresh is already queued
+		}
+	})
+
+	f.done.Add(1)
+	go func() {
+		defer f.done.Done()
+		for {
+			select {
+			case <-pending:
+			case <-f.stop:
+				return
+			}
+			target := f.Generation()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			if local, err := storeGeneration(ctx, backend); err == nil && local < target {
+				start := time.Now()
+				if err := backend.Refresh(ctx); err != nil {
+					f.logger.Error("Failed to refresh storage after a published change",
+						"generation", target,
+						"error", err)
+				} else {
+					f.logger.Info("Storage refreshed after a published change",
+						"generation", target,
+						"duration_ms", time.Since(start).Milliseconds())
+				}
+			}
+			cancel()
+		}
+	}()
+}
+
+// FeedStore wraps a Store and publishes on a ChangeFeed the generation
+// reached by each successful write. A failed publication is logged: the
+// write succeeded, other instances catch up with the next one. Methods not
+// overridden pass straight through.
+type FeedStore struct {
+	Store
+	feed   *ChangeFeed
+	logger *slog.Logger
+}
+
+// NewFeedStore creates a new publishing store
+func NewFeedStore(store Store, feed *ChangeFeed, logger *slog.Logger) *FeedStore {
+	return &FeedStore{
+		Store:  store,
+		feed:   feed,
+		logger: logger,
+	}
+}
+
+// write runs a store write and publishes the change on success
+func (s *FeedStore) write(ctx context.Context, fn func() error) error {
+	if err := fn(); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	generation, err := storeGeneration(ctx, s.Store)
+	if err == nil {
+		err = s.feed.Publish(ctx, generation)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to publish change", "generation", generation, "error", err)
+	}
+	return nil
+}
+
+// CreateRegistry creates a registry and publishes the change
+func (s *FeedStore) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.write(ctx, func() error { return s.Store.CreateRegistry(ctx, r) })
+}
+
+// UpdateRegistry updates a registry and publishes the change
+func (s *FeedStore) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.write(ctx, func() error { return s.Store.UpdateRegistry(ctx, r) })
+}
+
+// DeleteRegistry deletes a registry and publishes the change
+func (s *FeedStore) DeleteRegistry(ctx context.Context, name string) error {
+	return s.write(ctx, func() error { return s.Store.DeleteRegistry(ctx, name) })
+}
+
+// CreatePackage creates a package and publishes the change
+func (s *FeedStore) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.write(ctx, func() error { return s.Store.CreatePackage(ctx, registryName, p) })
+}
+
+// UpdatePackage updates a package and publishes the change
+func (s *FeedStore) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.write(ctx, func() error { return s.Store.UpdatePackage(ctx, registryName, p) })
+}
+
+// UpdatePackages updates several packages and publishes the change
+func (s *FeedStore) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.write(ctx, func() error { return s.Store.UpdatePackages(ctx, registryName, packages) })
+}
+
+// DeletePackage deletes a package and publishes the change
+func (s *FeedStore) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return s.write(ctx, func() error { return s.Store.DeletePackage(ctx, registryName, packageName) })
+}
+
+// CreateVersion creates a version and publishes the change
+func (s *FeedStore) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return s.write(ctx, func() error { return s.Store.CreateVersion(ctx, registryName, packageName, v) })
+}
+
+// DeleteVersion deletes a version and publishes the change
+func (s *FeedStore) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.write(ctx, func() error { return s.Store.DeleteVersion(ctx, registryName, packageName, version) })
+}
+
+// ReleaseVersion releases a scheduled version and publishes the change
+func (s *FeedStore) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.write(ctx, func() error { return s.Store.ReleaseVersion(ctx, registryName, packageName, version) })
+}
+
+// CancelVersion cancels a scheduled version and publishes the change
+func (s *FeedStore) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.write(ctx, func() error { return s.Store.CancelVersion(ctx, registryName, packageName, version) })
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestChangeFeed_ReplicaRefreshes(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	path := filepath.Join(t.TempDir(), "registry.json")
+
+	writer := newRedisInstance(t, mr, path, true)
+	replica := newRedisInstance(t, mr, path, true)
+
+	require.NoError(t, writer.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, writer.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+
+	require.Eventually(t, func() bool {
+		_, err := replica.backend.GetPackage(ctx, "reg", "pkg")
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	generation, err := storeGeneration(ctx, replica.backend)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), generation)
+
+	// Once refreshed, the replica fills the cache too
+	_, err = replica.GetPackage(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.True(t, mr.Exists("test:2:package:reg/pkg"))
+}
+
+func TestChangeFeed_StartsFromPublishedGeneration(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.Set("test:generation", "42")
+
+	instance := newRedisInstance(t, mr, filepath.Join(t.TempDir(), "registry.json"), false)
+	assert.Equal(t, uint64(42), instance.feed.Generation())
+
+	mr.Publish("test:changes", "not a number")
+	mr.Publish("test:changes", "41")
+	mr.Publish("test:changes", "43")
+	require.Eventually(t, func() bool { return instance.feed.Generation() == 43 }, time.Second, 10*time.Millisecond)
+}
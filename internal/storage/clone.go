@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// CloneRegistry creates registry newName with the settings and packages of
+// source, and their versions when includeVersions is set. Objects are created
+// through store, so the clone goes through the same encryption, events and
+// name policy as any other write. If a step fails the partial clone is deleted.
+func CloneRegistry(ctx context.Context, store Store, source, newName string, includeVersions bool) (*models.Registry, error) {
+	src, err := store.GetRegistry(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := &models.Registry{
+		Name:          newName,
+		Description:   src.Description,
+		Admins:        slices.Clone(src.Admins),
+		CustomValues:  maps.Clone(src.CustomValues),
+		VersionPolicy: src.VersionPolicy,
+		SensitiveKeys: slices.Clone(src.SensitiveKeys),
+		AnonymousRead: src.AnonymousRead,
+		Announcements: slices.Clone(src.Announcements),
+		CacheTTL:      src.CacheTTL,
+		Aliases:       maps.Clone(src.Aliases),
+		IndexAliases:  src.IndexAliases,
+		Packages:      make(map[string]*models.Package),
+	}
+	if err := store.CreateRegistry(ctx, clone); err != nil {
+		return nil, err
+	}
+
+	if err := clonePackages(ctx, store, source, newName, includeVersions); err != nil {
+		// The registry did not exist before, so removing it only drops the clone
+		store.DeleteRegistry(ctx, newName)
+		return nil, err
+	}
+
+	return store.GetRegistry(ctx, newName)
+}
+
+func clonePackages(ctx context.Context, store Store, source, newName string, includeVersions bool) error {
+	packages, err := store.ListPackages(ctx, source)
+	if err != nil {
+		return err
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+
+	for _, pkg := range packages {
+		clone := &models.Package{
+			Name:         pkg.Name,
+			Description:  pkg.Description,
+			Maintainers:  slices.Clone(pkg.Maintainers),
+			CustomValues: maps.Clone(pkg.CustomValues),
+			Versions:     make(map[string]*models.Version),
+		}
+		if err := store.CreatePackage(ctx, newName, clone); err != nil {
+			return err
+		}
+		if !includeVersions {
+			continue
+		}
+
+		versions := make([]*models.Version, 0, len(pkg.Versions))
+		for _, v := range pkg.Versions {
+			versions = append(versions, v)
+		}
+		models.SortVersions(versions, false)
+		for _, v := range versions {
+			ver := *v
+			if err := store.CreateVersion(ctx, newName, pkg.Name, &ver); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
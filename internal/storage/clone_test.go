@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCloneSource(t *testing.T) *FileStorage {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	template := models.NewRegistry("template", "Golden template", []string{"admin@example.com"}, map[string]string{"team": "platform"})
+	template.VersionPolicy = models.VersionPolicyLegacy
+	require.NoError(t, fs.CreateRegistry(ctx, template))
+	for _, name := range []string{"deploy", "lint"} {
+		require.NoError(t, fs.CreatePackage(ctx, "template", &models.Package{
+			Name:         name,
+			Maintainers:  []string{"dev@example.com"},
+			CustomValues: map[string]string{"tier": "1"},
+			Versions:     map[string]*models.Version{},
+		}))
+	}
+	publishAt := time.Now().Add(time.Hour)
+	for _, v := range []*models.Version{
+		{Name: "deploy", Version: "1.0", Checksum: "sha256:0000000000000000000000000000000000000000000000000000000000000001", URL: "https://example.com/1.zip", EndPartition: 4},
+		{Name: "deploy", Version: "2.0", Checksum: "sha256:0000000000000000000000000000000000000000000000000000000000000002", URL: "https://example.com/2.zip", StartPartition: 5, EndPartition: 9, PublishAt: &publishAt},
+	} {
+		require.NoError(t, fs.CreateVersion(ctx, "template", "deploy", v))
+	}
+	return fs
+}
+
+func TestCloneRegistry_StructureOnly(t *testing.T) {
+	fs := newTestCloneSource(t)
+	ctx := context.Background()
+
+	clone, err := CloneRegistry(ctx, fs, "template", "payments", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "payments", clone.Name)
+	assert.Equal(t, "Golden template", clone.Description)
+	assert.Equal(t, []string{"admin@example.com"}, clone.Admins)
+	assert.Equal(t, map[string]string{"team": "platform"}, clone.CustomValues)
+	assert.Equal(t, models.VersionPolicyLegacy, clone.VersionPolicy)
+	require.Len(t, clone.Packages, 2)
+	assert.Equal(t, []string{"dev@example.com"}, clone.Packages["deploy"].Maintainers)
+	assert.Empty(t, clone.Packages["deploy"].Versions)
+
+	// The source is untouched
+	src, err := fs.GetRegistry(ctx, "template")
+	require.NoError(t, err)
+	assert.Len(t, src.Packages["deploy"].Versions, 2)
+}
+
+func TestCloneRegistry_IncludeVersions(t *testing.T) {
+	fs := newTestCloneSource(t)
+	ctx := context.Background()
+
+	clone, err := CloneRegistry(ctx, fs, "template", "payments", true)
+	require.NoError(t, err)
+
+	versions := clone.Packages["deploy"].Versions
+	require.Len(t, versions, 2)
+	assert.Equal(t, "https://example.com/1.zip", versions["1.0"].URL)
+	// Scheduled versions keep their embargo
+	assert.NotNil(t, versions["2.0"].PublishAt)
+
+	index, err := fs.GetRegistryIndex(ctx, "payments")
+	require.NoError(t, err)
+	assert.Len(t, index, 1)
+}
+
+func TestCloneRegistry_Errors(t *testing.T) {
+	fs := newTestCloneSource(t)
+	ctx := context.Background()
+
+	_, err := CloneRegistry(ctx, fs, "missing", "payments", false)
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = CloneRegistry(ctx, fs, "template", "template", false)
+	assert.Equal(t, ErrAlreadyExists, err)
+}
+
+func TestCloneRegistry_RollsBackOnFailure(t *testing.T) {
+	fs := newTestCloneSource(t)
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	store := NewPackageNameStore(fs, PackageNamesUnique, logger)
+
+	_, err := CloneRegistry(ctx, store, "template", "payments", false)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrPackageNameTaken))
+
+	_, err = fs.GetRegistry(ctx, "payments")
+	assert.Equal(t, ErrNotFound, err)
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// Codec selects the serialization format of the persisted data
+type Codec string
+
+const (
+	CodecJSON Codec = "json"
+	CodecCBOR Codec = "cbor"
+)
+
+// cborMagic is the CBOR self-described tag (55799) written in front of CBOR
+// data, so a blob's codec can be recognized without any metadata
+var cborMagic = []byte{0xd9, 0xd9, 0xf7}
+
+// CBOR keeps timestamps as RFC 3339 strings with nanoseconds, matching JSON
+var cborEncMode, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+// ParseCodec parses a codec name (json|cbor). An empty name means JSON.
+func ParseCodec(name string) (Codec, error) {
+	switch Codec(name) {
+	case "", CodecJSON:
+		return CodecJSON, nil
+	case CodecCBOR:
+		return CodecCBOR, nil
+	default:
+		return "", fmt.Errorf("unsupported storage codec %q (must be json or cbor)", name)
+	}
+}
+
+// ContentType returns the MIME type of data written with the codec
+func (c Codec) ContentType() string {
+	if c == CodecCBOR {
+		return "application/cbor"
+	}
+	return "application/json"
+}
+
+// encodeStorage serializes data with the given codec.
+// pretty indents JSON output and is ignored for CBOR.
+func encodeStorage(c Codec, data *models.Storage, pretty bool) ([]byte, error) {
+	switch c {
+	case "", CodecJSON:
+		if pretty {
+			return json.MarshalIndent(data, "", "  ")
+		}
+		return json.Marshal(data)
+
+	case CodecCBOR:
+		body, err := cborEncMode.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return append(append(make([]byte, 0, len(cborMagic)+len(body)), cborMagic...), body...), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported storage codec %q", c)
+	}
+}
+
+// decodeStorage deserializes data written by encodeStorage, detecting the
+// codec from the data itself so either format loads whatever is configured
+func decodeStorage(raw []byte) (*models.Storage, Codec, error) {
+	var data models.Storage
+	if bytes.HasPrefix(raw, cborMagic) {
+		if err := cbor.Unmarshal(raw[len(cborMagic):], &data); err != nil {
+			return nil, CodecCBOR, err
+		}
+		return &data, CodecCBOR, nil
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, CodecJSON, err
+	}
+	return &data, CodecJSON, nil
+}
+
+// ConvertResult describes a converted storage blob
+type ConvertResult struct {
+	Data              []byte
+	SourceCodec       Codec
+	SourceCompression Compression
+	RegistryCount     int
+}
+
+// Convert re-encodes a persisted storage blob (as found in a storage file,
+// S3 object or OCI layer) with the codec, JSON formatting and compression
+// given in opts. The source format is detected automatically.
+func Convert(raw []byte, opts Options) (*ConvertResult, error) {
+	return convert(raw, opts, nil)
+}
+
+// ConvertAnonymized converts like Convert, anonymizing the data on the way
+// (see models.Anonymizer) so it can be shared outside the organization
+func ConvertAnonymized(raw []byte, opts Options) (*ConvertResult, error) {
+	return convert(raw, opts, models.NewAnonymizer().Storage)
+}
+
+// convert re-encodes a storage blob, applying transform if not nil.
+// opts.Envelope decrypts encrypted input and encrypts the output.
+func convert(raw []byte, opts Options, transform func(*models.Storage) *models.Storage) (*ConvertResult, error) {
+	opened, err := opts.Envelope.Open(raw)
+	if err != nil {
+		return nil, err
+	}
+	plain, compression, err := decompressData(opened)
+	if err != nil {
+		return nil, err
+	}
+	data, codec, err := decodeStorage(plain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s data: %w", codec, err)
+	}
+	if transform != nil {
+		data = transform(data)
+	}
+	out, err := encodeStorage(opts.Codec, data, opts.PrettyJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s data: %w", opts.Codec, err)
+	}
+	out, err = compressData(opts.Compression, out)
+	if err != nil {
+		return nil, err
+	}
+	out, err = opts.Envelope.Seal(out)
+	if err != nil {
+		return nil, err
+	}
+	return &ConvertResult{
+		Data:              out,
+		SourceCodec:       codec,
+		SourceCompression: compression,
+		RegistryCount:     len(data.Registries),
+	}, nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func newCodecTestData(t *testing.T) *models.Storage {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("tools", "Build tools", []string{"alice"}, map[string]string{"team": "build"}), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "tools", models.NewPackage("deployer", "Deploys things", nil, nil), nil))
+	v := models.NewVersion("deployer", "1.2.0", "sha256:abc", "https://example.com/deployer-1.2.0.zip", 0, 9)
+	publishAt := time.Date(2030, 1, 2, 3, 4, 5, 678, time.UTC)
+	v.PublishAt = &publishAt
+	require.NoError(t, bs.CreateVersion(ctx, "tools", "deployer", v, nil))
+
+	return bs.GetData()
+}
+
+func TestParseCodec(t *testing.T) {
+	c, err := ParseCodec("")
+	require.NoError(t, err)
+	assert.Equal(t, CodecJSON, c)
+
+	c, err = ParseCodec("cbor")
+	require.NoError(t, err)
+	assert.Equal(t, CodecCBOR, c)
+	assert.Equal(t, "application/cbor", c.ContentType())
+
+	_, err = ParseCodec("protobuf")
+	assert.Error(t, err)
+}
+
+func TestEncodeStorage_RoundTrip(t *testing.T) {
+	data := newCodecTestData(t)
+
+	for _, codec := range []Codec{CodecJSON, CodecCBOR} {
+		t.Run(string(codec), func(t *testing.T) {
+			raw, err := encodeStorage(codec, data, false)
+			require.NoError(t, err)
+			assert.Equal(t, codec == CodecCBOR, bytes.HasPrefix(raw, cborMagic))
+
+			got, detected, err := decodeStorage(raw)
+			require.NoError(t, err)
+			assert.Equal(t, codec, detected)
+			assert.True(t, got.Registries["tools"].Packages["deployer"].Versions["1.2.0"].PublishAt.Equal(
+				*data.Registries["tools"].Packages["deployer"].Versions["1.2.0"].PublishAt))
+
+			// Empty maps come back as nil, as with JSON, so compare encodings
+			want, err := json.Marshal(data)
+			require.NoError(t, err)
+			have, err := json.Marshal(got)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(have))
+		})
+	}
+}
+
+func TestEncodeStorage_CBORIsSmaller(t *testing.T) {
+	data := newCodecTestData(t)
+
+	jsonData, err := encodeStorage(CodecJSON, data, false)
+	require.NoError(t, err)
+	cborData, err := encodeStorage(CodecCBOR, data, false)
+	require.NoError(t, err)
+	assert.Less(t, len(cborData), len(jsonData))
+}
+
+func TestConvert(t *testing.T) {
+	data := newCodecTestData(t)
+	jsonData, err := encodeStorage(CodecJSON, data, true)
+	require.NoError(t, err)
+
+	// JSON -> compressed CBOR -> JSON gives back the same data
+	toCBOR, err := Convert(jsonData, Options{Codec: CodecCBOR, Compression: CompressionZstd})
+	require.NoError(t, err)
+	assert.Equal(t, CodecJSON, toCBOR.SourceCodec)
+	assert.Equal(t, CompressionNone, toCBOR.SourceCompression)
+	assert.Equal(t, 1, toCBOR.RegistryCount)
+
+	back, err := Convert(toCBOR.Data, Options{Codec: CodecJSON, PrettyJSON: true})
+	require.NoError(t, err)
+	assert.Equal(t, CodecCBOR, back.SourceCodec)
+	assert.Equal(t, CompressionZstd, back.SourceCompression)
+	assert.Equal(t, jsonData, back.Data)
+
+	_, err = Convert([]byte("not a registry"), Options{})
+	assert.Error(t, err)
+}
+
+func TestNewStorage_FileCBOR(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	path := filepath.Join(t.TempDir(), "registry.json")
+	ctx := context.Background()
+
+	// Start with JSON data, then switch the codec to CBOR
+	jsonStore, err := NewFileStorage(path, "", logger)
+	require.NoError(t, err)
+	require.NoError(t, jsonStore.CreateRegistry(ctx, models.NewRegistry("tools", "Build tools", nil, nil)))
+
+	uri, err := ParseStorageURI("file://" + path)
+	require.NoError(t, err)
+	store, err := NewStorage(uri, "", Options{Codec: CodecCBOR}, logger)
+	require.NoError(t, err)
+	_, err = store.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("infra", "Infra tools", nil, nil)))
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, cborMagic))
+
+	// A JSON-configured server still loads the CBOR file
+	reopened, err := NewFileStorage(path, "", logger)
+	require.NoError(t, err)
+	registries, err := reopened.ListRegistries(ctx)
+	require.NoError(t, err)
+	assert.Len(t, registries, 2)
+}
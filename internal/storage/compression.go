@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects how the S3, GCS and OCI backends compress the persisted JSON
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// Magic numbers used to recognize compressed blobs on load
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseCompression parses a compression name (none|gzip|zstd).
+// An empty name means no compression.
+func ParseCompression(name string) (Compression, error) {
+	switch Compression(name) {
+	case "", CompressionNone:
+		return CompressionNone, nil
+	case CompressionGzip, CompressionZstd:
+		return Compression(name), nil
+	default:
+		return "", fmt.Errorf("unsupported storage compression %q (must be none, gzip or zstd)", name)
+	}
+}
+
+// Enabled reports whether data is compressed at all
+func (c Compression) Enabled() bool {
+	return c != "" && c != CompressionNone
+}
+
+// compressData compresses data with the given algorithm
+func compressData(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case "", CompressionNone:
+		return data, nil
+
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, fmt.Errorf("gzip compression failed: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("gzip compression failed: %w", err)
+		}
+		return buf.Bytes(), nil
+
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, fmt.Errorf("zstd compression failed: %w", err)
+		}
+		defer enc.Close()
+		return enc.EncodeAll(data, make([]byte, 0, len(data)/4)), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported storage compression %q", c)
+	}
+}
+
+// decompressData returns the plain JSON of a persisted blob and the
+// compression it was stored with. The format is recognized from the blob's
+// magic number, so data written before compression existed (or with a
+// different setting) still loads.
+func decompressData(data []byte) ([]byte, Compression, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, CompressionGzip, fmt.Errorf("gzip decompression failed: %w", err)
+		}
+		defer zr.Close()
+		plain, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, CompressionGzip, fmt.Errorf("gzip decompression failed: %w", err)
+		}
+		return plain, CompressionGzip, nil
+
+	case bytes.HasPrefix(data, zstdMagic):
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, CompressionZstd, fmt.Errorf("zstd decompression failed: %w", err)
+		}
+		defer dec.Close()
+		plain, err := dec.DecodeAll(data, nil)
+		if err != nil {
+			return nil, CompressionZstd, fmt.Errorf("zstd decompression failed: %w", err)
+		}
+		return plain, CompressionZstd, nil
+
+	default:
+		return data, CompressionNone, nil
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompression(t *testing.T) {
+	for _, name := range []string{"", "none"} {
+		c, err := ParseCompression(name)
+		require.NoError(t, err)
+		assert.Equal(t, CompressionNone, c)
+		assert.False(t, c.Enabled())
+	}
+
+	c, err := ParseCompression("zstd")
+	require.NoError(t, err)
+	assert.Equal(t, CompressionZstd, c)
+	assert.True(t, c.Enabled())
+
+	_, err = ParseCompression("brotli")
+	assert.Error(t, err)
+}
+
+func TestCompressData_RoundTrip(t *testing.T) {
+	plain := bytes.Repeat([]byte(`{"name":"deployer","description":"Deploys things"},`), 1000)
+
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			stored, err := compressData(c, plain)
+			require.NoError(t, err)
+			if c.Enabled() {
+				assert.Less(t, len(stored), len(plain)/10)
+			}
+
+			got, detected, err := decompressData(stored)
+			require.NoError(t, err)
+			assert.Equal(t, c, detected)
+			assert.Equal(t, plain, got)
+		})
+	}
+}
+
+func TestDecompressData_Uncompressed(t *testing.T) {
+	// Blobs written before compression was configured load unchanged
+	legacy := []byte("{\n  \"registries\": {}\n}")
+
+	got, detected, err := decompressData(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, CompressionNone, detected)
+	assert.Equal(t, legacy, got)
+}
+
+func TestDecompressData_Corrupt(t *testing.T) {
+	_, _, err := decompressData(append([]byte{0x1f, 0x8b}, "garbage"...))
+	assert.Error(t, err)
+
+	_, _, err = decompressData(append([]byte{0x28, 0xb5, 0x2f, 0xfd}, "garbage"...))
+	assert.Error(t, err)
+}
+
+func TestOCILayerMediaType(t *testing.T) {
+	assert.Equal(t, OCILayerMediaType, ociLayerMediaType(CodecJSON, CompressionNone))
+	assert.Equal(t, OCILayerMediaTypeGzip, ociLayerMediaType(CodecJSON, CompressionGzip))
+	assert.Equal(t, OCILayerMediaTypeZstd, ociLayerMediaType("", CompressionZstd))
+	assert.Equal(t, OCILayerMediaTypeCBOR, ociLayerMediaType(CodecCBOR, CompressionNone))
+	assert.Equal(t, "application/cbor+gzip", ociLayerMediaType(CodecCBOR, CompressionGzip))
+}
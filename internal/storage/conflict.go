@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// conflictRefreshTimeout bounds the refresh following a conflicting write
+const conflictRefreshTimeout = 5 * time.Minute
+
+// checkRemoteVersion compares the version (S3 ETag, OCI manifest digest) of
+// the stored data with the one this instance last loaded or wrote, empty
+// when it found none. A different version means another writer changed the
+// data since, and writing would discard their changes. Data that has
+// disappeared is written again: this instance holds all of it.
+func checkRemoteVersion(what, expected, actual string) error {
+	if actual == expected || actual == "" {
+		return nil
+	}
+	if expected == "" {
+		return fmt.Errorf("%w: %s was created by another writer", ErrConflictRemoteModified, what)
+	}
+	return fmt.Errorf("%w: %s is at %s, expected %s", ErrConflictRemoteModified, what, actual, expected)
+}
+
+// conflictRefresher reloads a backend after a write was refused with
+// ErrConflictRemoteModified, so retrying the request applies it on top of
+// the other writer's changes
+type conflictRefresher struct {
+	running atomic.Bool
+}
+
+// trigger starts a refresh in the background, unless one is running: the
+// refused write still holds the lock the refresh needs
+func (r *conflictRefresher) trigger(logger *slog.Logger, refresh func(ctx context.Context) error) {
+	if !r.running.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer r.running.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), conflictRefreshTimeout)
+		defer cancel()
+
+		start := time.Now()
+		if err := refresh(ctx); err != nil {
+			logger.Error("Failed to refresh storage after a conflicting write", "error", err)
+			return
+		}
+		logger.Info("Storage refreshed after a conflicting write",
+			"duration_ms", time.Since(start).Milliseconds())
+	}()
+}
@@ -0,0 +1,74 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestS3Storage_ConflictRemoteModified(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "bucket")
+	uri, err := storage.ParseStorageURI(strings.Replace(s3.URL, "http://", "s3+http://", 1) + "/bucket/registry.json?region=us-east-1")
+	require.NoError(t, err)
+	open := func() *storage.S3Storage {
+		store, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", storage.Options{
+			Retry: storage.RetryPolicy{MaxAttempts: 1},
+		}, newConformanceLogger())
+		require.NoError(t, err)
+		return store
+	}
+	first, second := open(), open()
+
+	require.NoError(t, first.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+
+	// The second instance would overwrite the first one's registry
+	err = second.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil))
+	require.ErrorIs(t, err, storage.ErrConflictRemoteModified)
+	_, err = second.GetRegistry(ctx, "games")
+	assert.ErrorIs(t, err, storage.ErrNotFound, "refused write kept in memory")
+
+	// Once refreshed in the background, the retried write keeps both
+	require.Eventually(t, func() bool {
+		_, err := second.GetRegistry(ctx, "tools")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, second.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)))
+
+	third := open()
+	registries, err := third.ListRegistries(ctx)
+	require.NoError(t, err)
+	assert.Len(t, registries, 2)
+
+	// And the first instance, now behind, is refused in turn
+	err = first.DeleteRegistry(ctx, "tools")
+	assert.ErrorIs(t, err, storage.ErrConflictRemoteModified)
+}
+
+func TestS3Storage_ConflictBetweenCheckAndUpload(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "bucket")
+	uri, err := storage.ParseStorageURI(strings.Replace(s3.URL, "http://", "s3+http://", 1) + "/bucket/registry.json?region=us-east-1")
+	require.NoError(t, err)
+	store, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", storage.Options{
+		Retry: storage.RetryPolicy{MaxAttempts: 1},
+	}, newConformanceLogger())
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	puts := s3.Puts()
+
+	// Another writer uploads after the ETag check passed: the conditional
+	// PUT is refused rather than overwriting its data
+	other := []byte(`{"registries":{}}`)
+	s3.InterleavePut(other)
+	err = store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil))
+	require.ErrorIs(t, err, storage.ErrConflictRemoteModified)
+	assert.Equal(t, puts, s3.Puts())
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// Storage token names
+const (
+	CredentialPrimary   = "primary"
+	CredentialSecondary = "secondary"
+)
+
+// Credentials are the tokens S3, GCS, OCI, DynamoDB and WebDAV storage authenticate with: a
+// primary token, and an optional secondary token the client falls back to
+// when the backend rejects the one in use, so a token can be rotated
+// without downtime. The token in use stays active until it is rejected in
+// turn, the tokens change, or Reauthenticate is called.
+type Credentials struct {
+	logger *slog.Logger
+
+	mu         sync.Mutex
+	tokens     [2]string // Primary, secondary
+	active     int       // Index of the token in use
+	generation uint64    // Changes with the token in use, so clients drop cached authentication
+	switchedAt time.Time
+	lastError  string                          // Rejection that caused the last switch
+	check      func(ctx context.Context) error // Probes the backend, set by the client
+}
+
+// CredentialStatus reports the storage token in use
+type CredentialStatus struct {
+	Active     string     `json:"active"`                // primary or secondary
+	Secondary  bool       `json:"secondary_configured"`  // Whether there is a token to fall back to
+	SwitchedAt *time.Time `json:"switched_at,omitempty"` // Last change of the token in use
+	LastError  string     `json:"last_error,omitempty"`  // Rejection that caused the last fallback
+}
+
+// NewCredentials creates credentials using the primary token first
+func NewCredentials(primary, secondary string, logger *slog.Logger) *Credentials {
+	return &Credentials{
+		logger: logger,
+		tokens: [2]string{primary, secondary},
+	}
+}
+
+func credentialName(index int) string {
+	if index == 0 {
+		return CredentialPrimary
+	}
+	return CredentialSecondary
+}
+
+// current returns the token in use and its generation
+func (c *Credentials) current() (string, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[c.active], c.generation
+}
+
+// Tokens returns the primary and secondary tokens
+func (c *Credentials) Tokens() (primary, secondary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[0], c.tokens[1]
+}
+
+// SetTokens replaces the tokens, on configuration reload. Clients switch
+// back to the primary token if either changed.
+func (c *Credentials) SetTokens(primary, secondary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == [2]string{primary, secondary} {
+		return
+	}
+	c.tokens = [2]string{primary, secondary}
+	c.switchLocked(0, "")
+	c.logger.Info("Storage tokens changed, using the primary token",
+		"secondary_configured", secondary != "")
+}
+
+func (c *Credentials) switchLocked(active int, cause string) {
+	c.active = active
+	c.generation++
+	c.switchedAt = time.Now().UTC()
+	c.lastError = cause
+}
+
+// setCheck sets how Reauthenticate probes the backend
+func (c *Credentials) setCheck(check func(ctx context.Context) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.check = check
+}
+
+// fallback switches to the other token after the token of generation was
+// rejected by err. It reports whether the operation should be tried again.
+func (c *Credentials) fallback(ctx context.Context, generation uint64, err error) bool {
+	if !IsAuthError(err) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens[1] == "" {
+		return false
+	}
+	// Another operation already switched
+	if generation != c.generation {
+		return true
+	}
+	rejected := c.active
+	c.switchLocked(1-rejected, err.Error())
+	c.logger.Warn("Storage token rejected, falling back to the other token",
+		append(tracing.LogAttrs(ctx),
+			"rejected", credentialName(rejected),
+			"active", credentialName(c.active),
+			"error", err)...)
+	return true
+}
+
+// do runs fn, trying it again with the other token if the backend rejects
+// the one in use. Nil credentials run fn once.
+func (c *Credentials) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if c == nil {
+		return fn(ctx)
+	}
+	_, generation := c.current()
+	err := fn(ctx)
+	if err != nil && c.fallback(ctx, generation, err) {
+		err = fn(ctx)
+	}
+	return err
+}
+
+// Status reports the token in use
+func (c *Credentials) Status() CredentialStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := CredentialStatus{
+		Active:    credentialName(c.active),
+		Secondary: c.tokens[1] != "",
+		LastError: c.lastError,
+	}
+	if !c.switchedAt.IsZero() {
+		switchedAt := c.switchedAt
+		status.SwitchedAt = &switchedAt
+	}
+	return status
+}
+
+// Reauthenticate drops the authentication cached by the client and
+// switches back to the primary token, then probes the backend, falling
+// back to the secondary token if the primary one is rejected. It returns
+// the resulting status, and the error of the probe if no token works.
+func (c *Credentials) Reauthenticate(ctx context.Context) (CredentialStatus, error) {
+	c.mu.Lock()
+	c.switchLocked(0, "")
+	check := c.check
+	c.mu.Unlock()
+	c.logger.Info("Re-authenticating to storage with the primary token",
+		tracing.LogAttrs(ctx)...)
+
+	var err error
+	if check != nil {
+		err = c.do(ctx, check)
+	}
+	return c.Status(), err
+}
+
+// IsAuthError reports whether a failed storage operation was rejected for
+// its credentials
+func IsAuthError(err error) bool {
+	var ociErr *OCIError
+	var s3Err *S3Error
+	var gcsErr *GCSError
+	var dynamoErr *DynamoDBError
+	var webdavErr *WebDAVError
+	switch {
+	case errors.As(err, &ociErr):
+		return ociErr.Category == OCICategoryAuth
+	case errors.As(err, &s3Err):
+		return s3Err.Category == S3CategoryAuth
+	case errors.As(err, &gcsErr):
+		return gcsErr.Category == GCSCategoryAuth
+	case errors.As(err, &dynamoErr):
+		return dynamoErr.Category == DynamoDBCategoryAuth
+	case errors.As(err, &webdavErr):
+		return webdavErr.Category == WebDAVCategoryAuth
+	default:
+		return false
+	}
+}
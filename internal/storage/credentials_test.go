@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentials(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	creds := NewCredentials("old", "new", logger)
+	rejected := map[string]bool{"old": true}
+	op := func(ctx context.Context) error {
+		token, _ := creds.current()
+		if rejected[token] {
+			return NewOCIAuthError(OCIOpPull, errors.New("response status code 401: unauthorized"))
+		}
+		return nil
+	}
+
+	// A rejected primary token falls back to the secondary one
+	require.NoError(t, creds.do(ctx, op))
+	status := creds.Status()
+	assert.Equal(t, CredentialSecondary, status.Active)
+	assert.True(t, status.Secondary)
+	assert.NotNil(t, status.SwitchedAt)
+	assert.Contains(t, status.LastError, "401")
+
+	// Other errors do not switch
+	require.Error(t, creds.do(ctx, func(ctx context.Context) error {
+		return NewOCINetworkError(OCIOpPull, errors.New("connection refused"))
+	}))
+	assert.Equal(t, CredentialSecondary, creds.Status().Active)
+
+	// Re-authentication goes back to the primary token once it works again
+	creds.setCheck(op)
+	status, err := creds.Reauthenticate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CredentialSecondary, status.Active)
+	rejected["old"] = false
+	status, err = creds.Reauthenticate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CredentialPrimary, status.Active)
+	assert.Empty(t, status.LastError)
+
+	// Both tokens rejected
+	rejected["old"], rejected["new"] = true, true
+	_, err = creds.Reauthenticate(ctx)
+	assert.True(t, IsAuthError(err))
+
+	// New tokens start over with the primary one
+	creds.SetTokens("newer", "")
+	status = creds.Status()
+	assert.Equal(t, CredentialPrimary, status.Active)
+	assert.False(t, status.Secondary)
+	assert.Empty(t, status.LastError)
+
+	// Without a secondary token, nothing to fall back to
+	creds = NewCredentials("old", "", logger)
+	assert.True(t, IsAuthError(creds.do(ctx, op)))
+	assert.Equal(t, CredentialPrimary, creds.Status().Active)
+}
+
+func TestS3Client_CredentialFallback(t *testing.T) {
+	var mu sync.Mutex
+	var accessKeys []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		authorization := r.Header.Get("Authorization")
+		switch {
+		case strings.Contains(authorization, "Credential=OLDKEY/"):
+			accessKeys = append(accessKeys, "OLDKEY")
+			w.WriteHeader(http.StatusForbidden)
+		case strings.Contains(authorization, "Credential=NEWKEY/"):
+			accessKeys = append(accessKeys, "NEWKEY")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	client, err := NewS3Client(strings.TrimPrefix(backend.URL, "http://"), "bucket", "registry.json", "", "", false, "us-east-1", logger)
+	require.NoError(t, err)
+	creds := NewCredentials("OLDKEY:secret", "NEWKEY:secret", logger)
+	client.SetCredentials(creds)
+
+	require.NoError(t, client.ValidateBucket(context.Background()))
+	assert.Equal(t, CredentialSecondary, creds.Status().Active)
+
+	// The new keys are kept for the next operations
+	require.NoError(t, client.ValidateBucket(context.Background()))
+	assert.Equal(t, []string{"OLDKEY", "NEWKEY", "NEWKEY"}, accessKeys)
+}
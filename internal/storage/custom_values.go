@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// customValueIndex is an inverted index over package custom values:
+// registry -> key -> value -> package names.
+// It is maintained by BaseStorage under its lock and only updated once a
+// write has been persisted, so rollbacks never need to touch it.
+type customValueIndex map[string]map[string]map[string]map[string]struct{}
+
+// newCustomValueIndex builds the index from scratch
+func newCustomValueIndex(data *models.Storage) customValueIndex {
+	idx := make(customValueIndex)
+	for registryName, registry := range data.Registries {
+		for _, pkg := range registry.Packages {
+			idx.add(registryName, pkg)
+		}
+	}
+	return idx
+}
+
+// add indexes the custom values of a package
+func (idx customValueIndex) add(registryName string, p *models.Package) {
+	keys, ok := idx[registryName]
+	if !ok {
+		keys = make(map[string]map[string]map[string]struct{})
+		idx[registryName] = keys
+	}
+	for key, value := range p.CustomValues {
+		values, ok := keys[key]
+		if !ok {
+			values = make(map[string]map[string]struct{})
+			keys[key] = values
+		}
+		names, ok := values[value]
+		if !ok {
+			names = make(map[string]struct{})
+			values[value] = names
+		}
+		names[p.Name] = struct{}{}
+	}
+}
+
+// remove drops a package's custom values from the index
+func (idx customValueIndex) remove(registryName string, p *models.Package) {
+	keys, ok := idx[registryName]
+	if !ok {
+		return
+	}
+	for key, value := range p.CustomValues {
+		values, ok := keys[key]
+		if !ok {
+			continue
+		}
+		if names, ok := values[value]; ok {
+			delete(names, p.Name)
+			if len(names) == 0 {
+				delete(values, value)
+			}
+		}
+		if len(values) == 0 {
+			delete(keys, key)
+		}
+	}
+}
+
+// lookup returns the names of packages matching the query, sorted
+func (idx customValueIndex) lookup(registryName string, query models.CustomValueQuery) []string {
+	keys := idx[registryName]
+
+	var result map[string]struct{}
+	for _, key := range query.Keys() {
+		// Union of the packages holding any of the wanted values
+		matches := make(map[string]struct{})
+		for _, value := range query[key] {
+			for name := range keys[key][value] {
+				matches[name] = struct{}{}
+			}
+		}
+
+		// Intersection across keys
+		if result == nil {
+			result = matches
+		} else {
+			for name := range result {
+				if _, ok := matches[name]; !ok {
+					delete(result, name)
+				}
+			}
+		}
+		if len(result) == 0 {
+			return []string{}
+		}
+	}
+
+	names := make([]string, 0, len(result))
+	for name := range result {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindPackages returns the packages of a registry whose custom values match
+// the query, using the custom value index. An empty query matches every package.
+func (b *BaseStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	registry, exists := b.data.Registries[registryName]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	if len(query) == 0 {
+		packages := make([]*models.Package, 0, len(registry.Packages))
+		for _, p := range registry.Packages {
+			packages = append(packages, p)
+		}
+		return packages, nil
+	}
+
+	names := b.customIndex.lookup(registryName, query)
+	packages := make([]*models.Package, 0, len(names))
+	for _, name := range names {
+		if p, ok := registry.Packages[name]; ok {
+			packages = append(packages, p)
+		}
+	}
+	return packages, nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func packageNames(packages []*models.Package) []string {
+	names := make([]string, 0, len(packages))
+	for _, p := range packages {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestBaseStorage_FindPackages(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("billing", "", nil, map[string]string{"team": "payments", "tier": "1"}), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("refunds", "", nil, map[string]string{"team": "payments", "tier": "2"}), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("search", "", nil, map[string]string{"team": "discovery", "tier": "1"}), nil))
+
+	tests := []struct {
+		name     string
+		query    models.CustomValueQuery
+		expected []string
+	}{
+		{"single key", models.CustomValueQuery{"team": {"payments"}}, []string{"billing", "refunds"}},
+		{"and across keys", models.CustomValueQuery{"team": {"payments"}, "tier": {"1"}}, []string{"billing"}},
+		{"or within key", models.CustomValueQuery{"tier": {"1", "2"}}, []string{"billing", "refunds", "search"}},
+		{"no match", models.CustomValueQuery{"team": {"infra"}}, []string{}},
+		{"unknown key", models.CustomValueQuery{"owner": {"x"}}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages, err := bs.FindPackages(ctx, "reg", tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, packageNames(packages))
+		})
+	}
+
+	_, err := bs.FindPackages(ctx, "missing", models.CustomValueQuery{"team": {"payments"}})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestBaseStorage_FindPackages_IndexFollowsWrites(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+	query := models.CustomValueQuery{"team": {"payments"}}
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("billing", "", nil, map[string]string{"team": "payments"}), nil))
+
+	// Update moves the package to another team
+	require.NoError(t, bs.UpdatePackage(ctx, "reg", models.NewPackage("billing", "", nil, map[string]string{"team": "infra"}), nil))
+	packages, err := bs.FindPackages(ctx, "reg", query)
+	require.NoError(t, err)
+	assert.Empty(t, packages)
+
+	// A failed write leaves the index untouched
+	failing := func(context.Context) error { return errors.New("disk full") }
+	err = bs.UpdatePackage(ctx, "reg", models.NewPackage("billing", "", nil, map[string]string{"team": "payments"}), failing)
+	assert.Equal(t, ErrStorageUnavailable, err)
+	packages, err = bs.FindPackages(ctx, "reg", models.CustomValueQuery{"team": {"infra"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"billing"}, packageNames(packages))
+
+	// Deleted packages disappear from results
+	require.NoError(t, bs.DeletePackage(ctx, "reg", "billing", nil))
+	packages, err = bs.FindPackages(ctx, "reg", models.CustomValueQuery{"team": {"infra"}})
+	require.NoError(t, err)
+	assert.Empty(t, packages)
+
+	// The index is rebuilt from loaded data
+	err = bs.UnmarshalData([]byte(`{"registries":{"reg":{"name":"reg","packages":{"p":{"name":"p","custom_values":{"team":"payments"}}}}}}`))
+	require.NoError(t, err)
+	packages, err = bs.FindPackages(ctx, "reg", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p"}, packageNames(packages))
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// Default per-operation deadlines. Reads are served from memory and only
+// wait for the lock; writes also cover persisting the whole document.
+const (
+	DefaultReadTimeout  = 10 * time.Second
+	DefaultWriteTimeout = 60 * time.Second
+)
+
+// contextError reports whether an operation must stop: ErrTimeout once its
+// deadline has passed, or the context's error if it was cancelled
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return err
+}
+
+// persistError maps a failed persist to the error returned to callers:
+// ErrConflictRemoteModified when another writer changed the stored data,
+// ErrTimeout when the operation ran out of time, ErrStorageUnavailable
+// otherwise
+func persistError(ctx context.Context, err error) error {
+	if errors.Is(err, ErrConflictRemoteModified) {
+		return ErrConflictRemoteModified
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ErrStorageUnavailable
+}
+
+// operationContext bounds ctx by timeout; zero keeps ctx's own deadline
+func operationContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// acquire takes a lock, giving up with the context's error (ErrTimeout past
+// its deadline) when ctx is done first, e.g. behind a slow persist. A free
+// lock is taken without waiting; otherwise a goroutine waits in line for
+// it, so writers still keep new readers out, and releases it as soon as
+// it gets it if the caller has given up.
+func acquire(ctx context.Context, tryLock func() bool, lock, unlock func()) error {
+	if tryLock() {
+		return nil
+	}
+	acquired := make(chan struct{})
+	go func() {
+		lock()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		go func() {
+			<-acquired
+			unlock()
+		}()
+		return contextError(ctx)
+	}
+}
+
+// lock takes the write lock for a mutation bounded by the write timeout, and
+// returns the operation's context, to pass to persist, and the function
+// releasing both. The context names the operation for backend calls and
+// logs (see the tracing package). The context is checked again once the
+// lock is held: an operation whose caller gave up in the meantime changes
+// nothing.
+func (b *BaseStorage) lock(ctx context.Context, operation string) (context.Context, func(), error) {
+	ctx, cancel := operationContext(tracing.WithOperation(ctx, operation), b.writeTimeout)
+	if err := acquire(ctx, b.mu.TryLock, b.mu.Lock, b.mu.Unlock); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	if err := contextError(ctx); err != nil {
+		b.mu.Unlock()
+		cancel()
+		return nil, nil, err
+	}
+	return ctx, func() {
+		b.mu.Unlock()
+		cancel()
+	}, nil
+}
+
+// rlock takes the read lock for a read bounded by the read timeout, and
+// returns the function releasing it
+func (b *BaseStorage) rlock(ctx context.Context) (func(), error) {
+	ctx, cancel := operationContext(ctx, b.readTimeout)
+	defer cancel()
+	if err := acquire(ctx, b.mu.TryRLock, b.mu.RLock, b.mu.RUnlock); err != nil {
+		return nil, err
+	}
+	if err := contextError(ctx); err != nil {
+		b.mu.RUnlock()
+		return nil, err
+	}
+	return b.mu.RUnlock, nil
+}
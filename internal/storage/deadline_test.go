@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseStorage_WriteTimeout(t *testing.T) {
+	bs := newTestBaseStorage()
+	bs.writeTimeout = 20 * time.Millisecond
+	ctx := context.Background()
+
+	// A persist outliving the write timeout is rolled back
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	err := bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), slow)
+	assert.Equal(t, ErrTimeout, err)
+	_, err = bs.GetRegistry(ctx, "reg")
+	assert.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), func(context.Context) error { return nil }))
+}
+
+func TestBaseStorage_LockWaitTimesOut(t *testing.T) {
+	bs := newTestBaseStorage()
+	bs.writeTimeout = 50 * time.Millisecond
+	bs.readTimeout = 50 * time.Millisecond
+	ctx := context.Background()
+
+	// A write stuck behind a slow persist holding the lock
+	bs.mu.Lock()
+	start := time.Now()
+	err := bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil)
+	assert.Equal(t, ErrTimeout, err)
+	_, err = bs.ListRegistries(ctx)
+	assert.Equal(t, ErrTimeout, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The waiters given up release the lock once they get it
+	bs.mu.Unlock()
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	registries, err := bs.ListRegistries(ctx)
+	require.NoError(t, err)
+	assert.Len(t, registries, 1)
+}
+
+func TestBaseStorage_HonorsContext(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, bs.CreatePackage(cancelled, "reg", models.NewPackage("pkg", "", nil, nil), nil))
+	_, err := bs.GetPackage(ctx, "reg", "pkg")
+	assert.Equal(t, ErrNotFound, err, "a cancelled write changes nothing")
+
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = bs.ListRegistries(expired)
+	assert.Equal(t, ErrTimeout, err)
+	_, _, err = bs.Changes(expired, 0)
+	assert.Equal(t, ErrTimeout, err)
+}
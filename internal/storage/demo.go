@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// DemoRegistry is the name of the registry created by SeedDemo
+const DemoRegistry = "demo"
+
+// demoPackages are the packages of the demo registry, with their versions
+// oldest first. URLs point to example.com: the index can be browsed and
+// fetched, but the archives cannot be installed.
+var demoPackages = []struct {
+	name        string
+	description string
+	team        string
+	versions    []models.Version
+}{
+	{
+		name:        "hello",
+		description: "Prints a greeting, the smallest possible command package",
+		team:        "platform",
+		versions: []models.Version{
+			{Version: "1.0.0", StartPartition: 0, EndPartition: 7},
+			{Version: "1.1.0", StartPartition: 8, EndPartition: 9}, // Canary on 2 partitions out of 10
+		},
+	},
+	{
+		name:        "deployer",
+		description: "Deploys services to the staging and production clusters",
+		team:        "delivery",
+		versions: []models.Version{
+			{Version: "2.3.1", StartPartition: 0, EndPartition: 9},
+		},
+	},
+	{
+		name:        "db-tools",
+		description: "Database snapshots and migrations",
+		team:        "data",
+		versions: []models.Version{
+			{Version: "0.9.0", StartPartition: 0, EndPartition: 9},
+		},
+	},
+}
+
+// SeedDemo fills an empty store with an example registry, so a fresh
+// standalone server has something to browse. It reports whether data was
+// added; stores that already hold registries are left untouched.
+func SeedDemo(ctx context.Context, store Store) (bool, error) {
+	registries, err := store.ListRegistries(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(registries) > 0 {
+		return false, nil
+	}
+
+	registry := models.NewRegistry(DemoRegistry, "Example registry created by --demo", []string{"admin@example.com"}, nil)
+	registry.Announcements = []string{"This is demo data: archive URLs point to example.com and cannot be installed."}
+	if err := store.CreateRegistry(ctx, registry); err != nil {
+		return false, err
+	}
+
+	for _, demo := range demoPackages {
+		pkg := models.NewPackage(demo.name, demo.description, []string{demo.team + "@example.com"},
+			map[string]string{"team": demo.team})
+		if err := store.CreatePackage(ctx, DemoRegistry, pkg); err != nil {
+			return false, err
+		}
+		for _, v := range demo.versions {
+			version := models.NewVersion(demo.name, v.Version,
+				fmt.Sprintf("sha256:%064x", len(demo.name)*1000+v.StartPartition), // Placeholder
+				fmt.Sprintf("https://example.com/packages/%s-%s.zip", demo.name, v.Version),
+				v.StartPartition, v.EndPartition)
+			if err := store.CreateVersion(ctx, DemoRegistry, demo.name, version); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestSeedDemo(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", slog.Default())
+	require.NoError(t, err)
+
+	seeded, err := SeedDemo(ctx, store)
+	require.NoError(t, err)
+	assert.True(t, seeded)
+
+	packages, err := store.ListPackages(ctx, DemoRegistry)
+	require.NoError(t, err)
+	assert.Len(t, packages, len(demoPackages))
+	versions, err := store.ListVersions(ctx, DemoRegistry, "hello")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	// A store holding data is left untouched
+	seeded, err = SeedDemo(ctx, store)
+	require.NoError(t, err)
+	assert.False(t, seeded)
+
+	other, err := NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, other.CreateRegistry(ctx, models.NewRegistry("team", "", nil, nil)))
+	seeded, err = SeedDemo(ctx, other)
+	require.NoError(t, err)
+	assert.False(t, seeded)
+	_, err = other.GetRegistry(ctx, DemoRegistry)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
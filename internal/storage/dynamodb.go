@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// DynamoDBStorage implements Store interface using a DynamoDB table.
+// It embeds BaseStorage for in-memory CRUD operations and keeps the rows of
+// the SQL backends (see rows.go) as items keyed by their rowKey. A write
+// puts and deletes the items that changed in a transaction, conditioned on
+// each item being as last read or written: records created or changed by
+// another server are never overwritten, they fail the write instead.
+type DynamoDBStorage struct {
+	*BaseStorage // Embedded for shared CRUD logic
+	client       *DynamoDBClient
+	table        string
+
+	// written holds the JSON of each row as last committed, so a write only
+	// touches the rows that changed. Guarded by the BaseStorage lock.
+	written map[recordRow]string
+}
+
+// NewDynamoDBStorage creates a new DynamoDB-backed storage.
+// The uri should be a parsed DynamoDB StorageURI (dynamodb://table).
+// The token is ACCESS_KEY:SECRET_KEY; empty uses the AWS credential chain
+// (environment, shared credentials file, then ECS, EKS or EC2 role).
+// The region defaults to AWS_REGION, then AWS_DEFAULT_REGION.
+func NewDynamoDBStorage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*DynamoDBStorage, error) {
+	if !uri.IsDynamoDBScheme() {
+		return nil, fmt.Errorf("expected DynamoDB URI, got scheme: %s", uri.Scheme)
+	}
+	table := uri.DynamoDBTable()
+
+	region := uri.DynamoDBRegion()
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("DynamoDB region required: add ?region= to the URI or set AWS_REGION")
+	}
+	endpoint := uri.DynamoDBEndpoint()
+	if endpoint == "" {
+		endpoint = dynamoDBRegionalEndpoint(region)
+	}
+
+	var accessKey, secretKey string
+	if token != "" {
+		var err error
+		if accessKey, secretKey, err = ParseS3Token(token); err != nil {
+			return nil, fmt.Errorf("failed to parse DynamoDB credentials: %w", err)
+		}
+	}
+	if opts.Credentials != nil {
+		if _, secondary := opts.Credentials.Tokens(); secondary != "" {
+			if _, _, err := ParseS3Token(secondary); err != nil {
+				return nil, fmt.Errorf("failed to parse secondary DynamoDB credentials: %w", err)
+			}
+		}
+	}
+
+	client := NewDynamoDBClient(endpoint, region, table, accessKey, secretKey, logger)
+	if opts.LoadTimeout > DynamoDBScanTimeout {
+		client.scanTimeout = opts.LoadTimeout
+	}
+	client.SetRetryPolicy(opts.Retry)
+	if opts.Credentials != nil {
+		client.SetCredentials(opts.Credentials)
+	}
+
+	// Validate table exists
+	ctx := tracing.WithOperation(context.Background(), "validate_table")
+	if err := client.ValidateTable(ctx); err != nil {
+		return nil, fmt.Errorf("DynamoDB table validation failed: %w", err)
+	}
+
+	s := &DynamoDBStorage{
+		BaseStorage: NewBaseStorage(logger),
+		client:      client,
+		table:       table,
+		written:     make(map[recordRow]string),
+	}
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("failed to load data from DynamoDB: %w", err)
+	}
+	return s, nil
+}
+
+// load reads all items and nests them into a document, decoded like the
+// other backends' so invalid records are quarantined
+func (s *DynamoDBStorage) load() error {
+	ctx := tracing.WithOperation(context.Background(), "load")
+
+	items, err := s.client.Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan DynamoDB table: %w", err)
+	}
+	rows := make(map[recordRow]string, len(items))
+	for key, data := range items {
+		row, ok := parseRowKey(key)
+		if !ok {
+			s.logger.Warn("Ignoring unknown item of the DynamoDB table", "table", s.table, "key", key)
+			continue
+		}
+		rows[row] = data
+	}
+
+	encoded, err := assembleRows(rows)
+	if err != nil {
+		return fmt.Errorf("failed to assemble DynamoDB items: %w", err)
+	}
+	if err := s.UnmarshalData(encoded); err != nil {
+		return fmt.Errorf("failed to decode DynamoDB items: %w", err)
+	}
+	s.written = rows
+
+	data := s.GetData()
+	s.logger.Info("DynamoDB storage loaded",
+		"table", s.table,
+		"registry_count", len(data.Registries))
+	return nil
+}
+
+// persist is the callback passed to BaseStorage methods: it writes the rows
+// that changed since the last commit. A write of more than 100 rows spans
+// several transactions; if one fails, the rows committed by the previous
+// ones are remembered as written, so the next write puts them back in line
+// with the data in memory.
+func (s *DynamoDBStorage) persist(ctx context.Context) error {
+	rows, err := encodeRows(s.getDataLocked())
+	if err != nil {
+		return fmt.Errorf("failed to encode rows: %w", err)
+	}
+
+	var changes []dynamoDBChange
+	var changed []recordRow
+	for row, previous := range s.written {
+		if _, exists := rows[row]; !exists {
+			changes = append(changes, dynamoDBChange{Key: rowKey(row), Previous: previous})
+			changed = append(changed, row)
+		}
+	}
+	for row, data := range rows {
+		if previous := s.written[row]; previous != data {
+			changes = append(changes, dynamoDBChange{Key: rowKey(row), Data: data, Previous: previous})
+			changed = append(changed, row)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	committed, err := s.client.Write(ctx, changes)
+	if err != nil && committed == 0 {
+		return err // Already categorized by DynamoDBClient
+	}
+	written := make(map[recordRow]string, len(rows))
+	for row, data := range s.written {
+		written[row] = data
+	}
+	for i, row := range changed[:committed] {
+		if changes[i].Data == "" {
+			delete(written, row)
+		} else {
+			written[row] = changes[i].Data
+		}
+	}
+	s.written = written
+	if err != nil {
+		s.logger.Error("DynamoDB write partially committed",
+			append(tracing.LogAttrs(ctx),
+				"table", s.table,
+				"committed", committed,
+				"records", len(changes),
+				"error", err)...)
+	}
+	return err
+}
+
+// CreateRegistry creates a new registry
+func (s *DynamoDBStorage) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.CreateRegistry(ctx, r, s.persist)
+}
+
+// GetRegistry retrieves a registry by name
+func (s *DynamoDBStorage) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	return s.BaseStorage.GetRegistry(ctx, name)
+}
+
+// UpdateRegistry updates registry metadata
+func (s *DynamoDBStorage) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.UpdateRegistry(ctx, r, s.persist)
+}
+
+// DeleteRegistry deletes a registry and all its packages (atomic)
+func (s *DynamoDBStorage) DeleteRegistry(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteRegistry(ctx, name, s.persist)
+}
+
+// ListRegistries returns all registries
+func (s *DynamoDBStorage) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	return s.BaseStorage.ListRegistries(ctx)
+}
+
+// CreatePackage creates a new package in a registry
+func (s *DynamoDBStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.CreatePackage(ctx, registryName, p, s.persist)
+}
+
+// GetPackage retrieves a package from a registry
+func (s *DynamoDBStorage) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	return s.BaseStorage.GetPackage(ctx, registryName, packageName)
+}
+
+// UpdatePackage updates package metadata (preserves versions)
+func (s *DynamoDBStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
+}
+
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *DynamoDBStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
+// DeletePackage deletes a package and all its versions (atomic)
+func (s *DynamoDBStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
+}
+
+// ListPackages returns all packages in a registry
+func (s *DynamoDBStorage) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	return s.BaseStorage.ListPackages(ctx, registryName)
+}
+
+// FindPackages returns the packages whose custom values match a query
+func (s *DynamoDBStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *DynamoDBStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *DynamoDBStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *DynamoDBStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *DynamoDBStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *DynamoDBStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *DynamoDBStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *DynamoDBStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *DynamoDBStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *DynamoDBStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
+// CreateVersion creates a new version for a package
+func (s *DynamoDBStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
+}
+
+// GetVersion retrieves a specific version
+func (s *DynamoDBStorage) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
+	return s.BaseStorage.GetVersion(ctx, registryName, packageName, version)
+}
+
+// DeleteVersion deletes a specific version
+func (s *DynamoDBStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.DeleteVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// ListVersions returns all versions for a package
+func (s *DynamoDBStorage) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
+	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
+}
+
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *DynamoDBStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *DynamoDBStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// GetRegistryIndex generates the registry index (Command Launcher format)
+func (s *DynamoDBStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
+	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
+}
+
+// Changes returns the records changed since a generation (differential sync)
+func (s *DynamoDBStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *DynamoDBStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close closes the storage (no-op for DynamoDB storage)
+func (s *DynamoDBStorage) Close() error {
+	return nil
+}
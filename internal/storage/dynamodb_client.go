@@ -0,0 +1,409 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// DynamoDB timeout constants
+const (
+	DynamoDBWriteTimeout = 60 * time.Second
+	DynamoDBScanTimeout  = 30 * time.Second
+)
+
+// dynamoDBTransactionLimit is the most items a TransactWriteItems request
+// can write
+const dynamoDBTransactionLimit = 100
+
+// dynamoDBKeyAttribute is the partition key of the table, a string, and
+// dynamoDBDataAttribute the attribute holding the JSON of a record
+const (
+	dynamoDBKeyAttribute  = "pk"
+	dynamoDBDataAttribute = "data"
+)
+
+// DynamoDBClient talks to the DynamoDB JSON API, signing requests with AWS
+// Signature Version 4. Without a token it uses the AWS credential chain:
+// environment variables (with the session token of Lambda), the shared
+// credentials file, then the ECS, EKS or EC2 role.
+type DynamoDBClient struct {
+	http        *http.Client
+	endpoint    string
+	region      string
+	table       string
+	scanTimeout time.Duration // Bounds Scan (DynamoDBScanTimeout unless set longer for startup)
+	retrier     *retrier      // Retries failed operations (see RetryPolicy)
+	creds       *Credentials  // Tokens, with fallback to the secondary one; nil uses static
+	static      *credentials.Credentials
+	logger      *slog.Logger
+}
+
+// dynamoDBChange is the write of a record by DynamoDBClient.Write,
+// conditioned on the record being as last read or written
+type dynamoDBChange struct {
+	Key      string
+	Data     string // Empty deletes the record
+	Previous string // Empty if the record is new
+}
+
+// NewDynamoDBClient creates a new DynamoDB client for a table. Empty keys
+// use the AWS credential chain.
+func NewDynamoDBClient(endpoint, region, table, accessKey, secretKey string, logger *slog.Logger) *DynamoDBClient {
+	static := credentials.NewStaticV4(accessKey, secretKey, "")
+	if accessKey == "" {
+		static = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+
+	logger.Info("DynamoDB client created",
+		"endpoint", endpoint,
+		"region", region,
+		"table", table)
+
+	// Tag every DynamoDB request with the request ID and operation behind
+	// it (see tracingTransport)
+	return &DynamoDBClient{
+		http:        &http.Client{Transport: newTracingTransport(backendTransport(), logger)},
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		region:      region,
+		table:       table,
+		scanTimeout: DynamoDBScanTimeout,
+		retrier:     newRetrier(DefaultRetryPolicy(), logger),
+		static:      static,
+		logger:      logger,
+	}
+}
+
+// SetCredentials makes the client authenticate with the ACCESS_KEY:SECRET_KEY
+// tokens of creds, falling back to their secondary token, instead of the
+// keys it was created with. It must be called before any operation.
+func (c *DynamoDBClient) SetCredentials(creds *Credentials) {
+	c.creds = creds
+	creds.setCheck(c.validateTable)
+}
+
+// SetRetryPolicy changes how failed operations are retried
+func (c *DynamoDBClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retrier = newRetrier(policy, c.logger)
+}
+
+// ValidateTable checks that the table exists and has a string partition
+// key named pk, and no sort key
+func (c *DynamoDBClient) ValidateTable(ctx context.Context) error {
+	return c.retrier.do(ctx, DynamoDBOpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, c.validateTable)
+	})
+}
+
+func (c *DynamoDBClient) validateTable(ctx context.Context) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Debug("Validating DynamoDB table", "table", c.table)
+
+	var output struct {
+		Table struct {
+			KeySchema []struct {
+				AttributeName string
+				KeyType       string
+			}
+			AttributeDefinitions []struct {
+				AttributeName string
+				AttributeType string
+			}
+		}
+	}
+	if err := c.call(ctx, "DescribeTable", map[string]any{"TableName": c.table}, &output); err != nil {
+		logger.Error("DynamoDB table validation failed",
+			"table", c.table,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return CategorizeDynamoDBError(DynamoDBOpConnect, err)
+	}
+
+	keySchema := output.Table.KeySchema
+	valid := len(keySchema) == 1 && keySchema[0].AttributeName == dynamoDBKeyAttribute && keySchema[0].KeyType == "HASH"
+	for _, attribute := range output.Table.AttributeDefinitions {
+		if attribute.AttributeName == dynamoDBKeyAttribute && attribute.AttributeType != "S" {
+			valid = false
+		}
+	}
+	if !valid {
+		return CategorizeDynamoDBError(DynamoDBOpConnect, fmt.Errorf("table %s must have a string partition key named %s and no sort key", c.table, dynamoDBKeyAttribute))
+	}
+
+	logger.Info("DynamoDB table validated",
+		"table", c.table,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// Scan reads every record of the table, by key, with strongly consistent
+// reads
+func (c *DynamoDBClient) Scan(ctx context.Context) (map[string]string, error) {
+	var records map[string]string
+	err := c.retrier.do(ctx, DynamoDBOpScan, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			records, err = c.scan(ctx)
+			return err
+		})
+	})
+	return records, err
+}
+
+func (c *DynamoDBClient) scan(ctx context.Context) (map[string]string, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Debug("Starting DynamoDB scan", "table", c.table)
+
+	// Apply timeout
+	ctx, cancel := context.WithTimeout(ctx, c.scanTimeout)
+	defer cancel()
+
+	records := make(map[string]string)
+	input := map[string]any{"TableName": c.table, "ConsistentRead": true}
+	for pages := 1; ; pages++ {
+		var output struct {
+			Items            []map[string]map[string]string
+			LastEvaluatedKey map[string]any
+		}
+		if err := c.call(ctx, "Scan", input, &output); err != nil {
+			logger.Error("DynamoDB scan failed",
+				"table", c.table,
+				"pages", pages,
+				"error", err,
+				"duration_ms", time.Since(start).Milliseconds())
+			return nil, CategorizeDynamoDBError(DynamoDBOpScan, err)
+		}
+		for _, item := range output.Items {
+			records[item[dynamoDBKeyAttribute]["S"]] = item[dynamoDBDataAttribute]["S"]
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			logger.Info("DynamoDB scan completed",
+				"table", c.table,
+				"records", len(records),
+				"pages", pages,
+				"duration_ms", time.Since(start).Milliseconds())
+			return records, nil
+		}
+		input["ExclusiveStartKey"] = output.LastEvaluatedKey
+	}
+}
+
+// Write applies changes in transactions of up to 100 records, each change
+// failing the whole transaction if the record is not as expected. It
+// returns the number of changes committed, all of them unless err is not
+// nil.
+func (c *DynamoDBClient) Write(ctx context.Context, changes []dynamoDBChange) (int, error) {
+	committed := 0
+	for len(changes) > 0 {
+		batch := changes[:min(len(changes), dynamoDBTransactionLimit)]
+		// The token makes retries of a transaction that did commit succeed
+		token := uuid.NewString()
+		err := c.retrier.do(ctx, DynamoDBOpWrite, func(ctx context.Context) error {
+			return c.creds.do(ctx, func(ctx context.Context) error {
+				return c.write(ctx, batch, token)
+			})
+		})
+		if err != nil {
+			return committed, err
+		}
+		committed += len(batch)
+		changes = changes[len(batch):]
+	}
+	return committed, nil
+}
+
+func (c *DynamoDBClient) write(ctx context.Context, changes []dynamoDBChange, token string) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+
+	// Apply timeout
+	ctx, cancel := context.WithTimeout(ctx, DynamoDBWriteTimeout)
+	defer cancel()
+
+	items := make([]map[string]any, 0, len(changes))
+	for _, change := range changes {
+		key := map[string]any{dynamoDBKeyAttribute: map[string]string{"S": change.Key}}
+		action := map[string]any{"TableName": c.table}
+		if change.Previous == "" {
+			action["ConditionExpression"] = "attribute_not_exists(#key)"
+			action["ExpressionAttributeNames"] = map[string]string{"#key": dynamoDBKeyAttribute}
+		} else {
+			action["ConditionExpression"] = "#data = :previous"
+			action["ExpressionAttributeNames"] = map[string]string{"#data": dynamoDBDataAttribute}
+			action["ExpressionAttributeValues"] = map[string]any{":previous": map[string]string{"S": change.Previous}}
+		}
+		if change.Data == "" {
+			action["Key"] = key
+			items = append(items, map[string]any{"Delete": action})
+			continue
+		}
+		key[dynamoDBDataAttribute] = map[string]string{"S": change.Data}
+		action["Item"] = key
+		items = append(items, map[string]any{"Put": action})
+	}
+
+	input := map[string]any{"TransactItems": items, "ClientRequestToken": token}
+	if err := c.call(ctx, "TransactWriteItems", input, nil); err != nil {
+		logger.Error("DynamoDB write failed",
+			"table", c.table,
+			"records", len(changes),
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return CategorizeDynamoDBError(DynamoDBOpWrite, err)
+	}
+
+	logger.Info("DynamoDB write completed",
+		"table", c.table,
+		"records", len(changes),
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// call sends a DynamoDB API request, decoding its answer into output
+// unless nil, and returning a dynamoDBStatusError for non-2xx answers
+func (c *DynamoDBClient) call(ctx context.Context, target string, input, output any) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	creds, err := c.credentials()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+target)
+	signAWSRequest(req, body, creds, c.region, "dynamodb", time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return newDynamoDBStatusError(resp, answer)
+	}
+	if output == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+// credentials returns the keys of the token in use, or the client's
+// static ones when it has no Credentials or the token is empty
+func (c *DynamoDBClient) credentials() (credentials.Value, error) {
+	if c.creds != nil {
+		if token, _ := c.creds.current(); token != "" {
+			accessKey, secretKey, err := ParseS3Token(token)
+			if err != nil {
+				return credentials.Value{}, &dynamoDBCredentialsError{Err: err}
+			}
+			return credentials.Value{AccessKeyID: accessKey, SecretAccessKey: secretKey}, nil
+		}
+	}
+	value, err := c.static.Get()
+	if err == nil && value.AccessKeyID == "" {
+		err = fmt.Errorf("no credentials found")
+	}
+	if err != nil {
+		return credentials.Value{}, &dynamoDBCredentialsError{Err: err}
+	}
+	return value, nil
+}
+
+// signAWSRequest signs req, whose body is body, with AWS Signature Version
+// 4, covering the host and every header already set
+func signAWSRequest(req *http.Request, body []byte, creds credentials.Value, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// dynamoDBRegionalEndpoint returns the AWS endpoint of DynamoDB in a region
+func dynamoDBRegionalEndpoint(region string) string {
+	return (&url.URL{Scheme: "https", Host: "dynamodb." + region + ".amazonaws.com"}).String()
+}
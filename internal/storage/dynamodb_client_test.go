@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDynamoDBStorage_InvalidURI(t *testing.T) {
+	uri := &StorageURI{Scheme: "file", Path: "./test/data.json", Raw: "file://./test/data.json"}
+	_, err := NewDynamoDBStorage(uri, "", Options{}, newTestS3Logger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected DynamoDB URI")
+
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	uri, err = ParseStorageURI("dynamodb://registry")
+	require.NoError(t, err)
+	_, err = NewDynamoDBStorage(uri, "", Options{}, newTestS3Logger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DynamoDB region required")
+}
+
+// TestSignAWSRequest checks the signer against the get-vanilla case of the
+// AWS Signature Version 4 test suite
+func TestSignAWSRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	creds := credentials.Value{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+	signAWSRequest(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+
+	// Temporary credentials send their session token, signed
+	req, err = http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	creds.SessionToken = "session"
+	signAWSRequest(req, nil, creds, "us-east-1", "service", time.Now())
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,")
+}
+
+func TestCategorizeDynamoDBError(t *testing.T) {
+	exception := func(name string, reasons ...string) error {
+		return &dynamoDBStatusError{StatusCode: http.StatusBadRequest, Type: name, Reasons: reasons}
+	}
+	tests := []struct {
+		name      string
+		err       error
+		category  string
+		retryable bool
+	}{
+		{"unrecognized client", exception("UnrecognizedClientException"), DynamoDBCategoryAuth, false},
+		{"access denied", exception("AccessDeniedException"), DynamoDBCategoryAuth, false},
+		{"no credentials", &dynamoDBCredentialsError{Err: errors.New("no credentials found")}, DynamoDBCategoryAuth, false},
+		{"table not found", exception("ResourceNotFoundException"), DynamoDBCategoryStorage, false},
+		{"condition failed", exception("TransactionCanceledException", "None", "ConditionalCheckFailed"), DynamoDBCategoryStorage, false},
+		{"transaction conflict", exception("TransactionCanceledException", "TransactionConflict", "None"), DynamoDBCategoryStorage, true},
+		{"throttled", exception("ProvisionedThroughputExceededException"), DynamoDBCategoryStorage, true},
+		{"server error", &dynamoDBStatusError{StatusCode: http.StatusInternalServerError, Type: "InternalServerError"}, DynamoDBCategoryStorage, true},
+		{"validation", exception("ValidationException"), DynamoDBCategoryStorage, false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, DynamoDBCategoryNetwork, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CategorizeDynamoDBError(DynamoDBOpWrite, tt.err)
+			assert.Equal(t, tt.category, err.Category)
+			assert.Equal(t, tt.retryable, Retryable(err))
+			assert.Equal(t, tt.category == DynamoDBCategoryAuth, IsAuthError(err))
+			assert.ErrorIs(t, err, ErrStorageUnavailable)
+		})
+	}
+
+	assert.Contains(t, CategorizeDynamoDBError(DynamoDBOpWrite, exception("TransactionCanceledException", "ConditionalCheckFailed")).Error(),
+		"modified by another writer")
+}
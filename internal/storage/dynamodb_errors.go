@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DynamoDB error categories for clear error messages
+const (
+	DynamoDBCategoryAuth    = "authentication"
+	DynamoDBCategoryNetwork = "network"
+	DynamoDBCategoryStorage = "storage"
+)
+
+// DynamoDB operations for error context
+const (
+	DynamoDBOpConnect = "connect"
+	DynamoDBOpScan    = "scan"
+	DynamoDBOpWrite   = "write"
+)
+
+// DynamoDBError wraps DynamoDB-specific failures with categorization
+type DynamoDBError struct {
+	Category  string // "authentication", "network", or "storage"
+	Op        string // "connect", "scan", or "write"
+	Err       error  // Underlying error
+	Retryable bool   // The operation may succeed if retried (see RetryPolicy)
+}
+
+// Error implements the error interface
+func (e *DynamoDBError) Error() string {
+	return fmt.Sprintf("DynamoDB %s error during %s: %v", e.Category, e.Op, e.Err)
+}
+
+// Unwrap implements the errors.Unwrap interface
+func (e *DynamoDBError) Unwrap() error {
+	return e.Err
+}
+
+// Is implements the errors.Is interface to match ErrStorageUnavailable
+func (e *DynamoDBError) Is(target error) bool {
+	return target == ErrStorageUnavailable
+}
+
+// dynamoDBStatusError is a DynamoDB API error answer
+type dynamoDBStatusError struct {
+	StatusCode int
+	Type       string // Exception name, without its namespace
+	Message    string
+	Reasons    []string // Cancellation reason codes of a TransactionCanceledException
+}
+
+func (e *dynamoDBStatusError) Error() string {
+	message := fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Type)
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+// newDynamoDBStatusError reads the error answer of a DynamoDB API request
+func newDynamoDBStatusError(resp *http.Response, body []byte) *dynamoDBStatusError {
+	var answer struct {
+		Type                string `json:"__type"`
+		Message             string `json:"message"`
+		MessageCapitalized  string `json:"Message"`
+		CancellationReasons []struct {
+			Code string `json:"Code"`
+		} `json:"CancellationReasons"`
+	}
+	statusErr := &dynamoDBStatusError{StatusCode: resp.StatusCode, Type: http.StatusText(resp.StatusCode)}
+	if json.Unmarshal(body, &answer) != nil {
+		return statusErr
+	}
+	if answer.Type != "" {
+		// com.amazonaws.dynamodb.v20120810#ResourceNotFoundException
+		statusErr.Type = answer.Type[strings.LastIndex(answer.Type, "#")+1:]
+	}
+	statusErr.Message = answer.Message
+	if statusErr.Message == "" {
+		statusErr.Message = answer.MessageCapitalized
+	}
+	for _, reason := range answer.CancellationReasons {
+		statusErr.Reasons = append(statusErr.Reasons, reason.Code)
+	}
+	return statusErr
+}
+
+// dynamoDBCredentialsError is a failure to obtain AWS credentials
+type dynamoDBCredentialsError struct {
+	Err error
+}
+
+func (e *dynamoDBCredentialsError) Error() string {
+	return fmt.Sprintf("failed to obtain AWS credentials: %v", e.Err)
+}
+
+func (e *dynamoDBCredentialsError) Unwrap() error {
+	return e.Err
+}
+
+// DynamoDB exceptions, by how they are handled
+var (
+	dynamoDBAuthErrors = []string{
+		"AccessDeniedException",
+		"ExpiredTokenException",
+		"IncompleteSignatureException",
+		"InvalidSignatureException",
+		"MissingAuthenticationTokenException",
+		"UnrecognizedClientException",
+	}
+	dynamoDBRetryableErrors = []string{
+		"InternalServerError",
+		"LimitExceededException",
+		"ProvisionedThroughputExceededException",
+		"RequestLimitExceeded",
+		"ServiceUnavailable",
+		"ThrottlingException",
+		"TransactionInProgressException",
+	}
+)
+
+// CategorizeDynamoDBError examines an error and returns an appropriately
+// categorized DynamoDBError. A write whose condition failed because another
+// writer changed the records is a storage error that is not retried: the
+// data must be reloaded first. Throttling, transaction conflicts and 5xx
+// answers are retryable.
+func CategorizeDynamoDBError(op string, err error) *DynamoDBError {
+	if err == nil {
+		return nil
+	}
+
+	var dynamoErr *DynamoDBError
+	if errors.As(err, &dynamoErr) {
+		return dynamoErr
+	}
+
+	var statusErr *dynamoDBStatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case slices.Contains(dynamoDBAuthErrors, statusErr.Type):
+			return &DynamoDBError{Category: DynamoDBCategoryAuth, Op: op, Err: fmt.Errorf("authentication failed: %v (verify the credentials and that they allow dynamodb:DescribeTable, Scan and TransactWriteItems on the table)", statusErr)}
+		case statusErr.Type == "ResourceNotFoundException":
+			return &DynamoDBError{Category: DynamoDBCategoryStorage, Op: op, Err: fmt.Errorf("table not found: verify the table exists in the region")}
+		case statusErr.Type == "ConditionalCheckFailedException" || slices.Contains(statusErr.Reasons, "ConditionalCheckFailed"):
+			return &DynamoDBError{Category: DynamoDBCategoryStorage, Op: op, Err: fmt.Errorf("records were modified by another writer: %v", statusErr)}
+		case slices.Contains(statusErr.Reasons, "TransactionConflict") || slices.Contains(statusErr.Reasons, "ThrottlingError"):
+			return &DynamoDBError{Category: DynamoDBCategoryStorage, Op: op, Err: statusErr, Retryable: true}
+		default:
+			return &DynamoDBError{
+				Category:  DynamoDBCategoryStorage,
+				Op:        op,
+				Err:       statusErr,
+				Retryable: statusErr.StatusCode >= 500 || slices.Contains(dynamoDBRetryableErrors, statusErr.Type),
+			}
+		}
+	}
+
+	var credsErr *dynamoDBCredentialsError
+	if errors.As(err, &credsErr) {
+		return &DynamoDBError{Category: DynamoDBCategoryAuth, Op: op, Err: fmt.Errorf("%v (use an IAM role, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or --storage-token ACCESS_KEY:SECRET_KEY)", credsErr)}
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		if (netErr != nil && netErr.Timeout()) || (urlErr != nil && urlErr.Timeout()) {
+			return &DynamoDBError{Category: DynamoDBCategoryNetwork, Op: op, Err: fmt.Errorf("network timeout: unable to reach DynamoDB endpoint"), Retryable: true}
+		}
+		return &DynamoDBError{Category: DynamoDBCategoryNetwork, Op: op, Err: fmt.Errorf("network error: unable to reach DynamoDB endpoint: %w", err), Retryable: true}
+	}
+
+	return &DynamoDBError{Category: DynamoDBCategoryStorage, Op: op, Err: err}
+}
@@ -0,0 +1,101 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func newTestDynamoDBStorage(t *testing.T, dynamodb *fakeDynamoDB) *storage.DynamoDBStorage {
+	t.Helper()
+	uri, err := storage.ParseStorageURI("dynamodb://registry?region=eu-west-1&endpoint=" + url.QueryEscape(dynamodb.URL))
+	require.NoError(t, err)
+	store, err := storage.NewDynamoDBStorage(uri, "ACCESSKEY:SECRETKEY", storage.Options{
+		Retry: storage.RetryPolicy{MaxAttempts: 1},
+	}, newConformanceLogger())
+	require.NoError(t, err)
+	return store
+}
+
+func TestDynamoDBStorage_ItemsPerRecord(t *testing.T) {
+	ctx := context.Background()
+	dynamodb := newFakeDynamoDB(t, "registry")
+
+	s := newTestDynamoDBStorage(t, dynamodb)
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "Tools", nil, nil)))
+	require.NoError(t, s.CreatePackage(ctx, "reg", models.NewPackage("pkg", "A package", nil, nil)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:"+fmt.Sprintf("%064d", 1), "https://x/1.zip", 0, 4)))
+
+	_, ok := dynamodb.Item(`["registries","reg"]`)
+	assert.True(t, ok)
+	_, ok = dynamodb.Item(`["packages","reg","pkg"]`)
+	assert.True(t, ok)
+	version, ok := dynamodb.Item(`["versions","reg","pkg","1.0.0"]`)
+	require.True(t, ok)
+	assert.Contains(t, version, `"version":"1.0.0"`)
+
+	require.NoError(t, s.DeletePackage(ctx, "reg", "pkg"))
+	_, ok = dynamodb.Item(`["versions","reg","pkg","1.0.0"]`)
+	assert.False(t, ok, "deleting a package deletes the items of its versions")
+}
+
+func TestDynamoDBStorage_ConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	dynamodb := newFakeDynamoDB(t, "registry")
+
+	first := newTestDynamoDBStorage(t, dynamodb)
+	require.NoError(t, first.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, first.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	second := newTestDynamoDBStorage(t, dynamodb)
+
+	// A version created by another server is not overwritten
+	checksum := "sha256:" + fmt.Sprintf("%064d", 1)
+	require.NoError(t, first.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksum, "https://x/1.zip", 0, 4)))
+	stored, _ := dynamodb.Item(`["versions","reg","pkg","1.0.0"]`)
+	err := second.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:"+fmt.Sprintf("%064d", 2), "https://y/1.zip", 0, 4))
+	assert.ErrorIs(t, err, storage.ErrStorageUnavailable)
+	current, _ := dynamodb.Item(`["versions","reg","pkg","1.0.0"]`)
+	assert.Equal(t, stored, current)
+
+	// The failed write was rolled back in memory
+	_, err = second.GetVersion(ctx, "reg", "pkg", "1.0.0")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestDynamoDBStorage_LargeWritesSpanTransactions(t *testing.T) {
+	ctx := context.Background()
+	dynamodb := newFakeDynamoDB(t, "registry")
+
+	s := newTestDynamoDBStorage(t, dynamodb)
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	packages := make([]*models.Package, 150)
+	for i := range packages {
+		packages[i] = models.NewPackage(fmt.Sprintf("pkg-%03d", i), "", nil, nil)
+		require.NoError(t, s.CreatePackage(ctx, "reg", packages[i]))
+	}
+
+	// Updating every package writes more than 100 items
+	before := dynamodb.Transactions()
+	for _, p := range packages {
+		p.Description = "Updated"
+	}
+	require.NoError(t, s.UpdatePackages(ctx, "reg", packages))
+	assert.Equal(t, 2, dynamodb.Transactions()-before)
+
+	// Reloading reads every page of the table
+	require.NoError(t, s.Close())
+	s = newTestDynamoDBStorage(t, dynamodb)
+	list, err := s.ListPackages(ctx, "reg")
+	require.NoError(t, err)
+	assert.Len(t, list, 150)
+	for _, p := range list {
+		assert.Equal(t, "Updated", p.Description)
+	}
+}
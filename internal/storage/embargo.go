@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// ReleaseVersion clears the embargo of a scheduled version, making it
+// permanent in index.json. This is the only change allowed on a version.
+// Returns ErrNotScheduled if the version has no publish_at.
+// The persist callback is called after the in-memory operation succeeds.
+func (b *BaseStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string, persist PersistFunc) error {
+	ctx, unlock, err := b.lock(ctx, "release_version")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	pkg, ver, err := b.getVersionLocked(registryName, packageName, version)
+	if err != nil {
+		return err
+	}
+	if ver.PublishAt == nil {
+		return ErrNotScheduled
+	}
+
+	// Replace rather than mutate: callers may hold the old pointer
+	released := *ver
+	released.PublishAt = nil
+	pkg.Versions[version] = &released
+	undo := b.changeLocked(models.RecordKey(registryName, packageName, version), false)
+	undoHistory := b.recordLocked(ctx, registryName, packageName, models.ChangeRecord{Type: models.ChangeVersionPublished, Version: version})
+
+	// Persist
+	if persist != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
+			// Rollback
+			undoHistory()
+			undo()
+			pkg.Versions[version] = ver
+			b.logger.Error("Storage write failed",
+				"operation", "release_version",
+				"registry", registryName,
+				"package", packageName,
+				"version", version,
+				"error", err)
+			return persistError(ctx, err)
+		}
+	}
+
+	b.logger.Info("Scheduled version released",
+		"registry", registryName,
+		"package", packageName,
+		"version", version)
+	return nil
+}
+
+// CancelVersion deletes a version that is still embargoed.
+// Returns ErrNotScheduled if the version is already public, so a cancel
+// racing with the release never removes a published version.
+// The persist callback is called after the in-memory operation succeeds.
+func (b *BaseStorage) CancelVersion(ctx context.Context, registryName, packageName, version string, persist PersistFunc) error {
+	ctx, unlock, err := b.lock(ctx, "cancel_version")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	pkg, ver, err := b.getVersionLocked(registryName, packageName, version)
+	if err != nil {
+		return err
+	}
+	if !ver.IsPending(time.Now()) {
+		return ErrNotScheduled
+	}
+
+	delete(pkg.Versions, version)
+	undo := b.changeLocked(models.RecordKey(registryName, packageName, version), true)
+	undoHistory := b.recordLocked(ctx, registryName, packageName, models.ChangeRecord{Type: models.ChangeVersionCancelled, Version: version})
+
+	// Persist
+	if persist != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
+			// Rollback
+			undoHistory()
+			undo()
+			pkg.Versions[version] = ver
+			b.logger.Error("Storage write failed",
+				"operation", "cancel_version",
+				"registry", registryName,
+				"package", packageName,
+				"version", version,
+				"error", err)
+			return persistError(ctx, err)
+		}
+	}
+
+	b.logger.Info("Scheduled version cancelled",
+		"registry", registryName,
+		"package", packageName,
+		"version", version)
+	return nil
+}
+
+// getVersionLocked looks up a version and its package.
+// Caller MUST hold at least a read lock.
+func (b *BaseStorage) getVersionLocked(registryName, packageName, version string) (*models.Package, *models.Version, error) {
+	registry, exists := b.data.Registries[registryName]
+	if !exists {
+		return nil, nil, ErrNotFound
+	}
+	pkg, exists := registry.Packages[packageName]
+	if !exists {
+		return nil, nil, ErrNotFound
+	}
+	ver, exists := pkg.Versions[version]
+	if !exists {
+		return nil, nil, ErrNotFound
+	}
+	return pkg, ver, nil
+}
+
+// ReleaseDueVersions releases every scheduled version whose publish_at has
+// passed. Versions are already served in index.json from that moment on;
+// releasing records it durably and lets event sinks announce the publication.
+func ReleaseDueVersions(ctx context.Context, store Store, now time.Time) (int, error) {
+	registries, err := store.ListRegistries(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, registry := range registries {
+		for _, pkg := range registry.Packages {
+			for _, ver := range pkg.Versions {
+				if ver.PublishAt == nil || ver.IsPending(now) {
+					continue
+				}
+				err := store.ReleaseVersion(ctx, registry.Name, pkg.Name, ver.Version)
+				if err == ErrNotScheduled || err == ErrNotFound {
+					// Released or deleted concurrently
+					continue
+				}
+				if err != nil {
+					return released, err
+				}
+				released++
+			}
+		}
+	}
+	return released, nil
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scheduledVersion(version string, startPartition, endPartition int, publishAt time.Time) *models.Version {
+	v := models.NewVersion("pkg", version, "sha256:a", "http://x/"+version+".zip", startPartition, endPartition)
+	v.PublishAt = &publishAt
+	return v
+}
+
+func TestBaseStorage_GetRegistryIndex_ExcludesScheduledVersions(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip", 0, 3), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", scheduledVersion("2.0.0", 4, 6, time.Now().Add(time.Hour)), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", scheduledVersion("0.9.0", 7, 9, time.Now().Add(-time.Minute)), nil))
+
+	index, err := bs.GetRegistryIndex(ctx, "reg")
+	require.NoError(t, err)
+
+	var versions []string
+	for _, entry := range index {
+		versions = append(versions, entry.Version)
+	}
+	assert.ElementsMatch(t, []string{"1.0.0", "0.9.0"}, versions)
+}
+
+func TestBaseStorage_ReleaseVersion(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", scheduledVersion("1.0.0", 0, 9, time.Now().Add(time.Hour)), nil))
+
+	// Persist failure keeps the embargo
+	err := bs.ReleaseVersion(ctx, "reg", "pkg", "1.0.0", func(context.Context) error { return errors.New("disk full") })
+	assert.Equal(t, ErrStorageUnavailable, err)
+	v, err := bs.GetVersion(ctx, "reg", "pkg", "1.0.0")
+	require.NoError(t, err)
+	assert.NotNil(t, v.PublishAt)
+
+	require.NoError(t, bs.ReleaseVersion(ctx, "reg", "pkg", "1.0.0", nil))
+	v, err = bs.GetVersion(ctx, "reg", "pkg", "1.0.0")
+	require.NoError(t, err)
+	assert.Nil(t, v.PublishAt)
+
+	index, err := bs.GetRegistryIndex(ctx, "reg")
+	require.NoError(t, err)
+	assert.Len(t, index, 1)
+
+	assert.Equal(t, ErrNotScheduled, bs.ReleaseVersion(ctx, "reg", "pkg", "1.0.0", nil))
+	assert.Equal(t, ErrNotFound, bs.ReleaseVersion(ctx, "reg", "pkg", "9.9.9", nil))
+}
+
+func TestBaseStorage_CancelVersion(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip", 0, 4), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", scheduledVersion("2.0.0", 5, 9, time.Now().Add(time.Hour)), nil))
+
+	// Published versions cannot be cancelled
+	assert.Equal(t, ErrNotScheduled, bs.CancelVersion(ctx, "reg", "pkg", "1.0.0", nil))
+
+	require.NoError(t, bs.CancelVersion(ctx, "reg", "pkg", "2.0.0", nil))
+	_, err := bs.GetVersion(ctx, "reg", "pkg", "2.0.0")
+	assert.Equal(t, ErrNotFound, err)
+
+	// The cancellation is replicated as a tombstone
+	changes, _, err := bs.Changes(ctx, 0)
+	require.NoError(t, err)
+	last := changes[len(changes)-1]
+	assert.Equal(t, models.SyncOpDelete, last.Op)
+	assert.Equal(t, "2.0.0", last.Version)
+}
+
+func TestReleaseDueVersions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	store, err := NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	now := time.Now()
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.NoError(t, store.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip", 0, 2)))
+	require.NoError(t, store.CreateVersion(ctx, "reg", "pkg", scheduledVersion("2.0.0", 3, 5, now.Add(-time.Minute))))
+	require.NoError(t, store.CreateVersion(ctx, "reg", "pkg", scheduledVersion("3.0.0", 6, 9, now.Add(time.Hour))))
+
+	released, err := ReleaseDueVersions(ctx, store, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, released)
+
+	v, err := store.GetVersion(ctx, "reg", "pkg", "2.0.0")
+	require.NoError(t, err)
+	assert.Nil(t, v.PublishAt)
+	v, err = store.GetVersion(ctx, "reg", "pkg", "3.0.0")
+	require.NoError(t, err)
+	assert.NotNil(t, v.PublishAt)
+
+	// Nothing left to do until the next one falls due
+	released, err = ReleaseDueVersions(ctx, store, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, released)
+
+	released, err = ReleaseDueVersions(ctx, store, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, released)
+}
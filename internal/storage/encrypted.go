@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/secrets"
+)
+
+// EncryptedStore wraps a Store and encrypts custom values whose keys are
+// marked sensitive on their registry before they reach the backend.
+// Values are decrypted on read; masking for non-admin callers is left to
+// the HTTP layer. Methods not overridden pass straight through.
+type EncryptedStore struct {
+	Store
+	cipher *secrets.Cipher
+	logger *slog.Logger
+}
+
+// NewEncryptedStore creates a new encrypting store.
+// cipher may be nil, in which case writes that would need encryption fail
+// with ErrEncryptionNotConfigured and reads are returned as stored.
+func NewEncryptedStore(store Store, cipher *secrets.Cipher, logger *slog.Logger) *EncryptedStore {
+	return &EncryptedStore{
+		Store:  store,
+		cipher: cipher,
+		logger: logger,
+	}
+}
+
+// CreateRegistry encrypts sensitive registry values and creates the registry
+func (s *EncryptedStore) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	values, err := s.encryptValues(r.CustomValues, r.SensitiveKeys)
+	if err != nil {
+		return err
+	}
+
+	encrypted := *r
+	encrypted.CustomValues = values
+	if err := s.Store.CreateRegistry(ctx, &encrypted); err != nil {
+		return err
+	}
+	r.CreatedAt, r.UpdatedAt = encrypted.CreatedAt, encrypted.UpdatedAt
+	return nil
+}
+
+// GetRegistry returns the registry with sensitive values decrypted
+func (s *EncryptedStore) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	registry, err := s.Store.GetRegistry(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptRegistry(registry), nil
+}
+
+// UpdateRegistry encrypts sensitive registry values and updates the registry.
+// Packages holding plaintext values for newly sensitive keys are re-saved encrypted.
+func (s *EncryptedStore) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	values, err := s.encryptValues(r.CustomValues, r.SensitiveKeys)
+	if err != nil {
+		return err
+	}
+
+	// Prepare package re-encryption up front so a missing key fails before any write
+	packages, err := s.Store.ListPackages(ctx, r.Name)
+	if err != nil {
+		return err
+	}
+	var reencrypt []*models.Package
+	for _, pkg := range packages {
+		if !s.needsEncryption(pkg.CustomValues, r.SensitiveKeys) {
+			continue
+		}
+		pkgValues, err := s.encryptValues(pkg.CustomValues, r.SensitiveKeys)
+		if err != nil {
+			return err
+		}
+		encrypted := *pkg
+		encrypted.CustomValues = pkgValues
+		reencrypt = append(reencrypt, &encrypted)
+	}
+
+	encrypted := *r
+	encrypted.CustomValues = values
+	if err := s.Store.UpdateRegistry(ctx, &encrypted); err != nil {
+		return err
+	}
+	r.CreatedAt, r.UpdatedAt = encrypted.CreatedAt, encrypted.UpdatedAt
+
+	for _, pkg := range reencrypt {
+		if err := s.Store.UpdatePackage(ctx, r.Name, pkg); err != nil {
+			return err
+		}
+		s.logger.Info("Package custom values encrypted",
+			"registry", r.Name,
+			"package", pkg.Name)
+	}
+
+	return nil
+}
+
+// ListRegistries returns all registries with sensitive values decrypted
+func (s *EncryptedStore) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	registries, err := s.Store.ListRegistries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]*models.Registry, 0, len(registries))
+	for _, registry := range registries {
+		decrypted = append(decrypted, s.decryptRegistry(registry))
+	}
+	return decrypted, nil
+}
+
+// CreatePackage encrypts sensitive package values and creates the package
+func (s *EncryptedStore) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	encrypted, err := s.encryptPackage(ctx, registryName, p)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.CreatePackage(ctx, registryName, encrypted); err != nil {
+		return err
+	}
+	p.CreatedAt, p.UpdatedAt = encrypted.CreatedAt, encrypted.UpdatedAt
+	return nil
+}
+
+// GetPackage returns the package with sensitive values decrypted
+func (s *EncryptedStore) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	pkg, err := s.Store.GetPackage(ctx, registryName, packageName)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptPackage(pkg), nil
+}
+
+// UpdatePackage encrypts sensitive package values and updates the package
+func (s *EncryptedStore) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	encrypted, err := s.encryptPackage(ctx, registryName, p)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.UpdatePackage(ctx, registryName, encrypted); err != nil {
+		return err
+	}
+	p.CreatedAt, p.UpdatedAt = encrypted.CreatedAt, encrypted.UpdatedAt
+	return nil
+}
+
+// UpdatePackages encrypts sensitive values of every package and updates them
+func (s *EncryptedStore) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	encrypted := make([]*models.Package, 0, len(packages))
+	for _, p := range packages {
+		pkg, err := s.encryptPackage(ctx, registryName, p)
+		if err != nil {
+			return err
+		}
+		encrypted = append(encrypted, pkg)
+	}
+	if err := s.Store.UpdatePackages(ctx, registryName, encrypted); err != nil {
+		return err
+	}
+	for i, p := range packages {
+		p.CreatedAt, p.UpdatedAt = encrypted[i].CreatedAt, encrypted[i].UpdatedAt
+	}
+	return nil
+}
+
+// ListPackages returns all packages with sensitive values decrypted
+func (s *EncryptedStore) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	packages, err := s.Store.ListPackages(ctx, registryName)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]*models.Package, 0, len(packages))
+	for _, pkg := range packages {
+		decrypted = append(decrypted, s.decryptPackage(pkg))
+	}
+	return decrypted, nil
+}
+
+// FindPackages returns the packages matching a query with sensitive values decrypted.
+// Sensitive values are stored with a random nonce, so queries on them never match.
+func (s *EncryptedStore) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	packages, err := s.Store.FindPackages(ctx, registryName, query)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]*models.Package, 0, len(packages))
+	for _, pkg := range packages {
+		decrypted = append(decrypted, s.decryptPackage(pkg))
+	}
+	return decrypted, nil
+}
+
+// encryptPackage returns a copy of p with values encrypted per its registry's sensitive keys.
+// Callers copy the timestamps storage sets on it back onto p, which responses are built from.
+func (s *EncryptedStore) encryptPackage(ctx context.Context, registryName string, p *models.Package) (*models.Package, error) {
+	registry, err := s.Store.GetRegistry(ctx, registryName)
+	if err != nil {
+		// Let the backend report the missing registry
+		return p, nil
+	}
+
+	values, err := s.encryptValues(p.CustomValues, registry.SensitiveKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := *p
+	encrypted.CustomValues = values
+	return &encrypted, nil
+}
+
+// needsEncryption reports whether any sensitive key holds a plaintext value
+func (s *EncryptedStore) needsEncryption(values map[string]string, sensitiveKeys []string) bool {
+	for _, key := range sensitiveKeys {
+		if value, ok := values[key]; ok && value != "" && !secrets.IsEncrypted(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptValues returns a copy of values with sensitive keys encrypted
+func (s *EncryptedStore) encryptValues(values map[string]string, sensitiveKeys []string) (map[string]string, error) {
+	if !s.needsEncryption(values, sensitiveKeys) {
+		return values, nil
+	}
+	if s.cipher == nil {
+		return nil, ErrEncryptionNotConfigured
+	}
+
+	encrypted := make(map[string]string, len(values))
+	for k, v := range values {
+		encrypted[k] = v
+	}
+	for _, key := range sensitiveKeys {
+		value, ok := encrypted[key]
+		if !ok || value == "" {
+			continue
+		}
+		ciphertext, err := s.cipher.Encrypt(value)
+		if err != nil {
+			return nil, err
+		}
+		encrypted[key] = ciphertext
+	}
+	return encrypted, nil
+}
+
+// decryptValues returns a copy of values with encrypted entries decrypted.
+// Values that cannot be decrypted are left as stored and logged.
+func (s *EncryptedStore) decryptValues(values map[string]string) map[string]string {
+	if s.cipher == nil || values == nil {
+		return values
+	}
+
+	decrypted := make(map[string]string, len(values))
+	for k, v := range values {
+		plaintext, err := s.cipher.Decrypt(v)
+		if err != nil {
+			s.logger.Error("Failed to decrypt custom value",
+				"key", k,
+				"error", err)
+			plaintext = v
+		}
+		decrypted[k] = plaintext
+	}
+	return decrypted
+}
+
+// decryptRegistry returns a decrypted copy of a registry and its packages.
+// The backend's in-memory data is never modified.
+func (s *EncryptedStore) decryptRegistry(r *models.Registry) *models.Registry {
+	decrypted := *r
+	decrypted.CustomValues = s.decryptValues(r.CustomValues)
+
+	if r.Packages != nil {
+		decrypted.Packages = make(map[string]*models.Package, len(r.Packages))
+		for name, pkg := range r.Packages {
+			decrypted.Packages[name] = s.decryptPackage(pkg)
+		}
+	}
+	return &decrypted
+}
+
+// decryptPackage returns a decrypted copy of a package
+func (s *EncryptedStore) decryptPackage(p *models.Package) *models.Package {
+	decrypted := *p
+	decrypted.CustomValues = s.decryptValues(p.CustomValues)
+	return &decrypted
+}
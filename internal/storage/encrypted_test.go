@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEncryptedStore(t *testing.T, withKey bool) (*EncryptedStore, *FileStorage, string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	path := filepath.Join(t.TempDir(), "registry.json")
+
+	fs, err := NewFileStorage(path, "", logger)
+	require.NoError(t, err)
+
+	var c *secrets.Cipher
+	if withKey {
+		c, err = secrets.NewCipher([]byte(strings.Repeat("k", secrets.KeySize)))
+		require.NoError(t, err)
+	}
+	return NewEncryptedStore(fs, c, logger), fs, path
+}
+
+func TestEncryptedStore_EncryptsSensitiveValuesAtRest(t *testing.T) {
+	store, _, path := newTestEncryptedStore(t, true)
+	ctx := context.Background()
+
+	registry := models.NewRegistry("tools", "", nil, map[string]string{
+		"api_key": "s3cr3t-registry",
+		"team":    "build",
+	})
+	registry.SensitiveKeys = []string{"api_key"}
+	require.NoError(t, store.CreateRegistry(ctx, registry))
+
+	pkg := models.NewPackage("deployer", "", nil, map[string]string{"api_key": "s3cr3t-package"})
+	require.NoError(t, store.CreatePackage(ctx, "tools", pkg))
+
+	// Persisted file never contains the plaintext
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cr3t")
+	assert.Contains(t, string(data), secrets.Prefix)
+	assert.Contains(t, string(data), `"team":"build"`)
+
+	// Reads are decrypted
+	got, err := store.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t-registry", got.CustomValues["api_key"])
+	assert.Equal(t, "s3cr3t-package", got.Packages["deployer"].CustomValues["api_key"])
+
+	gotPkg, err := store.GetPackage(ctx, "tools", "deployer")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t-package", gotPkg.CustomValues["api_key"])
+
+	// Caller input is not modified
+	assert.Equal(t, "s3cr3t-registry", registry.CustomValues["api_key"])
+}
+
+func TestEncryptedStore_MarkingKeySensitiveEncryptsExistingPackages(t *testing.T) {
+	store, fs, _ := newTestEncryptedStore(t, true)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, map[string]string{"token": "plain"})))
+
+	registry, err := store.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	registry.SensitiveKeys = []string{"token"}
+	require.NoError(t, store.UpdateRegistry(ctx, registry))
+
+	raw, err := fs.GetPackage(ctx, "tools", "deployer")
+	require.NoError(t, err)
+	assert.True(t, secrets.IsEncrypted(raw.CustomValues["token"]))
+
+	pkg, err := store.GetPackage(ctx, "tools", "deployer")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", pkg.CustomValues["token"])
+}
+
+func TestEncryptedStore_RequiresKeyForSensitiveValues(t *testing.T) {
+	store, _, _ := newTestEncryptedStore(t, false)
+	ctx := context.Background()
+
+	registry := models.NewRegistry("tools", "", nil, map[string]string{"api_key": "secret"})
+	registry.SensitiveKeys = []string{"api_key"}
+	assert.ErrorIs(t, store.CreateRegistry(ctx, registry), ErrEncryptionNotConfigured)
+
+	// Marking keys without values needs no key
+	registry.CustomValues = nil
+	require.NoError(t, store.CreateRegistry(ctx, registry))
+
+	pkg := models.NewPackage("deployer", "", nil, map[string]string{"api_key": "secret"})
+	assert.ErrorIs(t, store.CreatePackage(ctx, "tools", pkg), ErrEncryptionNotConfigured)
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// EnvelopeKeySize is the required length of the key encryption key in bytes
+// (AES-256)
+const EnvelopeKeySize = 32
+
+// EnvelopeAlgorithm names the encryption of sealed data, e.g. in OCI
+// annotations
+const EnvelopeAlgorithm = "aes-256-gcm"
+
+// envelopeMagic marks data sealed by an Envelope. The version allows
+// changing the scheme later without ambiguity.
+var envelopeMagic = []byte("COLAENC1")
+
+// envelopeKeyIDSize is the length of the key fingerprint stored in sealed
+// data, so data sealed with another key is reported as such
+const envelopeKeyIDSize = 8
+
+var (
+	// ErrEnvelopeKeyRequired is returned when loading encrypted data
+	// without an encryption key
+	ErrEnvelopeKeyRequired = errors.New("storage data is encrypted but no storage encryption key is configured")
+
+	// ErrEnvelopeKeyMismatch is returned when loading data encrypted with
+	// another key
+	ErrEnvelopeKeyMismatch = errors.New("storage data is encrypted with another key")
+)
+
+// Envelope encrypts persisted data with AES-256-GCM envelope encryption:
+// each write is sealed with a random data key, itself sealed with the key
+// encryption key and stored in front of the data. Loading recognizes sealed
+// data, so data written before encryption was enabled still loads.
+//
+// Sealed data is the magic, the key fingerprint, the sealed data key (nonce,
+// ciphertext and tag) then the sealed data (nonce, ciphertext and tag).
+type Envelope struct {
+	kek   cipher.AEAD
+	keyID []byte
+}
+
+// NewEnvelope creates an envelope from a 32-byte key encryption key
+func NewEnvelope(key []byte) (*Envelope, error) {
+	if len(key) != EnvelopeKeySize {
+		return nil, fmt.Errorf("storage encryption key must be %d bytes, got %d", EnvelopeKeySize, len(key))
+	}
+	kek, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &Envelope{kek: kek, keyID: sum[:envelopeKeyIDSize]}, nil
+}
+
+// NewEnvelopeFromBase64 creates an envelope from a base64-encoded 32-byte
+// key (e.g. the output of `openssl rand -base64 32`)
+func NewEnvelopeFromBase64(encoded string) (*Envelope, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("storage encryption key is not valid base64: %w", err)
+	}
+	return NewEnvelope(key)
+}
+
+// NewEnvelopeFromFile creates an envelope from a file holding a
+// base64-encoded key, such as one a KMS or secret manager agent writes
+func NewEnvelopeFromFile(path string) (*Envelope, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage encryption key file: %w", err)
+	}
+	return NewEnvelopeFromBase64(string(encoded))
+}
+
+// isSealed reports whether data was sealed by an Envelope
+func isSealed(data []byte) bool {
+	return bytes.HasPrefix(data, envelopeMagic)
+}
+
+// Seal encrypts data under a new data key. A nil envelope returns data
+// unchanged.
+func (e *Envelope) Seal(data []byte) ([]byte, error) {
+	if e == nil {
+		return data, nil
+	}
+	dataKey := make([]byte, EnvelopeKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	dek, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, envelopeMagic...), e.keyID...)
+	sealedKey, err := sealGCM(e.kek, dataKey, header)
+	if err != nil {
+		return nil, err
+	}
+	sealedData, err := sealGCM(dek, data, header)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(header)+len(sealedKey)+len(sealedData))
+	out = append(out, header...)
+	out = append(out, sealedKey...)
+	return append(out, sealedData...), nil
+}
+
+// Open decrypts data sealed by Seal. Data that is not sealed is returned
+// unchanged, with or without an envelope.
+func (e *Envelope) Open(data []byte) ([]byte, error) {
+	if !isSealed(data) {
+		return data, nil
+	}
+	if e == nil {
+		return nil, ErrEnvelopeKeyRequired
+	}
+
+	headerSize := len(envelopeMagic) + envelopeKeyIDSize
+	sealedKeySize := e.kek.NonceSize() + EnvelopeKeySize + e.kek.Overhead()
+	if len(data) < headerSize+sealedKeySize {
+		return nil, errors.New("encrypted storage data is truncated")
+	}
+	header := data[:headerSize]
+	if !bytes.Equal(header[len(envelopeMagic):], e.keyID) {
+		return nil, ErrEnvelopeKeyMismatch
+	}
+
+	dataKey, err := openGCM(e.kek, data[headerSize:headerSize+sealedKeySize], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	dek, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openGCM(dek, data[headerSize+sealedKeySize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt storage data: %w", err)
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealGCM encrypts plaintext under a random nonce, returned in front of the
+// ciphertext
+func sealGCM(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// openGCM decrypts the output of sealGCM
+func openGCM(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
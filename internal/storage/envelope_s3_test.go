@@ -0,0 +1,42 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestS3Storage_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "bucket")
+	uri, err := storage.ParseStorageURI(strings.Replace(s3.URL, "http://", "s3+http://", 1) + "/bucket/registry.json?region=us-east-1")
+	require.NoError(t, err)
+	envelope, err := storage.NewEnvelope(bytes.Repeat([]byte{1}, storage.EnvelopeKeySize))
+	require.NoError(t, err)
+	opts := storage.Options{Envelope: envelope, Retry: storage.RetryPolicy{MaxAttempts: 1}}
+
+	store, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", opts, newConformanceLogger())
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Build tools", nil, nil)))
+
+	s3.mu.Lock()
+	stored := s3.objects["registry.json"]
+	s3.mu.Unlock()
+	assert.False(t, bytes.Contains(stored, []byte("tools")), "object is encrypted")
+
+	reopened, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", opts, newConformanceLogger())
+	require.NoError(t, err)
+	_, err = reopened.GetRegistry(ctx, "tools")
+	assert.NoError(t, err)
+
+	opts.Envelope = nil
+	_, err = storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", opts, newConformanceLogger())
+	assert.ErrorIs(t, err, storage.ErrEnvelopeKeyRequired)
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func newTestEnvelope(t *testing.T, fill byte) *Envelope {
+	e, err := NewEnvelope(bytes.Repeat([]byte{fill}, EnvelopeKeySize))
+	require.NoError(t, err)
+	return e
+}
+
+func TestNewEnvelope_KeySize(t *testing.T) {
+	_, err := NewEnvelope([]byte("too short"))
+	assert.Error(t, err)
+
+	_, err = NewEnvelopeFromBase64("not base64!")
+	assert.Error(t, err)
+
+	e, err := NewEnvelopeFromBase64(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, EnvelopeKeySize)) + "\n")
+	require.NoError(t, err)
+	assert.NotNil(t, e)
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, EnvelopeKeySize))), 0600))
+	_, err = NewEnvelopeFromFile(keyFile)
+	assert.NoError(t, err)
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	e := newTestEnvelope(t, 1)
+	plain := []byte(`{"registries":{"tools":{"name":"tools"}}}`)
+
+	sealed, err := e.Seal(plain)
+	require.NoError(t, err)
+	assert.True(t, isSealed(sealed))
+	assert.False(t, bytes.Contains(sealed, []byte("tools")))
+
+	// Each seal uses a new data key
+	again, err := e.Seal(plain)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again)
+
+	opened, err := e.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+
+	// Tampered data is refused
+	sealed[len(sealed)-1] ^= 0xff
+	_, err = e.Open(sealed)
+	assert.Error(t, err)
+}
+
+func TestEnvelope_Keys(t *testing.T) {
+	plain := []byte(`{"registries":{}}`)
+	sealed, err := newTestEnvelope(t, 1).Seal(plain)
+	require.NoError(t, err)
+
+	_, err = newTestEnvelope(t, 2).Open(sealed)
+	assert.ErrorIs(t, err, ErrEnvelopeKeyMismatch)
+
+	var none *Envelope
+	_, err = none.Open(sealed)
+	assert.ErrorIs(t, err, ErrEnvelopeKeyRequired)
+
+	// Without an envelope, data is left alone
+	unsealed, err := none.Seal(plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, unsealed)
+
+	// Data written before encryption was enabled still loads
+	opened, err := newTestEnvelope(t, 1).Open(plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+}
+
+func TestNewStorage_FileEncrypted(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	path := filepath.Join(t.TempDir(), "registry.json")
+	uri, err := ParseStorageURI("file://" + path)
+	require.NoError(t, err)
+	ctx := context.Background()
+	opts := Options{Envelope: newTestEnvelope(t, 1), Compression: CompressionZstd}
+
+	store, err := NewStorage(uri, "", opts, logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Build tools", []string{"alice@example.com"}, nil)))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, isSealed(raw))
+	assert.False(t, bytes.Contains(raw, []byte("alice@example.com")))
+
+	reopened, err := NewStorage(uri, "", opts, logger)
+	require.NoError(t, err)
+	_, err = reopened.GetRegistry(ctx, "tools")
+	assert.NoError(t, err)
+
+	_, err = NewStorage(uri, "", Options{}, logger)
+	assert.ErrorIs(t, err, ErrEnvelopeKeyRequired)
+
+	// Convert decrypts with the key, and encrypts again unless told not to
+	converted, err := Convert(raw, Options{Envelope: opts.Envelope})
+	require.NoError(t, err)
+	assert.Equal(t, 1, converted.RegistryCount)
+	assert.True(t, isSealed(converted.Data))
+	_, err = Convert(raw, Options{})
+	assert.ErrorIs(t, err, ErrEnvelopeKeyRequired)
+}
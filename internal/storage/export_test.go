@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// FakeOCIRegistry exposes fakeOCI to the external tests of this package
+// (storage_test), which run the conformance suite: it cannot be imported
+// from here without an import cycle
+type FakeOCIRegistry struct {
+	registry *fakeOCI
+}
+
+// NewFakeOCIRegistry starts an in-memory OCI registry serving repo
+func NewFakeOCIRegistry(t *testing.T, repo string) *FakeOCIRegistry {
+	return &FakeOCIRegistry{registry: newFakeOCI(t, repo)}
+}
+
+// Open opens OCI storage on the latest tag of the registry, over plain HTTP
+func (r *FakeOCIRegistry) Open(t *testing.T, opts Options, logger *slog.Logger) *OCIStorage {
+	client, err := NewOCIClient(r.registry.Host+"/"+r.registry.repo+":latest", "", logger)
+	require.NoError(t, err)
+	client.repository.PlainHTTP = true
+	client.revisions = opts.OCIRevisions
+	client.SetRetryPolicy(opts.Retry)
+	s, err := newOCIStorage(client, opts, logger)
+	require.NoError(t, err)
+	return s
+}
+
+// FailPushes makes manifest pushes fail until called again with false
+func (r *FakeOCIRegistry) FailPushes(fail bool) {
+	if fail {
+		r.registry.FailManifestPuts(math.MaxInt)
+	} else {
+		r.registry.FailManifestPuts(0)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 )
 
 var (
@@ -11,26 +12,143 @@ var (
 	ErrTokenRequired = errors.New("storage token required")
 )
 
+// Options tunes how a backend encodes the data it persists
+type Options struct {
+	// Codec is the serialization format (JSON by default). Loading detects
+	// the stored codec, so switching converts the data on the next write.
+	Codec Codec
+
+	// PrettyJSON indents the stored JSON so it stays human-readable.
+	// Only file and Git storage with the JSON codec honour it; S3, GCS,
+	// OCI and WebDAV blobs are always compact.
+	PrettyJSON bool
+
+	// Compression compresses the S3 or GCS object, OCI layer or WebDAV file. Loading detects
+	// the stored format, so existing uncompressed data keeps working.
+	// File storage is never compressed.
+	Compression Compression
+
+	// Envelope encrypts the data file storage, S3, GCS, OCI and WebDAV
+	// storage persist, and their cache file. Loading detects encrypted
+	// data, so existing plain data keeps working. Nil writes plain data.
+	Envelope *Envelope
+
+	// LoadTimeout bounds the initial pull of S3, GCS, OCI, DynamoDB and WebDAV data,
+	// which can take long for large datasets. Zero keeps the default
+	// download timeout.
+	LoadTimeout time.Duration
+
+	// ReadTimeout and WriteTimeout bound each storage operation, on top of
+	// the caller's own deadline; an operation running out of time returns
+	// ErrTimeout. Zero leaves only the caller's deadline.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// CacheFile is where S3, GCS, OCI and WebDAV storage keep a local copy of the data,
+	// rewritten after each load and write, for OpenCache to serve when the
+	// backend is unavailable at boot. Empty disables the cache; file
+	// storage ignores it.
+	CacheFile string
+
+	// PersistMode decides when S3, GCS, OCI and WebDAV storage persist
+	// writes: each one before it returns (the default), or in debounced mode
+	// all the writes of FlushInterval (DefaultFlushInterval when zero) at
+	// once, in the background. Other backends always persist immediately.
+	PersistMode   PersistMode
+	FlushInterval time.Duration
+
+	// Retry decides how S3, GCS, OCI, DynamoDB and WebDAV clients retry failed
+	// operations. The zero value is DefaultRetryPolicy.
+	Retry RetryPolicy
+
+	// Credentials authenticate S3, GCS, OCI, DynamoDB and WebDAV storage instead of
+	// the token argument, falling back to their secondary token when the
+	// backend rejects the primary one. Nil uses the token alone.
+	Credentials *Credentials
+
+	// OCIRevisions is how many revision tags (rev-000123) OCI storage keeps
+	// besides the reference tag, for Versioned.Rollback; older revisions are
+	// deleted. Zero disables revision tags.
+	OCIRevisions int
+
+	// GitAuthor and GitCommitter sign the commits of Git storage. The
+	// zero value is DefaultGitSignature.
+	GitAuthor    GitSignature
+	GitCommitter GitSignature
+}
+
 // NewStorage creates a storage backend based on the URI scheme.
 // Returns an appropriate Store implementation based on the URI scheme:
 //   - file:// -> FileStorage
+//   - sqlite:// -> SQLiteStorage
+//   - postgres:// or postgresql:// -> PostgresStorage
 //   - oci:// -> OCIStorage (requires token)
 //   - s3:// or s3+http:// -> S3Storage
-func NewStorage(uri *StorageURI, token string, logger *slog.Logger) (Store, error) {
+//   - gcs:// -> GCSStorage
+//   - git://, git+https://, git+http:// or git+file:// -> GitStorage
+//   - redis:// or rediss:// -> RedisStorage
+//   - dynamodb:// -> DynamoDBStorage
+//   - http+dav:// or https+dav:// -> WebDAVStorage
+func NewStorage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (Store, error) {
+	if opts.PrettyJSON && ((!uri.IsFileScheme() && !uri.IsGitScheme()) || opts.Codec == CodecCBOR) {
+		logger.Warn("Pretty JSON is only supported by file and Git storage with the JSON codec, ignoring it",
+			"scheme", uri.Scheme,
+			"codec", opts.Codec)
+	}
+	if opts.Compression.Enabled() && (uri.IsLocal() || uri.IsPostgresScheme() || uri.IsRedisScheme() || uri.IsDynamoDBScheme() || uri.IsGitScheme()) {
+		logger.Warn("Compression is only supported by S3, GCS, OCI and WebDAV storage, writing uncompressed data",
+			"compression", opts.Compression)
+	}
+	if opts.Envelope != nil && (uri.IsSQLiteScheme() || uri.IsPostgresScheme() || uri.IsRedisScheme() || uri.IsDynamoDBScheme() || uri.IsGitScheme()) {
+		logger.Warn("Storage encryption is only supported by file, S3, GCS, OCI and WebDAV storage, writing plain data",
+			"scheme", uri.Scheme)
+	}
+	if opts.PersistMode == PersistDebounced && !uri.IsS3Scheme() && !uri.IsGCSScheme() && !uri.IsOCIScheme() && !uri.IsWebDAVScheme() {
+		logger.Warn("Debounced persistence is only supported by S3, GCS, OCI and WebDAV storage, persisting each write",
+			"scheme", uri.Scheme)
+	}
+	if opts.Codec == CodecCBOR && (uri.IsSQLiteScheme() || uri.IsPostgresScheme() || uri.IsRedisScheme() || uri.IsDynamoDBScheme()) {
+		logger.Warn("SQL, Redis and DynamoDB storage keep records as JSON, ignoring the CBOR codec")
+	}
+
 	switch uri.Scheme {
 	case "file":
-		return NewFileStorage(uri.Path, token, logger)
+		return newFileStorage(uri.Path, uri.FileLayout(), token, opts, logger)
+
+	case "sqlite":
+		return NewSQLiteStorage(uri.Path, token, opts, logger)
+
+	case "postgres", "postgresql":
+		return NewPostgresStorage(uri, token, opts, logger)
 
 	case "oci":
 		// Token is required for OCI storage
 		if token == "" {
 			return nil, fmt.Errorf("%w: OCI storage requires authentication token (--storage-token or COLA_REGISTRY_STORAGE_TOKEN)", ErrTokenRequired)
 		}
-		return NewOCIStorage(uri, token, logger)
+		return NewOCIStorage(uri, token, opts, logger)
 
 	case "s3", "s3+http":
 		// S3 storage (credentials optional for IAM role)
-		return NewS3Storage(uri, token, logger)
+		return NewS3Storage(uri, token, opts, logger)
+
+	case "gcs":
+		// GCS storage (Application Default Credentials without a token)
+		return NewGCSStorage(uri, token, opts, logger)
+
+	case "git", "git+https", "git+http", "git+file":
+		return NewGitStorage(uri, token, opts, logger)
+
+	case "redis", "rediss":
+		return NewRedisStorage(uri, token, opts, logger)
+
+	case "dynamodb":
+		// DynamoDB storage (AWS credential chain without a token)
+		return NewDynamoDBStorage(uri, token, opts, logger)
+
+	case "http+dav", "https+dav":
+		// WebDAV storage (URI credentials or anonymous without a token)
+		return NewWebDAVStorage(uri, token, opts, logger)
 
 	default:
 		return nil, fmt.Errorf("unsupported storage scheme: %s", uri.Scheme)
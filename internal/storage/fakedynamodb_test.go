@@ -0,0 +1,202 @@
+package storage_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDynamoDBPageSize is the number of items per Scan page, small so
+// tests go through pagination
+const fakeDynamoDBPageSize = 25
+
+// fakeDynamoDB is an in-memory DynamoDB JSON API server with one table
+// keyed by the string pk, serving the requests DynamoDBClient makes:
+// DescribeTable, paginated Scans and TransactWriteItems with their
+// conditions. Requests must be signed, but signatures are not checked.
+type fakeDynamoDB struct {
+	URL   string
+	table string
+
+	mu           sync.Mutex
+	items        map[string]string // data by pk
+	failWrites   bool
+	transactions int
+}
+
+func newFakeDynamoDB(t *testing.T, table string) *fakeDynamoDB {
+	s := &fakeDynamoDB{table: table, items: make(map[string]string)}
+	server := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(server.Close)
+	s.URL = server.URL
+	return s
+}
+
+// FailWrites makes transactions fail with 500 until called again with false
+func (s *fakeDynamoDB) FailWrites(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failWrites = fail
+}
+
+// Item returns the data of an item
+func (s *fakeDynamoDB) Item(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.items[key]
+	return data, ok
+}
+
+// SetItem writes an item, as another writer would
+func (s *fakeDynamoDB) SetItem(key, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = data
+}
+
+// Transactions returns the number of transactions committed
+func (s *fakeDynamoDB) Transactions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transactions
+}
+
+type fakeDynamoDBAction struct {
+	TableName                 string
+	Key                       map[string]map[string]string
+	Item                      map[string]map[string]string
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]map[string]string
+}
+
+func (s *fakeDynamoDB) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") {
+		writeDynamoDBError(w, http.StatusBadRequest, "MissingAuthenticationTokenException", "Request is missing Authentication Token", nil)
+		return
+	}
+	var input struct {
+		TableName         string
+		ExclusiveStartKey map[string]map[string]string
+		TransactItems     []struct {
+			Put    *fakeDynamoDBAction
+			Delete *fakeDynamoDBAction
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeDynamoDBError(w, http.StatusBadRequest, "SerializationException", err.Error(), nil)
+		return
+	}
+
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.") {
+	case "DescribeTable":
+		if input.TableName != s.table {
+			writeDynamoDBError(w, http.StatusBadRequest, "ResourceNotFoundException", "Requested resource not found", nil)
+			return
+		}
+		fmt.Fprintf(w, `{"Table":{"TableName":%q,"KeySchema":[{"AttributeName":"pk","KeyType":"HASH"}],"AttributeDefinitions":[{"AttributeName":"pk","AttributeType":"S"}]}}`, s.table)
+
+	case "Scan":
+		keys := make([]string, 0, len(s.items))
+		for key := range s.items {
+			if start := input.ExclusiveStartKey; start == nil || key > start["pk"]["S"] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		output := map[string]any{}
+		if len(keys) > fakeDynamoDBPageSize {
+			keys = keys[:fakeDynamoDBPageSize]
+			output["LastEvaluatedKey"] = map[string]any{"pk": map[string]string{"S": keys[len(keys)-1]}}
+		}
+		items := []any{}
+		for _, key := range keys {
+			items = append(items, map[string]any{"pk": map[string]string{"S": key}, "data": map[string]string{"S": s.items[key]}})
+		}
+		output["Items"] = items
+		json.NewEncoder(w).Encode(output)
+
+	case "TransactWriteItems":
+		if s.failWrites {
+			writeDynamoDBError(w, http.StatusInternalServerError, "InternalServerError", "Internal server error", nil)
+			return
+		}
+		if len(input.TransactItems) > 100 {
+			writeDynamoDBError(w, http.StatusBadRequest, "ValidationException", "Member must have length less than or equal to 100", nil)
+			return
+		}
+		reasons := make([]string, len(input.TransactItems))
+		failed := false
+		for i, item := range input.TransactItems {
+			action := item.Put
+			if action == nil {
+				action = item.Delete
+			}
+			reasons[i] = "None"
+			if !s.conditionHolds(action) {
+				reasons[i] = "ConditionalCheckFailed"
+				failed = true
+			}
+		}
+		if failed {
+			writeDynamoDBError(w, http.StatusBadRequest, "TransactionCanceledException", "Transaction cancelled", reasons)
+			return
+		}
+		for _, item := range input.TransactItems {
+			if item.Put != nil {
+				s.items[item.Put.Item["pk"]["S"]] = item.Put.Item["data"]["S"]
+			} else {
+				delete(s.items, item.Delete.Key["pk"]["S"])
+			}
+		}
+		s.transactions++
+		fmt.Fprint(w, `{}`)
+
+	default:
+		writeDynamoDBError(w, http.StatusBadRequest, "UnknownOperationException", "", nil)
+	}
+}
+
+// conditionHolds evaluates the conditions DynamoDBClient writes
+func (s *fakeDynamoDB) conditionHolds(action *fakeDynamoDBAction) bool {
+	key := action.Key
+	if key == nil {
+		key = action.Item
+	}
+	current, exists := s.items[key["pk"]["S"]]
+	switch action.ConditionExpression {
+	case "":
+		return true
+	case "attribute_not_exists(#key)":
+		return !exists
+	case "#data = :previous":
+		return exists && current == action.ExpressionAttributeValues[":previous"]["S"]
+	default:
+		return false
+	}
+}
+
+func writeDynamoDBError(w http.ResponseWriter, status int, exception, message string, reasons []string) {
+	answer := map[string]any{
+		"__type":  "com.amazonaws.dynamodb.v20120810#" + exception,
+		"message": message,
+	}
+	if reasons != nil {
+		codes := make([]map[string]string, len(reasons))
+		for i, reason := range reasons {
+			codes[i] = map[string]string{"Code": reason}
+		}
+		answer["CancellationReasons"] = codes
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(answer)
+}
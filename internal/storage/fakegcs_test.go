@@ -0,0 +1,99 @@
+package storage_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGCS is an in-memory GCS JSON API server with one bucket, serving the
+// requests GCSClient makes: bucket checks, object metadata and media
+// downloads, and media uploads. Authentication is not checked.
+type fakeGCS struct {
+	URL    string
+	bucket string
+
+	mu          sync.Mutex
+	objects     map[string][]byte
+	failUploads bool
+}
+
+func newFakeGCS(t *testing.T, bucket string) *fakeGCS {
+	s := &fakeGCS{bucket: bucket, objects: make(map[string][]byte)}
+	server := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(server.Close)
+	s.URL = server.URL
+	return s
+}
+
+// FailUploads makes uploads fail with 503 until called again with false
+func (s *fakeGCS) FailUploads(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failUploads = fail
+}
+
+func (s *fakeGCS) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Object names are escaped as a single path segment
+	path := r.URL.EscapedPath()
+	if rest, ok := strings.CutPrefix(path, "/upload/storage/v1/b/"+s.bucket+"/o"); ok && rest == "" && r.Method == http.MethodPost {
+		if s.failUploads {
+			writeGCSError(w, http.StatusServiceUnavailable, "Backend Error")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeGCSError(w, http.StatusBadRequest, "Incomplete body")
+			return
+		}
+		name := r.URL.Query().Get("name")
+		s.objects[name] = data
+		fmt.Fprintf(w, `{"name":%q,"size":"%d"}`, name, len(data))
+		return
+	}
+
+	rest, ok := strings.CutPrefix(path, "/storage/v1/b/")
+	if !ok || r.Method != http.MethodGet {
+		writeGCSError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	bucket, object, _ := strings.Cut(rest, "/o/")
+	if bucket != s.bucket {
+		writeGCSError(w, http.StatusNotFound, "The specified bucket does not exist.")
+		return
+	}
+	if object == "" {
+		fmt.Fprintf(w, `{"name":%q}`, s.bucket)
+		return
+	}
+	name, err := url.PathUnescape(object)
+	if err != nil {
+		writeGCSError(w, http.StatusBadRequest, "Invalid object name")
+		return
+	}
+	data, ok := s.objects[name]
+	if !ok {
+		writeGCSError(w, http.StatusNotFound, "No such object: "+s.bucket+"/"+name)
+		return
+	}
+	if r.URL.Query().Get("alt") == "media" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+		return
+	}
+	fmt.Fprintf(w, `{"name":%q}`, name)
+}
+
+func writeGCSError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q}}`, status, message)
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeOCI is an in-memory OCI distribution registry with one repository,
+// serving the requests OCIClient makes: blob uploads and fetches, manifest
+// pushes, fetches and deletes by tag or digest, and tag listings.
+// Authentication is not checked.
+type fakeOCI struct {
+	Host string // host:port, served over plain HTTP
+	repo string
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte // By digest
+	tags      map[string]string // Tag to manifest digest
+	uploads   int
+	failPuts  int // Manifest pushes left to answer with 502 Bad Gateway
+	tagLists  int
+	racingTag string // Tagged to the reference tag's manifest after the next listing
+}
+
+func newFakeOCI(t *testing.T, repo string) *fakeOCI {
+	r := &fakeOCI{
+		repo:      repo,
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		tags:      make(map[string]string),
+	}
+	server := httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(server.Close)
+	r.Host = strings.TrimPrefix(server.URL, "http://")
+	return r
+}
+
+// Tags returns the tags of the repository, sorted
+func (r *fakeOCI) Tags() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tags := make([]string, 0, len(r.tags))
+	for tag := range r.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// TagLists returns how many times the tags were listed
+func (r *fakeOCI) TagLists() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tagLists
+}
+
+// TagAfterListing tags the manifest of the latest tag as tag right after
+// the next tag listing, like another writer racing a push
+func (r *fakeOCI) TagAfterListing(tag string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.racingTag = tag
+}
+
+// FailManifestPuts makes the next n manifest pushes fail with 502 Bad
+// Gateway, like a registry behind a flaky proxy
+func (r *fakeOCI) FailManifestPuts(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failPuts = n
+}
+
+func (r *fakeOCI) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/v2/" {
+		return
+	}
+	prefix := "/v2/" + r.repo + "/"
+	if !strings.HasPrefix(req.URL.Path, prefix) {
+		http.Error(w, `{"errors":[{"code":"NAME_UNKNOWN"}]}`, http.StatusNotFound)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, prefix)
+
+	switch {
+	case path == "tags/list":
+		tags := make([]string, 0, len(r.tags))
+		for tag := range r.tags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"name": r.repo, "tags": tags})
+		r.tagLists++
+		if r.racingTag != "" {
+			r.tags[r.racingTag] = r.tags["latest"]
+			r.racingTag = ""
+		}
+	case path == "blobs/uploads/" && req.Method == http.MethodPost:
+		r.uploads++
+		w.Header().Set("Location", fmt.Sprintf("%supload-%d", req.URL.Path, r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(path, "blobs/uploads/") && req.Method == http.MethodPut:
+		data, _ := io.ReadAll(req.Body)
+		dgst := req.URL.Query().Get("digest")
+		if digest.FromBytes(data).String() != dgst {
+			http.Error(w, `{"errors":[{"code":"DIGEST_INVALID"}]}`, http.StatusBadRequest)
+			return
+		}
+		r.blobs[dgst] = data
+		w.Header().Set("Docker-Content-Digest", dgst)
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(path, "blobs/"):
+		data, ok := r.blobs[strings.TrimPrefix(path, "blobs/")]
+		if !ok {
+			http.Error(w, `{"errors":[{"code":"BLOB_UNKNOWN"}]}`, http.StatusNotFound)
+			return
+		}
+		r.writeContent(w, req, "application/octet-stream", data)
+	case strings.HasPrefix(path, "manifests/"):
+		r.serveManifest(w, req, strings.TrimPrefix(path, "manifests/"))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (r *fakeOCI) serveManifest(w http.ResponseWriter, req *http.Request, ref string) {
+	dgst := ref
+	if tagged, ok := r.tags[ref]; ok {
+		dgst = tagged
+	}
+
+	switch req.Method {
+	case http.MethodPut:
+		if r.failPuts > 0 {
+			r.failPuts--
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		data, _ := io.ReadAll(req.Body)
+		dgst = digest.FromBytes(data).String()
+		r.manifests[dgst] = data
+		if !strings.HasPrefix(ref, "sha256:") {
+			r.tags[ref] = dgst
+		}
+		w.Header().Set("Docker-Content-Digest", dgst)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := r.manifests[dgst]; !ok || dgst != ref {
+			http.Error(w, `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`, http.StatusNotFound)
+			return
+		}
+		delete(r.manifests, dgst)
+		for tag, tagged := range r.tags {
+			if tagged == dgst {
+				delete(r.tags, tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		data, ok := r.manifests[dgst]
+		if !ok {
+			http.Error(w, `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", dgst)
+		r.writeContent(w, req, ocispec.MediaTypeImageManifest, data)
+	}
+}
+
+func (r *fakeOCI) writeContent(w http.ResponseWriter, req *http.Request, mediaType string, data []byte) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	if req.Method == http.MethodHead {
+		return
+	}
+	w.Write(data)
+}
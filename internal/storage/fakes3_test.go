@@ -0,0 +1,295 @@
+package storage_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory S3 server with one bucket, serving the requests
+// S3Client makes: bucket checks and listings, and HEAD, GET, PUT and DELETE
+// of objects, with versions once EnableVersioning is called. Signatures are
+// not checked.
+type fakeS3 struct {
+	URL    string
+	bucket string
+
+	mu         sync.Mutex
+	objects    map[string][]byte
+	versions   map[string][]fakeS3Version // Oldest first, when versioning is enabled
+	versioning bool
+	failPuts   bool
+	puts       int
+	interleave []byte // Stored before the next upload is handled, when set
+	modified   time.Time
+}
+
+type fakeS3Version struct {
+	id       string
+	data     []byte
+	modified time.Time
+}
+
+func newFakeS3(t *testing.T, bucket string) *fakeS3 {
+	s := &fakeS3{bucket: bucket, objects: make(map[string][]byte), versions: make(map[string][]fakeS3Version)}
+	server := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(server.Close)
+	s.URL = server.URL
+	return s
+}
+
+// Puts returns the object uploads so far
+func (s *fakeS3) Puts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.puts
+}
+
+// Keys returns the keys of the stored objects, sorted
+func (s *fakeS3) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EnableVersioning keeps every version of the objects uploaded from now on
+func (s *fakeS3) EnableVersioning() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versioning = true
+}
+
+// InterleavePut stores data under the key of the next upload right before
+// handling it, like another writer getting in between a check and a PUT
+func (s *fakeS3) InterleavePut(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interleave = data
+}
+
+// FailPuts makes object uploads fail with 500 until called again with false
+func (s *fakeS3) FailPuts(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failPuts = fail
+}
+
+func (s *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" && r.URL.Query().Has("versions") {
+		s.listVersions(w, r.URL.Query().Get("prefix"))
+		return
+	}
+	if key == "" && r.URL.Query().Get("list-type") == "2" {
+		s.list(w, r.URL.Query().Get("prefix"))
+		return
+	}
+	if key == "" {
+		w.WriteHeader(http.StatusOK) // Bucket checks
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		data, ok := s.objects[key]
+		versionID := r.URL.Query().Get("versionId")
+		if versionID != "" {
+			ok = false
+			for _, v := range s.versions[key] {
+				if v.id == versionID {
+					data, ok = v.data, true
+				}
+			}
+			if !ok {
+				writeS3Error(w, http.StatusNotFound, "NoSuchVersion")
+				return
+			}
+		}
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if versions := s.versions[key]; versionID == "" && len(versions) > 0 {
+			versionID = versions[len(versions)-1].id
+		}
+		if versionID != "" {
+			w.Header().Set("x-amz-version-id", versionID)
+		}
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Last-Modified", s.modified.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodPut:
+		if s.failPuts {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		if s.interleave != nil {
+			s.objects[key] = s.interleave
+			s.interleave = nil
+		}
+		if !s.preconditionsMet(r, key) {
+			writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		data, err := readS3Payload(r)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.objects[key] = data
+		s.puts++
+		s.modified = time.Now()
+		if s.versioning {
+			id := fmt.Sprintf("v%d", s.puts)
+			s.versions[key] = append(s.versions[key], fakeS3Version{id: id, data: data, modified: s.modified})
+			w.Header().Set("x-amz-version-id", id)
+		}
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// preconditionsMet checks the If-Match and If-None-Match headers of an
+// upload against the stored object
+func (s *fakeS3) preconditionsMet(r *http.Request, key string) bool {
+	current, exists := s.objects[key]
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		return false
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !exists {
+			return false
+		}
+		sum := md5.Sum(current)
+		return strings.Trim(ifMatch, `"`) == hex.EncodeToString(sum[:])
+	}
+	return true
+}
+
+// list answers a ListObjectsV2 request, in a single page
+func (s *fakeS3) list(w http.ResponseWriter, prefix string) {
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
+	fmt.Fprintf(&body, "<Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><IsTruncated>false</IsTruncated>", s.bucket, prefix, len(keys))
+	for _, key := range keys {
+		sum := md5.Sum(s.objects[key])
+		fmt.Fprintf(&body, "<Contents><Key>%s</Key><Size>%d</Size><ETag>&quot;%s&quot;</ETag><LastModified>%s</LastModified></Contents>",
+			key, len(s.objects[key]), hex.EncodeToString(sum[:]), s.modified.UTC().Format(time.RFC3339))
+	}
+	body.WriteString("</ListBucketResult>")
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, body.String())
+}
+
+// listVersions answers a ListObjectVersions request, in a single page
+func (s *fakeS3) listVersions(w http.ResponseWriter, prefix string) {
+	keys := make([]string, 0, len(s.versions))
+	for key := range s.versions {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
+	fmt.Fprintf(&body, "<Name>%s</Name><Prefix>%s</Prefix><IsTruncated>false</IsTruncated>", s.bucket, prefix)
+	for _, key := range keys {
+		versions := s.versions[key]
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			sum := md5.Sum(v.data)
+			fmt.Fprintf(&body, "<Version><Key>%s</Key><VersionId>%s</VersionId><IsLatest>%t</IsLatest><LastModified>%s</LastModified><ETag>&quot;%s&quot;</ETag><Size>%d</Size></Version>",
+				key, v.id, i == len(versions)-1, v.modified.UTC().Format(time.RFC3339Nano), hex.EncodeToString(sum[:]), len(v.data))
+		}
+	}
+	body.WriteString("</ListVersionsResult>")
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, body.String())
+}
+
+// readS3Payload reads an uploaded object, decoding aws-chunked bodies
+// (sent over plain HTTP with streaming signatures)
+func readS3Payload(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return body, nil
+	}
+
+	var data bytes.Buffer
+	reader := bufio.NewReader(bytes.NewReader(body))
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk size %q: %w", sizeHex, err)
+		}
+		if size == 0 {
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil { // CRLF
+			return nil, err
+		}
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
@@ -0,0 +1,110 @@
+package storage_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeWebDAV is an in-memory WebDAV server serving the requests
+// WebDAVClient makes: HEAD, GET and PUT of files, and MKCOL of collections.
+// Like WebDAV servers, it rejects a PUT whose parent collection is missing
+// with 409. When authorization is set, requests must carry it.
+type fakeWebDAV struct {
+	URL           string
+	authorization string
+
+	mu          sync.Mutex
+	files       map[string][]byte
+	collections map[string]bool
+	failPuts    bool
+}
+
+func newFakeWebDAV(t *testing.T, authorization string) *fakeWebDAV {
+	s := &fakeWebDAV{
+		authorization: authorization,
+		files:         make(map[string][]byte),
+		collections:   map[string]bool{"/": true},
+	}
+	server := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(server.Close)
+	s.URL = server.URL
+	return s
+}
+
+// FailPuts makes uploads fail with 503 until called again with false
+func (s *fakeWebDAV) FailPuts(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failPuts = fail
+}
+
+// File returns the content of a file
+func (s *fakeWebDAV) File(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	return data, ok
+}
+
+func (s *fakeWebDAV) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.authorization != "" && r.Header.Get("Authorization") != s.authorization {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "bad credentials")
+		return
+	}
+	name := r.URL.Path
+	parent := path.Dir(strings.TrimSuffix(name, "/"))
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		data, ok := s.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case http.MethodPut:
+		if s.failPuts {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if !s.collections[parent] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.files[name] = data
+		w.WriteHeader(http.StatusCreated)
+
+	case "MKCOL":
+		collection := strings.TrimSuffix(name, "/")
+		switch {
+		case s.collections[collection]:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case !s.collections[parent]:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			s.collections[collection] = true
+			w.WriteHeader(http.StatusCreated)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"os"
@@ -16,11 +18,27 @@ import (
 type FileStorage struct {
 	*BaseStorage         // Embedded for shared CRUD logic
 	filePath     string  // Path to storage file
+	sharded      bool    // One file per registry under registries/ (see file_sharded.go)
+	written      map[string][]byte // Registry shards as last written, by registry name
+	version      string  // Of the files as last read or written (see storedVersion)
 }
 
 // NewFileStorage creates a new file-based storage
 // The token parameter is accepted but ignored for file storage (for interface compatibility)
 func NewFileStorage(filePath string, token string, logger *slog.Logger) (*FileStorage, error) {
+	return newFileStorage(filePath, FileLayoutSingle, token, Options{}, logger)
+}
+
+// NewShardedFileStorage creates a file-based storage keeping each registry
+// in its own file, migrating a single storage file on first load
+func NewShardedFileStorage(filePath string, token string, logger *slog.Logger) (*FileStorage, error) {
+	return newFileStorage(filePath, FileLayoutSharded, token, Options{}, logger)
+}
+
+// newFileStorage creates a file-based storage in the given layout, with the
+// codec, JSON formatting and encryption from opts (compression does not
+// apply to files)
+func newFileStorage(filePath string, layout string, token string, opts Options, logger *slog.Logger) (*FileStorage, error) {
 	// Log warning if token is provided (file storage doesn't use it)
 	if token != "" {
 		logger.Warn("Storage token provided but file storage does not use authentication",
@@ -30,7 +48,13 @@ func NewFileStorage(filePath string, token string, logger *slog.Logger) (*FileSt
 	fs := &FileStorage{
 		BaseStorage: NewBaseStorage(logger),
 		filePath:    filePath,
+		sharded:     layout == FileLayoutSharded,
 	}
+	fs.codec = opts.Codec
+	fs.readTimeout = opts.ReadTimeout
+	fs.writeTimeout = opts.WriteTimeout
+	fs.prettyJSON = opts.PrettyJSON
+	fs.envelope = opts.Envelope
 
 	// Load existing data or create new storage
 	if err := fs.load(); err != nil {
@@ -44,6 +68,15 @@ func NewFileStorage(filePath string, token string, logger *slog.Logger) (*FileSt
 func (fs *FileStorage) load() error {
 	// Check if file exists
 	if _, err := os.Stat(fs.filePath); os.IsNotExist(err) {
+		// Never start empty over the registries of a lost storage file
+		shards, err := fs.shardFiles()
+		if err != nil {
+			return err
+		}
+		if len(shards) > 0 {
+			return fmt.Errorf("storage file %s is missing but registry shards exist in %s", fs.filePath, fs.shardDir())
+		}
+
 		// Create empty storage (already initialized in NewBaseStorage)
 		fs.logger.Info("Storage file not found, creating empty storage",
 			"file_path", fs.filePath)
@@ -55,22 +88,24 @@ func (fs *FileStorage) load() error {
 		}
 
 		// Write empty storage to file
-		if err := fs.saveToFile(); err != nil {
+		if err := fs.save(); err != nil {
 			return fmt.Errorf("failed to create storage file: %w", err)
 		}
 
 		return nil
 	}
 
-	// Read existing file
-	fileData, err := os.ReadFile(fs.filePath)
+	// Read existing file, and the registry shards next to it if any
+	stale, err := fs.read()
 	if err != nil {
-		return fmt.Errorf("failed to read storage file: %w", err)
+		return err
 	}
 
-	// Parse JSON using BaseStorage's unmarshal
-	if err := fs.UnmarshalData(fileData); err != nil {
-		return fmt.Errorf("failed to parse storage file (invalid JSON syntax): %w", err)
+	// Rewrite data found in the other layout in the configured one
+	if stale {
+		if err := fs.migrate(); err != nil {
+			return fmt.Errorf("failed to migrate storage layout: %w", err)
+		}
 	}
 
 	data := fs.GetData()
@@ -81,6 +116,68 @@ func (fs *FileStorage) load() error {
 	return nil
 }
 
+// Refresh reads the file again, to catch up with writes made by another
+// instance sharing it (e.g. over a network file system)
+func (fs *FileStorage) Refresh(ctx context.Context) error {
+	_, err := fs.read()
+	return err
+}
+
+// StoredVersion identifies the files on disk by their size and modification
+// time, for Watcher
+func (fs *FileStorage) StoredVersion(ctx context.Context) (string, error) {
+	return fs.storedVersion()
+}
+
+// Ping checks the storage file can still be reached, for health checks
+func (fs *FileStorage) Ping(ctx context.Context) error {
+	_, err := os.Stat(fs.filePath)
+	return err
+}
+
+// LoadedVersion returns the version of the files as last read or written
+func (fs *FileStorage) LoadedVersion(ctx context.Context) (string, error) {
+	unlock, err := fs.rlock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return fs.version, nil
+}
+
+// WatchPaths returns the directories of the storage file and registry shards
+func (fs *FileStorage) WatchPaths() []string {
+	dirs := []string{filepath.Dir(fs.filePath)}
+	if fs.sharded {
+		dirs = append(dirs, fs.shardDir())
+	}
+	return dirs
+}
+
+// storedVersion hashes the name, size and modification time of the storage
+// file and registry shards; empty when the storage file does not exist
+func (fs *FileStorage) storedVersion() (string, error) {
+	if _, err := os.Stat(fs.filePath); os.IsNotExist(err) {
+		return "", nil
+	}
+	shards, err := fs.shardFiles()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, path := range append([]string{fs.filePath}, shards...) {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to stat storage file: %w", err)
+		}
+		fmt.Fprintf(h, "%s %d %d\n", filepath.Base(path), info.Size(), info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
 // saveToFile writes data to file atomically (temp file + rename)
 // NOTE: This is called from persist() while BaseStorage holds the lock,
 // so we use marshalDataLocked() to avoid deadlock.
@@ -90,10 +187,38 @@ func (fs *FileStorage) saveToFile() error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal storage: %w", err)
 	}
+	if jsonData, err = fs.envelope.Seal(jsonData); err != nil {
+		return fmt.Errorf("failed to encrypt storage: %w", err)
+	}
+
+	if err := writeFileSynced(fs.filePath, jsonData); err != nil {
+		return err
+	}
+
+	// Check file size and warn if > 50MB
+	if info, err := os.Stat(fs.filePath); err == nil {
+		sizeMB := float64(info.Size()) / (1024 * 1024)
+		if sizeMB > 50 {
+			data := fs.getDataLocked() // Use lock-free version (caller holds lock)
+			fs.logger.Warn("Storage file size exceeds recommended threshold",
+				"file_path", fs.filePath,
+				"current_size_mb", sizeMB,
+				"threshold_mb", 50,
+				"max_size_mb", 100,
+				"registries_count", len(data.Registries),
+			)
+		}
+	}
+
+	return nil
+}
 
+// writeFileSynced replaces path with data (temp file + sync + rename), so
+// readers never see a partial file and the data survives a crash
+func writeFileSynced(path string, data []byte) error {
 	// Create temp file in same directory
-	dir := filepath.Dir(fs.filePath)
-	tempFile, err := os.CreateTemp(dir, ".registry-*.json.tmp")
+	dir := filepath.Dir(path)
+	tempFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
@@ -108,7 +233,7 @@ func (fs *FileStorage) saveToFile() error {
 	}()
 
 	// Write to temp file
-	if _, err := tempFile.Write(jsonData); err != nil {
+	if _, err := tempFile.Write(data); err != nil {
 		return fmt.Errorf("failed to write temp file: %w", err)
 	}
 
@@ -124,31 +249,19 @@ func (fs *FileStorage) saveToFile() error {
 	tempFile = nil // Prevent deferred cleanup
 
 	// Atomic rename
-	if err := os.Rename(tempPath, fs.filePath); err != nil {
+	if err := os.Rename(tempPath, path); err != nil {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
-
-	// Check file size and warn if > 50MB
-	if info, err := os.Stat(fs.filePath); err == nil {
-		sizeMB := float64(info.Size()) / (1024 * 1024)
-		if sizeMB > 50 {
-			data := fs.getDataLocked() // Use lock-free version (caller holds lock)
-			fs.logger.Warn("Storage file size exceeds recommended threshold",
-				"file_path", fs.filePath,
-				"current_size_mb", sizeMB,
-				"threshold_mb", 50,
-				"max_size_mb", 100,
-				"registries_count", len(data.Registries),
-			)
-		}
-	}
-
 	return nil
 }
 
-// persist is the callback passed to BaseStorage methods
-func (fs *FileStorage) persist() error {
-	return fs.saveToFile()
+// persist is the callback passed to BaseStorage methods. Writing the file
+// cannot be interrupted, so the deadline is only checked before it starts.
+func (fs *FileStorage) persist(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fs.save()
 }
 
 // CreateRegistry creates a new registry
@@ -191,6 +304,11 @@ func (fs *FileStorage) UpdatePackage(ctx context.Context, registryName string, p
 	return fs.BaseStorage.UpdatePackage(ctx, registryName, p, fs.persist)
 }
 
+// UpdatePackages updates several packages with a single write (atomic)
+func (fs *FileStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return fs.BaseStorage.UpdatePackages(ctx, registryName, packages, fs.persist)
+}
+
 // DeletePackage deletes a package and all its versions (atomic)
 func (fs *FileStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
 	return fs.BaseStorage.DeletePackage(ctx, registryName, packageName, fs.persist)
@@ -201,6 +319,56 @@ func (fs *FileStorage) ListPackages(ctx context.Context, registryName string) ([
 	return fs.BaseStorage.ListPackages(ctx, registryName)
 }
 
+// FindPackages returns the packages whose custom values match a query
+func (fs *FileStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return fs.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (fs *FileStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return fs.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (fs *FileStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return fs.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (fs *FileStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return fs.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (fs *FileStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return fs.BaseStorage.PutCertificate(ctx, name, data, fs.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (fs *FileStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return fs.BaseStorage.DeleteCertificate(ctx, name, fs.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (fs *FileStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return fs.BaseStorage.CreateAPIKey(ctx, key, fs.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (fs *FileStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return fs.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (fs *FileStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return fs.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (fs *FileStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return fs.BaseStorage.DeleteAPIKey(ctx, registryName, id, fs.persist)
+}
+
 // CreateVersion creates a new version for a package
 func (fs *FileStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
 	return fs.BaseStorage.CreateVersion(ctx, registryName, packageName, v, fs.persist)
@@ -221,11 +389,31 @@ func (fs *FileStorage) ListVersions(ctx context.Context, registryName, packageNa
 	return fs.BaseStorage.ListVersions(ctx, registryName, packageName)
 }
 
+// ReleaseVersion clears the embargo of a scheduled version
+func (fs *FileStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return fs.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, fs.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (fs *FileStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return fs.BaseStorage.CancelVersion(ctx, registryName, packageName, version, fs.persist)
+}
+
 // GetRegistryIndex generates the registry index (Command Launcher format)
 func (fs *FileStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
 	return fs.BaseStorage.GetRegistryIndex(ctx, registryName)
 }
 
+// Changes returns the records changed since a generation (differential sync)
+func (fs *FileStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return fs.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (fs *FileStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return fs.BaseStorage.ReplaceData(ctx, raw, fs.persist)
+}
+
 // Close closes the storage (no-op for file storage)
 func (fs *FileStorage) Close() error {
 	return nil
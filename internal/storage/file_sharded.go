@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// File storage layouts, chosen with the layout query parameter of the file
+// URI (file://./data/registry.json?layout=sharded)
+const (
+	// FileLayoutSingle keeps all data in the storage file (default)
+	FileLayoutSingle = "single"
+
+	// FileLayoutSharded keeps each registry in registries/<name>.json next
+	// to the storage file, which holds the rest (sync state, history, API
+	// keys, certificates). A write rewrites only the registries it changed
+	// and the storage file.
+	FileLayoutSharded = "sharded"
+)
+
+// shardDirName is the directory of the registry shards, next to the
+// storage file
+const shardDirName = "registries"
+
+// shardDir returns the directory of the registry shards
+func (fs *FileStorage) shardDir() string {
+	return filepath.Join(filepath.Dir(fs.filePath), shardDirName)
+}
+
+// shardPath returns the file of a registry in the sharded layout
+func (fs *FileStorage) shardPath(name string) string {
+	return filepath.Join(fs.shardDir(), url.PathEscape(name)+".json")
+}
+
+// shardFiles lists the registry shards on disk, none when the shard
+// directory does not exist
+func (fs *FileStorage) shardFiles() ([]string, error) {
+	entries, err := os.ReadDir(fs.shardDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list registry shards: %w", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// Skip temp files of interrupted writes
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		paths = append(paths, filepath.Join(fs.shardDir(), name))
+	}
+	return paths, nil
+}
+
+// read loads the storage file and the registry shards next to it, whatever
+// the configured layout. It returns whether data was found in the other
+// layout, for load to migrate it.
+func (fs *FileStorage) read() (bool, error) {
+	// Taken first: files changed while being read are read again
+	version, err := fs.storedVersion()
+	if err != nil {
+		return false, err
+	}
+
+	raw, err := os.ReadFile(fs.filePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read storage file: %w", err)
+	}
+	if raw, err = fs.envelope.Open(raw); err != nil {
+		return false, fmt.Errorf("failed to decrypt storage file: %w", err)
+	}
+	data, quarantined, err := loadStorage(raw)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse storage file (invalid JSON or CBOR): %w", err)
+	}
+	stale := fs.sharded && len(data.Registries) > 0
+
+	paths, err := fs.shardFiles()
+	if err != nil {
+		return false, err
+	}
+	written := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read registry shard: %w", err)
+		}
+		if raw, err = fs.envelope.Open(raw); err != nil {
+			return false, fmt.Errorf("failed to decrypt registry shard %s: %w", path, err)
+		}
+		shard, shardQuarantined, err := loadStorage(raw)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse registry shard %s (invalid JSON or CBOR): %w", path, err)
+		}
+		for name, r := range shard.Registries {
+			data.Registries[name] = r
+			if len(shard.Registries) == 1 && path == fs.shardPath(name) {
+				written[name] = raw
+			}
+		}
+		data.Quarantine = append(data.Quarantine, shardQuarantined...)
+		quarantined = append(quarantined, shardQuarantined...)
+		stale = stale || !fs.sharded
+	}
+
+	fs.setLoadedData(data, quarantined)
+	fs.mu.Lock()
+	fs.written = written
+	fs.version = version
+	fs.mu.Unlock()
+	return stale, nil
+}
+
+// save writes the data in the configured layout, and remembers the version
+// of the files written so a Watcher does not reload them.
+// Caller MUST hold the write lock (or be loading the storage).
+func (fs *FileStorage) save() error {
+	save := fs.saveToFile
+	if fs.sharded {
+		save = fs.saveSharded
+	}
+	if err := save(); err != nil {
+		return err
+	}
+	fs.version, _ = fs.storedVersion() // Empty on error: reloaded once
+	return nil
+}
+
+// shardBackup is the content of a registry shard before a write changed or
+// removed it, nil when the shard did not exist
+type shardBackup struct {
+	name string // Registry name, empty for a removed shard
+	path string
+	raw  []byte
+}
+
+// saveSharded writes the registries that changed since they were last
+// written, removes the shards of deleted registries, then writes the storage
+// file. A failed write restores the shards it had already replaced; each file
+// is replaced atomically, but a crash between files can leave the registries
+// ahead of the sync state in the storage file.
+// Caller MUST hold the write lock (or be loading the storage).
+func (fs *FileStorage) saveSharded() (err error) {
+	data := fs.getDataLocked()
+	if err := os.MkdirAll(fs.shardDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create registry shard directory: %w", err)
+	}
+	if fs.written == nil {
+		fs.written = make(map[string][]byte)
+	}
+
+	var backups []shardBackup
+	defer func() {
+		if err != nil {
+			fs.restoreShards(backups)
+		}
+	}()
+	backup := func(name, path string) error {
+		raw, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read registry shard: %w", err)
+		}
+		backups = append(backups, shardBackup{name: name, path: path, raw: raw})
+		return nil
+	}
+
+	keep := make(map[string]bool, len(data.Registries))
+	for name, r := range data.Registries {
+		path := fs.shardPath(name)
+		keep[path] = true
+		raw, err := encodeStorage(fs.codec, &models.Storage{Registries: map[string]*models.Registry{name: r}}, fs.prettyJSON)
+		if err != nil {
+			return fmt.Errorf("failed to marshal registry %s: %w", name, err)
+		}
+		if bytes.Equal(raw, fs.written[name]) {
+			continue
+		}
+		if err := backup(name, path); err != nil {
+			return err
+		}
+		sealed, err := fs.envelope.Seal(raw)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt registry %s: %w", name, err)
+		}
+		if err := writeFileSynced(path, sealed); err != nil {
+			return fmt.Errorf("failed to write registry shard %s: %w", name, err)
+		}
+		fs.written[name] = raw
+	}
+
+	paths, err := fs.shardFiles()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if keep[path] {
+			continue
+		}
+		if err := backup("", path); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove registry shard: %w", err)
+		}
+	}
+
+	// The storage file holds everything but the registries
+	root := *data
+	root.Registries = map[string]*models.Registry{}
+	raw, err := encodeStorage(fs.codec, &root, fs.prettyJSON)
+	if err != nil {
+		return fmt.Errorf("failed to marshal storage: %w", err)
+	}
+	if raw, err = fs.envelope.Seal(raw); err != nil {
+		return fmt.Errorf("failed to encrypt storage: %w", err)
+	}
+	if err := writeFileSynced(fs.filePath, raw); err != nil {
+		return err
+	}
+
+	for name := range fs.written {
+		if _, ok := data.Registries[name]; !ok {
+			delete(fs.written, name)
+		}
+	}
+	return nil
+}
+
+// restoreShards puts back the registry shards a failed write had replaced or
+// removed, newest first. The restored registries are rewritten by the next
+// write, whatever their content.
+func (fs *FileStorage) restoreShards(backups []shardBackup) {
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		var err error
+		if b.raw == nil {
+			err = os.Remove(b.path)
+		} else {
+			err = writeFileSynced(b.path, b.raw)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fs.logger.Error("Failed to restore registry shard after a failed write",
+				"path", b.path,
+				"error", err)
+		}
+		if b.name != "" {
+			delete(fs.written, b.name)
+		}
+	}
+}
+
+// migrate rewrites data read from the other layout in the configured one:
+// it splits a single storage file into registry shards, or merges the shards
+// back into the storage file and removes them. Both are safe to run again
+// after a crash, since read merges whatever it finds.
+func (fs *FileStorage) migrate() error {
+	if err := fs.save(); err != nil {
+		return err
+	}
+	if fs.sharded {
+		fs.logger.Info("Migrated storage file to the sharded layout",
+			"file_path", fs.filePath,
+			"shard_dir", fs.shardDir())
+		return nil
+	}
+
+	paths, err := fs.shardFiles()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove registry shard: %w", err)
+		}
+	}
+	os.Remove(fs.shardDir()) // Kept if it holds other files
+	fs.written = nil
+	fs.version, _ = fs.storedVersion()
+	fs.logger.Info("Migrated registry shards into the storage file",
+		"file_path", fs.filePath,
+		"shard_dir", fs.shardDir())
+	return nil
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedFileStorage_WritesOnlyChangedRegistries(t *testing.T) {
+	const checksum = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "registry.json")
+	store, err := NewShardedFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("hammer", "", nil, nil)))
+
+	// The storage file holds no registries
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	root, _, err := decodeStorage(raw)
+	require.NoError(t, err)
+	assert.Empty(t, root.Registries)
+	assert.NotEmpty(t, root.Sync.Records)
+
+	games, err := os.Stat(filepath.Join(dir, "registries", "games.json"))
+	require.NoError(t, err)
+	tools, err := os.Stat(filepath.Join(dir, "registries", "tools.json"))
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreateVersion(ctx, "tools", "hammer", &models.Version{
+		Name: "hammer", Version: "1.0.0", Checksum: checksum, URL: "https://example.com/hammer.zip", EndPartition: 9,
+	}))
+
+	after, err := os.Stat(filepath.Join(dir, "registries", "games.json"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(games, after), "untouched registry was rewritten")
+	after, err = os.Stat(filepath.Join(dir, "registries", "tools.json"))
+	require.NoError(t, err)
+	assert.False(t, os.SameFile(tools, after), "changed registry was not rewritten")
+
+	// Deleting a registry removes its shard
+	require.NoError(t, store.DeleteRegistry(ctx, "games"))
+	assert.NoFileExists(t, filepath.Join(dir, "registries", "games.json"))
+
+	reopened, err := NewShardedFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+	registries, err := reopened.ListRegistries(ctx)
+	require.NoError(t, err)
+	require.Len(t, registries, 1)
+	v, err := reopened.GetVersion(ctx, "tools", "hammer", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, checksum, v.Checksum)
+}
+
+func TestShardedFileStorage_Migration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "registry.json")
+	single, err := NewFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, single.CreateRegistry(ctx, models.NewRegistry("tools", "Tools", nil, nil)))
+	require.NoError(t, single.CreatePackage(ctx, "tools", models.NewPackage("hammer", "", nil, nil)))
+	require.NoError(t, single.PutCertificate(ctx, "example.com", []byte("cert")))
+	generation := single.GetData().Sync.Generation
+
+	// Opening the single file in the sharded layout splits it
+	sharded, err := NewShardedFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "registries", "tools.json"))
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	root, _, err := decodeStorage(raw)
+	require.NoError(t, err)
+	assert.Empty(t, root.Registries)
+	assert.Equal(t, generation, root.Sync.Generation)
+	_, err = sharded.GetPackage(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	cert, err := sharded.GetCertificate(ctx, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cert"), cert)
+
+	// And back: opening the shards in the single layout merges them
+	single, err = NewFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(dir, "registries"))
+	raw, err = os.ReadFile(path)
+	require.NoError(t, err)
+	root, _, err = decodeStorage(raw)
+	require.NoError(t, err)
+	assert.Contains(t, root.Registries, "tools")
+	_, err = single.GetPackage(ctx, "tools", "hammer")
+	require.NoError(t, err)
+}
+
+func TestShardedFileStorage_MissingStorageFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "registry.json")
+	store, err := NewShardedFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, os.Remove(path))
+
+	// Starting empty would delete the shards on the first write
+	_, err = NewShardedFileStorage(path, "", newTestS3Logger())
+	assert.ErrorContains(t, err, "registry shards exist")
+	assert.FileExists(t, filepath.Join(dir, "registries", "tools.json"))
+}
@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// GCSStorage implements Store interface using Google Cloud Storage as backend.
+// It embeds BaseStorage for in-memory CRUD operations and provides
+// GCS-based persistence via persist().
+type GCSStorage struct {
+	*BaseStorage // Embedded for shared CRUD logic
+	client       *GCSClient
+	bucket       string
+	object       string
+}
+
+// NewGCSStorage creates a new GCS-backed storage.
+// The uri should be a parsed GCS StorageURI (gcs://bucket/path).
+// The token is a service account key, as JSON or the path of a JSON file;
+// empty uses Application Default Credentials (e.g. GKE workload identity).
+// opts selects the codec and compression of the uploaded object.
+func NewGCSStorage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*GCSStorage, error) {
+	if !uri.IsGCSScheme() {
+		return nil, fmt.Errorf("expected GCS URI, got scheme: %s", uri.Scheme)
+	}
+	bucket := uri.GCSBucket()
+	object := uri.GCSObject()
+
+	if opts.Credentials != nil {
+		if _, secondary := opts.Credentials.Tokens(); secondary != "" {
+			if _, err := ParseGCSToken(secondary); err != nil {
+				return nil, fmt.Errorf("failed to parse secondary GCS credentials: %w", err)
+			}
+		}
+	}
+
+	client, err := NewGCSClient(bucket, object, token, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	client.codec = opts.Codec
+	client.compression = opts.Compression
+	client.envelope = opts.Envelope
+	if opts.LoadTimeout > GCSDownloadTimeout {
+		client.downloadTimeout = opts.LoadTimeout
+	}
+	client.SetRetryPolicy(opts.Retry)
+	if opts.Credentials != nil {
+		client.SetCredentials(opts.Credentials)
+	}
+
+	// Validate bucket exists
+	ctx := tracing.WithOperation(context.Background(), "validate_bucket")
+	if err := client.ValidateBucket(ctx); err != nil {
+		return nil, fmt.Errorf("GCS bucket validation failed: %w", err)
+	}
+
+	s := &GCSStorage{
+		BaseStorage: NewBaseStorage(logger),
+		client:      client,
+		bucket:      bucket,
+		object:      object,
+	}
+	s.codec = opts.Codec
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+	s.cacheFile = opts.CacheFile
+	s.envelope = opts.Envelope
+
+	// Load existing data from GCS or initialize empty storage
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("failed to load data from GCS: %w", err)
+	}
+
+	// Initializing the storage in load is persisted immediately
+	s.setPersistMode(opts.PersistMode, opts.FlushInterval, s.persist)
+
+	return s, nil
+}
+
+// load retrieves registry data from GCS on startup.
+// If the object doesn't exist, initializes empty storage and pushes it.
+func (s *GCSStorage) load() error {
+	ctx := tracing.WithOperation(context.Background(), "load")
+
+	exists, err := s.client.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check GCS object existence: %w", err)
+	}
+
+	if !exists {
+		s.logger.Info("GCS object does not exist, initializing empty storage",
+			"bucket", s.bucket,
+			"object", s.object)
+
+		if err := s.persist(ctx); err != nil {
+			return fmt.Errorf("failed to initialize GCS storage: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.reload(ctx); err != nil {
+		return err
+	}
+
+	storageData := s.GetData()
+	s.logger.Info("GCS storage loaded",
+		"bucket", s.bucket,
+		"object", s.object,
+		"registry_count", len(storageData.Registries))
+
+	return nil
+}
+
+// Refresh downloads the data again, to catch up with writes made by another
+// instance sharing the object
+func (s *GCSStorage) Refresh(ctx context.Context) error {
+	ctx = tracing.WithOperation(ctx, "refresh")
+	if err := s.flushBeforeReload(ctx); err != nil {
+		return err
+	}
+	return s.reload(ctx)
+}
+
+// Ping checks the object can still be reached, for health checks
+func (s *GCSStorage) Ping(ctx context.Context) error {
+	_, err := s.client.Exists(tracing.WithOperation(ctx, "ping"))
+	return err
+}
+
+// reload replaces the in-memory data with the stored one
+func (s *GCSStorage) reload(ctx context.Context) error {
+	data, err := s.client.Download(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to download from GCS: %w", err)
+	}
+	if err := s.UnmarshalData(data); err != nil {
+		return fmt.Errorf("failed to parse registry data (corrupted JSON or CBOR): %w", err)
+	}
+	if s.cacheFile != "" {
+		if cached, err := s.MarshalData(); err == nil {
+			s.writeCache(cached)
+		}
+	}
+	return nil
+}
+
+// persist uploads the complete registry data to GCS.
+// NOTE: This is called while BaseStorage holds the lock,
+// so we use marshalDataLocked() to avoid deadlock.
+func (s *GCSStorage) persist(ctx context.Context) error {
+	if s.deferPersist() {
+		return nil
+	}
+
+	data, err := s.marshalDataLocked()
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry data: %w", err)
+	}
+
+	if err := s.client.Upload(ctx, data); err != nil {
+		return err // Already categorized by GCSClient
+	}
+	s.writeCache(data)
+
+	return nil
+}
+
+// CreateRegistry creates a new registry
+func (s *GCSStorage) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.CreateRegistry(ctx, r, s.persist)
+}
+
+// GetRegistry retrieves a registry by name
+func (s *GCSStorage) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	return s.BaseStorage.GetRegistry(ctx, name)
+}
+
+// UpdateRegistry updates registry metadata
+func (s *GCSStorage) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.UpdateRegistry(ctx, r, s.persist)
+}
+
+// DeleteRegistry deletes a registry and all its packages (atomic)
+func (s *GCSStorage) DeleteRegistry(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteRegistry(ctx, name, s.persist)
+}
+
+// ListRegistries returns all registries
+func (s *GCSStorage) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	return s.BaseStorage.ListRegistries(ctx)
+}
+
+// CreatePackage creates a new package in a registry
+func (s *GCSStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.CreatePackage(ctx, registryName, p, s.persist)
+}
+
+// GetPackage retrieves a package from a registry
+func (s *GCSStorage) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	return s.BaseStorage.GetPackage(ctx, registryName, packageName)
+}
+
+// UpdatePackage updates package metadata (preserves versions)
+func (s *GCSStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
+}
+
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *GCSStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
+// DeletePackage deletes a package and all its versions (atomic)
+func (s *GCSStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
+}
+
+// ListPackages returns all packages in a registry
+func (s *GCSStorage) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	return s.BaseStorage.ListPackages(ctx, registryName)
+}
+
+// FindPackages returns the packages whose custom values match a query
+func (s *GCSStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *GCSStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *GCSStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *GCSStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *GCSStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *GCSStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *GCSStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *GCSStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *GCSStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *GCSStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
+// CreateVersion creates a new version for a package
+func (s *GCSStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
+}
+
+// GetVersion retrieves a specific version
+func (s *GCSStorage) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
+	return s.BaseStorage.GetVersion(ctx, registryName, packageName, version)
+}
+
+// DeleteVersion deletes a specific version
+func (s *GCSStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.DeleteVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// ListVersions returns all versions for a package
+func (s *GCSStorage) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
+	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
+}
+
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *GCSStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *GCSStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// GetRegistryIndex generates the registry index (Command Launcher format)
+func (s *GCSStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
+	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
+}
+
+// Changes returns the records changed since a generation (differential sync)
+func (s *GCSStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *GCSStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close flushes the pending writes of debounced mode
+func (s *GCSStorage) Close() error {
+	return s.closeWriteBehind()
+}
@@ -0,0 +1,422 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// GCS timeout constants
+const (
+	GCSUploadTimeout   = 60 * time.Second
+	GCSDownloadTimeout = 30 * time.Second
+)
+
+// GCSEndpoint is the Google Cloud Storage JSON API endpoint
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// gcsScope is the OAuth2 scope of the access tokens used for GCS
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// GCSClient talks to the Google Cloud Storage JSON API. Requests carry an
+// access token from Application Default Credentials (the GKE workload
+// identity, GOOGLE_APPLICATION_CREDENTIALS...) or from a service account
+// key; with STORAGE_EMULATOR_HOST set, they go unauthenticated to that
+// emulator instead, like Google's own client libraries.
+type GCSClient struct {
+	http            *http.Client
+	endpoint        string
+	bucket          string
+	object          string
+	codec           Codec         // Sets the uploaded Content-Type
+	compression     Compression   // Applied to uploads; downloads detect it
+	envelope        *Envelope     // Encrypts uploads when set; downloads detect it
+	downloadTimeout time.Duration // Bounds Download (GCSDownloadTimeout unless set longer for startup)
+	retrier         *retrier      // Retries failed operations (see RetryPolicy)
+	creds           *Credentials  // Tokens, with fallback to the secondary one; nil uses the key given at creation
+	logger          *slog.Logger
+}
+
+// NewGCSClient creates a new GCS client for an object. key is a service
+// account key, as JSON or the path of a JSON file; empty uses Application
+// Default Credentials.
+func NewGCSClient(bucket, object, key string, logger *slog.Logger) (*GCSClient, error) {
+	c := &GCSClient{
+		endpoint:        GCSEndpoint,
+		bucket:          bucket,
+		object:          object,
+		downloadTimeout: GCSDownloadTimeout,
+		retrier:         newRetrier(DefaultRetryPolicy(), logger),
+		logger:          logger,
+	}
+
+	// Tag every GCS request with the request ID and operation behind it
+	// (see tracingTransport)
+	transport := newTracingTransport(backendTransport(), logger)
+	if emulator := os.Getenv("STORAGE_EMULATOR_HOST"); emulator != "" {
+		c.endpoint = strings.TrimSuffix(emulator, "/")
+		if !strings.Contains(c.endpoint, "://") {
+			c.endpoint = "http://" + c.endpoint
+		}
+		c.http = &http.Client{Transport: transport}
+		logger.Info("GCS client created for emulator",
+			"endpoint", c.endpoint,
+			"bucket", bucket,
+			"object", object)
+		return c, nil
+	}
+
+	static, err := gcsTokenSource(key)
+	if err != nil {
+		return nil, CategorizeGCSError(GCSOpConnect, err)
+	}
+	c.http = &http.Client{Transport: &oauth2.Transport{
+		Source: &gcsCredentialSource{client: c, static: static},
+		Base:   transport,
+	}}
+
+	logger.Info("GCS client created",
+		"bucket", bucket,
+		"object", object,
+		"credentials", gcsCredentialKind(key))
+	return c, nil
+}
+
+// SetCredentials makes the client authenticate with the service account
+// keys of creds, falling back to their secondary key, instead of the key
+// it was created with. It must be called before any operation.
+func (c *GCSClient) SetCredentials(creds *Credentials) {
+	c.creds = creds
+	creds.setCheck(c.validateBucket)
+}
+
+// SetRetryPolicy changes how failed operations are retried
+func (c *GCSClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retrier = newRetrier(policy, c.logger)
+}
+
+// ValidateBucket checks if the bucket exists and is accessible
+func (c *GCSClient) ValidateBucket(ctx context.Context) error {
+	return c.retrier.do(ctx, GCSOpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, c.validateBucket)
+	})
+}
+
+func (c *GCSClient) validateBucket(ctx context.Context) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Debug("Validating GCS bucket", "bucket", c.bucket)
+
+	resp, err := c.do(ctx, http.MethodGet, c.endpoint+"/storage/v1/b/"+url.PathEscape(c.bucket)+"?fields=name", nil, "")
+	if err != nil {
+		logger.Error("GCS bucket validation failed",
+			"bucket", c.bucket,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return CategorizeGCSError(GCSOpConnect, err)
+	}
+	resp.Body.Close()
+
+	logger.Info("GCS bucket validated",
+		"bucket", c.bucket,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// Exists checks if the object exists in the GCS bucket
+func (c *GCSClient) Exists(ctx context.Context) (bool, error) {
+	var exists bool
+	err := c.retrier.do(ctx, GCSOpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			exists, err = c.exists(ctx)
+			return err
+		})
+	})
+	return exists, err
+}
+
+func (c *GCSClient) exists(ctx context.Context) (bool, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Debug("Checking GCS object existence", "bucket", c.bucket, "object", c.object)
+
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL()+"?fields=name", nil, "")
+	if err != nil {
+		if isGCSNotFound(err) {
+			logger.Info("GCS object does not exist",
+				"bucket", c.bucket,
+				"object", c.object,
+				"duration_ms", time.Since(start).Milliseconds())
+			return false, nil
+		}
+		logger.Error("GCS existence check failed",
+			"bucket", c.bucket,
+			"object", c.object,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return false, CategorizeGCSError(GCSOpConnect, err)
+	}
+	resp.Body.Close()
+
+	logger.Info("GCS object exists",
+		"bucket", c.bucket,
+		"object", c.object,
+		"duration_ms", time.Since(start).Milliseconds())
+	return true, nil
+}
+
+// Upload uploads data to the GCS bucket, compressing it if the client was
+// configured to. The compression is not declared as Content-Encoding, which
+// GCS would undo on download for gzip only; downloads detect it instead.
+func (c *GCSClient) Upload(ctx context.Context, data []byte) error {
+	return c.retrier.do(ctx, GCSOpUpload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			return c.upload(ctx, data)
+		})
+	})
+}
+
+func (c *GCSClient) upload(ctx context.Context, data []byte) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	size := len(data)
+
+	data, err := compressData(c.compression, data)
+	if err != nil {
+		return CategorizeGCSError(GCSOpUpload, err)
+	}
+	if data, err = c.envelope.Seal(data); err != nil {
+		return CategorizeGCSError(GCSOpUpload, err)
+	}
+	logger.Info("Starting GCS upload",
+		"bucket", c.bucket,
+		"object", c.object,
+		"size_bytes", size,
+		"stored_bytes", len(data),
+		"compression", c.compression)
+
+	// Apply timeout
+	ctx, cancel := context.WithTimeout(ctx, GCSUploadTimeout)
+	defer cancel()
+
+	uploadURL := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		c.endpoint, url.PathEscape(c.bucket), url.QueryEscape(c.object))
+	resp, err := c.do(ctx, http.MethodPost, uploadURL, data, c.codec.ContentType())
+	if err != nil {
+		logger.Error("GCS upload failed",
+			"bucket", c.bucket,
+			"object", c.object,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return CategorizeGCSError(GCSOpUpload, err)
+	}
+	resp.Body.Close()
+
+	logger.Info("GCS upload completed",
+		"bucket", c.bucket,
+		"object", c.object,
+		"size_bytes", size,
+		"stored_bytes", len(data),
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// Download downloads data from the GCS bucket
+func (c *GCSClient) Download(ctx context.Context) ([]byte, error) {
+	var data []byte
+	err := c.retrier.do(ctx, GCSOpDownload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			data, err = c.download(ctx)
+			return err
+		})
+	})
+	return data, err
+}
+
+func (c *GCSClient) download(ctx context.Context) ([]byte, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Debug("Starting GCS download", "bucket", c.bucket, "object", c.object)
+
+	// Apply timeout
+	ctx, cancel := context.WithTimeout(ctx, c.downloadTimeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL()+"?alt=media", nil, "")
+	if err != nil {
+		logger.Error("GCS download failed",
+			"bucket", c.bucket,
+			"object", c.object,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return nil, CategorizeGCSError(GCSOpDownload, err)
+	}
+	defer resp.Body.Close()
+	logger.Info("Downloading GCS object",
+		"bucket", c.bucket,
+		"object", c.object,
+		"total_bytes", resp.ContentLength)
+
+	stored, err := io.ReadAll(newProgressReader(resp.Body, resp.ContentLength, logger, "bucket", c.bucket, "object", c.object))
+	if err != nil {
+		logger.Error("GCS download read failed",
+			"bucket", c.bucket,
+			"object", c.object,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return nil, CategorizeGCSError(GCSOpDownload, err)
+	}
+
+	opened, err := c.envelope.Open(stored)
+	if err != nil {
+		logger.Error("GCS download decryption failed",
+			"bucket", c.bucket,
+			"object", c.object,
+			"error", err)
+		return nil, CategorizeGCSError(GCSOpDownload, err)
+	}
+	data, compression, err := decompressData(opened)
+	if err != nil {
+		logger.Error("GCS download decompression failed",
+			"bucket", c.bucket,
+			"object", c.object,
+			"compression", compression,
+			"error", err)
+		return nil, CategorizeGCSError(GCSOpDownload, err)
+	}
+
+	logger.Info("GCS download completed",
+		"bucket", c.bucket,
+		"object", c.object,
+		"size_bytes", len(data),
+		"stored_bytes", len(stored),
+		"compression", compression,
+		"duration_ms", time.Since(start).Milliseconds())
+	return data, nil
+}
+
+// objectURL returns the JSON API URL of the object
+func (c *GCSClient) objectURL() string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", c.endpoint, url.PathEscape(c.bucket), url.PathEscape(c.object))
+}
+
+// do sends a request, returning the response of a 2xx answer and a
+// gcsStatusError for the others
+func (c *GCSClient) do(ctx context.Context, method, target string, body []byte, contentType string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, newGCSStatusError(resp, answer)
+	}
+	return resp, nil
+}
+
+func isGCSNotFound(err error) bool {
+	var statusErr *gcsStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// gcsCredentialSource supplies access tokens for the key in use, or from
+// the client's static source when it has no Credentials. Its token source
+// is recreated whenever the key in use changes.
+type gcsCredentialSource struct {
+	client *GCSClient
+	static oauth2.TokenSource
+
+	mu         sync.Mutex
+	source     oauth2.TokenSource
+	generation uint64 // Of source
+}
+
+func (s *gcsCredentialSource) Token() (*oauth2.Token, error) {
+	if s.client.creds == nil {
+		return s.static.Token()
+	}
+	key, generation := s.client.creds.current()
+	s.mu.Lock()
+	if s.source == nil || s.generation != generation {
+		source, err := gcsTokenSource(key)
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		s.source, s.generation = source, generation
+	}
+	source := s.source
+	s.mu.Unlock()
+	return source.Token()
+}
+
+// gcsTokenSource returns the access tokens of a service account key, or of
+// Application Default Credentials if key is empty
+func gcsTokenSource(key string) (oauth2.TokenSource, error) {
+	ctx := context.Background()
+	if key == "" {
+		creds, err := google.FindDefaultCredentials(ctx, gcsScope)
+		if err != nil {
+			return nil, fmt.Errorf("no GCS credentials: %w (use workload identity, GOOGLE_APPLICATION_CREDENTIALS or --storage-token with a service account key)", err)
+		}
+		return creds.TokenSource, nil
+	}
+	keyJSON, err := ParseGCSToken(key)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := google.CredentialsFromJSON(ctx, keyJSON, gcsScope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GCS service account key: %w", err)
+	}
+	return creds.TokenSource, nil
+}
+
+// ParseGCSToken returns the service account key of a storage token: the
+// token itself if it is JSON, else the content of the file it names.
+func ParseGCSToken(token string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(token), "{") {
+		return []byte(token), nil
+	}
+	data, err := os.ReadFile(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS service account key file: %w", err)
+	}
+	return data, nil
+}
+
+// gcsCredentialKind describes the credentials of a key for logging
+func gcsCredentialKind(key string) string {
+	if key == "" {
+		return "application-default"
+	}
+	return "service-account-key"
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// GCS error categories for clear error messages
+const (
+	GCSCategoryAuth    = "authentication"
+	GCSCategoryNetwork = "network"
+	GCSCategoryStorage = "storage"
+)
+
+// GCS operations for error context
+const (
+	GCSOpUpload   = "upload"
+	GCSOpDownload = "download"
+	GCSOpConnect  = "connect"
+)
+
+// GCSError wraps GCS-specific failures with categorization
+type GCSError struct {
+	Category  string // "authentication", "network", or "storage"
+	Op        string // "upload", "download", or "connect"
+	Err       error  // Underlying error
+	Retryable bool   // The operation may succeed if retried (see RetryPolicy)
+}
+
+// Error implements the error interface
+func (e *GCSError) Error() string {
+	return fmt.Sprintf("GCS %s error during %s: %v", e.Category, e.Op, e.Err)
+}
+
+// Unwrap implements the errors.Unwrap interface
+func (e *GCSError) Unwrap() error {
+	return e.Err
+}
+
+// Is implements the errors.Is interface to match ErrStorageUnavailable
+func (e *GCSError) Is(target error) bool {
+	return target == ErrStorageUnavailable
+}
+
+// gcsStatusError is a GCS JSON API error answer
+type gcsStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *gcsStatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// newGCSStatusError reads the error answer of a GCS JSON API request
+func newGCSStatusError(resp *http.Response, body []byte) *gcsStatusError {
+	var answer struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	message := http.StatusText(resp.StatusCode)
+	if json.Unmarshal(body, &answer) == nil && answer.Error.Message != "" {
+		message = answer.Error.Message
+	}
+	return &gcsStatusError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// CategorizeGCSError examines an error and returns an appropriately
+// categorized GCSError: failures to obtain an access token and 401/403
+// answers are authentication errors, transport failures are network errors,
+// and other answers are storage errors, retryable for 408, 429 and 5xx.
+func CategorizeGCSError(op string, err error) *GCSError {
+	if err == nil {
+		return nil
+	}
+
+	var gcsErr *GCSError
+	if errors.As(err, &gcsErr) {
+		return gcsErr
+	}
+
+	var statusErr *gcsStatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusUnauthorized:
+			return &GCSError{Category: GCSCategoryAuth, Op: op, Err: fmt.Errorf("authentication failed: %v (verify the service account key or workload identity)", statusErr)}
+		case code == http.StatusForbidden:
+			return &GCSError{Category: GCSCategoryAuth, Op: op, Err: fmt.Errorf("access denied: %v (the service account needs roles/storage.objectAdmin on the bucket)", statusErr)}
+		case code == http.StatusNotFound:
+			return &GCSError{Category: GCSCategoryStorage, Op: op, Err: fmt.Errorf("not found: verify bucket exists and name is correct")}
+		default:
+			return &GCSError{
+				Category:  GCSCategoryStorage,
+				Op:        op,
+				Err:       statusErr,
+				Retryable: code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout,
+			}
+		}
+	}
+
+	// The token source failed: the key was refused, or ADC found nothing
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return &GCSError{Category: GCSCategoryAuth, Op: op, Err: fmt.Errorf("failed to obtain access token: %v", retrieveErr)}
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		if (netErr != nil && netErr.Timeout()) || (urlErr != nil && urlErr.Timeout()) {
+			return &GCSError{Category: GCSCategoryNetwork, Op: op, Err: fmt.Errorf("network timeout: unable to reach GCS endpoint"), Retryable: true}
+		}
+		return &GCSError{Category: GCSCategoryNetwork, Op: op, Err: fmt.Errorf("network error: unable to reach GCS endpoint: %w", err), Retryable: true}
+	}
+
+	return &GCSError{Category: GCSCategoryStorage, Op: op, Err: err}
+}
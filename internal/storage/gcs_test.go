@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGCSStorage_InvalidScheme(t *testing.T) {
+	uri := &StorageURI{
+		Scheme: "file",
+		Path:   "./test/data.json",
+		Raw:    "file://./test/data.json",
+	}
+
+	_, err := NewGCSStorage(uri, "", Options{}, newTestS3Logger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected GCS URI")
+}
+
+func TestParseGCSToken(t *testing.T) {
+	key := `{"type":"service_account","client_email":"cola@project.iam.gserviceaccount.com"}`
+
+	data, err := ParseGCSToken(key)
+	require.NoError(t, err)
+	assert.Equal(t, key, string(data), "a JSON token is the key itself")
+
+	path := filepath.Join(t.TempDir(), "key.json")
+	require.NoError(t, os.WriteFile(path, []byte(key), 0600))
+	data, err = ParseGCSToken(path)
+	require.NoError(t, err)
+	assert.Equal(t, key, string(data), "other tokens name the key file")
+
+	_, err = ParseGCSToken(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read GCS service account key file")
+}
+
+func TestCategorizeGCSError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		category  string
+		retryable bool
+	}{
+		{"unauthorized", &gcsStatusError{StatusCode: 401}, GCSCategoryAuth, false},
+		{"forbidden", &gcsStatusError{StatusCode: 403}, GCSCategoryAuth, false},
+		{"not found", &gcsStatusError{StatusCode: 404}, GCSCategoryStorage, false},
+		{"rate limited", &gcsStatusError{StatusCode: 429}, GCSCategoryStorage, true},
+		{"unavailable", &gcsStatusError{StatusCode: 503}, GCSCategoryStorage, true},
+		{"bad request", &gcsStatusError{StatusCode: 400}, GCSCategoryStorage, false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, GCSCategoryNetwork, true},
+		{"other", errors.New("boom"), GCSCategoryStorage, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CategorizeGCSError(GCSOpUpload, tt.err)
+			assert.Equal(t, tt.category, err.Category)
+			assert.Equal(t, tt.retryable, Retryable(err))
+			assert.Equal(t, tt.category == GCSCategoryAuth, IsAuthError(err))
+			assert.ErrorIs(t, err, ErrStorageUnavailable)
+		})
+	}
+}
+
+// newTestGCSKey returns a service account key whose tokens, issued by an
+// in-memory token endpoint, are accessToken
+func newTestGCSKey(t *testing.T, accessToken string) string {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	encoded, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":3600}`, accessToken)
+	}))
+	t.Cleanup(server.Close)
+
+	key, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "cola@project.iam.gserviceaccount.com",
+		"private_key_id": "key-id",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encoded})),
+		"token_uri":      server.URL,
+	})
+	require.NoError(t, err)
+	return string(key)
+}
+
+func TestGCSClient_ServiceAccountKeyRotation(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Authorization"))
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secondary-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"code":401,"message":"Invalid Credentials"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"name":"bucket"}`)
+	}))
+	defer api.Close()
+
+	logger := newTestS3Logger()
+	primary := newTestGCSKey(t, "primary-token")
+	client, err := NewGCSClient("bucket", "registry.json", primary, logger)
+	require.NoError(t, err)
+	client.endpoint = api.URL
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	client.SetCredentials(NewCredentials(primary, newTestGCSKey(t, "secondary-token"), logger))
+
+	require.NoError(t, client.ValidateBucket(t.Context()))
+	assert.Equal(t, []string{"Bearer primary-token", "Bearer secondary-token"}, seen,
+		"the rejected primary key falls back to the secondary one")
+	assert.Equal(t, CredentialSecondary, client.creds.Status().Active)
+}
@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// GitStorage implements Store interface using a file in a Git repository as
+// backend. It embeds BaseStorage for in-memory CRUD operations; each
+// persist() commits the data file and pushes it, so the repository keeps
+// the history of every change.
+type GitStorage struct {
+	*BaseStorage // Embedded for shared CRUD logic
+	client       *GitClient
+	remote       string
+	branch       string
+	file         string
+}
+
+// NewGitStorage creates a new Git-backed storage.
+// The uri should be a parsed Git StorageURI (git+https://host/org/repo.git?branch=main&path=registry.json).
+// The token authenticates HTTP(S) remotes, as USER:TOKEN or TOKEN alone.
+// opts selects the codec and the author and committer of commits.
+func NewGitStorage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*GitStorage, error) {
+	if !uri.IsGitScheme() {
+		return nil, fmt.Errorf("expected Git URI, got scheme: %s", uri.Scheme)
+	}
+
+	author, committer := opts.GitAuthor, opts.GitCommitter
+	if author == (GitSignature{}) {
+		author, _ = ParseGitSignature(DefaultGitSignature)
+	}
+	if committer == (GitSignature{}) {
+		committer = author
+	}
+
+	client, err := NewGitClient(uri.GitRemote(), uri.GitBranch(), uri.GitFile(), token, author, committer, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Git client: %w", err)
+	}
+	client.SetRetryPolicy(opts.Retry)
+
+	s := &GitStorage{
+		BaseStorage: NewBaseStorage(logger),
+		client:      client,
+		remote:      uri.String(),
+		branch:      uri.GitBranch(),
+		file:        uri.GitFile(),
+	}
+	s.codec = opts.Codec
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+	s.prettyJSON = opts.PrettyJSON
+
+	// Load existing data from the repository or initialize empty storage
+	if err := s.load(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to load data from Git: %w", err)
+	}
+
+	return s, nil
+}
+
+// load clones the repository on startup.
+// If the data file doesn't exist, initializes empty storage and pushes it.
+func (s *GitStorage) load() error {
+	ctx := tracing.WithOperation(context.Background(), "load")
+
+	data, err := s.client.Clone(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to clone Git repository: %w", err)
+	}
+
+	if data == nil {
+		s.logger.Info("Git data file does not exist, initializing empty storage",
+			"remote", s.remote,
+			"branch", s.branch,
+			"file", s.file)
+
+		if err := s.persist(ctx); err != nil {
+			return fmt.Errorf("failed to initialize Git storage: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.UnmarshalData(data); err != nil {
+		return fmt.Errorf("failed to parse registry data (corrupted JSON or CBOR): %w", err)
+	}
+
+	storageData := s.GetData()
+	s.logger.Info("Git storage loaded",
+		"remote", s.remote,
+		"branch", s.branch,
+		"file", s.file,
+		"registry_count", len(storageData.Registries))
+
+	return nil
+}
+
+// Refresh fetches the branch again, to catch up with commits pushed by
+// another instance or merged by hand
+func (s *GitStorage) Refresh(ctx context.Context) error {
+	data, err := s.client.Fetch(tracing.WithOperation(ctx, "refresh"))
+	if err != nil {
+		return fmt.Errorf("failed to fetch Git repository: %w", err)
+	}
+	if data == nil {
+		return nil
+	}
+	if err := s.UnmarshalData(data); err != nil {
+		return fmt.Errorf("failed to parse registry data (corrupted JSON or CBOR): %w", err)
+	}
+	return nil
+}
+
+// persist commits the complete registry data and pushes it.
+// NOTE: This is called while BaseStorage holds the lock,
+// so we use marshalDataLocked() to avoid deadlock.
+func (s *GitStorage) persist(ctx context.Context) error {
+	data, err := s.marshalDataLocked()
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry data: %w", err)
+	}
+
+	if err := s.client.Push(ctx, data, commitMessage(ctx)); err != nil {
+		return err // Already categorized by GitClient
+	}
+	return nil
+}
+
+// commitMessage describes a write: the operation, then trailers naming the
+// user and request behind it
+func commitMessage(ctx context.Context) string {
+	operation := tracing.Operation(ctx)
+	if operation == "" {
+		operation = "update"
+	}
+	var message strings.Builder
+	message.WriteString(strings.ReplaceAll(operation, "_", " "))
+	message.WriteString("\n")
+	var trailers []string
+	if user := auth.UserFromContext(ctx); user != nil {
+		trailers = append(trailers, "Actor: "+user.Username)
+	}
+	if requestID := tracing.RequestID(ctx); requestID != "" {
+		trailers = append(trailers, "Request-Id: "+requestID)
+	}
+	if len(trailers) > 0 {
+		message.WriteString("\n" + strings.Join(trailers, "\n") + "\n")
+	}
+	return message.String()
+}
+
+// CreateRegistry creates a new registry
+func (s *GitStorage) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.CreateRegistry(ctx, r, s.persist)
+}
+
+// GetRegistry retrieves a registry by name
+func (s *GitStorage) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	return s.BaseStorage.GetRegistry(ctx, name)
+}
+
+// UpdateRegistry updates registry metadata
+func (s *GitStorage) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.UpdateRegistry(ctx, r, s.persist)
+}
+
+// DeleteRegistry deletes a registry and all its packages (atomic)
+func (s *GitStorage) DeleteRegistry(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteRegistry(ctx, name, s.persist)
+}
+
+// ListRegistries returns all registries
+func (s *GitStorage) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	return s.BaseStorage.ListRegistries(ctx)
+}
+
+// CreatePackage creates a new package in a registry
+func (s *GitStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.CreatePackage(ctx, registryName, p, s.persist)
+}
+
+// GetPackage retrieves a package from a registry
+func (s *GitStorage) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	return s.BaseStorage.GetPackage(ctx, registryName, packageName)
+}
+
+// UpdatePackage updates package metadata (preserves versions)
+func (s *GitStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
+}
+
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *GitStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
+// DeletePackage deletes a package and all its versions (atomic)
+func (s *GitStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
+}
+
+// ListPackages returns all packages in a registry
+func (s *GitStorage) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	return s.BaseStorage.ListPackages(ctx, registryName)
+}
+
+// FindPackages returns the packages whose custom values match a query
+func (s *GitStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *GitStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *GitStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *GitStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *GitStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *GitStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *GitStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *GitStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *GitStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *GitStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
+// CreateVersion creates a new version for a package
+func (s *GitStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
+}
+
+// GetVersion retrieves a specific version
+func (s *GitStorage) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
+	return s.BaseStorage.GetVersion(ctx, registryName, packageName, version)
+}
+
+// DeleteVersion deletes a specific version
+func (s *GitStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.DeleteVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// ListVersions returns all versions for a package
+func (s *GitStorage) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
+	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
+}
+
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *GitStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *GitStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// GetRegistryIndex generates the registry index (Command Launcher format)
+func (s *GitStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
+	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
+}
+
+// Changes returns the records changed since a generation (differential sync)
+func (s *GitStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *GitStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close removes the local clone
+func (s *GitStorage) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,402 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// Git timeout constants
+const (
+	GitCloneTimeout = 60 * time.Second
+	GitPushTimeout  = 60 * time.Second
+)
+
+// DefaultGitSignature is the author and committer of registry commits when
+// none is configured
+const DefaultGitSignature = "cola-registry <cola-registry@localhost>"
+
+// GitSignature is the identity recorded as author or committer of commits
+type GitSignature struct {
+	Name  string
+	Email string
+}
+
+// ParseGitSignature parses an identity in the "Name <email>" format of Git.
+// An empty string is DefaultGitSignature.
+func ParseGitSignature(s string) (GitSignature, error) {
+	if strings.TrimSpace(s) == "" {
+		s = DefaultGitSignature
+	}
+	address, err := mail.ParseAddress(s)
+	if err != nil || address.Name == "" {
+		return GitSignature{}, fmt.Errorf("invalid Git identity %q: expected \"Name <email>\"", s)
+	}
+	return GitSignature{Name: address.Name, Email: address.Address}, nil
+}
+
+// String returns the identity in the "Name <email>" format
+func (s GitSignature) String() string {
+	return fmt.Sprintf("%s <%s>", s.Name, s.Email)
+}
+
+func (s GitSignature) at(when time.Time) *object.Signature {
+	return &object.Signature{Name: s.Name, Email: s.Email, When: when}
+}
+
+// GitClient keeps a shallow clone of one branch of a Git repository in a
+// temporary directory, and commits and pushes the data file to it
+type GitClient struct {
+	remote    string // Clone URL
+	branch    plumbing.ReferenceName
+	file      string // Path of the data file in the repository
+	auth      transport.AuthMethod
+	author    GitSignature
+	committer GitSignature
+	retrier   *retrier // Retries failed operations (see RetryPolicy)
+	logger    *slog.Logger
+
+	mu   sync.Mutex // Serializes access to the clone
+	dir  string
+	repo *git.Repository
+}
+
+// NewGitClient creates a client for a branch of a remote repository. An
+// HTTP(S) remote authenticates with token, as USER:TOKEN or TOKEN alone.
+func NewGitClient(remote, branch, file, token string, author, committer GitSignature, logger *slog.Logger) (*GitClient, error) {
+	c := &GitClient{
+		remote:    remote,
+		branch:    plumbing.NewBranchReferenceName(branch),
+		file:      file,
+		author:    author,
+		committer: committer,
+		retrier:   newRetrier(DefaultRetryPolicy(), logger),
+		logger:    logger,
+	}
+	if token != "" {
+		if !strings.HasPrefix(remote, "http://") && !strings.HasPrefix(remote, "https://") {
+			logger.Warn("Storage token provided but only HTTP(S) Git remotes use it",
+				"remote", remote)
+		} else {
+			user, password, found := strings.Cut(token, ":")
+			if !found {
+				user, password = "git", token // Most forges ignore the user name of token authentication
+			}
+			c.auth = &githttp.BasicAuth{Username: user, Password: password}
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "cola-registry-git-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Git work directory: %w", err)
+	}
+	c.dir = dir
+	return c, nil
+}
+
+// SetRetryPolicy changes how failed operations are retried
+func (c *GitClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retrier = newRetrier(policy, c.logger)
+}
+
+// Clone makes a shallow clone of the branch and returns the data file,
+// nil if the repository, the branch or the file does not exist yet. A
+// missing branch is created by the first push.
+func (c *GitClient) Clone(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.retrier.do(ctx, GitOpClone, func(ctx context.Context) error {
+		return c.clone(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.readFile()
+}
+
+func (c *GitClient) clone(ctx context.Context) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Info("Cloning Git repository",
+		"remote", c.remote,
+		"branch", c.branch.Short())
+
+	ctx, cancel := context.WithTimeout(ctx, GitCloneTimeout)
+	defer cancel()
+
+	// A failed attempt may leave a partial clone behind
+	if err := resetDir(c.dir); err != nil {
+		return CategorizeGitError(GitOpClone, err)
+	}
+	repo, err := git.PlainCloneContext(ctx, c.dir, false, &git.CloneOptions{
+		URL:           c.remote,
+		Auth:          c.auth,
+		ReferenceName: c.branch,
+		SingleBranch:  true,
+		Depth:         1,
+		Tags:          git.NoTags,
+	})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) || errors.Is(err, git.NoMatchingRefSpecError{}) {
+		logger.Info("Git branch does not exist, it will be created on first write",
+			"remote", c.remote,
+			"branch", c.branch.Short())
+		repo, err = c.initRepository()
+	}
+	if err != nil {
+		logger.Error("Git clone failed",
+			"remote", c.remote,
+			"branch", c.branch.Short(),
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return CategorizeGitError(GitOpClone, err)
+	}
+	c.repo = repo
+
+	logger.Info("Git repository cloned",
+		"remote", c.remote,
+		"branch", c.branch.Short(),
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// initRepository creates an empty repository pushing to the remote branch
+func (c *GitClient) initRepository() (*git.Repository, error) {
+	if err := resetDir(c.dir); err != nil {
+		return nil, err
+	}
+	repo, err := git.PlainInit(c.dir, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{c.remote}}); err != nil {
+		return nil, err
+	}
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, c.branch)); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// Fetch updates the clone to the remote branch and returns the data file
+func (c *GitClient) Fetch(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.retrier.do(ctx, GitOpFetch, func(ctx context.Context) error {
+		return c.fetch(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.readFile()
+}
+
+func (c *GitClient) fetch(ctx context.Context) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, GitCloneTimeout)
+	defer cancel()
+
+	remoteBranch := plumbing.NewRemoteReferenceName(git.DefaultRemoteName, c.branch.Short())
+	err := c.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", c.branch, remoteBranch))},
+		Auth:       c.auth,
+		Depth:      1,
+		Tags:       git.NoTags,
+		Force:      true,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		err = nil
+	}
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) || errors.Is(err, git.NoMatchingRefSpecError{}) {
+		return nil // Nothing pushed yet
+	}
+	if err == nil {
+		var ref *plumbing.Reference
+		if ref, err = c.repo.Reference(remoteBranch, true); err == nil {
+			err = c.resetTo(ref.Hash())
+		}
+	}
+	if err != nil {
+		logger.Error("Git fetch failed",
+			"remote", c.remote,
+			"branch", c.branch.Short(),
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return CategorizeGitError(GitOpFetch, err)
+	}
+
+	logger.Debug("Git repository fetched",
+		"remote", c.remote,
+		"branch", c.branch.Short(),
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// Push commits data as the data file with message and pushes the commit.
+// If the push fails, the commit is dropped so the clone matches the remote.
+func (c *GitClient) Push(ctx context.Context, data []byte, message string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+
+	current, err := c.readFile()
+	if err != nil {
+		return CategorizeGitError(GitOpPush, err)
+	}
+	if current != nil && string(current) == string(data) {
+		return nil // Nothing to commit
+	}
+
+	var previous plumbing.Hash
+	if head, err := c.repo.Reference(c.branch, true); err == nil {
+		previous = head.Hash()
+	}
+	commit, err := c.commit(data, message)
+	if err != nil {
+		return CategorizeGitError(GitOpPush, err)
+	}
+
+	err = c.retrier.do(ctx, GitOpPush, func(ctx context.Context) error {
+		return c.push(ctx)
+	})
+	if err != nil {
+		if resetErr := c.resetTo(previous); resetErr != nil {
+			logger.Error("Failed to drop unpushed Git commit",
+				"commit", commit.String(),
+				"error", resetErr)
+		}
+		return err
+	}
+
+	logger.Info("Git push completed",
+		"remote", c.remote,
+		"branch", c.branch.Short(),
+		"commit", commit.String(),
+		"size_bytes", len(data),
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+func (c *GitClient) commit(data []byte, message string) (plumbing.Hash, error) {
+	worktree, err := c.repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path()), 0700); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := writeFileAtomic(c.path(), data); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := worktree.Add(c.file); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	now := time.Now()
+	return worktree.Commit(message, &git.CommitOptions{
+		Author:    c.author.at(now),
+		Committer: c.committer.at(now),
+	})
+}
+
+func (c *GitClient) push(ctx context.Context) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, GitPushTimeout)
+	defer cancel()
+
+	err := c.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", c.branch, c.branch))},
+		Auth:       c.auth,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		err = nil
+	}
+	if err != nil {
+		logger.Error("Git push failed",
+			"remote", c.remote,
+			"branch", c.branch.Short(),
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return CategorizeGitError(GitOpPush, err)
+	}
+	return nil
+}
+
+// resetTo points the branch, index and work tree at commit; the zero hash
+// empties the branch
+func (c *GitClient) resetTo(commit plumbing.Hash) error {
+	if commit.IsZero() {
+		if err := c.repo.Storer.RemoveReference(c.branch); err != nil {
+			return err
+		}
+		if err := os.Remove(c.path()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := c.repo.Storer.SetReference(plumbing.NewHashReference(c.branch, commit)); err != nil {
+		return err
+	}
+	worktree, err := c.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Reset(&git.ResetOptions{Commit: commit, Mode: git.HardReset})
+}
+
+// readFile returns the data file of the work tree, nil if it does not exist
+func (c *GitClient) readFile() ([]byte, error) {
+	f, err := os.Open(c.path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (c *GitClient) path() string {
+	return filepath.Join(c.dir, filepath.FromSlash(c.file))
+}
+
+// Close removes the clone
+func (c *GitClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return os.RemoveAll(c.dir)
+}
+
+// resetDir empties dir
+func resetDir(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0700)
+}
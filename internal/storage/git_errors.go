@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Git error categories for clear error messages
+const (
+	GitCategoryAuth    = "authentication"
+	GitCategoryNetwork = "network"
+	GitCategoryStorage = "storage"
+)
+
+// Git operations for error context
+const (
+	GitOpClone = "clone"
+	GitOpFetch = "fetch"
+	GitOpPush  = "push"
+)
+
+// GitError wraps Git-specific failures with categorization
+type GitError struct {
+	Category  string // "authentication", "network", or "storage"
+	Op        string // "clone", "fetch", or "push"
+	Err       error  // Underlying error
+	Retryable bool   // The operation may succeed if retried (see RetryPolicy)
+}
+
+// Error implements the error interface
+func (e *GitError) Error() string {
+	return fmt.Sprintf("Git %s error during %s: %v", e.Category, e.Op, e.Err)
+}
+
+// Unwrap implements the errors.Unwrap interface
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// Is implements the errors.Is interface to match ErrStorageUnavailable
+func (e *GitError) Is(target error) bool {
+	return target == ErrStorageUnavailable
+}
+
+// CategorizeGitError examines an error and returns an appropriately
+// categorized GitError. A push rejected because the branch moved on the
+// remote is a storage error that is not retried: the data must be
+// refreshed first.
+func CategorizeGitError(op string, err error) *GitError {
+	if err == nil {
+		return nil
+	}
+
+	var gitErr *GitError
+	if errors.As(err, &gitErr) {
+		return gitErr
+	}
+
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired):
+		return &GitError{Category: GitCategoryAuth, Op: op, Err: fmt.Errorf("authentication required: set --storage-token to a token with push access")}
+	case errors.Is(err, transport.ErrAuthorizationFailed):
+		return &GitError{Category: GitCategoryAuth, Op: op, Err: fmt.Errorf("authorization failed: verify the token has read and write access to the repository")}
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		return &GitError{Category: GitCategoryStorage, Op: op, Err: fmt.Errorf("repository not found: verify the URI (or that the token can see the repository)")}
+	case errors.Is(err, git.ErrForceNeeded), errors.Is(err, git.ErrNonFastForwardUpdate):
+		return &GitError{Category: GitCategoryStorage, Op: op, Err: fmt.Errorf("branch was updated by another writer: %w", err)}
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		return &GitError{Category: GitCategoryNetwork, Op: op, Err: fmt.Errorf("network error: unable to reach Git remote: %w", err), Retryable: true}
+	}
+
+	return &GitError{Category: GitCategoryStorage, Op: op, Err: err}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// newTestGitRepository creates a bare repository to push to, skipping the
+// test when git-receive-pack is not installed
+func newTestGitRepository(t *testing.T) (string, *StorageURI) {
+	if _, err := exec.LookPath("git-receive-pack"); err != nil {
+		t.Skip("git-receive-pack is needed to push to a local repository")
+	}
+	repository := filepath.Join(t.TempDir(), "registry.git")
+	_, err := git.PlainInit(repository, true)
+	require.NoError(t, err)
+	uri, err := ParseStorageURI("git+file://" + filepath.ToSlash(repository))
+	require.NoError(t, err)
+	return repository, uri
+}
+
+func TestGitStorage_CommitsEveryWrite(t *testing.T) {
+	repository, uri := newTestGitRepository(t)
+	author, err := ParseGitSignature("Registry Bot <registry@example.com>")
+	require.NoError(t, err)
+	committer, err := ParseGitSignature("CI <ci@example.com>")
+	require.NoError(t, err)
+
+	store, err := NewGitStorage(uri, "", Options{GitAuthor: author, GitCommitter: committer, PrettyJSON: true}, newTestS3Logger())
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx := auth.WithUser(tracing.WithRequestID(context.Background(), "req-1"), &auth.User{Username: "alice"})
+	require.NoError(t, store.CreateRegistry(ctx, &models.Registry{Name: "tools"}))
+
+	remote, err := git.PlainOpen(repository)
+	require.NoError(t, err)
+	head, err := remote.Reference(plumbing.NewBranchReferenceName("main"), true)
+	require.NoError(t, err)
+	commit, err := remote.CommitObject(head.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "create registry\n\nActor: alice\nRequest-Id: req-1\n", commit.Message)
+	assert.Equal(t, "Registry Bot", commit.Author.Name)
+	assert.Equal(t, "ci@example.com", commit.Committer.Email)
+
+	file, err := commit.File("registry.json")
+	require.NoError(t, err)
+	content, err := file.Contents()
+	require.NoError(t, err)
+	assert.Contains(t, content, "\n  \"registries\"", "pretty JSON keeps diffs readable")
+
+	// The initial commit, then the write
+	commits, err := remote.Log(&git.LogOptions{From: head.Hash()})
+	require.NoError(t, err)
+	count := 0
+	require.NoError(t, commits.ForEach(func(*object.Commit) error { count++; return nil }))
+	assert.Equal(t, 2, count)
+}
+
+func TestGitStorage_ShallowCloneAndRefresh(t *testing.T) {
+	_, uri := newTestGitRepository(t)
+	ctx := context.Background()
+	logger := newTestS3Logger()
+
+	first, err := NewGitStorage(uri, "", Options{}, logger)
+	require.NoError(t, err)
+	defer first.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, first.CreateRegistry(ctx, &models.Registry{Name: name}))
+	}
+
+	second, err := NewGitStorage(uri, "", Options{}, logger)
+	require.NoError(t, err)
+	defer second.Close()
+	_, err = os.Stat(filepath.Join(second.client.dir, ".git", "shallow"))
+	assert.NoError(t, err, "the clone is shallow")
+	registries, err := second.ListRegistries(ctx)
+	require.NoError(t, err)
+	assert.Len(t, registries, 3)
+
+	// A write from the second instance, on top of the shallow clone
+	require.NoError(t, second.CreateRegistry(ctx, &models.Registry{Name: "d"}))
+
+	// The first instance is behind: its push is rejected and rolled back
+	// until it refreshes
+	err = first.CreateRegistry(ctx, &models.Registry{Name: "e"})
+	require.ErrorIs(t, err, ErrStorageUnavailable)
+	_, err = first.GetRegistry(ctx, "e")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, first.Refresh(ctx))
+	_, err = first.GetRegistry(ctx, "d")
+	require.NoError(t, err)
+	require.NoError(t, first.CreateRegistry(ctx, &models.Registry{Name: "e"}))
+}
+
+func TestParseGitSignature(t *testing.T) {
+	signature, err := ParseGitSignature("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultGitSignature, signature.String())
+
+	signature, err = ParseGitSignature("Jane Doe <jane@example.com>")
+	require.NoError(t, err)
+	assert.Equal(t, GitSignature{Name: "Jane Doe", Email: "jane@example.com"}, signature)
+
+	_, err = ParseGitSignature("jane@example.com")
+	assert.Error(t, err, "a name is required")
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Pinger is implemented by backends that can check they still reach their
+// storage, for health checks: a stat of the file, a HEAD of the S3 or GCS
+// object or WebDAV file, a resolve of the OCI tag
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// PersistHealth is implemented by backends reporting whether their writes
+// reach the storage
+type PersistHealth interface {
+	PersistStatus() PersistStatus
+}
+
+// PersistStatus reports the outcome of the writes persisted to storage
+type PersistStatus struct {
+	Failures    int        `json:"failures"`                // Failed writes since the last successful one
+	LastSuccess *time.Time `json:"last_success,omitempty"`  // Of the last persisted write
+	LastError   string     `json:"last_error,omitempty"`    // Of the last failed write, absent once one succeeds
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"` // Of the last failed write
+}
+
+// persistHealth records the outcome of persist callbacks
+type persistHealth struct {
+	mu          sync.Mutex
+	failures    int
+	lastSuccess time.Time
+	lastErr     string
+	lastErrorAt time.Time
+}
+
+// runPersist calls the backend's persist callback and records its outcome
+// for PersistStatus; called with the lock held. In debounced mode the
+// callback only defers the write, so the flush records the outcome instead.
+func (b *BaseStorage) runPersist(ctx context.Context, persist PersistFunc) error {
+	err := persist(ctx)
+	if b.writeBehind == nil {
+		b.recordPersist(err)
+	}
+	return err
+}
+
+// recordPersist records a persist outcome. Conflicts are left out: the
+// storage was reached, another writer got there first.
+func (b *BaseStorage) recordPersist(err error) {
+	if errors.Is(err, ErrConflictRemoteModified) {
+		return
+	}
+	h := &b.persistHealth
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.failures++
+		h.lastErr = err.Error()
+		h.lastErrorAt = time.Now().UTC()
+		return
+	}
+	h.failures = 0
+	h.lastSuccess = time.Now().UTC()
+	h.lastErr = ""
+}
+
+// PersistStatus returns the outcome of the writes persisted so far
+func (b *BaseStorage) PersistStatus() PersistStatus {
+	h := &b.persistHealth
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := PersistStatus{Failures: h.failures, LastError: h.lastErr}
+	if !h.lastSuccess.IsZero() {
+		lastSuccess := h.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+	if h.lastErr != "" {
+		lastErrorAt := h.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	return status
+}
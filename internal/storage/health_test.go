@@ -0,0 +1,57 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestS3Storage_PersistStatus(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "bucket")
+	uri, err := storage.ParseStorageURI(strings.Replace(s3.URL, "http://", "s3+http://", 1) + "/bucket/registry.json?region=us-east-1")
+	require.NoError(t, err)
+	store, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", storage.Options{Retry: storage.RetryPolicy{MaxAttempts: 1}}, newConformanceLogger())
+	require.NoError(t, err)
+	require.NoError(t, store.Ping(ctx))
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	status := store.PersistStatus()
+	assert.Zero(t, status.Failures)
+	assert.NotNil(t, status.LastSuccess)
+	assert.Empty(t, status.LastError)
+
+	// Failed writes are counted until one succeeds; the object stays reachable
+	s3.FailPuts(true)
+	assert.ErrorIs(t, store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)), storage.ErrStorageUnavailable)
+	assert.ErrorIs(t, store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)), storage.ErrStorageUnavailable)
+	status = store.PersistStatus()
+	assert.Equal(t, 2, status.Failures)
+	assert.NotEmpty(t, status.LastError)
+	assert.NotNil(t, status.LastErrorAt)
+	assert.NoError(t, store.Ping(ctx))
+
+	s3.FailPuts(false)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)))
+	status = store.PersistStatus()
+	assert.Zero(t, status.Failures)
+	assert.Empty(t, status.LastError)
+}
+
+func TestFileStorage_Ping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	store, err := storage.NewFileStorage(path, "", newConformanceLogger())
+	require.NoError(t, err)
+	assert.NoError(t, store.Ping(context.Background()))
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, store.Ping(context.Background()))
+}
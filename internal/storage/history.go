@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// recordLocked appends a change to a package's history, stamped with the
+// current time and the authenticated user, and returns a function undoing
+// it, for rollback when persistence fails. Only the last
+// models.HistoryLimit changes are kept.
+// Caller MUST hold the write lock.
+func (b *BaseStorage) recordLocked(ctx context.Context, registryName, packageName string, change models.ChangeRecord) func() {
+	if b.data.History == nil {
+		b.data.History = make(map[string][]*models.ChangeRecord)
+	}
+	key := models.RecordKey(registryName, packageName)
+	prev, existed := b.data.History[key]
+
+	change.Time = time.Now().UTC()
+	if user := auth.UserFromContext(ctx); user != nil {
+		change.Actor = user.Username
+	}
+	// Copy rather than append in place, so undo can restore prev as it was
+	history := make([]*models.ChangeRecord, 0, len(prev)+1)
+	history = append(history, prev...)
+	history = append(history, &change)
+	if len(history) > models.HistoryLimit {
+		history = history[len(history)-models.HistoryLimit:]
+	}
+	b.data.History[key] = history
+
+	return func() {
+		if existed {
+			b.data.History[key] = prev
+		} else {
+			delete(b.data.History, key)
+		}
+	}
+}
+
+// dropHistoryLocked removes the histories of every package of a registry
+// and returns a function restoring them, for rollback when persistence
+// fails. Caller MUST hold the write lock.
+func (b *BaseStorage) dropHistoryLocked(registryName string) func() {
+	prefix := models.RecordKey(registryName) + "/"
+	dropped := make(map[string][]*models.ChangeRecord)
+	for key, history := range b.data.History {
+		if strings.HasPrefix(key, prefix) {
+			dropped[key] = history
+			delete(b.data.History, key)
+		}
+	}
+
+	return func() {
+		for key, history := range dropped {
+			b.data.History[key] = history
+		}
+	}
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest
+// first. The history of a deleted package stays available until its
+// registry is deleted; ErrNotFound is returned when the package neither
+// exists nor has a history.
+func (b *BaseStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	registry, exists := b.data.Registries[registryName]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	history := b.data.History[models.RecordKey(registryName, packageName)]
+	if _, exists := registry.Packages[packageName]; !exists && len(history) == 0 {
+		return nil, ErrNotFound
+	}
+
+	newestFirst := make([]*models.ChangeRecord, len(history))
+	for i, change := range history {
+		newestFirst[len(history)-1-i] = change
+	}
+	return newestFirst, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/auth"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func historyTypes(history []*models.ChangeRecord) []string {
+	types := make([]string, len(history))
+	for i, change := range history {
+		types[i] = change.Type
+	}
+	return types
+}
+
+func TestBaseStorage_PackageHistory(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := auth.WithUser(context.Background(), &auth.User{Username: "alice"})
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil), nil))
+	require.NoError(t, bs.UpdatePackage(ctx, "reg", models.NewPackage("pkg", "Tools", nil, map[string]string{"team": "infra"}), nil))
+	// An update changing nothing is not recorded
+	require.NoError(t, bs.UpdatePackage(ctx, "reg", models.NewPackage("pkg", "Tools", nil, map[string]string{"team": "infra"}), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip", 0, 4), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", scheduledVersion("2.0.0", 5, 9, time.Now().Add(time.Hour)), nil))
+	require.NoError(t, bs.CancelVersion(ctx, "reg", "pkg", "2.0.0", nil))
+	require.NoError(t, bs.DeleteVersion(ctx, "reg", "pkg", "1.0.0", nil))
+
+	// Failed writes leave no trace
+	err := bs.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "3.0.0", "sha256:a", "http://x/3.zip", 0, 9), func(context.Context) error { return errors.New("disk full") })
+	assert.Equal(t, ErrStorageUnavailable, err)
+
+	history, err := bs.GetPackageHistory(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		models.ChangeVersionDeleted,
+		models.ChangeVersionCancelled,
+		models.ChangeVersionScheduled,
+		models.ChangeVersionPublished,
+		models.ChangePackageUpdated,
+		models.ChangePackageCreated,
+	}, historyTypes(history))
+	assert.Equal(t, "alice", history[0].Actor)
+	assert.Equal(t, "1.0.0", history[0].Version)
+	assert.Equal(t, []models.FieldChange{
+		{Field: "description", New: "Tools"},
+		{Field: "custom_values.team", New: "infra"},
+	}, history[4].Changes)
+
+	// The history outlives the package, not its registry
+	require.NoError(t, bs.DeletePackage(ctx, "reg", "pkg", nil))
+	history, err = bs.GetPackageHistory(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.Equal(t, models.ChangePackageDeleted, history[0].Type)
+
+	_, err = bs.GetPackageHistory(ctx, "reg", "other")
+	assert.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, bs.DeleteRegistry(ctx, "reg", nil))
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	_, err = bs.GetPackageHistory(ctx, "reg", "pkg")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestBaseStorage_PackageHistoryLimit(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil), nil))
+	for i := 0; i < models.HistoryLimit; i++ {
+		v := models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip", 0, 9)
+		require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", v, nil))
+		require.NoError(t, bs.DeleteVersion(ctx, "reg", "pkg", "1.0.0", nil))
+	}
+
+	history, err := bs.GetPackageHistory(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.Len(t, history, models.HistoryLimit)
+	assert.Equal(t, models.ChangeVersionPublished, history[len(history)-1].Type)
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// ErrMigrationMismatch is returned when migrated data does not read back
+// the same as its source
+var ErrMigrationMismatch = errors.New("migrated data differs from the source data")
+
+// MigrationResult reports the data copied by Migrate
+type MigrationResult struct {
+	Registries int
+	Packages   int
+	Versions   int
+	Generation uint64 // Sync generation of the data, kept by the copy
+	Bytes      int    // Size of the exported source data
+}
+
+// Migrate copies the whole data of from over the data of to, sync state
+// included, so sync clients of the old backend keep their generation. The
+// data of to is replaced in a single write.
+func Migrate(ctx context.Context, from Snapshotter, to Replacer) (*MigrationResult, error) {
+	raw, err := from.MarshalData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export source data: %w", err)
+	}
+	data, _, err := loadStorage(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode source data: %w", err)
+	}
+	if err := to.ReplaceData(ctx, raw); err != nil {
+		return nil, fmt.Errorf("failed to write target data: %w", err)
+	}
+
+	result := countMigrated(data)
+	result.Bytes = len(raw)
+	return result, nil
+}
+
+// VerifyMigration compares the data of a migration target, preferably
+// reloaded from its backend, with the source data. ErrMigrationMismatch is
+// returned when they differ.
+func VerifyMigration(from, to Snapshotter) error {
+	source, err := from.MarshalData()
+	if err != nil {
+		return fmt.Errorf("failed to export source data: %w", err)
+	}
+	target, err := to.MarshalData()
+	if err != nil {
+		return fmt.Errorf("failed to export target data: %w", err)
+	}
+	same, err := sameData(source, target)
+	if err != nil {
+		return err
+	}
+	if same {
+		return nil
+	}
+
+	// Counts help telling a partial copy from a corrupted one
+	want, _, _ := loadStorage(source)
+	got, _, err := loadStorage(target)
+	if err != nil || want == nil {
+		return ErrMigrationMismatch
+	}
+	w, g := countMigrated(want), countMigrated(got)
+	return fmt.Errorf("%w: source has %d registries, %d packages and %d versions, target %d, %d and %d",
+		ErrMigrationMismatch, w.Registries, w.Packages, w.Versions, g.Registries, g.Packages, g.Versions)
+}
+
+// IsEmpty reports whether a backend holds no registries, so a migration
+// does not overwrite data by mistake
+func IsEmpty(s Snapshotter) (bool, error) {
+	raw, err := s.MarshalData()
+	if err != nil {
+		return false, err
+	}
+	data, _, err := decodeStorage(raw)
+	if err != nil {
+		return false, err
+	}
+	return len(data.Registries) == 0, nil
+}
+
+func countMigrated(data *models.Storage) *MigrationResult {
+	result := &MigrationResult{Registries: len(data.Registries)}
+	for _, registry := range data.Registries {
+		result.Packages += len(registry.Packages)
+		for _, pkg := range registry.Packages {
+			result.Versions += len(pkg.Versions)
+		}
+	}
+	if data.Sync != nil {
+		result.Generation = data.Sync.Generation
+	}
+	return result
+}
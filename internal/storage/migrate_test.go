@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source, err := NewFileStorage(filepath.Join(dir, "source.json"), "", newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, source.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, source.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, nil)))
+	require.NoError(t, source.CreateVersion(ctx, "tools", "deployer", models.NewVersion("deployer", "1.0.0", "sha256:"+strings.Repeat("a", 64), "https://example.com/deployer-1.0.0.zip", 0, 9)))
+	_, generation, err := source.Changes(ctx, 0)
+	require.NoError(t, err)
+
+	targetURI, err := ParseStorageURI("file://" + filepath.Join(dir, "target.json"))
+	require.NoError(t, err)
+	store, err := NewStorage(targetURI, "", Options{Codec: CodecCBOR}, newTestS3Logger())
+	require.NoError(t, err)
+	target := store.(Replacer)
+	empty, err := IsEmpty(target)
+	require.NoError(t, err)
+	assert.True(t, empty)
+
+	result, err := Migrate(ctx, source, target)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Registries)
+	assert.Equal(t, 1, result.Packages)
+	assert.Equal(t, 1, result.Versions)
+	assert.Equal(t, generation, result.Generation)
+
+	// The target reloaded from its file holds the same data, sync state included
+	reloaded, err := NewStorage(targetURI, "", Options{}, newTestS3Logger())
+	require.NoError(t, err)
+	assert.NoError(t, VerifyMigration(source, reloaded.(Snapshotter)))
+	_, current, err := reloaded.Changes(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, generation, current)
+	empty, err = IsEmpty(reloaded.(Snapshotter))
+	require.NoError(t, err)
+	assert.False(t, empty)
+
+	// A target that lost a record is reported
+	require.NoError(t, reloaded.DeletePackage(ctx, "tools", "deployer"))
+	err = VerifyMigration(source, reloaded.(Snapshotter))
+	assert.ErrorIs(t, err, ErrMigrationMismatch)
+	assert.ErrorContains(t, err, "source has 1 registries, 1 packages and 1 versions, target 1, 0 and 0")
+}
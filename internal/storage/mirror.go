@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// MirrorRetryInterval is how long a failed mirror write waits before it is
+// tried again when no other write comes first
+const MirrorRetryInterval = 30 * time.Second
+
+// mirrorTimeout bounds each copy of the data to the mirror
+const mirrorTimeout = 5 * time.Minute
+
+// Snapshotter is implemented by backends that can export their whole data
+type Snapshotter interface {
+	MarshalData() ([]byte, error)
+}
+
+// Replacer is implemented by backends whose whole data can be replaced in a
+// single write, so they can serve as a mirror
+type Replacer interface {
+	Store
+	Snapshotter
+	ReplaceData(ctx context.Context, raw []byte) error
+}
+
+// ReplaceData replaces the data with raw, in any codec, and persists it.
+// If persist fails, the previous data is restored.
+func (b *BaseStorage) ReplaceData(ctx context.Context, raw []byte, persist PersistFunc) error {
+	data, _, err := loadStorage(raw)
+	if err != nil {
+		return fmt.Errorf("failed to decode replacement data: %w", err)
+	}
+	initSyncState(data)
+	backfillTimestamps(data)
+
+	ctx, unlock, err := b.lock(ctx, "replace_data")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	previous, previousIndex := b.data, b.customIndex
+	b.data = data
+	b.customIndex = newCustomValueIndex(data)
+
+	if persist != nil {
+		if err := b.runPersist(ctx, persist); err != nil {
+			b.data, b.customIndex = previous, previousIndex
+			b.logger.Error("Storage write failed",
+				"operation", "replace_data",
+				"error", err)
+			// Keep the backend error: the caller is a mirror reporting it
+			return fmt.Errorf("%w: %w", persistError(ctx, err), err)
+		}
+	}
+
+	b.logger.Info("Storage data replaced", "registry_count", len(data.Registries))
+	return nil
+}
+
+// MirrorStats reports how far a mirror is behind the primary backend
+type MirrorStats struct {
+	URI         string     `json:"uri"`                     // Password redacted
+	Generation  uint64     `json:"generation"`              // Primary generation last copied to the mirror
+	Lag         uint64     `json:"lag"`                     // Primary generations not copied yet
+	LagSeconds  float64    `json:"lag_seconds"`             // Since the oldest write not copied yet; 0 when in sync
+	Writes      uint64     `json:"writes"`                  // Successful copies
+	Failures    uint64     `json:"failures"`                // Failed copies
+	LastSuccess *time.Time `json:"last_success,omitempty"`  // Of the last successful copy
+	LastError   string     `json:"last_error,omitempty"`    // Of the last failed copy, cleared by a success
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"` // Of the last failed copy
+}
+
+// MirrorStore wraps a Store and copies the data of its primary backend to a
+// mirror backend after each successful write, e.g. from S3 to a local file.
+// Copies run in the background, one at a time, and writes made meanwhile
+// are caught up by a single further copy, so the mirror never slows down
+// or fails a request: a failed copy is logged, counted in the stats and
+// tried again after MirrorRetryInterval or with the next write. Methods not
+// overridden pass straight through.
+type MirrorStore struct {
+	Store
+	source Snapshotter // The primary backend
+	mirror Replacer
+	uri    string
+	logger *slog.Logger
+
+	pending chan struct{}
+	stop    chan struct{}
+	done    sync.WaitGroup
+	syncMu  sync.Mutex // Serializes copies, so an older one never lands last
+
+	mu           sync.Mutex
+	stats        MirrorStats
+	pendingSince time.Time // Of the oldest write not copied yet; zero when in sync
+}
+
+// NewMirrorStore wraps store, whose data is read from source (the primary
+// backend, store itself or a store it wraps), to copy it to mirror. uri
+// names the mirror in stats and logs. Call Reconcile before serving, then
+// Close to stop copying and close both backends.
+func NewMirrorStore(store Store, source Snapshotter, mirror Replacer, uri string, logger *slog.Logger) *MirrorStore {
+	m := &MirrorStore{
+		Store:   store,
+		source:  source,
+		mirror:  mirror,
+		uri:     uri,
+		logger:  logger.With("mirror_uri", uri),
+		pending: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stats:   MirrorStats{URI: uri},
+	}
+	m.done.Add(1)
+	go m.run()
+	return m
+}
+
+// Reconcile compares the mirror with the primary backend and copies the
+// primary data over when they differ, to catch up with writes the mirror
+// missed while the server was down or the mirror unavailable. A failed copy
+// is retried in the background.
+func (m *MirrorStore) Reconcile(ctx context.Context) error {
+	generation, err := storeGeneration(ctx, m.Store)
+	if err != nil {
+		return err
+	}
+	primary, err := m.source.MarshalData()
+	if err != nil {
+		return fmt.Errorf("failed to marshal primary data: %w", err)
+	}
+	mirrored, err := m.mirror.MarshalData()
+	if err != nil {
+		return fmt.Errorf("failed to marshal mirror data: %w", err)
+	}
+	same, err := sameData(primary, mirrored)
+	if err != nil {
+		return err
+	}
+	if same {
+		m.mu.Lock()
+		m.stats.Generation = generation
+		m.mu.Unlock()
+		m.logger.Info("Storage mirror is up to date", "generation", generation)
+		return nil
+	}
+
+	m.logger.Warn("Storage mirror differs from the primary backend, copying the primary data",
+		"generation", generation)
+	m.markPending()
+	if err := m.Sync(ctx); err != nil {
+		m.schedule()
+		return err
+	}
+	return nil
+}
+
+// Sync copies the primary data to the mirror now
+func (m *MirrorStore) Sync(ctx context.Context) error {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+
+	start := time.Now()
+	generation, err := storeGeneration(ctx, m.Store)
+	if err == nil {
+		var data []byte
+		if data, err = m.source.MarshalData(); err == nil {
+			err = m.mirror.ReplaceData(ctx, data)
+		}
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.stats.Failures++
+		m.stats.LastError = err.Error()
+		m.stats.LastErrorAt = &now
+		m.logger.Error("Storage mirror write failed",
+			"generation", generation,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return err
+	}
+	m.stats.Writes++
+	m.stats.Generation = max(m.stats.Generation, generation)
+	m.stats.LastSuccess = &now
+	m.stats.LastError = ""
+	if current, err := storeGeneration(ctx, m.Store); err == nil && current <= generation {
+		m.pendingSince = time.Time{}
+	}
+	m.logger.Info("Storage mirror written",
+		"generation", generation,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// Stats returns the mirror lag and the outcome of recent copies
+func (m *MirrorStore) Stats(ctx context.Context) MirrorStats {
+	generation, err := storeGeneration(ctx, m.Store)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats
+	if err == nil && generation > stats.Generation {
+		stats.Lag = generation - stats.Generation
+	}
+	if !m.pendingSince.IsZero() {
+		stats.LagSeconds = time.Since(m.pendingSince).Seconds()
+	}
+	return stats
+}
+
+// run copies the data after writes until Close
+func (m *MirrorStore) run() {
+	defer m.done.Done()
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-m.pending:
+		case <-retry:
+		case <-m.stop:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+		if err := m.Sync(ctx); err != nil {
+			retry = time.After(MirrorRetryInterval)
+		} else {
+			retry = nil
+		}
+		cancel()
+	}
+}
+
+// markPending records a write not copied yet
+func (m *MirrorStore) markPending() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pendingSince.IsZero() {
+		m.pendingSince = time.Now()
+	}
+}
+
+// write runs a store write and schedules a copy on success
+func (m *MirrorStore) write(fn func() error) error {
+	if err := fn(); err != nil {
+		return err
+	}
+	m.markPending()
+	m.schedule()
+	return nil
+}
+
+// schedule queues a copy
+func (m *MirrorStore) schedule() {
+	select {
+	case m.pending <- struct{}{}:
+	default: // A copy is already queued
+	}
+}
+
+// Close stops copying, makes a last attempt to copy pending writes, and
+// closes the mirror and the wrapped store
+func (m *MirrorStore) Close() error {
+	close(m.stop)
+	m.done.Wait()
+
+	m.mu.Lock()
+	pending := !m.pendingSince.IsZero()
+	m.mu.Unlock()
+	if pending {
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+		m.Sync(ctx)
+		cancel()
+	}
+
+	if err := m.mirror.Close(); err != nil {
+		m.logger.Warn("Failed to close storage mirror", "error", err)
+	}
+	return m.Store.Close()
+}
+
+// sameData reports whether two encoded datasets hold the same data,
+// whatever their codec
+func sameData(a, b []byte) (bool, error) {
+	var encoded [2][]byte
+	for i, raw := range [][]byte{a, b} {
+		data, _, err := loadStorage(raw)
+		if err != nil {
+			return false, fmt.Errorf("failed to decode data: %w", err)
+		}
+		initSyncState(data)
+		if encoded[i], err = encodeStorage(CodecJSON, data, false); err != nil {
+			return false, err
+		}
+	}
+	return bytes.Equal(encoded[0], encoded[1]), nil
+}
+
+// CreateRegistry creates a registry and schedules a copy
+func (m *MirrorStore) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return m.write(func() error { return m.Store.CreateRegistry(ctx, r) })
+}
+
+// UpdateRegistry updates a registry and schedules a copy
+func (m *MirrorStore) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return m.write(func() error { return m.Store.UpdateRegistry(ctx, r) })
+}
+
+// DeleteRegistry deletes a registry and schedules a copy
+func (m *MirrorStore) DeleteRegistry(ctx context.Context, name string) error {
+	return m.write(func() error { return m.Store.DeleteRegistry(ctx, name) })
+}
+
+// CreatePackage creates a package and schedules a copy
+func (m *MirrorStore) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return m.write(func() error { return m.Store.CreatePackage(ctx, registryName, p) })
+}
+
+// UpdatePackage updates a package and schedules a copy
+func (m *MirrorStore) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return m.write(func() error { return m.Store.UpdatePackage(ctx, registryName, p) })
+}
+
+// UpdatePackages updates several packages and schedules a copy
+func (m *MirrorStore) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return m.write(func() error { return m.Store.UpdatePackages(ctx, registryName, packages) })
+}
+
+// DeletePackage deletes a package and schedules a copy
+func (m *MirrorStore) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return m.write(func() error { return m.Store.DeletePackage(ctx, registryName, packageName) })
+}
+
+// CreateVersion creates a version and schedules a copy
+func (m *MirrorStore) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return m.write(func() error { return m.Store.CreateVersion(ctx, registryName, packageName, v) })
+}
+
+// DeleteVersion deletes a version and schedules a copy
+func (m *MirrorStore) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return m.write(func() error { return m.Store.DeleteVersion(ctx, registryName, packageName, version) })
+}
+
+// ReleaseVersion releases a scheduled version and schedules a copy
+func (m *MirrorStore) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return m.write(func() error { return m.Store.ReleaseVersion(ctx, registryName, packageName, version) })
+}
+
+// CancelVersion cancels a scheduled version and schedules a copy
+func (m *MirrorStore) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return m.write(func() error { return m.Store.CancelVersion(ctx, registryName, packageName, version) })
+}
+
+// PutCertificate stores a certificate and schedules a copy
+func (m *MirrorStore) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return m.write(func() error { return m.Store.PutCertificate(ctx, name, data) })
+}
+
+// DeleteCertificate removes a certificate and schedules a copy
+func (m *MirrorStore) DeleteCertificate(ctx context.Context, name string) error {
+	return m.write(func() error { return m.Store.DeleteCertificate(ctx, name) })
+}
+
+// CreateAPIKey stores an API key and schedules a copy
+func (m *MirrorStore) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return m.write(func() error { return m.Store.CreateAPIKey(ctx, key) })
+}
+
+// DeleteAPIKey revokes an API key and schedules a copy
+func (m *MirrorStore) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return m.write(func() error { return m.Store.DeleteAPIKey(ctx, registryName, id) })
+}
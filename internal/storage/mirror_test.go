@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// failingMirror is a file mirror whose copies fail while fail is set
+type failingMirror struct {
+	*FileStorage
+	fail atomic.Bool
+}
+
+func (m *failingMirror) ReplaceData(ctx context.Context, raw []byte) error {
+	if m.fail.Load() {
+		return errors.New("mirror unavailable")
+	}
+	return m.FileStorage.ReplaceData(ctx, raw)
+}
+
+func newMirrorTestStores(t *testing.T) (*FileStorage, *failingMirror, string) {
+	dir := t.TempDir()
+	primary, err := NewFileStorage(filepath.Join(dir, "primary.json"), "", newTestS3Logger())
+	require.NoError(t, err)
+	mirrorPath := filepath.Join(dir, "mirror.json")
+	mirror, err := NewFileStorage(mirrorPath, "", newTestS3Logger())
+	require.NoError(t, err)
+	return primary, &failingMirror{FileStorage: mirror}, mirrorPath
+}
+
+func TestMirrorStore_CopiesWrites(t *testing.T) {
+	ctx := context.Background()
+	primary, mirror, mirrorPath := newMirrorTestStores(t)
+	store := NewMirrorStore(primary, primary, mirror, "file://mirror.json", newTestS3Logger())
+	require.NoError(t, store.Reconcile(ctx))
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+
+	require.Eventually(t, func() bool {
+		return store.Stats(ctx).Lag == 0 && store.Stats(ctx).LagSeconds == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	stats := store.Stats(ctx)
+	assert.Equal(t, uint64(2), stats.Generation)
+	assert.NotZero(t, stats.Writes)
+	assert.NotNil(t, stats.LastSuccess)
+	require.NoError(t, store.Close())
+
+	// The mirror file holds the data
+	reopened, err := NewFileStorage(mirrorPath, "", newTestS3Logger())
+	require.NoError(t, err)
+	_, err = reopened.GetPackage(ctx, "reg", "pkg")
+	assert.NoError(t, err)
+}
+
+func TestMirrorStore_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	primary, mirror, _ := newMirrorTestStores(t)
+	require.NoError(t, primary.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+
+	// The mirror missed the write: it is copied at startup
+	store := NewMirrorStore(primary, primary, mirror, "file://mirror.json", newTestS3Logger())
+	defer store.Close()
+	require.NoError(t, store.Reconcile(ctx))
+	_, err := mirror.GetRegistry(ctx, "reg")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), store.Stats(ctx).Writes)
+
+	// An up to date mirror is left alone
+	require.NoError(t, store.Reconcile(ctx))
+	stats := store.Stats(ctx)
+	assert.Equal(t, uint64(1), stats.Writes)
+	assert.Equal(t, uint64(1), stats.Generation)
+	assert.Zero(t, stats.Lag)
+}
+
+func TestMirrorStore_Failures(t *testing.T) {
+	ctx := context.Background()
+	primary, mirror, _ := newMirrorTestStores(t)
+	store := NewMirrorStore(primary, primary, mirror, "file://mirror.json", newTestS3Logger())
+	defer store.Close()
+	require.NoError(t, store.Reconcile(ctx))
+
+	// A failing mirror does not fail writes, and is reported
+	mirror.fail.Store(true)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.Eventually(t, func() bool {
+		return store.Stats(ctx).Failures > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	stats := store.Stats(ctx)
+	assert.Equal(t, uint64(1), stats.Lag)
+	assert.Positive(t, stats.LagSeconds)
+	assert.Contains(t, stats.LastError, "mirror unavailable")
+	assert.NotNil(t, stats.LastErrorAt)
+
+	// The next write catches up
+	mirror.fail.Store(false)
+	require.NoError(t, store.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.Eventually(t, func() bool {
+		return store.Stats(ctx).Lag == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	stats = store.Stats(ctx)
+	assert.Empty(t, stats.LastError)
+	assert.Zero(t, stats.LagSeconds)
+	_, err := mirror.GetPackage(ctx, "reg", "pkg")
+	assert.NoError(t, err)
+}
+
+func TestBaseStorage_ReplaceDataRollsBack(t *testing.T) {
+	ctx := context.Background()
+	primary, mirror, _ := newMirrorTestStores(t)
+	require.NoError(t, primary.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	data, err := primary.MarshalData()
+	require.NoError(t, err)
+
+	err = mirror.BaseStorage.ReplaceData(ctx, data, func(context.Context) error {
+		return errors.New("disk full")
+	})
+	assert.ErrorIs(t, err, ErrStorageUnavailable)
+	assert.Contains(t, err.Error(), "disk full")
+	_, err = mirror.GetRegistry(ctx, "reg")
+	assert.ErrorIs(t, err, ErrNotFound, "the previous data is restored")
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// PackageNamePolicy controls whether a package name may be used by more than
+// one registry. Command Launcher exposes packages by name, so the same name
+// in two registries can put different tools behind the same command.
+type PackageNamePolicy string
+
+const (
+	PackageNamesAllow  PackageNamePolicy = "allow"  // No check (default)
+	PackageNamesWarn   PackageNamePolicy = "warn"   // Log collisions on creation
+	PackageNamesUnique PackageNamePolicy = "unique" // Reject collisions on creation
+)
+
+// ErrPackageNameTaken is returned when a package name is already used by
+// another registry and the policy requires unique names
+var ErrPackageNameTaken = errors.New("package name already used in another registry")
+
+// ParsePackageNamePolicy parses a policy name (allow|warn|unique).
+// An empty name means allow.
+func ParsePackageNamePolicy(name string) (PackageNamePolicy, error) {
+	switch PackageNamePolicy(name) {
+	case "", PackageNamesAllow:
+		return PackageNamesAllow, nil
+	case PackageNamesWarn, PackageNamesUnique:
+		return PackageNamePolicy(name), nil
+	default:
+		return "", fmt.Errorf("unsupported package name policy %q (must be allow, warn or unique)", name)
+	}
+}
+
+// PackageNameError reports the registries already using a package name.
+// It matches ErrPackageNameTaken with errors.Is.
+type PackageNameError struct {
+	Name       string
+	Registries []string
+}
+
+func (e *PackageNameError) Error() string {
+	return fmt.Sprintf("package name '%s' is already used in registry %s", e.Name, strings.Join(e.Registries, ", "))
+}
+
+func (e *PackageNameError) Unwrap() error {
+	return ErrPackageNameTaken
+}
+
+// PackageNameConflicts lists every package name used by more than one
+// registry, sorted by name
+func PackageNameConflicts(ctx context.Context, store Store) ([]models.PackageNameConflict, error) {
+	registries, err := store.ListRegistries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string][]string)
+	for _, registry := range registries {
+		for name := range registry.Packages {
+			byName[name] = append(byName[name], registry.Name)
+		}
+	}
+
+	conflicts := make([]models.PackageNameConflict, 0)
+	for name, names := range byName {
+		if len(names) < 2 {
+			continue
+		}
+		sort.Strings(names)
+		conflicts = append(conflicts, models.PackageNameConflict{Name: name, Registries: names})
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Name < conflicts[j].Name
+	})
+	return conflicts, nil
+}
+
+// PackageNameStore wraps a Store and applies a PackageNamePolicy to package
+// creation. Creations are serialized so two registries cannot claim the same
+// name concurrently. Methods not overridden pass straight through.
+type PackageNameStore struct {
+	Store
+	policy PackageNamePolicy
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+// NewPackageNameStore creates a store enforcing policy
+func NewPackageNameStore(store Store, policy PackageNamePolicy, logger *slog.Logger) *PackageNameStore {
+	return &PackageNameStore{
+		Store:  store,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// CreatePackage creates the package unless its name is used by another
+// registry and the policy is unique. Under the warn policy the collision is
+// logged and the package is created.
+func (s *PackageNameStore) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	if s.policy != PackageNamesWarn && s.policy != PackageNamesUnique {
+		return s.Store.CreatePackage(ctx, registryName, p)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	others, err := s.registriesUsing(ctx, p.Name, registryName)
+	if err != nil {
+		return err
+	}
+	if len(others) > 0 {
+		if s.policy == PackageNamesUnique {
+			return &PackageNameError{Name: p.Name, Registries: others}
+		}
+		s.logger.Warn("Package name already used in other registries",
+			"registry", registryName,
+			"package", p.Name,
+			"other_registries", others)
+	}
+
+	return s.Store.CreatePackage(ctx, registryName, p)
+}
+
+// registriesUsing returns the registries other than exclude holding a
+// package called name, sorted
+func (s *PackageNameStore) registriesUsing(ctx context.Context, name, exclude string) ([]string, error) {
+	registries, err := s.Store.ListRegistries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, registry := range registries {
+		if registry.Name == exclude {
+			continue
+		}
+		if _, ok := registry.Packages[name]; ok {
+			names = append(names, registry.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPackageNameStore(t *testing.T, policy PackageNamePolicy) *PackageNameStore {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, name := range []string{"tools", "infra", "data"} {
+		require.NoError(t, fs.CreateRegistry(ctx, models.NewRegistry(name, "", nil, nil)))
+	}
+	require.NoError(t, fs.CreatePackage(ctx, "tools", &models.Package{Name: "deploy", Versions: map[string]*models.Version{}}))
+
+	return NewPackageNameStore(fs, policy, logger)
+}
+
+func TestPackageNameStore_Unique(t *testing.T) {
+	store := newTestPackageNameStore(t, PackageNamesUnique)
+	ctx := context.Background()
+
+	err := store.CreatePackage(ctx, "infra", &models.Package{Name: "deploy", Versions: map[string]*models.Version{}})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrPackageNameTaken))
+
+	var nameErr *PackageNameError
+	require.True(t, errors.As(err, &nameErr))
+	assert.Equal(t, []string{"tools"}, nameErr.Registries)
+
+	_, err = store.GetPackage(ctx, "infra", "deploy")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Other names and the same registry are unaffected
+	assert.NoError(t, store.CreatePackage(ctx, "infra", &models.Package{Name: "provision", Versions: map[string]*models.Version{}}))
+	assert.Equal(t, ErrAlreadyExists, store.CreatePackage(ctx, "tools", &models.Package{Name: "deploy", Versions: map[string]*models.Version{}}))
+}
+
+func TestPackageNameStore_WarnAndAllowCreate(t *testing.T) {
+	for _, policy := range []PackageNamePolicy{PackageNamesWarn, PackageNamesAllow} {
+		t.Run(string(policy), func(t *testing.T) {
+			store := newTestPackageNameStore(t, policy)
+			ctx := context.Background()
+
+			require.NoError(t, store.CreatePackage(ctx, "infra", &models.Package{Name: "deploy", Versions: map[string]*models.Version{}}))
+			require.NoError(t, store.CreatePackage(ctx, "data", &models.Package{Name: "deploy", Versions: map[string]*models.Version{}}))
+
+			conflicts, err := PackageNameConflicts(ctx, store)
+			require.NoError(t, err)
+			assert.Equal(t, []models.PackageNameConflict{
+				{Name: "deploy", Registries: []string{"data", "infra", "tools"}},
+			}, conflicts)
+		})
+	}
+}
+
+func TestParsePackageNamePolicy(t *testing.T) {
+	policy, err := ParsePackageNamePolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, PackageNamesAllow, policy)
+
+	policy, err = ParsePackageNamePolicy("unique")
+	require.NoError(t, err)
+	assert.Equal(t, PackageNamesUnique, policy)
+
+	_, err = ParsePackageNamePolicy("strict")
+	assert.Error(t, err)
+}
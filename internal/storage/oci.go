@@ -2,10 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 
 	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
 )
 
 // OCIStorage implements Store interface using OCI registry as backend.
@@ -15,12 +18,17 @@ type OCIStorage struct {
 	*BaseStorage       // Embedded for shared CRUD logic
 	client       *OCIClient
 	reference    string // OCI reference "registry/repo:latest"
+	digest       string // Of the manifest last pulled or pushed, guarded by the BaseStorage lock
+	revision     string // Revision tag of that manifest, empty when untagged; same guard
+	conflicts    conflictRefresher
 }
 
 // NewOCIStorage creates a new OCI-backed storage.
 // The uri should be a parsed OCI StorageURI (oci://registry/repo).
 // The token is used as a bearer token for OCI registry authentication.
-func NewOCIStorage(uri *StorageURI, token string, logger *slog.Logger) (*OCIStorage, error) {
+// opts selects the codec and compression of the pushed data layer, and how
+// many revision tags are kept.
+func NewOCIStorage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*OCIStorage, error) {
 	if !uri.IsOCIScheme() {
 		return nil, fmt.Errorf("expected OCI URI, got scheme: %s", uri.Scheme)
 	}
@@ -32,25 +40,48 @@ func NewOCIStorage(uri *StorageURI, token string, logger *slog.Logger) (*OCIStor
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OCI client: %w", err)
 	}
+	client.codec = opts.Codec
+	client.compression = opts.Compression
+	client.envelope = opts.Envelope
+	client.revisions = opts.OCIRevisions
+	if opts.LoadTimeout > OCIPullTimeout {
+		client.pullTimeout = opts.LoadTimeout
+	}
+	client.SetRetryPolicy(opts.Retry)
+	if opts.Credentials != nil {
+		client.SetCredentials(opts.Credentials)
+	}
+	return newOCIStorage(client, opts, logger)
+}
 
+// newOCIStorage creates an OCI-backed storage using a configured client
+func newOCIStorage(client *OCIClient, opts Options, logger *slog.Logger) (*OCIStorage, error) {
 	s := &OCIStorage{
 		BaseStorage: NewBaseStorage(logger),
 		client:      client,
-		reference:   reference,
+		reference:   client.reference,
 	}
+	s.codec = opts.Codec
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+	s.cacheFile = opts.CacheFile
+	s.envelope = opts.Envelope
 
 	// Load existing data from OCI or initialize empty storage
 	if err := s.load(); err != nil {
 		return nil, fmt.Errorf("failed to load data from OCI: %w", err)
 	}
 
+	// Initializing the storage in load is persisted immediately
+	s.setPersistMode(opts.PersistMode, opts.FlushInterval, s.persist)
+
 	return s, nil
 }
 
 // load retrieves registry data from OCI registry on startup.
 // If the artifact doesn't exist, initializes empty storage and pushes it.
 func (s *OCIStorage) load() error {
-	ctx := context.Background()
+	ctx := tracing.WithOperation(context.Background(), "load")
 
 	// Check if artifact exists
 	exists, err := s.client.Exists(ctx)
@@ -64,21 +95,14 @@ func (s *OCIStorage) load() error {
 			"reference", s.reference)
 
 		// Push initial empty storage
-		if err := s.persist(); err != nil {
+		if err := s.persist(ctx); err != nil {
 			return fmt.Errorf("failed to initialize OCI storage: %w", err)
 		}
 		return nil
 	}
 
-	// Pull existing data
-	data, err := s.client.Pull(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to pull from OCI: %w", err)
-	}
-
-	// Parse JSON data
-	if err := s.UnmarshalData(data); err != nil {
-		return fmt.Errorf("failed to parse registry data (corrupted JSON): %w", err)
+	if err := s.reload(ctx); err != nil {
+		return err
 	}
 
 	storageData := s.GetData()
@@ -89,20 +113,135 @@ func (s *OCIStorage) load() error {
 	return nil
 }
 
-// persist pushes the complete registry data to OCI registry.
+// Refresh pulls the data again, to catch up with writes made by another
+// instance sharing the artifact
+func (s *OCIStorage) Refresh(ctx context.Context) error {
+	ctx = tracing.WithOperation(ctx, "refresh")
+	if err := s.flushBeforeReload(ctx); err != nil {
+		return err
+	}
+	return s.reload(ctx)
+}
+
+// StoredVersion returns the digest of the manifest the tag points to, for
+// Watcher
+func (s *OCIStorage) StoredVersion(ctx context.Context) (string, error) {
+	return s.client.Resolve(ctx)
+}
+
+// Ping checks the tag can still be resolved, for health checks
+func (s *OCIStorage) Ping(ctx context.Context) error {
+	_, err := s.client.Resolve(tracing.WithOperation(ctx, "ping"))
+	return err
+}
+
+// LoadedVersion returns the digest of the manifest last pulled or pushed
+func (s *OCIStorage) LoadedVersion(ctx context.Context) (string, error) {
+	unlock, err := s.rlock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return s.digest, nil
+}
+
+// CurrentVersion returns the revision tag of the manifest last pulled or
+// pushed, empty when it has none
+func (s *OCIStorage) CurrentVersion(ctx context.Context) (string, error) {
+	unlock, err := s.rlock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return s.revision, nil
+}
+
+// Versions lists the revisions the repository keeps, most recent first;
+// none when revision tags are disabled
+func (s *OCIStorage) Versions(ctx context.Context) ([]DataVersion, error) {
+	return s.client.ListRevisions(tracing.WithOperation(ctx, "list_versions"))
+}
+
+// Rollback replaces the data with a prior revision, given by its tag or
+// number. The restored data is pushed as a new revision, so the rollback
+// can itself be rolled back.
+func (s *OCIStorage) Rollback(ctx context.Context, id string) (uint64, error) {
+	ctx = tracing.WithOperation(ctx, "rollback")
+	revision, err := ParseOCIRevision(id)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrVersionNotFound, err)
+	}
+	versions, err := s.Versions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list OCI revisions: %w", err)
+	}
+	if !slices.ContainsFunc(versions, func(v DataVersion) bool { return v.ID == revision }) {
+		return 0, ErrVersionNotFound
+	}
+
+	raw, err := s.client.PullRevision(ctx, revision)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pull OCI revision %s: %w", revision, err)
+	}
+	restored, err := restoreData(ctx, raw, s)
+	if err != nil {
+		return 0, err
+	}
+	s.logger.Info("Storage rolled back to OCI revision",
+		"reference", s.reference,
+		"revision", revision,
+		"generation", restored.Sync.Generation,
+		"registry_count", len(restored.Registries))
+	return restored.Sync.Generation, nil
+}
+
+// reload replaces the in-memory data with the stored one
+func (s *OCIStorage) reload(ctx context.Context) error {
+	data, artifact, err := s.client.Pull(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to pull from OCI: %w", err)
+	}
+	if err := s.UnmarshalData(data); err != nil {
+		return fmt.Errorf("failed to parse registry data (corrupted JSON or CBOR): %w", err)
+	}
+	s.mu.Lock()
+	s.digest, s.revision = artifact.Digest, artifact.Revision
+	s.mu.Unlock()
+	if s.cacheFile != "" {
+		if cached, err := s.MarshalData(); err == nil {
+			s.writeCache(cached)
+		}
+	}
+	return nil
+}
+
+// persist pushes the complete registry data to OCI registry, unless another
+// writer moved the tag since it was last pulled or pushed: the write is then
+// refused with ErrConflictRemoteModified and the data refreshed.
 // NOTE: This is called while BaseStorage holds the lock,
 // so we use marshalDataLocked() to avoid deadlock.
-func (s *OCIStorage) persist() error {
-	ctx := context.Background()
+func (s *OCIStorage) persist(ctx context.Context) error {
+	if s.deferPersist() {
+		return nil
+	}
 
 	data, err := s.marshalDataLocked()
 	if err != nil {
 		return fmt.Errorf("failed to marshal registry data: %w", err)
 	}
 
-	if err := s.client.Push(ctx, data); err != nil {
+	artifact, err := s.client.Push(ctx, data, s.digest)
+	if err != nil {
+		if errors.Is(err, ErrConflictRemoteModified) {
+			s.logger.Warn("OCI artifact was modified by another writer, refreshing",
+				"reference", s.reference,
+				"error", err)
+			s.conflicts.trigger(s.logger, s.Refresh)
+		}
 		return err // Already categorized by OCIClient
 	}
+	s.digest, s.revision = artifact.Digest, artifact.Revision
+	s.writeCache(data)
 
 	return nil
 }
@@ -147,6 +286,11 @@ func (s *OCIStorage) UpdatePackage(ctx context.Context, registryName string, p *
 	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
 }
 
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *OCIStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
 // DeletePackage deletes a package and all its versions (atomic)
 func (s *OCIStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
 	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
@@ -157,6 +301,56 @@ func (s *OCIStorage) ListPackages(ctx context.Context, registryName string) ([]*
 	return s.BaseStorage.ListPackages(ctx, registryName)
 }
 
+// FindPackages returns the packages whose custom values match a query
+func (s *OCIStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *OCIStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *OCIStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *OCIStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *OCIStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *OCIStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *OCIStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *OCIStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *OCIStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *OCIStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
 // CreateVersion creates a new version for a package
 func (s *OCIStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
 	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
@@ -177,12 +371,32 @@ func (s *OCIStorage) ListVersions(ctx context.Context, registryName, packageName
 	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
 }
 
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *OCIStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *OCIStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
 // GetRegistryIndex generates the registry index (Command Launcher format)
 func (s *OCIStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
 	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
 }
 
-// Close closes the storage (no-op for OCI storage)
+// Changes returns the records changed since a generation (differential sync)
+func (s *OCIStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *OCIStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close flushes the pending writes of debounced mode
 func (s *OCIStorage) Close() error {
-	return nil
+	return s.closeWriteBehind()
 }
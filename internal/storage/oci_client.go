@@ -6,16 +6,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/memory"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
-	"oras.land/oras-go/v2/registry/remote/retry"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
 )
 
 // OCI timeout constants per FR-016
@@ -29,13 +33,39 @@ const (
 	OCIConfigMediaType = "application/vnd.oci.image.config.v1+json"
 	OCILayerMediaType  = "application/json"
 	OCIManifestTitle   = "registry.json"
+
+	OCILayerMediaTypeCBOR = "application/cbor"
+
+	// Compressed layers keep the data media type with a compression suffix
+	OCILayerMediaTypeGzip = "application/json+gzip"
+	OCILayerMediaTypeZstd = "application/json+zstd"
+
+	// OCIAnnotationCompression records the layer compression (none|gzip|zstd)
+	OCIAnnotationCompression = "com.cola-registry.compression"
+
+	// OCIAnnotationEncryption records the layer encryption (aes-256-gcm)
+	// when the storage encryption key is set
+	OCIAnnotationEncryption = "com.cola-registry.encryption"
 )
 
 // OCIClient wraps oras-go for OCI registry operations
 type OCIClient struct {
-	repository *remote.Repository
-	reference  string // Full reference "registry/repo:latest"
-	logger     *slog.Logger
+	repository  *remote.Repository
+	reference   string      // Full reference "registry/repo:latest"
+	codec       Codec         // Selects the layer media type
+	compression Compression   // Applied to pushed layers; pulls detect it
+	envelope    *Envelope     // Encrypts pushed layers when set; pulls detect it
+	pullTimeout time.Duration // Bounds Pull (OCIPullTimeout unless set longer for startup)
+	revisions   int           // Revision tags kept besides the reference tag; 0 disables them
+	retrier     *retrier      // Retries failed operations (see RetryPolicy)
+	creds       *Credentials  // Tokens, with fallback to the secondary one
+	logger      *slog.Logger
+}
+
+// OCIArtifact identifies a pushed or pulled registry data artifact
+type OCIArtifact struct {
+	Digest   string // Of the manifest
+	Revision string // Revision tag of the manifest, e.g. rev-000123; empty when not tagged
 }
 
 // NewOCIClient creates a new OCI client for the given reference and token.
@@ -53,123 +83,231 @@ func NewOCIClient(reference string, token string, logger *slog.Logger) (*OCIClie
 		return nil, CategorizeOCIError(OCIOpConnect, fmt.Errorf("invalid OCI reference %q: %w", reference, err))
 	}
 
+	// Tag every registry request with the request ID and operation behind
+	// it (see tracingTransport). Failed operations are retried as a whole
+	// by the retrier, like S3 ones, rather than request by request.
+	oc := &OCIClient{
+		repository:  repo,
+		reference:   reference,
+		pullTimeout: OCIPullTimeout,
+		retrier:     newRetrier(DefaultRetryPolicy(), logger),
+		logger:      logger,
+	}
+	oc.SetCredentials(NewCredentials(token, "", logger))
+	client := &auth.Client{
+		Client: &http.Client{Transport: newTracingTransport(backendTransport(), logger)},
+		Header: http.Header{"User-Agent": {"oras-go"}},
+		Cache:  &credentialCache{creds: oc.creds},
+	}
+
 	// Configure authentication
 	// Use token as password with empty username - this works for:
 	// - ghcr.io: GitHub PAT as password (username can be anything non-empty)
 	// - docker.io: access token as password
 	// - ACR/ECR: tokens work as password with special usernames
-	if token != "" {
-		repo.Client = &auth.Client{
-			Client: retry.DefaultClient,
-			Credential: func(ctx context.Context, reg string) (auth.Credential, error) {
-				return auth.Credential{
-					Username: "token",
-					Password: token,
-				}, nil
-			},
+	client.Credential = func(ctx context.Context, reg string) (auth.Credential, error) {
+		token, _ := oc.creds.current()
+		if token == "" {
+			return auth.EmptyCredential, nil
 		}
+		return auth.Credential{
+			Username: "token",
+			Password: token,
+		}, nil
 	}
+	repo.Client = client
 
 	logger.Info("OCI client created",
 		"reference", reference,
 		"has_token", token != "",
 		"duration_ms", time.Since(start).Milliseconds())
 
-	return &OCIClient{
-		repository: repo,
-		reference:  reference,
-		logger:     logger,
-	}, nil
+	return oc, nil
+}
+
+// SetCredentials makes the client authenticate with creds, falling back
+// to their secondary token. It must be called before any operation.
+func (c *OCIClient) SetCredentials(creds *Credentials) {
+	c.creds = creds
+	if client, ok := c.repository.Client.(*auth.Client); ok {
+		client.Cache = &credentialCache{creds: creds}
+	}
+	creds.setCheck(func(ctx context.Context) error {
+		_, err := c.exists(ctx)
+		return err
+	})
+}
+
+// SetRetryPolicy changes how failed operations are retried
+func (c *OCIClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retrier = newRetrier(policy, c.logger)
 }
 
 // Pull retrieves the registry data from the OCI repository.
-// Uses 30s timeout per FR-016. Returns the JSON data or an error.
-func (c *OCIClient) Pull(ctx context.Context) ([]byte, error) {
+// Uses a 30s timeout per FR-016 unless the client was given a longer load
+// timeout, per attempt. The data layer is streamed and verified against its
+// digest, with progress logged for large layers. Returns the JSON data or an
+// error.
+func (c *OCIClient) Pull(ctx context.Context) ([]byte, OCIArtifact, error) {
+	return c.pullTag(ctx, c.repository.Reference.Reference)
+}
+
+// pullTag pulls the artifact tag points to, with the retries of Pull
+func (c *OCIClient) pullTag(ctx context.Context, tag string) ([]byte, OCIArtifact, error) {
+	var data []byte
+	var artifact OCIArtifact
+	err := c.retrier.do(ctx, OCIOpPull, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			data, artifact, err = c.pull(ctx, tag)
+			return err
+		})
+	})
+	return data, artifact, err
+}
+
+func (c *OCIClient) pull(ctx context.Context, tag string) ([]byte, OCIArtifact, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
 	start := time.Now()
-	c.logger.Debug("Starting OCI pull", "reference", c.reference)
+	logger.Debug("Starting OCI pull", "reference", c.reference, "tag", tag)
 
 	// Apply timeout
-	ctx, cancel := context.WithTimeout(ctx, OCIPullTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.pullTimeout)
 	defer cancel()
 
-	// Create in-memory store for pulled content
-	store := memory.New()
-
-	// Pull the artifact
-	desc, err := oras.Copy(ctx, c.repository, c.repository.Reference.Reference, store, "", oras.DefaultCopyOptions)
+	// Fetch the manifest
+	manifestDesc, manifestReader, err := c.repository.FetchReference(ctx, tag)
 	if err != nil {
-		c.logger.Error("OCI pull failed",
+		logger.Error("OCI pull failed",
 			"reference", c.reference,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return nil, CategorizeOCIError(OCIOpPull, err)
+		return nil, OCIArtifact{}, CategorizeOCIError(OCIOpPull, err)
 	}
-
-	// Fetch the manifest
-	manifestReader, err := store.Fetch(ctx, desc)
+	manifestJSON, err := content.ReadAll(manifestReader, manifestDesc)
+	manifestReader.Close()
 	if err != nil {
-		c.logger.Error("Failed to fetch manifest",
+		logger.Error("Failed to fetch manifest",
 			"reference", c.reference,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return nil, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to fetch manifest: %w", err))
-	}
-	defer manifestReader.Close()
-
-	var manifestBuf bytes.Buffer
-	if _, err := manifestBuf.ReadFrom(manifestReader); err != nil {
-		return nil, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to read manifest: %w", err))
+		return nil, OCIArtifact{}, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to fetch manifest: %w", err))
 	}
 
 	// Parse manifest to find the data layer
 	var manifest ocispec.Manifest
-	if err := json.Unmarshal(manifestBuf.Bytes(), &manifest); err != nil {
-		c.logger.Error("Failed to parse manifest",
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		logger.Error("Failed to parse manifest",
 			"reference", c.reference,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return nil, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to parse manifest: %w", err))
+		return nil, OCIArtifact{}, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to parse manifest: %w", err))
 	}
 
 	// Find the registry.json layer
 	if len(manifest.Layers) == 0 {
-		return nil, CategorizeOCIError(OCIOpPull, fmt.Errorf("artifact has no layers"))
+		return nil, OCIArtifact{}, CategorizeOCIError(OCIOpPull, fmt.Errorf("artifact has no layers"))
 	}
 
 	// Get the first layer (registry.json data)
 	layerDesc := manifest.Layers[0]
-	layerReader, err := store.Fetch(ctx, layerDesc)
+	logger.Info("Downloading OCI data layer",
+		"reference", c.reference,
+		"digest", layerDesc.Digest,
+		"media_type", layerDesc.MediaType,
+		"total_bytes", layerDesc.Size)
+
+	layerReader, err := c.repository.Blobs().Fetch(ctx, layerDesc)
 	if err != nil {
-		c.logger.Error("Failed to fetch layer",
+		logger.Error("Failed to fetch layer",
 			"reference", c.reference,
 			"digest", layerDesc.Digest,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return nil, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to fetch data layer: %w", err))
+		return nil, OCIArtifact{}, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to fetch data layer: %w", err))
 	}
 	defer layerReader.Close()
 
-	var dataBuf bytes.Buffer
-	if _, err := dataBuf.ReadFrom(layerReader); err != nil {
-		return nil, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to read data layer: %w", err))
+	progress := newProgressReader(layerReader, layerDesc.Size, logger, "reference", c.reference)
+	stored, err := content.ReadAll(progress, layerDesc)
+	if err != nil {
+		return nil, OCIArtifact{}, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to read data layer: %w", err))
 	}
 
-	data := dataBuf.Bytes()
-	c.logger.Info("OCI pull completed",
+	opened, err := c.envelope.Open(stored)
+	if err != nil {
+		return nil, OCIArtifact{}, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to decrypt data layer: %w", err))
+	}
+	data, compression, err := decompressData(opened)
+	if err != nil {
+		return nil, OCIArtifact{}, CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to decompress data layer: %w", err))
+	}
+	logger.Info("OCI pull completed",
 		"reference", c.reference,
 		"size_bytes", len(data),
+		"stored_bytes", layerDesc.Size,
+		"compression", compression,
 		"duration_ms", time.Since(start).Milliseconds())
 
-	return data, nil
+	return data, OCIArtifact{Digest: manifestDesc.Digest.String(), Revision: manifest.Annotations[OCIAnnotationRevision]}, nil
+}
+
+// Push uploads the registry data to the OCI repository, compressing the
+// layer if the client was configured to. Uses 60s timeout per attempt.
+// Always uses the "latest" tag, and also tags the manifest with the next
+// revision when the client keeps revisions (see pushRevision). The push is
+// refused with ErrConflictRemoteModified unless the tag still points to the
+// manifest digest ifMatch, or does not exist when ifMatch is empty. Returns
+// the pushed artifact.
+//
+// The check is best-effort: the distribution API has no conditional
+// manifest push, so a writer pushing between the check and the push is
+// overwritten. Unlike S3Client.Upload, which makes the PUT itself
+// conditional, this only narrows the window.
+func (c *OCIClient) Push(ctx context.Context, data []byte, ifMatch string) (OCIArtifact, error) {
+	// Checked once rather than per attempt: a retried attempt would find
+	// the manifest of an earlier one that reached the registry despite failing
+	current, err := c.Resolve(ctx)
+	if err != nil {
+		return OCIArtifact{}, err
+	}
+	if err := checkRemoteVersion("OCI artifact "+c.reference, ifMatch, current); err != nil {
+		return OCIArtifact{}, err
+	}
+	return c.pushRevision(ctx, data)
 }
 
-// Push uploads the registry data to the OCI repository.
-// Uses 60s timeout. Always uses the "latest" tag.
-func (c *OCIClient) Push(ctx context.Context, data []byte) error {
+// pushManifest pushes the data as a manifest annotated with revision,
+// retrying per the retry policy, and returns the manifest digest
+func (c *OCIClient) pushManifest(ctx context.Context, data []byte, revision string) (string, error) {
+	var manifestDigest string
+	err := c.retrier.do(ctx, OCIOpPush, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			manifestDigest, err = c.push(ctx, data, revision)
+			return err
+		})
+	})
+	return manifestDigest, err
+}
+
+func (c *OCIClient) push(ctx context.Context, data []byte, revision string) (string, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
 	start := time.Now()
-	c.logger.Info("Starting OCI push",
+	size := len(data)
+
+	data, err := compressData(c.compression, data)
+	if err != nil {
+		return "", CategorizeOCIError(OCIOpPush, err)
+	}
+	if data, err = c.envelope.Seal(data); err != nil {
+		return "", CategorizeOCIError(OCIOpPush, err)
+	}
+	logger.Info("Starting OCI push",
 		"reference", c.reference,
-		"size_bytes", len(data))
+		"size_bytes", size,
+		"stored_bytes", len(data),
+		"compression", c.compression)
 
 	// Apply timeout
 	ctx, cancel := context.WithTimeout(ctx, OCIPushTimeout)
@@ -186,20 +324,26 @@ func (c *OCIClient) Push(ctx context.Context, data []byte) error {
 		Size:      int64(len(configData)),
 	}
 	if err := store.Push(ctx, configDesc, bytes.NewReader(configData)); err != nil {
-		return CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to push config: %w", err))
+		return "", CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to push config: %w", err))
 	}
 
 	// Create the data layer with annotations
 	layerDesc := ocispec.Descriptor{
-		MediaType: OCILayerMediaType,
+		MediaType: ociLayerMediaType(c.codec, c.compression),
 		Digest:    digest.FromBytes(data),
 		Size:      int64(len(data)),
 		Annotations: map[string]string{
 			ocispec.AnnotationTitle: OCIManifestTitle,
 		},
 	}
+	if c.compression.Enabled() {
+		layerDesc.Annotations[OCIAnnotationCompression] = string(c.compression)
+	}
+	if c.envelope != nil {
+		layerDesc.Annotations[OCIAnnotationEncryption] = EnvelopeAlgorithm
+	}
 	if err := store.Push(ctx, layerDesc, bytes.NewReader(data)); err != nil {
-		return CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to push layer: %w", err))
+		return "", CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to push layer: %w", err))
 	}
 
 	// Create the manifest
@@ -212,11 +356,14 @@ func (c *OCIClient) Push(ctx context.Context, data []byte) error {
 			"com.cola-registry.version": "1.0.0",
 		},
 	}
+	if revision != "" {
+		manifest.Annotations[OCIAnnotationRevision] = revision
+	}
 	manifest.SchemaVersion = 2
 
 	manifestJSON, err := json.Marshal(manifest)
 	if err != nil {
-		return CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to marshal manifest: %w", err))
+		return "", CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to marshal manifest: %w", err))
 	}
 
 	manifestDesc := ocispec.Descriptor{
@@ -225,68 +372,157 @@ func (c *OCIClient) Push(ctx context.Context, data []byte) error {
 		Size:      int64(len(manifestJSON)),
 	}
 	if err := store.Push(ctx, manifestDesc, bytes.NewReader(manifestJSON)); err != nil {
-		return CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to push manifest: %w", err))
+		return "", CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to push manifest: %w", err))
 	}
 
 	// Tag the manifest
 	if err := store.Tag(ctx, manifestDesc, c.repository.Reference.Reference); err != nil {
-		return CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to tag manifest: %w", err))
+		return "", CategorizeOCIError(OCIOpPush, fmt.Errorf("failed to tag manifest: %w", err))
 	}
 
 	// Copy to remote repository
 	_, err = oras.Copy(ctx, store, c.repository.Reference.Reference, c.repository, "", oras.DefaultCopyOptions)
 	if err != nil {
-		c.logger.Error("OCI push failed",
+		logger.Error("OCI push failed",
 			"reference", c.reference,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return CategorizeOCIError(OCIOpPush, err)
+		return "", CategorizeOCIError(OCIOpPush, err)
 	}
 
-	c.logger.Info("OCI push completed",
+	logger.Info("OCI push completed",
 		"reference", c.reference,
-		"size_bytes", len(data),
+		"size_bytes", size,
+		"stored_bytes", len(data),
 		"duration_ms", time.Since(start).Milliseconds())
 
-	return nil
+	return manifestDesc.Digest.String(), nil
+}
+
+// ociLayerMediaType returns the data layer media type for a codec and
+// compression, e.g. application/json, application/cbor+zstd
+func ociLayerMediaType(codec Codec, c Compression) string {
+	mediaType := OCILayerMediaType
+	if codec == CodecCBOR {
+		mediaType = OCILayerMediaTypeCBOR
+	}
+	if c.Enabled() {
+		mediaType += "+" + string(c)
+	}
+	return mediaType
 }
 
 // Exists checks if the artifact exists in the OCI repository.
 func (c *OCIClient) Exists(ctx context.Context) (bool, error) {
-	start := time.Now()
-	c.logger.Debug("Checking OCI artifact existence", "reference", c.reference)
+	var exists bool
+	err := c.retrier.do(ctx, OCIOpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			exists, err = c.exists(ctx)
+			return err
+		})
+	})
+	return exists, err
+}
 
-	// Apply pull timeout for existence check
-	ctx, cancel := context.WithTimeout(ctx, OCIPullTimeout)
-	defer cancel()
+func (c *OCIClient) exists(ctx context.Context) (bool, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Debug("Checking OCI artifact existence", "reference", c.reference)
 
-	_, err := c.repository.Resolve(ctx, c.repository.Reference.Reference)
+	manifestDigest, err := c.resolve(ctx)
 	if err != nil {
-		// Check if it's a "not found" error
-		errStr := err.Error()
-		// oras-go returns various "not found" formats:
-		// - "ghcr.io/user/repo:tag: not found"
-		// - HTTP 404 status
-		// - "NAME_UNKNOWN" or "MANIFEST_UNKNOWN" errors
-		if containsHTTPStatus(errStr, 404) || containsHTTPStatus(errStr, 400) ||
-			strings.HasSuffix(errStr, ": not found") ||
-			strings.Contains(errStr, "NOT_FOUND") ||
-			strings.Contains(errStr, "NAME_UNKNOWN") ||
-			strings.Contains(errStr, "MANIFEST_UNKNOWN") {
-			c.logger.Info("OCI artifact does not exist",
-				"reference", c.reference,
-				"duration_ms", time.Since(start).Milliseconds())
-			return false, nil
-		}
-		c.logger.Error("OCI existence check failed",
+		logger.Error("OCI existence check failed",
 			"reference", c.reference,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return false, CategorizeOCIError(OCIOpConnect, err)
+		return false, err
+	}
+	if manifestDigest == "" {
+		logger.Info("OCI artifact does not exist",
+			"reference", c.reference,
+			"duration_ms", time.Since(start).Milliseconds())
+		return false, nil
 	}
 
-	c.logger.Info("OCI artifact exists",
+	logger.Info("OCI artifact exists",
 		"reference", c.reference,
 		"duration_ms", time.Since(start).Milliseconds())
 	return true, nil
 }
+
+// Resolve returns the digest of the manifest the tag points to, empty when
+// the artifact does not exist
+func (c *OCIClient) Resolve(ctx context.Context) (string, error) {
+	var manifestDigest string
+	err := c.retrier.do(ctx, OCIOpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			manifestDigest, err = c.resolve(ctx)
+			return err
+		})
+	})
+	return manifestDigest, err
+}
+
+func (c *OCIClient) resolve(ctx context.Context) (string, error) {
+	// Apply pull timeout for existence check
+	ctx, cancel := context.WithTimeout(ctx, OCIPullTimeout)
+	defer cancel()
+
+	desc, err := c.repository.Resolve(ctx, c.repository.Reference.Reference)
+	if err != nil {
+		if isOCINotFound(err) {
+			return "", nil
+		}
+		return "", CategorizeOCIError(OCIOpConnect, err)
+	}
+	return desc.Digest.String(), nil
+}
+
+// isOCINotFound reports whether err means the tag or repository does not exist
+func isOCINotFound(err error) bool {
+	errStr := err.Error()
+	// oras-go returns various "not found" formats:
+	// - "ghcr.io/user/repo:tag: not found"
+	// - HTTP 404 status
+	// - "NAME_UNKNOWN" or "MANIFEST_UNKNOWN" errors
+	return containsHTTPStatus(errStr, 404) || containsHTTPStatus(errStr, 400) ||
+		strings.HasSuffix(errStr, ": not found") ||
+		strings.Contains(errStr, "NOT_FOUND") ||
+		strings.Contains(errStr, "NAME_UNKNOWN") ||
+		strings.Contains(errStr, "MANIFEST_UNKNOWN")
+}
+
+// credentialCache caches the registry's authentication like auth.NewCache,
+// starting over whenever the token in use changes
+type credentialCache struct {
+	creds *Credentials
+
+	mu         sync.Mutex
+	generation uint64
+	cache      auth.Cache
+}
+
+func (c *credentialCache) current() auth.Cache {
+	_, generation := c.creds.current()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cache == nil || c.generation != generation {
+		c.cache = auth.NewCache()
+		c.generation = generation
+	}
+	return c.cache
+}
+
+func (c *credentialCache) GetScheme(ctx context.Context, registry string) (auth.Scheme, error) {
+	return c.current().GetScheme(ctx, registry)
+}
+
+func (c *credentialCache) GetToken(ctx context.Context, registry string, scheme auth.Scheme, key string) (string, error) {
+	return c.current().GetToken(ctx, registry, scheme, key)
+}
+
+func (c *credentialCache) Set(ctx context.Context, registry string, scheme auth.Scheme, key string, fetch func(context.Context) (string, error)) (string, error) {
+	return c.current().Set(ctx, registry, scheme, key, fetch)
+}
@@ -102,9 +102,11 @@ func TestOCIClient_RealRegistry(t *testing.T) {
 
 	ctx := context.Background()
 
+	var pushed OCIArtifact
 	t.Run("Push", func(t *testing.T) {
 		testData := []byte(`{"registries":{}}`)
-		err := client.Push(ctx, testData)
+		_, current, _ := client.Pull(ctx) // Empty when the artifact does not exist yet
+		pushed, err = client.Push(ctx, testData, current.Digest)
 		require.NoError(t, err)
 	})
 
@@ -115,9 +117,15 @@ func TestOCIClient_RealRegistry(t *testing.T) {
 	})
 
 	t.Run("Pull", func(t *testing.T) {
-		data, err := client.Pull(ctx)
+		data, artifact, err := client.Pull(ctx)
 		require.NoError(t, err)
 		assert.Contains(t, string(data), "registries")
+		assert.Equal(t, pushed.Digest, artifact.Digest)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		_, err := client.Push(ctx, []byte(`{"registries":{}}`), "")
+		assert.ErrorIs(t, err, ErrConflictRemoteModified)
 	})
 }
 
@@ -158,7 +166,7 @@ func TestOCIClient_Push_ContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	err = client.Push(ctx, []byte(`{}`))
+	_, err = client.Push(ctx, []byte(`{}`), "")
 	assert.Error(t, err)
 }
 
@@ -173,7 +181,7 @@ func TestOCIClient_Pull_ContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	_, err = client.Pull(ctx)
+	_, _, err = client.Pull(ctx)
 	assert.Error(t, err)
 }
 
@@ -24,9 +24,10 @@ const (
 
 // OCIError wraps OCI-specific failures with categorization
 type OCIError struct {
-	Category string // "authentication", "network", or "storage"
-	Op       string // "push", "pull", or "connect"
-	Err      error  // Underlying error
+	Category  string // "authentication", "network", or "storage"
+	Op        string // "push", "pull", or "connect"
+	Err       error  // Underlying error
+	Retryable bool   // The operation may succeed if retried (see RetryPolicy)
 }
 
 // Error implements the error interface
@@ -56,9 +57,10 @@ func NewOCIAuthError(op string, err error) *OCIError {
 // NewOCINetworkError creates a network-related OCI error
 func NewOCINetworkError(op string, err error) *OCIError {
 	return &OCIError{
-		Category: OCICategoryNetwork,
-		Op:       op,
-		Err:      err,
+		Category:  OCICategoryNetwork,
+		Op:        op,
+		Err:       err,
+		Retryable: true,
 	}
 }
 
@@ -120,8 +122,16 @@ func CategorizeOCIError(op string, err error) *OCIError {
 	if containsHTTPStatus(errStr, 404) || strings.Contains(errStr, "NOT_FOUND") {
 		return NewOCIStorageError(op, fmt.Errorf("repository not found or not initialized"))
 	}
-	if containsHTTPStatus(errStr, 500) || containsHTTPStatus(errStr, 503) {
-		return NewOCIStorageError(op, fmt.Errorf("OCI registry unavailable: %v", err))
+	if containsHTTPStatus(errStr, 500) || containsHTTPStatus(errStr, 503) ||
+		containsHTTPStatus(errStr, 502) || containsHTTPStatus(errStr, 504) {
+		ociErr := NewOCIStorageError(op, fmt.Errorf("OCI registry unavailable: %v", err))
+		ociErr.Retryable = true
+		return ociErr
+	}
+	if containsHTTPStatus(errStr, 429) || strings.Contains(errStr, "TOOMANYREQUESTS") {
+		ociErr := NewOCIStorageError(op, fmt.Errorf("OCI registry rate limit exceeded: %v", err))
+		ociErr.Retryable = true
+		return ociErr
 	}
 
 	// Default to storage error
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// DefaultOCIRevisions is how many revision tags OCI storage keeps unless
+// configured otherwise
+const DefaultOCIRevisions = 10
+
+const (
+	// OCIRevisionTagPrefix starts the tags of revisions, e.g. rev-000123
+	OCIRevisionTagPrefix = "rev-"
+
+	// OCIAnnotationRevision records the revision tag of a manifest, so a
+	// pull of the reference tag tells which revision it got
+	OCIAnnotationRevision = "com.cola-registry.revision"
+)
+
+// ParseOCIRevision returns the tag of a revision given by its tag
+// (rev-000123) or its number (123)
+func ParseOCIRevision(revision string) (string, error) {
+	n, ok := parseOCIRevisionTag(revision)
+	if !ok {
+		var err error
+		if n, err = strconv.Atoi(revision); err != nil || n <= 0 {
+			return "", fmt.Errorf("invalid OCI revision %q: expected a number or a %s tag", revision, OCIRevisionTagPrefix)
+		}
+	}
+	return ociRevisionTag(n), nil
+}
+
+// ociRevisionTag returns the tag of revision n, zero-padded so tags sort
+// in order
+func ociRevisionTag(n int) string {
+	return fmt.Sprintf("%s%06d", OCIRevisionTagPrefix, n)
+}
+
+// parseOCIRevisionTag returns the number of a revision tag
+func parseOCIRevisionTag(tag string) (int, bool) {
+	digits, ok := strings.CutPrefix(tag, OCIRevisionTagPrefix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	return n, err == nil && n > 0
+}
+
+// revisionNumbers lists the numbers of the revisions tagged in the
+// repository, in ascending order
+func (c *OCIClient) revisionNumbers(ctx context.Context) ([]int, error) {
+	var numbers []int
+	err := c.retrier.do(ctx, OCIOpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, OCIPullTimeout)
+			defer cancel()
+
+			numbers = nil
+			err := c.repository.Tags(ctx, "", func(tags []string) error {
+				for _, tag := range tags {
+					if n, ok := parseOCIRevisionTag(tag); ok {
+						numbers = append(numbers, n)
+					}
+				}
+				return nil
+			})
+			// The repository does not exist before the first push
+			if err != nil && !isOCINotFound(err) {
+				return CategorizeOCIError(OCIOpConnect, err)
+			}
+			return nil
+		})
+	})
+	slices.Sort(numbers)
+	return numbers, err
+}
+
+// ociRevisionAttempts bounds the revision numbers a push tries when
+// other writers tag the ones it picked first
+const ociRevisionAttempts = 3
+
+// errRevisionTaken is returned by tagRevision when the revision tag points
+// to the manifest of another writer
+var errRevisionTaken = errors.New("OCI revision tag already exists")
+
+// pushRevision pushes the data, tagged with the next revision when the
+// client keeps revisions, then prunes the revisions beyond the ones it
+// keeps. The tags are listed once, which numbers the revision and tells
+// what to prune. When another writer tagged the revision in the meantime,
+// the manifest is pushed again with the next number, as it records its
+// revision. Tagging and pruning failures are logged rather than returned,
+// as the data is pushed by then.
+func (c *OCIClient) pushRevision(ctx context.Context, data []byte) (OCIArtifact, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	var numbers []int
+	revision := ""
+	if c.revisions > 0 {
+		var err error
+		if numbers, err = c.revisionNumbers(ctx); err != nil {
+			logger.Warn("Failed to list OCI revision tags, pushing without revision",
+				"reference", c.reference,
+				"error", err)
+		} else {
+			revision = nextRevision(numbers)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		manifestDigest, err := c.pushManifest(ctx, data, revision)
+		if err != nil {
+			return OCIArtifact{}, err
+		}
+		if revision == "" {
+			return OCIArtifact{Digest: manifestDigest}, nil
+		}
+
+		err = c.tagRevision(ctx, manifestDigest, revision)
+		if errors.Is(err, errRevisionTaken) && attempt < ociRevisionAttempts {
+			n, _ := parseOCIRevisionTag(revision)
+			numbers = append(numbers, n)
+			logger.Info("OCI revision tagged by another writer, pushing the next one",
+				"reference", c.reference,
+				"revision", revision)
+			revision = nextRevision(numbers)
+			continue
+		}
+		if err != nil {
+			logger.Warn("Failed to tag OCI revision, it cannot be rolled back to",
+				"reference", c.reference,
+				"revision", revision,
+				"error", err)
+			return OCIArtifact{Digest: manifestDigest}, nil
+		}
+		logger.Info("OCI revision tagged",
+			"reference", c.reference,
+			"revision", revision,
+			"digest", manifestDigest)
+
+		n, _ := parseOCIRevisionTag(revision)
+		c.pruneRevisions(ctx, manifestDigest, append(numbers, n))
+		return OCIArtifact{Digest: manifestDigest, Revision: revision}, nil
+	}
+}
+
+// nextRevision returns the tag of the revision following numbers, in
+// ascending order: one more than the highest, so numbers keep increasing
+// across pruning
+func nextRevision(numbers []int) string {
+	if len(numbers) == 0 {
+		return ociRevisionTag(1)
+	}
+	return ociRevisionTag(numbers[len(numbers)-1] + 1)
+}
+
+// tagRevision tags the pushed manifest with its revision. It returns
+// errRevisionTaken rather than moving the tag when it already points to
+// another manifest, which narrows but cannot close the window for two
+// writers tagging the same revision.
+func (c *OCIClient) tagRevision(ctx context.Context, manifestDigest, revision string) error {
+	return c.retrier.do(ctx, OCIOpPush, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, OCIPushTimeout)
+			defer cancel()
+			desc, err := c.repository.Resolve(ctx, revision)
+			switch {
+			case err == nil && desc.Digest.String() != manifestDigest:
+				return errRevisionTaken
+			case err != nil && !isOCINotFound(err):
+				return CategorizeOCIError(OCIOpPush, err)
+			}
+			if _, err := oras.Tag(ctx, c.repository, manifestDigest, revision); err != nil {
+				return CategorizeOCIError(OCIOpPush, err)
+			}
+			return nil
+		})
+	})
+}
+
+// pruneRevisions deletes the manifests of the revisions older than the
+// last c.revisions of numbers, in ascending order, sparing the manifest
+// just pushed. Registries that do not allow deleting manifests keep them,
+// which is logged.
+func (c *OCIClient) pruneRevisions(ctx context.Context, pushed string, numbers []int) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	if len(numbers) <= c.revisions {
+		return
+	}
+
+	for _, n := range numbers[:len(numbers)-c.revisions] {
+		revision := ociRevisionTag(n)
+		err := c.creds.do(ctx, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, OCIPushTimeout)
+			defer cancel()
+			desc, err := c.repository.Resolve(ctx, revision)
+			if err != nil {
+				return CategorizeOCIError(OCIOpPush, err)
+			}
+			if desc.Digest.String() == pushed {
+				return nil
+			}
+			// Deleting the manifest removes its tag as well
+			if err := c.repository.Delete(ctx, desc); err != nil {
+				return CategorizeOCIError(OCIOpPush, err)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Warn("Failed to delete old OCI revision, keeping it",
+				"reference", c.reference,
+				"revision", revision,
+				"error", err)
+			return
+		}
+		logger.Debug("Old OCI revision deleted",
+			"reference", c.reference,
+			"revision", revision)
+	}
+}
+
+// ListRevisions lists the revisions tagged in the repository, most recent
+// first. The current one is the revision the reference tag points to.
+func (c *OCIClient) ListRevisions(ctx context.Context) ([]DataVersion, error) {
+	numbers, err := c.revisionNumbers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := c.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]DataVersion, 0, len(numbers))
+	for i := len(numbers) - 1; i >= 0; i-- {
+		version, manifestDigest, err := c.describeRevision(ctx, ociRevisionTag(numbers[i]))
+		if err != nil {
+			return nil, err
+		}
+		version.Current = manifestDigest == current
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// describeRevision returns the version a revision tag stands for, from its
+// manifest, and the manifest digest
+func (c *OCIClient) describeRevision(ctx context.Context, revision string) (DataVersion, string, error) {
+	version := DataVersion{ID: revision}
+	var manifestDigest string
+	err := c.retrier.do(ctx, OCIOpPull, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, OCIPullTimeout)
+			defer cancel()
+
+			desc, reader, err := c.repository.FetchReference(ctx, revision)
+			if err != nil {
+				return CategorizeOCIError(OCIOpPull, err)
+			}
+			defer reader.Close()
+			manifestJSON, err := content.ReadAll(reader, desc)
+			if err != nil {
+				return CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to fetch manifest: %w", err))
+			}
+			var manifest ocispec.Manifest
+			if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+				return CategorizeOCIError(OCIOpPull, fmt.Errorf("failed to parse manifest: %w", err))
+			}
+
+			version.Size = 0
+			for _, layer := range manifest.Layers {
+				version.Size += layer.Size
+			}
+			version.ModifiedAt, _ = time.Parse(time.RFC3339, manifest.Annotations[ocispec.AnnotationCreated])
+			manifestDigest = desc.Digest.String()
+			return nil
+		})
+	})
+	return version, manifestDigest, err
+}
+
+// PullRevision retrieves the registry data of a revision tag, like Pull
+func (c *OCIClient) PullRevision(ctx context.Context, revision string) ([]byte, error) {
+	data, _, err := c.pullTag(ctx, revision)
+	return data, err
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func newTestOCIRevisionStorage(t *testing.T, registry *fakeOCI, revisions int) *OCIStorage {
+	client, err := NewOCIClient(registry.Host+"/acme/registry:latest", "", newTestOCILogger())
+	require.NoError(t, err)
+	client.repository.PlainHTTP = true
+	client.revisions = revisions
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	s, err := newOCIStorage(client, Options{}, newTestOCILogger())
+	require.NoError(t, err)
+	return s
+}
+
+func TestParseOCIRevision(t *testing.T) {
+	for _, revision := range []string{"123", "rev-123", "rev-000123"} {
+		tag, err := ParseOCIRevision(revision)
+		require.NoError(t, err, revision)
+		assert.Equal(t, "rev-000123", tag)
+	}
+	for _, revision := range []string{"", "0", "-1", "latest", "rev-", "rev-abc"} {
+		_, err := ParseOCIRevision(revision)
+		assert.Error(t, err, revision)
+	}
+}
+
+func TestOCIStorage_Revisions(t *testing.T) {
+	ctx := context.Background()
+	registry := newFakeOCI(t, "acme/registry")
+	store := newTestOCIRevisionStorage(t, registry, 3)
+
+	current, err := store.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rev-000001", current) // Initial empty data
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)))
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("docs", "", nil, nil)))
+	_, before, err := store.Changes(ctx, 0)
+	require.NoError(t, err)
+
+	// The oldest revision is pruned, numbers keep increasing
+	assert.Equal(t, []string{"latest", "rev-000002", "rev-000003", "rev-000004"}, registry.Tags())
+	versions, err := store.Versions(ctx)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, "rev-000004", versions[0].ID)
+	assert.True(t, versions[0].Current)
+	assert.False(t, versions[1].Current)
+	assert.Positive(t, versions[0].Size)
+	assert.False(t, versions[0].ModifiedAt.IsZero())
+
+	_, err = store.Rollback(ctx, "1")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	_, err = store.Rollback(ctx, "latest")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+
+	// Rolling back pushes the prior data as a new revision
+	generation, err := store.Rollback(ctx, "2")
+	require.NoError(t, err)
+	assert.Equal(t, before+1, generation)
+	_, err = store.GetRegistry(ctx, "tools")
+	assert.NoError(t, err)
+	_, err = store.GetRegistry(ctx, "games")
+	assert.ErrorIs(t, err, ErrNotFound)
+	current, err = store.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rev-000005", current)
+	assert.Equal(t, []string{"latest", "rev-000003", "rev-000004", "rev-000005"}, registry.Tags())
+
+	// Another instance loads the rolled back data and its revision
+	other := newTestOCIRevisionStorage(t, registry, 3)
+	current, err = other.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rev-000005", current)
+	_, err = other.GetRegistry(ctx, "games")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestOCIStorage_RevisionTaken(t *testing.T) {
+	ctx := context.Background()
+	registry := newFakeOCI(t, "acme/registry")
+	store := newTestOCIRevisionStorage(t, registry, 3)
+	other := registry.tags["rev-000001"]
+
+	// Another writer tags rev-000002 between the listing and the tagging
+	registry.TagAfterListing("rev-000002")
+	lists := registry.TagLists()
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	assert.Equal(t, lists+1, registry.TagLists(), "tags listed once per push")
+
+	current, err := store.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rev-000003", current)
+	assert.Equal(t, []string{"latest", "rev-000001", "rev-000002", "rev-000003"}, registry.Tags())
+	assert.Equal(t, other, registry.tags["rev-000002"], "the other writer's tag is kept")
+	assert.Equal(t, registry.tags["latest"], registry.tags["rev-000003"])
+
+	reopened := newTestOCIRevisionStorage(t, registry, 3)
+	current, err = reopened.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rev-000003", current)
+}
+
+func TestOCIStorage_RevisionsDisabled(t *testing.T) {
+	ctx := context.Background()
+	registry := newFakeOCI(t, "acme/registry")
+	store := newTestOCIRevisionStorage(t, registry, 0)
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	assert.Equal(t, []string{"latest"}, registry.Tags())
+	current, err := store.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, current)
+	versions, err := store.Versions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
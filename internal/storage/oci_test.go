@@ -21,7 +21,7 @@ func TestNewOCIStorage_InvalidScheme(t *testing.T) {
 	require.NoError(t, err)
 
 	// Try to create OCI storage with file URI - should fail
-	_, err = NewOCIStorage(uri, "token", logger)
+	_, err = NewOCIStorage(uri, "token", Options{}, logger)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "expected OCI URI")
 }
@@ -44,7 +44,7 @@ func TestNewOCIStorage_ValidURIParsing(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, uri.IsOCIScheme())
 
-	storage, err := NewOCIStorage(uri, ociToken, logger)
+	storage, err := NewOCIStorage(uri, ociToken, Options{}, logger)
 	require.NoError(t, err)
 	assert.NotNil(t, storage)
 }
@@ -55,7 +55,7 @@ func TestFactory_NewStorage_FileScheme(t *testing.T) {
 	uri, err := ParseStorageURI("file://./test-data/factory-test.json")
 	require.NoError(t, err)
 
-	store, err := NewStorage(uri, "", logger)
+	store, err := NewStorage(uri, "", Options{}, logger)
 	require.NoError(t, err)
 	assert.NotNil(t, store)
 
@@ -72,7 +72,7 @@ func TestFactory_NewStorage_OCIScheme_NoToken(t *testing.T) {
 	require.NoError(t, err)
 
 	// OCI without token should fail with ErrTokenRequired
-	_, err = NewStorage(uri, "", logger)
+	_, err = NewStorage(uri, "", Options{}, logger)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrTokenRequired)
 	assert.Contains(t, err.Error(), "OCI storage requires authentication token")
@@ -88,7 +88,7 @@ func TestFactory_NewStorage_UnsupportedScheme(t *testing.T) {
 		Raw:    "ftp://host/path",
 	}
 
-	_, err := NewStorage(uri, "", logger)
+	_, err := NewStorage(uri, "", Options{}, logger)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unsupported storage scheme")
 }
@@ -110,7 +110,7 @@ func TestFactory_NewStorage_OCIScheme_WithToken(t *testing.T) {
 	uri, err := ParseStorageURI(ociURI)
 	require.NoError(t, err)
 
-	store, err := NewStorage(uri, ociToken, logger)
+	store, err := NewStorage(uri, ociToken, Options{}, logger)
 	require.NoError(t, err)
 	assert.NotNil(t, store)
 }
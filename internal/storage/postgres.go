@@ -0,0 +1,367 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Registered as "pgx"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// postgresSchemaVersion is stored in the schema_version table
+const postgresSchemaVersion = 1
+
+// PostgreSQL connection settings
+const (
+	postgresMaxConns       = 4
+	postgresConnectTimeout = 30 * time.Second // Bounds the initial load unless LoadTimeout is longer
+)
+
+// postgresSchema holds the record rows (see rows.go). The json type keeps
+// records exactly as written, so unchanged rows compare equal after a load.
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS registries (
+	name TEXT PRIMARY KEY,
+	data JSON NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS packages (
+	registry TEXT NOT NULL REFERENCES registries (name) ON DELETE CASCADE,
+	name     TEXT NOT NULL,
+	data     JSON NOT NULL,
+	PRIMARY KEY (registry, name)
+)`,
+	`CREATE TABLE IF NOT EXISTS versions (
+	registry TEXT NOT NULL,
+	package  TEXT NOT NULL,
+	version  TEXT NOT NULL,
+	data     JSON NOT NULL,
+	PRIMARY KEY (registry, package, version),
+	FOREIGN KEY (registry, package) REFERENCES packages (registry, name) ON DELETE CASCADE
+)`,
+	`CREATE TABLE IF NOT EXISTS state (
+	key  TEXT PRIMARY KEY,
+	data JSON NOT NULL
+)`,
+}
+
+// PostgresStorage implements Store interface using a PostgreSQL database.
+// It embeds BaseStorage for in-memory CRUD operations and persists the
+// records that changed in one transaction per write, like SQLiteStorage.
+type PostgresStorage struct {
+	*BaseStorage // Embedded for shared CRUD logic
+	db           *sql.DB
+	database     string
+
+	// written holds the JSON of each row as last committed, so a write only
+	// touches the rows that changed. Guarded by the BaseStorage lock.
+	written map[recordRow]string
+}
+
+// NewPostgresStorage connects to the PostgreSQL database of uri, creating
+// the tables if needed, and loads its data.
+// The token, USER:PASSWORD or PASSWORD, replaces the credentials of the URI.
+func NewPostgresStorage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*PostgresStorage, error) {
+	if !uri.IsPostgresScheme() {
+		return nil, fmt.Errorf("expected PostgreSQL URI, got scheme: %s", uri.Scheme)
+	}
+
+	db, err := sql.Open("pgx", uri.PostgresDSN(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL database: %w", err)
+	}
+	// Writes are serialized by the BaseStorage lock and reads are served
+	// from memory, so few connections are needed
+	db.SetMaxOpenConns(postgresMaxConns)
+
+	s := &PostgresStorage{
+		BaseStorage: NewBaseStorage(logger),
+		db:          db,
+		database:    uri.Path,
+		written:     make(map[recordRow]string),
+	}
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+
+	timeout := postgresConnectTimeout
+	if opts.LoadTimeout > timeout {
+		timeout = opts.LoadTimeout
+	}
+	ctx, cancel := context.WithTimeout(tracing.WithOperation(context.Background(), "load"), timeout)
+	defer cancel()
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.load(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+	return s, nil
+}
+
+// migrate creates the schema, refusing databases written by a newer server
+func (s *PostgresStorage) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range postgresSchema {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create PostgreSQL schema: %w", err)
+		}
+	}
+
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT version FROM schema_version").Scan(&version)
+	switch {
+	case err == sql.ErrNoRows:
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_version (version) VALUES ($1)", postgresSchemaVersion); err != nil {
+			return fmt.Errorf("failed to set PostgreSQL schema version: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to read PostgreSQL schema version: %w", err)
+	case version > postgresSchemaVersion:
+		return fmt.Errorf("PostgreSQL schema version %d is newer than supported version %d", version, postgresSchemaVersion)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create PostgreSQL schema: %w", err)
+	}
+	return nil
+}
+
+// load reads all rows and nests them into a document, decoded like the
+// other backends' so invalid records are quarantined
+func (s *PostgresStorage) load(ctx context.Context) error {
+	rows, err := readRows(ctx, s.db, postgresQueries)
+	if err != nil {
+		return fmt.Errorf("failed to read PostgreSQL database: %w", err)
+	}
+	encoded, err := assembleRows(rows)
+	if err != nil {
+		return fmt.Errorf("failed to assemble PostgreSQL rows: %w", err)
+	}
+	if err := s.UnmarshalData(encoded); err != nil {
+		return fmt.Errorf("failed to decode PostgreSQL rows: %w", err)
+	}
+	s.written = rows
+
+	data := s.GetData()
+	s.logger.Info("PostgreSQL storage loaded",
+		"database", s.database,
+		"registry_count", len(data.Registries))
+	return nil
+}
+
+// persist is the callback passed to BaseStorage methods: it writes the rows
+// that changed since the last commit in a single transaction. Finding them
+// re-encodes every record and compares it with s.written, so each write
+// still costs time proportional to the dataset, only the statements sent
+// are proportional to the change.
+func (s *PostgresStorage) persist(ctx context.Context) error {
+	rows, err := encodeRows(s.getDataLocked())
+	if err != nil {
+		return fmt.Errorf("failed to encode rows: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := writeRows(ctx, tx, postgresQueries, s.written, rows); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.written = rows
+	return nil
+}
+
+var postgresQueries = rowQueries{
+	selects: map[recordTable]string{
+		tableRegistries: "SELECT name, data FROM registries",
+		tablePackages:   "SELECT registry, name, data FROM packages",
+		tableVersions:   "SELECT registry, package, version, data FROM versions",
+		tableState:      "SELECT key, data FROM state",
+	},
+	upserts: map[recordTable]string{
+		tableRegistries: "INSERT INTO registries (name, data) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET data = excluded.data",
+		tablePackages:   "INSERT INTO packages (registry, name, data) VALUES ($1, $2, $3) ON CONFLICT (registry, name) DO UPDATE SET data = excluded.data",
+		tableVersions:   "INSERT INTO versions (registry, package, version, data) VALUES ($1, $2, $3, $4) ON CONFLICT (registry, package, version) DO UPDATE SET data = excluded.data",
+		tableState:      "INSERT INTO state (key, data) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET data = excluded.data",
+	},
+	deletes: map[recordTable]string{
+		tableRegistries: "DELETE FROM registries WHERE name = $1",
+		tablePackages:   "DELETE FROM packages WHERE registry = $1 AND name = $2",
+		tableVersions:   "DELETE FROM versions WHERE registry = $1 AND package = $2 AND version = $3",
+		tableState:      "DELETE FROM state WHERE key = $1",
+	},
+}
+
+// CreateRegistry creates a new registry
+func (s *PostgresStorage) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.CreateRegistry(ctx, r, s.persist)
+}
+
+// GetRegistry retrieves a registry by name
+func (s *PostgresStorage) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	return s.BaseStorage.GetRegistry(ctx, name)
+}
+
+// UpdateRegistry updates registry metadata
+func (s *PostgresStorage) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.UpdateRegistry(ctx, r, s.persist)
+}
+
+// DeleteRegistry deletes a registry and all its packages (atomic)
+func (s *PostgresStorage) DeleteRegistry(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteRegistry(ctx, name, s.persist)
+}
+
+// ListRegistries returns all registries
+func (s *PostgresStorage) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	return s.BaseStorage.ListRegistries(ctx)
+}
+
+// CreatePackage creates a new package in a registry
+func (s *PostgresStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.CreatePackage(ctx, registryName, p, s.persist)
+}
+
+// GetPackage retrieves a package from a registry
+func (s *PostgresStorage) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	return s.BaseStorage.GetPackage(ctx, registryName, packageName)
+}
+
+// UpdatePackage updates package metadata (preserves versions)
+func (s *PostgresStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
+}
+
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *PostgresStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
+// DeletePackage deletes a package and all its versions (atomic)
+func (s *PostgresStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
+}
+
+// ListPackages returns all packages in a registry
+func (s *PostgresStorage) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	return s.BaseStorage.ListPackages(ctx, registryName)
+}
+
+// FindPackages returns the packages whose custom values match a query
+func (s *PostgresStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *PostgresStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *PostgresStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *PostgresStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *PostgresStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *PostgresStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *PostgresStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *PostgresStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *PostgresStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *PostgresStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
+// CreateVersion creates a new version for a package
+func (s *PostgresStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
+}
+
+// GetVersion retrieves a specific version
+func (s *PostgresStorage) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
+	return s.BaseStorage.GetVersion(ctx, registryName, packageName, version)
+}
+
+// DeleteVersion deletes a specific version
+func (s *PostgresStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.DeleteVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// ListVersions returns all versions for a package
+func (s *PostgresStorage) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
+	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
+}
+
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *PostgresStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *PostgresStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// GetRegistryIndex generates the registry index (Command Launcher format)
+func (s *PostgresStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
+	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
+}
+
+// Changes returns the records changed since a generation (differential sync)
+func (s *PostgresStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *PostgresStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close closes the database connections
+func (s *PostgresStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// fakePostgres is an in-memory database/sql driver answering the row
+// queries of postgresQueries, so PostgresStorage is tested without a
+// server. Statements of a transaction are applied on commit; the schema
+// statements of migrate are not supported.
+type fakePostgres struct {
+	mu        sync.Mutex
+	rows      map[recordRow]string
+	statement map[string]fakePostgresStatement // By query
+	upserted  []recordRow                      // Rows committed since takeWrites
+	deleted   []recordRow
+	failExecs bool
+}
+
+// fakePostgresStatement is what a query of postgresQueries does
+type fakePostgresStatement struct {
+	table recordTable
+	kind  string // select, upsert or delete
+}
+
+func newFakePostgres() *fakePostgres {
+	f := &fakePostgres{
+		rows:      make(map[recordRow]string),
+		statement: make(map[string]fakePostgresStatement),
+	}
+	for table, query := range postgresQueries.selects {
+		f.statement[query] = fakePostgresStatement{table, "select"}
+	}
+	for table, query := range postgresQueries.upserts {
+		f.statement[query] = fakePostgresStatement{table, "upsert"}
+	}
+	for table, query := range postgresQueries.deletes {
+		f.statement[query] = fakePostgresStatement{table, "delete"}
+	}
+	return f
+}
+
+// open opens PostgresStorage on the fake database, as NewPostgresStorage
+// does once the schema is created
+func (f *fakePostgres) open(t *testing.T) *PostgresStorage {
+	t.Helper()
+	s := &PostgresStorage{
+		BaseStorage: NewBaseStorage(slog.Default()),
+		db:          sql.OpenDB(f),
+		database:    "fake",
+		written:     make(map[recordRow]string),
+	}
+	t.Cleanup(func() { s.db.Close() })
+	require.NoError(t, s.load(context.Background()))
+	return s
+}
+
+// takeWrites returns the rows upserted and deleted since the last call
+func (f *fakePostgres) takeWrites() (upserted, deleted []recordRow) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	upserted, deleted = f.upserted, f.deleted
+	f.upserted, f.deleted = nil, nil
+	return upserted, deleted
+}
+
+// count returns the rows of a table
+func (f *fakePostgres) count(table recordTable) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for row := range f.rows {
+		if row.table == table {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakePostgres) Connect(context.Context) (driver.Conn, error) {
+	return &fakePostgresConn{db: f}, nil
+}
+func (f *fakePostgres) Driver() driver.Driver { return nil }
+
+// fakePostgresConn buffers the statements of a transaction
+type fakePostgresConn struct {
+	db      *fakePostgres
+	pending []fakePostgresExec
+}
+
+type fakePostgresExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (c *fakePostgresConn) Prepare(query string) (driver.Stmt, error) {
+	if _, ok := c.db.statement[query]; !ok {
+		return nil, errors.New("fakePostgres: unsupported query: " + query)
+	}
+	return &fakePostgresStmt{conn: c, query: query}, nil
+}
+
+func (c *fakePostgresConn) Close() error              { return nil }
+func (c *fakePostgresConn) Begin() (driver.Tx, error) { return c, nil }
+
+func (c *fakePostgresConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for _, exec := range c.pending {
+		statement := c.db.statement[exec.query]
+		var row recordRow
+		row.table = statement.table
+		for i := 0; i < keyColumns[statement.table]; i++ {
+			row.key[i] = exec.args[i].(string)
+		}
+		if statement.kind == "delete" {
+			delete(c.db.rows, row)
+			c.db.deleted = append(c.db.deleted, row)
+		} else {
+			c.db.rows[row] = exec.args[len(exec.args)-1].(string)
+			c.db.upserted = append(c.db.upserted, row)
+		}
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *fakePostgresConn) Rollback() error {
+	c.pending = nil
+	return nil
+}
+
+type fakePostgresStmt struct {
+	conn  *fakePostgresConn
+	query string
+}
+
+func (s *fakePostgresStmt) Close() error  { return nil }
+func (s *fakePostgresStmt) NumInput() int { return -1 }
+
+func (s *fakePostgresStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.db.mu.Lock()
+	fail := s.conn.db.failExecs
+	s.conn.db.mu.Unlock()
+	if fail {
+		return nil, errors.New("fakePostgres: connection reset")
+	}
+	s.conn.pending = append(s.conn.pending, fakePostgresExec{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakePostgresStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	table := db.statement[s.query].table
+	var values [][]driver.Value
+	for row, data := range db.rows {
+		if row.table != table {
+			continue
+		}
+		var value []driver.Value
+		for i := 0; i < keyColumns[table]; i++ {
+			value = append(value, row.key[i])
+		}
+		values = append(values, append(value, data))
+	}
+	return &fakePostgresRows{columns: keyColumns[table] + 1, values: values}, nil
+}
+
+type fakePostgresRows struct {
+	columns int
+	values  [][]driver.Value
+}
+
+func (r *fakePostgresRows) Columns() []string { return make([]string, r.columns) }
+func (r *fakePostgresRows) Close() error      { return nil }
+
+func (r *fakePostgresRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestEncodeRows_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := NewBaseStorage(slog.Default())
+	noop := func(context.Context) error { return nil }
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "Tools", []string{"admin@example.com"}, nil), noop))
+	require.NoError(t, s.CreatePackage(ctx, "reg", models.NewPackage("pkg", "A package", nil, map[string]string{"team": "infra"}), noop))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 4), noop))
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("empty", "", nil, nil), noop))
+
+	rows, err := encodeRows(s.GetData())
+	require.NoError(t, err)
+	assert.Len(t, rows, 2+1+1+5) // Registries, packages, versions, state documents
+
+	// Rows hold records without their children
+	assert.NotContains(t, rows[recordRow{tableRegistries, [3]string{"reg"}}], `"pkg"`)
+
+	encoded, err := assembleRows(rows)
+	require.NoError(t, err)
+	decoded := NewBaseStorage(slog.Default())
+	require.NoError(t, decoded.UnmarshalData(encoded))
+	assert.Equal(t, s.GetData().Registries, decoded.GetData().Registries)
+	assert.Equal(t, s.GetData().Sync, decoded.GetData().Sync)
+}
+
+func TestPostgresStorage_PersistsChangedRows(t *testing.T) {
+	ctx := context.Background()
+	db := newFakePostgres()
+	s := db.open(t)
+
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("other", "", nil, nil)))
+	require.NoError(t, s.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 4)))
+	assert.Equal(t, 2, db.count(tableRegistries))
+	assert.Equal(t, 1, db.count(tablePackages))
+	assert.Equal(t, 1, db.count(tableVersions))
+	db.takeWrites()
+
+	// A new version rewrites its own row, not the versions and registries
+	// it left alone
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "2.0.0", checksumB, "https://x/2.zip", 5, 9)))
+	upserted, deleted := db.takeWrites()
+	assert.Contains(t, upserted, recordRow{tableVersions, [3]string{"reg", "pkg", "2.0.0"}})
+	assert.NotContains(t, upserted, recordRow{tableVersions, [3]string{"reg", "pkg", "1.0.0"}})
+	assert.NotContains(t, upserted, recordRow{tableRegistries, [3]string{"other"}})
+	assert.Empty(t, deleted)
+
+	// Deletes remove the row
+	require.NoError(t, s.DeleteVersion(ctx, "reg", "pkg", "1.0.0"))
+	_, deleted = db.takeWrites()
+	assert.Equal(t, []recordRow{{tableVersions, [3]string{"reg", "pkg", "1.0.0"}}}, deleted)
+	assert.Equal(t, 1, db.count(tableVersions))
+
+	// Deleting a registry removes the rows below it
+	require.NoError(t, s.DeleteRegistry(ctx, "reg"))
+	assert.Equal(t, 1, db.count(tableRegistries))
+	assert.Zero(t, db.count(tableVersions))
+	assert.Zero(t, db.count(tablePackages))
+
+	// Another instance loads what was committed
+	other := db.open(t)
+	registries, err := other.ListRegistries(ctx)
+	require.NoError(t, err)
+	require.Len(t, registries, 1)
+	assert.Equal(t, "other", registries[0].Name)
+}
+
+func TestPostgresStorage_FailedCommitRetried(t *testing.T) {
+	ctx := context.Background()
+	db := newFakePostgres()
+	s := db.open(t)
+
+	db.failExecs = true
+	assert.Error(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	_, err := s.GetRegistry(ctx, "reg")
+	assert.ErrorIs(t, err, ErrNotFound, "failed write rolled back in memory")
+	assert.Zero(t, db.count(tableRegistries))
+
+	// The rows of the failed write were not recorded as written
+	db.failExecs = false
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	assert.Equal(t, 1, db.count(tableRegistries))
+}
+
+func TestPostgresStorage_QuarantinesInvalidRows(t *testing.T) {
+	ctx := context.Background()
+	db := newFakePostgres()
+	s := db.open(t)
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, s.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 4)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "2.0.0", checksumB, "https://x/2.zip", 5, 9)))
+
+	// Edited by hand into something that is not a version
+	db.rows[recordRow{tableVersions, [3]string{"reg", "pkg", "2.0.0"}}] = "not json"
+
+	s = db.open(t)
+	versions, err := s.ListVersions(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+	quarantined, err := s.GetQuarantine(ctx)
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, "reg/pkg/2.0.0", quarantined[0].Key)
+}
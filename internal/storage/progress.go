@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"io"
+	"log/slog"
+	"time"
+)
+
+// loadProgressInterval is how often progress of a blob download is logged
+var loadProgressInterval = 5 * time.Second
+
+// progressReader logs how much of a large blob has been read, at most once
+// per loadProgressInterval, so a slow startup pull is visible in the logs
+type progressReader struct {
+	r       io.Reader
+	total   int64 // expected size, 0 if unknown
+	read    int64
+	started time.Time
+	logged  time.Time
+	logger  *slog.Logger
+	attrs   []any
+}
+
+// newProgressReader wraps r; attrs identify the blob in the log lines
+func newProgressReader(r io.Reader, total int64, logger *slog.Logger, attrs ...any) *progressReader {
+	now := time.Now()
+	return &progressReader{
+		r:       r,
+		total:   total,
+		started: now,
+		logged:  now,
+		logger:  logger,
+		attrs:   attrs,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if now := time.Now(); now.Sub(p.logged) >= loadProgressInterval && err == nil {
+		p.logged = now
+		attrs := append(append([]any{}, p.attrs...),
+			"read_bytes", p.read,
+			"total_bytes", p.total,
+			"elapsed_ms", now.Sub(p.started).Milliseconds())
+		if p.total > 0 {
+			attrs = append(attrs, "percent", p.read*100/p.total)
+		}
+		p.logger.Info("Loading storage data", attrs...)
+	}
+	return n, err
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressReader_LogsProgress(t *testing.T) {
+	saved := loadProgressInterval
+	loadProgressInterval = 0
+	defer func() { loadProgressInterval = saved }()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	data := strings.Repeat("x", 1000)
+	r := newProgressReader(io.LimitReader(strings.NewReader(data), 1000), 1000, logger, "key", "registry.json")
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, string(got))
+
+	assert.Contains(t, logs.String(), "Loading storage data")
+	assert.Contains(t, logs.String(), "key=registry.json")
+	assert.Contains(t, logs.String(), "total_bytes=1000")
+	assert.Contains(t, logs.String(), "percent=")
+}
+
+func TestProgressReader_QuietForFastReads(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	_, err := io.ReadAll(newProgressReader(strings.NewReader("small"), 5, logger))
+	require.NoError(t, err)
+	assert.Empty(t, logs.String())
+}
+
+func TestErrorCategory(t *testing.T) {
+	assert.Equal(t, OCICategoryAuth, ErrorCategory(NewOCIAuthError(OCIOpPull, fmt.Errorf("denied"))))
+	assert.Equal(t, S3CategoryNetwork, ErrorCategory(fmt.Errorf("wrapped: %w", NewS3NetworkError(S3OpDownload, fmt.Errorf("refused")))))
+	assert.Equal(t, CategoryTimeout, ErrorCategory(fmt.Errorf("%w after %s", ErrLoadTimeout, time.Minute)))
+	assert.Equal(t, CategoryData, ErrorCategory(fmt.Errorf("failed to parse storage file")))
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// rawRecord holds an undecoded JSON or CBOR value, so each record of a
+// document can be decoded on its own
+type rawRecord []byte
+
+func (r *rawRecord) UnmarshalJSON(data []byte) error {
+	*r = append((*r)[:0], data...)
+	return nil
+}
+
+func (r *rawRecord) UnmarshalCBOR(data []byte) error {
+	*r = append((*r)[:0], data...)
+	return nil
+}
+
+// looseStorage mirrors models.Storage with records left undecoded
+type looseStorage struct {
+	Registries map[string]rawRecord              `json:"registries"`
+	Sync       *models.SyncState                 `json:"sync,omitempty"`
+	History    map[string][]*models.ChangeRecord `json:"history,omitempty"`
+	Quarantine []*models.QuarantinedRecord       `json:"quarantine,omitempty"`
+
+	Certificates map[string][]byte         `json:"certificates,omitempty"`
+	APIKeys      map[string]*models.APIKey `json:"api_keys,omitempty"`
+}
+
+type looseRegistry struct {
+	models.Registry
+	Packages map[string]rawRecord `json:"packages"`
+}
+
+type loosePackage struct {
+	models.Package
+	Versions map[string]rawRecord `json:"versions"`
+}
+
+// cborJSONDecMode decodes CBOR maps with string keys, so quarantined CBOR
+// records can be shown as JSON
+var cborJSONDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}{})}.DecMode()
+
+// decodeStorageTolerant decodes the records of a document that failed to
+// decode as a whole one by one, and returns the records that do not decode
+// separately. A registry or package that does not decode is quarantined
+// with everything below it. Only errors in the document's structure itself
+// are returned.
+func decodeStorageTolerant(raw []byte) (*models.Storage, []*models.QuarantinedRecord, error) {
+	unmarshal := json.Unmarshal
+	asJSON := func(data []byte) json.RawMessage {
+		if !json.Valid(data) {
+			return nil
+		}
+		return json.RawMessage(data)
+	}
+	if bytes.HasPrefix(raw, cborMagic) {
+		raw = raw[len(cborMagic):]
+		unmarshal = cbor.Unmarshal
+		asJSON = func(data []byte) json.RawMessage {
+			var v interface{}
+			if err := cborJSONDecMode.Unmarshal(data, &v); err != nil {
+				return nil
+			}
+			out, err := json.Marshal(v)
+			if err != nil {
+				return nil
+			}
+			return out
+		}
+	}
+
+	var loose looseStorage
+	if err := unmarshal(raw, &loose); err != nil {
+		return nil, nil, err
+	}
+
+	data := &models.Storage{
+		Registries: make(map[string]*models.Registry, len(loose.Registries)),
+		Sync:       loose.Sync,
+		History:    loose.History,
+		Quarantine: loose.Quarantine,
+
+		Certificates: loose.Certificates,
+		APIKeys:      loose.APIKeys,
+	}
+	var quarantined []*models.QuarantinedRecord
+	reject := func(key string, record rawRecord, err error) {
+		recordType, _, _, _ := models.SplitRecordKey(key)
+		quarantined = append(quarantined, &models.QuarantinedRecord{
+			Key:    key,
+			Type:   recordType,
+			Reason: err.Error(),
+			Data:   asJSON(record),
+		})
+	}
+
+	for registryName, rawRegistry := range loose.Registries {
+		var registry looseRegistry
+		if err := unmarshal(rawRegistry, &registry); err != nil {
+			reject(models.RecordKey(registryName), rawRegistry, err)
+			continue
+		}
+		r := registry.Registry
+		r.Packages = make(map[string]*models.Package, len(registry.Packages))
+		data.Registries[registryName] = &r
+
+		for packageName, rawPackage := range registry.Packages {
+			var pkg loosePackage
+			if err := unmarshal(rawPackage, &pkg); err != nil {
+				reject(models.RecordKey(registryName, packageName), rawPackage, err)
+				continue
+			}
+			p := pkg.Package
+			p.Versions = make(map[string]*models.Version, len(pkg.Versions))
+			r.Packages[packageName] = &p
+
+			for version, rawVersion := range pkg.Versions {
+				var v *models.Version
+				if err := unmarshal(rawVersion, &v); err != nil {
+					reject(models.RecordKey(registryName, packageName, version), rawVersion, err)
+					continue
+				}
+				p.Versions[version] = v
+			}
+		}
+	}
+	return data, quarantined, nil
+}
+
+// loadStorage decodes a persisted document, setting aside the records the
+// registry cannot serve instead of failing the whole load: records that do
+// not decode, then records failing validation (see
+// models.QuarantineInvalid). The quarantined records are appended to the
+// document's quarantine section and returned.
+func loadStorage(raw []byte) (*models.Storage, []*models.QuarantinedRecord, error) {
+	data, _, err := decodeStorage(raw)
+	var quarantined []*models.QuarantinedRecord
+	if err != nil {
+		var tolerantErr error
+		data, quarantined, tolerantErr = decodeStorageTolerant(raw)
+		if tolerantErr != nil {
+			return nil, nil, err
+		}
+	}
+	models.Normalize(data)
+	quarantined = append(quarantined, models.QuarantineInvalid(data)...)
+
+	sort.Slice(quarantined, func(i, j int) bool { return quarantined[i].Key < quarantined[j].Key })
+	now := time.Now().UTC()
+	for _, record := range quarantined {
+		record.QuarantinedAt = now
+	}
+	data.Quarantine = append(data.Quarantine, quarantined...)
+	return data, quarantined, nil
+}
+
+// dropQuarantinedLocked records the deletion of quarantined records that
+// were known to sync clients, so mirrors drop them too.
+// Caller MUST hold the write lock.
+func (b *BaseStorage) dropQuarantinedLocked(quarantined []*models.QuarantinedRecord) {
+	for _, record := range quarantined {
+		if _, known := b.data.Sync.Records[record.Key]; known {
+			b.touchLocked(record.Key, true)
+		}
+	}
+}
+
+// GetQuarantine returns the records set aside while loading the data,
+// oldest first
+func (b *BaseStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return append([]*models.QuarantinedRecord(nil), b.data.Quarantine...), nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const checksumA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+// handEditedStorage has one valid version and records broken in every way
+// the loader tolerates
+const handEditedStorage = `{
+  "registries": {
+    "tools": {
+      "name": "tools",
+      "packages": {
+        "deployer": {
+          "name": "deployer",
+          "versions": {
+            "1.0.0": {"name": "deployer", "version": "1.0.0", "checksum": "` + checksumA + `", "url": "https://e/1.zip", "startPartition": 0, "endPartition": 9},
+            "1.x": {"name": "deployer", "version": "1.x", "checksum": "` + checksumA + `", "url": "https://e/2.zip", "startPartition": 0, "endPartition": 9},
+            "2.0.0": {"name": "deployer", "version": "2.0.0", "checksum": "` + checksumA + `", "url": "https://e/3.zip", "startPartition": "0", "endPartition": 9}
+          }
+        },
+        "broken": {"name": "broken", "maintainers": "not-a-list"}
+      }
+    },
+    "Bad Name": {"name": "Bad Name"}
+  }
+}`
+
+func TestBaseStorage_UnmarshalData_QuarantinesInvalidRecords(t *testing.T) {
+	ctx := context.Background()
+
+	for _, codec := range []Codec{CodecJSON, CodecCBOR} {
+		t.Run(string(codec), func(t *testing.T) {
+			raw := []byte(handEditedStorage)
+			if codec == CodecCBOR {
+				// Re-encode the hand-edited document as CBOR, keeping its broken types
+				var doc interface{}
+				require.NoError(t, json.Unmarshal(raw, &doc))
+				body, err := cborEncMode.Marshal(integers(doc))
+				require.NoError(t, err)
+				raw = append(append([]byte{}, cborMagic...), body...)
+			}
+
+			bs := newTestBaseStorage()
+			require.NoError(t, bs.UnmarshalData(raw))
+
+			versions, err := bs.ListVersions(ctx, "tools", "deployer")
+			require.NoError(t, err)
+			require.Len(t, versions, 1)
+			assert.Equal(t, "1.0.0", versions[0].Version)
+
+			quarantined, err := bs.GetQuarantine(ctx)
+			require.NoError(t, err)
+			var keys []string
+			for _, record := range quarantined {
+				keys = append(keys, record.Key)
+				assert.NotEmpty(t, record.Reason)
+				assert.NotEmpty(t, record.Data)
+				assert.False(t, record.QuarantinedAt.IsZero())
+			}
+			assert.Equal(t, []string{"Bad Name", "tools/broken", "tools/deployer/1.x", "tools/deployer/2.0.0"}, keys)
+			assert.Equal(t, models.QuarantineCounts{Registries: 1, Packages: 1, Versions: 2}, models.CountQuarantined(quarantined))
+
+			// Quarantined records are persisted with the data, once
+			data, err := bs.MarshalData()
+			require.NoError(t, err)
+			reloaded := newTestBaseStorage()
+			require.NoError(t, reloaded.UnmarshalData(data))
+			again, err := reloaded.GetQuarantine(ctx)
+			require.NoError(t, err)
+			assert.Len(t, again, 4)
+		})
+	}
+}
+
+// integers turns the whole numbers of a decoded JSON document into integers,
+// as CBOR encodes them
+func integers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = integers(value)
+		}
+	case float64:
+		return int64(v)
+	}
+	return v
+}
+
+func TestBaseStorage_UnmarshalData_UnreadableDocument(t *testing.T) {
+	bs := newTestBaseStorage()
+	assert.Error(t, bs.UnmarshalData([]byte(`{"registries": `)))
+	assert.Error(t, bs.UnmarshalData([]byte(`{"registries": []}`)))
+}
+
+func TestBaseStorage_UnmarshalData_QuarantineReachesSyncClients(t *testing.T) {
+	ctx := context.Background()
+	bs := newTestBaseStorage()
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, nil), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "tools", "deployer", models.NewVersion("deployer", "1.0.0", checksumA, "https://e/1.zip", 0, 9), nil))
+	_, generation, err := bs.Changes(ctx, 0)
+	require.NoError(t, err)
+
+	// Hand edit: break the version's partitions
+	bs.GetData().Registries["tools"].Packages["deployer"].Versions["1.0.0"].EndPartition = 12
+	data, err := bs.MarshalData()
+	require.NoError(t, err)
+	require.NoError(t, bs.UnmarshalData(data))
+
+	changes, _, err := bs.Changes(ctx, generation)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, models.SyncOpDelete, changes[0].Op)
+	assert.Equal(t, "1.0.0", changes[0].Version)
+}
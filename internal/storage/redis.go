@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// redisConnectTimeout bounds the initial load unless LoadTimeout is longer
+const redisConnectTimeout = 30 * time.Second
+
+// RedisStorage implements Store interface using a Redis server.
+// It embeds BaseStorage for in-memory CRUD operations and keeps the rows of
+// the SQL backends (see rows.go) in a single hash, whose fields are their
+// rowKey. A write sets and deletes the fields that changed in one
+// MULTI/EXEC transaction.
+type RedisStorage struct {
+	*BaseStorage // Embedded for shared CRUD logic
+	client       *redis.Client
+	key          string // Key of the records hash
+
+	// written holds the JSON of each row as last committed, so a write only
+	// touches the rows that changed. Guarded by the BaseStorage lock.
+	written map[recordRow]string
+}
+
+// NewRedisStorage connects to the Redis server of uri and loads its data.
+// The token, USER:PASSWORD or PASSWORD, replaces the credentials of the URI.
+func NewRedisStorage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*RedisStorage, error) {
+	if !uri.IsRedisScheme() {
+		return nil, fmt.Errorf("expected Redis URI, got scheme: %s", uri.Scheme)
+	}
+
+	redisOpts, err := redis.ParseURL(uri.RedisURL(token))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URI: %w", err)
+	}
+
+	s := &RedisStorage{
+		BaseStorage: NewBaseStorage(logger),
+		client:      redis.NewClient(redisOpts),
+		key:         uri.RedisPrefix() + ":records",
+		written:     make(map[recordRow]string),
+	}
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+
+	timeout := redisConnectTimeout
+	if opts.LoadTimeout > timeout {
+		timeout = opts.LoadTimeout
+	}
+	ctx, cancel := context.WithTimeout(tracing.WithOperation(context.Background(), "load"), timeout)
+	defer cancel()
+	if err := s.load(ctx); err != nil {
+		s.client.Close()
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+	return s, nil
+}
+
+// load reads all rows and nests them into a document, decoded like the
+// other backends' so invalid records are quarantined
+func (s *RedisStorage) load(ctx context.Context) error {
+	fields, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read Redis hash %s: %w", s.key, err)
+	}
+	rows := make(map[recordRow]string, len(fields))
+	for field, data := range fields {
+		row, ok := parseRowKey(field)
+		if !ok {
+			s.logger.Warn("Ignoring unknown field of the Redis records hash", "key", s.key, "field", field)
+			continue
+		}
+		rows[row] = data
+	}
+
+	encoded, err := assembleRows(rows)
+	if err != nil {
+		return fmt.Errorf("failed to assemble Redis rows: %w", err)
+	}
+	if err := s.UnmarshalData(encoded); err != nil {
+		return fmt.Errorf("failed to decode Redis rows: %w", err)
+	}
+	s.written = rows
+
+	data := s.GetData()
+	s.logger.Info("Redis storage loaded",
+		"key", s.key,
+		"registry_count", len(data.Registries))
+	return nil
+}
+
+// persist is the callback passed to BaseStorage methods: it writes the rows
+// that changed since the last commit in a single transaction
+func (s *RedisStorage) persist(ctx context.Context) error {
+	rows, err := encodeRows(s.getDataLocked())
+	if err != nil {
+		return fmt.Errorf("failed to encode rows: %w", err)
+	}
+
+	var deleted []string
+	for row := range s.written {
+		if _, exists := rows[row]; !exists {
+			deleted = append(deleted, rowKey(row))
+		}
+	}
+	changed := make(map[string]any)
+	for row, data := range rows {
+		if s.written[row] != data {
+			changed[rowKey(row)] = data
+		}
+	}
+	if len(deleted) == 0 && len(changed) == 0 {
+		return nil
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(deleted) > 0 {
+			pipe.HDel(ctx, s.key, deleted...)
+		}
+		if len(changed) > 0 {
+			pipe.HSet(ctx, s.key, changed)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write Redis hash %s: %w", s.key, err)
+	}
+	s.written = rows
+	return nil
+}
+
+// CreateRegistry creates a new registry
+func (s *RedisStorage) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.CreateRegistry(ctx, r, s.persist)
+}
+
+// GetRegistry retrieves a registry by name
+func (s *RedisStorage) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	return s.BaseStorage.GetRegistry(ctx, name)
+}
+
+// UpdateRegistry updates registry metadata
+func (s *RedisStorage) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.UpdateRegistry(ctx, r, s.persist)
+}
+
+// DeleteRegistry deletes a registry and all its packages (atomic)
+func (s *RedisStorage) DeleteRegistry(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteRegistry(ctx, name, s.persist)
+}
+
+// ListRegistries returns all registries
+func (s *RedisStorage) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	return s.BaseStorage.ListRegistries(ctx)
+}
+
+// CreatePackage creates a new package in a registry
+func (s *RedisStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.CreatePackage(ctx, registryName, p, s.persist)
+}
+
+// GetPackage retrieves a package from a registry
+func (s *RedisStorage) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	return s.BaseStorage.GetPackage(ctx, registryName, packageName)
+}
+
+// UpdatePackage updates package metadata (preserves versions)
+func (s *RedisStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
+}
+
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *RedisStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
+// DeletePackage deletes a package and all its versions (atomic)
+func (s *RedisStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
+}
+
+// ListPackages returns all packages in a registry
+func (s *RedisStorage) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	return s.BaseStorage.ListPackages(ctx, registryName)
+}
+
+// FindPackages returns the packages whose custom values match a query
+func (s *RedisStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *RedisStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *RedisStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *RedisStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *RedisStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *RedisStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *RedisStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *RedisStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *RedisStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *RedisStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
+// CreateVersion creates a new version for a package
+func (s *RedisStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
+}
+
+// GetVersion retrieves a specific version
+func (s *RedisStorage) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
+	return s.BaseStorage.GetVersion(ctx, registryName, packageName, version)
+}
+
+// DeleteVersion deletes a specific version
+func (s *RedisStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.DeleteVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// ListVersions returns all versions for a package
+func (s *RedisStorage) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
+	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
+}
+
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *RedisStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *RedisStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// GetRegistryIndex generates the registry index (Command Launcher format)
+func (s *RedisStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
+	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
+}
+
+// Changes returns the records changed since a generation (differential sync)
+func (s *RedisStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *RedisStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close closes the connections to the Redis server
+func (s *RedisStorage) Close() error {
+	return s.client.Close()
+}
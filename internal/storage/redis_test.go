@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func newTestRedisStorage(t *testing.T, mr *miniredis.Miniredis, token string) *RedisStorage {
+	t.Helper()
+	uri, err := ParseStorageURI("redis://" + mr.Addr() + "/0?prefix=test")
+	require.NoError(t, err)
+	s, err := NewRedisStorage(uri, token, Options{}, slog.Default())
+	require.NoError(t, err)
+	return s
+}
+
+func TestRedisStorage_PersistAndReload(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+
+	s := newTestRedisStorage(t, mr, "")
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "Tools", []string{"admin@example.com"}, nil)))
+	require.NoError(t, s.CreatePackage(ctx, "reg", models.NewPackage("pkg", "A package", nil, map[string]string{"team": "infra"})))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 4)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "2.0.0", checksumB, "https://x/2.zip", 5, 9)))
+	require.NoError(t, s.Close())
+
+	// One field per record, under the key prefix
+	fields, err := mr.HKeys("test:records")
+	require.NoError(t, err)
+	assert.Contains(t, fields, `["registries","reg"]`)
+	assert.Contains(t, fields, `["packages","reg","pkg"]`)
+	assert.Contains(t, fields, `["versions","reg","pkg","1.0.0"]`)
+	assert.Contains(t, fields, `["state","history"]`)
+
+	s = newTestRedisStorage(t, mr, "")
+	defer s.Close()
+	pkg, err := s.GetPackage(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.Equal(t, "infra", pkg.CustomValues["team"])
+	assert.Len(t, pkg.Versions, 2)
+
+	// Deletes remove the fields below the deleted record
+	require.NoError(t, s.DeleteRegistry(ctx, "reg"))
+	fields, err = mr.HKeys("test:records")
+	require.NoError(t, err)
+	for _, field := range fields {
+		assert.Regexp(t, `^\["state",`, field)
+	}
+}
+
+func TestRedisStorage_QuarantinesInvalidRows(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+
+	s := newTestRedisStorage(t, mr, "")
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, s.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 4)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "2.0.0", checksumB, "https://x/2.zip", 5, 9)))
+	require.NoError(t, s.Close())
+
+	// Edited by hand into something that is not a version
+	mr.HSet("test:records", `["versions","reg","pkg","2.0.0"]`, "not json")
+
+	s = newTestRedisStorage(t, mr, "")
+	defer s.Close()
+	versions, err := s.ListVersions(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+	quarantined, err := s.GetQuarantine(ctx)
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, "reg/pkg/2.0.0", quarantined[0].Key)
+}
+
+func TestRedisStorage_TokenAuthenticates(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.RequireUserAuth("cola", "secret")
+
+	uri, err := ParseStorageURI("redis://" + mr.Addr())
+	require.NoError(t, err)
+	_, err = NewRedisStorage(uri, "", Options{}, slog.Default())
+	assert.Error(t, err)
+
+	s := newTestRedisStorage(t, mr, "cola:secret")
+	assert.NoError(t, s.Close())
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// RedisCache wraps a Store and caches hot reads (index.json, registries,
+// packages and versions) in Redis, shared by every instance.
+//
+// Entries are keyed by the newest generation published on the ChangeFeed,
+// so once a write is published, entries written before it are never served
+// again and expire with their TTL. An instance only fills entries when its
+// own data is at that generation, so a replica that has not refreshed yet
+// cannot spread stale data. Redis failures are logged and reads fall back
+// to the store: the cache never fails a request. Methods not overridden
+// pass straight through.
+type RedisCache struct {
+	Store
+	client *redis.Client
+	feed   *ChangeFeed
+	prefix string
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+// NewRedisCache wraps store with entries kept for ttl under prefix. Writes
+// must be published on feed (see FeedStore).
+func NewRedisCache(store Store, client *redis.Client, feed *ChangeFeed, prefix string, ttl time.Duration, logger *slog.Logger) *RedisCache {
+	return &RedisCache{
+		Store:  store,
+		client: client,
+		feed:   feed,
+		prefix: prefix,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// cached returns the entry at key for the current generation, or loads it
+// from the store and fills the entry when the store is up to date
+func cached[T any](ctx context.Context, c *RedisCache, key string, load func() (T, error)) (T, error) {
+	generation := c.feed.Generation()
+	fullKey := fmt.Sprintf("%s:%d:%s", c.prefix, generation, key)
+
+	raw, err := c.client.Get(ctx, fullKey).Bytes()
+	if err == nil {
+		var value T
+		if err := json.Unmarshal(raw, &value); err == nil {
+			return value, nil
+		}
+		c.logger.Warn("Ignoring undecodable cache entry", "key", fullKey)
+	} else if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+		c.logger.Warn("Redis cache read failed", "key", fullKey, "error", err)
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if local, err := storeGeneration(ctx, c.Store); err != nil || local != generation {
+		return value, nil
+	}
+	if encoded, err := json.Marshal(value); err == nil {
+		if err := c.client.Set(context.WithoutCancel(ctx), fullKey, encoded, c.ttl).Err(); err != nil {
+			c.logger.Warn("Redis cache write failed", "key", fullKey, "error", err)
+		}
+	}
+	return value, nil
+}
+
+// GetRegistryIndex returns the cached index.json entries of a registry
+func (c *RedisCache) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
+	return cached(ctx, c, "index:"+registryName, func() ([]models.IndexEntry, error) {
+		return c.Store.GetRegistryIndex(ctx, registryName)
+	})
+}
+
+// GetRegistry returns a cached registry
+func (c *RedisCache) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	return cached(ctx, c, "registry:"+name, func() (*models.Registry, error) {
+		return c.Store.GetRegistry(ctx, name)
+	})
+}
+
+// ListRegistries returns the cached list of registries
+func (c *RedisCache) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	return cached(ctx, c, "registries", func() ([]*models.Registry, error) {
+		return c.Store.ListRegistries(ctx)
+	})
+}
+
+// GetPackage returns a cached package
+func (c *RedisCache) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	return cached(ctx, c, "package:"+registryName+"/"+packageName, func() (*models.Package, error) {
+		return c.Store.GetPackage(ctx, registryName, packageName)
+	})
+}
+
+// ListPackages returns the cached packages of a registry
+func (c *RedisCache) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	return cached(ctx, c, "packages:"+registryName, func() ([]*models.Package, error) {
+		return c.Store.ListPackages(ctx, registryName)
+	})
+}
+
+// GetVersion returns a cached version
+func (c *RedisCache) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
+	return cached(ctx, c, "version:"+registryName+"/"+packageName+"/"+version, func() (*models.Version, error) {
+		return c.Store.GetVersion(ctx, registryName, packageName, version)
+	})
+}
+
+// ListVersions returns the cached versions of a package
+func (c *RedisCache) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
+	return cached(ctx, c, "versions:"+registryName+"/"+packageName, func() ([]*models.Version, error) {
+		return c.Store.ListVersions(ctx, registryName, packageName)
+	})
+}
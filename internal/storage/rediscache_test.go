@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// redisInstance is one server instance sharing a backend through Redis
+type redisInstance struct {
+	Store
+	backend *FileStorage
+	feed    *ChangeFeed
+}
+
+func newRedisInstance(t *testing.T, mr *miniredis.Miniredis, path string, follow bool) *redisInstance {
+	t.Helper()
+	backend, err := NewFileStorage(path, "", slog.Default())
+	require.NoError(t, err)
+	client, err := NewRedisClient("redis://" + mr.Addr())
+	require.NoError(t, err)
+	feed, err := NewChangeFeed(client, "test", backend, slog.Default())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		feed.Close()
+		client.Close()
+	})
+	if follow {
+		feed.Follow(backend)
+	}
+	cache := NewRedisCache(backend, client, feed, "test", time.Minute, slog.Default())
+	return &redisInstance{
+		Store:   NewFeedStore(cache, feed, slog.Default()),
+		backend: backend,
+		feed:    feed,
+	}
+}
+
+func TestRedisCache_ServesAndInvalidates(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	path := filepath.Join(t.TempDir(), "registry.json")
+
+	writer := newRedisInstance(t, mr, path, false)
+	require.NoError(t, writer.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, writer.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.NoError(t, writer.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 9)))
+	assert.Equal(t, "3", mustGet(t, mr, "test:generation"))
+
+	index, err := writer.GetRegistryIndex(ctx, "reg")
+	require.NoError(t, err)
+	require.Len(t, index, 1)
+	assert.True(t, mr.Exists("test:3:index:reg"))
+
+	// An instance started after the write serves the shared entry
+	replica := newRedisInstance(t, mr, path, false)
+	mr.Set("test:3:index:reg", `[{"name":"pkg","version":"cached"}]`)
+	index, err = replica.GetRegistryIndex(ctx, "reg")
+	require.NoError(t, err)
+	assert.Equal(t, "cached", index[0].Version)
+
+	// A write moves every instance to the next generation
+	require.NoError(t, writer.DeleteVersion(ctx, "reg", "pkg", "1.0.0"))
+	require.Eventually(t, func() bool { return replica.feed.Generation() == 4 }, time.Second, 10*time.Millisecond)
+
+	// The replica does not follow changes: it serves its own data but does
+	// not fill the cache with it
+	index, err = replica.GetRegistryIndex(ctx, "reg")
+	require.NoError(t, err)
+	assert.Len(t, index, 1)
+	assert.False(t, mr.Exists("test:4:index:reg"))
+
+	index, err = writer.GetRegistryIndex(ctx, "reg")
+	require.NoError(t, err)
+	assert.Empty(t, index)
+	assert.True(t, mr.Exists("test:4:index:reg"))
+}
+
+func TestRedisCache_WatcherReloadMovesGeneration(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	path := filepath.Join(t.TempDir(), "registry.json")
+
+	instance := newRedisInstance(t, mr, path, false)
+	watcher := NewWatcher(instance.backend, time.Hour, slog.Default())
+	defer watcher.Close()
+	watcher.OnReload(func(ctx context.Context, generation uint64) {
+		assert.NoError(t, instance.feed.Publish(ctx, generation))
+	})
+
+	require.NoError(t, instance.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, instance.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	index, err := instance.GetRegistryIndex(ctx, "reg")
+	require.NoError(t, err)
+	assert.Empty(t, index)
+	assert.True(t, mr.Exists("test:2:index:reg"))
+
+	// Another writer without the change feed adds a version behind the
+	// instance's back
+	other, err := NewFileStorage(path, "", slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, other.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 9)))
+
+	// Once reloaded, the new generation is published and the stale entry
+	// is no longer served
+	require.Eventually(t, func() bool {
+		generation, err := mr.Get("test:generation")
+		return err == nil && generation == "3"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(3), instance.feed.Generation())
+	index, err = instance.GetRegistryIndex(ctx, "reg")
+	require.NoError(t, err)
+	assert.Len(t, index, 1)
+	assert.True(t, mr.Exists("test:3:index:reg"))
+}
+
+func TestRedisCache_FallsBackWhenRedisIsDown(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	c := newRedisInstance(t, mr, filepath.Join(t.TempDir(), "registry.json"), false)
+
+	mr.Close()
+	require.NoError(t, c.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	registry, err := c.GetRegistry(ctx, "reg")
+	require.NoError(t, err)
+	assert.Equal(t, "reg", registry.Name)
+
+	_, err = c.GetRegistry(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	t.Helper()
+	value, err := mr.Get(key)
+	require.NoError(t, err)
+	return value
+}
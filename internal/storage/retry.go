@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// Default retry policy of the OCI, S3, GCS, Git, DynamoDB and WebDAV clients
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryMinBackoff  = 250 * time.Millisecond
+	DefaultRetryMaxBackoff  = 3 * time.Second
+	DefaultRetryBudget      = 0.2
+	DefaultRetryJitter      = 0.1
+)
+
+// retryBudgetReserve is the number of retries a client can make back to
+// back, before its budget only refills with new operations
+const retryBudgetReserve = 10
+
+// RetryPolicy decides how the OCI, S3, GCS, Git, DynamoDB and WebDAV clients retry failed operations.
+// All clients retry the same errors, those marked Retryable (network
+// errors, and timeouts, rate limiting and 5xx answers of the backend),
+// never authentication errors.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts per operation, the first included; 1 disables retries
+	MinBackoff  time.Duration // Wait before the first retry, doubled on each retry
+	MaxBackoff  time.Duration // Longest wait between attempts
+	// Budget is the number of retries earned by each operation, so that a
+	// backend that is down gets at most (1+Budget) times the usual requests
+	// once the reserve of retries is spent. Zero disables the budget.
+	Budget float64
+	// Jitter is the largest fraction of the backoff added at random to each
+	// wait, so clients failing together do not retry in lockstep. Zero
+	// disables it.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DefaultRetryMaxAttempts,
+		MinBackoff:  DefaultRetryMinBackoff,
+		MaxBackoff:  DefaultRetryMaxBackoff,
+		Budget:      DefaultRetryBudget,
+		Jitter:      DefaultRetryJitter,
+	}
+}
+
+// retrier runs client operations under a retry policy, sharing one retry
+// budget across the operations of a client
+type retrier struct {
+	policy RetryPolicy
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	tokens float64 // Retries left in the budget
+}
+
+// newRetrier creates a retrier. A zero policy is the default policy; zero
+// attempts and backoffs of other policies take their default.
+func newRetrier(policy RetryPolicy, logger *slog.Logger) *retrier {
+	if policy == (RetryPolicy{}) {
+		policy = DefaultRetryPolicy()
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = DefaultRetryMinBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = max(DefaultRetryMaxBackoff, policy.MinBackoff)
+	}
+	return &retrier{policy: policy, logger: logger, tokens: retryBudgetReserve}
+}
+
+// do runs fn until it succeeds, fails with an error that is not retryable,
+// or runs out of attempts, budget or time; it returns the last error
+func (r *retrier) do(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	r.deposit()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !Retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= r.policy.MaxAttempts {
+			r.logger.Warn("Storage operation failed after retries",
+				append(tracing.LogAttrs(ctx), "operation", op, "attempts", attempt, "error", err)...)
+			return err
+		}
+		if !r.withdraw() {
+			r.logger.Warn("Storage retry budget exhausted, not retrying",
+				append(tracing.LogAttrs(ctx), "operation", op, "attempts", attempt, "error", err)...)
+			return err
+		}
+
+		wait := r.backoff(attempt)
+		r.logger.Info("Retrying storage operation",
+			append(tracing.LogAttrs(ctx), "operation", op, "attempt", attempt+1, "wait_ms", wait.Milliseconds(), "error", err)...)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait before the retry following attempt: exponential,
+// with up to the policy's jitter added at random
+func (r *retrier) backoff(attempt int) time.Duration {
+	wait := r.policy.MinBackoff << (attempt - 1)
+	if wait > r.policy.MaxBackoff || wait <= 0 {
+		wait = r.policy.MaxBackoff
+	}
+	return wait + time.Duration(rand.Int64N(int64(float64(wait)*r.policy.Jitter)+1))
+}
+
+func (r *retrier) deposit() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = min(r.tokens+r.policy.Budget, retryBudgetReserve)
+}
+
+func (r *retrier) withdraw() bool {
+	if r.policy.Budget == 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// Retryable reports whether a failed storage operation may succeed if
+// retried: OCI, S3, GCS, Git, DynamoDB and WebDAV network errors, and transient backend answers
+func Retryable(err error) bool {
+	var ociErr *OCIError
+	var s3Err *S3Error
+	var gcsErr *GCSError
+	var gitErr *GitError
+	var dynamoErr *DynamoDBError
+	var webdavErr *WebDAVError
+	switch {
+	case errors.As(err, &ociErr):
+		return ociErr.Retryable
+	case errors.As(err, &s3Err):
+		return s3Err.Retryable
+	case errors.As(err, &gcsErr):
+		return gcsErr.Retryable
+	case errors.As(err, &gitErr):
+		return gitErr.Retryable
+	case errors.As(err, &dynamoErr):
+		return dynamoErr.Retryable
+	case errors.As(err, &webdavErr):
+		return webdavErr.Retryable
+	default:
+		return false
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRetrier(policy RetryPolicy) *retrier {
+	return newRetrier(policy, slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func TestRetrier(t *testing.T) {
+	ctx := context.Background()
+	fast := RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	// Transient errors are retried up to the attempts
+	attempts := 0
+	err := newTestRetrier(fast).do(ctx, OCIOpPull, func(ctx context.Context) error {
+		attempts++
+		return NewOCINetworkError(OCIOpPull, errors.New("connection reset"))
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = newTestRetrier(fast).do(ctx, S3OpDownload, func(ctx context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return CategorizeS3Error(S3OpDownload, minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable})
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// Authentication and other permanent errors are not
+	attempts = 0
+	err = newTestRetrier(fast).do(ctx, S3OpUpload, func(ctx context.Context) error {
+		attempts++
+		return CategorizeS3Error(S3OpUpload, minio.ErrorResponse{Code: "AccessDenied", StatusCode: http.StatusForbidden})
+	})
+	assert.Equal(t, S3CategoryAuth, ErrorCategory(err))
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	newTestRetrier(fast).do(ctx, OCIOpPull, func(ctx context.Context) error {
+		attempts++
+		return CategorizeOCIError(OCIOpPull, errors.New("response status code 404: not found"))
+	})
+	assert.Equal(t, 1, attempts)
+
+	// Once the reserve is spent, retries are bounded by the budget
+	budgeted := fast
+	budgeted.MaxAttempts = 2
+	budgeted.Budget = 0.5
+	r := newTestRetrier(budgeted)
+	failing := func(ctx context.Context) error {
+		attempts++
+		return NewOCINetworkError(OCIOpPull, errors.New("connection refused"))
+	}
+	for i := 0; i < 40; i++ {
+		r.do(ctx, OCIOpPull, failing)
+	}
+	attempts = 0
+	for i := 0; i < 10; i++ {
+		r.do(ctx, OCIOpPull, failing)
+	}
+	// 10 operations earn 5 retries
+	assert.Equal(t, 15, attempts)
+}
+
+func TestRetrier_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, MinBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	r := newTestRetrier(policy)
+	assert.Equal(t, 100*time.Millisecond, r.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, r.backoff(2))
+	assert.Equal(t, 300*time.Millisecond, r.backoff(3)) // Capped
+
+	policy.Jitter = 0.5
+	r = newTestRetrier(policy)
+	for range 20 {
+		wait := r.backoff(2)
+		assert.GreaterOrEqual(t, wait, 200*time.Millisecond)
+		assert.LessOrEqual(t, wait, 300*time.Millisecond)
+	}
+}
+
+func TestOCIClient_PushRetries(t *testing.T) {
+	registry := newFakeOCI(t, "acme/registry")
+	client, err := NewOCIClient(registry.Host+"/acme/registry:latest", "", newTestOCILogger())
+	require.NoError(t, err)
+	client.repository.PlainHTTP = true
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	// Transient 502s of the registry do not fail the push
+	registry.FailManifestPuts(2)
+	artifact, err := client.Push(context.Background(), []byte(`{"registries":{}}`), "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, artifact.Digest)
+
+	registry.FailManifestPuts(3)
+	_, err = client.Push(context.Background(), []byte(`{"registries":{"tools":{}}}`), artifact.Digest)
+	assert.ErrorIs(t, err, ErrStorageUnavailable)
+	assert.True(t, Retryable(err))
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, Retryable(CategorizeOCIError(OCIOpPush, errors.New("response status code 503: service unavailable"))))
+	assert.True(t, Retryable(CategorizeOCIError(OCIOpPush, errors.New("response status code 429: toomanyrequests"))))
+	assert.False(t, Retryable(CategorizeOCIError(OCIOpPush, errors.New("response status code 401: unauthorized"))))
+	assert.True(t, Retryable(CategorizeS3Error(S3OpDownload, minio.ErrorResponse{Code: "BadGateway", StatusCode: http.StatusBadGateway})))
+	assert.False(t, Retryable(CategorizeS3Error(S3OpDownload, minio.ErrorResponse{Code: "NoSuchBucket", StatusCode: http.StatusNotFound})))
+	assert.False(t, Retryable(errors.New("decode failed")))
+}
+
+func TestS3Client_Retries(t *testing.T) {
+	var requests atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first request fails; the SDK must not retry it itself
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	client, err := NewS3Client(strings.TrimPrefix(backend.URL, "http://"), "bucket", "registry.json", "access", "secret", false, "us-east-1", logger)
+	require.NoError(t, err)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	require.NoError(t, client.ValidateBucket(context.Background()))
+	assert.Equal(t, int32(2), requests.Load())
+}
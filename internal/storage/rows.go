@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// SQL backends (SQLite and PostgreSQL) keep one row per registry, package
+// and version, holding the record as JSON without its children, and the
+// sync state, histories, quarantined records, certificates and API keys as
+// JSON documents in a state table keyed by their field name. A write
+// commits only the rows that changed. Key-value backends keep the same
+// rows under their rowKey.
+
+// recordTable names the table of a row
+type recordTable int
+
+const (
+	tableRegistries recordTable = iota
+	tablePackages
+	tableVersions
+	tableState
+)
+
+// recordRow identifies a row: its table and primary key (unused key parts
+// are empty)
+type recordRow struct {
+	table recordTable
+	key   [3]string
+}
+
+// keyColumns is the number of primary key columns of each table
+var keyColumns = map[recordTable]int{tableRegistries: 1, tablePackages: 2, tableVersions: 3, tableState: 1}
+
+// recordTableNames name the tables in the row keys of the key-value
+// backends (see rowKey)
+var recordTableNames = map[recordTable]string{
+	tableRegistries: "registries",
+	tablePackages:   "packages",
+	tableVersions:   "versions",
+	tableState:      "state",
+}
+
+// rowKey identifies a row in a key-value backend (Redis and DynamoDB) by
+// the JSON array of its table name and primary key, e.g.
+// ["versions","tools","hello","1.0.0"]
+func rowKey(row recordRow) string {
+	parts := append([]string{recordTableNames[row.table]}, row.key[:keyColumns[row.table]]...)
+	encoded, _ := json.Marshal(parts)
+	return string(encoded)
+}
+
+// parseRowKey returns the row of a rowKey
+func parseRowKey(key string) (recordRow, bool) {
+	var parts []string
+	if err := json.Unmarshal([]byte(key), &parts); err != nil || len(parts) == 0 {
+		return recordRow{}, false
+	}
+	for table, name := range recordTableNames {
+		if name != parts[0] || len(parts) != keyColumns[table]+1 {
+			continue
+		}
+		row := recordRow{table: table}
+		copy(row.key[:], parts[1:])
+		return row, true
+	}
+	return recordRow{}, false
+}
+
+// rowQueries are the statements of a SQL dialect, by table: select reads
+// the key columns then the data, upsert takes the key columns then the
+// data, delete takes the key columns
+type rowQueries struct {
+	selects map[recordTable]string
+	upserts map[recordTable]string
+	deletes map[recordTable]string
+}
+
+// encodeRows encodes data as rows
+func encodeRows(data *models.Storage) (map[recordRow]string, error) {
+	rows := make(map[recordRow]string)
+	add := func(table recordTable, key [3]string, v any) error {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		rows[recordRow{table, key}] = string(encoded)
+		return nil
+	}
+
+	for registryName, registry := range data.Registries {
+		r := *registry
+		r.Packages = nil
+		if err := add(tableRegistries, [3]string{registryName}, &r); err != nil {
+			return nil, err
+		}
+		for packageName, pkg := range registry.Packages {
+			p := *pkg
+			p.Versions = nil
+			if err := add(tablePackages, [3]string{registryName, packageName}, &p); err != nil {
+				return nil, err
+			}
+			for version, v := range pkg.Versions {
+				if err := add(tableVersions, [3]string{registryName, packageName, version}, v); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	state := map[string]any{
+		"sync":         data.Sync,
+		"history":      data.History,
+		"quarantine":   data.Quarantine,
+		"certificates": data.Certificates,
+		"api_keys":     data.APIKeys,
+	}
+	for key, v := range state {
+		if err := add(tableState, [3]string{key}, v); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// assembleRows nests rows into a storage document, to be decoded like the
+// other backends' data so invalid records are quarantined
+func assembleRows(rows map[recordRow]string) ([]byte, error) {
+	registries := map[string]string{}
+	packages := map[string]map[string]string{} // By registry
+	versions := map[[2]string]map[string]json.RawMessage{}
+	doc := map[string]json.RawMessage{}
+	for row, data := range rows {
+		key := row.key
+		switch row.table {
+		case tableRegistries:
+			registries[key[0]] = data
+		case tablePackages:
+			if packages[key[0]] == nil {
+				packages[key[0]] = map[string]string{}
+			}
+			packages[key[0]][key[1]] = data
+		case tableVersions:
+			pkg := [2]string{key[0], key[1]}
+			if versions[pkg] == nil {
+				versions[pkg] = map[string]json.RawMessage{}
+			}
+			versions[pkg][key[2]] = rawRow(data)
+		case tableState:
+			doc[key[0]] = json.RawMessage(data)
+		}
+	}
+
+	registryDocs := map[string]json.RawMessage{}
+	for registryName, data := range registries {
+		packageDocs := map[string]json.RawMessage{}
+		for packageName, pkg := range packages[registryName] {
+			packageDocs[packageName] = withChildren(pkg, "versions", versions[[2]string{registryName, packageName}])
+		}
+		registryDocs[registryName] = withChildren(data, "packages", packageDocs)
+	}
+	encoded, err := json.Marshal(registryDocs)
+	if err != nil {
+		return nil, err
+	}
+	doc["registries"] = encoded
+	return json.Marshal(doc)
+}
+
+// readRows reads every row of the tables
+func readRows(ctx context.Context, db *sql.DB, queries rowQueries) (map[recordRow]string, error) {
+	rows := make(map[recordRow]string)
+	for _, table := range []recordTable{tableRegistries, tablePackages, tableVersions, tableState} {
+		if err := scanRows(ctx, db, table, queries.selects[table], rows); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+func scanRows(ctx context.Context, db *sql.DB, table recordTable, query string, rows map[recordRow]string) error {
+	result, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var key [3]string
+		var data string
+		dest := make([]any, 0, keyColumns[table]+1)
+		for i := 0; i < keyColumns[table]; i++ {
+			dest = append(dest, &key[i])
+		}
+		dest = append(dest, &data)
+		if err := result.Scan(dest...); err != nil {
+			return err
+		}
+		rows[recordRow{table, key}] = data
+	}
+	return result.Err()
+}
+
+// writeRows turns the rows written by the last commit into rows within tx,
+// touching only the rows that changed
+func writeRows(ctx context.Context, tx *sql.Tx, queries rowQueries, written, rows map[recordRow]string) error {
+	// Children first: deleting a parent cascades to the rows below it
+	for _, table := range []recordTable{tableVersions, tablePackages, tableRegistries, tableState} {
+		for row := range written {
+			if _, exists := rows[row]; exists || row.table != table {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, queries.deletes[table], keyArgs(table, row.key)...); err != nil {
+				return fmt.Errorf("failed to delete row: %w", err)
+			}
+		}
+	}
+	// Parents first, for foreign keys
+	for _, table := range []recordTable{tableRegistries, tablePackages, tableVersions, tableState} {
+		for row, data := range rows {
+			if row.table != table || written[row] == data {
+				continue
+			}
+			args := append(keyArgs(table, row.key), data)
+			if _, err := tx.ExecContext(ctx, queries.upserts[table], args...); err != nil {
+				return fmt.Errorf("failed to write row: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// keyArgs returns the primary key columns of a row as query arguments
+func keyArgs(table recordTable, key [3]string) []any {
+	args := make([]any, keyColumns[table])
+	for i := range args {
+		args[i] = key[i]
+	}
+	return args
+}
+
+// rawRow returns the JSON of a record row. A row that is not JSON becomes
+// a JSON string, which fails to decode as a record and is quarantined.
+func rawRow(row string) json.RawMessage {
+	if json.Valid([]byte(row)) {
+		return json.RawMessage(row)
+	}
+	encoded, _ := json.Marshal(row)
+	return encoded
+}
+
+// withChildren adds the children of a record row under field. A row that is
+// not a JSON object is returned as is, to be quarantined on decoding.
+func withChildren(row string, field string, children map[string]json.RawMessage) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(row), &object); err != nil || object == nil {
+		return rawRow(row)
+	}
+	if children == nil {
+		children = map[string]json.RawMessage{}
+	}
+	encoded, err := json.Marshal(children)
+	if err != nil {
+		return rawRow(row)
+	}
+	object[field] = encoded
+	encoded, err = json.Marshal(object)
+	if err != nil {
+		return rawRow(row)
+	}
+	return encoded
+}
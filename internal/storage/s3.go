@@ -2,10 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 
 	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
 )
 
 // S3Storage implements Store interface using S3-compatible storage as backend.
@@ -16,16 +19,58 @@ type S3Storage struct {
 	client       *S3Client
 	bucket       string
 	key          string
+	etag         string // Of the object last downloaded or uploaded, guarded by the BaseStorage lock
+	versionID    string // Of the same object, empty unless the bucket has versioning enabled
+	conflicts    conflictRefresher
 }
 
 // NewS3Storage creates a new S3-backed storage.
 // The uri should be a parsed S3 StorageURI (s3://endpoint/bucket/path or s3+http://...).
 // The token should be in format ACCESS_KEY:SECRET_KEY.
-func NewS3Storage(uri *StorageURI, token string, logger *slog.Logger) (*S3Storage, error) {
+// opts selects the codec and compression of the uploaded object.
+func NewS3Storage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*S3Storage, error) {
 	if !uri.IsS3Scheme() {
 		return nil, fmt.Errorf("expected S3 URI, got scheme: %s", uri.Scheme)
 	}
 
+	client, err := newS3Client(uri, token, opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	bucket, key := uri.S3Bucket(), uri.S3Key()
+
+	// Validate bucket exists
+	ctx := tracing.WithOperation(context.Background(), "validate_bucket")
+	if err := client.ValidateBucket(ctx); err != nil {
+		return nil, fmt.Errorf("S3 bucket validation failed: %w", err)
+	}
+
+	s := &S3Storage{
+		BaseStorage: NewBaseStorage(logger),
+		client:      client,
+		bucket:      bucket,
+		key:         key,
+	}
+	s.codec = opts.Codec
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+	s.cacheFile = opts.CacheFile
+	s.envelope = opts.Envelope
+
+	// Load existing data from S3 or initialize empty storage
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("failed to load data from S3: %w", err)
+	}
+
+	// Initializing the storage in load is persisted immediately
+	s.setPersistMode(opts.PersistMode, opts.FlushInterval, s.persist)
+
+	return s, nil
+}
+
+// newS3Client creates the client of the object uri names, configured with
+// opts and authenticated with token (or opts.Credentials)
+func newS3Client(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*S3Client, error) {
 	// Extract S3 components from URI
 	endpoint := uri.S3Endpoint()
 	bucket := uri.S3Bucket()
@@ -43,38 +88,36 @@ func NewS3Storage(uri *StorageURI, token string, logger *slog.Logger) (*S3Storag
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse S3 credentials: %w", err)
 	}
+	if opts.Credentials != nil {
+		if _, secondary := opts.Credentials.Tokens(); secondary != "" {
+			if _, _, err := ParseS3Token(secondary); err != nil {
+				return nil, fmt.Errorf("failed to parse secondary S3 credentials: %w", err)
+			}
+		}
+	}
 
 	// Create S3 client
 	client, err := NewS3Client(endpoint, bucket, key, accessKey, secretKey, useSSL, region, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 client: %w", err)
 	}
-
-	// Validate bucket exists
-	ctx := context.Background()
-	if err := client.ValidateBucket(ctx); err != nil {
-		return nil, fmt.Errorf("S3 bucket validation failed: %w", err)
+	client.codec = opts.Codec
+	client.compression = opts.Compression
+	client.envelope = opts.Envelope
+	if opts.LoadTimeout > S3DownloadTimeout {
+		client.downloadTimeout = opts.LoadTimeout
 	}
-
-	s := &S3Storage{
-		BaseStorage: NewBaseStorage(logger),
-		client:      client,
-		bucket:      bucket,
-		key:         key,
+	client.SetRetryPolicy(opts.Retry)
+	if opts.Credentials != nil {
+		client.SetCredentials(opts.Credentials)
 	}
-
-	// Load existing data from S3 or initialize empty storage
-	if err := s.load(); err != nil {
-		return nil, fmt.Errorf("failed to load data from S3: %w", err)
-	}
-
-	return s, nil
+	return client, nil
 }
 
 // load retrieves registry data from S3 on startup.
 // If the object doesn't exist, initializes empty storage and pushes it.
 func (s *S3Storage) load() error {
-	ctx := context.Background()
+	ctx := tracing.WithOperation(context.Background(), "load")
 
 	// Check if object exists
 	exists, err := s.client.Exists(ctx)
@@ -89,21 +132,14 @@ func (s *S3Storage) load() error {
 			"key", s.key)
 
 		// Push initial empty storage
-		if err := s.persist(); err != nil {
+		if err := s.persist(ctx); err != nil {
 			return fmt.Errorf("failed to initialize S3 storage: %w", err)
 		}
 		return nil
 	}
 
-	// Download existing data
-	data, err := s.client.Download(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to download from S3: %w", err)
-	}
-
-	// Parse JSON data
-	if err := s.UnmarshalData(data); err != nil {
-		return fmt.Errorf("failed to parse registry data (corrupted JSON): %w", err)
+	if err := s.reload(ctx); err != nil {
+		return err
 	}
 
 	storageData := s.GetData()
@@ -115,20 +151,133 @@ func (s *S3Storage) load() error {
 	return nil
 }
 
-// persist uploads the complete registry data to S3.
+// Refresh downloads the data again, to catch up with writes made by another
+// instance sharing the object
+func (s *S3Storage) Refresh(ctx context.Context) error {
+	ctx = tracing.WithOperation(ctx, "refresh")
+	if err := s.flushBeforeReload(ctx); err != nil {
+		return err
+	}
+	return s.reload(ctx)
+}
+
+// StoredVersion returns the ETag of the object, for Watcher
+func (s *S3Storage) StoredVersion(ctx context.Context) (string, error) {
+	return s.client.ETag(ctx)
+}
+
+// Ping checks the object can still be reached with a HEAD request, for
+// health checks
+func (s *S3Storage) Ping(ctx context.Context) error {
+	_, err := s.client.ETag(tracing.WithOperation(ctx, "ping"))
+	return err
+}
+
+// LoadedVersion returns the ETag of the object as last downloaded or uploaded
+func (s *S3Storage) LoadedVersion(ctx context.Context) (string, error) {
+	unlock, err := s.rlock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return s.etag, nil
+}
+
+// CurrentVersion returns the version ID of the object as last downloaded or
+// uploaded, empty unless the bucket has versioning enabled
+func (s *S3Storage) CurrentVersion(ctx context.Context) (string, error) {
+	unlock, err := s.rlock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return s.versionID, nil
+}
+
+// Versions lists the versions of the object the bucket keeps, most recent
+// first; none unless the bucket has versioning enabled
+func (s *S3Storage) Versions(ctx context.Context) ([]DataVersion, error) {
+	return s.client.ListVersions(tracing.WithOperation(ctx, "list_versions"))
+}
+
+// Rollback replaces the data with a prior version of the object. The
+// restored data is uploaded as a new version, so the rollback can itself
+// be rolled back.
+func (s *S3Storage) Rollback(ctx context.Context, id string) (uint64, error) {
+	ctx = tracing.WithOperation(ctx, "rollback")
+	versions, err := s.Versions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list S3 object versions: %w", err)
+	}
+	if !slices.ContainsFunc(versions, func(v DataVersion) bool { return v.ID == id }) {
+		return 0, ErrVersionNotFound
+	}
+
+	raw, err := s.client.DownloadVersion(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download S3 object version %s: %w", id, err)
+	}
+	restored, err := restoreData(ctx, raw, s)
+	if err != nil {
+		return 0, err
+	}
+	s.logger.Info("Storage rolled back to S3 object version",
+		"bucket", s.bucket,
+		"key", s.key,
+		"version_id", id,
+		"generation", restored.Sync.Generation,
+		"registry_count", len(restored.Registries))
+	return restored.Sync.Generation, nil
+}
+
+// reload replaces the in-memory data with the stored one
+func (s *S3Storage) reload(ctx context.Context) error {
+	data, version, err := s.client.Download(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to download from S3: %w", err)
+	}
+	if err := s.UnmarshalData(data); err != nil {
+		return fmt.Errorf("failed to parse registry data (corrupted JSON or CBOR): %w", err)
+	}
+	s.mu.Lock()
+	s.etag, s.versionID = version.ETag, version.VersionID
+	s.mu.Unlock()
+	if s.cacheFile != "" {
+		if cached, err := s.MarshalData(); err == nil {
+			s.writeCache(cached)
+		}
+	}
+	return nil
+}
+
+// persist uploads the complete registry data to S3, unless another writer
+// changed the object since it was last downloaded or uploaded: the write is
+// then refused with ErrConflictRemoteModified and the data refreshed.
 // NOTE: This is called while BaseStorage holds the lock,
 // so we use marshalDataLocked() to avoid deadlock.
-func (s *S3Storage) persist() error {
-	ctx := context.Background()
+func (s *S3Storage) persist(ctx context.Context) error {
+	if s.deferPersist() {
+		return nil
+	}
 
 	data, err := s.marshalDataLocked()
 	if err != nil {
 		return fmt.Errorf("failed to marshal registry data: %w", err)
 	}
 
-	if err := s.client.Upload(ctx, data); err != nil {
+	uploaded, err := s.client.Upload(ctx, data, s.etag)
+	if err != nil {
+		if errors.Is(err, ErrConflictRemoteModified) {
+			s.logger.Warn("S3 object was modified by another writer, refreshing",
+				"bucket", s.bucket,
+				"key", s.key,
+				"error", err)
+			s.conflicts.trigger(s.logger, s.Refresh)
+		}
 		return err // Already categorized by S3Client
 	}
+	s.etag, s.versionID = uploaded.ETag, uploaded.VersionID
+	s.writeCache(data)
 
 	return nil
 }
@@ -173,6 +322,11 @@ func (s *S3Storage) UpdatePackage(ctx context.Context, registryName string, p *m
 	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
 }
 
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *S3Storage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
 // DeletePackage deletes a package and all its versions (atomic)
 func (s *S3Storage) DeletePackage(ctx context.Context, registryName, packageName string) error {
 	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
@@ -183,6 +337,56 @@ func (s *S3Storage) ListPackages(ctx context.Context, registryName string) ([]*m
 	return s.BaseStorage.ListPackages(ctx, registryName)
 }
 
+// FindPackages returns the packages whose custom values match a query
+func (s *S3Storage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *S3Storage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *S3Storage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *S3Storage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *S3Storage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *S3Storage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *S3Storage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *S3Storage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *S3Storage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *S3Storage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
 // CreateVersion creates a new version for a package
 func (s *S3Storage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
 	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
@@ -203,12 +407,32 @@ func (s *S3Storage) ListVersions(ctx context.Context, registryName, packageName
 	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
 }
 
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *S3Storage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *S3Storage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
 // GetRegistryIndex generates the registry index (Command Launcher format)
 func (s *S3Storage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
 	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
 }
 
-// Close closes the storage (no-op for S3 storage)
+// Changes returns the records changed since a generation (differential sync)
+func (s *S3Storage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *S3Storage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close flushes the pending writes of debounced mode
 func (s *S3Storage) Close() error {
-	return nil
+	return s.closeWriteBehind()
 }
@@ -8,11 +8,15 @@ import (
 	"log/slog"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/criteo/command-launcher-registry/internal/fips"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
 )
 
 // S3 timeout constants
@@ -21,21 +25,53 @@ const (
 	S3DownloadTimeout = 30 * time.Second
 )
 
+// S3ObjectVersion identifies a content of the S3 object. VersionID is empty
+// unless the bucket has versioning enabled.
+type S3ObjectVersion struct {
+	ETag      string
+	VersionID string
+}
+
 // S3Client wraps MinIO SDK for S3 operations
 type S3Client struct {
-	client *minio.Client
-	bucket string
-	key    string
-	logger *slog.Logger
+	client          *minio.Client
+	bucket          string
+	key             string
+	codec           Codec         // Sets the uploaded Content-Type
+	compression     Compression   // Applied to uploads; downloads detect it
+	envelope        *Envelope     // Encrypts uploads when set; downloads detect it
+	downloadTimeout time.Duration // Bounds Download (S3DownloadTimeout unless set longer for startup)
+	retrier         *retrier      // Retries failed operations (see RetryPolicy)
+	creds           *Credentials  // Tokens, with fallback to the secondary one; nil uses the static keys
+	logger          *slog.Logger
 }
 
 // NewS3Client creates a new S3 client for the given endpoint and credentials.
 func NewS3Client(endpoint, bucket, key, accessKey, secretKey string, useSSL bool, region string, logger *slog.Logger) (*S3Client, error) {
 	start := time.Now()
 
+	// Tag every S3 request with the request ID and operation behind it
+	// (see tracingTransport)
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, CategorizeS3Error(S3OpConnect, fmt.Errorf("failed to create S3 transport: %w", err))
+	}
+	fips.RestrictTLS(transport)
+
+	// Failed operations are retried as a whole by the retrier, like OCI
+	// ones, rather than by the SDK's own retry loop
+	c := &S3Client{
+		bucket:          bucket,
+		key:             key,
+		downloadTimeout: S3DownloadTimeout,
+		retrier:         newRetrier(DefaultRetryPolicy(), logger),
+		logger:          logger,
+	}
 	opts := &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
+		Creds:      credentials.New(&s3CredentialProvider{client: c, static: credentials.NewStaticV4(accessKey, secretKey, "")}),
+		Secure:     useSSL,
+		Transport:  newTracingTransport(transport, logger),
+		MaxRetries: 1,
 	}
 
 	// Set region if provided
@@ -61,22 +97,38 @@ func NewS3Client(endpoint, bucket, key, accessKey, secretKey string, useSSL bool
 		"region", region,
 		"duration_ms", time.Since(start).Milliseconds())
 
-	return &S3Client{
-		client: client,
-		bucket: bucket,
-		key:    key,
-		logger: logger,
-	}, nil
+	c.client = client
+	return c, nil
+}
+
+// SetCredentials makes the client authenticate with the ACCESS_KEY:SECRET_KEY
+// tokens of creds, falling back to their secondary token, instead of the
+// keys it was created with. It must be called before any operation.
+func (c *S3Client) SetCredentials(creds *Credentials) {
+	c.creds = creds
+	creds.setCheck(c.validateBucket)
+}
+
+// SetRetryPolicy changes how failed operations are retried
+func (c *S3Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retrier = newRetrier(policy, c.logger)
 }
 
 // ValidateBucket checks if the bucket exists and is accessible
 func (c *S3Client) ValidateBucket(ctx context.Context) error {
+	return c.retrier.do(ctx, S3OpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, c.validateBucket)
+	})
+}
+
+func (c *S3Client) validateBucket(ctx context.Context) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
 	start := time.Now()
-	c.logger.Debug("Validating S3 bucket", "bucket", c.bucket)
+	logger.Debug("Validating S3 bucket", "bucket", c.bucket)
 
 	exists, err := c.client.BucketExists(ctx, c.bucket)
 	if err != nil {
-		c.logger.Error("S3 bucket validation failed",
+		logger.Error("S3 bucket validation failed",
 			"bucket", c.bucket,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
@@ -84,13 +136,13 @@ func (c *S3Client) ValidateBucket(ctx context.Context) error {
 	}
 
 	if !exists {
-		c.logger.Error("S3 bucket does not exist",
+		logger.Error("S3 bucket does not exist",
 			"bucket", c.bucket,
 			"duration_ms", time.Since(start).Milliseconds())
 		return CategorizeS3Error(S3OpConnect, fmt.Errorf("bucket %q does not exist", c.bucket))
 	}
 
-	c.logger.Info("S3 bucket validated",
+	logger.Info("S3 bucket validated",
 		"bucket", c.bucket,
 		"duration_ms", time.Since(start).Milliseconds())
 	return nil
@@ -98,21 +150,34 @@ func (c *S3Client) ValidateBucket(ctx context.Context) error {
 
 // Exists checks if the object exists in the S3 bucket
 func (c *S3Client) Exists(ctx context.Context) (bool, error) {
+	var exists bool
+	err := c.retrier.do(ctx, S3OpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			exists, err = c.exists(ctx)
+			return err
+		})
+	})
+	return exists, err
+}
+
+func (c *S3Client) exists(ctx context.Context) (bool, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
 	start := time.Now()
-	c.logger.Debug("Checking S3 object existence", "bucket", c.bucket, "key", c.key)
+	logger.Debug("Checking S3 object existence", "bucket", c.bucket, "key", c.key)
 
 	_, err := c.client.StatObject(ctx, c.bucket, c.key, minio.StatObjectOptions{})
 	if err != nil {
 		// Check if it's a "not found" error
 		errResp := minio.ToErrorResponse(err)
 		if errResp.Code == "NoSuchKey" {
-			c.logger.Info("S3 object does not exist",
+			logger.Info("S3 object does not exist",
 				"bucket", c.bucket,
 				"key", c.key,
 				"duration_ms", time.Since(start).Milliseconds())
 			return false, nil
 		}
-		c.logger.Error("S3 existence check failed",
+		logger.Error("S3 existence check failed",
 			"bucket", c.bucket,
 			"key", c.key,
 			"error", err,
@@ -120,84 +185,378 @@ func (c *S3Client) Exists(ctx context.Context) (bool, error) {
 		return false, CategorizeS3Error(S3OpConnect, err)
 	}
 
-	c.logger.Info("S3 object exists",
+	logger.Info("S3 object exists",
 		"bucket", c.bucket,
 		"key", c.key,
 		"duration_ms", time.Since(start).Milliseconds())
 	return true, nil
 }
 
-// Upload uploads data to the S3 bucket
-func (c *S3Client) Upload(ctx context.Context, data []byte) error {
+// Upload uploads data to the S3 bucket, compressing it if the client was
+// configured to. Compressed objects carry a matching Content-Encoding.
+// The upload is refused with ErrConflictRemoteModified unless the object
+// still has the ETag ifMatch, or does not exist when ifMatch is empty.
+// Returns the ETag and version ID of the uploaded object.
+func (c *S3Client) Upload(ctx context.Context, data []byte, ifMatch string) (S3ObjectVersion, error) {
+	// Checked once rather than per attempt: a retried attempt would find
+	// the ETag of an earlier one that reached S3 despite failing
+	current, err := c.ETag(ctx)
+	if err != nil {
+		return S3ObjectVersion{}, err
+	}
+	if err := checkRemoteVersion(fmt.Sprintf("S3 object %s/%s", c.bucket, c.key), ifMatch, current); err != nil {
+		return S3ObjectVersion{}, err
+	}
+
+	// The check above fails early; the PUT itself is conditional on the
+	// state it saw, so a writer that got in between is not overwritten
+	cond := putCondition{ifMatch: current, ifAbsent: current == ""}
+	var uploaded S3ObjectVersion
+	err = c.retrier.do(ctx, S3OpUpload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			uploaded, err = c.upload(ctx, data, cond)
+			return err
+		})
+	})
+	return uploaded, err
+}
+
+// putCondition makes a PUT conditional on the state of the object, for
+// optimistic concurrency. The zero value overwrites the object whatever
+// its state.
+type putCondition struct {
+	ifMatch  string // ETag the object must still have (If-Match)
+	ifAbsent bool   // The object must not exist (If-None-Match: *)
+}
+
+func (cond putCondition) apply(opts *minio.PutObjectOptions) {
+	switch {
+	case cond.ifAbsent:
+		opts.SetMatchETagExcept("*")
+	case cond.ifMatch != "":
+		opts.SetMatchETag(cond.ifMatch)
+	}
+}
+
+// ETag returns the ETag of the object, empty when it does not exist
+func (c *S3Client) ETag(ctx context.Context) (string, error) {
+	var etag string
+	err := c.retrier.do(ctx, S3OpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			etag, err = c.etag(ctx)
+			return err
+		})
+	})
+	return etag, err
+}
+
+func (c *S3Client) etag(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.downloadTimeout)
+	defer cancel()
+
+	info, err := c.client.StatObject(ctx, c.bucket, c.key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return "", nil
+		}
+		return "", CategorizeS3Error(S3OpConnect, err)
+	}
+	return info.ETag, nil
+}
+
+func (c *S3Client) upload(ctx context.Context, data []byte, cond putCondition) (S3ObjectVersion, error) {
+	return c.put(ctx, c.key, data, cond)
+}
+
+// UploadObject uploads data to another key of the bucket, e.g. a backup,
+// compressed like the object. Unlike Upload, it overwrites the key whatever
+// its content.
+func (c *S3Client) UploadObject(ctx context.Context, key string, data []byte) error {
+	return c.retrier.do(ctx, S3OpUpload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			_, err := c.put(ctx, key, data, putCondition{})
+			return err
+		})
+	})
+}
+
+// ListObjects returns the keys of the bucket starting with prefix
+func (c *S3Client) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := c.retrier.do(ctx, S3OpList, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			keys = nil
+			for object := range c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+				if object.Err != nil {
+					return CategorizeS3Error(S3OpList, object.Err)
+				}
+				keys = append(keys, object.Key)
+			}
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// RemoveObject deletes key from the bucket
+func (c *S3Client) RemoveObject(ctx context.Context, key string) error {
+	return c.retrier.do(ctx, S3OpDelete, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			if err := c.client.RemoveObject(ctx, c.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+				return CategorizeS3Error(S3OpDelete, err)
+			}
+			return nil
+		})
+	})
+}
+
+// put uploads data to key
+func (c *S3Client) put(ctx context.Context, key string, data []byte, cond putCondition) (S3ObjectVersion, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
 	start := time.Now()
-	c.logger.Info("Starting S3 upload",
+	size := len(data)
+
+	data, err := compressData(c.compression, data)
+	if err != nil {
+		return S3ObjectVersion{}, CategorizeS3Error(S3OpUpload, err)
+	}
+	if data, err = c.envelope.Seal(data); err != nil {
+		return S3ObjectVersion{}, CategorizeS3Error(S3OpUpload, err)
+	}
+	logger.Info("Starting S3 upload",
 		"bucket", c.bucket,
-		"key", c.key,
-		"size_bytes", len(data))
+		"key", key,
+		"size_bytes", size,
+		"stored_bytes", len(data),
+		"compression", c.compression)
 
 	// Apply timeout
 	ctx, cancel := context.WithTimeout(ctx, S3UploadTimeout)
 	defer cancel()
 
+	putOpts := minio.PutObjectOptions{
+		ContentType: c.codec.ContentType(),
+	}
+	if c.compression.Enabled() {
+		putOpts.ContentEncoding = string(c.compression)
+	}
+	cond.apply(&putOpts)
+
 	reader := bytes.NewReader(data)
-	_, err := c.client.PutObject(ctx, c.bucket, c.key, reader, int64(len(data)),
-		minio.PutObjectOptions{
-			ContentType: "application/json",
-		},
-	)
+	info, err := c.client.PutObject(ctx, c.bucket, key, reader, int64(len(data)), putOpts)
 	if err != nil {
-		c.logger.Error("S3 upload failed",
+		// The precondition failed: another writer changed the object since
+		// it was checked. A retried attempt also ends here when an earlier
+		// one reached S3 despite failing; the refresh that follows a
+		// conflict then loads the data it wrote.
+		if code := minio.ToErrorResponse(err).Code; code == minio.PreconditionFailed || code == "ConditionalRequestConflict" {
+			logger.Warn("S3 upload refused by its precondition",
+				"bucket", c.bucket,
+				"key", key,
+				"error", err)
+			return S3ObjectVersion{}, fmt.Errorf("%w: S3 object %s/%s was modified by another writer", ErrConflictRemoteModified, c.bucket, key)
+		}
+		logger.Error("S3 upload failed",
 			"bucket", c.bucket,
-			"key", c.key,
+			"key", key,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return CategorizeS3Error(S3OpUpload, err)
+		return S3ObjectVersion{}, CategorizeS3Error(S3OpUpload, err)
 	}
 
-	c.logger.Info("S3 upload completed",
+	logger.Info("S3 upload completed",
 		"bucket", c.bucket,
-		"key", c.key,
-		"size_bytes", len(data),
+		"key", key,
+		"version_id", info.VersionID,
+		"size_bytes", size,
+		"stored_bytes", len(data),
 		"duration_ms", time.Since(start).Milliseconds())
-	return nil
+	return S3ObjectVersion{ETag: info.ETag, VersionID: info.VersionID}, nil
+}
+
+// Download downloads data from the S3 bucket, and returns it with the ETag
+// and version ID of the object
+func (c *S3Client) Download(ctx context.Context) ([]byte, S3ObjectVersion, error) {
+	var data []byte
+	var version S3ObjectVersion
+	err := c.retrier.do(ctx, S3OpDownload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			data, version, err = c.get(ctx, c.key, "")
+			return err
+		})
+	})
+	return data, version, err
+}
+
+// DownloadVersion downloads a prior version of the object, in a bucket with
+// versioning enabled
+func (c *S3Client) DownloadVersion(ctx context.Context, versionID string) ([]byte, error) {
+	var data []byte
+	err := c.retrier.do(ctx, S3OpDownload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			data, _, err = c.get(ctx, c.key, versionID)
+			return err
+		})
+	})
+	return data, err
+}
+
+// ListVersions returns the versions of the object, most recent first. It is
+// empty unless the bucket has versioning enabled; deleted versions are left
+// out.
+func (c *S3Client) ListVersions(ctx context.Context) ([]DataVersion, error) {
+	var versions []DataVersion
+	err := c.retrier.do(ctx, S3OpList, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			versions = nil
+			for object := range c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: c.key, WithVersions: true}) {
+				if object.Err != nil {
+					return CategorizeS3Error(S3OpList, object.Err)
+				}
+				// Unversioned buckets list the object with the "null" version
+				if object.Key != c.key || object.IsDeleteMarker || object.VersionID == "" || object.VersionID == "null" {
+					continue
+				}
+				versions = append(versions, DataVersion{
+					ID:         object.VersionID,
+					ModifiedAt: object.LastModified.UTC(),
+					Size:       object.Size,
+					Current:    object.IsLatest,
+				})
+			}
+			return nil
+		})
+	})
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].ModifiedAt.After(versions[j].ModifiedAt) })
+	return versions, err
 }
 
-// Download downloads data from the S3 bucket
-func (c *S3Client) Download(ctx context.Context) ([]byte, error) {
+// DownloadObject downloads another key of the bucket, e.g. a backup,
+// decompressed like the object
+func (c *S3Client) DownloadObject(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := c.retrier.do(ctx, S3OpDownload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			data, _, err = c.get(ctx, key, "")
+			return err
+		})
+	})
+	return data, err
+}
+
+// get downloads key, decompressed, with its ETag and version ID. versionID
+// selects a prior version; empty gets the current one.
+func (c *S3Client) get(ctx context.Context, key, versionID string) ([]byte, S3ObjectVersion, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
 	start := time.Now()
-	c.logger.Debug("Starting S3 download", "bucket", c.bucket, "key", c.key)
+	logger.Debug("Starting S3 download", "bucket", c.bucket, "key", key, "version_id", versionID)
 
 	// Apply timeout
-	ctx, cancel := context.WithTimeout(ctx, S3DownloadTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.downloadTimeout)
 	defer cancel()
 
-	obj, err := c.client.GetObject(ctx, c.bucket, c.key, minio.GetObjectOptions{})
+	obj, err := c.client.GetObject(ctx, c.bucket, key, minio.GetObjectOptions{VersionID: versionID})
 	if err != nil {
-		c.logger.Error("S3 download failed",
+		logger.Error("S3 download failed",
 			"bucket", c.bucket,
-			"key", c.key,
+			"key", key,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return nil, CategorizeS3Error(S3OpDownload, err)
+		return nil, S3ObjectVersion{}, CategorizeS3Error(S3OpDownload, err)
 	}
 	defer obj.Close()
 
-	data, err := io.ReadAll(obj)
+	// Stat issues the request; its size lets progress be reported
+	info, err := obj.Stat()
 	if err != nil {
-		c.logger.Error("S3 download read failed",
+		logger.Error("S3 download failed",
 			"bucket", c.bucket,
-			"key", c.key,
+			"key", key,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return nil, S3ObjectVersion{}, CategorizeS3Error(S3OpDownload, err)
+	}
+	logger.Info("Downloading S3 object",
+		"bucket", c.bucket,
+		"key", key,
+		"total_bytes", info.Size)
+
+	stored, err := io.ReadAll(newProgressReader(obj, info.Size, logger, "bucket", c.bucket, "key", key))
+	if err != nil {
+		logger.Error("S3 download read failed",
+			"bucket", c.bucket,
+			"key", key,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds())
-		return nil, CategorizeS3Error(S3OpDownload, err)
+		return nil, S3ObjectVersion{}, CategorizeS3Error(S3OpDownload, err)
+	}
+
+	opened, err := c.envelope.Open(stored)
+	if err != nil {
+		logger.Error("S3 download decryption failed",
+			"bucket", c.bucket,
+			"key", key,
+			"error", err)
+		return nil, S3ObjectVersion{}, CategorizeS3Error(S3OpDownload, err)
+	}
+	data, compression, err := decompressData(opened)
+	if err != nil {
+		logger.Error("S3 download decompression failed",
+			"bucket", c.bucket,
+			"key", key,
+			"compression", compression,
+			"error", err)
+		return nil, S3ObjectVersion{}, CategorizeS3Error(S3OpDownload, err)
 	}
 
-	c.logger.Info("S3 download completed",
+	logger.Info("S3 download completed",
 		"bucket", c.bucket,
-		"key", c.key,
+		"key", key,
+		"version_id", info.VersionID,
 		"size_bytes", len(data),
+		"stored_bytes", len(stored),
+		"compression", compression,
 		"duration_ms", time.Since(start).Milliseconds())
-	return data, nil
+	return data, S3ObjectVersion{ETag: info.ETag, VersionID: info.VersionID}, nil
+}
+
+// s3CredentialProvider supplies the keys of the token in use, or the
+// client's static keys when it has no Credentials. The SDK retrieves them
+// again whenever the token in use changes.
+type s3CredentialProvider struct {
+	client     *S3Client
+	static     *credentials.Credentials
+	generation uint64 // Of the keys last retrieved
+}
+
+func (p *s3CredentialProvider) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithCredContext(nil)
+}
+
+func (p *s3CredentialProvider) RetrieveWithCredContext(cc *credentials.CredContext) (credentials.Value, error) {
+	if p.client.creds == nil {
+		return p.static.GetWithContext(cc)
+	}
+	token, generation := p.client.creds.current()
+	accessKey, secretKey, err := ParseS3Token(token)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	p.generation = generation
+	return credentials.NewStaticV4(accessKey, secretKey, "").GetWithContext(cc)
+}
+
+func (p *s3CredentialProvider) IsExpired() bool {
+	if p.client.creds == nil {
+		return false
+	}
+	_, generation := p.client.creds.current()
+	return generation != p.generation
 }
 
 // ParseS3Token parses the storage token into access key and secret key.
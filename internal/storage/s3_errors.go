@@ -22,13 +22,16 @@ const (
 	S3OpUpload   = "upload"
 	S3OpDownload = "download"
 	S3OpConnect  = "connect"
+	S3OpList     = "list"
+	S3OpDelete   = "delete"
 )
 
 // S3Error wraps S3-specific failures with categorization
 type S3Error struct {
-	Category string // "authentication", "network", or "storage"
-	Op       string // "upload", "download", or "connect"
-	Err      error  // Underlying error
+	Category  string // "authentication", "network", or "storage"
+	Op        string // "upload", "download", "connect", "list" or "delete"
+	Err       error  // Underlying error
+	Retryable bool   // The operation may succeed if retried (see RetryPolicy)
 }
 
 // Error implements the error interface
@@ -58,9 +61,10 @@ func NewS3AuthError(op string, err error) *S3Error {
 // NewS3NetworkError creates a network-related S3 error
 func NewS3NetworkError(op string, err error) *S3Error {
 	return &S3Error{
-		Category: S3CategoryNetwork,
-		Op:       op,
-		Err:      err,
+		Category:  S3CategoryNetwork,
+		Op:        op,
+		Err:       err,
+		Retryable: true,
 	}
 }
 
@@ -150,10 +154,14 @@ func categorizeMinioError(op string, minioErr minio.ErrorResponse) *S3Error {
 		return NewS3StorageError(op, fmt.Errorf("bucket not found: verify bucket exists and name is correct"))
 	case "NoSuchKey":
 		return NewS3StorageError(op, fmt.Errorf("object not found"))
-	case "InternalError", "ServiceUnavailable":
-		return NewS3StorageError(op, fmt.Errorf("S3 service unavailable: %s", minioErr.Message))
+	case "InternalError", "ServiceUnavailable", "SlowDown", "RequestTimeout":
+		s3Err := NewS3StorageError(op, fmt.Errorf("S3 service unavailable: %s", minioErr.Message))
+		s3Err.Retryable = true
+		return s3Err
 	default:
-		return NewS3StorageError(op, fmt.Errorf("%s: %s", minioErr.Code, minioErr.Message))
+		s3Err := NewS3StorageError(op, fmt.Errorf("%s: %s", minioErr.Code, minioErr.Message))
+		s3Err.Retryable = minioErr.StatusCode >= 500 || minioErr.StatusCode == 429 || minioErr.StatusCode == 408
+		return s3Err
 	}
 }
 
@@ -23,7 +23,7 @@ func TestNewS3Storage_InvalidScheme(t *testing.T) {
 		Raw:    "file://./test/data.json",
 	}
 
-	_, err := NewS3Storage(uri, "access:secret", logger)
+	_, err := NewS3Storage(uri, "access:secret", Options{}, logger)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "expected S3 URI")
 }
@@ -36,7 +36,7 @@ func TestFactory_NewStorage_S3Scheme(t *testing.T) {
 	require.NoError(t, err)
 
 	// Factory should route to S3Storage (will fail to connect, but tests routing)
-	_, err = NewStorage(uri, "access:secret", logger)
+	_, err = NewStorage(uri, "access:secret", Options{}, logger)
 	// Error expected because we can't connect to S3
 	require.Error(t, err)
 	// But it should be an S3 error, not "unsupported scheme"
@@ -51,7 +51,7 @@ func TestFactory_NewStorage_S3HttpScheme(t *testing.T) {
 	require.NoError(t, err)
 
 	// Factory should route to S3Storage (will fail to connect, but tests routing)
-	_, err = NewStorage(uri, "access:secret", logger)
+	_, err = NewStorage(uri, "access:secret", Options{}, logger)
 	// Error expected because we can't connect to MinIO
 	require.Error(t, err)
 	// But it should be an S3 error, not "unsupported scheme"
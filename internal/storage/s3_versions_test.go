@@ -0,0 +1,82 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestS3Storage_Versions(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "bucket")
+	s3.EnableVersioning()
+	uri, err := storage.ParseStorageURI(strings.Replace(s3.URL, "http://", "s3+http://", 1) + "/bucket/registry.json?region=us-east-1")
+	require.NoError(t, err)
+	store, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", storage.Options{Retry: storage.RetryPolicy{MaxAttempts: 1}}, newConformanceLogger())
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	current, err := store.CurrentVersion(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)))
+	_, before, err := store.Changes(ctx, 0)
+	require.NoError(t, err)
+
+	versions, err := store.Versions(ctx)
+	require.NoError(t, err)
+	require.Len(t, versions, 3) // Initial empty data, then one per write
+	assert.True(t, versions[0].Current)
+	assert.Equal(t, current, versions[1].ID)
+	latest, err := store.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, versions[0].ID, latest)
+
+	_, err = store.Rollback(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrVersionNotFound)
+
+	// Rolling back uploads the prior data as a new version
+	generation, err := store.Rollback(ctx, current)
+	require.NoError(t, err)
+	assert.Equal(t, before+1, generation)
+	_, err = store.GetRegistry(ctx, "games")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.GetRegistry(ctx, "tools")
+	assert.NoError(t, err)
+
+	rolledBack, err := store.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, latest, rolledBack)
+	versions, err = store.Versions(ctx)
+	require.NoError(t, err)
+	assert.Len(t, versions, 4)
+	assert.Equal(t, rolledBack, versions[0].ID)
+
+	// Another instance loads the rolled back data
+	reopened, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", storage.Options{}, newConformanceLogger())
+	require.NoError(t, err)
+	registries, err := reopened.ListRegistries(ctx)
+	require.NoError(t, err)
+	assert.Len(t, registries, 1)
+}
+
+func TestS3Storage_VersionsUnversionedBucket(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "bucket")
+	uri, err := storage.ParseStorageURI(strings.Replace(s3.URL, "http://", "s3+http://", 1) + "/bucket/registry.json?region=us-east-1")
+	require.NoError(t, err)
+	store, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", storage.Options{Retry: storage.RetryPolicy{MaxAttempts: 1}}, newConformanceLogger())
+	require.NoError(t, err)
+
+	current, err := store.CurrentVersion(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, current)
+	versions, err := store.Versions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite" // Pure Go driver, registered as "sqlite"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// sqliteSchemaVersion is stored in PRAGMA user_version
+const sqliteSchemaVersion = 1
+
+// sqliteSchema holds the record rows (see rows.go)
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS registries (
+	name TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS packages (
+	registry TEXT NOT NULL REFERENCES registries (name) ON DELETE CASCADE,
+	name     TEXT NOT NULL,
+	data     TEXT NOT NULL,
+	PRIMARY KEY (registry, name)
+);
+CREATE TABLE IF NOT EXISTS versions (
+	registry TEXT NOT NULL,
+	package  TEXT NOT NULL,
+	version  TEXT NOT NULL,
+	data     TEXT NOT NULL,
+	PRIMARY KEY (registry, package, version),
+	FOREIGN KEY (registry, package) REFERENCES packages (registry, name) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS state (
+	key  TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+`
+
+// SQLiteStorage implements Store interface using a SQLite database.
+// It embeds BaseStorage for in-memory CRUD operations and persists the
+// records that changed in one transaction per write.
+type SQLiteStorage struct {
+	*BaseStorage // Embedded for shared CRUD logic
+	db           *sql.DB
+	path         string
+
+	// written holds the JSON of each row as last committed, so a write only
+	// touches the rows that changed. Guarded by the BaseStorage lock.
+	written map[recordRow]string
+}
+
+// NewSQLiteStorage opens (creating it if needed) the SQLite database at
+// path, in WAL mode. The token is accepted but ignored, as for file storage.
+func NewSQLiteStorage(path string, token string, opts Options, logger *slog.Logger) (*SQLiteStorage, error) {
+	if token != "" {
+		logger.Warn("Storage token provided but SQLite storage does not use authentication",
+			"file_path", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	// A single connection serializes writers in the process; readers are
+	// served from memory
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStorage{
+		BaseStorage: NewBaseStorage(logger),
+		db:          db,
+		path:        path,
+		written:     make(map[recordRow]string),
+	}
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+	return s, nil
+}
+
+// sqliteDSN builds the connection string: WAL journal, foreign keys, and
+// a busy timeout for tools reading the database concurrently
+func sqliteDSN(path string) string {
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_pragma", "foreign_keys(ON)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Set("_txlock", "immediate")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// migrate creates the schema, refusing databases written by a newer server
+func (s *SQLiteStorage) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read SQLite schema version: %w", err)
+	}
+	if version > sqliteSchemaVersion {
+		return fmt.Errorf("SQLite schema version %d is newer than supported version %d", version, sqliteSchemaVersion)
+	}
+	if _, err := s.db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("failed to create SQLite schema: %w", err)
+	}
+	if _, err := s.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion)); err != nil {
+		return fmt.Errorf("failed to set SQLite schema version: %w", err)
+	}
+	return nil
+}
+
+// load reads all rows and nests them into a document, decoded like the
+// other backends' so invalid records are quarantined
+func (s *SQLiteStorage) load() error {
+	rows, err := readRows(context.Background(), s.db, sqliteQueries)
+	if err != nil {
+		return fmt.Errorf("failed to read SQLite database: %w", err)
+	}
+	encoded, err := assembleRows(rows)
+	if err != nil {
+		return fmt.Errorf("failed to assemble SQLite rows: %w", err)
+	}
+	if err := s.UnmarshalData(encoded); err != nil {
+		return fmt.Errorf("failed to decode SQLite rows: %w", err)
+	}
+	s.written = rows
+
+	data := s.GetData()
+	s.logger.Info("SQLite storage loaded",
+		"file_path", s.path,
+		"registry_count", len(data.Registries))
+	return nil
+}
+
+// persist is the callback passed to BaseStorage methods: it writes the rows
+// that changed since the last commit in a single transaction
+func (s *SQLiteStorage) persist(ctx context.Context) error {
+	rows, err := encodeRows(s.getDataLocked())
+	if err != nil {
+		return fmt.Errorf("failed to encode rows: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := writeRows(ctx, tx, sqliteQueries, s.written, rows); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.written = rows
+	return nil
+}
+
+var sqliteQueries = rowQueries{
+	selects: map[recordTable]string{
+		tableRegistries: "SELECT name, data FROM registries",
+		tablePackages:   "SELECT registry, name, data FROM packages",
+		tableVersions:   "SELECT registry, package, version, data FROM versions",
+		tableState:      "SELECT key, data FROM state",
+	},
+	upserts: map[recordTable]string{
+		tableRegistries: "INSERT INTO registries (name, data) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET data = excluded.data",
+		tablePackages:   "INSERT INTO packages (registry, name, data) VALUES (?, ?, ?) ON CONFLICT (registry, name) DO UPDATE SET data = excluded.data",
+		tableVersions:   "INSERT INTO versions (registry, package, version, data) VALUES (?, ?, ?, ?) ON CONFLICT (registry, package, version) DO UPDATE SET data = excluded.data",
+		tableState:      "INSERT INTO state (key, data) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET data = excluded.data",
+	},
+	deletes: map[recordTable]string{
+		tableRegistries: "DELETE FROM registries WHERE name = ?",
+		tablePackages:   "DELETE FROM packages WHERE registry = ? AND name = ?",
+		tableVersions:   "DELETE FROM versions WHERE registry = ? AND package = ? AND version = ?",
+		tableState:      "DELETE FROM state WHERE key = ?",
+	},
+}
+
+// CreateRegistry creates a new registry
+func (s *SQLiteStorage) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.CreateRegistry(ctx, r, s.persist)
+}
+
+// GetRegistry retrieves a registry by name
+func (s *SQLiteStorage) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	return s.BaseStorage.GetRegistry(ctx, name)
+}
+
+// UpdateRegistry updates registry metadata
+func (s *SQLiteStorage) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.UpdateRegistry(ctx, r, s.persist)
+}
+
+// DeleteRegistry deletes a registry and all its packages (atomic)
+func (s *SQLiteStorage) DeleteRegistry(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteRegistry(ctx, name, s.persist)
+}
+
+// ListRegistries returns all registries
+func (s *SQLiteStorage) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	return s.BaseStorage.ListRegistries(ctx)
+}
+
+// CreatePackage creates a new package in a registry
+func (s *SQLiteStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.CreatePackage(ctx, registryName, p, s.persist)
+}
+
+// GetPackage retrieves a package from a registry
+func (s *SQLiteStorage) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	return s.BaseStorage.GetPackage(ctx, registryName, packageName)
+}
+
+// UpdatePackage updates package metadata (preserves versions)
+func (s *SQLiteStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
+}
+
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *SQLiteStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
+// DeletePackage deletes a package and all its versions (atomic)
+func (s *SQLiteStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
+}
+
+// ListPackages returns all packages in a registry
+func (s *SQLiteStorage) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	return s.BaseStorage.ListPackages(ctx, registryName)
+}
+
+// FindPackages returns the packages whose custom values match a query
+func (s *SQLiteStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *SQLiteStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *SQLiteStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *SQLiteStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *SQLiteStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *SQLiteStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *SQLiteStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *SQLiteStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *SQLiteStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *SQLiteStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
+// CreateVersion creates a new version for a package
+func (s *SQLiteStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
+}
+
+// GetVersion retrieves a specific version
+func (s *SQLiteStorage) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
+	return s.BaseStorage.GetVersion(ctx, registryName, packageName, version)
+}
+
+// DeleteVersion deletes a specific version
+func (s *SQLiteStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.DeleteVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// ListVersions returns all versions for a package
+func (s *SQLiteStorage) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
+	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
+}
+
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *SQLiteStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *SQLiteStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// GetRegistryIndex generates the registry index (Command Launcher format)
+func (s *SQLiteStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
+	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
+}
+
+// Changes returns the records changed since a generation (differential sync)
+func (s *SQLiteStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *SQLiteStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close checkpoints the WAL and closes the database
+func (s *SQLiteStorage) Close() error {
+	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		s.logger.Warn("Failed to checkpoint SQLite WAL", "file_path", s.path, "error", err)
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+var checksumB = "sha256:" + strings.Repeat("b", 64)
+
+func newTestSQLiteStorage(t *testing.T, path string) *SQLiteStorage {
+	t.Helper()
+	s, err := NewSQLiteStorage(path, "", Options{}, slog.Default())
+	require.NoError(t, err)
+	return s
+}
+
+func countRows(t *testing.T, path, table string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	require.NoError(t, err)
+	defer db.Close()
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&n))
+	return n
+}
+
+func TestSQLiteStorage_PersistAndReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "registry.db")
+
+	s := newTestSQLiteStorage(t, path)
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "Tools", []string{"admin@example.com"}, nil)))
+	require.NoError(t, s.CreatePackage(ctx, "reg", models.NewPackage("pkg", "A package", nil, map[string]string{"team": "infra"})))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 4)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "2.0.0", checksumB, "https://x/2.zip", 5, 9)))
+	require.NoError(t, s.PutCertificate(ctx, "acme_account", []byte("key")))
+	require.NoError(t, s.Close())
+
+	assert.Equal(t, 1, countRows(t, path, "registries"))
+	assert.Equal(t, 1, countRows(t, path, "packages"))
+	assert.Equal(t, 2, countRows(t, path, "versions"))
+
+	s = newTestSQLiteStorage(t, path)
+	defer s.Close()
+	pkg, err := s.GetPackage(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.Equal(t, "infra", pkg.CustomValues["team"])
+	assert.Len(t, pkg.Versions, 2)
+	cert, err := s.GetCertificate(ctx, "acme_account")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key"), cert)
+	history, err := s.GetPackageHistory(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.NotEmpty(t, history)
+
+	// Deletes remove the rows below the deleted record
+	require.NoError(t, s.DeleteVersion(ctx, "reg", "pkg", "1.0.0"))
+	assert.Equal(t, 1, countRows(t, path, "versions"))
+	require.NoError(t, s.DeleteRegistry(ctx, "reg"))
+	assert.Equal(t, 0, countRows(t, path, "registries"))
+	assert.Equal(t, 0, countRows(t, path, "packages"))
+	assert.Equal(t, 0, countRows(t, path, "versions"))
+}
+
+func TestSQLiteStorage_QuarantinesInvalidRows(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "registry.db")
+
+	s := newTestSQLiteStorage(t, path)
+	require.NoError(t, s.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil)))
+	require.NoError(t, s.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", checksumA, "https://x/1.zip", 0, 4)))
+	require.NoError(t, s.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "2.0.0", checksumB, "https://x/2.zip", 5, 9)))
+	require.NoError(t, s.Close())
+
+	// Edited by hand into something that is not a version
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE versions SET data = 'not json' WHERE version = '2.0.0'")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s = newTestSQLiteStorage(t, path)
+	defer s.Close()
+	versions, err := s.ListVersions(ctx, "reg", "pkg")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+	quarantined, err := s.GetQuarantine(ctx)
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, "reg/pkg/2.0.0", quarantined[0].Key)
+}
+
+func TestSQLiteStorage_RefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.db")
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	require.NoError(t, err)
+	_, err = db.Exec("PRAGMA user_version = 99")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	_, err = NewSQLiteStorage(path, "", Options{}, slog.Default())
+	assert.ErrorContains(t, err, "newer than supported")
+}
@@ -22,8 +22,72 @@ var (
 
 	// ErrPartitionOverlap is returned when version partition ranges overlap
 	ErrPartitionOverlap = errors.New("partition ranges overlap")
+
+	// ErrEncryptionNotConfigured is returned when sensitive values must be encrypted but no key is configured
+	ErrEncryptionNotConfigured = errors.New("encryption key not configured")
+
+	// ErrNotScheduled is returned when a version is not (or no longer) embargoed
+	ErrNotScheduled = errors.New("version not scheduled")
+
+	// ErrGenerationAhead is returned when a sync generation is newer than the store's
+	ErrGenerationAhead = errors.New("sync generation ahead of storage")
+
+	// ErrTimeout is returned when a storage operation does not complete
+	// within its deadline
+	ErrTimeout = errors.New("storage operation timed out")
+
+	// ErrLoadTimeout is returned when the initial load of the storage data takes too long
+	ErrLoadTimeout = errors.New("storage load timed out")
+
+	// ErrConflictRemoteModified is returned when a write is refused because
+	// another writer changed the stored data since this instance loaded it
+	ErrConflictRemoteModified = errors.New("stored data modified by another writer")
+
+	// ErrBackupNotFound is returned when restoring a backup that does not exist
+	ErrBackupNotFound = errors.New("backup not found")
+
+	// ErrVersionNotFound is returned when rolling back to a storage version
+	// the backend does not keep
+	ErrVersionNotFound = errors.New("storage version not found")
 )
 
+// Error categories reported by ErrorCategory, beyond the backend ones
+const (
+	CategoryTimeout = "timeout"
+	CategoryData    = "data"
+)
+
+// ErrorCategory classifies a storage initialization error for operators:
+// "authentication", "network" or "storage" for categorized backend errors,
+// "timeout" for ErrLoadTimeout, and "data" for anything else (typically
+// data that could not be decoded).
+func ErrorCategory(err error) string {
+	var ociErr *OCIError
+	var s3Err *S3Error
+	var gcsErr *GCSError
+	var gitErr *GitError
+	var dynamoErr *DynamoDBError
+	var webdavErr *WebDAVError
+	switch {
+	case errors.As(err, &ociErr):
+		return ociErr.Category
+	case errors.As(err, &s3Err):
+		return s3Err.Category
+	case errors.As(err, &gcsErr):
+		return gcsErr.Category
+	case errors.As(err, &gitErr):
+		return gitErr.Category
+	case errors.As(err, &dynamoErr):
+		return dynamoErr.Category
+	case errors.As(err, &webdavErr):
+		return webdavErr.Category
+	case errors.Is(err, ErrLoadTimeout):
+		return CategoryTimeout
+	default:
+		return CategoryData
+	}
+}
+
 // Store defines the interface for storage operations
 type Store interface {
 	// Registry operations
@@ -37,8 +101,11 @@ type Store interface {
 	CreatePackage(ctx context.Context, registryName string, p *models.Package) error
 	GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error)
 	UpdatePackage(ctx context.Context, registryName string, p *models.Package) error
+	UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error
 	DeletePackage(ctx context.Context, registryName, packageName string) error
 	ListPackages(ctx context.Context, registryName string) ([]*models.Package, error)
+	FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error)
+	GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error)
 
 	// Version operations
 	CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error
@@ -46,9 +113,31 @@ type Store interface {
 	DeleteVersion(ctx context.Context, registryName, packageName, version string) error
 	ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error)
 
+	// Scheduled publishing (embargoed versions)
+	ReleaseVersion(ctx context.Context, registryName, packageName, version string) error
+	CancelVersion(ctx context.Context, registryName, packageName, version string) error
+
 	// Index generation
 	GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error)
 
+	// Differential sync: records changed after generation since, and the current generation
+	Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error)
+
+	// Records set aside while loading because they could not be served
+	GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error)
+
+	// TLS certificates and ACME account keys, kept with the data so every
+	// instance sharing the backend uses the same ones
+	GetCertificate(ctx context.Context, name string) ([]byte, error)
+	PutCertificate(ctx context.Context, name string, data []byte) error
+	DeleteCertificate(ctx context.Context, name string) error
+
+	// API keys restricted to publishing in one registry, deleted with it
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, registryName, id string) error
+
 	// Close closes the storage
 	Close() error
 }
@@ -0,0 +1,634 @@
+// Package storagetest is the conformance suite of storage.Store: the
+// behaviour every backend must share, whatever it persists to. Each backend
+// runs it from its own tests with a Factory opening an empty store:
+//
+//	func TestFileStorage_Conformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) *storagetest.Backend { ... })
+//	}
+//
+// The suite covers CRUD and its errors, version immutability, index
+// generation, persistence across reopening, rollback when persisting fails,
+// and concurrent writers.
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// Backend is a store under test
+type Backend struct {
+	// Store is the store, empty when the backend is opened
+	Store storage.Store
+
+	// Reopen closes the store and opens a new one over the same persisted
+	// data, as a restarted server would
+	Reopen func(t *testing.T) storage.Store
+
+	// FailWrites makes every write to the persisted data fail until called
+	// again with false, leaving the data already persisted untouched. Nil
+	// skips the rollback tests.
+	FailWrites func(t *testing.T, fail bool)
+}
+
+// Factory opens a backend over empty data. It must register the cleanup
+// closing the backend's last store.
+type Factory func(t *testing.T) *Backend
+
+// concurrentWriters is the number of goroutines of the concurrency tests
+const concurrentWriters = 8
+
+// Run runs the conformance suite against the backends opened by open, each
+// subtest with its own backend
+func Run(t *testing.T, open Factory) {
+	t.Run("Registries", func(t *testing.T) { testRegistries(t, open(t)) })
+	t.Run("Packages", func(t *testing.T) { testPackages(t, open(t)) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, open(t)) })
+	t.Run("ScheduledVersions", func(t *testing.T) { testScheduledVersions(t, open(t)) })
+	t.Run("Index", func(t *testing.T) { testIndex(t, open(t)) })
+	t.Run("Timestamps", func(t *testing.T) { testTimestamps(t, open(t)) })
+	t.Run("CertificatesAndAPIKeys", func(t *testing.T) { testCertificatesAndAPIKeys(t, open(t)) })
+	t.Run("Persistence", func(t *testing.T) { testPersistence(t, open(t)) })
+	t.Run("Rollback", func(t *testing.T) {
+		backend := open(t)
+		if backend.FailWrites == nil {
+			t.Skip("backend cannot fail writes on demand")
+		}
+		testRollback(t, backend)
+	})
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, open(t)) })
+}
+
+func testRegistries(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+
+	registries, err := store.ListRegistries(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, registries)
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Tools", []string{"alice"}, map[string]string{"team": "infra"})))
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("games", "Games", nil, nil)))
+	assert.ErrorIs(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Again", nil, nil)), storage.ErrAlreadyExists)
+
+	registry, err := store.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	assert.Equal(t, "Tools", registry.Description)
+	assert.Equal(t, []string{"alice"}, registry.Admins)
+	assert.Equal(t, "infra", registry.CustomValues["team"])
+	_, err = store.GetRegistry(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	registries, err = store.ListRegistries(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tools", "games"}, registryNames(registries))
+
+	// Updates keep the packages
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("hammer", "Hammer", nil, nil)))
+	require.NoError(t, store.UpdateRegistry(ctx, models.NewRegistry("tools", "All the tools", []string{"bob"}, nil)))
+	registry, err = store.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	assert.Equal(t, "All the tools", registry.Description)
+	assert.Equal(t, []string{"bob"}, registry.Admins)
+	assert.Contains(t, registry.Packages, "hammer")
+	assert.ErrorIs(t, store.UpdateRegistry(ctx, models.NewRegistry("missing", "", nil, nil)), storage.ErrNotFound)
+
+	// Deleting a registry deletes its packages
+	require.NoError(t, store.DeleteRegistry(ctx, "tools"))
+	_, err = store.GetRegistry(ctx, "tools")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.GetPackage(ctx, "tools", "hammer")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteRegistry(ctx, "tools"), storage.ErrNotFound)
+
+	// A registry created again starts empty
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Tools", nil, nil)))
+	packages, err := store.ListPackages(ctx, "tools")
+	require.NoError(t, err)
+	assert.Empty(t, packages)
+}
+
+func testPackages(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+
+	assert.ErrorIs(t, store.CreatePackage(ctx, "tools", models.NewPackage("hammer", "", nil, nil)), storage.ErrNotFound)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Tools", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("hammer", "Hammer", []string{"alice"}, map[string]string{"team": "infra"})))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("saw", "Saw", nil, map[string]string{"team": "wood"})))
+	assert.ErrorIs(t, store.CreatePackage(ctx, "tools", models.NewPackage("hammer", "Again", nil, nil)), storage.ErrAlreadyExists)
+
+	pkg, err := store.GetPackage(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	assert.Equal(t, "Hammer", pkg.Description)
+	assert.Equal(t, []string{"alice"}, pkg.Maintainers)
+	_, err = store.GetPackage(ctx, "tools", "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.GetPackage(ctx, "missing", "hammer")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	packages, err := store.ListPackages(ctx, "tools")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"hammer", "saw"}, packageNames(packages))
+	_, err = store.ListPackages(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	found, err := store.FindPackages(ctx, "tools", models.CustomValueQuery{"team": {"infra"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hammer"}, packageNames(found))
+
+	// Updates replace the metadata
+	updated := models.NewPackage("hammer", "Claw hammer", []string{"bob"}, map[string]string{"team": "wood"})
+	require.NoError(t, store.UpdatePackage(ctx, "tools", updated))
+	pkg, err = store.GetPackage(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	assert.Equal(t, "Claw hammer", pkg.Description)
+	assert.Equal(t, []string{"bob"}, pkg.Maintainers)
+	found, err = store.FindPackages(ctx, "tools", models.CustomValueQuery{"team": {"wood"}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"hammer", "saw"}, packageNames(found))
+	assert.ErrorIs(t, store.UpdatePackage(ctx, "tools", models.NewPackage("missing", "", nil, nil)), storage.ErrNotFound)
+
+	// Batch updates apply all or nothing
+	err = store.UpdatePackages(ctx, "tools", []*models.Package{
+		models.NewPackage("saw", "Hand saw", nil, nil),
+		models.NewPackage("missing", "", nil, nil),
+	})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	pkg, err = store.GetPackage(ctx, "tools", "saw")
+	require.NoError(t, err)
+	assert.Equal(t, "Saw", pkg.Description)
+	require.NoError(t, store.UpdatePackages(ctx, "tools", []*models.Package{
+		models.NewPackage("saw", "Hand saw", nil, nil),
+		models.NewPackage("hammer", "Sledgehammer", nil, nil),
+	}))
+	pkg, err = store.GetPackage(ctx, "tools", "saw")
+	require.NoError(t, err)
+	assert.Equal(t, "Hand saw", pkg.Description)
+
+	// Histories record the changes, newest first
+	history, err := store.GetPackageHistory(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	assert.Equal(t, models.ChangePackageUpdated, history[0].Type)
+	assert.Equal(t, models.ChangePackageCreated, history[len(history)-1].Type)
+
+	// Deleting a package deletes its versions
+	require.NoError(t, store.CreateVersion(ctx, "tools", "hammer", newVersion("hammer", "1.0.0", 0, 9)))
+	require.NoError(t, store.DeletePackage(ctx, "tools", "hammer"))
+	_, err = store.GetPackage(ctx, "tools", "hammer")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.ListVersions(ctx, "tools", "hammer")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, store.DeletePackage(ctx, "tools", "hammer"), storage.ErrNotFound)
+}
+
+func testVersions(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Tools", nil, nil)))
+	assert.ErrorIs(t, store.CreateVersion(ctx, "tools", "hammer", newVersion("hammer", "1.0.0", 0, 9)), storage.ErrNotFound)
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("hammer", "Hammer", nil, nil)))
+	require.NoError(t, store.CreateVersion(ctx, "tools", "hammer", newVersion("hammer", "1.0.0", 0, 9)))
+
+	version, err := store.GetVersion(ctx, "tools", "hammer", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, newVersion("hammer", "1.0.0", 0, 9), version)
+	_, err = store.GetVersion(ctx, "tools", "hammer", "9.9.9")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Versions are immutable, and partitions of different versions must not overlap
+	changed := newVersion("hammer", "1.0.0", 0, 9)
+	changed.URL = "https://example.com/other.pkg"
+	assert.ErrorIs(t, store.CreateVersion(ctx, "tools", "hammer", changed), storage.ErrImmutabilityViolation)
+	version, err = store.GetVersion(ctx, "tools", "hammer", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, newVersion("hammer", "1.0.0", 0, 9).URL, version.URL)
+	assert.ErrorIs(t, store.CreateVersion(ctx, "tools", "hammer", newVersion("hammer", "2.0.0", 5, 9)), storage.ErrPartitionOverlap)
+
+	require.NoError(t, store.DeleteVersion(ctx, "tools", "hammer", "1.0.0"))
+	require.NoError(t, store.CreateVersion(ctx, "tools", "hammer", newVersion("hammer", "2.0.0", 0, 4)))
+	require.NoError(t, store.CreateVersion(ctx, "tools", "hammer", newVersion("hammer", "2.1.0", 5, 9)))
+	versions, err := store.ListVersions(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2.0.0", "2.1.0"}, versionNames(versions))
+	assert.ErrorIs(t, store.DeleteVersion(ctx, "tools", "hammer", "1.0.0"), storage.ErrNotFound)
+
+	history, err := store.GetPackageHistory(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		models.ChangeVersionPublished,
+		models.ChangeVersionPublished,
+		models.ChangeVersionDeleted,
+		models.ChangeVersionPublished,
+		models.ChangePackageCreated,
+	}, changeTypes(history))
+}
+
+func testScheduledVersions(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+	seed(t, store)
+
+	// Embargoed versions are stored but left out of the index
+	scheduled := newVersion("hammer", "3.0.0", 5, 9)
+	publishAt := time.Now().Add(time.Hour).UTC()
+	scheduled.PublishAt = &publishAt
+	require.NoError(t, store.CreateVersion(ctx, "tools", "hammer", scheduled))
+	version, err := store.GetVersion(ctx, "tools", "hammer", "3.0.0")
+	require.NoError(t, err)
+	require.NotNil(t, version.PublishAt)
+	assert.Contains(t, indexKeys(t, store, "tools"), "saw@1.0.0")
+	assert.NotContains(t, indexKeys(t, store, "tools"), "hammer@3.0.0")
+
+	require.NoError(t, store.ReleaseVersion(ctx, "tools", "hammer", "3.0.0"))
+	version, err = store.GetVersion(ctx, "tools", "hammer", "3.0.0")
+	require.NoError(t, err)
+	assert.Nil(t, version.PublishAt)
+	assert.Contains(t, indexKeys(t, store, "tools"), "hammer@3.0.0")
+	assert.ErrorIs(t, store.ReleaseVersion(ctx, "tools", "hammer", "3.0.0"), storage.ErrNotScheduled)
+	assert.ErrorIs(t, store.CancelVersion(ctx, "tools", "hammer", "3.0.0"), storage.ErrNotScheduled)
+	assert.ErrorIs(t, store.ReleaseVersion(ctx, "tools", "hammer", "9.9.9"), storage.ErrNotFound)
+
+	// Cancelling deletes the version
+	cancelled := newVersion("saw", "2.0.0", 5, 9)
+	cancelled.PublishAt = &publishAt
+	require.NoError(t, store.CreateVersion(ctx, "tools", "saw", cancelled))
+	require.NoError(t, store.CancelVersion(ctx, "tools", "saw", "2.0.0"))
+	_, err = store.GetVersion(ctx, "tools", "saw", "2.0.0")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testIndex(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+
+	_, err := store.GetRegistryIndex(ctx, "tools")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Tools", nil, nil)))
+	index, err := store.GetRegistryIndex(ctx, "tools")
+	require.NoError(t, err)
+	assert.Empty(t, index)
+
+	seed(t, store)
+	index, err = store.GetRegistryIndex(ctx, "tools")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []models.IndexEntry{
+		newVersion("hammer", "1.0.0", 0, 4).ToIndexEntry(),
+		newVersion("saw", "1.0.0", 0, 4).ToIndexEntry(),
+	}, index)
+
+	// Aliased packages are listed under their old name only when asked to
+	registry := models.NewRegistry("tools", "Tools", nil, nil)
+	registry.Aliases = map[string]string{"mallet": "hammer"}
+	require.NoError(t, store.UpdateRegistry(ctx, registry))
+	assert.ElementsMatch(t, []string{"hammer@1.0.0", "saw@1.0.0"}, indexKeys(t, store, "tools"))
+	registry = models.NewRegistry("tools", "Tools", nil, nil)
+	registry.Aliases = map[string]string{"mallet": "hammer"}
+	registry.IndexAliases = true
+	require.NoError(t, store.UpdateRegistry(ctx, registry))
+	assert.ElementsMatch(t, []string{"hammer@1.0.0", "mallet@1.0.0", "saw@1.0.0"}, indexKeys(t, store, "tools"))
+}
+
+func testTimestamps(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+
+	// Timestamps are set by storage, whatever the caller sends
+	registry := models.NewRegistry("tools", "Tools", nil, nil)
+	epoch := time.Unix(0, 0).UTC()
+	registry.CreatedAt, registry.UpdatedAt = &epoch, &epoch
+	require.NoError(t, store.CreateRegistry(ctx, registry))
+	stored, err := store.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	require.NotNil(t, stored.CreatedAt)
+	require.NotNil(t, stored.UpdatedAt)
+	assert.True(t, stored.CreatedAt.After(epoch))
+	created := *stored.CreatedAt
+
+	// Changes to packages and versions update their parents, not their creation time
+	time.Sleep(time.Millisecond)
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("hammer", "Hammer", nil, nil)))
+	pkg, err := store.GetPackage(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	require.NotNil(t, pkg.CreatedAt)
+	packageCreated := *pkg.CreatedAt
+	time.Sleep(time.Millisecond)
+	require.NoError(t, store.CreateVersion(ctx, "tools", "hammer", newVersion("hammer", "1.0.0", 0, 9)))
+
+	stored, err = store.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	assert.True(t, stored.CreatedAt.Equal(created))
+	assert.True(t, stored.UpdatedAt.After(created))
+	pkg, err = store.GetPackage(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	assert.True(t, pkg.CreatedAt.Equal(packageCreated))
+	assert.True(t, pkg.UpdatedAt.After(packageCreated))
+
+	// Updates keep the creation times
+	require.NoError(t, store.UpdateRegistry(ctx, models.NewRegistry("tools", "All the tools", nil, nil)))
+	require.NoError(t, store.UpdatePackage(ctx, "tools", models.NewPackage("hammer", "Claw hammer", nil, nil)))
+	stored, err = store.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	assert.True(t, stored.CreatedAt.Equal(created))
+	pkg, err = store.GetPackage(ctx, "tools", "hammer")
+	require.NoError(t, err)
+	assert.True(t, pkg.CreatedAt.Equal(packageCreated))
+}
+
+func testCertificatesAndAPIKeys(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+
+	_, err := store.GetCertificate(ctx, "registry.example.com")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	require.NoError(t, store.PutCertificate(ctx, "registry.example.com", []byte("certificate")))
+	data, err := store.GetCertificate(ctx, "registry.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("certificate"), data)
+	require.NoError(t, store.DeleteCertificate(ctx, "registry.example.com"))
+	_, err = store.GetCertificate(ctx, "registry.example.com")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	key := newAPIKey(t, "tools")
+	assert.ErrorIs(t, store.CreateAPIKey(ctx, key), storage.ErrNotFound)
+	seed(t, store)
+	require.NoError(t, store.CreateAPIKey(ctx, key))
+	stored, err := store.GetAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, key.SecretHash, stored.SecretHash)
+	keys, err := store.ListAPIKeys(ctx, "tools")
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.ErrorIs(t, store.DeleteAPIKey(ctx, "other", key.ID), storage.ErrNotFound)
+
+	// API keys are deleted with their registry
+	require.NoError(t, store.DeleteRegistry(ctx, "tools"))
+	_, err = store.GetAPIKey(ctx, key.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testPersistence(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+	seed(t, store)
+	require.NoError(t, store.DeleteVersion(ctx, "tools", "saw", "1.0.0"))
+	require.NoError(t, store.UpdatePackage(ctx, "tools", models.NewPackage("saw", "Hand saw", []string{"bob"}, map[string]string{"team": "wood"})))
+	before := snapshot(t, store)
+
+	store = backend.Reopen(t)
+	assert.JSONEq(t, before, snapshot(t, store))
+	found, err := store.FindPackages(ctx, "tools", models.CustomValueQuery{"team": {"wood"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"saw"}, packageNames(found))
+	data, err := store.GetCertificate(ctx, "registry.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("certificate"), data)
+
+	// Changes made after reopening continue the sync generations
+	_, generation, err := store.Changes(ctx, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("drill", "Drill", nil, nil)))
+	changes, next, err := store.Changes(ctx, generation)
+	require.NoError(t, err)
+	assert.Greater(t, next, generation)
+	assert.NotEmpty(t, changes)
+	_, _, err = store.Changes(ctx, next+1)
+	assert.ErrorIs(t, err, storage.ErrGenerationAhead)
+}
+
+func testRollback(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+	seed(t, store)
+	publishAt := time.Now().Add(time.Hour).UTC()
+	scheduled := newVersion("saw", "2.0.0", 5, 9)
+	scheduled.PublishAt = &publishAt
+	require.NoError(t, store.CreateVersion(ctx, "tools", "saw", scheduled))
+	key := newAPIKey(t, "tools")
+
+	mutations := []struct {
+		name string
+		run  func() error
+	}{
+		{"CreateRegistry", func() error { return store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)) }},
+		{"UpdateRegistry", func() error { return store.UpdateRegistry(ctx, models.NewRegistry("tools", "Changed", nil, nil)) }},
+		{"DeleteRegistry", func() error { return store.DeleteRegistry(ctx, "tools") }},
+		{"CreatePackage", func() error { return store.CreatePackage(ctx, "tools", models.NewPackage("drill", "", nil, nil)) }},
+		{"UpdatePackage", func() error {
+			return store.UpdatePackage(ctx, "tools", models.NewPackage("hammer", "Changed", nil, nil))
+		}},
+		{"UpdatePackages", func() error {
+			return store.UpdatePackages(ctx, "tools", []*models.Package{models.NewPackage("hammer", "Changed", nil, nil)})
+		}},
+		{"DeletePackage", func() error { return store.DeletePackage(ctx, "tools", "hammer") }},
+		{"CreateVersion", func() error {
+			return store.CreateVersion(ctx, "tools", "hammer", newVersion("hammer", "2.0.0", 5, 9))
+		}},
+		{"DeleteVersion", func() error { return store.DeleteVersion(ctx, "tools", "hammer", "1.0.0") }},
+		{"ReleaseVersion", func() error { return store.ReleaseVersion(ctx, "tools", "saw", "2.0.0") }},
+		{"CancelVersion", func() error { return store.CancelVersion(ctx, "tools", "saw", "2.0.0") }},
+		{"PutCertificate", func() error { return store.PutCertificate(ctx, "other.example.com", []byte("other")) }},
+		{"DeleteCertificate", func() error { return store.DeleteCertificate(ctx, "registry.example.com") }},
+		{"CreateAPIKey", func() error { return store.CreateAPIKey(ctx, key) }},
+	}
+
+	before := snapshot(t, store)
+	for _, mutation := range mutations {
+		backend.FailWrites(t, true)
+		err := mutation.run()
+		backend.FailWrites(t, false)
+		assert.ErrorIs(t, err, storage.ErrStorageUnavailable, mutation.name)
+		assert.JSONEq(t, before, snapshot(t, store), "%s changed the data despite failing", mutation.name)
+	}
+
+	// Nothing failed writes left behind is read back, and the store still works
+	store = backend.Reopen(t)
+	assert.JSONEq(t, before, snapshot(t, store))
+	require.NoError(t, store.CreateAPIKey(ctx, key))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("drill", "", nil, nil)))
+	after := snapshot(t, store)
+	store = backend.Reopen(t)
+	assert.JSONEq(t, after, snapshot(t, store))
+}
+
+func testConcurrency(t *testing.T, backend *Backend) {
+	ctx := context.Background()
+	store := backend.Store
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Tools", nil, nil)))
+
+	// Concurrent writers lose no write, and no two of them create the same record
+	var wg sync.WaitGroup
+	created := make([]int, concurrentWriters)
+	for i := 0; i < concurrentWriters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("package-%d", i)
+			assert.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage(name, "", nil, nil)))
+			for partition := 0; partition < 3; partition++ {
+				version := newVersion(name, fmt.Sprintf("1.0.%d", partition), partition, partition)
+				assert.NoError(t, store.CreateVersion(ctx, "tools", name, version))
+			}
+			// Everyone races for the same package
+			if store.CreatePackage(ctx, "tools", models.NewPackage("shared", "", nil, nil)) == nil {
+				created[i]++
+			}
+			_, err := store.GetRegistryIndex(ctx, "tools")
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, count := range created {
+		total += count
+	}
+	assert.Equal(t, 1, total, "exactly one writer creates the shared package")
+	check := func(store storage.Store) {
+		packages, err := store.ListPackages(ctx, "tools")
+		require.NoError(t, err)
+		assert.Len(t, packages, concurrentWriters+1)
+		assert.Len(t, indexKeys(t, store, "tools"), concurrentWriters*3)
+	}
+	check(store)
+	check(backend.Reopen(t))
+}
+
+// seed creates the registry "tools" with the packages "hammer" and "saw",
+// each with version 1.0.0 on partitions 0-4, and the certificate
+// "registry.example.com"
+func seed(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	if _, err := store.GetRegistry(ctx, "tools"); errors.Is(err, storage.ErrNotFound) {
+		require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "Tools", []string{"alice"}, nil)))
+	}
+	for _, name := range []string{"hammer", "saw"} {
+		require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage(name, "", nil, map[string]string{"team": "infra"})))
+		require.NoError(t, store.CreateVersion(ctx, "tools", name, newVersion(name, "1.0.0", 0, 4)))
+	}
+	require.NoError(t, store.PutCertificate(ctx, "registry.example.com", []byte("certificate")))
+}
+
+func newVersion(name, version string, startPartition, endPartition int) *models.Version {
+	return models.NewVersion(name, version,
+		"sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		fmt.Sprintf("https://example.com/%s-%s.pkg", name, version),
+		startPartition, endPartition)
+}
+
+func newAPIKey(t *testing.T, registryName string) *models.APIKey {
+	key, _, err := models.NewAPIKey(registryName, "", "conformance", "alice", nil)
+	require.NoError(t, err)
+	return key
+}
+
+// snapshot returns everything a store serves, as JSON, to compare stores
+// regardless of map and slice order
+func snapshot(t *testing.T, store storage.Store) string {
+	ctx := context.Background()
+	registries, err := store.ListRegistries(ctx)
+	require.NoError(t, err)
+	sort.Slice(registries, func(i, j int) bool { return registries[i].Name < registries[j].Name })
+
+	histories := make(map[string][]*models.ChangeRecord)
+	apiKeys := make(map[string][]*models.APIKey)
+	for _, registry := range registries {
+		for name := range registry.Packages {
+			history, err := store.GetPackageHistory(ctx, registry.Name, name)
+			require.NoError(t, err)
+			histories[models.RecordKey(registry.Name, name)] = history
+		}
+		keys, err := store.ListAPIKeys(ctx, registry.Name)
+		require.NoError(t, err)
+		sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+		apiKeys[registry.Name] = keys
+	}
+
+	changes, generation, err := store.Changes(ctx, 0)
+	require.NoError(t, err)
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Generation != changes[j].Generation {
+			return changes[i].Generation < changes[j].Generation
+		}
+		return models.RecordKey(changes[i].Registry, changes[i].Package, changes[i].Version) <
+			models.RecordKey(changes[j].Registry, changes[j].Package, changes[j].Version)
+	})
+
+	certificates := make(map[string][]byte)
+	for _, name := range []string{"registry.example.com", "other.example.com"} {
+		data, err := store.GetCertificate(ctx, name)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		require.NoError(t, err)
+		certificates[name] = data
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"registries":   registries,
+		"histories":    histories,
+		"api_keys":     apiKeys,
+		"changes":      changes,
+		"generation":   generation,
+		"certificates": certificates,
+	})
+	require.NoError(t, err)
+	return string(data)
+}
+
+// indexKeys returns the entries of a registry index as name@version
+func indexKeys(t *testing.T, store storage.Store, registryName string) []string {
+	index, err := store.GetRegistryIndex(context.Background(), registryName)
+	require.NoError(t, err)
+	keys := make([]string, 0, len(index))
+	for _, entry := range index {
+		keys = append(keys, entry.Name+"@"+entry.Version)
+	}
+	return keys
+}
+
+func registryNames(registries []*models.Registry) []string {
+	names := make([]string, 0, len(registries))
+	for _, registry := range registries {
+		names = append(names, registry.Name)
+	}
+	return names
+}
+
+func packageNames(packages []*models.Package) []string {
+	names := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		names = append(names, pkg.Name)
+	}
+	return names
+}
+
+func versionNames(versions []*models.Version) []string {
+	names := make([]string, 0, len(versions))
+	for _, version := range versions {
+		names = append(names, version.Version)
+	}
+	return names
+}
+
+func changeTypes(history []*models.ChangeRecord) []string {
+	types := make([]string, 0, len(history))
+	for _, change := range history {
+		types = append(types, change.Type)
+	}
+	return types
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// initSyncState makes sure normalized data carries a sync state. Data
+// written before sync tracking existed gets every record stamped with
+// generation 1, which is deterministic, so nothing needs to be persisted
+// until the next write.
+func initSyncState(data *models.Storage) {
+	if data.Sync != nil {
+		return
+	}
+
+	state := models.NewSyncState()
+	for registryName, registry := range data.Registries {
+		state.Records[models.RecordKey(registryName)] = 1
+		for packageName, pkg := range registry.Packages {
+			state.Records[models.RecordKey(registryName, packageName)] = 1
+			for version := range pkg.Versions {
+				state.Records[models.RecordKey(registryName, packageName, version)] = 1
+			}
+		}
+	}
+	if len(state.Records) > 0 {
+		state.Generation = 1
+	}
+	data.Sync = state
+}
+
+// rebaseSyncState makes data, restored from a backup, the generation after
+// current: every record of data changes at that generation, and the records
+// of current data lacks are deleted at it. Tombstones of current are kept.
+func rebaseSyncState(data *models.Storage, current *models.SyncState) {
+	state := models.NewSyncState()
+	state.Generation = current.Generation + 1
+	for registryName, registry := range data.Registries {
+		state.Records[models.RecordKey(registryName)] = state.Generation
+		for packageName, pkg := range registry.Packages {
+			state.Records[models.RecordKey(registryName, packageName)] = state.Generation
+			for version := range pkg.Versions {
+				state.Records[models.RecordKey(registryName, packageName, version)] = state.Generation
+			}
+		}
+	}
+	for key, generation := range current.Tombstones {
+		if _, ok := state.Records[key]; !ok {
+			state.Tombstones[key] = generation
+		}
+	}
+	for key := range current.Records {
+		if _, ok := state.Records[key]; ok {
+			continue
+		}
+		// The tombstone of a deleted parent covers its children
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			if _, ok := state.Records[key[:i]]; !ok {
+				continue
+			}
+		}
+		state.Tombstones[key] = state.Generation
+	}
+	data.Sync = state
+}
+
+// touchLocked records a change to the record identified by key at a new
+// generation and returns a function undoing it, for rollback when
+// persistence fails. Deleting a record also drops its children's entries.
+// Caller MUST hold the write lock.
+func (b *BaseStorage) touchLocked(key string, deleted bool) func() {
+	state := b.data.Sync
+
+	// Remember every entry this change may overwrite
+	affected := []string{key}
+	if deleted {
+		prefix := key + "/"
+		for k := range state.Records {
+			if strings.HasPrefix(k, prefix) {
+				affected = append(affected, k)
+			}
+		}
+		for k := range state.Tombstones {
+			if strings.HasPrefix(k, prefix) {
+				affected = append(affected, k)
+			}
+		}
+	}
+
+	prevGeneration := state.Generation
+	prevRecords := make(map[string]uint64)
+	prevTombstones := make(map[string]uint64)
+	for _, k := range affected {
+		if gen, ok := state.Records[k]; ok {
+			prevRecords[k] = gen
+		}
+		if gen, ok := state.Tombstones[k]; ok {
+			prevTombstones[k] = gen
+		}
+	}
+
+	state.Generation++
+	if deleted {
+		for _, k := range affected {
+			delete(state.Records, k)
+			delete(state.Tombstones, k)
+		}
+		state.Tombstones[key] = state.Generation
+	} else {
+		delete(state.Tombstones, key)
+		state.Records[key] = state.Generation
+	}
+
+	return func() {
+		state.Generation = prevGeneration
+		for _, k := range affected {
+			delete(state.Records, k)
+			delete(state.Tombstones, k)
+		}
+		for k, gen := range prevRecords {
+			state.Records[k] = gen
+		}
+		for k, gen := range prevTombstones {
+			state.Tombstones[k] = gen
+		}
+	}
+}
+
+// Changes returns every record changed after generation since, ordered by
+// generation, along with the current generation to resume from.
+// Returns ErrGenerationAhead if since is newer than the store (e.g. the
+// store was reset), in which case the caller must resync from zero.
+func (b *BaseStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	unlock, err := b.rlock(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+
+	state := b.data.Sync
+	if since > state.Generation {
+		return nil, state.Generation, ErrGenerationAhead
+	}
+
+	var changes []models.SyncChange
+	for key, gen := range state.Records {
+		if gen <= since {
+			continue
+		}
+		change, err := b.recordChangeLocked(key, gen)
+		if err != nil {
+			return nil, 0, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	for key, gen := range state.Tombstones {
+		if gen <= since {
+			continue
+		}
+		recordType, registry, pkg, version := models.SplitRecordKey(key)
+		changes = append(changes, models.SyncChange{
+			Generation: gen,
+			Type:       recordType,
+			Op:         models.SyncOpDelete,
+			Registry:   registry,
+			Package:    pkg,
+			Version:    version,
+		})
+	}
+
+	// Records migrated from legacy data share generation 1; order them by key
+	// so parents come before their children
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Generation != changes[j].Generation {
+			return changes[i].Generation < changes[j].Generation
+		}
+		return changeKey(&changes[i]) < changeKey(&changes[j])
+	})
+
+	return changes, state.Generation, nil
+}
+
+// recordChangeLocked builds the upsert change for a record, or nil if the
+// record no longer exists. Caller MUST hold at least a read lock.
+func (b *BaseStorage) recordChangeLocked(key string, gen uint64) (*models.SyncChange, error) {
+	recordType, registryName, packageName, version := models.SplitRecordKey(key)
+	change := &models.SyncChange{
+		Generation: gen,
+		Type:       recordType,
+		Op:         models.SyncOpUpsert,
+		Registry:   registryName,
+		Package:    packageName,
+		Version:    version,
+	}
+
+	registry, exists := b.data.Registries[registryName]
+	if !exists {
+		return nil, nil
+	}
+
+	var record interface{}
+	switch recordType {
+	case models.SyncTypeRegistry:
+		copied := *registry
+		copied.Packages = nil
+		record = &copied
+	case models.SyncTypePackage:
+		pkg, exists := registry.Packages[packageName]
+		if !exists {
+			return nil, nil
+		}
+		copied := *pkg
+		copied.Versions = nil
+		record = &copied
+	default:
+		pkg, exists := registry.Packages[packageName]
+		if !exists {
+			return nil, nil
+		}
+		ver, exists := pkg.Versions[version]
+		if !exists {
+			return nil, nil
+		}
+		record = ver
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	change.Data = data
+	return change, nil
+}
+
+// changeKey rebuilds the record key of a change
+func changeKey(c *models.SyncChange) string {
+	switch c.Type {
+	case models.SyncTypeRegistry:
+		return models.RecordKey(c.Registry)
+	case models.SyncTypePackage:
+		return models.RecordKey(c.Registry, c.Package)
+	default:
+		return models.RecordKey(c.Registry, c.Package, c.Version)
+	}
+}
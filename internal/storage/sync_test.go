@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseStorage_Changes_ReplicaConverges(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+	require.NoError(t, bs.CreatePackage(ctx, "reg", models.NewPackage("pkg", "", nil, nil), nil))
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "1.0.0", "sha256:a", "http://x/1.zip", 0, 9), nil))
+
+	// Full sync from scratch
+	replica := models.NewStorage()
+	changes, gen, err := bs.Changes(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), gen)
+	assert.Len(t, changes, 3)
+	for i := range changes {
+		require.NoError(t, replica.ApplyChange(&changes[i]))
+	}
+	assert.Contains(t, replica.Registries["reg"].Packages["pkg"].Versions, "1.0.0")
+
+	// Differential sync only returns what changed since
+	require.NoError(t, bs.CreateVersion(ctx, "reg", "pkg", models.NewVersion("pkg", "2.0.0", "sha256:b", "http://x/2.zip", 10, 19), nil))
+	require.NoError(t, bs.DeleteVersion(ctx, "reg", "pkg", "1.0.0", nil))
+
+	changes, gen, err = bs.Changes(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), gen)
+	require.Len(t, changes, 2)
+	assert.Equal(t, models.SyncOpUpsert, changes[0].Op)
+	assert.Equal(t, "2.0.0", changes[0].Version)
+	assert.Equal(t, models.SyncOpDelete, changes[1].Op)
+	assert.Equal(t, "1.0.0", changes[1].Version)
+	for i := range changes {
+		require.NoError(t, replica.ApplyChange(&changes[i]))
+	}
+	versions := replica.Registries["reg"].Packages["pkg"].Versions
+	assert.Contains(t, versions, "2.0.0")
+	assert.NotContains(t, versions, "1.0.0")
+
+	// Deleting the registry collapses its children into one tombstone
+	require.NoError(t, bs.DeleteRegistry(ctx, "reg", nil))
+	changes, _, err = bs.Changes(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, models.SyncTypeRegistry, changes[0].Type)
+	assert.Equal(t, models.SyncOpDelete, changes[0].Op)
+}
+
+func TestBaseStorage_Changes_PersistFailureRollsBackGeneration(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	require.NoError(t, bs.CreateRegistry(ctx, models.NewRegistry("reg", "", nil, nil), nil))
+
+	failing := func(context.Context) error { return errors.New("disk full") }
+	err := bs.DeleteRegistry(ctx, "reg", failing)
+	assert.Equal(t, ErrStorageUnavailable, err)
+
+	changes, gen, err := bs.Changes(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), gen)
+	require.Len(t, changes, 1)
+	assert.Equal(t, models.SyncOpUpsert, changes[0].Op)
+}
+
+func TestBaseStorage_Changes_GenerationAhead(t *testing.T) {
+	bs := newTestBaseStorage()
+
+	_, gen, err := bs.Changes(context.Background(), 42)
+	assert.Equal(t, ErrGenerationAhead, err)
+	assert.Equal(t, uint64(0), gen)
+}
+
+func TestBaseStorage_Changes_LegacyDataStartsAtGenerationOne(t *testing.T) {
+	bs := newTestBaseStorage()
+
+	// Data persisted before sync tracking has no sync state
+	err := bs.UnmarshalData([]byte(`{"registries":{"reg":{"name":"reg","packages":{"pkg":{"name":"pkg","versions":{}}}}}}`))
+	require.NoError(t, err)
+
+	changes, gen, err := bs.Changes(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), gen)
+	assert.Len(t, changes, 2)
+
+	changes, _, err = bs.Changes(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+// changeLocked records a change to the record identified by key, like
+// touchLocked, and stamps its last-write times: a created or updated
+// registry or package gets UpdatedAt (and CreatedAt if it has none), and
+// its parents get UpdatedAt. It returns a function undoing both.
+// Caller MUST hold the write lock.
+func (b *BaseStorage) changeLocked(key string, deleted bool) func() {
+	undoTouch := b.touchLocked(key, deleted)
+	undoStamp := b.stampLocked(key, deleted)
+	return func() {
+		undoStamp()
+		undoTouch()
+	}
+}
+
+// stampLocked sets the last-write times of the record identified by key
+// and its parents, returning a function restoring them.
+// Caller MUST hold the write lock.
+func (b *BaseStorage) stampLocked(key string, deleted bool) func() {
+	now := time.Now().UTC()
+	recordType, registryName, packageName, _ := models.SplitRecordKey(key)
+
+	// Fields to stamp: updated ones, then created ones if unset
+	var updated, created []**time.Time
+	registry := b.data.Registries[registryName]
+	var pkg *models.Package
+	if registry != nil && packageName != "" {
+		pkg = registry.Packages[packageName]
+	}
+	switch {
+	case recordType == models.SyncTypeRegistry && registry != nil && !deleted:
+		updated = append(updated, &registry.UpdatedAt)
+		created = append(created, &registry.CreatedAt)
+	case recordType == models.SyncTypePackage && pkg != nil && !deleted:
+		updated = append(updated, &pkg.UpdatedAt, &registry.UpdatedAt)
+		created = append(created, &pkg.CreatedAt)
+	case recordType == models.SyncTypeVersion && pkg != nil:
+		updated = append(updated, &pkg.UpdatedAt, &registry.UpdatedAt)
+	case recordType != models.SyncTypeRegistry && registry != nil:
+		updated = append(updated, &registry.UpdatedAt)
+	}
+
+	fields := append(updated, created...)
+	previous := make([]*time.Time, len(fields))
+	for i, field := range fields {
+		previous[i] = *field
+	}
+	for _, field := range updated {
+		*field = &now
+	}
+	for _, field := range created {
+		if *field == nil {
+			*field = &now
+		}
+	}
+
+	return func() {
+		for i, field := range fields {
+			*field = previous[i]
+		}
+	}
+}
+
+// backfillTimestamps stamps registries and packages written before
+// last-write times were tracked from their package histories: a package
+// was created at its oldest recorded change and updated at its latest, and
+// a registry spans its packages. Records without history are left unset.
+// The result is deterministic, so nothing needs to be persisted until the
+// next write.
+func backfillTimestamps(data *models.Storage) {
+	for registryName, registry := range data.Registries {
+		var registryCreated, registryUpdated *time.Time
+		for packageName, pkg := range registry.Packages {
+			history := data.History[models.RecordKey(registryName, packageName)]
+			if len(history) > 0 {
+				oldest, latest := history[0].Time, history[len(history)-1].Time
+				if pkg.CreatedAt == nil {
+					pkg.CreatedAt = &oldest
+				}
+				if pkg.UpdatedAt == nil {
+					pkg.UpdatedAt = &latest
+				}
+			}
+			if pkg.CreatedAt != nil && (registryCreated == nil || pkg.CreatedAt.Before(*registryCreated)) {
+				registryCreated = pkg.CreatedAt
+			}
+			if pkg.UpdatedAt != nil && (registryUpdated == nil || pkg.UpdatedAt.After(*registryUpdated)) {
+				registryUpdated = pkg.UpdatedAt
+			}
+		}
+		if registry.CreatedAt == nil && registryCreated != nil {
+			createdAt := *registryCreated
+			registry.CreatedAt = &createdAt
+		}
+		if registry.UpdatedAt == nil && registryUpdated != nil {
+			updatedAt := *registryUpdated
+			registry.UpdatedAt = &updatedAt
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+)
+
+func TestBaseStorage_Timestamps(t *testing.T) {
+	bs := newTestBaseStorage()
+	ctx := context.Background()
+
+	// Timestamps sent by clients are ignored
+	forged := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	reg := models.NewRegistry("tools", "", nil, nil)
+	reg.CreatedAt = &forged
+	require.NoError(t, bs.CreateRegistry(ctx, reg, nil))
+	reg, err := bs.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	require.NotNil(t, reg.CreatedAt)
+	assert.True(t, reg.CreatedAt.After(forged))
+	assert.Equal(t, reg.CreatedAt, reg.UpdatedAt)
+	registryCreated := *reg.CreatedAt
+
+	require.NoError(t, bs.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, nil), nil))
+	pkg, err := bs.GetPackage(ctx, "tools", "deployer")
+	require.NoError(t, err)
+	require.NotNil(t, pkg.CreatedAt)
+	packageCreated := *pkg.CreatedAt
+
+	// Publishing a version updates the package and the registry
+	time.Sleep(time.Millisecond)
+	require.NoError(t, bs.CreateVersion(ctx, "tools", "deployer", models.NewVersion("deployer", "1.0.0", "sha256:abc", "https://example.com/1.0.0.zip", 0, 9), nil))
+	pkg, _ = bs.GetPackage(ctx, "tools", "deployer")
+	reg, _ = bs.GetRegistry(ctx, "tools")
+	assert.Equal(t, packageCreated, *pkg.CreatedAt)
+	assert.True(t, pkg.UpdatedAt.After(packageCreated))
+	assert.Equal(t, registryCreated, *reg.CreatedAt)
+	assert.Equal(t, *pkg.UpdatedAt, *reg.UpdatedAt)
+	versionPublished := *pkg.UpdatedAt
+
+	// Updates keep the creation time
+	time.Sleep(time.Millisecond)
+	edited := *pkg
+	edited.Description = "Deploys things"
+	edited.CreatedAt = nil
+	require.NoError(t, bs.UpdatePackage(ctx, "tools", &edited, nil))
+	pkg, _ = bs.GetPackage(ctx, "tools", "deployer")
+	assert.Equal(t, packageCreated, *pkg.CreatedAt)
+	assert.True(t, pkg.UpdatedAt.After(versionPublished))
+
+	require.NoError(t, bs.UpdateRegistry(ctx, models.NewRegistry("tools", "Tools", nil, nil), nil))
+	reg, _ = bs.GetRegistry(ctx, "tools")
+	assert.Equal(t, registryCreated, *reg.CreatedAt)
+
+	// A failed write restores the previous times
+	before := *reg.UpdatedAt
+	err = bs.DeleteVersion(ctx, "tools", "deployer", "1.0.0", func(context.Context) error { return assert.AnError })
+	assert.ErrorIs(t, err, ErrStorageUnavailable)
+	reg, _ = bs.GetRegistry(ctx, "tools")
+	assert.Equal(t, before, *reg.UpdatedAt)
+}
+
+func TestBackfillTimestamps(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	raw, err := json.Marshal(map[string]interface{}{
+		"registries": map[string]interface{}{
+			"tools": map[string]interface{}{"name": "tools", "packages": map[string]interface{}{
+				"deployer": map[string]interface{}{"name": "deployer", "versions": map[string]interface{}{}},
+				"linter":   map[string]interface{}{"name": "linter", "versions": map[string]interface{}{}},
+			}},
+			"empty": map[string]interface{}{"name": "empty", "packages": map[string]interface{}{}},
+		},
+		"history": map[string]interface{}{
+			"tools/deployer": []map[string]interface{}{
+				{"time": created, "type": models.ChangePackageCreated},
+				{"time": updated, "type": models.ChangeVersionPublished, "version": "1.0.0"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	bs := newTestBaseStorage()
+	require.NoError(t, bs.UnmarshalData(raw))
+	ctx := context.Background()
+
+	pkg, err := bs.GetPackage(ctx, "tools", "deployer")
+	require.NoError(t, err)
+	assert.Equal(t, created, *pkg.CreatedAt)
+	assert.Equal(t, updated, *pkg.UpdatedAt)
+
+	// Without history, nothing is known
+	pkg, err = bs.GetPackage(ctx, "tools", "linter")
+	require.NoError(t, err)
+	assert.Nil(t, pkg.CreatedAt)
+
+	reg, err := bs.GetRegistry(ctx, "tools")
+	require.NoError(t, err)
+	assert.Equal(t, created, *reg.CreatedAt)
+	assert.Equal(t, updated, *reg.UpdatedAt)
+	reg, err = bs.GetRegistry(ctx, "empty")
+	require.NoError(t, err)
+	assert.Nil(t, reg.UpdatedAt)
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/buildinfo"
+	"github.com/criteo/command-launcher-registry/internal/fips"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// backendRequestIDHeaders are the response headers in which storage
+// providers return their own identifier for a request, the one their
+// support asks for
+var backendRequestIDHeaders = []string{
+	"X-GitHub-Request-Id",  // ghcr.io
+	"X-Amz-Request-Id",     // S3 and most S3-compatible stores
+	"X-Amz-Id-2",           // S3 extended request ID
+	"X-Ms-Request-Id",      // Azure Container Registry
+	"X-Guploader-Uploadid", // Google Cloud Storage
+	"X-Request-Id",
+}
+
+// backendTransport returns the base transport of storage backend clients,
+// http.DefaultTransport with TLS restricted in FIPS mode
+func backendTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	fips.RestrictTLS(transport)
+	return transport
+}
+
+// tracingTransport tags backend requests with the request ID and operation
+// that caused them, appended to the User-Agent, and logs each exchange with
+// the identifiers the backend returned
+type tracingTransport struct {
+	base   http.RoundTripper
+	logger *slog.Logger
+}
+
+// newTracingTransport wraps base, http.DefaultTransport if nil
+func newTracingTransport(base http.RoundTripper, logger *slog.Logger) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{base: base, logger: logger}
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attrs := tracing.LogAttrs(ctx)
+
+	// RoundTrip must not modify the caller's request
+	req = req.Clone(ctx)
+	req.Header.Set("User-Agent", userAgent(req.Header.Get("User-Agent"), tracing.RequestID(ctx), tracing.Operation(ctx)))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	attrs = append(attrs,
+		"method", req.Method,
+		"host", req.URL.Host,
+		"path", req.URL.Path,
+		"duration_ms", time.Since(start).Milliseconds())
+	if err != nil {
+		t.logger.Warn("Storage backend request failed", append(attrs, "error", err)...)
+		return nil, err
+	}
+
+	attrs = append(attrs, "status_code", resp.StatusCode)
+	for _, header := range backendRequestIDHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			attrs = append(attrs, "backend_request_id", value)
+			break
+		}
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		t.logger.Warn("Storage backend request failed", attrs...)
+	} else {
+		t.logger.Debug("Storage backend request", attrs...)
+	}
+	return resp, nil
+}
+
+// userAgent appends the server version, request ID and operation to the
+// User-Agent set by the backend client library
+func userAgent(base, requestID, operation string) string {
+	var comments []string
+	if requestID != "" || operation != "" {
+		comments = append(comments, "request_id="+requestID, "operation="+operation)
+	}
+	agent := buildinfo.UserAgent("cola-registry", comments...)
+	if base != "" {
+		agent = base + " " + agent
+	}
+	return agent
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+func TestTracingTransport(t *testing.T) {
+	var userAgent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Header().Set("X-GitHub-Request-Id", "C0DE:1234:ABCD")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer backend.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := &http.Client{Transport: newTracingTransport(nil, logger)}
+
+	ctx := tracing.WithOperation(tracing.WithRequestID(context.Background(), "req-1"), "create_version")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, backend.URL+"/v2/org/repo/manifests/latest", nil)
+	require.NoError(t, err)
+	req.Header.Set("User-Agent", "oras-go")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "oras-go cola-registry/1.0.0 ("+runtime.GOOS+"; "+runtime.GOARCH+"; request_id=req-1; operation=create_version)", userAgent)
+	assert.Equal(t, "oras-go", req.Header.Get("User-Agent"), "the caller's request is left unchanged")
+	assert.Contains(t, logs.String(), "request_id=req-1")
+	assert.Contains(t, logs.String(), "operation=create_version")
+	assert.Contains(t, logs.String(), "backend_request_id=C0DE:1234:ABCD")
+	assert.Contains(t, logs.String(), "status_code=204")
+}
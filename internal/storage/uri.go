@@ -3,11 +3,13 @@ package storage
 import (
 	"fmt"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 // SupportedSchemes lists all currently supported storage URI schemes
-var SupportedSchemes = []string{"file", "oci", "s3", "s3+http"}
+var SupportedSchemes = []string{"file", "sqlite", "postgres", "postgresql", "oci", "s3", "s3+http", "gcs", "git", "git+https", "git+http", "git+file", "redis", "rediss", "dynamodb", "http+dav", "https+dav"}
 
 // PlannedSchemes lists schemes that are recognized but not yet implemented
 var PlannedSchemes = []string{}
@@ -17,8 +19,9 @@ type StorageURI struct {
 	Scheme string // Storage backend type (e.g., "file", "oci", "s3", "s3+http")
 	Host   string // Host for network backends (optional for file://)
 	Path   string // Path to storage resource
-	Raw    string // Original URI string for logging/debugging
-	Query  url.Values // Query parameters (for S3 region)
+	Raw    string // Original URI string for logging/debugging (password redacted)
+	Query  url.Values // Query parameters (for S3 and DynamoDB region, PostgreSQL and Redis connection settings, Git branch and file layout)
+	User   *url.Userinfo // Credentials given in the URI (PostgreSQL, Redis, Git and WebDAV only)
 }
 
 // NormalizeStorageURI ensures the URI has a scheme, prepending "file://" if missing
@@ -118,6 +121,200 @@ func ParseStorageURI(uri string) (*StorageURI, error) {
 		}, nil
 	}
 
+	// GCS-specific validation: gcs://bucket/path/to/object.json
+	if parsed.Scheme == "gcs" {
+		if parsed.RawQuery != "" {
+			return nil, fmt.Errorf("GCS URI does not support query parameters")
+		}
+		if parsed.Fragment != "" {
+			return nil, fmt.Errorf("GCS URI does not support fragments")
+		}
+		if parsed.Host == "" {
+			return nil, fmt.Errorf("GCS URI must include bucket: gcs://bucket/path/to/object.json")
+		}
+		object := strings.TrimPrefix(parsed.Path, "/")
+		if object == "" || strings.HasSuffix(object, "/") {
+			return nil, fmt.Errorf("GCS URI must include object path: gcs://bucket/path/to/object.json")
+		}
+		return &StorageURI{
+			Scheme: parsed.Scheme,
+			Host:   parsed.Host,
+			Path:   object,
+			Raw:    uri,
+		}, nil
+	}
+
+	// Git-specific validation: git://host/repo, git+https://host/repo.git or
+	// git+file:///path/to/repo.git, with optional branch and path parameters
+	if strings.HasPrefix(parsed.Scheme, "git") {
+		if parsed.Fragment != "" {
+			return nil, fmt.Errorf("Git URI does not support fragments")
+		}
+		if parsed.Scheme != "git+file" && parsed.Host == "" {
+			return nil, fmt.Errorf("Git URI must include host: git+https://host/org/repo.git")
+		}
+		if strings.Trim(parsed.Path, "/") == "" {
+			return nil, fmt.Errorf("Git URI must include repository path: git+https://host/org/repo.git")
+		}
+		for key := range parsed.Query() {
+			if key != "branch" && key != "path" {
+				return nil, fmt.Errorf("Git URI does not support query parameter %q; only 'branch' and 'path' are allowed", key)
+			}
+		}
+		if file := parsed.Query().Get("path"); file != "" {
+			if strings.HasPrefix(file, "/") || strings.HasSuffix(file, "/") || slices.Contains(strings.Split(file, "/"), "..") {
+				return nil, fmt.Errorf("Git URI path parameter must be a file path relative to the repository root")
+			}
+		}
+		return &StorageURI{
+			Scheme: parsed.Scheme,
+			Host:   parsed.Host,
+			Path:   parsed.Path,
+			Raw:    parsed.Redacted(),
+			Query:  parsed.Query(),
+			User:   parsed.User,
+		}, nil
+	}
+
+	// SQLite-specific validation: the path is a local database file, given
+	// as sqlite://path.db, sqlite://./path.db or sqlite:///abs/path.db
+	if parsed.Scheme == "sqlite" {
+		if parsed.RawQuery != "" {
+			return nil, fmt.Errorf("SQLite URI does not support query parameters")
+		}
+		if parsed.Fragment != "" {
+			return nil, fmt.Errorf("SQLite URI does not support fragments")
+		}
+		sqlitePath := parsed.Host + parsed.Path
+		if parsed.Host == "." {
+			sqlitePath = "./" + strings.TrimPrefix(parsed.Path, "/")
+		}
+		if sqlitePath == "" || strings.HasSuffix(sqlitePath, "/") {
+			return nil, fmt.Errorf("SQLite URI must include a database file: sqlite://path/to/registry.db")
+		}
+		return &StorageURI{
+			Scheme: parsed.Scheme,
+			Path:   sqlitePath,
+			Raw:    uri,
+		}, nil
+	}
+
+	// PostgreSQL-specific validation: postgres://[user[:password]@]host[:port]/database[?params],
+	// the parameters being passed to the driver (sslmode, search_path...)
+	if parsed.Scheme == "postgres" || parsed.Scheme == "postgresql" {
+		if parsed.Fragment != "" {
+			return nil, fmt.Errorf("PostgreSQL URI does not support fragments")
+		}
+		if parsed.Host == "" {
+			return nil, fmt.Errorf("PostgreSQL URI must include server host: postgres://host:5432/database")
+		}
+		database := strings.TrimPrefix(parsed.Path, "/")
+		if database == "" || strings.Contains(database, "/") {
+			return nil, fmt.Errorf("PostgreSQL URI must include a database name: postgres://host:5432/database")
+		}
+		return &StorageURI{
+			Scheme: parsed.Scheme,
+			Host:   parsed.Host,
+			Path:   database,
+			Raw:    parsed.Redacted(),
+			Query:  parsed.Query(),
+			User:   parsed.User,
+		}, nil
+	}
+
+	// Redis-specific validation: redis[s]://[user[:password]@]host[:port][/db][?params],
+	// the key prefix being a parameter and the others passed to the client
+	if parsed.Scheme == "redis" || parsed.Scheme == "rediss" {
+		if parsed.Fragment != "" {
+			return nil, fmt.Errorf("Redis URI does not support fragments")
+		}
+		if parsed.Host == "" {
+			return nil, fmt.Errorf("Redis URI must include server host: redis://host:6379/0")
+		}
+		db := strings.TrimPrefix(parsed.Path, "/")
+		if db != "" {
+			if _, err := strconv.Atoi(db); err != nil {
+				return nil, fmt.Errorf("Redis URI path must be a database number: redis://host:6379/0")
+			}
+		}
+		if parsed.Query().Has("prefix") && parsed.Query().Get("prefix") == "" {
+			return nil, fmt.Errorf("Redis URI prefix parameter cannot be empty")
+		}
+		return &StorageURI{
+			Scheme: parsed.Scheme,
+			Host:   parsed.Host,
+			Path:   db,
+			Raw:    parsed.Redacted(),
+			Query:  parsed.Query(),
+			User:   parsed.User,
+		}, nil
+	}
+
+	// DynamoDB-specific validation: dynamodb://table[?region=...&endpoint=...]
+	if parsed.Scheme == "dynamodb" {
+		if parsed.Fragment != "" {
+			return nil, fmt.Errorf("DynamoDB URI does not support fragments")
+		}
+		if parsed.Host == "" || parsed.Port() != "" {
+			return nil, fmt.Errorf("DynamoDB URI must include a table name: dynamodb://table")
+		}
+		if parsed.Path != "" && parsed.Path != "/" {
+			return nil, fmt.Errorf("DynamoDB URI does not support a path: dynamodb://table")
+		}
+		for key := range parsed.Query() {
+			if key != "region" && key != "endpoint" {
+				return nil, fmt.Errorf("DynamoDB URI does not support query parameter %q; only 'region' and 'endpoint' are allowed", key)
+			}
+		}
+		if endpoint := parsed.Query().Get("endpoint"); endpoint != "" {
+			if endpointURL, err := url.Parse(endpoint); err != nil || (endpointURL.Scheme != "http" && endpointURL.Scheme != "https") || endpointURL.Host == "" {
+				return nil, fmt.Errorf("DynamoDB endpoint must be an http:// or https:// URL")
+			}
+		}
+		return &StorageURI{
+			Scheme: parsed.Scheme,
+			Host:   parsed.Host,
+			Raw:    uri,
+			Query:  parsed.Query(),
+		}, nil
+	}
+
+	// WebDAV-specific validation: http+dav://host/path/registry.json or
+	// https+dav://[user[:password]@]host[:port]/path/registry.json
+	if parsed.Scheme == "http+dav" || parsed.Scheme == "https+dav" {
+		if parsed.RawQuery != "" {
+			return nil, fmt.Errorf("WebDAV URI does not support query parameters")
+		}
+		if parsed.Fragment != "" {
+			return nil, fmt.Errorf("WebDAV URI does not support fragments")
+		}
+		if parsed.Host == "" {
+			return nil, fmt.Errorf("WebDAV URI must include server host: https+dav://host/path/registry.json")
+		}
+		if strings.Trim(parsed.Path, "/") == "" || strings.HasSuffix(parsed.Path, "/") {
+			return nil, fmt.Errorf("WebDAV URI must include a file path: https+dav://host/path/registry.json")
+		}
+		return &StorageURI{
+			Scheme: parsed.Scheme,
+			Host:   parsed.Host,
+			Path:   parsed.Path,
+			Raw:    parsed.Redacted(),
+			User:   parsed.User,
+		}, nil
+	}
+
+	// File-specific validation: only 'layout' is allowed (file://path?layout=sharded)
+	if parsed.Scheme == "file" {
+		for key := range parsed.Query() {
+			if key != "layout" {
+				return nil, fmt.Errorf("file URI does not support query parameter %q; only 'layout' is allowed", key)
+			}
+		}
+		if layout := parsed.Query().Get("layout"); layout != "" && layout != FileLayoutSingle && layout != FileLayoutSharded {
+			return nil, fmt.Errorf("invalid file layout %q: must be '%s' or '%s'", layout, FileLayoutSingle, FileLayoutSharded)
+		}
+	}
+
 	// Extract path - for file:// URIs, the path may be in different places
 	path := parsed.Path
 	if parsed.Scheme == "file" {
@@ -147,6 +344,7 @@ func ParseStorageURI(uri string) (*StorageURI, error) {
 		Host:   parsed.Host,
 		Path:   path,
 		Raw:    uri,
+		Query:  parsed.Query(),
 	}, nil
 }
 
@@ -177,6 +375,101 @@ func (u *StorageURI) IsFileScheme() bool {
 	return u.Scheme == "file"
 }
 
+// FileLayout returns the layout of file storage: FileLayoutSingle unless
+// the URI has ?layout=sharded
+func (u *StorageURI) FileLayout() string {
+	if u.Query.Get("layout") == FileLayoutSharded {
+		return FileLayoutSharded
+	}
+	return FileLayoutSingle
+}
+
+// IsSQLiteScheme returns true if this is a sqlite:// URI
+func (u *StorageURI) IsSQLiteScheme() bool {
+	return u.Scheme == "sqlite"
+}
+
+// IsPostgresScheme returns true if this is a postgres:// or postgresql:// URI
+func (u *StorageURI) IsPostgresScheme() bool {
+	return u.Scheme == "postgres" || u.Scheme == "postgresql"
+}
+
+// PostgresDSN returns the connection string of a PostgreSQL URI. A token
+// USER:PASSWORD, or PASSWORD alone, replaces the credentials of the URI so
+// the password does not have to be part of it.
+// This should only be called for PostgreSQL scheme URIs
+func (u *StorageURI) PostgresDSN(token string) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		Host:     u.Host,
+		Path:     "/" + u.Path,
+		RawQuery: u.Query.Encode(),
+		User:     tokenUser(u.User, token),
+	}
+	return dsn.String()
+}
+
+// tokenUser returns the credentials of a URI with a token USER:PASSWORD,
+// or PASSWORD alone keeping the URI user name, replacing them
+func tokenUser(user *url.Userinfo, token string) *url.Userinfo {
+	if token == "" {
+		return user
+	}
+	name, password, found := strings.Cut(token, ":")
+	if !found {
+		password = token
+		name = ""
+		if user != nil {
+			name = user.Username()
+		}
+	}
+	return url.UserPassword(name, password)
+}
+
+// IsRedisScheme returns true if this is a redis:// or rediss:// URI
+func (u *StorageURI) IsRedisScheme() bool {
+	return u.Scheme == "redis" || u.Scheme == "rediss"
+}
+
+// RedisURL returns the URL the Redis client connects to, without the
+// prefix parameter. A token replaces the credentials of the URI, like
+// PostgresDSN.
+// This should only be called for Redis scheme URIs
+func (u *StorageURI) RedisURL(token string) string {
+	query := url.Values{}
+	for key, values := range u.Query {
+		if key != "prefix" {
+			query[key] = values
+		}
+	}
+	redisURL := url.URL{
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		RawQuery: query.Encode(),
+		User:     tokenUser(u.User, token),
+	}
+	if u.Path != "" {
+		redisURL.Path = "/" + u.Path
+	}
+	return redisURL.String()
+}
+
+// RedisPrefix returns the prefix of the keys holding the data,
+// "cola-registry" by default
+// This should only be called for Redis scheme URIs
+func (u *StorageURI) RedisPrefix() string {
+	if prefix := u.Query.Get("prefix"); prefix != "" {
+		return prefix
+	}
+	return "cola-registry"
+}
+
+// IsLocal returns true for backends storing data on the local disk
+// (file:// and sqlite://), which have no remote end to be unavailable
+func (u *StorageURI) IsLocal() bool {
+	return u.IsFileScheme() || u.IsSQLiteScheme()
+}
+
 // IsOCIScheme returns true if this is an oci:// URI
 func (u *StorageURI) IsOCIScheme() bool {
 	return u.Scheme == "oci"
@@ -238,3 +531,98 @@ func (u *StorageURI) S3Region() string {
 func (u *StorageURI) S3UseSSL() bool {
 	return u.Scheme == "s3"
 }
+
+// IsGCSScheme returns true if this is a gcs:// URI
+func (u *StorageURI) IsGCSScheme() bool {
+	return u.Scheme == "gcs"
+}
+
+// GCSBucket returns the GCS bucket name (the URI host)
+// This should only be called for GCS scheme URIs
+func (u *StorageURI) GCSBucket() string {
+	return u.Host
+}
+
+// GCSObject returns the GCS object name (path after bucket)
+// This should only be called for GCS scheme URIs
+func (u *StorageURI) GCSObject() string {
+	return u.Path
+}
+
+// IsDynamoDBScheme returns true if this is a dynamodb:// URI
+func (u *StorageURI) IsDynamoDBScheme() bool {
+	return u.Scheme == "dynamodb"
+}
+
+// DynamoDBTable returns the DynamoDB table name (the URI host)
+// This should only be called for DynamoDB scheme URIs
+func (u *StorageURI) DynamoDBTable() string {
+	return u.Host
+}
+
+// DynamoDBRegion returns the region from query parameter, or empty if not specified
+// This should only be called for DynamoDB scheme URIs
+func (u *StorageURI) DynamoDBRegion() string {
+	return u.Query.Get("region")
+}
+
+// DynamoDBEndpoint returns the endpoint from query parameter (e.g. DynamoDB
+// Local), or empty for the AWS endpoint of the region
+// This should only be called for DynamoDB scheme URIs
+func (u *StorageURI) DynamoDBEndpoint() string {
+	return u.Query.Get("endpoint")
+}
+
+// IsGitScheme returns true if this is a git://, git+https://, git+http://
+// or git+file:// URI
+func (u *StorageURI) IsGitScheme() bool {
+	return u.Scheme == "git" || strings.HasPrefix(u.Scheme, "git+")
+}
+
+// GitRemote returns the URL the Git repository is cloned from
+// This should only be called for Git scheme URIs
+func (u *StorageURI) GitRemote() string {
+	remote := url.URL{
+		Scheme: strings.TrimPrefix(u.Scheme, "git+"),
+		Host:   u.Host,
+		Path:   u.Path,
+		User:   u.User,
+	}
+	return remote.String()
+}
+
+// GitBranch returns the branch holding the data, "main" by default
+// This should only be called for Git scheme URIs
+func (u *StorageURI) GitBranch() string {
+	if branch := u.Query.Get("branch"); branch != "" {
+		return branch
+	}
+	return "main"
+}
+
+// GitFile returns the path of the data file in the repository,
+// "registry.json" by default
+// This should only be called for Git scheme URIs
+func (u *StorageURI) GitFile() string {
+	if file := u.Query.Get("path"); file != "" {
+		return file
+	}
+	return "registry.json"
+}
+
+// IsWebDAVScheme returns true if this is an http+dav:// or https+dav:// URI
+func (u *StorageURI) IsWebDAVScheme() bool {
+	return u.Scheme == "http+dav" || u.Scheme == "https+dav"
+}
+
+// WebDAVURL returns the http:// or https:// URL of the data file, without
+// the credentials of the URI
+// This should only be called for WebDAV scheme URIs
+func (u *StorageURI) WebDAVURL() string {
+	fileURL := url.URL{
+		Scheme: strings.TrimSuffix(u.Scheme, "+dav"),
+		Host:   u.Host,
+		Path:   u.Path,
+	}
+	return fileURL.String()
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// DataVersion is a state of the stored data kept by the backend, which
+// Versioned.Rollback can restore
+type DataVersion struct {
+	ID         string    `json:"id"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Size       int64     `json:"size"`    // Stored size in bytes
+	Current    bool      `json:"current"` // The version the backend holds now
+}
+
+// Versioned is implemented by backends whose storage keeps prior versions of
+// the data, e.g. S3 buckets with versioning enabled or OCI revision tags
+type Versioned interface {
+	// CurrentVersion returns the ID of the version last loaded or
+	// persisted, empty when the storage does not keep versions
+	CurrentVersion(ctx context.Context) (string, error)
+
+	// Versions lists the versions kept, most recent first
+	Versions(ctx context.Context) ([]DataVersion, error)
+
+	// Rollback replaces the data with the version id and returns the
+	// generation of the restored data. Like a backup restore, it is a write
+	// like any other; ErrVersionNotFound is returned unless Versions lists
+	// id.
+	Rollback(ctx context.Context, id string) (uint64, error)
+}
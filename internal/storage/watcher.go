@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// DefaultWatchInterval is how often a Watcher polls the stored data version
+const DefaultWatchInterval = 30 * time.Second
+
+// watchSettleDelay lets the files of a write settle before a file system
+// notification is checked, so a write touching several files (sharded
+// layout) is reloaded once
+const watchSettleDelay = 200 * time.Millisecond
+
+// watchCheckTimeout bounds a check, reload included
+const watchCheckTimeout = 5 * time.Minute
+
+// Watched is implemented by backends whose stored data can be changed by
+// another writer, and that can tell without loading it
+type Watched interface {
+	Refresher
+
+	// StoredVersion returns the version of the stored data (file sizes and
+	// modification times, S3 ETag, OCI manifest digest), empty when there
+	// is none
+	StoredVersion(ctx context.Context) (string, error)
+
+	// LoadedVersion returns the version of the stored data the in-memory
+	// data was last loaded from or written to
+	LoadedVersion(ctx context.Context) (string, error)
+}
+
+// PathWatched is implemented by Watched backends kept in local files: the
+// directories returned are watched for changes, on top of polling
+type PathWatched interface {
+	WatchPaths() []string
+}
+
+// WatchStats reports the out-of-band changes a Watcher reloaded
+type WatchStats struct {
+	Checks      uint64     `json:"checks"`
+	Reloads     uint64     `json:"reloads"`
+	Failures    uint64     `json:"failures"` // Failed checks and reloads
+	LastReload  *time.Time `json:"last_reload,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Watcher reloads a backend's in-memory data when its stored data changes
+// out-of-band, e.g. edited by hand or written by another instance. It polls
+// the stored version, and also follows file system notifications for
+// backends kept in local files.
+type Watcher struct {
+	backend  Watched
+	interval time.Duration
+	logger   *slog.Logger
+	files    *fsnotify.Watcher // nil unless the backend is PathWatched
+
+	stop chan struct{}
+	done chan struct{}
+
+	mu       sync.Mutex
+	stats    WatchStats
+	handlers []func(ctx context.Context, generation uint64)
+}
+
+// NewWatcher starts watching backend, polling it every interval
+// (DefaultWatchInterval when zero). File system notifications that cannot
+// be set up are logged: polling still catches the changes.
+func NewWatcher(backend Watched, interval time.Duration, logger *slog.Logger) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	w := &Watcher{
+		backend:  backend,
+		interval: interval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if paths, ok := backend.(PathWatched); ok {
+		w.files = w.watchPaths(paths.WatchPaths())
+	}
+	go w.run()
+	return w
+}
+
+// watchPaths sets up the file system notifications of dirs
+func (w *Watcher) watchPaths(dirs []string) *fsnotify.Watcher {
+	files, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.logger.Warn("Failed to watch storage files, polling only", "error", err)
+		return nil
+	}
+	for _, dir := range dirs {
+		if err := files.Add(dir); err != nil {
+			w.logger.Warn("Failed to watch storage directory, polling only",
+				"dir", dir,
+				"error", err)
+		}
+	}
+	return files
+}
+
+// OnReload registers fn, called after each reload with the data generation
+// (see Store.Changes) of the reloaded data, so it can be published on the
+// ChangeFeed: the Redis cache and sync clients otherwise keep following the
+// generation of the data before the reload
+func (w *Watcher) OnReload(fn func(ctx context.Context, generation uint64)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, fn)
+}
+
+// Stats returns the checks and reloads so far
+func (w *Watcher) Stats() WatchStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+// Close stops watching and waits for a running check
+func (w *Watcher) Close() error {
+	close(w.stop)
+	<-w.done
+	if w.files != nil {
+		return w.files.Close()
+	}
+	return nil
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var events chan fsnotify.Event
+	var errs chan error
+	if w.files != nil {
+		events, errs = w.files.Events, w.files.Errors
+	}
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			// Temp files of writes in progress are hidden
+			if !strings.HasPrefix(filepath.Base(event.Name), ".") && settle == nil {
+				settle = time.After(watchSettleDelay)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			w.logger.Warn("Storage file watch error", "error", err)
+		case <-settle:
+			settle = nil
+			w.check()
+		}
+	}
+}
+
+// check reloads the backend if its stored data version is not the one its
+// in-memory data matches
+func (w *Watcher) check() {
+	ctx, cancel := context.WithTimeout(tracing.WithOperation(context.Background(), "watch"), watchCheckTimeout)
+	defer cancel()
+
+	w.mu.Lock()
+	w.stats.Checks++
+	w.mu.Unlock()
+
+	// The loaded version first: it waits for a write in progress
+	loaded, err := w.backend.LoadedVersion(ctx)
+	if err != nil {
+		w.fail("Failed to check storage for out-of-band changes", err)
+		return
+	}
+	stored, err := w.backend.StoredVersion(ctx)
+	if err != nil {
+		w.fail("Failed to check storage for out-of-band changes", err)
+		return
+	}
+	if stored == loaded || stored == "" {
+		return
+	}
+
+	start := time.Now()
+	w.logger.Info("Storage changed out-of-band, reloading",
+		"loaded_version", loaded,
+		"stored_version", stored)
+	if err := w.backend.Refresh(ctx); err != nil {
+		w.fail("Failed to reload storage after an out-of-band change", err)
+		return
+	}
+	now := time.Now().UTC()
+	w.mu.Lock()
+	w.stats.Reloads++
+	w.stats.LastReload = &now
+	handlers := w.handlers
+	w.mu.Unlock()
+	w.logger.Info("Storage reloaded after an out-of-band change",
+		"stored_version", stored,
+		"duration_ms", time.Since(start).Milliseconds())
+
+	if len(handlers) == 0 {
+		return
+	}
+	generation, err := storeGeneration(ctx, w.backend)
+	if err != nil {
+		w.fail("Failed to read the generation of reloaded storage", err)
+		return
+	}
+	for _, fn := range handlers {
+		fn(ctx, generation)
+	}
+}
+
+func (w *Watcher) fail(msg string, err error) {
+	now := time.Now().UTC()
+	w.mu.Lock()
+	w.stats.Failures++
+	w.stats.LastError = err.Error()
+	w.stats.LastErrorAt = &now
+	w.mu.Unlock()
+	w.logger.Warn(msg, "error", err)
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_ReloadsFileChangedOutOfBand(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "registry.json")
+	store, err := NewFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+	other, err := NewFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+
+	// Polling is too slow for the test: the reload comes from fsnotify
+	watcher := NewWatcher(store, time.Hour, newTestS3Logger())
+	defer watcher.Close()
+
+	// Writes of the watched store are not reloaded
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	time.Sleep(2 * watchSettleDelay)
+	assert.Zero(t, watcher.Stats().Reloads)
+
+	// other reads the file as left by store, then changes it
+	require.NoError(t, other.Refresh(ctx))
+	require.NoError(t, other.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)))
+
+	require.Eventually(t, func() bool {
+		_, err := store.GetRegistry(ctx, "games")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	stats := watcher.Stats()
+	assert.Equal(t, uint64(1), stats.Reloads)
+	assert.NotNil(t, stats.LastReload)
+	assert.Zero(t, stats.Failures)
+}
+
+func TestWatcher_PollsStoredVersion(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "registry.json")
+	store, err := NewFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+	other, err := NewFileStorage(path, "", newTestS3Logger())
+	require.NoError(t, err)
+	require.NoError(t, other.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+
+	// Not PathWatched: only polling sees the change
+	watcher := NewWatcher(pollOnly{store}, 20*time.Millisecond, newTestS3Logger())
+	defer watcher.Close()
+
+	require.Eventually(t, func() bool {
+		_, err := store.GetRegistry(ctx, "tools")
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, uint64(1), watcher.Stats().Reloads)
+}
+
+// pollOnly hides the WatchPaths of a FileStorage
+type pollOnly struct {
+	Watched
+}
@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// WebDAVStorage implements Store interface using a file on a WebDAV or
+// plain HTTP server (such as an Artifactory generic repository) as backend.
+// It embeds BaseStorage for in-memory CRUD operations and provides
+// HTTP-based persistence via persist().
+type WebDAVStorage struct {
+	*BaseStorage // Embedded for shared CRUD logic
+	client       *WebDAVClient
+	url          string
+}
+
+// NewWebDAVStorage creates a new WebDAV-backed storage.
+// The uri should be a parsed WebDAV StorageURI (https+dav://host/path/registry.json).
+// The token is USER:PASSWORD for Basic auth or a Bearer token; empty uses
+// the credentials of the URI, if any.
+// opts selects the codec and compression of the uploaded file.
+func NewWebDAVStorage(uri *StorageURI, token string, opts Options, logger *slog.Logger) (*WebDAVStorage, error) {
+	if !uri.IsWebDAVScheme() {
+		return nil, fmt.Errorf("expected WebDAV URI, got scheme: %s", uri.Scheme)
+	}
+	fileURL := uri.WebDAVURL()
+
+	client := NewWebDAVClient(fileURL, uri.User, token, logger)
+	client.codec = opts.Codec
+	client.compression = opts.Compression
+	client.envelope = opts.Envelope
+	if opts.LoadTimeout > WebDAVDownloadTimeout {
+		client.downloadTimeout = opts.LoadTimeout
+	}
+	client.SetRetryPolicy(opts.Retry)
+	if opts.Credentials != nil {
+		client.SetCredentials(opts.Credentials)
+	}
+
+	s := &WebDAVStorage{
+		BaseStorage: NewBaseStorage(logger),
+		client:      client,
+		url:         fileURL,
+	}
+	s.codec = opts.Codec
+	s.readTimeout = opts.ReadTimeout
+	s.writeTimeout = opts.WriteTimeout
+	s.cacheFile = opts.CacheFile
+	s.envelope = opts.Envelope
+
+	// Load existing data from the server or initialize empty storage
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("failed to load data from WebDAV: %w", err)
+	}
+
+	// Initializing the storage in load is persisted immediately
+	s.setPersistMode(opts.PersistMode, opts.FlushInterval, s.persist)
+
+	return s, nil
+}
+
+// load retrieves registry data from the server on startup.
+// If the file doesn't exist, initializes empty storage and uploads it.
+func (s *WebDAVStorage) load() error {
+	ctx := tracing.WithOperation(context.Background(), "load")
+
+	exists, err := s.client.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check WebDAV file existence: %w", err)
+	}
+
+	if !exists {
+		s.logger.Info("WebDAV file does not exist, initializing empty storage",
+			"url", s.url)
+
+		if err := s.persist(ctx); err != nil {
+			return fmt.Errorf("failed to initialize WebDAV storage: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.reload(ctx); err != nil {
+		return err
+	}
+
+	storageData := s.GetData()
+	s.logger.Info("WebDAV storage loaded",
+		"url", s.url,
+		"registry_count", len(storageData.Registries))
+
+	return nil
+}
+
+// Refresh downloads the data again, to catch up with writes made by another
+// instance sharing the file
+func (s *WebDAVStorage) Refresh(ctx context.Context) error {
+	ctx = tracing.WithOperation(ctx, "refresh")
+	if err := s.flushBeforeReload(ctx); err != nil {
+		return err
+	}
+	return s.reload(ctx)
+}
+
+// Ping checks the file can still be reached, for health checks
+func (s *WebDAVStorage) Ping(ctx context.Context) error {
+	_, err := s.client.Exists(tracing.WithOperation(ctx, "ping"))
+	return err
+}
+
+// reload replaces the in-memory data with the stored one
+func (s *WebDAVStorage) reload(ctx context.Context) error {
+	data, err := s.client.Download(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to download from WebDAV: %w", err)
+	}
+	if err := s.UnmarshalData(data); err != nil {
+		return fmt.Errorf("failed to parse registry data (corrupted JSON or CBOR): %w", err)
+	}
+	if s.cacheFile != "" {
+		if cached, err := s.MarshalData(); err == nil {
+			s.writeCache(cached)
+		}
+	}
+	return nil
+}
+
+// persist uploads the complete registry data to the server.
+// NOTE: This is called while BaseStorage holds the lock,
+// so we use marshalDataLocked() to avoid deadlock.
+func (s *WebDAVStorage) persist(ctx context.Context) error {
+	if s.deferPersist() {
+		return nil
+	}
+
+	data, err := s.marshalDataLocked()
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry data: %w", err)
+	}
+
+	if err := s.client.Upload(ctx, data); err != nil {
+		return err // Already categorized by WebDAVClient
+	}
+	s.writeCache(data)
+
+	return nil
+}
+
+// CreateRegistry creates a new registry
+func (s *WebDAVStorage) CreateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.CreateRegistry(ctx, r, s.persist)
+}
+
+// GetRegistry retrieves a registry by name
+func (s *WebDAVStorage) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	return s.BaseStorage.GetRegistry(ctx, name)
+}
+
+// UpdateRegistry updates registry metadata
+func (s *WebDAVStorage) UpdateRegistry(ctx context.Context, r *models.Registry) error {
+	return s.BaseStorage.UpdateRegistry(ctx, r, s.persist)
+}
+
+// DeleteRegistry deletes a registry and all its packages (atomic)
+func (s *WebDAVStorage) DeleteRegistry(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteRegistry(ctx, name, s.persist)
+}
+
+// ListRegistries returns all registries
+func (s *WebDAVStorage) ListRegistries(ctx context.Context) ([]*models.Registry, error) {
+	return s.BaseStorage.ListRegistries(ctx)
+}
+
+// CreatePackage creates a new package in a registry
+func (s *WebDAVStorage) CreatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.CreatePackage(ctx, registryName, p, s.persist)
+}
+
+// GetPackage retrieves a package from a registry
+func (s *WebDAVStorage) GetPackage(ctx context.Context, registryName, packageName string) (*models.Package, error) {
+	return s.BaseStorage.GetPackage(ctx, registryName, packageName)
+}
+
+// UpdatePackage updates package metadata (preserves versions)
+func (s *WebDAVStorage) UpdatePackage(ctx context.Context, registryName string, p *models.Package) error {
+	return s.BaseStorage.UpdatePackage(ctx, registryName, p, s.persist)
+}
+
+// UpdatePackages updates several packages with a single write (atomic)
+func (s *WebDAVStorage) UpdatePackages(ctx context.Context, registryName string, packages []*models.Package) error {
+	return s.BaseStorage.UpdatePackages(ctx, registryName, packages, s.persist)
+}
+
+// DeletePackage deletes a package and all its versions (atomic)
+func (s *WebDAVStorage) DeletePackage(ctx context.Context, registryName, packageName string) error {
+	return s.BaseStorage.DeletePackage(ctx, registryName, packageName, s.persist)
+}
+
+// ListPackages returns all packages in a registry
+func (s *WebDAVStorage) ListPackages(ctx context.Context, registryName string) ([]*models.Package, error) {
+	return s.BaseStorage.ListPackages(ctx, registryName)
+}
+
+// FindPackages returns the packages whose custom values match a query
+func (s *WebDAVStorage) FindPackages(ctx context.Context, registryName string, query models.CustomValueQuery) ([]*models.Package, error) {
+	return s.BaseStorage.FindPackages(ctx, registryName, query)
+}
+
+// GetPackageHistory returns the recorded changes of a package, newest first
+func (s *WebDAVStorage) GetPackageHistory(ctx context.Context, registryName, packageName string) ([]*models.ChangeRecord, error) {
+	return s.BaseStorage.GetPackageHistory(ctx, registryName, packageName)
+}
+
+// GetQuarantine returns the records set aside while loading the data
+func (s *WebDAVStorage) GetQuarantine(ctx context.Context) ([]*models.QuarantinedRecord, error) {
+	return s.BaseStorage.GetQuarantine(ctx)
+}
+
+// GetCertificate returns a stored TLS certificate or ACME key
+func (s *WebDAVStorage) GetCertificate(ctx context.Context, name string) ([]byte, error) {
+	return s.BaseStorage.GetCertificate(ctx, name)
+}
+
+// PutCertificate stores a TLS certificate or ACME key
+func (s *WebDAVStorage) PutCertificate(ctx context.Context, name string, data []byte) error {
+	return s.BaseStorage.PutCertificate(ctx, name, data, s.persist)
+}
+
+// DeleteCertificate removes a stored TLS certificate or ACME key
+func (s *WebDAVStorage) DeleteCertificate(ctx context.Context, name string) error {
+	return s.BaseStorage.DeleteCertificate(ctx, name, s.persist)
+}
+
+// CreateAPIKey stores a new API key
+func (s *WebDAVStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return s.BaseStorage.CreateAPIKey(ctx, key, s.persist)
+}
+
+// GetAPIKey returns an API key by ID
+func (s *WebDAVStorage) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return s.BaseStorage.GetAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns the API keys of a registry
+func (s *WebDAVStorage) ListAPIKeys(ctx context.Context, registryName string) ([]*models.APIKey, error) {
+	return s.BaseStorage.ListAPIKeys(ctx, registryName)
+}
+
+// DeleteAPIKey revokes an API key of a registry
+func (s *WebDAVStorage) DeleteAPIKey(ctx context.Context, registryName, id string) error {
+	return s.BaseStorage.DeleteAPIKey(ctx, registryName, id, s.persist)
+}
+
+// CreateVersion creates a new version for a package
+func (s *WebDAVStorage) CreateVersion(ctx context.Context, registryName, packageName string, v *models.Version) error {
+	return s.BaseStorage.CreateVersion(ctx, registryName, packageName, v, s.persist)
+}
+
+// GetVersion retrieves a specific version
+func (s *WebDAVStorage) GetVersion(ctx context.Context, registryName, packageName, version string) (*models.Version, error) {
+	return s.BaseStorage.GetVersion(ctx, registryName, packageName, version)
+}
+
+// DeleteVersion deletes a specific version
+func (s *WebDAVStorage) DeleteVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.DeleteVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// ListVersions returns all versions for a package
+func (s *WebDAVStorage) ListVersions(ctx context.Context, registryName, packageName string) ([]*models.Version, error) {
+	return s.BaseStorage.ListVersions(ctx, registryName, packageName)
+}
+
+// ReleaseVersion clears the embargo of a scheduled version
+func (s *WebDAVStorage) ReleaseVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.ReleaseVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// CancelVersion deletes a version that is still embargoed
+func (s *WebDAVStorage) CancelVersion(ctx context.Context, registryName, packageName, version string) error {
+	return s.BaseStorage.CancelVersion(ctx, registryName, packageName, version, s.persist)
+}
+
+// GetRegistryIndex generates the registry index (Command Launcher format)
+func (s *WebDAVStorage) GetRegistryIndex(ctx context.Context, registryName string) ([]models.IndexEntry, error) {
+	return s.BaseStorage.GetRegistryIndex(ctx, registryName)
+}
+
+// Changes returns the records changed since a generation (differential sync)
+func (s *WebDAVStorage) Changes(ctx context.Context, since uint64) ([]models.SyncChange, uint64, error) {
+	return s.BaseStorage.Changes(ctx, since)
+}
+
+// ReplaceData replaces all data with a copy of another store's (mirroring)
+func (s *WebDAVStorage) ReplaceData(ctx context.Context, raw []byte) error {
+	return s.BaseStorage.ReplaceData(ctx, raw, s.persist)
+}
+
+// Close flushes the pending writes of debounced mode
+func (s *WebDAVStorage) Close() error {
+	return s.closeWriteBehind()
+}
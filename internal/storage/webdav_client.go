@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// WebDAV timeout constants
+const (
+	WebDAVUploadTimeout   = 60 * time.Second
+	WebDAVDownloadTimeout = 30 * time.Second
+)
+
+// WebDAVClient reads and writes a file over plain HTTP: GET to download and
+// PUT to upload, as accepted by WebDAV servers and Artifactory-style
+// artifact stores. A token USER:PASSWORD authenticates with Basic auth,
+// any other token as a Bearer token.
+type WebDAVClient struct {
+	http            *http.Client
+	url             string        // Of the file, without credentials
+	user            *url.Userinfo // Credentials of the URI, used without a token
+	token           string
+	codec           Codec         // Sets the uploaded Content-Type
+	compression     Compression   // Applied to uploads; downloads detect it
+	envelope        *Envelope     // Encrypts uploads when set; downloads detect it
+	downloadTimeout time.Duration // Bounds Download (WebDAVDownloadTimeout unless set longer for startup)
+	retrier         *retrier      // Retries failed operations (see RetryPolicy)
+	creds           *Credentials  // Tokens, with fallback to the secondary one; nil uses token
+	logger          *slog.Logger
+}
+
+// NewWebDAVClient creates a new WebDAV client for the file at fileURL
+func NewWebDAVClient(fileURL string, user *url.Userinfo, token string, logger *slog.Logger) *WebDAVClient {
+	logger.Info("WebDAV client created",
+		"url", fileURL,
+		"credentials", webDAVCredentialKind(token, user))
+
+	// Tag every WebDAV request with the request ID and operation behind it
+	// (see tracingTransport)
+	return &WebDAVClient{
+		http:            &http.Client{Transport: newTracingTransport(backendTransport(), logger)},
+		url:             fileURL,
+		user:            user,
+		token:           token,
+		downloadTimeout: WebDAVDownloadTimeout,
+		retrier:         newRetrier(DefaultRetryPolicy(), logger),
+		logger:          logger,
+	}
+}
+
+// SetCredentials makes the client authenticate with the tokens of creds,
+// falling back to their secondary token, instead of the token it was
+// created with. It must be called before any operation.
+func (c *WebDAVClient) SetCredentials(creds *Credentials) {
+	c.creds = creds
+	creds.setCheck(func(ctx context.Context) error {
+		_, err := c.exists(ctx)
+		return err
+	})
+}
+
+// SetRetryPolicy changes how failed operations are retried
+func (c *WebDAVClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retrier = newRetrier(policy, c.logger)
+}
+
+// Exists checks if the file exists on the server
+func (c *WebDAVClient) Exists(ctx context.Context) (bool, error) {
+	var exists bool
+	err := c.retrier.do(ctx, WebDAVOpConnect, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			exists, err = c.exists(ctx)
+			return err
+		})
+	})
+	return exists, err
+}
+
+func (c *WebDAVClient) exists(ctx context.Context) (bool, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Debug("Checking WebDAV file existence", "url", c.url)
+
+	resp, err := c.do(ctx, http.MethodHead, c.url, nil, "")
+	if err != nil {
+		if isWebDAVStatus(err, http.StatusNotFound) {
+			logger.Info("WebDAV file does not exist",
+				"url", c.url,
+				"duration_ms", time.Since(start).Milliseconds())
+			return false, nil
+		}
+		logger.Error("WebDAV existence check failed",
+			"url", c.url,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return false, CategorizeWebDAVError(WebDAVOpConnect, err)
+	}
+	resp.Body.Close()
+
+	logger.Info("WebDAV file exists",
+		"url", c.url,
+		"duration_ms", time.Since(start).Milliseconds())
+	return true, nil
+}
+
+// Upload uploads data to the server, compressing it if the client was
+// configured to. The compression is not declared as Content-Encoding, which
+// servers may undo on download; downloads detect it instead.
+func (c *WebDAVClient) Upload(ctx context.Context, data []byte) error {
+	return c.retrier.do(ctx, WebDAVOpUpload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			return c.upload(ctx, data)
+		})
+	})
+}
+
+func (c *WebDAVClient) upload(ctx context.Context, data []byte) error {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	size := len(data)
+
+	data, err := compressData(c.compression, data)
+	if err != nil {
+		return CategorizeWebDAVError(WebDAVOpUpload, err)
+	}
+	if data, err = c.envelope.Seal(data); err != nil {
+		return CategorizeWebDAVError(WebDAVOpUpload, err)
+	}
+	logger.Info("Starting WebDAV upload",
+		"url", c.url,
+		"size_bytes", size,
+		"stored_bytes", len(data),
+		"compression", c.compression)
+
+	// Apply timeout
+	ctx, cancel := context.WithTimeout(ctx, WebDAVUploadTimeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodPut, c.url, data, c.codec.ContentType())
+	// WebDAV servers answer 409 when the parent collection is missing
+	if isWebDAVStatus(err, http.StatusConflict) {
+		if err = c.makeCollections(ctx); err == nil {
+			resp, err = c.do(ctx, http.MethodPut, c.url, data, c.codec.ContentType())
+		}
+	}
+	if err != nil {
+		logger.Error("WebDAV upload failed",
+			"url", c.url,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return CategorizeWebDAVError(WebDAVOpUpload, err)
+	}
+	resp.Body.Close()
+
+	logger.Info("WebDAV upload completed",
+		"url", c.url,
+		"size_bytes", size,
+		"stored_bytes", len(data),
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// makeCollections creates the missing collections above the file with
+// MKCOL, from the root down. Existing collections answer 405.
+func (c *WebDAVClient) makeCollections(ctx context.Context) error {
+	fileURL, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	segments := strings.Split(strings.Trim(fileURL.Path, "/"), "/")
+	collection := *fileURL
+	collection.Path = ""
+	for _, segment := range segments[:len(segments)-1] {
+		collection.Path += "/" + segment
+		resp, err := c.do(ctx, "MKCOL", collection.String()+"/", nil, "")
+		if err != nil {
+			if isWebDAVStatus(err, http.StatusMethodNotAllowed) {
+				continue
+			}
+			return err
+		}
+		resp.Body.Close()
+		c.logger.Info("WebDAV collection created", "url", collection.String()+"/")
+	}
+	return nil
+}
+
+// Download downloads data from the server
+func (c *WebDAVClient) Download(ctx context.Context) ([]byte, error) {
+	var data []byte
+	err := c.retrier.do(ctx, WebDAVOpDownload, func(ctx context.Context) error {
+		return c.creds.do(ctx, func(ctx context.Context) error {
+			var err error
+			data, err = c.download(ctx)
+			return err
+		})
+	})
+	return data, err
+}
+
+func (c *WebDAVClient) download(ctx context.Context) ([]byte, error) {
+	logger := c.logger.With(tracing.LogAttrs(ctx)...)
+	start := time.Now()
+	logger.Debug("Starting WebDAV download", "url", c.url)
+
+	// Apply timeout
+	ctx, cancel := context.WithTimeout(ctx, c.downloadTimeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodGet, c.url, nil, "")
+	if err != nil {
+		logger.Error("WebDAV download failed",
+			"url", c.url,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return nil, CategorizeWebDAVError(WebDAVOpDownload, err)
+	}
+	defer resp.Body.Close()
+	logger.Info("Downloading WebDAV file",
+		"url", c.url,
+		"total_bytes", resp.ContentLength)
+
+	stored, err := io.ReadAll(newProgressReader(resp.Body, resp.ContentLength, logger, "url", c.url))
+	if err != nil {
+		logger.Error("WebDAV download read failed",
+			"url", c.url,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds())
+		return nil, CategorizeWebDAVError(WebDAVOpDownload, err)
+	}
+
+	opened, err := c.envelope.Open(stored)
+	if err != nil {
+		logger.Error("WebDAV download decryption failed",
+			"url", c.url,
+			"error", err)
+		return nil, CategorizeWebDAVError(WebDAVOpDownload, err)
+	}
+	data, compression, err := decompressData(opened)
+	if err != nil {
+		logger.Error("WebDAV download decompression failed",
+			"url", c.url,
+			"compression", compression,
+			"error", err)
+		return nil, CategorizeWebDAVError(WebDAVOpDownload, err)
+	}
+
+	logger.Info("WebDAV download completed",
+		"url", c.url,
+		"size_bytes", len(data),
+		"stored_bytes", len(stored),
+		"compression", compression,
+		"duration_ms", time.Since(start).Milliseconds())
+	return data, nil
+}
+
+// do sends an authenticated request, returning the response of a 2xx
+// answer and a webDAVStatusError for the others
+func (c *WebDAVClient) do(ctx context.Context, method, target string, body []byte, contentType string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.authorize(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, newWebDAVStatusError(resp, answer)
+	}
+	return resp, nil
+}
+
+// authorize adds the credentials of the token in use to req
+func (c *WebDAVClient) authorize(req *http.Request) {
+	token := c.token
+	if c.creds != nil {
+		token, _ = c.creds.current()
+	}
+	switch {
+	case token == "" && c.user != nil:
+		password, _ := c.user.Password()
+		req.SetBasicAuth(c.user.Username(), password)
+	case strings.Contains(token, ":"):
+		user, password, _ := strings.Cut(token, ":")
+		req.SetBasicAuth(user, password)
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func isWebDAVStatus(err error, code int) bool {
+	var statusErr *webDAVStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == code
+}
+
+// webDAVCredentialKind describes the credentials of a token for logging
+func webDAVCredentialKind(token string, user *url.Userinfo) string {
+	switch {
+	case strings.Contains(token, ":"), token == "" && user != nil:
+		return "basic"
+	case token != "":
+		return "bearer"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WebDAV error categories for clear error messages
+const (
+	WebDAVCategoryAuth    = "authentication"
+	WebDAVCategoryNetwork = "network"
+	WebDAVCategoryStorage = "storage"
+)
+
+// WebDAV operations for error context
+const (
+	WebDAVOpUpload   = "upload"
+	WebDAVOpDownload = "download"
+	WebDAVOpConnect  = "connect"
+)
+
+// WebDAVError wraps WebDAV/HTTP-specific failures with categorization
+type WebDAVError struct {
+	Category  string // "authentication", "network", or "storage"
+	Op        string // "upload", "download", or "connect"
+	Err       error  // Underlying error
+	Retryable bool   // The operation may succeed if retried (see RetryPolicy)
+}
+
+// Error implements the error interface
+func (e *WebDAVError) Error() string {
+	return fmt.Sprintf("WebDAV %s error during %s: %v", e.Category, e.Op, e.Err)
+}
+
+// Unwrap implements the errors.Unwrap interface
+func (e *WebDAVError) Unwrap() error {
+	return e.Err
+}
+
+// Is implements the errors.Is interface to match ErrStorageUnavailable
+func (e *WebDAVError) Is(target error) bool {
+	return target == ErrStorageUnavailable
+}
+
+// webDAVStatusError is a non-2xx answer of the WebDAV server
+type webDAVStatusError struct {
+	StatusCode int
+	Message    string // Start of the answer body, if text
+}
+
+func (e *webDAVStatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// newWebDAVStatusError reads the error answer of a WebDAV request
+func newWebDAVStatusError(resp *http.Response, body []byte) *webDAVStatusError {
+	message := http.StatusText(resp.StatusCode)
+	contentType := resp.Header.Get("Content-Type")
+	if text := strings.TrimSpace(string(body)); text != "" && (strings.HasPrefix(contentType, "text/plain") || strings.HasPrefix(contentType, "application/json")) {
+		if len(text) > 200 {
+			text = text[:200] + "..."
+		}
+		message = text
+	}
+	return &webDAVStatusError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// CategorizeWebDAVError examines an error and returns an appropriately
+// categorized WebDAVError: 401/403 answers are authentication errors,
+// transport failures are network errors, and other answers are storage
+// errors, retryable for 408, 429 and 5xx.
+func CategorizeWebDAVError(op string, err error) *WebDAVError {
+	if err == nil {
+		return nil
+	}
+
+	var webdavErr *WebDAVError
+	if errors.As(err, &webdavErr) {
+		return webdavErr
+	}
+
+	var statusErr *webDAVStatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusUnauthorized:
+			return &WebDAVError{Category: WebDAVCategoryAuth, Op: op, Err: fmt.Errorf("authentication failed: %v (verify --storage-token)", statusErr)}
+		case code == http.StatusForbidden:
+			return &WebDAVError{Category: WebDAVCategoryAuth, Op: op, Err: fmt.Errorf("access denied: %v (the token needs read and write access to the file)", statusErr)}
+		case code == http.StatusNotFound:
+			return &WebDAVError{Category: WebDAVCategoryStorage, Op: op, Err: fmt.Errorf("not found: verify the URI")}
+		case code == http.StatusConflict && op == WebDAVOpUpload:
+			return &WebDAVError{Category: WebDAVCategoryStorage, Op: op, Err: fmt.Errorf("parent collection does not exist and could not be created: %v", statusErr)}
+		default:
+			return &WebDAVError{
+				Category:  WebDAVCategoryStorage,
+				Op:        op,
+				Err:       statusErr,
+				Retryable: code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout,
+			}
+		}
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		if (netErr != nil && netErr.Timeout()) || (urlErr != nil && urlErr.Timeout()) {
+			return &WebDAVError{Category: WebDAVCategoryNetwork, Op: op, Err: fmt.Errorf("network timeout: unable to reach WebDAV server"), Retryable: true}
+		}
+		return &WebDAVError{Category: WebDAVCategoryNetwork, Op: op, Err: fmt.Errorf("network error: unable to reach WebDAV server: %w", err), Retryable: true}
+	}
+
+	return &WebDAVError{Category: WebDAVCategoryStorage, Op: op, Err: err}
+}
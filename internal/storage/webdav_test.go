@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebDAVStorage_InvalidScheme(t *testing.T) {
+	uri := &StorageURI{
+		Scheme: "file",
+		Path:   "./test/data.json",
+		Raw:    "file://./test/data.json",
+	}
+
+	_, err := NewWebDAVStorage(uri, "", Options{}, newTestS3Logger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected WebDAV URI")
+}
+
+func TestCategorizeWebDAVError(t *testing.T) {
+	tests := []struct {
+		name      string
+		op        string
+		err       error
+		category  string
+		retryable bool
+	}{
+		{"unauthorized", WebDAVOpDownload, &webDAVStatusError{StatusCode: 401}, WebDAVCategoryAuth, false},
+		{"forbidden", WebDAVOpUpload, &webDAVStatusError{StatusCode: 403}, WebDAVCategoryAuth, false},
+		{"not found", WebDAVOpDownload, &webDAVStatusError{StatusCode: 404}, WebDAVCategoryStorage, false},
+		{"missing collection", WebDAVOpUpload, &webDAVStatusError{StatusCode: 409}, WebDAVCategoryStorage, false},
+		{"rate limited", WebDAVOpUpload, &webDAVStatusError{StatusCode: 429}, WebDAVCategoryStorage, true},
+		{"unavailable", WebDAVOpUpload, &webDAVStatusError{StatusCode: 503}, WebDAVCategoryStorage, true},
+		{"bad request", WebDAVOpUpload, &webDAVStatusError{StatusCode: 400}, WebDAVCategoryStorage, false},
+		{"network", WebDAVOpConnect, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, WebDAVCategoryNetwork, true},
+		{"other", WebDAVOpDownload, errors.New("boom"), WebDAVCategoryStorage, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CategorizeWebDAVError(tt.op, tt.err)
+			assert.Equal(t, tt.category, err.Category)
+			assert.Equal(t, tt.retryable, Retryable(err))
+			assert.Equal(t, tt.category == WebDAVCategoryAuth, IsAuthError(err))
+			assert.Equal(t, tt.category, ErrorCategory(err))
+			assert.ErrorIs(t, err, ErrStorageUnavailable)
+		})
+	}
+}
+
+func TestWebDAVClient_Authorization(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		user          *url.Userinfo
+		token         string
+		authorization string
+	}{
+		{"bearer token", nil, "artifact-token", "Bearer artifact-token"},
+		{"basic token", nil, "ci:secret", "Basic Y2k6c2VjcmV0"},
+		{"token over URI credentials", url.UserPassword("uri", "password"), "artifact-token", "Bearer artifact-token"},
+		{"URI credentials", url.UserPassword("ci", "secret"), "", "Basic Y2k6c2VjcmV0"},
+		{"anonymous", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			client := NewWebDAVClient(server.URL+"/registry.json", tt.user, tt.token, newTestS3Logger())
+			exists, err := client.Exists(t.Context())
+			require.NoError(t, err)
+			assert.False(t, exists)
+			assert.Equal(t, []string{tt.authorization}, seen)
+		})
+	}
+}
+
+func TestWebDAVClient_TokenRotation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secondary-token" {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, "token expired")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	logger := newTestS3Logger()
+	client := NewWebDAVClient(server.URL+"/registry.json", nil, "primary-token", logger)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	client.SetCredentials(NewCredentials("primary-token", "secondary-token", logger))
+
+	exists, err := client.Exists(t.Context())
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, CredentialSecondary, client.creds.Status().Active,
+		"the rejected primary token falls back to the secondary one")
+}
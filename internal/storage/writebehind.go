@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/tracing"
+)
+
+// PersistMode decides when S3, GCS, OCI and WebDAV storage persist writes
+type PersistMode string
+
+const (
+	PersistImmediate PersistMode = "immediate" // Each write, before it returns
+	PersistDebounced PersistMode = "debounced" // In the background, the writes of a flush interval at once
+)
+
+// DefaultFlushInterval is how long debounced writes wait to be persisted
+const DefaultFlushInterval = 5 * time.Second
+
+// ParsePersistMode parses a persist mode name (immediate|debounced). An
+// empty name means immediate.
+func ParsePersistMode(name string) (PersistMode, error) {
+	switch PersistMode(name) {
+	case "", PersistImmediate:
+		return PersistImmediate, nil
+	case PersistDebounced:
+		return PersistDebounced, nil
+	default:
+		return "", fmt.Errorf("unsupported storage persist mode %q (must be immediate or debounced)", name)
+	}
+}
+
+// WriteBehind is implemented by backends that can persist writes in the
+// background (PersistDebounced)
+type WriteBehind interface {
+	// PendingWrites returns the writes not persisted yet, and false when
+	// writes are persisted immediately
+	PendingWrites() (PendingWrites, bool)
+
+	// Flush persists the pending writes
+	Flush(ctx context.Context) error
+}
+
+// PendingWrites reports the writes of a debounced backend
+type PendingWrites struct {
+	Count       int        `json:"count"`                   // Writes not persisted yet
+	Since       *time.Time `json:"since,omitempty"`         // Oldest write not persisted yet
+	LastFlush   *time.Time `json:"last_flush,omitempty"`    // Last successful flush
+	LastError   string     `json:"last_error,omitempty"`    // Error of the last flush, absent once one succeeds
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"` // Time of the last failed flush
+}
+
+// writeBehind defers the persist callback of a backend in debounced mode:
+// writes only mark the data pending, and a flush persists them all at once
+// after the flush interval. A failed flush keeps them pending and is retried
+// after another interval.
+type writeBehind struct {
+	base     *BaseStorage
+	persist  PersistFunc
+	interval time.Duration
+
+	// Set while a flush runs persist; only changed under base.mu
+	flushing bool
+
+	mu          sync.Mutex
+	pending     int
+	since       time.Time
+	timer       *time.Timer // nil when no flush is scheduled
+	closed      bool
+	lastFlush   time.Time
+	lastErr     string
+	lastErrorAt time.Time
+}
+
+// setPersistMode makes the backend's persist callback run in the background
+// in debounced mode; the backend calls deferPersist first thing in persist.
+// interval is DefaultFlushInterval when zero.
+func (b *BaseStorage) setPersistMode(mode PersistMode, interval time.Duration, persist PersistFunc) {
+	if mode != PersistDebounced {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	b.writeBehind = &writeBehind{
+		base:     b,
+		persist:  persist,
+		interval: interval,
+	}
+}
+
+// deferPersist marks the data pending in debounced mode, in which case the
+// backend's persist callback returns without persisting. It is called with
+// the lock held, like the callback.
+func (b *BaseStorage) deferPersist() bool {
+	w := b.writeBehind
+	if w == nil || w.flushing {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending++
+	if w.pending == 1 {
+		w.since = time.Now().UTC()
+	}
+	w.scheduleLocked()
+	return true
+}
+
+// PendingWrites returns the writes not persisted yet, and false unless the
+// backend is in debounced mode
+func (b *BaseStorage) PendingWrites() (PendingWrites, bool) {
+	w := b.writeBehind
+	if w == nil {
+		return PendingWrites{}, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := PendingWrites{Count: w.pending, LastError: w.lastErr}
+	if w.pending > 0 {
+		since := w.since
+		pending.Since = &since
+	}
+	if !w.lastFlush.IsZero() {
+		lastFlush := w.lastFlush
+		pending.LastFlush = &lastFlush
+	}
+	if w.lastErr != "" {
+		lastErrorAt := w.lastErrorAt
+		pending.LastErrorAt = &lastErrorAt
+	}
+	return pending, true
+}
+
+// Flush persists the pending writes of a backend in debounced mode
+func (b *BaseStorage) Flush(ctx context.Context) error {
+	w := b.writeBehind
+	if w == nil {
+		return nil
+	}
+	ctx, unlock, err := b.lock(ctx, "flush")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return w.flushLocked(ctx)
+}
+
+// flushBeforeReload persists the pending writes before the data is reloaded
+// from the backend, which would drop them. Writes refused because another
+// writer changed the data are dropped by the reload: they cannot be
+// persisted without overwriting the other writer's.
+func (b *BaseStorage) flushBeforeReload(ctx context.Context) error {
+	err := b.Flush(ctx)
+	if errors.Is(err, ErrConflictRemoteModified) {
+		pending, _ := b.PendingWrites()
+		b.logger.Warn("Dropping pending storage writes refused by a conflict",
+			"pending_writes", pending.Count,
+			"error", err)
+		return nil
+	}
+	return err
+}
+
+// discardPending forgets the pending writes once the data they changed is
+// replaced; called with the lock held
+func (b *BaseStorage) discardPending() {
+	w := b.writeBehind
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = 0
+	w.since = time.Time{}
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// closeWriteBehind stops the background flushes, then flushes the pending
+// writes
+func (b *BaseStorage) closeWriteBehind() error {
+	w := b.writeBehind
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.mu.Unlock()
+	if err := b.Flush(tracing.WithOperation(context.Background(), "close")); err != nil {
+		pending, _ := b.PendingWrites()
+		return fmt.Errorf("failed to flush %d pending storage writes: %w", pending.Count, err)
+	}
+	return nil
+}
+
+// scheduleLocked starts the timer of the next flush unless one is scheduled
+// or the backend is closed; called with w.mu held
+func (w *writeBehind) scheduleLocked() {
+	if w.timer == nil && !w.closed {
+		w.timer = time.AfterFunc(w.interval, w.flushInBackground)
+	}
+}
+
+// flushLocked persists the pending writes; called with the base lock held
+func (w *writeBehind) flushLocked(ctx context.Context) error {
+	w.mu.Lock()
+	pending := w.pending
+	w.mu.Unlock()
+	if pending == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	w.flushing = true
+	err := w.persist(ctx)
+	w.flushing = false
+	w.base.recordPersist(err)
+
+	now := time.Now().UTC()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if err != nil {
+		w.lastErr = err.Error()
+		w.lastErrorAt = now
+		w.scheduleLocked()
+		return persistError(ctx, err)
+	}
+	w.pending = 0
+	w.since = time.Time{}
+	w.lastFlush = now
+	w.lastErr = ""
+	w.base.logger.Debug("Pending storage writes flushed",
+		"writes", pending,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+func (w *writeBehind) flushInBackground() {
+	w.mu.Lock()
+	w.timer = nil
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return
+	}
+
+	if err := w.base.Flush(tracing.WithOperation(context.Background(), "flush")); err != nil {
+		w.mu.Lock()
+		w.scheduleLocked()
+		w.mu.Unlock()
+		pending, _ := w.base.PendingWrites()
+		w.base.logger.Error("Failed to flush pending storage writes, retrying",
+			"pending_writes", pending.Count,
+			"retry_in", w.interval.String(),
+			"error", err)
+	}
+}
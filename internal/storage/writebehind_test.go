@@ -0,0 +1,93 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestS3Storage_DebouncedWrites(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, "bucket")
+	uri, err := storage.ParseStorageURI(strings.Replace(s3.URL, "http://", "s3+http://", 1) + "/bucket/registry.json?region=us-east-1")
+	require.NoError(t, err)
+	open := func(interval time.Duration) *storage.S3Storage {
+		store, err := storage.NewS3Storage(uri, "ACCESSKEY:SECRETKEY", storage.Options{
+			Retry:         storage.RetryPolicy{MaxAttempts: 1},
+			PersistMode:   storage.PersistDebounced,
+			FlushInterval: interval,
+		}, newConformanceLogger())
+		require.NoError(t, err)
+		return store
+	}
+
+	t.Run("BatchesWrites", func(t *testing.T) {
+		store := open(time.Hour)
+		puts := s3.Puts()
+
+		require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+		for i := range 50 {
+			require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage(fmt.Sprintf("tool-%d", i), "", nil, nil)))
+		}
+		assert.Equal(t, puts, s3.Puts(), "writes persisted before the flush")
+		pending, ok := store.PendingWrites()
+		require.True(t, ok)
+		assert.Equal(t, 51, pending.Count)
+		assert.NotNil(t, pending.Since)
+
+		require.NoError(t, store.Flush(ctx))
+		assert.Equal(t, puts+1, s3.Puts())
+		pending, _ = store.PendingWrites()
+		assert.Zero(t, pending.Count)
+		assert.NotNil(t, pending.LastFlush)
+
+		packages, err := open(time.Hour).ListPackages(ctx, "tools")
+		require.NoError(t, err)
+		assert.Len(t, packages, 50)
+	})
+
+	t.Run("FlushesInBackground", func(t *testing.T) {
+		store := open(20 * time.Millisecond)
+		require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("games", "", nil, nil)))
+		require.Eventually(t, func() bool {
+			pending, _ := store.PendingWrites()
+			return pending.Count == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		_, err := open(time.Hour).GetRegistry(ctx, "games")
+		assert.NoError(t, err)
+	})
+
+	t.Run("RetriesFailedFlush", func(t *testing.T) {
+		store := open(time.Hour)
+		require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("books", "", nil, nil)))
+
+		s3.FailPuts(true)
+		assert.Error(t, store.Flush(ctx))
+		s3.FailPuts(false)
+		pending, _ := store.PendingWrites()
+		assert.Equal(t, 1, pending.Count, "failed flush dropped the writes")
+		assert.NotEmpty(t, pending.LastError)
+
+		_, err := store.GetRegistry(ctx, "books")
+		assert.NoError(t, err, "failed flush rolled the write back")
+		require.NoError(t, store.Flush(ctx))
+		pending, _ = store.PendingWrites()
+		assert.Empty(t, pending.LastError)
+	})
+
+	t.Run("FlushesOnClose", func(t *testing.T) {
+		store := open(time.Hour)
+		require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("music", "", nil, nil)))
+		require.NoError(t, store.Close())
+		_, err := open(time.Hour).GetRegistry(ctx, "music")
+		assert.NoError(t, err)
+	})
+}
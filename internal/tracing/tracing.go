@@ -0,0 +1,69 @@
+// Package tracing carries the identifiers that tie storage backend calls to
+// the API request and storage operation that caused them, so the calls can
+// be found in our logs and quoted to a backend provider's support.
+package tracing
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+type requestIDKey struct{}
+type operationKey struct{}
+
+// validRequestID accepts request IDs given by clients or proxies that are
+// safe to log and forward in headers
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// NewRequestID returns a fresh request ID
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// ValidRequestID reports whether a request ID received from a client can
+// be reused as is
+func ValidRequestID(id string) bool {
+	return validRequestID.MatchString(id)
+}
+
+// WithRequestID returns a copy of ctx carrying a request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithOperation returns a copy of ctx carrying the storage operation in
+// progress (e.g. create_version), with a fresh request ID if ctx has none,
+// as for operations started by the server itself
+func WithOperation(ctx context.Context, operation string) context.Context {
+	if RequestID(ctx) == "" {
+		ctx = WithRequestID(ctx, NewRequestID())
+	}
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// Operation returns the storage operation carried by ctx, or ""
+func Operation(ctx context.Context) string {
+	operation, _ := ctx.Value(operationKey{}).(string)
+	return operation
+}
+
+// LogAttrs returns the request ID and operation carried by ctx as slog
+// key-value pairs, omitting those not set
+func LogAttrs(ctx context.Context) []any {
+	var attrs []any
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, "request_id", id)
+	}
+	if operation := Operation(ctx); operation != "" {
+		attrs = append(attrs, "operation", operation)
+	}
+	return attrs
+}
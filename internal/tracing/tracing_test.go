@@ -0,0 +1,31 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithOperation(t *testing.T) {
+	// Operations of a request keep its ID
+	ctx := WithOperation(WithRequestID(context.Background(), "req-1"), "create_version")
+	assert.Equal(t, "req-1", RequestID(ctx))
+	assert.Equal(t, "create_version", Operation(ctx))
+	assert.Equal(t, []any{"request_id", "req-1", "operation", "create_version"}, LogAttrs(ctx))
+
+	// Operations started by the server get their own
+	ctx = WithOperation(context.Background(), "release_version")
+	assert.NotEmpty(t, RequestID(ctx))
+	assert.Empty(t, LogAttrs(context.Background()))
+}
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, ValidRequestID("5f0c6b7e-1b7a-4a56-9d1e-3f5a1c2b9e10"))
+	assert.True(t, ValidRequestID("1-67891233-abcdef012345678912345678"))
+	assert.False(t, ValidRequestID(""))
+	assert.False(t, ValidRequestID("id with spaces"))
+	assert.False(t, ValidRequestID("id\r\nX-Injected: 1"))
+	assert.False(t, ValidRequestID(strings.Repeat("a", 129)))
+}
@@ -0,0 +1,221 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// linkTimeout bounds the check of one URL
+const linkTimeout = 30 * time.Second
+
+// linkWorkers is the number of URLs checked concurrently
+const linkWorkers = 8
+
+// Link is the reachability of a version URL
+type Link struct {
+	Registry      string     `json:"registry"`
+	Package       string     `json:"package"`
+	Version       string     `json:"version"`
+	URL           string     `json:"url"`
+	Reachable     bool       `json:"reachable"`
+	StatusCode    int        `json:"status_code,omitempty"` // Of the last check, when the host answered
+	Error         string     `json:"error,omitempty"`
+	CheckedAt     time.Time  `json:"checked_at"`
+	BrokenSince   *time.Time `json:"broken_since,omitempty"`   // First failed check of the current outage
+	LastReachable *time.Time `json:"last_reachable,omitempty"` // Since startup
+}
+
+func (l Link) key() string {
+	return l.Registry + "/" + l.Package + "/" + l.Version
+}
+
+// LinkChecker checks the URL of every version on each run. Versions broken
+// for longer than yankAfter are deleted, as the registry has no other way
+// to withdraw a version; yankAfter 0 only reports them.
+type LinkChecker struct {
+	store     storage.Store
+	yankAfter time.Duration
+	client    *http.Client
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	links   map[string]Link // By version key
+	lastRun time.Time
+}
+
+// NewLinkChecker creates a link checker
+func NewLinkChecker(store storage.Store, yankAfter time.Duration, logger *slog.Logger) *LinkChecker {
+	return &LinkChecker{
+		store:     store,
+		yankAfter: yankAfter,
+		client:    newHTTPClient(),
+		logger:    logger,
+		links:     make(map[string]Link),
+	}
+}
+
+// Run checks every version URL; it is the scheduled job
+func (c *LinkChecker) Run(ctx context.Context) error {
+	registries, err := c.store.ListRegistries(ctx)
+	if err != nil {
+		return err
+	}
+	var targets []Link
+	for _, registry := range registries {
+		for _, pkg := range registry.Packages {
+			for _, ver := range pkg.Versions {
+				targets = append(targets, Link{Registry: registry.Name, Package: pkg.Name, Version: ver.Version, URL: ver.URL})
+			}
+		}
+	}
+
+	checked := make([]Link, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < linkWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				checked[i] = c.check(ctx, targets[i])
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := time.Now().UTC()
+	links := make(map[string]Link, len(checked))
+	c.mu.Lock()
+	for _, link := range checked {
+		previous, seen := c.links[link.key()]
+		// A changed URL starts over
+		if seen && previous.URL == link.URL {
+			if !link.Reachable {
+				link.BrokenSince = previous.BrokenSince
+			}
+			if link.LastReachable == nil {
+				link.LastReachable = previous.LastReachable
+			}
+		}
+		if !link.Reachable && link.BrokenSince == nil {
+			brokenSince := link.CheckedAt
+			link.BrokenSince = &brokenSince
+		}
+		links[link.key()] = link
+	}
+	c.links = links
+	c.lastRun = now
+	c.mu.Unlock()
+
+	for _, link := range checked {
+		if link.Reachable {
+			continue
+		}
+		link = links[link.key()]
+		c.logger.Warn("Version URL is broken",
+			"registry", link.Registry,
+			"package", link.Package,
+			"version", link.Version,
+			"url", link.URL,
+			"status_code", link.StatusCode,
+			"error", link.Error,
+			"broken_since", link.BrokenSince)
+		if c.yankAfter > 0 && now.Sub(*link.BrokenSince) >= c.yankAfter {
+			c.yank(ctx, link)
+		}
+	}
+	return nil
+}
+
+// check requests url with HEAD, falling back to a one-byte GET for hosts
+// that do not support HEAD
+func (c *LinkChecker) check(ctx context.Context, link Link) Link {
+	link.CheckedAt = time.Now().UTC()
+	status, err := c.request(ctx, http.MethodHead, link.URL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusForbidden || status == http.StatusNotImplemented) {
+		status, err = c.request(ctx, http.MethodGet, link.URL)
+	}
+	link.StatusCode = status
+	switch {
+	case err != nil:
+		link.Error = err.Error()
+	case status >= 200 && status < 300:
+		link.Reachable = true
+		checkedAt := link.CheckedAt
+		link.LastReachable = &checkedAt
+	default:
+		link.Error = fmt.Sprintf("HTTP %d", status)
+	}
+	return link
+}
+
+func (c *LinkChecker) request(ctx context.Context, method, url string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, linkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// yank deletes a version whose URL has been broken for too long
+func (c *LinkChecker) yank(ctx context.Context, link Link) {
+	err := c.store.DeleteVersion(ctx, link.Registry, link.Package, link.Version)
+	if err != nil && err != storage.ErrNotFound {
+		c.logger.Error("Failed to yank version with broken URL",
+			"registry", link.Registry,
+			"package", link.Package,
+			"version", link.Version,
+			"error", err)
+		return
+	}
+	c.logger.Warn("Yanked version with broken URL",
+		"registry", link.Registry,
+		"package", link.Package,
+		"version", link.Version,
+		"url", link.URL,
+		"broken_since", link.BrokenSince)
+
+	c.mu.Lock()
+	delete(c.links, link.key())
+	c.mu.Unlock()
+}
+
+// Broken returns the broken links of a registry and the time of the last run
+func (c *LinkChecker) Broken(registryName string) ([]Link, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	broken := []Link{}
+	for _, link := range c.links {
+		if link.Registry == registryName && !link.Reachable {
+			broken = append(broken, link)
+		}
+	}
+	sort.Slice(broken, func(i, j int) bool { return broken[i].key() < broken[j].key() })
+	return broken, c.lastRun
+}
@@ -0,0 +1,86 @@
+package verify
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+func TestLinkChecker(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	host := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok.zip":
+		case "/no-head.zip":
+			// Some hosts only answer GET
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			assert.Equal(t, "bytes=0-0", r.Header.Get("Range"))
+			w.WriteHeader(http.StatusPartialContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer host.Close()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, models.NewRegistry("tools", "", nil, nil)))
+	require.NoError(t, store.CreatePackage(ctx, "tools", models.NewPackage("deployer", "", nil, nil)))
+	for i, version := range []string{"1.0.0", "1.0.1", "1.0.2"} {
+		file := []string{"ok", "no-head", "gone"}[i]
+		require.NoError(t, store.CreateVersion(ctx, "tools", "deployer",
+			models.NewVersion("deployer", version, "sha256:00", host.URL+"/"+file+".zip", i, i)))
+	}
+
+	// Without yanking, broken links are only reported
+	checker := NewLinkChecker(store, 0, logger)
+	links, lastRun := checker.Broken("tools")
+	assert.Empty(t, links)
+	assert.True(t, lastRun.IsZero())
+
+	require.NoError(t, checker.Run(ctx))
+	links, lastRun = checker.Broken("tools")
+	assert.False(t, lastRun.IsZero())
+	require.Len(t, links, 1)
+	assert.Equal(t, "1.0.2", links[0].Version)
+	assert.Equal(t, http.StatusNotFound, links[0].StatusCode)
+	require.NotNil(t, links[0].BrokenSince)
+	assert.Nil(t, links[0].LastReachable)
+	brokenSince := *links[0].BrokenSince
+
+	// The outage keeps its start across runs
+	require.NoError(t, checker.Run(ctx))
+	links, _ = checker.Broken("tools")
+	require.Len(t, links, 1)
+	assert.Equal(t, brokenSince, *links[0].BrokenSince)
+	links, _ = checker.Broken("other")
+	assert.Empty(t, links)
+
+	// Versions broken for longer than yankAfter are deleted
+	yanking := NewLinkChecker(store, time.Nanosecond, logger)
+	require.NoError(t, yanking.Run(ctx)) // Starts the outage
+	time.Sleep(time.Millisecond)
+	require.NoError(t, yanking.Run(ctx))
+	links, _ = yanking.Broken("tools")
+	assert.Empty(t, links)
+	_, err = store.GetVersion(ctx, "tools", "deployer", "1.0.2")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.GetVersion(ctx, "tools", "deployer", "1.0.1")
+	assert.NoError(t, err)
+}
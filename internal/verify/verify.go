@@ -0,0 +1,256 @@
+// Package verify checks published archives in the background: that their
+// URL is still reachable, and that they still match the checksum they were
+// published with, since external hosts can replace or drop a file behind an
+// unchanged URL.
+package verify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/criteo/command-launcher-registry/internal/checksum"
+	"github.com/criteo/command-launcher-registry/internal/events"
+	"github.com/criteo/command-launcher-registry/internal/fips"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+// Verification statuses
+const (
+	StatusOK       = "ok"
+	StatusMismatch = "mismatch" // The archive no longer matches its checksum
+	StatusError    = "error"    // The archive could not be downloaded or hashed
+)
+
+// downloadTimeout bounds the download of one archive
+const downloadTimeout = 5 * time.Minute
+
+// Result is the outcome of the last verification of a version
+type Result struct {
+	Registry  string    `json:"registry"`
+	Package   string    `json:"package"`
+	Version   string    `json:"version"`
+	URL       string    `json:"url"`
+	Checksum  string    `json:"checksum"`         // Published checksum
+	Actual    string    `json:"actual,omitempty"` // Checksum of the downloaded archive, when it differs
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func (r Result) key() string {
+	return r.Registry + "/" + r.Package + "/" + r.Version
+}
+
+// Report summarizes the verifications since startup
+type Report struct {
+	Enabled  bool           `json:"enabled"`
+	LastRun  *time.Time     `json:"last_run,omitempty"`
+	Versions int            `json:"versions"` // Versions in storage at the last run
+	Counts   map[string]int `json:"counts"`   // Verified versions per status
+	Problems []Result       `json:"problems"` // Mismatched and failed versions
+}
+
+// Notifier publishes events about verified versions
+type Notifier interface {
+	Notify(ctx context.Context, eventType, registryName, packageName, version string)
+}
+
+// Verifier verifies a sample of versions on each run, resuming where the
+// previous run stopped, so every version is verified in turn
+type Verifier struct {
+	store    storage.Store
+	sample   int
+	notifier Notifier // nil publishes no events
+	client   *http.Client
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	cursor   string            // Key of the last version verified
+	results  map[string]Result // By version key
+	versions int
+	lastRun  time.Time
+}
+
+// New creates a verifier checking sample versions per run
+func New(store storage.Store, sample int, notifier Notifier, logger *slog.Logger) *Verifier {
+	return &Verifier{
+		store:    store,
+		sample:   sample,
+		notifier: notifier,
+		client:   newHTTPClient(),
+		logger:   logger,
+		results:  make(map[string]Result),
+	}
+}
+
+// newHTTPClient returns the client fetching archives, with TLS restricted
+// in FIPS mode
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	fips.RestrictTLS(transport)
+	return &http.Client{Transport: transport}
+}
+
+// Run verifies the next sample of versions; it is the scheduled job
+func (v *Verifier) Run(ctx context.Context) error {
+	targets, total, err := v.nextSample(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		result := v.verify(ctx, target)
+
+		v.mu.Lock()
+		previous, seen := v.results[result.key()]
+		v.results[result.key()] = result
+		v.cursor = result.key()
+		v.mu.Unlock()
+
+		switch result.Status {
+		case StatusMismatch:
+			v.logger.Warn("Archive no longer matches its checksum",
+				"registry", result.Registry,
+				"package", result.Package,
+				"version", result.Version,
+				"url", result.URL,
+				"checksum", result.Checksum,
+				"actual", result.Actual)
+			// Announce a mismatch once, not on every run
+			if v.notifier != nil && (!seen || previous.Status != StatusMismatch) {
+				v.notifier.Notify(ctx, events.VersionChecksumMismatch, result.Registry, result.Package, result.Version)
+			}
+		case StatusError:
+			v.logger.Warn("Archive could not be verified",
+				"registry", result.Registry,
+				"package", result.Package,
+				"version", result.Version,
+				"url", result.URL,
+				"error", result.Error)
+		}
+	}
+
+	v.mu.Lock()
+	v.versions = total
+	v.lastRun = time.Now().UTC()
+	v.mu.Unlock()
+	return nil
+}
+
+// nextSample returns the versions after the cursor, wrapping around, and
+// the number of versions. Results of versions gone from storage are dropped.
+func (v *Verifier) nextSample(ctx context.Context) ([]Result, int, error) {
+	registries, err := v.store.ListRegistries(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	var all []Result
+	for _, registry := range registries {
+		for _, pkg := range registry.Packages {
+			for _, ver := range pkg.Versions {
+				all = append(all, Result{
+					Registry: registry.Name,
+					Package:  pkg.Name,
+					Version:  ver.Version,
+					URL:      ver.URL,
+					Checksum: ver.Checksum,
+				})
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].key() < all[j].key() })
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	present := make(map[string]bool, len(all))
+	for _, target := range all {
+		present[target.key()] = true
+	}
+	for key := range v.results {
+		if !present[key] {
+			delete(v.results, key)
+		}
+	}
+
+	start := sort.Search(len(all), func(i int) bool { return all[i].key() > v.cursor })
+	n := min(v.sample, len(all))
+	sample := make([]Result, 0, n)
+	for i := 0; i < n; i++ {
+		sample = append(sample, all[(start+i)%len(all)])
+	}
+	return sample, len(all), nil
+}
+
+// verify downloads target's archive and compares its checksum
+func (v *Verifier) verify(ctx context.Context, target Result) Result {
+	result := target
+	result.CheckedAt = time.Now().UTC()
+
+	actual, err := v.download(ctx, target.URL, checksum.Algorithm(target.Checksum))
+	switch {
+	case err != nil:
+		result.Status = StatusError
+		result.Error = err.Error()
+	case !strings.EqualFold(actual, target.Checksum):
+		result.Status = StatusMismatch
+		result.Actual = actual
+	default:
+		result.Status = StatusOK
+	}
+	return result
+}
+
+// download returns the checksum of the content at url
+func (v *Verifier) download(ctx context.Context, url, algorithm string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download returned HTTP %d", resp.StatusCode)
+	}
+	return checksum.Compute(resp.Body, algorithm)
+}
+
+// Report returns the verification report
+func (v *Verifier) Report() Report {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	report := Report{
+		Enabled:  true,
+		Versions: v.versions,
+		Counts:   map[string]int{StatusOK: 0, StatusMismatch: 0, StatusError: 0},
+		Problems: []Result{},
+	}
+	if !v.lastRun.IsZero() {
+		lastRun := v.lastRun
+		report.LastRun = &lastRun
+	}
+	for _, result := range v.results {
+		report.Counts[result.Status]++
+		if result.Status != StatusOK {
+			report.Problems = append(report.Problems, result)
+		}
+	}
+	sort.Slice(report.Problems, func(i, j int) bool { return report.Problems[i].key() < report.Problems[j].key() })
+	return report
+}
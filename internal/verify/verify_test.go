@@ -0,0 +1,102 @@
+package verify
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/criteo/command-launcher-registry/internal/checksum"
+	"github.com/criteo/command-launcher-registry/internal/events"
+	"github.com/criteo/command-launcher-registry/internal/models"
+	"github.com/criteo/command-launcher-registry/internal/storage"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, eventType, registryName, packageName, version string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, eventType+" "+registryName+"/"+packageName+"/"+version)
+}
+
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	var mu sync.Mutex
+	archives := map[string]string{"/1.0.0.zip": "one", "/2.0.0.zip": "two"}
+	host := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		content, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer host.Close()
+
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "registry.json"), "", logger)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRegistry(ctx, &models.Registry{Name: "tools", Packages: map[string]*models.Package{}}))
+	require.NoError(t, store.CreatePackage(ctx, "tools", &models.Package{Name: "deployer", Versions: map[string]*models.Version{}}))
+	for i, version := range []string{"1.0.0", "2.0.0", "3.0.0"} {
+		content := archives["/"+version+".zip"]
+		sum, err := checksum.Compute(strings.NewReader(content), checksum.SHA256)
+		require.NoError(t, err)
+		require.NoError(t, store.CreateVersion(ctx, "tools", "deployer", &models.Version{
+			Name: "deployer", Version: version, Checksum: sum, URL: host.URL + "/" + version + ".zip", StartPartition: i, EndPartition: i,
+		}))
+	}
+
+	notifier := &recordingNotifier{}
+	verifier := New(store, 2, notifier, logger)
+
+	// Each run verifies the next versions, wrapping around
+	require.NoError(t, verifier.Run(ctx))
+	report := verifier.Report()
+	assert.Equal(t, 3, report.Versions)
+	assert.Equal(t, map[string]int{StatusOK: 2, StatusMismatch: 0, StatusError: 0}, report.Counts)
+	assert.Empty(t, report.Problems)
+
+	// The host replaces an archive
+	mu.Lock()
+	archives["/1.0.0.zip"] = "tampered"
+	mu.Unlock()
+
+	require.NoError(t, verifier.Run(ctx)) // 3.0.0, then 1.0.0
+	report = verifier.Report()
+	assert.Equal(t, map[string]int{StatusOK: 1, StatusMismatch: 1, StatusError: 1}, report.Counts)
+	require.Len(t, report.Problems, 2)
+	assert.Equal(t, "1.0.0", report.Problems[0].Version)
+	assert.Equal(t, StatusMismatch, report.Problems[0].Status)
+	assert.NotEmpty(t, report.Problems[0].Actual)
+	assert.Equal(t, "3.0.0", report.Problems[1].Version)
+	assert.Equal(t, StatusError, report.Problems[1].Status)
+	assert.Contains(t, report.Problems[1].Error, "404")
+
+	// A mismatch is announced once
+	require.NoError(t, verifier.Run(ctx))
+	require.NoError(t, verifier.Run(ctx))
+	assert.Equal(t, []string{events.VersionChecksumMismatch + " tools/deployer/1.0.0"}, notifier.events)
+
+	// Results of deleted versions are dropped
+	require.NoError(t, store.DeleteVersion(ctx, "tools", "deployer", "1.0.0"))
+	require.NoError(t, verifier.Run(ctx))
+	report = verifier.Report()
+	assert.Equal(t, 2, report.Versions)
+	assert.Zero(t, report.Counts[StatusMismatch])
+}
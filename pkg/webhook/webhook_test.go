@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedRequest(t *testing.T, body, secret []byte, now time.Time) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(body))
+	require.NoError(t, Sign(req, body, secret, now))
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	body := []byte(`{"type":"version.published"}`)
+	verifier := NewVerifier(time.Minute, []byte("old"), []byte("current"))
+
+	req := signedRequest(t, body, []byte("current"), now)
+	assert.Regexp(t, `^v1=[0-9a-f]{64}$`, req.Header.Get(SignatureHeader))
+	assert.NoError(t, verifier.Verify(req.Header, body, now.Add(30*time.Second)))
+
+	// The same request again is a replay, even within the window
+	assert.ErrorIs(t, verifier.Verify(req.Header, body, now.Add(40*time.Second)), ErrReplayed)
+
+	// Rotated-out secrets still verify while listed
+	assert.NoError(t, verifier.Verify(signedRequest(t, body, []byte("old"), now).Header, body, now))
+
+	assert.ErrorIs(t, verifier.Verify(signedRequest(t, body, []byte("other"), now).Header, body, now), ErrInvalidSignature)
+	assert.ErrorIs(t, verifier.Verify(signedRequest(t, body, []byte("current"), now).Header, []byte(`{}`), now), ErrInvalidSignature)
+	assert.ErrorIs(t, verifier.Verify(signedRequest(t, body, []byte("current"), now).Header, body, now.Add(2*time.Minute)), ErrExpired)
+	assert.ErrorIs(t, verifier.Verify(signedRequest(t, body, []byte("current"), now.Add(2*time.Minute)).Header, body, now), ErrExpired)
+	assert.ErrorIs(t, verifier.Verify(http.Header{}, body, now), ErrMissingSignature)
+
+	// A forged request does not use up the nonce of a genuine one
+	genuine := signedRequest(t, body, []byte("current"), now)
+	forged := genuine.Header.Clone()
+	forged.Set(SignatureHeader, "v1="+sign([]byte("other"), forged.Get(TimestampHeader), forged.Get(NonceHeader), body))
+	assert.ErrorIs(t, verifier.Verify(forged, body, now), ErrInvalidSignature)
+	assert.NoError(t, verifier.Verify(genuine.Header, body, now))
+}
+
+func TestNonceCache_Expiry(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	cache := NewNonceCache()
+
+	assert.True(t, cache.Add("a", now.Add(time.Minute), now))
+	assert.False(t, cache.Add("a", now.Add(time.Minute), now))
+	assert.True(t, cache.Add("a", now.Add(3*time.Minute), now.Add(2*time.Minute)))
+}
+
+func TestMiddleware(t *testing.T) {
+	body := []byte(`{"ok":true}`)
+	verifier := NewVerifier(0, []byte("secret"))
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		w.Write(got)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, body, []byte("secret"), time.Now()))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, body, rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -46,7 +46,7 @@ func TestOCIStorage_Integration(t *testing.T) {
 	require.True(t, parsedURI.IsOCIScheme())
 
 	// Create OCI storage
-	store, err := storage.NewStorage(parsedURI, token, logger)
+	store, err := storage.NewStorage(parsedURI, token, storage.Options{}, logger)
 	require.NoError(t, err)
 	defer store.Close()
 
@@ -118,7 +118,7 @@ func TestOCIStorage_FactoryValidation(t *testing.T) {
 		require.NoError(t, err)
 
 		// Creating OCI storage without token should fail
-		_, err = storage.NewStorage(uri, "", logger)
+		_, err = storage.NewStorage(uri, "", storage.Options{}, logger)
 		assert.Error(t, err)
 		assert.ErrorIs(t, err, storage.ErrTokenRequired)
 	})
@@ -128,7 +128,7 @@ func TestOCIStorage_FactoryValidation(t *testing.T) {
 		require.NoError(t, err)
 
 		// Creating file storage without token should succeed
-		store, err := storage.NewStorage(uri, "", logger)
+		store, err := storage.NewStorage(uri, "", storage.Options{}, logger)
 		require.NoError(t, err)
 		store.Close()
 
@@ -179,7 +179,7 @@ func testOCIStorageBasicOps(t *testing.T, uri, token string) {
 	parsedURI, err := storage.ParseStorageURI(uri)
 	require.NoError(t, err)
 
-	store, err := storage.NewStorage(parsedURI, token, logger)
+	store, err := storage.NewStorage(parsedURI, token, storage.Options{}, logger)
 	require.NoError(t, err)
 	defer store.Close()
 
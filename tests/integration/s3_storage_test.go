@@ -78,7 +78,7 @@ func TestS3Storage_FullCRUDLifecycle(t *testing.T) {
 	require.NoError(t, err, "Failed to parse S3 URI")
 
 	// Create storage
-	store, err := storage.NewS3Storage(storageURI, token, logger)
+	store, err := storage.NewS3Storage(storageURI, token, storage.Options{}, logger)
 	require.NoError(t, err, "Failed to create S3 storage")
 	defer store.Close()
 